@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	anonymizeHash = "hash"
+	anonymizeFake = "fake"
+	anonymizeMask = "mask"
+)
+
+// anonymizeRules is the format of the rules file used by `event anonymize`.
+type anonymizeRules struct {
+	// Salt is mixed into every hash so hashed values can't be reversed with a lookup table.
+	Salt  string          `yaml:"salt"`
+	Rules []anonymizeRule `yaml:"rules"`
+}
+
+type anonymizeRule struct {
+	// Path is a dot separated path to the field, `*` matches any key or array element.
+	Path string `yaml:"path"`
+	// Action is one of hash, fake or mask.
+	Action string `yaml:"action"`
+	// Kind selects the generated value for the fake action: name, email, phone, ipv4, uuid or
+	// string.
+	Kind string `yaml:"kind"`
+	// KeepLast leaves the last N characters untouched for the mask action.
+	KeepLast int `yaml:"keepLast"`
+}
+
+func parseAnonymizeRules(rulesPath string, reader fileReader) (anonymizeRules, error) {
+	data, err := reader.read(rulesPath)
+	if err != nil {
+		return anonymizeRules{}, fmt.Errorf("[in lambdalocal.parseAnonymizeRules] read file failed: %w", err)
+	}
+
+	rules := anonymizeRules{}
+	if err = yaml.Unmarshal(data, &rules); err != nil {
+		return anonymizeRules{}, fmt.Errorf("[in lambdalocal.parseAnonymizeRules] unmarshal yaml failed: %w", err)
+	}
+
+	for i, rule := range rules.Rules {
+		if rule.Path == "" {
+			return anonymizeRules{}, fmt.Errorf("[in lambdalocal.parseAnonymizeRules] rule %d has no path", i)
+		}
+
+		switch rule.Action {
+		case anonymizeHash, anonymizeFake, anonymizeMask:
+		default:
+			return anonymizeRules{}, fmt.Errorf(
+				"[in lambdalocal.parseAnonymizeRules] rule %d has unknown action '%s'",
+				i,
+				rule.Action,
+			)
+		}
+	}
+
+	return rules, nil
+}
+
+// RunAnonymizeEvent rewrites the fields of event matched by rules and writes the result to w.
+func RunAnonymizeEvent(w io.Writer, event string, rules anonymizeRules) error {
+	// numbers are decoded as json.Number and objects keep their key order, so the fields that aren't
+	// anonymized are written as they were
+	data, err := unmarshalOrderedJSON([]byte(event))
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunAnonymizeEvent] unmarshal event failed: %w", err)
+	}
+
+	for _, rule := range rules.Rules {
+		data = anonymizePath(data, strings.Split(rule.Path, "."), func(value any) any {
+			return anonymizeValue(value, rule, rules.Salt)
+		})
+	}
+
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunAnonymizeEvent] marshal event failed: %w", err)
+	}
+
+	if _, err = fmt.Fprintln(w, string(out)); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunAnonymizeEvent] write event failed: %w", err)
+	}
+
+	return nil
+}
+
+// anonymizePath walks data along path and replaces every matched leaf with the result of replace.
+func anonymizePath(data any, path []string, replace func(any) any) any {
+	if len(path) == 0 {
+		return replace(data)
+	}
+
+	key, rest := path[0], path[1:]
+
+	switch node := data.(type) {
+	case orderedObject:
+		for i, field := range node {
+			if key == "*" || key == field.key {
+				node[i].value = anonymizePath(field.value, rest, replace)
+			}
+		}
+	case []any:
+		for i, v := range node {
+			if key == "*" || key == strconv.Itoa(i) {
+				node[i] = anonymizePath(v, rest, replace)
+			}
+		}
+	}
+
+	return data
+}
+
+func anonymizeValue(value any, rule anonymizeRule, salt string) any {
+	// objects and arrays are anonymized leaf by leaf
+	switch node := value.(type) {
+	case orderedObject, []any:
+		return anonymizePath(node, []string{"*"}, func(v any) any {
+			return anonymizeValue(v, rule, salt)
+		})
+	case nil:
+		return nil
+	case json.Number:
+		// numbers stay numbers of the same length, a hash replaces all of their digits
+		if rule.Action != anonymizeFake {
+			sum := sha256.Sum256([]byte(salt + node.String()))
+			keepLast := rule.KeepLast
+
+			if rule.Action == anonymizeHash {
+				keepLast = 0
+			}
+
+			return json.Number(maskNumber(node.String(), keepLast, sum))
+		}
+	}
+
+	original := fmt.Sprint(value)
+	sum := sha256.Sum256([]byte(salt + original))
+
+	switch rule.Action {
+	case anonymizeHash:
+		return hex.EncodeToString(sum[:])
+	case anonymizeFake:
+		return fakeValue(rule.Kind, sum)
+	default:
+		return maskValue(original, rule.KeepLast, sum)
+	}
+}
+
+// fakeValue generates a substitute of the given kind. The generator is seeded from the hash of the
+// original value so the same input always yields the same substitute across events.
+func fakeValue(kind string, seed [32]byte) string {
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(seed[:8]), binary.BigEndian.Uint64(seed[8:16]))) //nolint:gosec
+
+	firstNames := []string{"Alex", "Blake", "Casey", "Devon", "Emery", "Finley", "Harper", "Jordan"}
+	lastNames := []string{"Adams", "Brooks", "Carter", "Diaz", "Ellis", "Foster", "Gray", "Hayes"}
+
+	first := firstNames[rng.IntN(len(firstNames))]
+	last := lastNames[rng.IntN(len(lastNames))]
+
+	switch kind {
+	case "name":
+		return first + " " + last
+	case "email":
+		return fmt.Sprintf(
+			"%s.%s%d@example.com",
+			strings.ToLower(first),
+			strings.ToLower(last),
+			rng.IntN(1000), //nolint:mnd
+		)
+	case "phone":
+		return fmt.Sprintf("+1555%07d", rng.IntN(10_000_000)) //nolint:mnd
+	case "ipv4":
+		return fmt.Sprintf("10.%d.%d.%d", rng.IntN(256), rng.IntN(256), rng.IntN(256)) //nolint:mnd
+	case "uuid":
+		return uuid.NewSHA1(uuid.NameSpaceOID, seed[:]).String()
+	default:
+		return "anon-" + hex.EncodeToString(seed[:6])
+	}
+}
+
+// maskValue replaces letters and digits while keeping punctuation, length and case so the result
+// still passes format validation.
+func maskValue(original string, keepLast int, seed [32]byte) string {
+	runes := []rune(original)
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(seed[:8]), binary.BigEndian.Uint64(seed[8:16]))) //nolint:gosec
+
+	for i, r := range runes {
+		if i >= len(runes)-keepLast {
+			break
+		}
+
+		switch {
+		case unicode.IsDigit(r):
+			runes[i] = rune('0' + rng.IntN(10)) //nolint:mnd
+		case unicode.IsUpper(r):
+			runes[i] = rune('A' + rng.IntN(26)) //nolint:mnd
+		case unicode.IsLetter(r):
+			runes[i] = rune('a' + rng.IntN(26)) //nolint:mnd
+		}
+	}
+
+	return string(runes)
+}
+
+// maskNumber replaces the digits of the JSON number original like maskValue. The first digit is
+// never replaced with a 0, so the result is still a valid number.
+func maskNumber(original string, keepLast int, seed [32]byte) string {
+	runes := []rune(original)
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(seed[:8]), binary.BigEndian.Uint64(seed[8:16]))) //nolint:gosec
+	first := true
+
+	for i, r := range runes {
+		if i >= len(runes)-keepLast {
+			break
+		}
+
+		if !unicode.IsDigit(r) {
+			continue
+		}
+
+		switch {
+		case first && r == '0':
+		case first:
+			runes[i] = rune('1' + rng.IntN(9)) //nolint:mnd
+		default:
+			runes[i] = rune('0' + rng.IntN(10)) //nolint:mnd
+		}
+
+		first = false
+	}
+
+	return string(runes)
+}
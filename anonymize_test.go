@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAnonymizeEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		event          string
+		rules          anonymizeRules
+		expectedOutput string
+		expectError    bool
+	}{
+		"hash nested field": {
+			event: `{"headers": {"Authorization": "Bearer token", "Accept": "*/*"}}`,
+			rules: anonymizeRules{
+				Rules: []anonymizeRule{{Path: "headers.Authorization", Action: anonymizeHash}},
+			},
+			expectedOutput: `{"headers": {
+				"Authorization": "b22ac30e61f624d5d9ecfaec62edc932976e15d2f1599809297f5422ed3b396b",
+				"Accept": "*/*"
+			}}`,
+		},
+		"mask keeps format and suffix": {
+			event: `{"card": "4111-1111-1111-1234"}`,
+			rules: anonymizeRules{
+				Rules: []anonymizeRule{{Path: "card", Action: anonymizeMask, KeepLast: 4}},
+			},
+		},
+		"wildcard over array": {
+			event: `{"Records": [{"email": "a@b.com"}, {"email": "c@d.com"}]}`,
+			rules: anonymizeRules{
+				Rules: []anonymizeRule{{Path: "Records.*.email", Action: anonymizeFake, Kind: "email"}},
+			},
+		},
+		"invalid event": {
+			event:       `{`,
+			rules:       anonymizeRules{},
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var buf bytes.Buffer
+
+				err := RunAnonymizeEvent(&buf, tc.event, tc.rules)
+				if tc.expectError {
+					assert.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.NotContains(t, buf.String(), "Bearer token")
+				assert.NotContains(t, buf.String(), "a@b.com")
+				assert.NotContains(t, buf.String(), "4111-1111-1111-1234")
+
+				if tc.expectedOutput != "" {
+					assert.JSONEq(t, tc.expectedOutput, buf.String())
+				}
+			},
+		)
+	}
+}
+
+func TestRunAnonymizeEventKeepsNumbersAndKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		action       string
+		keepLast     int
+		expectedCard string
+	}{
+		"mask": {
+			action:       anonymizeMask,
+			keepLast:     4,
+			expectedCard: `^[1-9]\d{14}6789$`,
+		},
+		"hash": {
+			action:       anonymizeHash,
+			expectedCard: `^[1-9]\d{18}$`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var buf bytes.Buffer
+
+				rules := anonymizeRules{
+					Rules: []anonymizeRule{{Path: "card", Action: tc.action, KeepLast: tc.keepLast}},
+				}
+
+				err := RunAnonymizeEvent(&buf, `{"orderId": 9007199254740993, "card": 1234567890123456789}`, rules)
+				require.NoError(t, err)
+
+				lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+				require.Len(t, lines, 4)
+				assert.Equal(t, `    "orderId": 9007199254740993,`, lines[1])
+
+				card, ok := strings.CutPrefix(lines[2], `    "card": `)
+				require.True(t, ok, lines[2])
+				assert.Regexp(t, tc.expectedCard, card)
+				assert.NotEqual(t, "1234567890123456789", card)
+			},
+		)
+	}
+}
+
+func TestAnonymizeIsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	rule := anonymizeRule{Action: anonymizeFake, Kind: "name"}
+
+	assert.Equal(t, anonymizeValue("Jane Doe", rule, "salt"), anonymizeValue("Jane Doe", rule, "salt"))
+	assert.NotEqual(t, anonymizeValue("Jane Doe", rule, "salt"), anonymizeValue("Jane Doe", rule, "pepper"))
+	assert.Equal(t, "4111-1111-1111-1234", "4111-1111-1111-"+anonymizeValue("4111-1111-1111-1234", anonymizeRule{
+		Action:   anonymizeMask,
+		KeepLast: 4,
+	}, "").(string)[15:]) //nolint:forcetypeassert
+}
+
+func TestParseAnonymizeRules(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mockReturn     []any
+		expectedRules  anonymizeRules
+		expectedErrStr string
+	}{
+		"valid rules": {
+			mockReturn: []any{
+				[]byte(`
+salt: abc
+rules:
+  - path: headers.Authorization
+    action: hash
+  - path: requestContext.identity.sourceIp
+    action: fake
+    kind: ipv4
+                `),
+				nil,
+			},
+			expectedRules: anonymizeRules{
+				Salt: "abc",
+				Rules: []anonymizeRule{
+					{Path: "headers.Authorization", Action: anonymizeHash},
+					{Path: "requestContext.identity.sourceIp", Action: anonymizeFake, Kind: "ipv4"},
+				},
+			},
+		},
+		"unknown action": {
+			mockReturn: []any{
+				[]byte(`
+rules:
+  - path: body
+    action: encrypt
+                `),
+				nil,
+			},
+			expectedErrStr: "unknown action 'encrypt'",
+		},
+		"file read error": {
+			mockReturn:     []any{[]byte{}, errors.New("test error")},
+			expectedErrStr: "[in lambdalocal.parseAnonymizeRules] read file failed:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "rules.yaml").Return(tc.mockReturn...).Once()
+
+				rules, err := parseAnonymizeRules("rules.yaml", mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedRules, rules)
+				}
+			},
+		)
+	}
+}
@@ -1,58 +1,263 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
-	"gopkg.in/yaml.v3"
 )
 
 const shutdownDuration = 5 * time.Second
 
+// unixSocketPrefix prefixes the listen addresses of unix domain sockets.
+const unixSocketPrefix = "unix:"
+
 type apiRoute struct {
 	method string
 	path   string
+	// function is the logical ID of the function the route belongs to.
+	function string
+	// binaryMediaTypes are the media types of request bodies that are base64 encoded, from the
+	// BinaryMediaTypes of REST APIs and --binary-media-types.
+	binaryMediaTypes []string
+	// payloadFormat is the event format sent to the lambda, payloadFormatV1 or payloadFormatV2.
+	payloadFormat string
+	// authorizer is the JWT authorizer of HttpApi routes that require a token.
+	authorizer *jwtAuthorizer
+	// cors is the CORS configuration of the route's API, nil without CORS.
+	cors *corsConfig
+	// rawPassthrough returns the body of the lambda's responses byte for byte.
+	rawPassthrough bool
+	// identity overrides the identity of the caller in the events.
+	identity callerIdentity
+	// memorySize is the MemorySize of the function in MB, 0 when the template doesn't set one.
+	memorySize int
+	// limits are the size limits of the requests and events of the route.
+	limits requestLimits
+	// faults are the latency and errors injected into the requests of the route.
+	faults faultInjection
+}
+
+const (
+	eventTypeAPI     = "Api"
+	eventTypeHTTPAPI = "HttpApi"
+
+	serverlessAPIType = "AWS::Serverless::Api"
+
+	// defaultRouteKey is the HttpApi route that matches requests no other route matches.
+	defaultRouteKey = "$default"
+	// defaultRoutePath is the path used for the $default route.
+	defaultRoutePath = "/{proxy+}"
+	// anyMethod is the method of routes that accept every HTTP method.
+	anyMethod = "ANY"
+	// requestIDHeader carries the correlation id of a request in the event and the response.
+	requestIDHeader = "X-Request-Id"
+)
+
+// routeKey returns the route in the "METHOD /path" form used by HttpApi. A route without a method
+// matches any method.
+func (r apiRoute) routeKey() string {
+	if r.method == "" && r.path == defaultRoutePath {
+		return defaultRouteKey
+	}
+
+	method := r.method
+	if method == "" {
+		method = anyMethod
+	}
+
+	return method + " " + r.path
+}
+
+// muxPattern converts the route to a http.ServeMux pattern. Greedy path parameters like
+// {proxy+} become {proxy...}.
+func (r apiRoute) muxPattern() string {
+	path := greedyParamRegex.ReplaceAllString(r.path, "{$1...}")
+
+	if r.method == "" {
+		return path
+	}
+
+	return r.method + " " + path
+}
+
+var greedyParamRegex = regexp.MustCompile(`{([^}]*)\+}`) //nolint:gochecknoglobals
+
+// serverConfig holds the limits of the local API server. Zero values keep the net/http defaults.
+type serverConfig struct {
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	idleTimeout    time.Duration
+	maxHeaderBytes int
+	// disableKeepAlive closes every connection after its response, like clients without keep-alive.
+	disableKeepAlive bool
+	// latencyBudgets are the maximum invocation latencies of routes, keyed by route key.
+	latencyBudgets map[string]time.Duration
+	// warmup invokes every route once on startup, functions in warmupEvents with their event.
+	warmup       bool
+	warmupEvents map[string]string
+	// hooks are the hook scripts invocations of every route pass through.
+	hooks *scriptHooks
+	// mocks answer their routes in place of the lambda, routes missing from the template are added.
+	mocks []mockRoute
+	// routing sends requests matching its rules to other functions or mocks.
+	routing conditionalRoutes
+	// controlToken authorizes control API requests, like the shutdown of --takeover. The control
+	// API is disabled without it.
+	controlToken string
+	// grpc transcodes gRPC requests into requests of the routes, gRPC is disabled without it.
+	grpc *grpcTranscoder
+	// async invokes the lambdas of the routes asynchronously, they are invoked synchronously without
+	// it.
+	async *asyncInvoker
+	// errorContentType is the content type of lambdalocal's own errors for clients accepting any.
+	errorContentType string
+	// workers run the invocations of the routes, every request invokes its lambda right away
+	// without it.
+	workers *workerPool
+	// concurrency throttles the invocations of functions beyond their reserved concurrency, nil
+	// without --reserved-concurrency.
+	concurrency *reservedConcurrency
+	// payloads writes the payloads of the invocations of the routes, nil without --output-dir.
+	payloads *payloadDump
+	// rawPassthrough returns the body of the lambda's responses as returned, without re-encoding it.
+	rawPassthrough bool
+	// binaryMediaTypes are added to the binary media types of every route.
+	binaryMediaTypes []string
+	// identity overrides the identity of the caller in the events of every route.
+	identity callerIdentity
+	// historySize is the number of invocations kept for the control API, none are kept when 0.
+	historySize int
+	// historyMaxBytes caps the size of the invocations kept for the control API with their payloads.
+	historyMaxBytes int64
+	// recordings is the store the invocations are recorded in, they are kept in memory without it.
+	recordings store
+	// tls serves the api over https, it is served over http without a certificate.
+	tls tlsConfig
+	// limits are the size limits of requests and invocation payloads.
+	limits requestLimits
+	// faults are the latency and errors injected into the requests of the routes.
+	faults faultInjection
+}
+
+// newHTTPServer creates the local API server on addr, applying the limits of config.
+func newHTTPServer(addr string, handler http.Handler, config serverConfig) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second, //nolint:mnd
+		ReadTimeout:       config.readTimeout,
+		WriteTimeout:      config.writeTimeout,
+		IdleTimeout:       config.idleTimeout,
+		MaxHeaderBytes:    config.maxHeaderBytes,
+	}
+
+	server.SetKeepAlivesEnabled(!config.disableKeepAlive)
+
+	return server
 }
 
 type lambdaCaller interface {
-	Invoke(data []byte) (messages.InvokeResponse, error)
+	Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error)
 }
 
 func RunLambdaAPI(
 	ctx context.Context,
 	w io.Writer,
 	lambdaRPC lambdaCaller,
-	port, templatePath string,
-	parseJSON bool,
+	functionCallers map[string]lambdaCaller,
+	listenAddr, templatePath, payloadFormat string,
+	parameterOverrides map[string]string,
+	jwt jwtConfig,
+	config serverConfig,
+	stats *statsRecorder,
+	format responseFormat,
 	logger *slog.Logger,
 ) error {
 	_, _ = fmt.Fprintln(w, line)
 
 	logger.Info("Starting local API Gateway for Lambda")
 
-	routes, err := parseTemplate(templatePath, osFileReader{})
+	routes, err := parseTemplate(templatePath, osFileReader{}, parameterOverrides)
 	if err != nil {
 		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] parseTemplate failed: %w", err)
 	}
 
-	if len(routes) == 0 {
-		return errors.New("[in lambdalocal.RunLambdaAPI] no routes found")
+	// explain why none of the resources of the template became a route
+	if len(routes) == 0 && len(config.mocks) == 0 {
+		var reasons strings.Builder
+		for _, diagnostic := range routeDiagnostics(templatePath, osFileReader{}, parameterOverrides) {
+			reasons.WriteString("\n  - " + diagnostic.String())
+		}
+
+		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] no routes found in %s:%s", templatePath, reasons.String())
+	}
+
+	// an explicit payload format overrides the one derived from the template
+	if payloadFormat != "" {
+		for i := range routes {
+			routes[i].payloadFormat = payloadFormat
+		}
+	}
+
+	memorySizes, err := functionMemorySizes(templatePath, osFileReader{}, parameterOverrides)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] %w", err)
+	}
+
+	// the binary media types of the flag are added to those of the template
+	binaryMediaTypes := normalizeBinaryMediaTypes(config.binaryMediaTypes)
+
+	for i := range routes {
+		routes[i].rawPassthrough = config.rawPassthrough
+		routes[i].identity = config.identity
+		routes[i].memorySize = memorySizes[routes[i].function]
+		routes[i].limits = config.limits
+		routes[i].faults = config.faults
+
+		if len(binaryMediaTypes) > 0 {
+			routes[i].binaryMediaTypes = append(slices.Clone(routes[i].binaryMediaTypes), binaryMediaTypes...)
+		}
+	}
+
+	// the issuer and audience flags override the JwtConfiguration of every authorizer
+	for i := range routes {
+		if routes[i].authorizer != nil {
+			authorizer := jwt.apply(*routes[i].authorizer)
+			routes[i].authorizer = &authorizer
+		}
+	}
+
+	if jwt.insecureDecode {
+		logger.Warn("JWT signatures are not verified, --jwt-insecure-decode is set")
 	}
 
-	if err = runServer(ctx, w, lambdaRPC, port, routes, parseJSON, logger); err != nil {
+	validator := newJWTValidator(jwt.insecureDecode)
+
+	if err = runServer(
+		ctx, w, lambdaRPC, functionCallers, listenAddr, routes, validator, config, stats, format, logger,
+	); err != nil {
 		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] runServer failed: %w", err)
 	}
 
@@ -63,28 +268,255 @@ func runServer(
 	ctx context.Context,
 	w io.Writer,
 	lambdaRPC lambdaCaller,
-	port string,
+	functionCallers map[string]lambdaCaller,
+	listenAddr string,
 	routes []apiRoute,
-	parseJSON bool,
+	validator *jwtValidator,
+	config serverConfig,
+	stats *statsRecorder,
+	format responseFormat,
 	logger *slog.Logger,
 ) error {
-	addr := fmt.Sprintf("%s:%s", "localhost", port)
+	network, address := listenNetwork(listenAddr)
+
+	host := "localhost"
+	if network == "tcp" {
+		host, _, _ = net.SplitHostPort(address)
+	}
+
+	scheme := "http"
+
+	var tlsConfig *tls.Config
+
+	if config.tls.enabled() {
+		cert, err := config.tls.certificate(host)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunLambdaAPI] %w", err)
+		}
+
+		if config.tls.selfSigned {
+			logger.Warn(
+				"Serving https with a self-signed certificate, clients have to trust it or skip verification",
+				"hosts", strings.Join(certificateHosts(host), ","),
+			)
+		}
+
+		scheme = "https"
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	// listening first resolves port 0 to the free port picked by the system, which the routes are
+	// printed with
+	listener, err := listen(network, address)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] %w", err)
+	}
+
+	// the routes are printed with an address that can be opened, also when served on all interfaces
+	baseURL := scheme + "://localhost"
+
+	if network == "tcp" {
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		listenAddr = net.JoinHostPort(host, port)
+		baseURL = scheme + "://" + reachableAddress(listenAddr)
+	} else {
+		logger.Info(fmt.Sprintf("Send requests through the socket, like curl --unix-socket %s %s/", address, baseURL))
+	}
+
 	router := http.NewServeMux()
 
+	// serve connection metrics next to the template routes
+	metrics := newConnMetrics(config.disableKeepAlive)
+	metrics.budgets = newLatencyBudgets(config.latencyBudgets, logger)
+	metrics.workers = config.workers
+	router.Handle("GET "+metricsPath, metrics)
+	logger.Info(fmt.Sprintf("metrics %s%s", baseURL, metricsPath))
+
+	// the control API serves the invocations, routes and metrics of the server to tooling
+	control := &controlAPI{metrics: metrics}
+
+	if config.recordings != nil {
+		control.history = newInvocationHistory(config.historySize, config.historyMaxBytes, config.recordings, logger)
+		control.history.recorded = true
+
+		if err = control.history.load(); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunLambdaAPI] %w", err)
+		}
+	} else {
+		control.history = newInvocationHistory(config.historySize, config.historyMaxBytes, newMemoryStore(), logger)
+	}
+
+	var warmupTargets []warmupTarget
+
+	// routeCaller returns the caller of the invocations of route's function
+	routeCaller := func(route apiRoute) lambdaCaller {
+		// routes of functions with their own address use that function's caller
+		caller := lambdaRPC
+		if functionCaller, ok := functionCallers[route.function]; ok {
+			caller = functionCaller
+		}
+
+		caller = config.concurrency.caller(caller, route.function)
+
+		if config.workers != nil {
+			caller = config.workers.caller(caller)
+		}
+
+		if config.payloads != nil {
+			caller = config.payloads.caller(caller)
+		}
+
+		if stats != nil {
+			caller = stats.caller(caller, route.routeKey())
+		}
+
+		caller = metrics.budgets.caller(caller, route.routeKey())
+		caller = config.hooks.caller(caller, route.routeKey())
+		caller = control.history.caller(caller, route)
+
+		if config.async != nil {
+			caller = config.async.caller(caller)
+		}
+
+		return caller
+	}
+
+	var routeKeys []string
+
 	// register routes from template.yaml
 	for _, route := range routes {
-		logger.Info(fmt.Sprintf("%s http://%s%s", route.method, addr, route.path))
+		routeKeys = append(routeKeys, route.routeKey())
+
+		method := route.method
+		if method == "" {
+			method = anyMethod
+		}
+
+		// requests matching a routing rule go to another function of the route or a mock
+		functionHandler := func(function string) http.Handler {
+			target := route
+			target.function = function
+
+			return gatewayHandler(routeCaller(target), format, target, validator, logger)
+		}
+
+		attrs := []any{"function", route.function}
+		if rules := len(config.routing.rules[route.routeKey()]); rules > 0 {
+			attrs = append(attrs, "routingRules", rules)
+		}
+
+		if mock, ok := mockFor(config.mocks, route); ok {
+			logger.Info(fmt.Sprintf("%s %s%s", method, baseURL, route.path), append(attrs, "mock", true)...)
+			control.routes = append(control.routes, newControlRoute(route, true))
+			router.Handle(
+				route.muxPattern(),
+				config.routing.handler(route, mock.handler(logger), functionHandler, logger),
+			)
+
+			continue
+		}
+
+		control.routes = append(control.routes, newControlRoute(route, false))
+
+		caller := routeCaller(route)
+		warmupTargets = append(warmupTargets, warmupTarget{route: route, caller: caller})
+
+		if route.authorizer != nil {
+			attrs = append(attrs, "authorizer", route.authorizer.name)
+		}
+
+		logger.Info(fmt.Sprintf("%s %s%s", method, baseURL, route.path), attrs...)
 		router.Handle(
-			fmt.Sprintf("%s %s", route.method, route.path),
-			gatewayHandler(lambdaRPC, parseJSON, route, logger),
+			route.muxPattern(),
+			config.routing.handler(
+				route,
+				gatewayHandler(caller, format, route, validator, logger),
+				functionHandler,
+				logger,
+			),
 		)
 	}
 
-	// Create a simple HTTP server
-	server := &http.Server{
-		Addr:              addr,
-		Handler:           router,
-		ReadHeaderTimeout: 5 * time.Second, //nolint:mnd
+	// mocks of routes that aren't in the template are added
+	for _, mock := range config.mocks {
+		if slices.Contains(routeKeys, mock.route.routeKey()) {
+			continue
+		}
+
+		routeKeys = append(routeKeys, mock.route.routeKey())
+
+		functionHandler := func(function string) http.Handler {
+			target := mock.route
+			target.function = function
+			target.payloadFormat = payloadFormatV2
+			target.rawPassthrough = config.rawPassthrough
+			target.identity = config.identity
+
+			return gatewayHandler(routeCaller(target), format, target, validator, logger)
+		}
+
+		method := cmp.Or(mock.route.method, anyMethod)
+		logger.Info(fmt.Sprintf("%s %s%s", method, baseURL, mock.route.path), "mock", true)
+		control.routes = append(control.routes, newControlRoute(mock.route, true))
+		router.Handle(
+			mock.route.muxPattern(),
+			config.routing.handler(mock.route, mock.handler(logger), functionHandler, logger),
+		)
+	}
+
+	for _, route := range config.routing.unknownRoutes(routeKeys) {
+		logger.Warn(fmt.Sprintf("routing rules of '%s' don't match a route of the template or a mock", route))
+	}
+
+	for _, route := range metrics.budgets.unknownRoutes(routes) {
+		logger.Warn(fmt.Sprintf("latency budget of '%s' doesn't match a route of the template", route))
+	}
+
+	// serve the Invoke action of the Lambda API, for functions invoking each other with an SDK
+	async := config.async
+	if async == nil {
+		async = newAsyncInvoker(asyncPolicy{retries: asyncDefaultRetries, backoff: asyncDefaultBackoff}, nil, logger)
+		defer async.Wait()
+	}
+
+	lambdaAPI := newLambdaAPIHandler(lambdaRPC, functionCallers, routes, async, format, logger)
+	lambdaAPI.limits = config.limits
+	lambdaAPI.concurrency = config.concurrency
+
+	router.Handle("POST "+lambdaInvokePath, lambdaAPI)
+	logger.Info("Lambda API "+baseURL, "path", lambdaInvokePath)
+
+	control.register(router)
+	logger.Info(fmt.Sprintf("control API %s%s", baseURL, controlAPIPrefix))
+
+	// answer CORS preflight requests of APIs with CORS like API Gateway
+	for _, preflight := range corsPreflights(routes) {
+		logger.Info(fmt.Sprintf("%s %s%s", http.MethodOptions, baseURL, preflight.path), "cors", "preflight")
+		router.Handle(preflight.pattern, preflight.handler(logger))
+	}
+
+	// Create a simple HTTP server, its own errors are negotiated with the client
+	handler := negotiatedErrors(router, config.errorContentType)
+
+	// gRPC clients speak HTTP/2 without TLS, their requests are transcoded into route requests
+	if config.grpc != nil {
+		for _, path := range config.grpc.paths() {
+			rule := config.grpc.methods[path].rule
+			logger.Info(fmt.Sprintf("gRPC %s%s", baseURL, path), "route", rule.method+" "+rule.path)
+		}
+
+		handler = config.grpc.handler(handler, logger)
+	}
+
+	server := newHTTPServer(listenAddr, handler, config)
+	server.ConnState = metrics.connState
+	server.TLSConfig = tlsConfig
+
+	if config.grpc != nil {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(tlsConfig != nil)
+		server.Protocols.SetUnencryptedHTTP2(true)
 	}
 
 	wg, ctx := errgroup.WithContext(ctx)
@@ -93,6 +525,19 @@ func runServer(
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
+	// another instance started with --takeover shuts the server down like a signal
+	if config.controlToken != "" {
+		shutdown := func() {
+			select {
+			case quit <- syscall.SIGTERM:
+			default:
+			}
+		}
+
+		router.Handle("POST "+shutdownPath, shutdownHandler(config.controlToken, shutdown, logger))
+		router.Handle("POST "+controlAPIPrefix+"/shutdown", shutdownHandler(config.controlToken, shutdown, logger))
+	}
+
 	wg.Go(
 		func() error {
 			<-quit
@@ -116,11 +561,30 @@ func runServer(
 		},
 	)
 
+	// warm up the routes in process while the server starts
+	if config.warmup {
+		wg.Go(
+			func() error {
+				warmupRoutes(ctx, router, warmupTargets, config.warmupEvents, logger)
+
+				return nil
+			},
+		)
+	}
+
 	// Start the server in a separate goroutine
-	logger.Info("Starting server on " + addr)
+	logger.Info("Starting server on " + listenAddr)
 
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] ListenAndServe: %w", err)
+	serve := server.Serve
+	if tlsConfig != nil {
+		// the certificate is in TLSConfig
+		serve = func(listener net.Listener) error {
+			return server.ServeTLS(listener, "", "")
+		}
+	}
+
+	if err := serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("[in lambdalocal.RunLambdaAPI] Serve: %w", err)
 	}
 
 	if err := wg.Wait(); err != nil {
@@ -142,6 +606,20 @@ type genericAPIEvent struct {
 	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
 	PathParameters                  map[string]string   `json:"pathParameters"`
 	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  apiRequestContext   `json:"requestContext"`
+}
+
+type apiRequestContext struct {
+	Identity apiIdentity `json:"identity"`
+	// Authorizer is only set for routes with a JWT authorizer.
+	Authorizer *apiAuthorizer `json:"authorizer,omitempty"`
+}
+
+// apiAuthorizer holds the claims of a JWT authorizer in payload format 1.0 events.
+type apiAuthorizer struct {
+	Claims map[string]string `json:"claims"`
+	Scopes []string          `json:"scopes"`
 }
 
 type genericAPIResponse struct {
@@ -150,12 +628,15 @@ type genericAPIResponse struct {
 	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
 	Body              string              `json:"body"`
 	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+	// Cookies is only set by payload format 2.0 responses.
+	Cookies []string `json:"cookies,omitempty"`
 }
 
 func gatewayHandler(
 	lambdaRPC lambdaCaller,
-	parseJSON bool,
+	format responseFormat,
 	route apiRoute,
+	validator *jwtValidator,
 	logger *slog.Logger,
 ) http.Handler {
 	// get path param keys
@@ -166,42 +647,183 @@ func gatewayHandler(
 
 	for _, match := range matches {
 		if len(match) > 1 {
-			pathParamKeys = append(pathParamKeys, match[1])
+			pathParamKeys = append(pathParamKeys, strings.TrimSuffix(match[1], "+"))
+		}
+	}
+
+	// select the event format of the route
+	parseRequest := func(r *http.Request) ([]byte, error) {
+		return parseHTTPRequest(r, pathParamKeys, route.path, route.binaryMediaTypes, route.identity)
+	}
+	returnResponse := returnHTTPResponse
+
+	if route.payloadFormat == payloadFormatV2 {
+		parseRequest = func(r *http.Request) ([]byte, error) {
+			return parseHTTPAPIRequest(r, pathParamKeys, route)
+		}
+		returnResponse = returnHTTPAPIResponse
+	}
+
+	if route.rawPassthrough {
+		returnResponse = returnRawHTTPResponse
+
+		if route.payloadFormat == payloadFormatV2 {
+			returnResponse = returnRawHTTPAPIResponse
 		}
 	}
 
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			// correlate the request, event, invocation, log lines and response with one id
+			requestID := uuid.New().String()
+			r.Header.Set(requestIDHeader, requestID)
+			w.Header().Set(requestIDHeader, requestID)
+
+			// the trace of the client is continued in the event and the invocation
+			traceHeader := requestTraceHeader(r.Header.Get(traceIDHeader), time.Now())
+			r.Header.Set(traceIDHeader, traceHeader)
+
+			logger := logger.With("requestId", requestID)
+
+			// HttpApi adds its CORS headers to every response of a cross-origin request
+			if origin := r.Header.Get("Origin"); route.cors != nil && route.cors.httpAPI && origin != "" {
+				w = &corsResponseWriter{ResponseWriter: w, cors: route.cors, origin: origin}
+			}
+
+			if r.Method == http.MethodHead {
+				w = headResponseWriter{ResponseWriter: w}
+			}
+
 			fmt.Println(line) //nolint:forbidigo
 			logger.Info("Handling request for: " + route.path)
 			logger.Info("URL request path: " + r.URL.Path)
 
-			eventByte, err := parseHTTPRequest(r, pathParamKeys, route.path)
+			if logger.Enabled(r.Context(), slog.LevelDebug) {
+				logger.Debug("Request headers", "headers", format.redaction.requestHeaders(r.Header))
+			}
+
+			if !route.limits.limitBody(w, r) {
+				logger.Warn("[in lambdalocal.RunLambdaAPI] request too large", "size", r.ContentLength)
+
+				return
+			}
+
+			// routes with a JWT authorizer only invoke the lambda for valid tokens
+			if route.authorizer != nil {
+				claims, err := validator.authorize(r, *route.authorizer)
+				if err != nil {
+					logger.Warn(
+						"[in lambdalocal.RunLambdaAPI] token rejected",
+						"authorizer", route.authorizer.name,
+						"err", err,
+					)
+					writeAuthorizerError(w, err)
+
+					return
+				}
+
+				r = r.WithContext(withJWTClaims(r.Context(), claims))
+			}
+
+			eventByte, err := parseRequest(r)
+			if isBodyTooLarge(err) {
+				logger.Warn("[in lambdalocal.RunLambdaAPI] request too large", "err", err)
+				writeGatewayError(w, r, gatewayMessageTooLong, http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
 			if err != nil {
 				logger.Error("[in lambdalocal.RunLambdaAPI] parseHTTPRequest failed", "err", err)
-				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusBadRequest)
+				writeGatewayError(w, r, gatewayMessageBadRequest, http.StatusBadRequest)
+
+				return
+			}
+
+			// the event includes the headers and the base64 encoding of binary bodies, so it can
+			// exceed the payload limit of Lambda while the body doesn't
+			if route.limits.exceedsPayload(len(eventByte)) {
+				logger.Warn("[in lambdalocal.RunLambdaAPI] event too large", "size", len(eventByte))
+				writeGatewayError(w, r, route.limits.payloadMessage(), http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			// slow down and fail requests on purpose, like a slow or failing lambda would
+			latency, err := route.faults.delay(r.Context())
+			if err != nil {
+				logger.Warn("[in lambdalocal.RunLambdaAPI] client disconnected during injected latency")
+
+				return
+			}
+
+			if latency > 0 {
+				logger.Info("Injected latency", "latency", latency)
+			}
+
+			if route.faults.fails() {
+				logger.Warn("Injected error, the lambda isn't invoked")
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusBadGateway)
+
+				return
+			}
+
+			invokeResponse, err := lambdaRPC.Invoke(
+				r.Context(),
+				eventByte,
+				WithRequestID(requestID),
+				WithTraceID(traceHeader),
+				WithMemorySize(route.memorySize),
+			)
+			if errors.Is(err, errWorkerPoolSaturated) || errors.Is(err, errFunctionThrottled) {
+				// throttled like API Gateway and Lambda, the client can retry once invocations finished
+				logger.Warn("[in lambdalocal.RunLambdaAPI] invocation throttled", "err", err)
+				writeGatewayError(w, r, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+				return
+			}
+
+			if errors.Is(err, errInvocationTimeout) {
+				// API Gateway gives up on integrations that exceed their timeout
+				logger.Error("[in lambdalocal.RunLambdaAPI] invoke timed out", "err", err)
+				writeGatewayError(w, r, gatewayMessageTimeout, http.StatusGatewayTimeout)
+
+				return
+			}
+
+			// the client is gone, nobody reads the response
+			if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+				logger.Warn("[in lambdalocal.RunLambdaAPI] client disconnected, invocation canceled")
 
 				return
 			}
 
-			invokeResponse, err := lambdaRPC.Invoke(eventByte)
 			if err != nil {
 				logger.Error("[in lambdalocal.RunLambdaAPI] invoke failed", "err", err)
-				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				logRPCDrift(logger, err)
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusBadGateway)
 
 				return
 			}
 
-			if err = printResponse(logger, invokeResponse, parseJSON); err != nil {
+			if err = printResponse(logger, invokeResponse, format); err != nil {
 				logger.Error("[in lambdalocal.RunLambdaAPI] printResponse failed", "err", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusBadGateway)
 
 				return
 			}
 
-			if err = returnHTTPResponse(w, invokeResponse); err != nil {
+			// like API Gateway, errors of the function are answered without their message, which is
+			// logged by printResponse
+			if invokeResponse.Error != nil {
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusBadGateway)
+
+				return
+			}
+
+			if err = returnResponse(w, invokeResponse); err != nil {
 				logger.Error("[in lambdalocal.RunLambdaAPI] returnHTTPResponse failed", "err", err)
-				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusInternalServerError)
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusBadGateway)
 
 				return
 			}
@@ -209,15 +831,74 @@ func gatewayHandler(
 	)
 }
 
-func parseHTTPRequest(r *http.Request, pathParamKeys []string, resourcePath string) ([]byte, error) {
-	// read body
-	requestBody, err := io.ReadAll(r.Body)
+// listenNetwork returns the network and address of a listen address: a unix domain socket for
+// unix:PATH, and a TCP host:port otherwise.
+func listenNetwork(listenAddr string) (string, string) {
+	if path, ok := strings.CutPrefix(listenAddr, unixSocketPrefix); ok {
+		return "unix", path
+	}
+
+	return "tcp", listenAddr
+}
+
+// listen listens on address of network. The socket file of a unix domain socket left behind by a
+// process that didn't shut down is replaced, a socket that is still served is an error.
+func listen(network, address string) (net.Listener, error) {
+	if network == "unix" {
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial(network, address); err == nil {
+				_ = conn.Close()
+
+				return nil, fmt.Errorf("socket '%s' is already in use", address)
+			}
+
+			if err = os.Remove(address); err != nil {
+				return nil, fmt.Errorf("remove stale socket '%s' failed: %w", address, err)
+			}
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("listen failed: %w", err)
+	}
+
+	return listener, nil
+}
+
+// reachableAddress returns addr with an unspecified host, like 0.0.0.0 or ::, replaced by
+// localhost, so it can be opened and connected to.
+func reachableAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return net.JoinHostPort("localhost", port)
+	}
+
+	return addr
+}
+
+func parseHTTPRequest(
+	r *http.Request,
+	pathParamKeys []string,
+	resourcePath string,
+	binaryMediaTypes []string,
+	identity callerIdentity,
+) ([]byte, error) {
+	// read body, large bodies are spilled to disk
+	requestBody, err := readRequestBody(r.Body, bodyMemoryLimit)
 	if err != nil {
 		return nil, fmt.Errorf("[in lambdalocal.RunLambdaAPI] failed to read request body: %w", err)
 	}
+	defer func() {
+		_ = requestBody.Close()
+	}()
 
 	// path parameters
-	pathParams := make(map[string]string)
+	pathParams := make(map[string]string, len(pathParamKeys))
 
 	for _, paramKey := range pathParamKeys {
 		paramValue := r.PathValue(paramKey)
@@ -227,8 +908,8 @@ func parseHTTPRequest(r *http.Request, pathParamKeys []string, resourcePath stri
 	}
 
 	// Extract headers
-	headers := make(map[string]string)
-	multiValueHeaders := make(map[string][]string)
+	headers := make(map[string]string, len(r.Header))
+	multiValueHeaders := make(map[string][]string, len(r.Header))
 
 	for key, values := range r.Header {
 		headers[key] = values[0]
@@ -236,15 +917,27 @@ func parseHTTPRequest(r *http.Request, pathParamKeys []string, resourcePath stri
 	}
 
 	// Extract query string parameters
-	queryStringParameters := make(map[string]string)
-	multiValueQueryStringParameters := make(map[string][]string)
+	query := r.URL.Query()
+	queryStringParameters := make(map[string]string, len(query))
+	multiValueQueryStringParameters := make(map[string][]string, len(query))
 
-	for key, values := range r.URL.Query() {
+	for key, values := range query {
 		queryStringParameters[key] = values[len(values)-1]
 		multiValueQueryStringParameters[key] = values
 	}
 
-	eventByte, err := json.Marshal(
+	body, isBase64Encoded := requestBody.field(isBinaryRequest(r.Header, binaryMediaTypes))
+
+	caller := identity.resolve(r)
+	requestContext := apiRequestContext{
+		Identity: apiIdentity{SourceIP: caller.sourceIP, UserAgent: caller.userAgent, UserARN: caller.userARN},
+	}
+
+	if claims := jwtClaimsFrom(r.Context()); claims != nil {
+		requestContext.Authorizer = &apiAuthorizer{Claims: claims.flatClaims(), Scopes: claims.scopes}
+	}
+
+	eventByte, err := requestBody.marshal(
 		genericAPIEvent{
 			Resource:                        resourcePath,
 			Path:                            r.URL.Path,
@@ -254,8 +947,11 @@ func parseHTTPRequest(r *http.Request, pathParamKeys []string, resourcePath stri
 			QueryStringParameters:           queryStringParameters,
 			MultiValueQueryStringParameters: multiValueQueryStringParameters,
 			PathParameters:                  pathParams,
-			Body:                            string(requestBody),
+			Body:                            body,
+			IsBase64Encoded:                 isBase64Encoded,
+			RequestContext:                  requestContext,
 		},
+		isBase64Encoded,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("[in lambdalocal.parseHTTPRequest] marshal response failed: %w", err)
@@ -264,20 +960,33 @@ func parseHTTPRequest(r *http.Request, pathParamKeys []string, resourcePath stri
 	return eventByte, nil
 }
 
-func outputLambdaResponse(invokeResponse messages.InvokeResponse, parseJSON bool) (string, error) {
+// marshalEvent returns the JSON of an API event. Unlike json.Marshal it doesn't escape '<', '>' and
+// '&', so XML and HTML bodies reach the lambda as they were sent.
+func marshalEvent(event any) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(event); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	// the event outlives the pooled buffer, it is copied once at its final size
+	return bytes.Clone(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+func outputLambdaResponse(invokeResponse messages.InvokeResponse, format responseFormat) (string, error) {
 	responseMap := make(map[string]any)
 
 	responseMap["Error"] = invokeResponse.Error
 
-	responseBody := make(map[string]any)
-	if err := json.Unmarshal(invokeResponse.Payload, &responseBody); err != nil {
+	responseBody, err := decodePayload(invokeResponse.Payload, format)
+	if err != nil {
 		return "", fmt.Errorf("[in lambdalocal.outputLambdaResponse] unmarshal response failed: %w", err)
 	}
 
-	if parseJSON {
-		responseBody = parseInnerJSON(responseBody)
-	}
-
 	responseMap["Payload"] = responseBody
 
 	out, err := json.MarshalIndent(responseMap, "", "    ")
@@ -291,45 +1000,174 @@ func outputLambdaResponse(invokeResponse messages.InvokeResponse, parseJSON bool
 func returnHTTPResponse(w http.ResponseWriter, invokeResponse messages.InvokeResponse) error {
 	APIResponse := genericAPIResponse{}
 
-	if invokeResponse.Error != nil {
-		http.Error(w, invokeResponse.Error.Message, http.StatusInternalServerError)
-
-		return nil //nolint:nilerr
-	}
-
 	if err := json.Unmarshal(invokeResponse.Payload, &APIResponse); err != nil {
 		return fmt.Errorf("[in lambdalocal.returnHTTPResponse] Unmarshal payload failed: %w", err)
 	}
 
-	// headers
-	for k, v := range APIResponse.Headers {
+	// binary bodies are returned base64 encoded by the lambda
+	body := []byte(APIResponse.Body)
+
+	if APIResponse.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(APIResponse.Body)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.returnHTTPResponse] decode base64 body failed: %w", err)
+		}
+
+		body = decoded
+	}
+
+	return writeAPIResponse(w, APIResponse, body)
+}
+
+// writeAPIResponse writes the status code and headers of response, and body.
+func writeAPIResponse(w http.ResponseWriter, response genericAPIResponse, body []byte) error {
+	// headers, like API Gateway the values of multiValueHeaders replace those of headers
+	for k, v := range response.Headers {
 		w.Header().Set(k, v)
 	}
 
-	// status code
-	if APIResponse.StatusCode == 0 {
-		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		w.WriteHeader(APIResponse.StatusCode)
+	for k, values := range response.MultiValueHeaders {
+		w.Header().Del(k)
+
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
 	}
 
-	// body
-	if _, err := w.Write([]byte(APIResponse.Body)); err != nil {
-		return fmt.Errorf("[in lambdalocal.returnHTTPResponse] Write body failed: %w", err)
+	for _, cookie := range response.Cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+
+	statusCode := cmp.Or(response.StatusCode, http.StatusInternalServerError)
+
+	return writeBody(w, statusCode, body)
+}
+
+// writeBody writes statusCode and body with an exact Content-Length. The framing headers returned
+// by the lambda are dropped, the body is sent as is and not chunked. 1xx, 204 and 304 responses
+// are sent without a body.
+func writeBody(w http.ResponseWriter, statusCode int, body []byte) error {
+	w.Header().Del("Transfer-Encoding")
+	w.Header().Del("Content-Length")
+
+	if !bodyAllowedForStatus(statusCode) {
+		w.WriteHeader(statusCode)
+
+		return nil
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("[in lambdalocal.writeBody] Write body failed: %w", err)
 	}
 
 	return nil
 }
 
+// bodyAllowedForStatus reports whether responses with statusCode may have a body, per RFC 9110.
+func bodyAllowedForStatus(statusCode int) bool {
+	switch {
+	case statusCode >= 100 && statusCode < 200:
+		return false
+	case statusCode == http.StatusNoContent, statusCode == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
+
+// headResponseWriter answers HEAD requests with the headers, including the Content-Length, of the
+// response a GET request would get, and discards its body.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
 type samTemplate struct {
+	Globals struct {
+		Function struct {
+			MemorySize  any            `yaml:"MemorySize"`  //nolint:tagliatelle
+			Environment samEnvironment `yaml:"Environment"` //nolint:tagliatelle
+		} `yaml:"Function"` //nolint:tagliatelle
+		API struct {
+			BinaryMediaTypes []string `yaml:"BinaryMediaTypes"` //nolint:tagliatelle
+			Cors             any      `yaml:"Cors"`             //nolint:tagliatelle
+		} `yaml:"Api"` //nolint:tagliatelle
+		HTTPAPI struct {
+			Auth              samHTTPAPIAuth `yaml:"Auth"`              //nolint:tagliatelle
+			CorsConfiguration any            `yaml:"CorsConfiguration"` //nolint:tagliatelle
+		} `yaml:"HttpApi"` //nolint:tagliatelle
+	} `yaml:"Globals"` //nolint:tagliatelle
 	Resources map[string]struct {
-		Type       string `yaml:"Type"` //nolint:tagliatelle
+		Type     string `yaml:"Type"` //nolint:tagliatelle
+		Metadata struct {
+			LambdaLocal struct {
+				// DefaultEvent is the path, relative to the template, of the event used by
+				// `event --function` when no event is given.
+				DefaultEvent string `yaml:"DefaultEvent"` //nolint:tagliatelle
+			} `yaml:"LambdaLocal"` //nolint:tagliatelle
+		} `yaml:"Metadata"` //nolint:tagliatelle
 		Properties struct {
+			// BinaryMediaTypes is set on AWS::Serverless::Api resources.
+			BinaryMediaTypes []string `yaml:"BinaryMediaTypes"` //nolint:tagliatelle
+			// Cors is set on AWS::Serverless::Api resources.
+			Cors any `yaml:"Cors"` //nolint:tagliatelle
+			// Auth and CorsConfiguration are read from AWS::Serverless::HttpApi resources.
+			Auth              samHTTPAPIAuth `yaml:"Auth"`              //nolint:tagliatelle
+			CorsConfiguration any            `yaml:"CorsConfiguration"` //nolint:tagliatelle
+			// DefinitionBody and DefinitionURI hold the OpenAPI definition of both API types.
+			DefinitionBody any `yaml:"DefinitionBody"` //nolint:tagliatelle
+			DefinitionURI  any `yaml:"DefinitionUri"`  //nolint:tagliatelle
+			// Location, TemplateURL and Parameters are set on AWS::Serverless::Application and
+			// AWS::CloudFormation::Stack resources.
+			Location    any            `yaml:"Location"`    //nolint:tagliatelle
+			TemplateURL string         `yaml:"TemplateURL"` //nolint:tagliatelle
+			Parameters  map[string]any `yaml:"Parameters"`  //nolint:tagliatelle
+			// MemorySize and Environment are set on AWS::Serverless::Function resources.
+			MemorySize  any            `yaml:"MemorySize"`  //nolint:tagliatelle
+			Environment samEnvironment `yaml:"Environment"` //nolint:tagliatelle
+			// TableName is set on AWS::DynamoDB::Table resources.
+			TableName any `yaml:"TableName"` //nolint:tagliatelle
+			// Name is set on AWS::Events::EventBus resources.
+			Name   any `yaml:"Name"` //nolint:tagliatelle
 			Events map[string]struct {
 				Type       string `yaml:"Type"` //nolint:tagliatelle
 				Properties struct {
-					Path   string `yaml:"Path"`   //nolint:tagliatelle
-					Method string `yaml:"Method"` //nolint:tagliatelle
+					Path                 string       `yaml:"Path"`                 //nolint:tagliatelle
+					Method               string       `yaml:"Method"`               //nolint:tagliatelle
+					PayloadFormatVersion string       `yaml:"PayloadFormatVersion"` //nolint:tagliatelle
+					APIID                any          `yaml:"ApiId"`                //nolint:tagliatelle
+					RestAPIID            any          `yaml:"RestApiId"`            //nolint:tagliatelle
+					Auth                 samEventAuth `yaml:"Auth"`                 //nolint:tagliatelle
+					// BatchSize and FunctionResponseTypes are set on SQS and stream events.
+					BatchSize             int      `yaml:"BatchSize"`             //nolint:tagliatelle
+					FunctionResponseTypes []string `yaml:"FunctionResponseTypes"` //nolint:tagliatelle
+					// Stream and StartingPosition are set on DynamoDB events.
+					Stream           any    `yaml:"Stream"`           //nolint:tagliatelle
+					StartingPosition string `yaml:"StartingPosition"` //nolint:tagliatelle
+					// Schedule is set on Schedule events, ScheduleExpression and
+					// ScheduleExpressionTimezone on ScheduleV2 events. Both can set Name, Input,
+					// State and, on Schedule events, Enabled.
+					Schedule                   string `yaml:"Schedule"`                   //nolint:tagliatelle
+					ScheduleExpression         string `yaml:"ScheduleExpression"`         //nolint:tagliatelle
+					ScheduleExpressionTimezone string `yaml:"ScheduleExpressionTimezone"` //nolint:tagliatelle
+					Name                       string `yaml:"Name"`                       //nolint:tagliatelle
+					Input                      string `yaml:"Input"`                      //nolint:tagliatelle
+					State                      string `yaml:"State"`                      //nolint:tagliatelle
+					Enabled                    *bool  `yaml:"Enabled"`                    //nolint:tagliatelle
+					// Pattern, EventBusName and InputPath are set on EventBridgeRule events.
+					Pattern      any    `yaml:"Pattern"`      //nolint:tagliatelle
+					EventBusName any    `yaml:"EventBusName"` //nolint:tagliatelle
+					InputPath    string `yaml:"InputPath"`    //nolint:tagliatelle
 				} `yaml:"Properties"` //nolint:tagliatelle
 			} `yaml:"Events"` //nolint:tagliatelle
 		} `yaml:"Properties"` //nolint:tagliatelle
@@ -346,29 +1184,232 @@ type fileReader interface {
 	read(name string) ([]byte, error)
 }
 
-func parseTemplate(templatePath string, reader fileReader) ([]apiRoute, error) {
+// parseTemplate returns the routes of the template at templatePath and of its nested stacks.
+// overrides set the values of the template's Parameters.
+func parseTemplate(templatePath string, reader fileReader, overrides map[string]string) ([]apiRoute, error) {
+	return parseStack(templatePath, reader, overrides, 0)
+}
+
+// parseStack returns the routes of the template at templatePath, a nested stack depth levels down.
+func parseStack(
+	templatePath string,
+	reader fileReader,
+	overrides map[string]string,
+	depth int,
+) ([]apiRoute, error) {
 	yamlFile, err := reader.read(templatePath)
 	if err != nil {
 		return []apiRoute{}, fmt.Errorf("[in lambdalocal.parseTemplate] read file failed: %w", err)
 	}
 
-	SAMData := samTemplate{}
-	if err = yaml.Unmarshal(yamlFile, &SAMData); err != nil {
+	// Terraform plans and states are read from the output of `terraform show -json`
+	if isTerraformJSON(yamlFile) {
+		routes, err := terraformRoutes(yamlFile)
+		if err != nil {
+			return []apiRoute{}, fmt.Errorf("[in lambdalocal.parseTemplate] %w", err)
+		}
+
+		sortRoutes(routes)
+
+		return routes, nil
+	}
+
+	index, err := templates.index(templatePath, yamlFile, overrides)
+	if err != nil {
 		return []apiRoute{}, fmt.Errorf("[in lambdalocal.parseTemplate] unmarshal yaml failed: %w", err)
 	}
 
+	// API Gateway resources of plain CloudFormation templates are read from CFNData
+	SAMData, CFNData := index.sam, index.cfn
+
+	// binary media types apply to all REST API routes of the template, the cached slice is copied
+	// before appending
+	binaryMediaTypes := slices.Clone(SAMData.Globals.API.BinaryMediaTypes)
+
+	// authorizers and CORS are configured on the API the event references, or in the Globals for
+	// the implicit APIs
+	httpAPIAuth := make(map[string]samHTTPAPIAuth)
+	restAPICorsConfigs := map[string]*corsConfig{"": restAPICors(SAMData.Globals.API.Cors)}
+	httpAPICorsConfigs := map[string]*corsConfig{"": httpAPICors(SAMData.Globals.HTTPAPI.CorsConfiguration)}
+
+	// OpenAPI definitions of the APIs, by API resource
+	definitions := make(map[string]map[string]any)
+
+	for name, resource := range SAMData.Resources {
+		if resource.Type == serverlessAPIType || resource.Type == serverlessHTTPAPIType {
+			definition, err := openAPIDefinition(
+				templatePath,
+				resource.Properties.DefinitionBody,
+				resource.Properties.DefinitionURI,
+				reader,
+			)
+			if err != nil {
+				return []apiRoute{}, fmt.Errorf(
+					"[in lambdalocal.parseTemplate] read definition of %s failed: %w",
+					name,
+					err,
+				)
+			}
+
+			definitions[name] = definition
+		}
+
+		switch resource.Type {
+		case serverlessAPIType:
+			binaryMediaTypes = append(binaryMediaTypes, resource.Properties.BinaryMediaTypes...)
+			binaryMediaTypes = append(binaryMediaTypes, templateStrings(definitions[name][openAPIBinaryMediaTypes])...)
+
+			restAPICorsConfigs[name] = restAPICorsConfigs[""]
+			if resource.Properties.Cors != nil {
+				restAPICorsConfigs[name] = restAPICors(resource.Properties.Cors)
+			}
+		case serverlessHTTPAPIType:
+			httpAPIAuth[name] = resource.Properties.Auth
+
+			httpAPICorsConfigs[name] = httpAPICorsConfigs[""]
+			if resource.Properties.CorsConfiguration != nil {
+				httpAPICorsConfigs[name] = httpAPICors(resource.Properties.CorsConfiguration)
+			}
+		}
+	}
+
+	binaryMediaTypes = normalizeBinaryMediaTypes(binaryMediaTypes)
+
 	var routes []apiRoute
 
-	for _, resource := range SAMData.Resources {
+	for function, resource := range SAMData.Resources {
 		for _, event := range resource.Properties.Events {
-			routes = append(
-				routes, apiRoute{
-					method: strings.ToUpper(event.Properties.Method),
-					path:   event.Properties.Path,
-				},
+			switch event.Type {
+			case eventTypeAPI:
+				routes = append(
+					routes, apiRoute{
+						method:           routeMethod(event.Properties.Method),
+						path:             event.Properties.Path,
+						function:         function,
+						binaryMediaTypes: binaryMediaTypes,
+						payloadFormat:    payloadFormatV1,
+						cors:             restAPICorsConfigs[refName(event.Properties.RestAPIID)],
+					},
+				)
+			case eventTypeHTTPAPI:
+				route := httpAPIRoute(
+					event.Properties.Path,
+					event.Properties.Method,
+					event.Properties.PayloadFormatVersion,
+				)
+				route.function = function
+				route.authorizer = httpAPIAuthorizer(
+					httpAPIAuth,
+					SAMData.Globals.HTTPAPI.Auth,
+					event.Properties.APIID,
+					event.Properties.Auth,
+				)
+				route.cors = httpAPICorsConfigs[refName(event.Properties.APIID)]
+
+				routes = append(routes, route)
+			}
+		}
+	}
+
+	// add the routes that are only in the OpenAPI definitions, SAM merges the event routes into them
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		seen[route.routeKey()] = true
+	}
+
+	for name, definition := range definitions {
+		for _, operation := range openAPIOperations(definition) {
+			var route apiRoute
+
+			if SAMData.Resources[name].Type == serverlessAPIType {
+				route = apiRoute{
+					method:           operation.method,
+					path:             operation.path,
+					binaryMediaTypes: binaryMediaTypes,
+					payloadFormat:    payloadFormatV1,
+					cors:             restAPICorsConfigs[name],
+				}
+			} else {
+				route = httpAPIRoute(operation.path, operation.method, operation.payloadFormatVersion)
+				route.authorizer = httpAPIAuthorizer(httpAPIAuth, SAMData.Globals.HTTPAPI.Auth, name, samEventAuth{})
+				route.cors = httpAPICorsConfigs[name]
+			}
+
+			route.function = operation.function
+
+			if !seen[route.routeKey()] {
+				seen[route.routeKey()] = true
+				routes = append(routes, route)
+			}
+		}
+	}
+
+	routes = append(routes, cfnRoutes(CFNData)...)
+
+	// nested stacks and applications with a local template
+	for _, stack := range nestedStacks(templatePath, SAMData) {
+		if depth >= maxNestedStackDepth {
+			return []apiRoute{}, fmt.Errorf(
+				"[in lambdalocal.parseTemplate] nested stacks are more than %d levels deep, does %s nest itself?",
+				maxNestedStackDepth,
+				stack.path,
 			)
 		}
+
+		stackRoutes, err := parseStack(stack.path, reader, stack.parameters, depth+1)
+		if err != nil {
+			return []apiRoute{}, fmt.Errorf("[in lambdalocal.parseTemplate] nested stack %s: %w", stack.name, err)
+		}
+
+		routes = append(routes, stackRoutes...)
 	}
 
+	sortRoutes(routes)
+
 	return routes, nil
 }
+
+// sortRoutes sorts routes by path and method. Map iteration order is random, sorting keeps the
+// routes registered and logged consistently.
+func sortRoutes(routes []apiRoute) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].path != routes[j].path {
+			return routes[i].path < routes[j].path
+		}
+
+		return routes[i].method < routes[j].method
+	})
+}
+
+// httpAPIRoute builds the route of an HttpApi event. A missing or $default path is the catch-all
+// $default route, and a missing or ANY method matches every method. Payload format 2.0 is used
+// unless the event sets PayloadFormatVersion.
+func httpAPIRoute(path, method, payloadFormatVersion string) apiRoute {
+	route := apiRoute{
+		method:        routeMethod(method),
+		path:          path,
+		payloadFormat: payloadFormatV2,
+	}
+
+	if path == "" || path == defaultRouteKey {
+		route.path = defaultRoutePath
+		route.method = ""
+	}
+
+	if payloadFormatVersion != "" {
+		route.payloadFormat = payloadFormatVersion
+	}
+
+	return route
+}
+
+// routeMethod normalizes the method of a template event. SAM's 'any' matches every method and,
+// like a missing method, becomes an empty method so the route is registered for all verbs.
+func routeMethod(method string) string {
+	method = strings.ToUpper(method)
+	if method == anyMethod {
+		return ""
+	}
+
+	return method
+}
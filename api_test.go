@@ -3,13 +3,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/stretchr/testify/assert"
@@ -48,11 +56,30 @@ func TestGatewayHandler(t *testing.T) {
 			parseJSON:          true,
 			requestPath:        "/test2",
 			requestMethod:      http.MethodGet,
-			expectedStatus:     http.StatusServiceUnavailable,
-			expectedResponse:   "Service Unavailable\n",
+			expectedStatus:     http.StatusBadGateway,
+			expectedResponse:   `{"message":"Internal server error"}` + "\n",
 			mockInvokeResponse: messages.InvokeResponse{},
 			mockInvokeError:    errors.New("invoke error"),
 		},
+		"Function error": {
+			route:            apiRoute{path: "/test3", method: http.MethodGet},
+			requestPath:      "/test3",
+			requestMethod:    http.MethodGet,
+			expectedStatus:   http.StatusBadGateway,
+			expectedResponse: `{"message":"Internal server error"}` + "\n",
+			mockInvokeResponse: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "user not found", Type: "errorString"},
+			},
+		},
+		"Invocation timeout": {
+			route:              apiRoute{path: "/test4", method: http.MethodGet},
+			requestPath:        "/test4",
+			requestMethod:      http.MethodGet,
+			expectedStatus:     http.StatusGatewayTimeout,
+			expectedResponse:   `{"message":"Endpoint request timed out"}` + "\n",
+			mockInvokeResponse: messages.InvokeResponse{},
+			mockInvokeError:    errInvocationTimeout,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -71,7 +98,7 @@ func TestGatewayHandler(t *testing.T) {
 				req := httptest.NewRequest(tc.requestMethod, tc.requestPath, nil)
 				rr := httptest.NewRecorder()
 
-				handler := gatewayHandler(mockLambdaRPC, tc.parseJSON, tc.route, logger)
+				handler := gatewayHandler(mockLambdaRPC, responseFormat{parseJSON: tc.parseJSON}, tc.route, nil, logger)
 				handler.ServeHTTP(rr, req)
 
 				resp := rr.Result()
@@ -93,15 +120,20 @@ func TestGatewayHandler(t *testing.T) {
 func TestParseHTTPRequest(t *testing.T) {
 	t.Parallel()
 
+	// httptest requests come from 192.0.2.1 without a user agent
+	localRequestContext := apiRequestContext{Identity: apiIdentity{SourceIP: "192.0.2.1"}}
+
 	tests := map[string]struct {
-		method        string
-		target        string
-		body          string
-		headers       map[string]string
-		pathParamKeys []string
-		resourcePath  string
-		expectedEvent genericAPIEvent
-		expectError   bool
+		method           string
+		target           string
+		body             string
+		headers          map[string]string
+		pathParamKeys    []string
+		resourcePath     string
+		binaryMediaTypes []string
+		identity         callerIdentity
+		expectedEvent    genericAPIEvent
+		expectError      bool
 	}{
 		"GET request with query params": {
 			method:        http.MethodGet,
@@ -120,6 +152,7 @@ func TestParseHTTPRequest(t *testing.T) {
 				MultiValueQueryStringParameters: map[string][]string{"id": {"123"}},
 				PathParameters:                  map[string]string{},
 				Body:                            "",
+				RequestContext:                  localRequestContext,
 			},
 			expectError: false,
 		},
@@ -146,6 +179,7 @@ func TestParseHTTPRequest(t *testing.T) {
 				MultiValueQueryStringParameters: map[string][]string{},
 				PathParameters:                  map[string]string{},
 				Body:                            `{"name": "test"}`,
+				RequestContext:                  localRequestContext,
 			},
 			expectError: false,
 		},
@@ -181,9 +215,80 @@ func TestParseHTTPRequest(t *testing.T) {
 				},
 				PathParameters: map[string]string{},
 				Body:           "",
+				RequestContext: localRequestContext,
+			},
+			expectError: false,
+		},
+		"binary body": {
+			method:           http.MethodPost,
+			target:           "/upload",
+			body:             "\x89PNG",
+			headers:          map[string]string{"Content-Type": "image/png"},
+			pathParamKeys:    []string{},
+			resourcePath:     "/upload",
+			binaryMediaTypes: []string{"image/*"},
+			expectedEvent: genericAPIEvent{
+				Resource:                        "/upload",
+				Path:                            "/upload",
+				HTTPMethod:                      http.MethodPost,
+				Headers:                         map[string]string{"Content-Type": "image/png"},
+				MultiValueHeaders:               map[string][]string{"Content-Type": {"image/png"}},
+				QueryStringParameters:           map[string]string{},
+				MultiValueQueryStringParameters: map[string][]string{},
+				PathParameters:                  map[string]string{},
+				Body:                            "iVBORw==",
+				IsBase64Encoded:                 true,
+				RequestContext:                  localRequestContext,
 			},
 			expectError: false,
 		},
+		"body of non binary media type": {
+			method:           http.MethodPost,
+			target:           "/upload",
+			body:             `{"name": "test"}`,
+			headers:          map[string]string{"Content-Type": "application/json"},
+			pathParamKeys:    []string{},
+			resourcePath:     "/upload",
+			binaryMediaTypes: []string{"image/*"},
+			expectedEvent: genericAPIEvent{
+				Resource:                        "/upload",
+				Path:                            "/upload",
+				HTTPMethod:                      http.MethodPost,
+				Headers:                         map[string]string{"Content-Type": "application/json"},
+				MultiValueHeaders:               map[string][]string{"Content-Type": {"application/json"}},
+				QueryStringParameters:           map[string]string{},
+				MultiValueQueryStringParameters: map[string][]string{},
+				PathParameters:                  map[string]string{},
+				Body:                            `{"name": "test"}`,
+				RequestContext:                  localRequestContext,
+			},
+			expectError: false,
+		},
+		"identity overrides": {
+			method:        http.MethodGet,
+			target:        "/test",
+			headers:       map[string]string{"User-Agent": "curl/8.0"},
+			pathParamKeys: []string{},
+			resourcePath:  "/test",
+			identity:      callerIdentity{sourceIP: "203.0.113.7", userARN: "arn:aws:iam::123456789012:user/alice"},
+			expectedEvent: genericAPIEvent{
+				Resource:                        "/test",
+				Path:                            "/test",
+				HTTPMethod:                      http.MethodGet,
+				Headers:                         map[string]string{"User-Agent": "curl/8.0"},
+				MultiValueHeaders:               map[string][]string{"User-Agent": {"curl/8.0"}},
+				QueryStringParameters:           map[string]string{},
+				MultiValueQueryStringParameters: map[string][]string{},
+				PathParameters:                  map[string]string{},
+				RequestContext: apiRequestContext{
+					Identity: apiIdentity{
+						SourceIP:  "203.0.113.7",
+						UserAgent: "curl/8.0",
+						UserARN:   "arn:aws:iam::123456789012:user/alice",
+					},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -196,7 +301,13 @@ func TestParseHTTPRequest(t *testing.T) {
 					req.Header.Set(k, v)
 				}
 
-				eventByte, err := parseHTTPRequest(req, tc.pathParamKeys, tc.resourcePath)
+				eventByte, err := parseHTTPRequest(
+					req,
+					tc.pathParamKeys,
+					tc.resourcePath,
+					tc.binaryMediaTypes,
+					tc.identity,
+				)
 				if tc.expectError {
 					assert.Error(t, err)
 				} else {
@@ -289,7 +400,7 @@ func TestOutputLambdaResponse(t *testing.T) {
 			name, func(t *testing.T) {
 				t.Parallel()
 
-				output, err := outputLambdaResponse(tc.input, tc.parseJSON)
+				output, err := outputLambdaResponse(tc.input, responseFormat{parseJSON: tc.parseJSON})
 				if tc.expectedError {
 					assert.Error(t, err)
 				} else {
@@ -335,6 +446,48 @@ func TestReturnHTTPResponse(t *testing.T) {
 			expectedBody:    "",
 			expectError:     true,
 		},
+		"base64 encoded body": {
+			invokeResponse: messages.InvokeResponse{
+				Payload: []byte(`{
+					"statusCode": 200,
+					"headers": {"Content-Type": "application/json"},
+					"body": "eyJtZXNzYWdlIjoiYmluYXJ5In0=",
+					"isBase64Encoded": true
+				}`),
+			},
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Content-Type": "application/json",
+			},
+			expectedBody: `{"message":"binary"}`,
+			expectError:  false,
+		},
+		"invalid base64 body": {
+			invokeResponse: messages.InvokeResponse{
+				Payload: []byte(`{"statusCode": 200, "body": "not base64!", "isBase64Encoded": true}`),
+			},
+			expectedStatus:  http.StatusInternalServerError,
+			expectedHeaders: map[string]string{},
+			expectedBody:    "",
+			expectError:     true,
+		},
+		"multi value headers": {
+			invokeResponse: messages.InvokeResponse{
+				Payload: []byte(`{
+					"statusCode": 200,
+					"headers": {"Content-Type": "text/plain", "X-Trace": "1"},
+					"multiValueHeaders": {"Content-Type": ["application/json; charset=utf-8"]},
+					"body": "{}"
+				}`),
+			},
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Content-Type": "application/json; charset=utf-8",
+				"X-Trace":      "1",
+			},
+			expectedBody: `{}`,
+			expectError:  false,
+		},
 		"missing status code": {
 			invokeResponse: messages.InvokeResponse{
 				Payload: []byte(`{
@@ -423,7 +576,7 @@ Resources:
 				nil,
 			},
 			expectedRoutes: []apiRoute{
-				{method: "GET", path: "/my/path"},
+				{method: "GET", path: "/my/path", function: "MyLambdaFunction", payloadFormat: payloadFormatV1},
 			},
 			expectedErrStr: "",
 		},
@@ -454,8 +607,399 @@ Resources:
 				nil,
 			},
 			expectedRoutes: []apiRoute{
-				{method: "POST", path: "/first/path"},
-				{method: "PUT", path: "/second/path"},
+				{method: "POST", path: "/first/path", function: "FirstLambdaFunction", payloadFormat: payloadFormatV1},
+				{method: "PUT", path: "/second/path", function: "SecondLambdaFunction", payloadFormat: payloadFormatV1},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with http api routes": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Resources:
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        HttpApiEvent:
+          Type: "HttpApi"
+          Properties:
+            Path: "/v2/path"
+            Method: "get"
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{method: "GET", path: "/v2/path", function: "MyLambdaFunction", payloadFormat: payloadFormatV2},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with http api catch-all routes": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Resources:
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        DefaultRoute:
+          Type: "HttpApi"
+        AnyMethod:
+          Type: "HttpApi"
+          Properties:
+            Path: "/items/{id}"
+            PayloadFormatVersion: "1.0"
+        QueueEvent:
+          Type: "SQS"
+          Properties:
+            Queue: "arn:aws:sqs:us-east-1:123456789012:queue"
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{method: "", path: "/items/{id}", function: "MyLambdaFunction", payloadFormat: payloadFormatV1},
+				{method: "", path: "/{proxy+}", function: "MyLambdaFunction", payloadFormat: payloadFormatV2},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with any method route": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Resources:
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        ApiEvent:
+          Type: "Api"
+          Properties:
+            Path: "/any/path"
+            Method: "any"
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{method: "", path: "/any/path", function: "MyLambdaFunction", payloadFormat: payloadFormatV1},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with binary media types": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Globals:
+  Api:
+    BinaryMediaTypes:
+      - image~1png
+Resources:
+  MyApi:
+    Type: "AWS::Serverless::Api"
+    Properties:
+      BinaryMediaTypes:
+        - application~1octet-stream
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        ApiEvent:
+          Type: "Api"
+          Properties:
+            Path: "/upload"
+            Method: "post"
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{
+					method:           "POST",
+					path:             "/upload",
+					function:         "MyLambdaFunction",
+					binaryMediaTypes: []string{"image/png", "application/octet-stream"},
+					payloadFormat:    payloadFormatV1,
+				},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with jwt authorizers": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Globals:
+  HttpApi:
+    Auth:
+      DefaultAuthorizer: GlobalAuth
+      Authorizers:
+        GlobalAuth:
+          JwtConfiguration:
+            issuer: https://global.example.com
+            audience: global-client
+Resources:
+  MyHttpApi:
+    Type: AWS::Serverless::HttpApi
+    Properties:
+      Auth:
+        Authorizers:
+          OAuth:
+            IdentitySource: $request.header.X-Token
+            JwtConfiguration:
+              issuer: !Sub https://cognito-idp.${AWS::Region}.amazonaws.com/${UserPool}
+              audience:
+                - !Ref UserPoolClient
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        Implicit:
+          Type: HttpApi
+          Properties:
+            Path: /implicit
+            Method: get
+        Public:
+          Type: HttpApi
+          Properties:
+            Path: /public
+            Method: get
+            Auth:
+              Authorizer: NONE
+        Explicit:
+          Type: HttpApi
+          Properties:
+            ApiId: !Ref MyHttpApi
+            Path: /explicit
+            Method: get
+            Auth:
+              Authorizer: OAuth
+              AuthorizationScopes:
+                - orders/read
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{
+					method:        "GET",
+					path:          "/explicit",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV2,
+					authorizer: &jwtAuthorizer{
+						name:     "OAuth",
+						header:   "X-Token",
+						issuer:   "https://cognito-idp.us-east-1.amazonaws.com/${UserPool}",
+						audience: []string{"UserPoolClient"},
+						scopes:   []string{"orders/read"},
+					},
+				},
+				{
+					method:        "GET",
+					path:          "/implicit",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV2,
+					authorizer: &jwtAuthorizer{
+						name:     "GlobalAuth",
+						header:   "Authorization",
+						issuer:   "https://global.example.com",
+						audience: []string{"global-client"},
+					},
+				},
+				{method: "GET", path: "/public", function: "MyLambdaFunction", payloadFormat: payloadFormatV2},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with cors": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Globals:
+  Api:
+    Cors: "'https://app.example.com'"
+  HttpApi:
+    CorsConfiguration: true
+Resources:
+  MyHttpApi:
+    Type: AWS::Serverless::HttpApi
+    Properties:
+      CorsConfiguration:
+        AllowOrigins:
+          - https://admin.example.com
+        AllowMethods:
+          - GET
+        MaxAge: 600
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        Rest:
+          Type: Api
+          Properties:
+            Path: /rest
+            Method: get
+        Implicit:
+          Type: HttpApi
+          Properties:
+            Path: /implicit
+            Method: get
+        Explicit:
+          Type: HttpApi
+          Properties:
+            ApiId: !Ref MyHttpApi
+            Path: /explicit
+            Method: get
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{
+					method:        "GET",
+					path:          "/explicit",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV2,
+					cors: &corsConfig{
+						allowOrigins: []string{"https://admin.example.com"},
+						allowMethods: []string{"GET"},
+						maxAge:       600,
+						httpAPI:      true,
+					},
+				},
+				{
+					method:        "GET",
+					path:          "/implicit",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV2,
+					cors: &corsConfig{
+						allowOrigins: []string{"*"},
+						allowMethods: []string{"*"},
+						allowHeaders: []string{"*"},
+						httpAPI:      true,
+					},
+				},
+				{
+					method:        "GET",
+					path:          "/rest",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV1,
+					cors:          &corsConfig{allowOrigins: []string{"https://app.example.com"}},
+				},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with openapi definition": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Resources:
+  MyApi:
+    Type: AWS::Serverless::Api
+    Properties:
+      DefinitionBody:
+        openapi: "3.0.1"
+        paths:
+          /hello:
+            get:
+              x-amazon-apigateway-integration:
+                type: aws_proxy
+                httpMethod: POST
+                uri: !Sub arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${HelloFn.Arn}/invocations
+            options:
+              x-amazon-apigateway-integration:
+                type: mock
+  HelloFn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Hello:
+          Type: Api
+          Properties:
+            RestApiId: !Ref MyApi
+            Path: /hello
+            Method: get
+  OrderFn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Order:
+          Type: HttpApi
+          Properties:
+            ApiId: !Ref MyHttpApi
+  MyHttpApi:
+    Type: AWS::Serverless::HttpApi
+    Properties:
+      DefinitionBody:
+        openapi: "3.0.1"
+        paths:
+          /orders/{id}:
+            x-amazon-apigateway-any-method:
+              x-amazon-apigateway-integration:
+                type: aws_proxy
+                payloadFormatVersion: "1.0"
+                uri:
+                  Fn::Sub: arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${OrderFn.Arn}/invocations
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{
+					method:        "GET",
+					path:          "/hello",
+					function:      "HelloFn",
+					payloadFormat: payloadFormatV1,
+				},
+				{
+					method:        "",
+					path:          "/orders/{id}",
+					function:      "OrderFn",
+					payloadFormat: payloadFormatV1,
+				},
+				{
+					method:        "",
+					path:          "/{proxy+}",
+					function:      "OrderFn",
+					payloadFormat: payloadFormatV2,
+				},
+			},
+			expectedErrStr: "",
+		},
+		"valid template with intrinsic functions": {
+			mockInput: []any{""},
+			mockReturn: []any{
+				[]byte(`
+Parameters:
+  Stage:
+    Type: String
+    Default: dev
+Conditions:
+  IsDev:
+    Fn::Equals: [!Ref Stage, dev]
+Resources:
+  MyLambdaFunction:
+    Type: "AWS::Serverless::Function"
+    Properties:
+      Events:
+        Hello:
+          Type: Api
+          Properties:
+            Path: !Sub /${Stage}/hello
+            Method: !If [IsDev, any, get]
+  DebugFunction:
+    Type: "AWS::Serverless::Function"
+    Condition: IsProd
+    Properties:
+      Events:
+        Debug:
+          Type: Api
+          Properties:
+            Path: /debug
+            Method: get
+                `),
+				nil,
+			},
+			expectedRoutes: []apiRoute{
+				{
+					method:        "",
+					path:          "/dev/hello",
+					function:      "MyLambdaFunction",
+					payloadFormat: payloadFormatV1,
+				},
 			},
 			expectedErrStr: "",
 		},
@@ -514,7 +1058,7 @@ Resources:
 					Return(tc.mockReturn...).
 					Once()
 
-				result, err := parseTemplate("", mockReader)
+				result, err := parseTemplate("", mockReader, nil)
 
 				assert.Equal(t, tc.expectedRoutes, result)
 
@@ -527,3 +1071,586 @@ Resources:
 		)
 	}
 }
+
+func TestAPIRoutePatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		route              apiRoute
+		expectedRouteKey   string
+		expectedMuxPattern string
+	}{
+		"method and path": {
+			route:              apiRoute{method: http.MethodGet, path: "/users/{id}"},
+			expectedRouteKey:   "GET /users/{id}",
+			expectedMuxPattern: "GET /users/{id}",
+		},
+		"any method": {
+			route:              apiRoute{path: "/users"},
+			expectedRouteKey:   "ANY /users",
+			expectedMuxPattern: "/users",
+		},
+		"greedy path parameter": {
+			route:              apiRoute{method: http.MethodPost, path: "/files/{path+}"},
+			expectedRouteKey:   "POST /files/{path+}",
+			expectedMuxPattern: "POST /files/{path...}",
+		},
+		"default route": {
+			route:              httpAPIRoute("$default", "", ""),
+			expectedRouteKey:   "$default",
+			expectedMuxPattern: "/{proxy...}",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expectedRouteKey, tc.route.routeKey())
+				assert.Equal(t, tc.expectedMuxPattern, tc.route.muxPattern())
+			},
+		)
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	t.Parallel()
+
+	network, address := listenNetwork("unix:/tmp/lambdalocal.sock")
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/tmp/lambdalocal.sock", address)
+
+	network, address = listenNetwork("localhost:8080")
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "localhost:8080", address)
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	// socket paths are limited to about 100 bytes, shorter than many temporary directories
+	dir, err := os.MkdirTemp("", "lambdalocal")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "api.sock")
+
+	// a socket left behind by a process that didn't shut down
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false) //nolint:forcetypeassert
+	require.NoError(t, stale.Close())
+	require.FileExists(t, path)
+
+	listener, err := listen("unix", path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	// a socket that is still served isn't replaced
+	_, err = listen("unix", path)
+	require.ErrorContains(t, err, "already in use")
+}
+
+func TestReachableAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		addr     string
+		expected string
+	}{
+		"localhost":    {addr: "localhost:8080", expected: "localhost:8080"},
+		"all IPv4":     {addr: "0.0.0.0:8080", expected: "localhost:8080"},
+		"all IPv6":     {addr: "[::]:8080", expected: "localhost:8080"},
+		"IPv6":         {addr: "[::1]:8080", expected: "[::1]:8080"},
+		"LAN address":  {addr: "192.168.1.20:8080", expected: "192.168.1.20:8080"},
+		"invalid addr": {addr: "localhost", expected: "localhost"},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, reachableAddress(tc.addr))
+			},
+		)
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config                 serverConfig
+		expectedReadTimeout    time.Duration
+		expectedWriteTimeout   time.Duration
+		expectedIdleTimeout    time.Duration
+		expectedMaxHeaderBytes int
+	}{
+		"defaults": {
+			config: serverConfig{},
+		},
+		"configured limits": {
+			config: serverConfig{
+				readTimeout:    time.Minute,
+				writeTimeout:   2 * time.Minute,
+				idleTimeout:    3 * time.Minute,
+				maxHeaderBytes: 64 << 10,
+			},
+			expectedReadTimeout:    time.Minute,
+			expectedWriteTimeout:   2 * time.Minute,
+			expectedIdleTimeout:    3 * time.Minute,
+			expectedMaxHeaderBytes: 64 << 10,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				server := newHTTPServer("localhost:8080", http.NewServeMux(), tc.config)
+
+				assert.Equal(t, "localhost:8080", server.Addr)
+				assert.Equal(t, 5*time.Second, server.ReadHeaderTimeout)
+				assert.Equal(t, tc.expectedReadTimeout, server.ReadTimeout)
+				assert.Equal(t, tc.expectedWriteTimeout, server.WriteTimeout)
+				assert.Equal(t, tc.expectedIdleTimeout, server.IdleTimeout)
+				assert.Equal(t, tc.expectedMaxHeaderBytes, server.MaxHeaderBytes)
+			},
+		)
+	}
+}
+
+func TestAnyMethodRoute(t *testing.T) {
+	t.Parallel()
+
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	for _, method := range methods {
+		t.Run(
+			method, func(t *testing.T) {
+				t.Parallel()
+
+				route := apiRoute{method: routeMethod("any"), path: "/any/{id}", payloadFormat: payloadFormatV1}
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				mockLambdaRPC.
+					On(
+						"Invoke", mock.MatchedBy(
+							func(data []byte) bool {
+								var event genericAPIEvent
+
+								return json.Unmarshal(data, &event) == nil &&
+									event.HTTPMethod == method &&
+									event.PathParameters["id"] == "42"
+							},
+						),
+					).
+					Return(messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}, nil).
+					Once()
+
+				router := http.NewServeMux()
+				router.Handle(
+					route.muxPattern(),
+					gatewayHandler(mockLambdaRPC, responseFormat{}, route, nil, slog.Default()),
+				)
+
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(method, "/any/42", nil))
+
+				assert.Equal(t, http.StatusOK, rr.Code)
+				mockLambdaRPC.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+// recordingLambdaCaller records the last invocation and answers it with a 200 response.
+type recordingLambdaCaller struct {
+	data    []byte
+	options invokeOptions
+}
+
+func (c *recordingLambdaCaller) Invoke(
+	ctx context.Context,
+	data []byte,
+	options ...Option,
+) (messages.InvokeResponse, error) {
+	c.data = data
+	c.options = invokeOptions{}.with(options)
+
+	return messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}, nil
+}
+
+func TestGatewayHandlerUnreadableBody(t *testing.T) {
+	t.Parallel()
+
+	caller := new(recordingLambdaCaller)
+	route := apiRoute{method: http.MethodPost, path: "/test", payloadFormat: payloadFormatV2}
+
+	rr := httptest.NewRecorder()
+	gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/test", iotest.ErrReader(errors.New("connection reset"))))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"message":"Bad Request"}`, rr.Body.String())
+	assert.Nil(t, caller.data)
+}
+
+func TestGatewayHandlerRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payloadFormat string
+		eventID       func(data []byte) string
+	}{
+		"payload format 1.0": {
+			payloadFormat: payloadFormatV1,
+			eventID: func(data []byte) string {
+				var event genericAPIEvent
+				_ = json.Unmarshal(data, &event)
+
+				return event.Headers[requestIDHeader]
+			},
+		},
+		"payload format 2.0": {
+			payloadFormat: payloadFormatV2,
+			eventID: func(data []byte) string {
+				var event httpAPIEvent
+				_ = json.Unmarshal(data, &event)
+
+				if event.Headers["x-request-id"] != event.RequestContext.RequestID {
+					return ""
+				}
+
+				return event.RequestContext.RequestID
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(recordingLambdaCaller)
+				route := apiRoute{method: http.MethodGet, path: "/test", payloadFormat: tc.payloadFormat}
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+					ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+				requestID := rr.Header().Get(requestIDHeader)
+
+				require.NotEmpty(t, requestID)
+				assert.Equal(t, requestID, caller.options.requestID)
+				assert.Equal(t, requestID, tc.eventID(caller.data))
+			},
+		)
+	}
+}
+
+// echoLambdaCaller records the last invocation and answers it with the body and Content-Type of
+// its API event.
+type echoLambdaCaller struct {
+	data []byte
+}
+
+func (c *echoLambdaCaller) Invoke(_ context.Context, data []byte, _ ...Option) (messages.InvokeResponse, error) {
+	c.data = data
+
+	var event struct {
+		Headers         map[string]string `json:"headers"`
+		Body            string            `json:"body"`
+		IsBase64Encoded bool              `json:"isBase64Encoded"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return messages.InvokeResponse{}, err //nolint:wrapcheck
+	}
+
+	contentType := event.Headers["Content-Type"] + event.Headers["content-type"]
+
+	payload, err := json.Marshal(
+		genericAPIResponse{
+			StatusCode:      http.StatusOK,
+			Headers:         map[string]string{"Content-Type": contentType},
+			Body:            event.Body,
+			IsBase64Encoded: event.IsBase64Encoded,
+		},
+	)
+
+	return messages.InvokeResponse{Payload: payload}, err //nolint:wrapcheck
+}
+
+func TestGatewayHandlerXMLPassthrough(t *testing.T) {
+	t.Parallel()
+
+	envelope := `<?xml version="1.0"?>` +
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` +
+		`<soap:Body><GetPrice><Item>Fish &amp; Chips</Item></GetPrice></soap:Body>` +
+		`</soap:Envelope>`
+
+	tests := map[string]struct {
+		payloadFormat string
+		contentType   string
+		body          string
+		// expectedEventBody is the body as it appears in the JSON of the event.
+		expectedEventBody string
+	}{
+		"payload format 1.0": {
+			payloadFormat:     payloadFormatV1,
+			contentType:       "text/xml; charset=utf-8",
+			body:              envelope,
+			expectedEventBody: `<soap:Envelope`,
+		},
+		"payload format 2.0": {
+			payloadFormat:     payloadFormatV2,
+			contentType:       `application/soap+xml; charset=utf-8; action="urn:GetPrice"`,
+			body:              envelope,
+			expectedEventBody: `Fish &amp; Chips`,
+		},
+		"payload format 1.0 latin-1": {
+			payloadFormat:     payloadFormatV1,
+			contentType:       "text/xml; charset=ISO-8859-1",
+			body:              "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><Item>Caf\xe9</Item>",
+			expectedEventBody: `"isBase64Encoded":true`,
+		},
+		"payload format 2.0 latin-1": {
+			payloadFormat:     payloadFormatV2,
+			contentType:       "text/xml; charset=ISO-8859-1",
+			body:              "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><Item>Caf\xe9</Item>",
+			expectedEventBody: `"isBase64Encoded":true`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(echoLambdaCaller)
+				route := apiRoute{method: http.MethodPost, path: "/soap", payloadFormat: tc.payloadFormat}
+
+				req := httptest.NewRequest(http.MethodPost, "/soap", strings.NewReader(tc.body))
+				req.Header.Set("Content-Type", tc.contentType)
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).ServeHTTP(rr, req)
+
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Contains(t, string(caller.data), tc.expectedEventBody)
+				assert.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+				assert.Equal(t, []byte(tc.body), rr.Body.Bytes())
+			},
+		)
+	}
+}
+
+func TestGatewayHandlerFraming(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method                string
+		payloadFormat         string
+		payload               string
+		expectedStatus        int
+		expectedContentLength string
+		expectedBody          string
+	}{
+		"content length": {
+			method:                http.MethodGet,
+			payload:               `{"statusCode":200,"body":"héllo"}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "6",
+			expectedBody:          "héllo",
+		},
+		"content length of the lambda is replaced": {
+			method:                http.MethodGet,
+			payload:               `{"statusCode":200,"headers":{"Content-Length":"99"},"body":"hello"}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "5",
+			expectedBody:          "hello",
+		},
+		"transfer encoding of the lambda is dropped": {
+			method:                http.MethodGet,
+			payload:               `{"statusCode":200,"headers":{"Transfer-Encoding":"chunked"},"body":"hello"}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "5",
+			expectedBody:          "hello",
+		},
+		"empty body": {
+			method:                http.MethodGet,
+			payload:               `{"statusCode":200}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "0",
+		},
+		"HEAD has the headers of GET without the body": {
+			method:                http.MethodHead,
+			payload:               `{"statusCode":200,"body":"hello"}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "5",
+		},
+		"HEAD of payload format 2.0 without statusCode": {
+			method:                http.MethodHead,
+			payloadFormat:         payloadFormatV2,
+			payload:               `{"message":"hello"}`,
+			expectedStatus:        http.StatusOK,
+			expectedContentLength: "19",
+		},
+		"204 has no body": {
+			method:         http.MethodGet,
+			payload:        `{"statusCode":204,"body":"ignored"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		"304 has no body": {
+			method:         http.MethodGet,
+			payload:        `{"statusCode":304,"headers":{"ETag":"\"1\""},"body":"ignored"}`,
+			expectedStatus: http.StatusNotModified,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := staticLambdaCaller{response: messages.InvokeResponse{Payload: []byte(tc.payload)}}
+				route := apiRoute{method: tc.method, path: "/hello", payloadFormat: tc.payloadFormat}
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+					ServeHTTP(rr, httptest.NewRequest(tc.method, "/hello", nil))
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectedContentLength, rr.Header().Get("Content-Length"))
+				assert.Empty(t, rr.Header().Get("Transfer-Encoding"))
+				assert.Equal(t, tc.expectedBody, rr.Body.String())
+			},
+		)
+	}
+}
+
+// staticLambdaCaller answers every invocation with the same response.
+type staticLambdaCaller struct {
+	response messages.InvokeResponse
+}
+
+func (c staticLambdaCaller) Invoke(context.Context, []byte, ...Option) (messages.InvokeResponse, error) {
+	return c.response, nil
+}
+
+// benchmarkPayload is the response of a small API, with a JSON body and a few headers.
+var benchmarkPayload = []byte( //nolint:gochecknoglobals
+	`{"statusCode":200,"headers":{"Content-Type":"application/json","X-Order-Id":"1234"},` +
+		`"multiValueHeaders":{"Set-Cookie":["a=1","b=2"]},` +
+		`"body":"{\"id\":\"1234\",\"status\":\"shipped\",\"items\":[{\"sku\":\"A-1\",\"quantity\":2}]}"}`,
+)
+
+// newBenchmarkRequest returns a small API request with a path parameter, a query string and the
+// headers of a typical client.
+func newBenchmarkRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/orders/1234?expand=items&page=2", strings.NewReader(`{"note":"x"}`))
+	req.SetPathValue("id", "1234")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "k6/0.49.0")
+	req.Header.Set("Authorization", "Bearer token")
+
+	return req
+}
+
+// discardStdout sends what is printed to stdout, like the separator line of every request, to
+// /dev/null for the rest of the benchmark.
+func discardStdout(b *testing.B) {
+	b.Helper()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(b, err)
+
+	stdout := os.Stdout
+	os.Stdout = devNull
+
+	b.Cleanup(
+		func() {
+			os.Stdout = stdout
+			_ = devNull.Close()
+		},
+	)
+}
+
+func BenchmarkParseHTTPRequest(b *testing.B) {
+	for b.Loop() {
+		_, err := parseHTTPRequest(newBenchmarkRequest(), []string{"id"}, "/orders/{id}", nil, callerIdentity{})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseHTTPAPIRequest(b *testing.B) {
+	route := httpAPIRoute("/orders/{id}", http.MethodPost, payloadFormatV2)
+
+	for b.Loop() {
+		if _, err := parseHTTPAPIRequest(newBenchmarkRequest(), []string{"id"}, route); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPrintResponse(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, format := range []responseFormat{{}, {parseJSON: true}, {sortKeys: true}} {
+		b.Run(
+			fmt.Sprintf("parseJSON=%t sortKeys=%t", format.parseJSON, format.sortKeys), func(b *testing.B) {
+				for b.Loop() {
+					response := messages.InvokeResponse{Payload: benchmarkPayload}
+					if err := printResponse(logger, response, format); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		)
+	}
+}
+
+func BenchmarkReturnHTTPResponse(b *testing.B) {
+	for b.Loop() {
+		response := messages.InvokeResponse{Payload: benchmarkPayload}
+		if err := returnHTTPResponse(httptest.NewRecorder(), response); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGatewayHandler(b *testing.B) {
+	discardStdout(b)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	caller := staticLambdaCaller{response: messages.InvokeResponse{Payload: benchmarkPayload}}
+
+	for _, payloadFormat := range []string{payloadFormatV1, payloadFormatV2} {
+		route := apiRoute{method: http.MethodPost, path: "/orders/{id}", payloadFormat: payloadFormat}
+		handler := gatewayHandler(caller, responseFormat{}, route, nil, logger)
+
+		b.Run(
+			"payload format "+payloadFormat, func(b *testing.B) {
+				b.ReportAllocs()
+
+				for b.Loop() {
+					rr := httptest.NewRecorder()
+					handler.ServeHTTP(rr, newBenchmarkRequest())
+
+					if rr.Code != http.StatusOK {
+						b.Fatalf("unexpected status %d", rr.Code)
+					}
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+)
+
+const (
+	invocationTypeRequestResponse = "RequestResponse"
+	invocationTypeEvent           = "Event"
+	// asyncDefaultRetries is how often Lambda retries failed asynchronous invocations by default.
+	asyncDefaultRetries = 2
+	// asyncDefaultBackoff is the delay before the first retry of a failed asynchronous invocation.
+	asyncDefaultBackoff = time.Second
+	// asyncErrorCodeInvoke is the error code of invocations that failed without a function error.
+	asyncErrorCodeInvoke = "InvokeFailed"
+)
+
+// asyncPolicy is the retry policy of asynchronous invocations.
+type asyncPolicy struct {
+	// retries is how often a failed invocation is retried.
+	retries int
+	// backoff is the delay before the first retry, it doubles for every further retry.
+	backoff time.Duration
+}
+
+// asyncInvoker invokes lambdas asynchronously, like the Event invocation type: callers get a 202
+// right away while the invocation runs in the background, failed invocations are retried and
+// the payloads of invocations that failed every attempt are sent to the dead-letter queue.
+type asyncInvoker struct {
+	policy asyncPolicy
+	// dlq receives the payloads of invocations that failed every attempt, they are only logged
+	// without one.
+	dlq    deadLetterQueue
+	logger *slog.Logger
+	// inFlight tracks the queued invocations, so they complete before lambdalocal exits.
+	inFlight sync.WaitGroup
+}
+
+// asyncFailure is an invocation that failed every attempt.
+type asyncFailure struct {
+	RequestID    string          `json:"requestId"`
+	ErrorCode    string          `json:"errorCode"`
+	ErrorMessage string          `json:"errorMessage"`
+	Attempts     int             `json:"attempts"`
+	Payload      json.RawMessage `json:"payload"`
+	// body is the payload as it was sent to the lambda.
+	body []byte
+}
+
+// deadLetterQueue receives the invocations that failed every attempt.
+type deadLetterQueue interface {
+	send(ctx context.Context, failure asyncFailure) error
+}
+
+func newAsyncInvoker(policy asyncPolicy, dlq deadLetterQueue, logger *slog.Logger) *asyncInvoker {
+	return &asyncInvoker{policy: policy, dlq: dlq, logger: logger}
+}
+
+// caller returns a caller that queues the invocations of caller and answers them with a 202.
+func (a *asyncInvoker) caller(caller lambdaCaller) lambdaCaller {
+	return asyncCaller{lambdaCaller: caller, invoker: a}
+}
+
+// Wait blocks until the queued invocations, including their retries, are done.
+func (a *asyncInvoker) Wait() {
+	a.inFlight.Wait()
+}
+
+type asyncCaller struct {
+	lambdaCaller
+	invoker *asyncInvoker
+}
+
+func (c asyncCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	// retries keep the request id of the first attempt, like Lambda
+	requestID := newInvokeOptions(options).requestID
+	if requestID == "" {
+		requestID = uuid.New().String()
+		options = append(options, WithRequestID(requestID))
+	}
+
+	c.invoker.inFlight.Add(1)
+
+	go func() {
+		defer c.invoker.inFlight.Done()
+
+		c.invoker.invoke(context.WithoutCancel(ctx), c.lambdaCaller, data, requestID, options)
+	}()
+
+	return messages.InvokeResponse{Payload: []byte(`{"statusCode":202}`)}, nil
+}
+
+// invoke calls caller until it succeeds or the retries of the policy are used up.
+func (a *asyncInvoker) invoke(
+	ctx context.Context,
+	caller lambdaCaller,
+	data []byte,
+	requestID string,
+	options []Option,
+) {
+	backoff := a.policy.backoff
+
+	for attempt := 1; ; attempt++ {
+		response, err := caller.Invoke(ctx, data, options...)
+		if err == nil && response.Error == nil {
+			return
+		}
+
+		failure := asyncFailure{
+			RequestID: requestID,
+			Attempts:  attempt,
+			Payload:   asyncPayload(data),
+			body:      data,
+		}
+
+		if err != nil {
+			failure.ErrorCode, failure.ErrorMessage = asyncErrorCodeInvoke, err.Error()
+		} else {
+			failure.ErrorCode, failure.ErrorMessage = response.Error.Type, response.Error.Message
+		}
+
+		if attempt > a.policy.retries {
+			a.deadLetter(failure)
+
+			return
+		}
+
+		a.logger.Warn(
+			fmt.Sprintf("Asynchronous invocation failed, retrying in %s", backoff),
+			"requestId", requestID,
+			"attempt", attempt,
+			"err", failure.ErrorMessage,
+		)
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+	}
+}
+
+// deadLetter sends failure to the dead-letter queue.
+func (a *asyncInvoker) deadLetter(failure asyncFailure) {
+	if a.dlq == nil {
+		a.logger.Error(
+			fmt.Sprintf("Asynchronous invocation failed %d times, dropping it", failure.Attempts),
+			"requestId", failure.RequestID,
+			"err", failure.ErrorMessage,
+		)
+
+		return
+	}
+
+	if err := a.dlq.send(context.Background(), failure); err != nil {
+		a.logger.Error(
+			"[in lambdalocal.asyncInvoker.deadLetter] send to dead-letter queue failed",
+			"requestId", failure.RequestID,
+			"err", err,
+		)
+
+		return
+	}
+
+	a.logger.Warn(
+		fmt.Sprintf("Asynchronous invocation failed %d times, sent it to the dead-letter queue", failure.Attempts),
+		"requestId", failure.RequestID,
+		"err", failure.ErrorMessage,
+	)
+}
+
+// asyncPayload returns data as raw JSON, payloads that aren't JSON as a string.
+func asyncPayload(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+
+	payload, _ := json.Marshal(string(data))
+
+	return payload
+}
+
+// newDeadLetterQueue returns the dead-letter queue at target, an SQS queue URL or a directory.
+func newDeadLetterQueue(target string, lookupEnv func(string) (string, bool)) (deadLetterQueue, error) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		if err := os.MkdirAll(target, 0o755); err != nil { //nolint:mnd
+			return nil, fmt.Errorf("[in lambdalocal.newDeadLetterQueue] create directory failed: %w", err)
+		}
+
+		return dirDeadLetterQueue{dir: target}, nil
+	}
+
+	queue, err := newSQSQueue(target, "")
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.newDeadLetterQueue] %w", err)
+	}
+
+	queueURL, _ := url.Parse(queue.url)
+
+	// queues of local emulators work without credentials
+	credentials, err := credentialsFromEnv(lookupEnv, !strings.HasSuffix(queueURL.Hostname(), ".amazonaws.com"))
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.newDeadLetterQueue] %w", err)
+	}
+
+	client := newSQSClient(
+		queueURL.Scheme+"://"+queueURL.Host,
+		queue.region,
+		credentials,
+		&http.Client{Timeout: 10 * time.Second}, //nolint:mnd
+	)
+
+	return sqsDeadLetterQueue{client: client, queue: queue}, nil
+}
+
+// dirDeadLetterQueue writes every failure to a file of its own, named after its request id.
+type dirDeadLetterQueue struct {
+	dir string
+}
+
+func (q dirDeadLetterQueue) send(_ context.Context, failure asyncFailure) error {
+	data, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal failure failed: %w", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(q.dir, failure.RequestID+".json"), data, 0o600); err != nil {
+		return fmt.Errorf("write failure failed: %w", err)
+	}
+
+	return nil
+}
+
+// sqsDeadLetterQueue sends the payload of every failure to an SQS queue, with the RequestID,
+// ErrorCode and ErrorMessage attributes of Lambda's dead-letter queues.
+type sqsDeadLetterQueue struct {
+	client sqsClient
+	queue  sqsQueue
+}
+
+func (q sqsDeadLetterQueue) send(ctx context.Context, failure asyncFailure) error {
+	return q.client.send(
+		ctx,
+		q.queue,
+		string(failure.body),
+		map[string]string{
+			"RequestID":    failure.RequestID,
+			"ErrorCode":    failure.ErrorCode,
+			"ErrorMessage": failure.ErrorMessage,
+		},
+	)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDeadLetterQueue struct {
+	mu       sync.Mutex
+	failures []asyncFailure
+}
+
+func (q *recordingDeadLetterQueue) send(_ context.Context, failure asyncFailure) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.failures = append(q.failures, failure)
+
+	return nil
+}
+
+// flakyLambdaCaller answers its invocations with the next of its responses and errors, the last
+// ones repeatedly, and records the request ids and client contexts.
+type flakyLambdaCaller struct {
+	mu             sync.Mutex
+	responses      []messages.InvokeResponse
+	errs           []error
+	requestIDs     []string
+	clientContexts []string
+}
+
+func (c *flakyLambdaCaller) Invoke(_ context.Context, _ []byte, options ...Option) (messages.InvokeResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	invokeOpts := invokeOptions{}.with(options)
+	c.requestIDs = append(c.requestIDs, invokeOpts.requestID)
+	c.clientContexts = append(c.clientContexts, string(invokeOpts.clientContext))
+	i := len(c.requestIDs) - 1
+
+	return c.responses[min(i, len(c.responses)-1)], c.errs[min(i, len(c.errs)-1)]
+}
+
+func TestAsyncInvoker(t *testing.T) {
+	t.Parallel()
+
+	functionError := messages.InvokeResponse{
+		Error: &messages.InvokeResponse_Error{Type: "errorString", Message: "boom"},
+	}
+
+	tests := map[string]struct {
+		responses        []messages.InvokeResponse
+		errs             []error
+		retries          int
+		expectedAttempts int
+		expectedFailures []asyncFailure
+	}{
+		"succeeds": {
+			responses:        []messages.InvokeResponse{{Payload: []byte(`{}`)}},
+			errs:             []error{nil},
+			retries:          2,
+			expectedAttempts: 1,
+		},
+		"succeeds on retry": {
+			responses:        []messages.InvokeResponse{functionError, {}, {Payload: []byte(`{}`)}},
+			errs:             []error{nil, errors.New("connection refused"), nil},
+			retries:          2,
+			expectedAttempts: 3,
+		},
+		"retries used up": {
+			responses:        []messages.InvokeResponse{functionError},
+			errs:             []error{nil},
+			retries:          2,
+			expectedAttempts: 3,
+			expectedFailures: []asyncFailure{
+				{
+					RequestID:    "request-1",
+					ErrorCode:    "errorString",
+					ErrorMessage: "boom",
+					Attempts:     3,
+					Payload:      json.RawMessage(`{"id":1}`),
+					body:         []byte(`{"id":1}`),
+				},
+			},
+		},
+		"no retries": {
+			responses:        []messages.InvokeResponse{{}},
+			errs:             []error{errors.New("connection refused")},
+			expectedAttempts: 1,
+			expectedFailures: []asyncFailure{
+				{
+					RequestID:    "request-1",
+					ErrorCode:    asyncErrorCodeInvoke,
+					ErrorMessage: "connection refused",
+					Attempts:     1,
+					Payload:      json.RawMessage(`{"id":1}`),
+					body:         []byte(`{"id":1}`),
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				lambda := &flakyLambdaCaller{responses: tc.responses, errs: tc.errs}
+
+				dlq := &recordingDeadLetterQueue{}
+				policy := asyncPolicy{retries: tc.retries, backoff: time.Millisecond}
+				invoker := newAsyncInvoker(policy, dlq, slog.Default())
+
+				response, err := invoker.caller(lambda).
+					Invoke(context.Background(), []byte(`{"id":1}`), WithRequestID("request-1"))
+				require.NoError(t, err)
+				assert.JSONEq(t, `{"statusCode":202}`, string(response.Payload))
+
+				invoker.Wait()
+
+				// retries keep the request id
+				assert.Len(t, lambda.requestIDs, tc.expectedAttempts)
+
+				for _, requestID := range lambda.requestIDs {
+					assert.Equal(t, "request-1", requestID)
+				}
+
+				assert.Equal(t, tc.expectedFailures, dlq.failures)
+			},
+		)
+	}
+}
+
+func TestDirDeadLetterQueue(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "dlq")
+
+	dlq, err := newDeadLetterQueue(dir, os.LookupEnv)
+	require.NoError(t, err)
+
+	err = dlq.send(
+		context.Background(),
+		asyncFailure{
+			RequestID:    "request-1",
+			ErrorCode:    "errorString",
+			ErrorMessage: "boom",
+			Attempts:     3,
+			Payload:      asyncPayload([]byte("not json")),
+		},
+	)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "request-1.json"))
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"requestId":"request-1","errorCode":"errorString","errorMessage":"boom","attempts":3,"payload":"not json"}`,
+		string(data),
+	)
+}
+
+func TestSQSDeadLetterQueue(t *testing.T) {
+	t.Parallel()
+
+	var request map[string]any
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "AmazonSQS.SendMessage", r.Header.Get("X-Amz-Target"))
+
+				body, _ := io.ReadAll(r.Body)
+				assert.NoError(t, json.Unmarshal(body, &request))
+
+				_, _ = w.Write([]byte(`{"MessageId":"1"}`))
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	lookupEnv := func(string) (string, bool) { return "", false }
+
+	dlq, err := newDeadLetterQueue(server.URL+"/000000000000/dlq", lookupEnv)
+	require.NoError(t, err)
+
+	err = dlq.send(
+		context.Background(),
+		asyncFailure{
+			RequestID:    "request-1",
+			ErrorCode:    "errorString",
+			ErrorMessage: "boom",
+			body:         []byte(`{"id":1}`),
+		},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, server.URL+"/000000000000/dlq", request["QueueUrl"])
+	assert.Equal(t, `{"id":1}`, request["MessageBody"])
+	assert.Equal(
+		t,
+		map[string]any{
+			"RequestID":    map[string]any{"DataType": "String", "StringValue": "request-1"},
+			"ErrorCode":    map[string]any{"DataType": "String", "StringValue": "errorString"},
+			"ErrorMessage": map[string]any{"DataType": "String", "StringValue": "boom"},
+		},
+		request["MessageAttributes"],
+	)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// awsJSONClient calls an AWS API that speaks the AWS JSON 1.0 protocol, like SQS and DynamoDB
+// Streams, with requests signed by Signature Version 4.
+type awsJSONClient struct {
+	endpoint string
+	region   string
+	// service is the signing name of the API.
+	service string
+	// target prefixes the action in the X-Amz-Target header, like AmazonSQS.
+	target      string
+	credentials awsCredentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+// call invokes action with in as the request and decodes the response into out.
+func (c awsJSONClient) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.awsJSONClient.call] marshal %s failed: %w", action, err)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.awsJSONClient.call] new request failed: %w", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	r.Header.Set("X-Amz-Target", c.target+"."+action)
+
+	c.credentials.sign(r, body, c.region, c.service, c.now())
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.awsJSONClient.call] %s failed: %w", action, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.awsJSONClient.call] read %s response failed: %w", action, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"` //nolint:tagliatelle
+			Message string `json:"message"`
+		}
+
+		_ = json.Unmarshal(data, &apiErr)
+
+		return fmt.Errorf(
+			"[in lambdalocal.awsJSONClient.call] %s failed with status %d: %s %s",
+			action,
+			resp.StatusCode,
+			apiErr.Type,
+			apiErr.Message,
+		)
+	}
+
+	if err = json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("[in lambdalocal.awsJSONClient.call] unmarshal %s response failed: %w", action, err)
+	}
+
+	return nil
+}
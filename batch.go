@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// functionResponseReportBatchItemFailures is the FunctionResponseTypes value of SQS and stream
+// events whose lambdas report the items of a batch that failed.
+const functionResponseReportBatchItemFailures = "ReportBatchItemFailures"
+
+// reportsBatchItemFailures reports if functionResponseTypes enables partial batch responses.
+func reportsBatchItemFailures(functionResponseTypes []string) bool {
+	return slices.Contains(functionResponseTypes, functionResponseReportBatchItemFailures)
+}
+
+// batchItemFailures returns the item identifiers in the batchItemFailures of the response payload
+// to a batch of the items with ids. Like Lambda, an empty or null response fails no item, while a
+// response that isn't an object or reports an item that isn't part of the batch fails it all.
+func batchItemFailures(payload []byte, ids []string) (map[string]bool, error) {
+	if len(payload) == 0 || string(payload) == "null" {
+		return nil, nil
+	}
+
+	var response struct {
+		BatchItemFailures []struct {
+			ItemIdentifier *string `json:"itemIdentifier"`
+		} `json:"batchItemFailures"`
+	}
+
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.batchItemFailures] invalid partial batch response: %w", err)
+	}
+
+	failed := make(map[string]bool, len(response.BatchItemFailures))
+
+	for _, failure := range response.BatchItemFailures {
+		if failure.ItemIdentifier == nil || *failure.ItemIdentifier == "" {
+			return nil, errors.New("[in lambdalocal.batchItemFailures] batchItemFailures has an empty itemIdentifier")
+		}
+
+		if !slices.Contains(ids, *failure.ItemIdentifier) {
+			return nil, fmt.Errorf(
+				"[in lambdalocal.batchItemFailures] batchItemFailures reports '%s', which isn't in the batch",
+				*failure.ItemIdentifier,
+			)
+		}
+
+		failed[*failure.ItemIdentifier] = true
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchItemFailures(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c"}
+
+	tests := map[string]struct {
+		payload        string
+		expected       map[string]bool
+		expectedErrStr string
+	}{
+		"empty response": {
+			payload: ``,
+		},
+		"null response": {
+			payload: `null`,
+		},
+		"no failures": {
+			payload:  `{"batchItemFailures":[]}`,
+			expected: map[string]bool{},
+		},
+		"other response": {
+			payload:  `{"statusCode":200}`,
+			expected: map[string]bool{},
+		},
+		"failures": {
+			payload:  `{"batchItemFailures":[{"itemIdentifier":"a"},{"itemIdentifier":"c"}]}`,
+			expected: map[string]bool{"a": true, "c": true},
+		},
+		"not an object": {
+			payload:        `"done"`,
+			expectedErrStr: "[in lambdalocal.batchItemFailures] invalid partial batch response",
+		},
+		"empty identifier": {
+			payload:        `{"batchItemFailures":[{"itemIdentifier":""}]}`,
+			expectedErrStr: "[in lambdalocal.batchItemFailures] batchItemFailures has an empty itemIdentifier",
+		},
+		"missing identifier": {
+			payload:        `{"batchItemFailures":[{"id":"a"}]}`,
+			expectedErrStr: "[in lambdalocal.batchItemFailures] batchItemFailures has an empty itemIdentifier",
+		},
+		"unknown identifier": {
+			payload:        `{"batchItemFailures":[{"itemIdentifier":"d"}]}`,
+			expectedErrStr: "batchItemFailures reports 'd', which isn't in the batch",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				failed, err := batchItemFailures([]byte(tc.payload), ids)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, failed)
+			},
+		)
+	}
+}
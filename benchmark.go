@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// benchmarkResult holds the latencies of the invocations of a benchmark run.
+type benchmarkResult struct {
+	concurrency int
+	latencies   []time.Duration
+	// errors counts the invocations that failed, returned a handler error, or an API response with a
+	// status code outside of 2xx.
+	errors  int
+	elapsed time.Duration
+}
+
+// RunLambdaBenchmark invokes the lambda repeat times with event, concurrency invocations at a
+// time, and prints a summary of their latencies.
+func RunLambdaBenchmark(
+	ctx context.Context,
+	w io.Writer,
+	lambdaRPC lambdaCaller,
+	event string,
+	repeat, concurrency int,
+	failOnError bool,
+	logger *slog.Logger,
+) error {
+	_, _ = fmt.Fprintln(w, line)
+
+	logger.Info(fmt.Sprintf("Invoking lambda %d times, %d at a time", repeat, concurrency))
+
+	// Ctrl+C cancels the invocations in flight and prints the summary of those that completed
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result := runBenchmark(ctx, lambdaRPC, []byte(event), repeat, concurrency, logger)
+
+	logger.Info("Lambda benchmark complete, Exiting...")
+
+	_, _ = fmt.Fprintln(w, line)
+
+	if err := printBenchmark(w, result); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunLambdaBenchmark] %w", err)
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("[in lambdalocal.RunLambdaBenchmark] benchmark interrupted: %w", ctx.Err())
+	}
+
+	if failOnError && result.errors > 0 {
+		return fmt.Errorf(
+			"[in lambdalocal.RunLambdaBenchmark] %w: %d of %d invocations",
+			errInvocationFailed,
+			result.errors,
+			len(result.latencies),
+		)
+	}
+
+	return nil
+}
+
+// runBenchmark invokes caller repeat times, stopping early when ctx is done.
+func runBenchmark(
+	ctx context.Context,
+	caller lambdaCaller,
+	event []byte,
+	repeat, concurrency int,
+	logger *slog.Logger,
+) benchmarkResult {
+	concurrency = max(1, min(concurrency, repeat))
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = benchmarkResult{concurrency: concurrency, latencies: make([]time.Duration, 0, repeat)}
+	)
+
+	invocations := make(chan int)
+
+	go func() {
+		defer close(invocations)
+
+		for i := range repeat {
+			select {
+			case <-ctx.Done():
+				return
+			case invocations <- i:
+			}
+		}
+	}()
+
+	start := time.Now()
+
+	wg.Add(concurrency)
+
+	for range concurrency {
+		go func() {
+			defer wg.Done()
+
+			for i := range invocations {
+				invocationStart := time.Now()
+				response, err := caller.Invoke(ctx, event)
+				latency := time.Since(invocationStart)
+
+				// invocations canceled by Ctrl+C didn't complete, they aren't counted
+				if errors.Is(err, context.Canceled) {
+					continue
+				}
+
+				if err == nil {
+					err = invocationFailure(response)
+				}
+
+				if err != nil {
+					logger.Debug("Invocation failed", "invocation", i, "err", err)
+				}
+
+				mu.Lock()
+				result.latencies = append(result.latencies, latency)
+
+				if err != nil {
+					result.errors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	result.elapsed = time.Since(start)
+
+	slices.Sort(result.latencies)
+
+	return result
+}
+
+// percentile returns the latency below which p percent of the invocations are, by nearest rank.
+func (r benchmarkResult) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(r.latencies)))) //nolint:mnd
+
+	return r.latencies[max(rank, 1)-1]
+}
+
+func (r benchmarkResult) average() time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, latency := range r.latencies {
+		total += latency
+	}
+
+	return total / time.Duration(len(r.latencies))
+}
+
+// throughput returns the invocations per second.
+func (r benchmarkResult) throughput() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+
+	return float64(len(r.latencies)) / r.elapsed.Seconds()
+}
+
+// printBenchmark prints the summary table of a benchmark run.
+func printBenchmark(w io.Writer, r benchmarkResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd
+	_, _ = fmt.Fprintln(tw, "INVOCATIONS\tCONCURRENCY\tERRORS\tMIN\tAVG\tP50\tP95\tP99\tMAX\tREQ/S")
+
+	if len(r.latencies) == 0 {
+		_, _ = fmt.Fprintf(tw, "0\t%d\t0\t-\t-\t-\t-\t-\t-\t-\n", r.concurrency)
+	} else {
+		_, _ = fmt.Fprintf(
+			tw,
+			"%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%.1f\n",
+			len(r.latencies),
+			r.concurrency,
+			r.errors,
+			formatLatency(r.latencies[0]),
+			formatLatency(r.average()),
+			formatLatency(r.percentile(50)), //nolint:mnd
+			formatLatency(r.percentile(95)), //nolint:mnd
+			formatLatency(r.percentile(99)), //nolint:mnd
+			formatLatency(r.latencies[len(r.latencies)-1]),
+			r.throughput(),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("[in lambdalocal.printBenchmark] Flush failed: %w", err)
+	}
+
+	return nil
+}
+
+// formatLatency rounds latency to about three significant digits, like 1.23ms or 456µs.
+func formatLatency(latency time.Duration) string {
+	switch {
+	case latency >= time.Second:
+		return latency.Round(10 * time.Millisecond).String() //nolint:mnd
+	case latency >= 100*time.Millisecond:
+		return latency.Round(time.Millisecond).String()
+	case latency >= 10*time.Millisecond:
+		return latency.Round(100 * time.Microsecond).String() //nolint:mnd
+	case latency >= time.Millisecond:
+		return latency.Round(10 * time.Microsecond).String() //nolint:mnd
+	default:
+		return latency.Round(time.Microsecond).String()
+	}
+}
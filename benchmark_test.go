@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingLambdaCaller fails every fourth invocation and tracks how many invocations run at once.
+type countingLambdaCaller struct {
+	invocations atomic.Int64
+	running     atomic.Int64
+	mu          sync.Mutex
+	maxRunning  int64
+}
+
+func (c *countingLambdaCaller) Invoke(context.Context, []byte, ...Option) (messages.InvokeResponse, error) {
+	running := c.running.Add(1)
+	defer c.running.Add(-1)
+
+	c.mu.Lock()
+	c.maxRunning = max(c.maxRunning, running)
+	c.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	switch c.invocations.Add(1) % 4 {
+	case 1:
+		return messages.InvokeResponse{}, errors.New("connection refused")
+	case 2:
+		return messages.InvokeResponse{Payload: []byte(`{"statusCode":500}`)}, nil
+	default:
+		return messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}, nil
+	}
+}
+
+func TestRunBenchmark(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		repeat      int
+		concurrency int
+		expected    int
+	}{
+		"sequential":                {repeat: 8, concurrency: 1, expected: 1},
+		"concurrent":                {repeat: 40, concurrency: 4, expected: 4},
+		"concurrency above repeats": {repeat: 2, concurrency: 10, expected: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := &countingLambdaCaller{}
+
+				result := runBenchmark(
+					context.Background(),
+					caller,
+					[]byte(`{}`),
+					tc.repeat,
+					tc.concurrency,
+					slog.New(slog.DiscardHandler),
+				)
+
+				assert.Len(t, result.latencies, tc.repeat)
+				assert.Equal(t, tc.expected, result.concurrency)
+				assert.LessOrEqual(t, caller.maxRunning, int64(tc.expected))
+				// invocation errors and 500s are errors
+				assert.Equal(t, (tc.repeat+3)/4+(tc.repeat+2)/4, result.errors)
+				assert.True(t, slices.IsSorted(result.latencies))
+			},
+		)
+	}
+}
+
+func TestRunBenchmarkCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := runBenchmark(ctx, &countingLambdaCaller{}, []byte(`{}`), 1000, 4, slog.New(slog.DiscardHandler))
+	assert.Less(t, len(result.latencies), 1000)
+}
+
+func TestBenchmarkResultPercentile(t *testing.T) {
+	t.Parallel()
+
+	result := benchmarkResult{}
+	for i := 1; i <= 100; i++ {
+		result.latencies = append(result.latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Millisecond, result.percentile(0))
+	assert.Equal(t, 50*time.Millisecond, result.percentile(50))
+	assert.Equal(t, 95*time.Millisecond, result.percentile(95))
+	assert.Equal(t, 99*time.Millisecond, result.percentile(99))
+	assert.Equal(t, 100*time.Millisecond, result.percentile(100))
+	assert.Equal(t, 50500*time.Microsecond, result.average())
+
+	assert.Equal(t, time.Duration(0), benchmarkResult{}.percentile(50))
+}
+
+func TestPrintBenchmark(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	require.NoError(
+		t,
+		printBenchmark(
+			&out,
+			benchmarkResult{
+				concurrency: 2,
+				latencies:   []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, time.Second},
+				errors:      1,
+				elapsed:     2 * time.Second,
+			},
+		),
+	)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(
+		t,
+		[]string{"INVOCATIONS", "CONCURRENCY", "ERRORS", "MIN", "AVG", "P50", "P95", "P99", "MAX", "REQ/S"},
+		strings.Fields(lines[0]),
+	)
+	assert.Equal(t, []string{"4", "2", "1", "1ms", "252ms", "2ms", "1s", "1s", "1s", "2.0"}, strings.Fields(lines[1]))
+}
+
+func TestFormatLatency(t *testing.T) {
+	t.Parallel()
+
+	tests := map[time.Duration]string{
+		1234567 * time.Nanosecond:   "1.23ms",
+		456789 * time.Nanosecond:    "457µs",
+		12345678 * time.Nanosecond:  "12.3ms",
+		123456789 * time.Nanosecond: "123ms",
+		1234567890:                  "1.23s",
+	}
+
+	for latency, expected := range tests {
+		assert.Equal(t, expected, formatLatency(latency))
+	}
+}
+
+func TestRunLambdaBenchmarkFailOnError(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	logger := slog.New(slog.DiscardHandler)
+
+	require.NoError(
+		t,
+		RunLambdaBenchmark(context.Background(), &out, &countingLambdaCaller{}, `{}`, 8, 2, false, logger),
+	)
+	assert.Contains(t, out.String(), "INVOCATIONS")
+
+	err := RunLambdaBenchmark(context.Background(), &out, &countingLambdaCaller{}, `{}`, 8, 2, true, logger)
+	require.ErrorIs(t, err, errInvocationFailed)
+	assert.ErrorContains(t, err, "4 of 8 invocations")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// textMediaTypes are the media types HTTP APIs pass to the lambda as text. Bodies of any other
+// media type are base64 encoded.
+var textMediaTypes = []string{ //nolint:gochecknoglobals
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"application/*+xml",
+	"application/javascript",
+	"application/x-www-form-urlencoded",
+	"application/graphql",
+}
+
+// normalizeBinaryMediaTypes decodes the '~1' escape SAM templates use for the '/' of a media type
+// in BinaryMediaTypes, e.g. 'image~1png'.
+func normalizeBinaryMediaTypes(mediaTypes []string) []string {
+	if len(mediaTypes) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		normalized = append(normalized, strings.ReplaceAll(mediaType, "~1", "/"))
+	}
+
+	return normalized
+}
+
+// isBinaryMediaType reports whether contentType matches one of the REST API binaryMediaTypes.
+// Like API Gateway, types may use wildcards such as 'image/*' or '*/*'.
+func isBinaryMediaType(contentType string, binaryMediaTypes []string) bool {
+	if contentType == "" {
+		return false
+	}
+
+	return matchesMediaType(contentType, binaryMediaTypes)
+}
+
+// isTextMediaType reports whether a payload format 2.0 body of contentType is passed as text.
+// Requests without a Content-Type are treated as text.
+func isTextMediaType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	return matchesMediaType(contentType, textMediaTypes)
+}
+
+func matchesMediaType(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	for _, pattern := range patterns {
+		patternType, patternSubtype, _ := strings.Cut(strings.ToLower(pattern), "/")
+
+		if patternType != "*" && patternType != typ {
+			continue
+		}
+
+		if patternSubtype == "*" || patternSubtype == subtype {
+			return true
+		}
+
+		// suffix patterns like '*+json' match 'vnd.api+json'
+		if suffix, ok := strings.CutPrefix(patternSubtype, "*"); ok && strings.HasSuffix(subtype, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBinaryRequest reports whether the body of a payload format 1.0 request is base64 encoded in
+// the event: its Content-Type matches binaryMediaTypes, or it is compressed.
+func isBinaryRequest(header http.Header, binaryMediaTypes []string) bool {
+	return isCompressed(header) || isBinaryMediaType(header.Get("Content-Type"), binaryMediaTypes)
+}
+
+// isBinaryHTTPAPIRequest reports whether the body of a payload format 2.0 request is base64
+// encoded in the event: its Content-Type isn't text or matches binaryMediaTypes, or it is
+// compressed.
+func isBinaryHTTPAPIRequest(header http.Header, binaryMediaTypes []string) bool {
+	contentType := header.Get("Content-Type")
+
+	return isCompressed(header) || !isTextMediaType(contentType) || isBinaryMediaType(contentType, binaryMediaTypes)
+}
+
+// isCompressed reports whether the body was sent with a Content-Encoding like gzip. Compressed
+// bodies are binary whatever their Content-Type, decoding them as a string would corrupt them.
+func isCompressed(header http.Header) bool {
+	for _, value := range header.Values("Content-Encoding") {
+		for encoding := range strings.SplitSeq(value, ",") {
+			if encoding = strings.TrimSpace(encoding); encoding != "" && !strings.EqualFold(encoding, "identity") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// encodeBody returns the body as sent in an event, base64 encoding binary bodies. Text bodies that
+// aren't valid UTF-8, like XML declaring encoding="ISO-8859-1", are base64 encoded too, since a
+// JSON string would replace their invalid bytes and the lambda couldn't get the body as it was
+// sent.
+func encodeBody(body []byte, binary bool) (string, bool) {
+	if len(body) == 0 || (!binary && utf8.Valid(body)) {
+		return string(body), false
+	}
+
+	return base64.StdEncoding.EncodeToString(body), true
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBinaryMediaType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		contentType      string
+		binaryMediaTypes []string
+		expected         bool
+	}{
+		"exact match": {
+			contentType:      "image/png",
+			binaryMediaTypes: []string{"image/png"},
+			expected:         true,
+		},
+		"match with parameters": {
+			contentType:      "application/octet-stream; charset=binary",
+			binaryMediaTypes: []string{"application/octet-stream"},
+			expected:         true,
+		},
+		"subtype wildcard": {
+			contentType:      "image/jpeg",
+			binaryMediaTypes: []string{"image/*"},
+			expected:         true,
+		},
+		"any media type": {
+			contentType:      "application/json",
+			binaryMediaTypes: []string{"*/*"},
+			expected:         true,
+		},
+		"no match": {
+			contentType:      "application/json",
+			binaryMediaTypes: []string{"image/*"},
+			expected:         false,
+		},
+		"no content type": {
+			contentType:      "",
+			binaryMediaTypes: []string{"*/*"},
+			expected:         false,
+		},
+		"no binary media types": {
+			contentType: "image/png",
+			expected:    false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, isBinaryMediaType(tc.contentType, tc.binaryMediaTypes))
+			},
+		)
+	}
+}
+
+func TestIsTextMediaType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		contentType string
+		expected    bool
+	}{
+		"no content type":    {contentType: "", expected: true},
+		"plain text":         {contentType: "text/plain; charset=utf-8", expected: true},
+		"json":               {contentType: "application/json", expected: true},
+		"json suffix":        {contentType: "application/vnd.api+json", expected: true},
+		"form":               {contentType: "application/x-www-form-urlencoded", expected: true},
+		"image":              {contentType: "image/png", expected: false},
+		"octet stream":       {contentType: "application/octet-stream", expected: false},
+		"invalid media type": {contentType: "/", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, isTextMediaType(tc.contentType))
+			},
+		)
+	}
+}
+
+func TestIsBinaryRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		contentType      string
+		contentEncoding  []string
+		binaryMediaTypes []string
+		expected         bool
+		expectedV2       bool
+	}{
+		"json": {
+			contentType: "application/json",
+		},
+		"gzip json": {
+			contentType:     "application/json",
+			contentEncoding: []string{"gzip"},
+			expected:        true,
+			expectedV2:      true,
+		},
+		"identity": {
+			contentType:     "application/json",
+			contentEncoding: []string{"identity"},
+		},
+		"several encodings": {
+			contentType:     "text/plain",
+			contentEncoding: []string{"identity, br"},
+			expected:        true,
+			expectedV2:      true,
+		},
+		"binary media type": {
+			contentType:      "application/json",
+			binaryMediaTypes: []string{"application/*"},
+			expected:         true,
+			expectedV2:       true,
+		},
+		"image without binary media types": {
+			contentType: "image/png",
+			expectedV2:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				header := http.Header{"Content-Type": []string{tc.contentType}}
+				if tc.contentEncoding != nil {
+					header["Content-Encoding"] = tc.contentEncoding
+				}
+
+				assert.Equal(t, tc.expected, isBinaryRequest(header, tc.binaryMediaTypes))
+				assert.Equal(t, tc.expectedV2, isBinaryHTTPAPIRequest(header, tc.binaryMediaTypes))
+			},
+		)
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		body                    string
+		binary                  bool
+		expectedBody            string
+		expectedIsBase64Encoded bool
+	}{
+		"text":       {body: `<a>&amp;</a>`, expectedBody: `<a>&amp;</a>`},
+		"binary":     {body: "\x89PNG", binary: true, expectedBody: "iVBORw==", expectedIsBase64Encoded: true},
+		"empty":      {body: "", binary: true, expectedBody: ""},
+		"not utf-8":  {body: "<a>caf\xe9</a>", expectedBody: "PGE+Y2Fm6TwvYT4=", expectedIsBase64Encoded: true},
+		"multi-byte": {body: "<a>café</a>", expectedBody: "<a>café</a>"},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				body, isBase64Encoded := encodeBody([]byte(tc.body), tc.binary)
+
+				assert.Equal(t, tc.expectedBody, body)
+				assert.Equal(t, tc.expectedIsBase64Encoded, isBase64Encoded)
+			},
+		)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// latencyBudgets counts the invocations of routes with a latency budget and those over budget.
+type latencyBudgets struct {
+	mu      sync.Mutex
+	budgets map[string]time.Duration
+	routes  map[string]*budgetMetrics
+	logger  *slog.Logger
+}
+
+type budgetMetrics struct {
+	BudgetMs     int64 `json:"budgetMs"`
+	Invocations  int   `json:"invocations"`
+	Exceeded     int   `json:"exceeded"`
+	MaxLatencyMs int64 `json:"maxLatencyMs"`
+}
+
+func newLatencyBudgets(budgets map[string]time.Duration, logger *slog.Logger) *latencyBudgets {
+	return &latencyBudgets{
+		budgets: budgets,
+		routes:  make(map[string]*budgetMetrics),
+		logger:  logger,
+	}
+}
+
+// caller wraps caller so invocations of route are checked against the route's budget. Routes
+// without a budget return caller.
+func (b *latencyBudgets) caller(caller lambdaCaller, route string) lambdaCaller {
+	budget, ok := b.budgets[route]
+	if !ok {
+		return caller
+	}
+
+	b.mu.Lock()
+	b.routes[route] = &budgetMetrics{BudgetMs: budget.Milliseconds()}
+	b.mu.Unlock()
+
+	return budgetCaller{lambdaCaller: caller, budgets: b, route: route, budget: budget}
+}
+
+// unknownRoutes returns the routes with a budget that aren't in routes, sorted.
+func (b *latencyBudgets) unknownRoutes(routes []apiRoute) []string {
+	known := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		known[route.routeKey()] = true
+	}
+
+	var unknown []string
+
+	for route := range b.budgets {
+		if !known[route] {
+			unknown = append(unknown, route)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+func (b *latencyBudgets) record(route string, latency, budget time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	metrics := b.routes[route]
+	metrics.Invocations++
+	metrics.MaxLatencyMs = max(metrics.MaxLatencyMs, latency.Milliseconds())
+
+	if latency > budget {
+		metrics.Exceeded++
+
+		b.logger.Warn(
+			fmt.Sprintf("%s took %s, over its latency budget of %s", route, latency.Round(time.Millisecond), budget),
+			"exceeded",
+			metrics.Exceeded,
+			"invocations",
+			metrics.Invocations,
+		)
+	}
+}
+
+// snapshot returns a copy of the metrics of every route with a budget.
+func (b *latencyBudgets) snapshot() map[string]budgetMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := make(map[string]budgetMetrics, len(b.routes))
+	for route, metrics := range b.routes {
+		snapshot[route] = *metrics
+	}
+
+	return snapshot
+}
+
+type budgetCaller struct {
+	lambdaCaller
+	budgets *latencyBudgets
+	route   string
+	budget  time.Duration
+}
+
+func (c budgetCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	start := time.Now()
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+
+	c.budgets.record(c.route, time.Since(start), c.budget)
+
+	return response, err //nolint:wrapcheck
+}
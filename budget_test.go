@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyBudgets(t *testing.T) {
+	t.Parallel()
+
+	budgets := newLatencyBudgets(
+		map[string]time.Duration{
+			"GET /slow":    time.Millisecond,
+			"GET /fast":    time.Hour,
+			"GET /missing": time.Second,
+		},
+		slog.Default(),
+	)
+
+	slowLambda := new(MockLambdaCaller)
+	slowLambda.On("Invoke", mock.Anything).
+		Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil).
+		After(5 * time.Millisecond)
+
+	fastLambda := new(MockLambdaCaller)
+	fastLambda.On("Invoke", mock.Anything).Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+
+	slow := budgets.caller(slowLambda, "GET /slow")
+	fast := budgets.caller(fastLambda, "GET /fast")
+
+	// routes without a budget are not wrapped
+	assert.Equal(t, fastLambda, budgets.caller(fastLambda, "GET /other"))
+
+	for range 2 {
+		_, err := slow.Invoke(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+		_, err = fast.Invoke(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+	}
+
+	snapshot := budgets.snapshot()
+
+	assert.Equal(t, int64(1), snapshot["GET /slow"].BudgetMs)
+	assert.Equal(t, 2, snapshot["GET /slow"].Invocations)
+	assert.Equal(t, 2, snapshot["GET /slow"].Exceeded)
+	assert.GreaterOrEqual(t, snapshot["GET /slow"].MaxLatencyMs, int64(5))
+	assert.Equal(t, 2, snapshot["GET /fast"].Invocations)
+	assert.Equal(t, 0, snapshot["GET /fast"].Exceeded)
+	assert.NotContains(t, snapshot, "GET /missing")
+
+	assert.Equal(
+		t,
+		[]string{"GET /missing"},
+		budgets.unknownRoutes([]apiRoute{{method: "GET", path: "/slow"}, {method: "GET", path: "/fast"}}),
+	)
+}
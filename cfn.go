@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	cfnHTTPAPIType     = "AWS::ApiGatewayV2::Api"
+	cfnRouteType       = "AWS::ApiGatewayV2::Route"
+	cfnIntegrationType = "AWS::ApiGatewayV2::Integration"
+	cfnRestAPIType     = "AWS::ApiGateway::RestApi"
+	cfnResourceType    = "AWS::ApiGateway::Resource"
+	cfnMethodType      = "AWS::ApiGateway::Method"
+
+	// cfnProxyIntegration is the only integration type lambdalocal can emulate.
+	cfnProxyIntegration = "AWS_PROXY"
+	// cfnIntegrationTarget prefixes the integration ID in the Target of a route.
+	cfnIntegrationTarget = "integrations/"
+	// cfnMaxPathDepth limits the resources followed up to the root of a REST API.
+	cfnMaxPathDepth = 100
+)
+
+// cfnTemplate holds the API Gateway resources of a plain CloudFormation template.
+type cfnTemplate struct {
+	Resources map[string]struct {
+		Type       string `yaml:"Type"` //nolint:tagliatelle
+		Properties struct {
+			// ProtocolType, Target and CorsConfiguration are set on AWS::ApiGatewayV2::Api.
+			ProtocolType      string `yaml:"ProtocolType"`      //nolint:tagliatelle
+			Target            any    `yaml:"Target"`            //nolint:tagliatelle
+			CorsConfiguration any    `yaml:"CorsConfiguration"` //nolint:tagliatelle
+			// APIID and RouteKey are set on AWS::ApiGatewayV2::Route.
+			APIID    any    `yaml:"ApiId"`    //nolint:tagliatelle
+			RouteKey string `yaml:"RouteKey"` //nolint:tagliatelle
+			// IntegrationType, IntegrationURI and PayloadFormatVersion are set on
+			// AWS::ApiGatewayV2::Integration.
+			IntegrationType      string `yaml:"IntegrationType"`      //nolint:tagliatelle
+			IntegrationURI       any    `yaml:"IntegrationUri"`       //nolint:tagliatelle
+			PayloadFormatVersion string `yaml:"PayloadFormatVersion"` //nolint:tagliatelle
+			// BinaryMediaTypes is set on AWS::ApiGateway::RestApi.
+			BinaryMediaTypes []string `yaml:"BinaryMediaTypes"` //nolint:tagliatelle
+			// ParentID and PathPart are set on AWS::ApiGateway::Resource.
+			ParentID any    `yaml:"ParentId"` //nolint:tagliatelle
+			PathPart string `yaml:"PathPart"` //nolint:tagliatelle
+			// RestAPIID, ResourceID, HTTPMethod and Integration are set on AWS::ApiGateway::Method.
+			RestAPIID   any    `yaml:"RestApiId"`  //nolint:tagliatelle
+			ResourceID  any    `yaml:"ResourceId"` //nolint:tagliatelle
+			HTTPMethod  string `yaml:"HttpMethod"` //nolint:tagliatelle
+			Integration struct {
+				Type string `yaml:"Type"` //nolint:tagliatelle
+				URI  any    `yaml:"Uri"`  //nolint:tagliatelle
+			} `yaml:"Integration"` //nolint:tagliatelle
+		} `yaml:"Properties"` //nolint:tagliatelle
+	} `yaml:"Resources"` //nolint:tagliatelle
+}
+
+// cfnRoutes returns the routes of the AWS::ApiGatewayV2 and AWS::ApiGateway resources of a
+// template. Only routes and methods with a lambda proxy integration are returned.
+func cfnRoutes(template cfnTemplate) []apiRoute {
+	var (
+		routes           []apiRoute
+		binaryMediaTypes []string
+	)
+
+	for _, resource := range template.Resources {
+		if resource.Type == cfnRestAPIType {
+			binaryMediaTypes = append(binaryMediaTypes, resource.Properties.BinaryMediaTypes...)
+		}
+	}
+
+	binaryMediaTypes = normalizeBinaryMediaTypes(binaryMediaTypes)
+
+	for _, resource := range template.Resources {
+		properties := resource.Properties
+
+		switch resource.Type {
+		case cfnHTTPAPIType:
+			// quick create APIs send every request to their Target
+			function := lambdaTarget(properties.Target)
+			if function == "" || !strings.EqualFold(properties.ProtocolType, "HTTP") {
+				continue
+			}
+
+			route := httpAPIRoute(defaultRouteKey, "", "")
+			route.function = function
+			route.cors = httpAPICors(properties.CorsConfiguration)
+
+			routes = append(routes, route)
+		case cfnRouteType:
+			integrationID, ok := strings.CutPrefix(refName(properties.Target), cfnIntegrationTarget)
+			if !ok {
+				continue
+			}
+
+			integration, ok := template.Resources[integrationID]
+			if !ok || integration.Type != cfnIntegrationType ||
+				!strings.EqualFold(integration.Properties.IntegrationType, cfnProxyIntegration) {
+				continue
+			}
+
+			function := lambdaTarget(integration.Properties.IntegrationURI)
+			if function == "" {
+				continue
+			}
+
+			method, path, _ := strings.Cut(properties.RouteKey, " ")
+			if path == "" {
+				// $default has no method
+				method, path = "", method
+			}
+
+			route := httpAPIRoute(path, method, integration.Properties.PayloadFormatVersion)
+			route.function = function
+			route.cors = httpAPICors(template.Resources[refName(properties.APIID)].Properties.CorsConfiguration)
+
+			routes = append(routes, route)
+		case cfnMethodType:
+			if !strings.EqualFold(properties.Integration.Type, cfnProxyIntegration) {
+				continue
+			}
+
+			function := lambdaTarget(properties.Integration.URI)
+			if function == "" {
+				continue
+			}
+
+			routes = append(
+				routes, apiRoute{
+					method:           routeMethod(properties.HTTPMethod),
+					path:             cfnResourcePath(template, refName(properties.ResourceID), 0),
+					function:         function,
+					binaryMediaTypes: binaryMediaTypes,
+					payloadFormat:    payloadFormatV1,
+				},
+			)
+		}
+	}
+
+	return routes
+}
+
+// cfnResourcePath returns the path of an AWS::ApiGateway::Resource, built from the PathPart of the
+// resource and its parents. Anything else, like !GetAtt Api.RootResourceId, is the root.
+func cfnResourcePath(template cfnTemplate, id string, depth int) string {
+	resource, ok := template.Resources[id]
+	if !ok || resource.Type != cfnResourceType || depth > cfnMaxPathDepth {
+		return "/"
+	}
+
+	parent := cfnResourcePath(template, refName(resource.Properties.ParentID), depth+1)
+
+	return strings.TrimSuffix(parent, "/") + "/" + resource.Properties.PathPart
+}
+
+// lambdaTarget returns the logical ID of the function an integration invokes, from an
+// integration uri like integrationFunction or from a function ARN written with Fn::GetAtt.
+func lambdaTarget(v any) string {
+	if function := integrationFunction(v); function != "" {
+		return function
+	}
+
+	switch value := v.(type) {
+	case string:
+		// the short form !GetAtt Function.Arn
+		if function, ok := strings.CutSuffix(value, ".Arn"); ok && !strings.Contains(function, ":") {
+			return function
+		}
+	case []any:
+		// the short form !GetAtt [Function, Arn]
+		if len(value) == 2 && value[1] == "Arn" { //nolint:mnd
+			function, _ := value[0].(string)
+
+			return function
+		}
+	case map[string]any:
+		if getAtt, ok := value["Fn::GetAtt"]; ok {
+			return lambdaTarget(getAtt)
+		}
+	}
+
+	return ""
+}
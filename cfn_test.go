@@ -0,0 +1,185 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCFNRoutes(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Resources:
+  HttpApi:
+    Type: AWS::ApiGatewayV2::Api
+    Properties:
+      ProtocolType: HTTP
+      CorsConfiguration:
+        AllowOrigins: ["*"]
+  HelloRoute:
+    Type: AWS::ApiGatewayV2::Route
+    Properties:
+      ApiId: !Ref HttpApi
+      RouteKey: GET /hello
+      Target: !Sub integrations/${HelloIntegration}
+  DefaultRoute:
+    Type: AWS::ApiGatewayV2::Route
+    Properties:
+      ApiId: !Ref HttpApi
+      RouteKey: $default
+      Target: !Join ["/", [integrations, !Ref HelloIntegration]]
+  MockRoute:
+    Type: AWS::ApiGatewayV2::Route
+    Properties:
+      ApiId: !Ref HttpApi
+      RouteKey: GET /mock
+      Target: !Sub integrations/${MockIntegration}
+  HelloIntegration:
+    Type: AWS::ApiGatewayV2::Integration
+    Properties:
+      ApiId: !Ref HttpApi
+      IntegrationType: AWS_PROXY
+      IntegrationUri: !GetAtt HelloFunction.Arn
+      PayloadFormatVersion: "1.0"
+  MockIntegration:
+    Type: AWS::ApiGatewayV2::Integration
+    Properties:
+      ApiId: !Ref HttpApi
+      IntegrationType: HTTP_PROXY
+      IntegrationUri: https://example.com
+  QuickApi:
+    Type: AWS::ApiGatewayV2::Api
+    Properties:
+      ProtocolType: HTTP
+      Target:
+        Fn::GetAtt: [QuickFunction, Arn]
+  RestApi:
+    Type: AWS::ApiGateway::RestApi
+    Properties:
+      BinaryMediaTypes: ["image/png"]
+  OrdersResource:
+    Type: AWS::ApiGateway::Resource
+    Properties:
+      RestApiId: !Ref RestApi
+      ParentId: !GetAtt RestApi.RootResourceId
+      PathPart: orders
+  OrderResource:
+    Type: AWS::ApiGateway::Resource
+    Properties:
+      RestApiId: !Ref RestApi
+      ParentId: !Ref OrdersResource
+      PathPart: "{id}"
+  OrderMethod:
+    Type: AWS::ApiGateway::Method
+    Properties:
+      RestApiId: !Ref RestApi
+      ResourceId: !Ref OrderResource
+      HttpMethod: ANY
+      Integration:
+        Type: AWS_PROXY
+        IntegrationHttpMethod: POST
+        Uri: !Sub arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${OrderFunction.Arn}/invocations
+  RootMethod:
+    Type: AWS::ApiGateway::Method
+    Properties:
+      RestApiId: !Ref RestApi
+      ResourceId: !GetAtt RestApi.RootResourceId
+      HttpMethod: GET
+      Integration:
+        Type: AWS_PROXY
+        Uri: !Sub arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${OrderFunction.Arn}/invocations
+  OptionsMethod:
+    Type: AWS::ApiGateway::Method
+    Properties:
+      RestApiId: !Ref RestApi
+      ResourceId: !Ref OrdersResource
+      HttpMethod: OPTIONS
+      Integration:
+        Type: MOCK
+`
+
+	var cfn cfnTemplate
+	require.NoError(t, unmarshalTemplate([]byte(template), nil, &cfn))
+
+	routes := cfnRoutes(cfn)
+	sort.Slice(
+		routes, func(i, j int) bool {
+			if routes[i].routeKey() == routes[j].routeKey() {
+				return routes[i].function < routes[j].function
+			}
+
+			return routes[i].routeKey() < routes[j].routeKey()
+		},
+	)
+
+	allOrigins := &corsConfig{allowOrigins: []string{"*"}, httpAPI: true}
+
+	assert.Equal(
+		t,
+		[]apiRoute{
+			{
+				method:        "",
+				path:          "/{proxy+}",
+				function:      "HelloFunction",
+				payloadFormat: payloadFormatV1,
+				cors:          allOrigins,
+			},
+			{method: "", path: "/{proxy+}", function: "QuickFunction", payloadFormat: payloadFormatV2},
+			{
+				method:           "",
+				path:             "/orders/{id}",
+				function:         "OrderFunction",
+				binaryMediaTypes: []string{"image/png"},
+				payloadFormat:    payloadFormatV1,
+			},
+			{
+				method:           "GET",
+				path:             "/",
+				function:         "OrderFunction",
+				binaryMediaTypes: []string{"image/png"},
+				payloadFormat:    payloadFormatV1,
+			},
+			{
+				method:        "GET",
+				path:          "/hello",
+				function:      "HelloFunction",
+				payloadFormat: payloadFormatV1,
+				cors:          allOrigins,
+			},
+		},
+		routes,
+	)
+}
+
+func TestLambdaTarget(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target   any
+		expected string
+	}{
+		"short get att":      {target: "HelloFn.Arn", expected: "HelloFn"},
+		"short get att list": {target: []any{"HelloFn", "Arn"}, expected: "HelloFn"},
+		"long get att":       {target: map[string]any{"Fn::GetAtt": []any{"HelloFn", "Arn"}}, expected: "HelloFn"},
+		"integration uri": {
+			target:   "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/${HelloFn.Arn}/invocations",
+			expected: "HelloFn",
+		},
+		"plain arn":      {target: "arn:aws:lambda:us-east-1:123456789012:function:hello", expected: ""},
+		"other get att":  {target: "HelloFn.Version", expected: ""},
+		"missing target": {target: nil, expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, lambdaTarget(tc.target))
+			},
+		)
+	}
+}
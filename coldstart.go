@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	coldStartAlways        = "always"
+	coldStartPercentPrefix = "percent="
+)
+
+// coldStartPolicy selects the invocations that restart the managed lambda process before they
+// run, like the cold starts of new execution environments. The zero value selects none.
+type coldStartPolicy struct {
+	// percent is the share of invocations, between 0 and 100, that are cold starts.
+	percent float64
+}
+
+// parseColdStart parses the policy of --cold-start, always or percent=N.
+func parseColdStart(s string) (coldStartPolicy, error) {
+	if s == coldStartAlways {
+		return coldStartPolicy{percent: 100}, nil //nolint:mnd
+	}
+
+	if value, ok := strings.CutPrefix(s, coldStartPercentPrefix); ok {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err == nil && percent >= 0 && percent <= 100 {
+			return coldStartPolicy{percent: percent}, nil
+		}
+	}
+
+	return coldStartPolicy{}, fmt.Errorf(
+		"[in lambdalocal.parseColdStart] expected '%s' or '%sN' with N between 0 and 100. Got '%s'",
+		coldStartAlways,
+		coldStartPercentPrefix,
+		s,
+	)
+}
+
+func (p coldStartPolicy) enabled() bool {
+	return p.percent > 0
+}
+
+// selects reports whether the next invocation is a cold start.
+func (p coldStartPolicy) selects() bool {
+	return p.percent >= 100 || rand.Float64()*100 < p.percent //nolint:gosec
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColdStart(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value       string
+		expected    coldStartPolicy
+		expectedErr bool
+	}{
+		"always":             {value: "always", expected: coldStartPolicy{percent: 100}},
+		"percent":            {value: "percent=25", expected: coldStartPolicy{percent: 25}},
+		"fractional percent": {value: "percent=0.5", expected: coldStartPolicy{percent: 0.5}},
+		"percent above 100":  {value: "percent=150", expectedErr: true},
+		"negative percent":   {value: "percent=-1", expectedErr: true},
+		"missing percent":    {value: "percent=", expectedErr: true},
+		"unknown policy":     {value: "sometimes", expectedErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				policy, err := parseColdStart(tc.value)
+				if tc.expectedErr {
+					assert.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, policy)
+			},
+		)
+	}
+}
+
+func TestColdStartPolicySelects(t *testing.T) {
+	t.Parallel()
+
+	for range 100 {
+		assert.True(t, coldStartPolicy{percent: 100}.selects())
+		assert.False(t, coldStartPolicy{}.selects())
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	collectionFormatPostman  = "postman"
+	collectionFormatInsomnia = "insomnia"
+
+	postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+	// insomniaExportFormat is the version of the Insomnia export format that is written and read.
+	insomniaExportFormat = 4
+	insomniaWorkspaceID  = "wrk_lambdalocal"
+
+	// collectionBaseURL is the variable holding the address of the local API in collections.
+	collectionBaseURL = "baseUrl"
+)
+
+// collectionParamRegex matches path parameters like {id} and {proxy+}.
+var collectionParamRegex = regexp.MustCompile(`{([^}+]*)\+?}`) //nolint:gochecknoglobals
+
+// collectionVariableRegex matches Postman {{name}} and Insomnia {{ _.name }} variables.
+var collectionVariableRegex = regexp.MustCompile(`{{\s*(?:_\.)?([A-Za-z0-9_.-]+)\s*}}`) //nolint:gochecknoglobals
+
+// collectionRequest is a request of a Postman collection or Insomnia export.
+type collectionRequest struct {
+	name    string
+	method  string
+	url     string
+	headers [][2]string
+	body    string
+}
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// postmanItem is a request or, with Item, a folder of requests.
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Request *postmanRequest `json:"request,omitempty"`
+	Item    []postmanItem   `json:"item,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanHeader `json:"header"`
+	Body   *postmanBody    `json:"body,omitempty"`
+	// URL is a string or an object with the raw URL and its path variables.
+	URL any `json:"url"`
+}
+
+type postmanHeader struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+type postmanBody struct {
+	Mode string `json:"mode"`
+	Raw  string `json:"raw"`
+}
+
+type postmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type insomniaExport struct {
+	Type         string             `json:"_type"`           //nolint:tagliatelle
+	ExportFormat int                `json:"__export_format"` //nolint:tagliatelle
+	ExportSource string             `json:"__export_source"` //nolint:tagliatelle
+	Resources    []insomniaResource `json:"resources"`
+}
+
+// insomniaResource is a workspace, environment, request group or request of an export.
+type insomniaResource struct {
+	ID       string            `json:"_id"`   //nolint:tagliatelle
+	Type     string            `json:"_type"` //nolint:tagliatelle
+	ParentID string            `json:"parentId,omitempty"`
+	Name     string            `json:"name"`
+	Method   string            `json:"method,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Body     *insomniaBody     `json:"body,omitempty"`
+	Headers  []insomniaHeader  `json:"headers,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+type insomniaBody struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type insomniaHeader struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// collectionMethod returns the method of the exported request of a route. Routes that match every
+// method are exported as GET.
+func collectionMethod(route apiRoute) string {
+	if route.method == "" {
+		return http.MethodGet
+	}
+
+	return route.method
+}
+
+// collectionParams returns the path parameters of the routes, used as variables with their name as
+// placeholder value.
+func collectionParams(routes []apiRoute) []string {
+	seen := make(map[string]bool)
+
+	var params []string
+
+	for _, route := range routes {
+		for _, match := range collectionParamRegex.FindAllStringSubmatch(route.path, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				params = append(params, match[1])
+			}
+		}
+	}
+
+	sort.Strings(params)
+
+	return params
+}
+
+// collectionExampleBody returns the body of an API event, used as the example body of the
+// function's requests. Other events return "".
+func collectionExampleBody(event string) string {
+	var apiEvent struct {
+		Body string `json:"body"`
+	}
+
+	if err := json.Unmarshal([]byte(event), &apiEvent); err != nil {
+		return ""
+	}
+
+	return apiEvent.Body
+}
+
+// RunCollectionExport writes the routes as a Postman collection or Insomnia export to w. Requests
+// are grouped by function and use baseURL through the baseUrl variable, path parameters are
+// variables too. examples holds the example body of the functions' requests.
+func RunCollectionExport(
+	w io.Writer,
+	routes []apiRoute,
+	format string,
+	name string,
+	baseURL string,
+	examples map[string]string,
+) error {
+	var collection any
+
+	switch format {
+	case collectionFormatPostman:
+		collection = postmanExport(routes, name, baseURL, examples)
+	case collectionFormatInsomnia:
+		collection = insomniaExportOf(routes, name, baseURL, examples)
+	default:
+		return fmt.Errorf("[in lambdalocal.RunCollectionExport] unknown format '%s'", format)
+	}
+
+	data, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunCollectionExport] marshal collection failed: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(w, string(data))
+
+	return nil
+}
+
+func postmanExport(routes []apiRoute, name, baseURL string, examples map[string]string) postmanCollection {
+	collection := postmanCollection{
+		Info:     postmanInfo{Name: name, Schema: postmanSchema},
+		Variable: []postmanVariable{{Key: collectionBaseURL, Value: baseURL}},
+	}
+
+	for _, param := range collectionParams(routes) {
+		collection.Variable = append(collection.Variable, postmanVariable{Key: param, Value: param})
+	}
+
+	folders := make(map[string]int)
+
+	for _, route := range routes {
+		request := &postmanRequest{
+			Method: collectionMethod(route),
+			Header: []postmanHeader{},
+			URL:    "{{" + collectionBaseURL + "}}" + collectionParamRegex.ReplaceAllString(route.path, "{{$1}}"),
+		}
+
+		if body := examples[route.function]; body != "" && request.Method != http.MethodGet {
+			request.Header = append(request.Header, postmanHeader{Key: "Content-Type", Value: "application/json"})
+			request.Body = &postmanBody{Mode: "raw", Raw: body}
+		}
+
+		folder, ok := folders[route.function]
+		if !ok {
+			folder = len(collection.Item)
+			folders[route.function] = folder
+			collection.Item = append(collection.Item, postmanItem{Name: route.function})
+		}
+
+		collection.Item[folder].Item = append(
+			collection.Item[folder].Item,
+			postmanItem{Name: route.routeKey(), Request: request},
+		)
+	}
+
+	return collection
+}
+
+func insomniaExportOf(routes []apiRoute, name, baseURL string, examples map[string]string) insomniaExport {
+	environment := map[string]string{collectionBaseURL: baseURL}
+	for _, param := range collectionParams(routes) {
+		environment[param] = param
+	}
+
+	export := insomniaExport{
+		Type:         "export",
+		ExportFormat: insomniaExportFormat,
+		ExportSource: "lambdalocal",
+		Resources: []insomniaResource{
+			{ID: insomniaWorkspaceID, Type: "workspace", Name: name},
+			{
+				ID:       "env_lambdalocal",
+				Type:     "environment",
+				ParentID: insomniaWorkspaceID,
+				Name:     "Base Environment",
+				Data:     environment,
+			},
+		},
+	}
+
+	folders := make(map[string]bool)
+
+	for i, route := range routes {
+		folderID := "fld_" + route.function
+		if !folders[route.function] {
+			folders[route.function] = true
+			export.Resources = append(
+				export.Resources,
+				insomniaResource{
+					ID:       folderID,
+					Type:     "request_group",
+					ParentID: insomniaWorkspaceID,
+					Name:     route.function,
+				},
+			)
+		}
+
+		request := insomniaResource{
+			ID:       fmt.Sprintf("req_lambdalocal_%d", i+1),
+			Type:     "request",
+			ParentID: folderID,
+			Name:     route.routeKey(),
+			Method:   collectionMethod(route),
+			URL: "{{ _." + collectionBaseURL + " }}" +
+				collectionParamRegex.ReplaceAllString(route.path, "{{ _.$1 }}"),
+		}
+
+		if body := examples[route.function]; body != "" && request.Method != http.MethodGet {
+			request.Headers = []insomniaHeader{{Name: "Content-Type", Value: "application/json"}}
+			request.Body = &insomniaBody{MimeType: "application/json", Text: body}
+		}
+
+		export.Resources = append(export.Resources, request)
+	}
+
+	return export
+}
+
+// parseCollection reads the requests of a Postman collection or Insomnia export. Variables are
+// replaced with the collection's variables or the export's environments, baseUrl with baseURL.
+func parseCollection(data []byte, baseURL string) ([]collectionRequest, error) {
+	var format struct {
+		Type string `json:"_type"` //nolint:tagliatelle
+	}
+
+	if err := json.Unmarshal(data, &format); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseCollection] unmarshal collection failed: %w", err)
+	}
+
+	var (
+		requests  []collectionRequest
+		variables = make(map[string]string)
+	)
+
+	if format.Type == "export" {
+		var export insomniaExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseCollection] unmarshal insomnia export failed: %w", err)
+		}
+
+		requests = insomniaRequests(export, variables)
+	} else {
+		var collection postmanCollection
+		if err := json.Unmarshal(data, &collection); err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseCollection] unmarshal postman collection failed: %w", err)
+		}
+
+		for _, variable := range collection.Variable {
+			variables[variable.Key] = variable.Value
+		}
+
+		requests = postmanRequests(collection.Item)
+	}
+
+	variables[collectionBaseURL] = baseURL
+
+	replace := func(value string) string {
+		return collectionVariableRegex.ReplaceAllStringFunc(
+			value, func(match string) string {
+				name := collectionVariableRegex.FindStringSubmatch(match)[1]
+				if value, ok := variables[name]; ok {
+					return value
+				}
+
+				return match
+			},
+		)
+	}
+
+	for i, request := range requests {
+		requests[i].url = replace(request.url)
+		requests[i].body = replace(request.body)
+
+		for j, header := range request.headers {
+			requests[i].headers[j][1] = replace(header[1])
+		}
+	}
+
+	return requests, nil
+}
+
+// postmanRequests returns the requests of items in order, folders included.
+func postmanRequests(items []postmanItem) []collectionRequest {
+	var requests []collectionRequest
+
+	for _, item := range items {
+		if item.Request == nil {
+			requests = append(requests, postmanRequests(item.Item)...)
+
+			continue
+		}
+
+		request := collectionRequest{name: item.Name, method: item.Request.Method}
+
+		switch url := item.Request.URL.(type) {
+		case string:
+			request.url = url
+		case map[string]any:
+			request.url, _ = url["raw"].(string)
+		}
+
+		for _, header := range item.Request.Header {
+			if !header.Disabled {
+				request.headers = append(request.headers, [2]string{header.Key, header.Value})
+			}
+		}
+
+		if item.Request.Body != nil && item.Request.Body.Mode == "raw" {
+			request.body = item.Request.Body.Raw
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests
+}
+
+// insomniaRequests returns the requests of an export and adds the data of its environments to
+// variables.
+func insomniaRequests(export insomniaExport, variables map[string]string) []collectionRequest {
+	var requests []collectionRequest
+
+	for _, resource := range export.Resources {
+		switch resource.Type {
+		case "environment":
+			for key, value := range resource.Data {
+				variables[key] = value
+			}
+		case "request":
+			request := collectionRequest{name: resource.Name, method: resource.Method, url: resource.URL}
+
+			for _, header := range resource.Headers {
+				if !header.Disabled {
+					request.headers = append(request.headers, [2]string{header.Name, header.Value})
+				}
+			}
+
+			if resource.Body != nil {
+				request.body = resource.Body.Text
+			}
+
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+// RunCollection sends the requests in order and writes the status and latency of each to w. It
+// fails when a request can't be sent or a response has a 5xx status.
+func RunCollection(ctx context.Context, w io.Writer, client *http.Client, requests []collectionRequest) error {
+	var failed int
+
+	for _, request := range requests {
+		req, err := http.NewRequestWithContext(ctx, request.method, request.url, strings.NewReader(request.body))
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunCollection] create request '%s' failed: %w", request.name, err)
+		}
+
+		for _, header := range request.headers {
+			req.Header.Add(header[0], header[1])
+		}
+
+		start := time.Now()
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunCollection] request '%s' failed: %w", request.name, err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			failed++
+		}
+
+		_, _ = fmt.Fprintf(
+			w,
+			"%d %s %s (%s) %s\n",
+			resp.StatusCode,
+			request.method,
+			request.url,
+			request.name,
+			time.Since(start).Round(time.Millisecond),
+		)
+	}
+
+	_, _ = fmt.Fprintf(w, "%d requests, %d failed\n", len(requests), failed)
+
+	if failed > 0 {
+		return fmt.Errorf("[in lambdalocal.RunCollection] %d of %d requests failed", failed, len(requests))
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionExportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	routes := []apiRoute{
+		{method: "GET", path: "/hello", function: "HelloFn"},
+		{method: "POST", path: "/orders", function: "OrderFn"},
+		{method: "", path: "/orders/{id}", function: "OrderFn"},
+	}
+	examples := map[string]string{"HelloFn": `{"ignored":true}`, "OrderFn": `{"item":"book"}`}
+
+	for _, format := range []string{collectionFormatPostman, collectionFormatInsomnia} {
+		t.Run(
+			format, func(t *testing.T) {
+				t.Parallel()
+
+				var out bytes.Buffer
+
+				err := RunCollectionExport(&out, routes, format, "orders", "http://localhost:8080", examples)
+				require.NoError(t, err)
+
+				requests, err := parseCollection(out.Bytes(), "http://localhost:9090")
+				require.NoError(t, err)
+
+				assert.Equal(
+					t,
+					[]collectionRequest{
+						{name: "GET /hello", method: "GET", url: "http://localhost:9090/hello"},
+						{
+							name:    "POST /orders",
+							method:  "POST",
+							url:     "http://localhost:9090/orders",
+							headers: [][2]string{{"Content-Type", "application/json"}},
+							body:    `{"item":"book"}`,
+						},
+						{name: "ANY /orders/{id}", method: "GET", url: "http://localhost:9090/orders/id"},
+					},
+					requests,
+				)
+			},
+		)
+	}
+}
+
+func TestRunCollectionExportUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	err := RunCollectionExport(io.Discard, nil, "har", "orders", "http://localhost:8080", nil)
+	assert.ErrorContains(t, err, "[in lambdalocal.RunCollectionExport] unknown format 'har'")
+}
+
+func TestParseCollection(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		collection       string
+		expectedRequests []collectionRequest
+		expectedErrStr   string
+	}{
+		"postman url object and disabled header": {
+			collection: `{
+				"info": {"name": "api"},
+				"variable": [{"key": "token", "value": "abc"}],
+				"item": [{
+					"name": "Get user",
+					"request": {
+						"method": "GET",
+						"header": [
+							{"key": "Authorization", "value": "Bearer {{token}}"},
+							{"key": "X-Debug", "value": "1", "disabled": true}
+						],
+						"url": {"raw": "{{baseUrl}}/users/{{missing}}", "host": ["{{baseUrl}}"]}
+					}
+				}]
+			}`,
+			expectedRequests: []collectionRequest{
+				{
+					name:    "Get user",
+					method:  "GET",
+					url:     "http://localhost:8080/users/{{missing}}",
+					headers: [][2]string{{"Authorization", "Bearer abc"}},
+				},
+			},
+		},
+		"insomnia environment": {
+			collection: `{
+				"_type": "export",
+				"resources": [
+					{"_id": "env", "_type": "environment", "data": {"baseUrl": "https://prod.example.com", "id": "7"}},
+					{
+						"_id": "req", "_type": "request", "name": "Order", "method": "DELETE",
+						"url": "{{ _.baseUrl }}/orders/{{ _.id }}"
+					}
+				]
+			}`,
+			expectedRequests: []collectionRequest{
+				{name: "Order", method: "DELETE", url: "http://localhost:8080/orders/7"},
+			},
+		},
+		"invalid json": {
+			collection:     `{`,
+			expectedErrStr: "[in lambdalocal.parseCollection] unmarshal collection failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				requests, err := parseCollection([]byte(tc.collection), "http://localhost:8080")
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedRequests, requests)
+			},
+		)
+	}
+}
+
+func TestRunCollection(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				switch {
+				case r.URL.Path == "/fail":
+					w.WriteHeader(http.StatusBadGateway)
+				case string(body) != "" && r.Header.Get("Content-Type") != "application/json":
+					w.WriteHeader(http.StatusBadRequest)
+				default:
+					w.WriteHeader(http.StatusOK)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	var out bytes.Buffer
+
+	err := RunCollection(
+		context.Background(),
+		&out,
+		server.Client(),
+		[]collectionRequest{
+			{
+				name:    "create",
+				method:  "POST",
+				url:     server.URL + "/orders",
+				headers: [][2]string{{"Content-Type", "application/json"}},
+				body:    `{}`,
+			},
+			{name: "fail", method: "GET", url: server.URL + "/fail"},
+		},
+	)
+	require.ErrorContains(t, err, "[in lambdalocal.RunCollection] 1 of 2 requests failed")
+
+	assert.Contains(t, out.String(), "200 POST "+server.URL+"/orders (create)")
+	assert.Contains(t, out.String(), "502 GET "+server.URL+"/fail (fail)")
+	assert.Contains(t, out.String(), "2 requests, 1 failed\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// errFunctionThrottled is returned for invocations of functions already running as many
+// invocations as their reserved concurrency.
+var errFunctionThrottled = errors.New("reserved concurrency exceeded")
+
+// lambdaThrottledMessage is the message Lambda throttles invocations with.
+const lambdaThrottledMessage = "Rate Exceeded."
+
+// reservedConcurrency caps the invocations each function runs at once, like the reserved
+// concurrency of deployed functions. Invocations beyond the cap are throttled instead of waiting,
+// so the retries and backoff of clients can be tested locally.
+type reservedConcurrency struct {
+	limit  int
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// active counts the running invocations of each function.
+	active map[string]int
+}
+
+// newReservedConcurrency returns the reserved concurrency of limit invocations per function.
+func newReservedConcurrency(limit int, logger *slog.Logger) *reservedConcurrency {
+	return &reservedConcurrency{limit: limit, logger: logger, active: make(map[string]int)}
+}
+
+// caller returns a caller that throttles the invocations of function by caller beyond the limit.
+// The invocations of every caller of function count towards the same limit.
+func (c *reservedConcurrency) caller(caller lambdaCaller, function string) lambdaCaller {
+	if c == nil {
+		return caller
+	}
+
+	return concurrencyCaller{lambdaCaller: caller, concurrency: c, function: function}
+}
+
+// acquire reserves one of the invocations of function, it reports false when all are running.
+func (c *reservedConcurrency) acquire(function string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active[function] >= c.limit {
+		return false
+	}
+
+	c.active[function]++
+
+	return true
+}
+
+func (c *reservedConcurrency) release(function string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active[function]--; c.active[function] <= 0 {
+		delete(c.active, function)
+	}
+}
+
+type concurrencyCaller struct {
+	lambdaCaller
+	concurrency *reservedConcurrency
+	function    string
+}
+
+func (c concurrencyCaller) Invoke(
+	ctx context.Context,
+	data []byte,
+	options ...Option,
+) (messages.InvokeResponse, error) {
+	if !c.concurrency.acquire(c.function) {
+		c.concurrency.logger.Warn(
+			"Function is running its reserved concurrency, throttling the invocation",
+			"function", c.function,
+			"reservedConcurrency", c.concurrency.limit,
+		)
+
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.concurrencyCaller.Invoke] %w: %d invocations are running",
+			errFunctionThrottled,
+			c.concurrency.limit,
+		)
+	}
+
+	defer c.concurrency.release(c.function)
+
+	return c.lambdaCaller.Invoke(ctx, data, options...) //nolint:wrapcheck
+}
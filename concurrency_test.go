@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservedConcurrency(t *testing.T) {
+	t.Parallel()
+
+	concurrency := newReservedConcurrency(1, slog.New(slog.DiscardHandler))
+
+	lambda := newBlockingLambdaCaller()
+	orders := concurrency.caller(lambda, "orders")
+
+	done := make(chan error)
+
+	go func() {
+		_, err := orders.Invoke(context.Background(), []byte(`{}`))
+		done <- err
+	}()
+
+	<-lambda.started
+
+	// the invocations of every caller of the function share its reserved concurrency
+	_, err := concurrency.caller(lambda, "orders").Invoke(context.Background(), []byte(`{}`))
+	require.ErrorIs(t, err, errFunctionThrottled)
+
+	// other functions have their own
+	go func() {
+		_, err := concurrency.caller(lambda, "payments").Invoke(context.Background(), []byte(`{}`))
+		done <- err
+	}()
+
+	<-lambda.started
+	close(lambda.release)
+
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	// the invocations finished, the function is invoked again
+	_, err = orders.Invoke(context.Background(), []byte(`{}`))
+	assert.NoError(t, err)
+}
+
+func TestReservedConcurrencyNil(t *testing.T) {
+	t.Parallel()
+
+	lambda := newBlockingLambdaCaller()
+
+	var concurrency *reservedConcurrency
+
+	assert.Equal(t, lambdaCaller(lambda), concurrency.caller(lambda, "orders"))
+}
+
+func TestReservedConcurrencyThrottling(t *testing.T) {
+	t.Parallel()
+
+	concurrency := newReservedConcurrency(1, slog.New(slog.DiscardHandler))
+	lambda := newBlockingLambdaCaller()
+
+	route := apiRoute{method: http.MethodGet, path: "/orders", function: "orders", payloadFormat: payloadFormatV2}
+
+	gateway := gatewayHandler(concurrency.caller(lambda, "orders"), responseFormat{}, route, nil, slog.Default())
+
+	lambdaAPI := newLambdaAPIHandler(lambda, nil, []apiRoute{route}, nil, responseFormat{}, slog.Default())
+	lambdaAPI.concurrency = concurrency
+
+	router := http.NewServeMux()
+	router.Handle("POST "+lambdaInvokePath, lambdaAPI)
+
+	done := make(chan int)
+
+	go func() {
+		rr := httptest.NewRecorder()
+		gateway.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		done <- rr.Code
+	}()
+
+	<-lambda.started
+
+	rr := httptest.NewRecorder()
+	gateway.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.JSONEq(t, `{"message":"Too Many Requests"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(
+		rr,
+		httptest.NewRequest(http.MethodPost, "/2015-03-31/functions/orders/invocations", strings.NewReader(`{}`)),
+	)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "TooManyRequestsException", rr.Header().Get("X-Amzn-Errortype"))
+	assert.JSONEq(t, `{"Type":"User","message":"Rate Exceeded."}`, rr.Body.String())
+
+	close(lambda.release)
+	assert.Equal(t, http.StatusOK, <-done)
+}
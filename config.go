@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigPath = "./lambdalocal.yaml"
+
+// projectConfig is the format of the lambdalocal.yaml project file.
+type projectConfig struct {
+	// Functions holds per function settings keyed by the function's logical ID in the template.
+	Functions map[string]functionConfig `yaml:"functions"`
+	// StatsFile opts in to recording local usage stats in this file.
+	StatsFile string `yaml:"statsFile"`
+	// Store is where the data of lambdalocal, like the stats file, is kept: a directory,
+	// sqlite://PATH or s3://BUCKET/PREFIX.
+	Store string `yaml:"store"`
+	// LatencyBudgets are the maximum invocation latencies of routes, keyed like "GET /users".
+	LatencyBudgets map[string]time.Duration `yaml:"latencyBudgets"`
+	// Plugins are paths, relative to the config, of WASM plugins that transform events and responses.
+	Plugins []string `yaml:"plugins"`
+	// Hooks are paths, relative to the config, of Starlark scripts that hook into invocations.
+	Hooks []string `yaml:"hooks"`
+	// Mocks are routes answered with a templated response instead of a lambda, keyed like
+	// "GET /users/{id}".
+	Mocks map[string]mockResponse `yaml:"mocks"`
+	// Routing are the rules that send requests of a route to other targets, keyed like mocks.
+	Routing map[string][]routingRule `yaml:"routing"`
+	// Sticky keeps the requests of a client session on one routing target.
+	Sticky stickySession `yaml:"sticky"`
+}
+
+type functionConfig struct {
+	// Address is the address of the locally running lambda for this function.
+	Address string `yaml:"address"`
+	// WarmupEvent is the path, relative to the config, of the event the function is invoked with by
+	// --warmup.
+	WarmupEvent string `yaml:"warmupEvent"`
+}
+
+// loadProjectConfig reads the project config at configPath. A missing file results in an empty
+// config.
+func loadProjectConfig(configPath string, reader fileReader) (projectConfig, error) {
+	data, err := reader.read(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return projectConfig{}, nil
+	}
+
+	if err != nil {
+		return projectConfig{}, fmt.Errorf("[in lambdalocal.loadProjectConfig] read file failed: %w", err)
+	}
+
+	config := projectConfig{}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return projectConfig{}, fmt.Errorf("[in lambdalocal.loadProjectConfig] unmarshal yaml failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// functionAddress returns the address configured for function, or fallback when there is none.
+func (c projectConfig) functionAddress(function, fallback string) string {
+	if address := c.Functions[function].Address; address != "" {
+		return address
+	}
+
+	return fallback
+}
+
+// functionAddresses returns the configured address of every function that has one.
+func (c projectConfig) functionAddresses() map[string]string {
+	addresses := make(map[string]string, len(c.Functions))
+
+	for function, functionConfig := range c.Functions {
+		if functionConfig.Address != "" {
+			addresses[function] = functionConfig.Address
+		}
+	}
+
+	return addresses
+}
+
+// statsFile returns the stats file set by flag, or the one from the config. Stats are only
+// recorded when one of them is set.
+func (c projectConfig) statsFile(flag string) string {
+	if flag != "" {
+		return flag
+	}
+
+	return c.StatsFile
+}
+
+// store returns the store location set by flag, or the one from the config.
+func (c projectConfig) store(flag string) string {
+	if flag != "" {
+		return flag
+	}
+
+	return c.Store
+}
+
+// warmupEvents reads the warmup event of every function that has one. Paths are relative to the
+// config at configPath.
+func (c projectConfig) warmupEvents(configPath string, reader fileReader) (map[string]string, error) {
+	events := make(map[string]string)
+
+	for function, functionConfig := range c.Functions {
+		if functionConfig.WarmupEvent == "" {
+			continue
+		}
+
+		event, err := reader.read(filepath.Join(filepath.Dir(configPath), functionConfig.WarmupEvent))
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.warmupEvents] read warmup event of %s failed: %w", function, err)
+		}
+
+		events[function] = string(event)
+	}
+
+	return events, nil
+}
+
+// pluginPaths returns the plugins of the config, relative to the config at configPath, followed
+// by the plugins set by flag.
+func (c projectConfig) pluginPaths(configPath string, flag []string) []string {
+	paths := make([]string, 0, len(c.Plugins)+len(flag))
+
+	for _, plugin := range c.Plugins {
+		paths = append(paths, filepath.Join(filepath.Dir(configPath), plugin))
+	}
+
+	return append(paths, flag...)
+}
+
+// hookPaths returns the hook scripts of the config, relative to the config at configPath.
+func (c projectConfig) hookPaths(configPath string) []string {
+	paths := make([]string, 0, len(c.Hooks))
+
+	for _, hook := range c.Hooks {
+		paths = append(paths, filepath.Join(filepath.Dir(configPath), hook))
+	}
+
+	return paths
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mockReturn     []any
+		expectedConfig projectConfig
+		expectedErrStr string
+	}{
+		"valid config": {
+			mockReturn: []any{
+				[]byte(`
+functions:
+  OrderFn:
+    address: localhost:8002
+                `),
+				nil,
+			},
+			expectedConfig: projectConfig{
+				Functions: map[string]functionConfig{
+					"OrderFn": {Address: "localhost:8002"},
+				},
+			},
+		},
+		"latency budgets": {
+			mockReturn: []any{
+				[]byte(`
+latencyBudgets:
+  GET /users: 200ms
+  POST /orders: 1.5s
+                `),
+				nil,
+			},
+			expectedConfig: projectConfig{
+				LatencyBudgets: map[string]time.Duration{
+					"GET /users":   200 * time.Millisecond,
+					"POST /orders": 1500 * time.Millisecond,
+				},
+			},
+		},
+		"invalid latency budget": {
+			mockReturn:     []any{[]byte("latencyBudgets:\n  GET /users: fast\n"), nil},
+			expectedErrStr: "[in lambdalocal.loadProjectConfig] unmarshal yaml failed:",
+		},
+		"missing file": {
+			mockReturn:     []any{[]byte{}, fs.ErrNotExist},
+			expectedConfig: projectConfig{},
+		},
+		"file read error": {
+			mockReturn:     []any{[]byte{}, errors.New("test error")},
+			expectedErrStr: "[in lambdalocal.loadProjectConfig] read file failed:",
+		},
+		"unmarshal error": {
+			mockReturn:     []any{[]byte(`functions: [`), nil},
+			expectedErrStr: "[in lambdalocal.loadProjectConfig] unmarshal yaml failed:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "lambdalocal.yaml").Return(tc.mockReturn...).Once()
+
+				config, err := loadProjectConfig("lambdalocal.yaml", mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedConfig, config)
+				}
+			},
+		)
+	}
+}
+
+func TestProjectConfigFunctionAddress(t *testing.T) {
+	t.Parallel()
+
+	config := projectConfig{
+		Functions: map[string]functionConfig{
+			"OrderFn": {Address: "localhost:8002"},
+			"HelloFn": {},
+		},
+	}
+
+	assert.Equal(t, "localhost:8002", config.functionAddress("OrderFn", "localhost:8000"))
+	assert.Equal(t, "localhost:8000", config.functionAddress("HelloFn", "localhost:8000"))
+	assert.Equal(t, "localhost:8000", config.functionAddress("MissingFn", "localhost:8000"))
+}
+
+func TestProjectConfigFunctionAddresses(t *testing.T) {
+	t.Parallel()
+
+	config := projectConfig{
+		Functions: map[string]functionConfig{
+			"OrderFn": {Address: "localhost:8002"},
+			"HelloFn": {},
+		},
+	}
+
+	assert.Equal(t, map[string]string{"OrderFn": "localhost:8002"}, config.functionAddresses())
+	assert.Equal(t, map[string]string{}, projectConfig{}.functionAddresses())
+}
+
+func TestProjectConfigWarmupEvents(t *testing.T) {
+	t.Parallel()
+
+	config := projectConfig{
+		Functions: map[string]functionConfig{
+			"OrderFn": {WarmupEvent: "events/warmup.json"},
+			"HelloFn": {},
+		},
+	}
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "project/events/warmup.json").Return([]byte(`{"warmup":true}`), nil).Once()
+
+	events, err := config.warmupEvents("project/lambdalocal.yaml", mockReader)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"OrderFn": `{"warmup":true}`}, events)
+	mockReader.AssertExpectations(t)
+
+	mockReader = new(mockOSFileReader)
+	mockReader.On("read", "events/warmup.json").Return([]byte{}, errors.New("test error")).Once()
+
+	_, err = config.warmupEvents("lambdalocal.yaml", mockReader)
+
+	assert.ErrorContains(t, err, "[in lambdalocal.warmupEvents] read warmup event of OrderFn failed:")
+}
+
+func TestProjectConfigPluginPaths(t *testing.T) {
+	t.Parallel()
+
+	config := projectConfig{Plugins: []string{"plugins/tenant.wasm"}}
+
+	assert.Equal(
+		t,
+		[]string{"project/plugins/tenant.wasm", "shim.wasm"},
+		config.pluginPaths("project/lambdalocal.yaml", []string{"shim.wasm"}),
+	)
+	assert.Empty(t, projectConfig{}.pluginPaths("lambdalocal.yaml", nil))
+}
+
+func TestProjectConfigHookPaths(t *testing.T) {
+	t.Parallel()
+
+	config := projectConfig{Hooks: []string{"hooks/mocks.star"}}
+
+	assert.Equal(t, []string{"project/hooks/mocks.star"}, config.hookPaths("project/lambdalocal.yaml"))
+	assert.Empty(t, projectConfig{}.hookPaths("lambdalocal.yaml"))
+}
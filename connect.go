@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	// connectInitialBackoff is the delay before the first retry of a refused connection.
+	connectInitialBackoff = 50 * time.Millisecond
+	// connectMaxBackoff caps the doubling delay between retries.
+	connectMaxBackoff = time.Second
+)
+
+// dialLambda connects to the lambda served over RPC on address. While the lambda refuses
+// connections, like when its process is still starting, the dial is retried with exponential
+// backoff for up to wait. Other errors, and refused connections without wait, fail right away.
+func dialLambda(ctx context.Context, address string, wait time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(wait)
+	backoff := connectInitialBackoff
+
+	for {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn, nil
+		}
+
+		remaining := time.Until(deadline)
+		if !errors.Is(err, syscall.ECONNREFUSED) || remaining <= 0 {
+			return nil, err //nolint:wrapcheck
+		}
+
+		timer := time.NewTimer(min(backoff, remaining))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff = min(2*backoff, connectMaxBackoff) //nolint:mnd
+	}
+}
+
+// waitForLambda is the pre-flight check of --wait: it blocks until the lambda on address accepts
+// connections, or wait passes.
+func waitForLambda(ctx context.Context, address string, wait time.Duration, logger *slog.Logger) error {
+	logger.Info(fmt.Sprintf("Waiting up to %s for the lambda on %s", wait, address))
+
+	conn, err := dialLambda(ctx, address, wait)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.waitForLambda] lambda on '%s' not reachable: %w", address, err)
+	}
+
+	_ = conn.Close()
+
+	logger.Info("Lambda is accepting connections on " + address)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddress returns an address nothing listens on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	return address
+}
+
+func TestDialLambda(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		wait        time.Duration
+		listenAfter time.Duration
+		expectError bool
+	}{
+		"lambda starts within the wait": {
+			wait:        5 * time.Second,
+			listenAfter: 200 * time.Millisecond,
+		},
+		"refused without wait": {
+			expectError: true,
+		},
+		"lambda doesn't start within the wait": {
+			wait:        200 * time.Millisecond,
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				address := freeAddress(t)
+
+				if tc.listenAfter > 0 {
+					time.AfterFunc(tc.listenAfter, func() {
+						listener, err := net.Listen("tcp", address)
+						if err != nil {
+							return
+						}
+
+						t.Cleanup(func() { _ = listener.Close() })
+
+						go func() {
+							conn, err := listener.Accept()
+							if err == nil {
+								_ = conn.Close()
+							}
+						}()
+					})
+				}
+
+				start := time.Now()
+				conn, err := dialLambda(context.Background(), address, tc.wait)
+
+				if tc.expectError {
+					require.ErrorIs(t, err, syscall.ECONNREFUSED)
+					assert.GreaterOrEqual(t, time.Since(start), tc.wait)
+
+					return
+				}
+
+				require.NoError(t, err)
+				_ = conn.Close()
+			},
+		)
+	}
+}
@@ -0,0 +1,34 @@
+// Package contextenv reads per invocation environment overrides passed by lambdalocal.
+//
+// Values given to lambdalocal with `--context-env KEY=VALUE` are sent in the custom map of the
+// invocation's client context. Handlers can use Getenv in place of os.Getenv so those values
+// toggle behavior during local testing without restarting the handler. Deployed functions have no
+// overrides and fall back to the process environment.
+package contextenv
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// Lookup returns the override for key set on the invocation in ctx, falling back to the process
+// environment. The boolean reports whether the key was found in either.
+func Lookup(ctx context.Context, key string) (string, bool) {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		if value, ok := lc.ClientContext.Custom[key]; ok {
+			return value, true
+		}
+	}
+
+	return os.LookupEnv(key)
+}
+
+// Getenv returns the override for key set on the invocation in ctx, falling back to
+// os.Getenv(key).
+func Getenv(ctx context.Context, key string) string {
+	value, _ := Lookup(ctx, key)
+
+	return value
+}
@@ -0,0 +1,57 @@
+package contextenv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	t.Setenv("CONTEXTENV_TEST_FLAG", "from-env")
+
+	lambdaCtx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		ClientContext: lambdacontext.ClientContext{
+			Custom: map[string]string{"CONTEXTENV_TEST_FLAG": "from-context"},
+		},
+	})
+
+	tests := map[string]struct {
+		ctx           context.Context //nolint:containedctx
+		key           string
+		expectedValue string
+		expectedOK    bool
+	}{
+		"override from client context": {
+			ctx:           lambdaCtx,
+			key:           "CONTEXTENV_TEST_FLAG",
+			expectedValue: "from-context",
+			expectedOK:    true,
+		},
+		"fallback to environment": {
+			ctx:           context.Background(),
+			key:           "CONTEXTENV_TEST_FLAG",
+			expectedValue: "from-env",
+			expectedOK:    true,
+		},
+		"missing key": {
+			ctx:           lambdaCtx,
+			key:           "CONTEXTENV_TEST_MISSING",
+			expectedValue: "",
+			expectedOK:    false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				value, ok := Lookup(tc.ctx, tc.key)
+
+				assert.Equal(t, tc.expectedValue, value)
+				assert.Equal(t, tc.expectedOK, ok)
+				assert.Equal(t, tc.expectedValue, Getenv(tc.ctx, tc.key))
+			},
+		)
+	}
+}
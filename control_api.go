@@ -0,0 +1,502 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+const (
+	// controlAPIPrefix is the prefix of the versioned control API served next to the template routes.
+	controlAPIPrefix = "/__lambdalocal/v1"
+	// controlAPIDefaultLimit is the page size of list endpoints without a limit parameter.
+	controlAPIDefaultLimit = 50
+	// controlAPIMaxLimit caps the limit parameter of list endpoints.
+	controlAPIMaxLimit = 500
+	// defaultHistorySize is the number of invocations kept for the control API by default.
+	defaultHistorySize = 1000
+	// defaultHistoryMaxSize is the size in MB of the invocations kept for the control API by default.
+	defaultHistoryMaxSize = 64
+
+	invocationStatusSuccess = "success"
+	// invocationStatusError is an invocation whose handler returned an error.
+	invocationStatusError = "error"
+	// invocationStatusFailed is an invocation that didn't reach the handler or got no response.
+	invocationStatusFailed = "failed"
+)
+
+// invocationRecord is an invocation of a route in the history, the schema of the items of
+// GET /__lambdalocal/v1/invocations.
+type invocationRecord struct {
+	ID           string    `json:"id"`
+	Sequence     uint64    `json:"sequence"`
+	Route        string    `json:"route"`
+	Function     string    `json:"function"`
+	StartedAt    time.Time `json:"startedAt"`
+	DurationMs   float64   `json:"durationMs"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"statusCode,omitempty"`
+	ErrorType    string    `json:"errorType,omitempty"`
+	ErrorMessage string    `json:"errorMessage,omitempty"`
+	RequestSize  int       `json:"requestSize"`
+	ResponseSize int       `json:"responseSize"`
+	// PayloadsOmitted is set when the payloads were larger than the history keeps.
+	PayloadsOmitted bool `json:"payloadsOmitted,omitempty"`
+	// key is the key of the invocation in the store of the history, size the size of its entry.
+	key  string
+	size int64
+}
+
+// invocationDetail is the schema of GET /__lambdalocal/v1/invocations/{id}, and of the entries of
+// the invocations in the store of the history.
+type invocationDetail struct {
+	invocationRecord
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// invocationHistory keeps the last invocations of the routes for the control API. The invocations
+// and their payloads are kept in a store, in memory unless they are recorded, and only their
+// records are held to list them.
+type invocationHistory struct {
+	mu sync.Mutex
+	// size is the number of invocations kept, maxBytes the size of their entries in the store.
+	size     int
+	maxBytes int64
+	store    store
+	// recorded keeps the invocations dropped from the history in the store, they are recordings.
+	recorded bool
+	// records are the invocations in the history, oldest first, and bytes the size of their entries.
+	records  []invocationRecord
+	bytes    int64
+	sequence uint64
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+// newInvocationHistory keeps the last size invocations in s, none when size is 0, dropping the
+// oldest ones once their entries exceed maxBytes.
+func newInvocationHistory(size int, maxBytes int64, s store, logger *slog.Logger) *invocationHistory {
+	return &invocationHistory{size: size, maxBytes: maxBytes, store: s, now: time.Now, logger: logger}
+}
+
+// add keeps an invocation with its payloads, dropping the oldest ones once the history is full.
+// Payloads that don't fit in maxBytes aren't kept.
+func (h *invocationHistory) add(record invocationRecord, request, response []byte) {
+	if h.size <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	record.Sequence = h.sequence
+	record.key = recordingKey(record.ID, record.StartedAt)
+
+	detail := invocationDetail{invocationRecord: record}
+	if int64(len(request)+len(response)) > h.maxBytes {
+		detail.PayloadsOmitted = true
+	} else {
+		detail.Request = asyncPayload(request)
+		if len(response) > 0 {
+			detail.Response = asyncPayload(response)
+		}
+	}
+
+	data, err := json.Marshal(detail)
+	if err == nil {
+		err = h.store.write(record.key, data)
+	}
+
+	// a history that can't be written never fails the invocation
+	if err != nil {
+		h.logger.Warn("[in lambdalocal.invocationHistory.add] write failed", "err", err)
+
+		return
+	}
+
+	record.PayloadsOmitted, record.size = detail.PayloadsOmitted, int64(len(data))
+	h.keep(record)
+}
+
+// keep adds record to the records, dropping the oldest ones until it fits.
+func (h *invocationHistory) keep(record invocationRecord) {
+	for len(h.records) > 0 && (len(h.records) >= h.size || h.bytes+record.size > h.maxBytes) {
+		oldest := h.records[0]
+		h.records = slices.Delete(h.records, 0, 1)
+		h.bytes -= oldest.size
+
+		if h.recorded {
+			continue
+		}
+
+		if err := h.store.delete(oldest.key); err != nil {
+			h.logger.Warn("[in lambdalocal.invocationHistory.keep] delete failed", "err", err)
+		}
+	}
+
+	h.records = append(h.records, record)
+	h.bytes += record.size
+}
+
+// load adds the last invocations recorded in the store to the history, so recordings of earlier
+// runs are listed too.
+func (h *invocationHistory) load() error {
+	if h.size <= 0 {
+		return nil
+	}
+
+	entries, err := h.store.list(recordingsPrefix)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.invocationHistory.load] list failed: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, entry := range entries[max(len(entries)-h.size, 0):] {
+		detail, err := h.read(entry.key)
+		if err != nil {
+			h.logger.Debug("Skipping recorded invocation", "key", entry.key, "err", err)
+
+			continue
+		}
+
+		h.sequence++
+		detail.Sequence, detail.key, detail.size = h.sequence, entry.key, entry.size
+		h.keep(detail.invocationRecord)
+	}
+
+	return nil
+}
+
+func (h *invocationHistory) read(key string) (invocationDetail, error) {
+	data, err := h.store.read(key)
+	if err != nil {
+		return invocationDetail{}, err //nolint:wrapcheck
+	}
+
+	var detail invocationDetail
+	if err = json.Unmarshal(data, &detail); err != nil {
+		return invocationDetail{}, fmt.Errorf("unmarshal invocation failed: %w", err)
+	}
+
+	return detail, nil
+}
+
+// invocationFilter selects the invocations of a list request, empty fields match every invocation.
+type invocationFilter struct {
+	route    string
+	function string
+	status   string
+	// before only matches invocations with a lower sequence, it is the cursor of the page.
+	before uint64
+}
+
+func (f invocationFilter) match(record invocationRecord) bool {
+	return (f.route == "" || record.Route == f.route) &&
+		(f.function == "" || record.Function == f.function) &&
+		(f.status == "" || record.Status == f.status) &&
+		(f.before == 0 || record.Sequence < f.before)
+}
+
+// list returns up to limit invocations matching filter, newest first, and whether more match.
+func (h *invocationHistory) list(filter invocationFilter, limit int) ([]invocationRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := make([]invocationRecord, 0, min(limit, len(h.records)))
+
+	for _, record := range slices.Backward(h.records) {
+		if !filter.match(record) {
+			continue
+		}
+
+		if len(records) == limit {
+			return records, true
+		}
+
+		records = append(records, record)
+	}
+
+	return records, false
+}
+
+// get returns the invocation with the request id id and its payloads.
+func (h *invocationHistory) get(id string) (invocationDetail, bool, error) {
+	h.mu.Lock()
+	index := slices.IndexFunc(
+		h.records, func(record invocationRecord) bool {
+			return record.ID == id
+		},
+	)
+
+	if index < 0 {
+		h.mu.Unlock()
+
+		return invocationDetail{}, false, nil
+	}
+
+	record := h.records[index]
+	h.mu.Unlock()
+
+	detail, err := h.read(record.key)
+	if err != nil {
+		return invocationDetail{}, true, fmt.Errorf("[in lambdalocal.invocationHistory.get] %w", err)
+	}
+
+	// the sequence is the one of this run, recordings of earlier runs got a new one
+	detail.invocationRecord = record
+
+	return detail, true, nil
+}
+
+// caller returns a caller that records the invocations of caller for route.
+func (h *invocationHistory) caller(caller lambdaCaller, route apiRoute) lambdaCaller {
+	if h.size <= 0 {
+		return caller
+	}
+
+	return historyCaller{lambdaCaller: caller, history: h, route: route.routeKey(), function: route.function}
+}
+
+type historyCaller struct {
+	lambdaCaller
+	history  *invocationHistory
+	route    string
+	function string
+}
+
+func (c historyCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	start := c.history.now()
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+
+	record := invocationRecord{
+		ID:           newInvokeOptions(options).requestID,
+		Route:        c.route,
+		Function:     c.function,
+		StartedAt:    start.UTC(),
+		DurationMs:   float64(c.history.now().Sub(start)) / float64(time.Millisecond),
+		Status:       invocationStatusSuccess,
+		RequestSize:  len(data),
+		ResponseSize: len(response.Payload),
+	}
+
+	switch {
+	case err != nil:
+		record.Status, record.ErrorMessage = invocationStatusFailed, err.Error()
+	case response.Error != nil:
+		record.Status = invocationStatusError
+		record.ErrorType, record.ErrorMessage = response.Error.Type, response.Error.Message
+	default:
+		var shape struct {
+			StatusCode int `json:"statusCode"`
+		}
+
+		if json.Unmarshal(response.Payload, &shape) == nil {
+			record.StatusCode = shape.StatusCode
+		}
+	}
+
+	c.history.add(record, data, response.Payload)
+
+	return response, err //nolint:wrapcheck
+}
+
+// controlRoute is a route of the server, the schema of the items of GET /__lambdalocal/v1/routes.
+type controlRoute struct {
+	Route         string `json:"route"`
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Function      string `json:"function,omitempty"`
+	PayloadFormat string `json:"payloadFormat,omitempty"`
+	Authorizer    string `json:"authorizer,omitempty"`
+	Mock          bool   `json:"mock"`
+}
+
+func newControlRoute(route apiRoute, mock bool) controlRoute {
+	result := controlRoute{
+		Route:         route.routeKey(),
+		Method:        cmp.Or(route.method, anyMethod),
+		Path:          route.path,
+		Function:      route.function,
+		PayloadFormat: route.payloadFormat,
+		Mock:          mock,
+	}
+
+	if route.authorizer != nil {
+		result.Authorizer = route.authorizer.name
+	}
+
+	return result
+}
+
+// controlPage is the schema of the responses of list endpoints. nextCursor is passed as the cursor
+// parameter to get the next page, it is left out on the last page.
+type controlPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// controlError is the schema of the errors of the control API.
+type controlError struct {
+	Error controlErrorBody `json:"error"`
+}
+
+type controlErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// controlAPI serves the versioned control API: the invocation history, the routes and the metrics
+// of the server, for tooling like dashboards and CI scripts.
+type controlAPI struct {
+	history *invocationHistory
+	routes  []controlRoute
+	metrics *connMetrics
+}
+
+// register adds the endpoints of the control API to router.
+func (c *controlAPI) register(router *http.ServeMux) {
+	router.HandleFunc("GET "+controlAPIPrefix+"/invocations", c.handleInvocations)
+	router.HandleFunc("GET "+controlAPIPrefix+"/invocations/{id}", c.handleInvocation)
+	router.HandleFunc("GET "+controlAPIPrefix+"/routes", c.handleRoutes)
+	router.Handle("GET "+controlAPIPrefix+"/metrics", c.metrics)
+}
+
+func (c *controlAPI) handleInvocations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, err := controlLimit(query.Get("limit"))
+	if err != nil {
+		writeControlError(w, http.StatusBadRequest, "InvalidParameter", err.Error())
+
+		return
+	}
+
+	filter := invocationFilter{
+		route:    query.Get("route"),
+		function: query.Get("function"),
+		status:   query.Get("status"),
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.before, err = strconv.ParseUint(cursor, 10, 64); err != nil || filter.before == 0 {
+			writeControlError(w, http.StatusBadRequest, "InvalidParameter", "invalid cursor '"+cursor+"'")
+
+			return
+		}
+	}
+
+	records, more := c.history.list(filter, limit)
+
+	page := controlPage[invocationRecord]{Items: records}
+	if more {
+		page.NextCursor = strconv.FormatUint(records[len(records)-1].Sequence, 10)
+	}
+
+	writeControlJSON(w, http.StatusOK, page)
+}
+
+func (c *controlAPI) handleInvocation(w http.ResponseWriter, r *http.Request) {
+	detail, ok, err := c.history.get(r.PathValue("id"))
+	if err != nil {
+		writeControlError(w, http.StatusInternalServerError, "InternalError", err.Error())
+
+		return
+	}
+
+	if !ok {
+		writeControlError(
+			w,
+			http.StatusNotFound,
+			"NotFound",
+			fmt.Sprintf("invocation '%s' not found in the history", r.PathValue("id")),
+		)
+
+		return
+	}
+
+	writeControlJSON(w, http.StatusOK, detail)
+}
+
+func (c *controlAPI) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, err := controlLimit(query.Get("limit"))
+	if err != nil {
+		writeControlError(w, http.StatusBadRequest, "InvalidParameter", err.Error())
+
+		return
+	}
+
+	// the cursor of routes is the offset of the next page
+	offset := 0
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			writeControlError(w, http.StatusBadRequest, "InvalidParameter", "invalid cursor '"+cursor+"'")
+
+			return
+		}
+	}
+
+	var routes []controlRoute
+
+	for _, route := range c.routes {
+		if function := query.Get("function"); function != "" && route.Function != function {
+			continue
+		}
+
+		routes = append(routes, route)
+	}
+
+	page := controlPage[controlRoute]{Items: []controlRoute{}}
+	if offset < len(routes) {
+		page.Items = routes[offset:min(offset+limit, len(routes))]
+	}
+
+	if offset+limit < len(routes) {
+		page.NextCursor = strconv.Itoa(offset + limit)
+	}
+
+	writeControlJSON(w, http.StatusOK, page)
+}
+
+// controlLimit parses the limit parameter of list endpoints.
+func controlLimit(value string) (int, error) {
+	if value == "" {
+		return controlAPIDefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > controlAPIMaxLimit {
+		return 0, fmt.Errorf("limit must be a number from 1 to %d, got '%s'", controlAPIMaxLimit, value)
+	}
+
+	return limit, nil
+}
+
+func writeControlJSON(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	_ = encoder.Encode(value)
+}
+
+func writeControlError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeControlJSON(w, statusCode, controlError{Error: controlErrorBody{Code: code, Message: message}})
+}
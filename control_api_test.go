@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestControlAPI returns a control API whose history holds size invocations, the last
+// invocations of 'GET /users' and 'POST /orders' out of count alternating ones.
+func newTestControlAPI(t *testing.T, size, count int) *http.ServeMux {
+	t.Helper()
+
+	history := newInvocationHistory(size, 1<<20, newMemoryStore(), slog.Default())
+	users := history.caller(
+		staticLambdaCaller{messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}},
+		apiRoute{method: http.MethodGet, path: "/users", function: "UsersFn"},
+	)
+	orders := history.caller(
+		staticLambdaCaller{
+			messages.InvokeResponse{Error: &messages.InvokeResponse_Error{Type: "Boom", Message: "boom"}},
+		},
+		apiRoute{method: http.MethodPost, path: "/orders", function: "OrdersFn"},
+	)
+
+	for i := range count {
+		caller := users
+		if i%2 == 1 {
+			caller = orders
+		}
+
+		data := []byte(`{"n":` + strconv.Itoa(i) + `}`)
+		_, err := caller.Invoke(context.Background(), data, WithRequestID(strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+
+	control := &controlAPI{
+		history: history,
+		metrics: newConnMetrics(false),
+		routes: []controlRoute{
+			newControlRoute(apiRoute{method: http.MethodGet, path: "/users", function: "UsersFn"}, false),
+			newControlRoute(apiRoute{method: http.MethodPost, path: "/orders", function: "OrdersFn"}, false),
+			newControlRoute(apiRoute{path: "/health"}, true),
+		},
+	}
+
+	router := http.NewServeMux()
+	control.register(router)
+
+	return router
+}
+
+func getControlAPI(t *testing.T, router http.Handler, target string, out any) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+
+	return w.Code
+}
+
+func TestControlAPIInvocations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target     string
+		wantIDs    []string
+		wantCursor string
+	}{
+		"newest first": {
+			target:  "/__lambdalocal/v1/invocations",
+			wantIDs: []string{"5", "4", "3", "2", "1", "0"},
+		},
+		"first page": {
+			target:     "/__lambdalocal/v1/invocations?limit=4",
+			wantIDs:    []string{"5", "4", "3", "2"},
+			wantCursor: "3",
+		},
+		"last page": {
+			target:  "/__lambdalocal/v1/invocations?limit=4&cursor=3",
+			wantIDs: []string{"1", "0"},
+		},
+		"page ending with the last invocation": {
+			target:  "/__lambdalocal/v1/invocations?limit=2&cursor=3",
+			wantIDs: []string{"1", "0"},
+		},
+		"by route": {
+			target:     "/__lambdalocal/v1/invocations?route=GET+/users&limit=2",
+			wantIDs:    []string{"4", "2"},
+			wantCursor: "3",
+		},
+		"by function and status": {
+			target:  "/__lambdalocal/v1/invocations?function=OrdersFn&status=error",
+			wantIDs: []string{"5", "3", "1"},
+		},
+		"no match": {
+			target:  "/__lambdalocal/v1/invocations?status=failed",
+			wantIDs: []string{},
+		},
+	}
+
+	router := newTestControlAPI(t, 10, 6)
+
+	for name, tt := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var page controlPage[invocationRecord]
+				assert.Equal(t, http.StatusOK, getControlAPI(t, router, tt.target, &page))
+
+				ids := make([]string, 0, len(page.Items))
+				for _, record := range page.Items {
+					ids = append(ids, record.ID)
+				}
+
+				assert.Equal(t, tt.wantIDs, ids)
+				assert.Equal(t, tt.wantCursor, page.NextCursor)
+			},
+		)
+	}
+}
+
+func TestControlAPIHistorySize(t *testing.T) {
+	t.Parallel()
+
+	// the oldest invocations are dropped once the history is full
+	router := newTestControlAPI(t, 3, 7)
+
+	var page controlPage[invocationRecord]
+	getControlAPI(t, router, "/__lambdalocal/v1/invocations", &page)
+
+	require.Len(t, page.Items, 3)
+	assert.Equal(t, "6", page.Items[0].ID)
+	assert.Equal(t, uint64(7), page.Items[0].Sequence)
+	assert.Equal(t, "4", page.Items[2].ID)
+}
+
+func TestControlAPIInvocation(t *testing.T) {
+	t.Parallel()
+
+	router := newTestControlAPI(t, 10, 2)
+
+	var detail invocationDetail
+	require.Equal(t, http.StatusOK, getControlAPI(t, router, "/__lambdalocal/v1/invocations/1", &detail))
+
+	assert.Equal(t, "POST /orders", detail.Route)
+	assert.Equal(t, "OrdersFn", detail.Function)
+	assert.Equal(t, invocationStatusError, detail.Status)
+	assert.Equal(t, "Boom", detail.ErrorType)
+	assert.Equal(t, "boom", detail.ErrorMessage)
+	assert.JSONEq(t, `{"n":1}`, string(detail.Request))
+	assert.Empty(t, detail.Response)
+
+	require.Equal(t, http.StatusOK, getControlAPI(t, router, "/__lambdalocal/v1/invocations/0", &detail))
+	assert.Equal(t, invocationStatusSuccess, detail.Status)
+	assert.Equal(t, http.StatusOK, detail.StatusCode)
+	assert.JSONEq(t, `{"statusCode":200}`, string(detail.Response))
+
+	var errResponse controlError
+	assert.Equal(t, http.StatusNotFound, getControlAPI(t, router, "/__lambdalocal/v1/invocations/7", &errResponse))
+	assert.Equal(t, "NotFound", errResponse.Error.Code)
+}
+
+func TestControlAPIRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target     string
+		wantRoutes []string
+		wantCursor string
+	}{
+		"all": {
+			target:     "/__lambdalocal/v1/routes",
+			wantRoutes: []string{"GET /users", "POST /orders", "ANY /health"},
+		},
+		"first page": {
+			target:     "/__lambdalocal/v1/routes?limit=2",
+			wantRoutes: []string{"GET /users", "POST /orders"},
+			wantCursor: "2",
+		},
+		"last page": {
+			target:     "/__lambdalocal/v1/routes?limit=2&cursor=2",
+			wantRoutes: []string{"ANY /health"},
+		},
+		"past the end": {
+			target:     "/__lambdalocal/v1/routes?cursor=5",
+			wantRoutes: []string{},
+		},
+		"by function": {
+			target:     "/__lambdalocal/v1/routes?function=OrdersFn",
+			wantRoutes: []string{"POST /orders"},
+		},
+	}
+
+	router := newTestControlAPI(t, 10, 0)
+
+	for name, tt := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var page controlPage[controlRoute]
+				assert.Equal(t, http.StatusOK, getControlAPI(t, router, tt.target, &page))
+
+				routes := make([]string, 0, len(page.Items))
+				for _, route := range page.Items {
+					routes = append(routes, route.Route)
+				}
+
+				assert.Equal(t, tt.wantRoutes, routes)
+				assert.Equal(t, tt.wantCursor, page.NextCursor)
+			},
+		)
+	}
+}
+
+func TestControlAPIInvalidParameters(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"limit zero":            "/__lambdalocal/v1/invocations?limit=0",
+		"limit too large":       "/__lambdalocal/v1/routes?limit=501",
+		"limit not a number":    "/__lambdalocal/v1/invocations?limit=ten",
+		"invocation cursor":     "/__lambdalocal/v1/invocations?cursor=abc",
+		"negative route cursor": "/__lambdalocal/v1/routes?cursor=-1",
+	}
+
+	router := newTestControlAPI(t, 10, 1)
+
+	for name, target := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var errResponse controlError
+				assert.Equal(t, http.StatusBadRequest, getControlAPI(t, router, target, &errResponse))
+				assert.Equal(t, "InvalidParameter", errResponse.Error.Code)
+				assert.NotEmpty(t, errResponse.Error.Message)
+			},
+		)
+	}
+}
+
+func TestControlAPIHistoryDisabled(t *testing.T) {
+	t.Parallel()
+
+	router := newTestControlAPI(t, 0, 3)
+
+	var page controlPage[invocationRecord]
+	getControlAPI(t, router, "/__lambdalocal/v1/invocations", &page)
+
+	assert.Empty(t, page.Items)
+}
+
+func TestInvocationHistoryMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+	history := newInvocationHistory(10, 1200, s, slog.Default())
+	caller := history.caller(
+		staticLambdaCaller{messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}},
+		apiRoute{method: http.MethodPost, path: "/upload"},
+	)
+
+	for i, size := range []int{100, 100, 100, 1300} {
+		body := `{"data":"` + strings.Repeat("x", size) + `"}`
+
+		_, err := caller.Invoke(context.Background(), []byte(body), WithRequestID(strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+
+	// the payloads of the upload larger than the history aren't kept, and the oldest invocations
+	// were dropped from the history and the store to make room for it
+	records, _ := history.list(invocationFilter{}, 10)
+	require.Len(t, records, 3)
+	assert.Equal(t, "3", records[0].ID)
+	assert.True(t, records[0].PayloadsOmitted)
+	assert.Equal(t, "1", records[2].ID)
+	assert.LessOrEqual(t, history.bytes, int64(1200))
+
+	entries, err := s.list(recordingsPrefix)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	detail, ok, err := history.get("3")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, detail.Request)
+	assert.Equal(t, 1311, detail.RequestSize)
+}
+
+func TestInvocationHistoryRecorded(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+
+	history := newInvocationHistory(2, 1<<20, s, slog.Default())
+	history.recorded = true
+	caller := history.caller(
+		staticLambdaCaller{messages.InvokeResponse{Payload: []byte(`{"statusCode":201}`)}},
+		apiRoute{method: http.MethodPost, path: "/orders", function: "OrdersFn"},
+	)
+
+	for _, id := range []string{"a", "b", "c/../d"} {
+		_, err := caller.Invoke(context.Background(), []byte(`{"id":"`+id+`"}`), WithRequestID(id))
+		require.NoError(t, err)
+	}
+
+	// recordings dropped from the history are kept in the store
+	entries, err := s.list(recordingsPrefix)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Regexp(t, `^recordings/\d{8}T\d{6}\.\d{9}Z-c____d\.json$`, entries[2].key)
+
+	// the next run lists the last recordings
+	next := newInvocationHistory(2, 1<<20, s, slog.Default())
+	require.NoError(t, next.load())
+
+	records, _ := next.list(invocationFilter{}, 10)
+	require.Len(t, records, 2)
+	assert.Equal(t, "c/../d", records[0].ID)
+	assert.Equal(t, uint64(2), records[0].Sequence)
+
+	detail, ok, err := next.get("b")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "OrdersFn", detail.Function)
+	assert.Equal(t, http.StatusCreated, detail.StatusCode)
+	assert.JSONEq(t, `{"id":"b"}`, string(detail.Request))
+}
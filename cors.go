@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// corsConfig is the CORS configuration of the API a route belongs to.
+type corsConfig struct {
+	allowOrigins     []string
+	allowMethods     []string
+	allowHeaders     []string
+	exposeHeaders    []string
+	maxAge           int
+	allowCredentials bool
+	// httpAPI adds the headers to every response. REST APIs only answer preflight requests, the
+	// lambda adds the headers to its responses.
+	httpAPI bool
+}
+
+// restAPICors parses the Cors property of AWS::Serverless::Api resources and Globals.Api. It is
+// either the allowed origin or an object, and its values are quoted like "'*'".
+func restAPICors(v any) *corsConfig {
+	switch value := v.(type) {
+	case string:
+		return &corsConfig{allowOrigins: []string{unquoteCors(value)}}
+	case map[string]any:
+		config := &corsConfig{
+			allowOrigins:     splitCors(value["AllowOrigin"]),
+			allowMethods:     splitCors(value["AllowMethods"]),
+			allowHeaders:     splitCors(value["AllowHeaders"]),
+			allowCredentials: value["AllowCredentials"] == true,
+		}
+
+		if maxAge, ok := value["MaxAge"].(string); ok {
+			config.maxAge, _ = strconv.Atoi(unquoteCors(maxAge))
+		}
+
+		return config
+	default:
+		return nil
+	}
+}
+
+// httpAPICors parses the CorsConfiguration property of AWS::Serverless::HttpApi resources and
+// Globals.HttpApi. true allows every origin, method and header.
+func httpAPICors(v any) *corsConfig {
+	switch value := v.(type) {
+	case bool:
+		if !value {
+			return nil
+		}
+
+		return &corsConfig{
+			allowOrigins: []string{"*"},
+			allowMethods: []string{"*"},
+			allowHeaders: []string{"*"},
+			httpAPI:      true,
+		}
+	case map[string]any:
+		config := &corsConfig{
+			allowOrigins:     templateStrings(value["AllowOrigins"]),
+			allowMethods:     templateStrings(value["AllowMethods"]),
+			allowHeaders:     templateStrings(value["AllowHeaders"]),
+			exposeHeaders:    templateStrings(value["ExposeHeaders"]),
+			allowCredentials: value["AllowCredentials"] == true,
+			httpAPI:          true,
+		}
+
+		if maxAge, ok := value["MaxAge"].(int); ok {
+			config.maxAge = maxAge
+		}
+
+		return config
+	default:
+		return nil
+	}
+}
+
+// unquoteCors removes the single quotes REST API header values are written with.
+func unquoteCors(value string) string {
+	return strings.Trim(strings.TrimSpace(value), "'")
+}
+
+// splitCors splits a quoted, comma separated REST API header value.
+func splitCors(v any) []string {
+	value, ok := v.(string)
+	if !ok {
+		return nil
+	}
+
+	var values []string
+
+	for _, item := range strings.Split(unquoteCors(value), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for a request from origin, or "" when
+// the origin isn't allowed. With credentials a wildcard echoes the origin, as browsers reject "*".
+// REST APIs return their configured origin for every request.
+func (c *corsConfig) allowOrigin(origin string) string {
+	for _, allowed := range c.allowOrigins {
+		if allowed == "*" {
+			if c.allowCredentials && origin != "" {
+				return origin
+			}
+
+			return "*"
+		}
+
+		if allowed == origin {
+			return origin
+		}
+	}
+
+	if !c.httpAPI && len(c.allowOrigins) == 1 {
+		return c.allowOrigins[0]
+	}
+
+	return ""
+}
+
+// setOriginHeaders sets the headers of responses to cross-origin requests and reports whether the
+// origin is allowed.
+func (c *corsConfig) setOriginHeaders(header http.Header, origin string) bool {
+	allowOrigin := c.allowOrigin(origin)
+	if allowOrigin == "" {
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+
+	if allowOrigin != "*" {
+		header.Add("Vary", "Origin")
+	}
+
+	if c.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(c.exposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(c.exposeHeaders, ","))
+	}
+
+	return true
+}
+
+// corsPreflight answers the preflight requests of one path.
+type corsPreflight struct {
+	// pattern is the http.ServeMux pattern of OPTIONS requests to the path.
+	pattern string
+	path    string
+	cors    *corsConfig
+	// methods are the methods of the path's routes, allowed when the config doesn't list any.
+	methods []string
+}
+
+// corsPreflights returns a preflight handler for every path with routes of an API with CORS,
+// unless the template has its own OPTIONS route for the path.
+func corsPreflights(routes []apiRoute) []corsPreflight {
+	preflights := make(map[string]*corsPreflight)
+	explicit := make(map[string]bool)
+
+	for _, route := range routes {
+		if route.method == http.MethodOptions {
+			explicit[route.path] = true
+		}
+
+		if route.cors == nil {
+			continue
+		}
+
+		preflight, ok := preflights[route.path]
+		if !ok {
+			options := route
+			options.method = http.MethodOptions
+
+			preflight = &corsPreflight{pattern: options.muxPattern(), path: route.path, cors: route.cors}
+			preflights[route.path] = preflight
+		}
+
+		method := route.method
+		if method == "" {
+			method = anyMethod
+		}
+
+		preflight.methods = append(preflight.methods, method)
+	}
+
+	result := make([]corsPreflight, 0, len(preflights))
+
+	for path, preflight := range preflights {
+		if !explicit[path] {
+			result = append(result, *preflight)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].path < result[j].path })
+
+	return result
+}
+
+// allowedMethods returns the configured methods, or the path's methods and OPTIONS. A path with an
+// any method route allows every method.
+func (p corsPreflight) allowedMethods() []string {
+	if len(p.cors.allowMethods) > 0 {
+		return p.cors.allowMethods
+	}
+
+	methods := []string{http.MethodOptions}
+
+	for _, method := range p.methods {
+		if method == anyMethod {
+			return []string{
+				http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+				http.MethodPatch, http.MethodPost, http.MethodPut,
+			}
+		}
+
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// handler answers preflight requests like API Gateway. HttpApi answers with 204, the mock
+// integration SAM adds to REST APIs with 200.
+func (p corsPreflight) handler(logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			status := http.StatusOK
+			if p.cors.httpAPI {
+				status = http.StatusNoContent
+			}
+
+			if !p.cors.setOriginHeaders(w.Header(), origin) {
+				logger.Warn(fmt.Sprintf("CORS preflight for %s from origin '%s' not allowed", r.URL.Path, origin))
+				w.WriteHeader(status)
+
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.allowedMethods(), ","))
+
+			if len(p.cors.allowHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.cors.allowHeaders, ","))
+			}
+
+			if p.cors.maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.cors.maxAge))
+			}
+
+			w.WriteHeader(status)
+		},
+	)
+}
+
+// corsResponseWriter sets the CORS headers of an HttpApi before the response is written, replacing
+// CORS headers returned by the lambda like API Gateway does.
+type corsResponseWriter struct {
+	http.ResponseWriter
+	cors        *corsConfig
+	origin      string
+	wroteHeader bool
+}
+
+func (w *corsResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		for key := range w.Header() {
+			if strings.HasPrefix(key, "Access-Control-") {
+				w.Header().Del(key)
+			}
+		}
+
+		w.cors.setOriginHeaders(w.Header(), w.origin)
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *corsResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck
+}
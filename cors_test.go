@@ -0,0 +1,245 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRestAPICors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cors     any
+		expected *corsConfig
+	}{
+		"not set": {
+			cors:     nil,
+			expected: nil,
+		},
+		"origin": {
+			cors:     "'*'",
+			expected: &corsConfig{allowOrigins: []string{"*"}},
+		},
+		"object": {
+			cors: map[string]any{
+				"AllowOrigin":      "'https://app.example.com'",
+				"AllowMethods":     "'GET, POST'",
+				"AllowHeaders":     "'Content-Type,Authorization'",
+				"MaxAge":           "'600'",
+				"AllowCredentials": true,
+			},
+			expected: &corsConfig{
+				allowOrigins:     []string{"https://app.example.com"},
+				allowMethods:     []string{"GET", "POST"},
+				allowHeaders:     []string{"Content-Type", "Authorization"},
+				maxAge:           600,
+				allowCredentials: true,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, restAPICors(tc.cors))
+			},
+		)
+	}
+}
+
+func TestCorsAllowOrigin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cors     corsConfig
+		origin   string
+		expected string
+	}{
+		"wildcard": {
+			cors:     corsConfig{allowOrigins: []string{"*"}, httpAPI: true},
+			origin:   "https://app.example.com",
+			expected: "*",
+		},
+		"wildcard with credentials": {
+			cors:     corsConfig{allowOrigins: []string{"*"}, allowCredentials: true, httpAPI: true},
+			origin:   "https://app.example.com",
+			expected: "https://app.example.com",
+		},
+		"listed origin": {
+			cors: corsConfig{
+				allowOrigins: []string{"https://a.example.com", "https://b.example.com"},
+				httpAPI:      true,
+			},
+			origin:   "https://b.example.com",
+			expected: "https://b.example.com",
+		},
+		"other origin": {
+			cors:     corsConfig{allowOrigins: []string{"https://a.example.com"}, httpAPI: true},
+			origin:   "https://evil.example.com",
+			expected: "",
+		},
+		"rest api returns the configured origin": {
+			cors:     corsConfig{allowOrigins: []string{"https://a.example.com"}},
+			origin:   "https://evil.example.com",
+			expected: "https://a.example.com",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, tc.cors.allowOrigin(tc.origin))
+			},
+		)
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	t.Parallel()
+
+	restCors := &corsConfig{allowOrigins: []string{"*"}, allowHeaders: []string{"Content-Type"}}
+	httpCors := &corsConfig{allowOrigins: []string{"https://app.example.com"}, maxAge: 600, httpAPI: true}
+
+	routes := []apiRoute{
+		{method: "GET", path: "/orders", cors: restCors},
+		{method: "POST", path: "/orders", cors: restCors},
+		{method: "", path: "/users/{id}", cors: httpCors},
+		{method: "GET", path: "/custom", cors: restCors},
+		{method: "OPTIONS", path: "/custom", cors: restCors},
+		{method: "GET", path: "/no-cors"},
+	}
+
+	preflights := corsPreflights(routes)
+
+	paths := make([]string, 0, len(preflights))
+	for _, preflight := range preflights {
+		paths = append(paths, preflight.path)
+	}
+
+	assert.Equal(t, []string{"/orders", "/users/{id}"}, paths)
+
+	tests := map[string]struct {
+		preflight       corsPreflight
+		origin          string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		"rest api": {
+			preflight:      preflights[0],
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET,OPTIONS,POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		"http api": {
+			preflight:      preflights[1],
+			origin:         "https://app.example.com",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "DELETE,GET,HEAD,OPTIONS,PATCH,POST,PUT",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin",
+			},
+		},
+		"http api with other origin": {
+			preflight:      preflights[1],
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				r := httptest.NewRequest(http.MethodOptions, "/", nil)
+				r.Header.Set("Origin", tc.origin)
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+				rr := httptest.NewRecorder()
+				tc.preflight.handler(slog.Default()).ServeHTTP(rr, r)
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+
+				for header, value := range tc.expectedHeaders {
+					assert.Equal(t, value, rr.Header().Get(header), header)
+				}
+			},
+		)
+	}
+}
+
+func TestGatewayHandlerCorsHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cors           *corsConfig
+		payloadFormat  string
+		expectedOrigin string
+	}{
+		"http api replaces the lambda's headers": {
+			cors:           &corsConfig{allowOrigins: []string{"https://app.example.com"}, httpAPI: true},
+			payloadFormat:  payloadFormatV2,
+			expectedOrigin: "https://app.example.com",
+		},
+		"http api without allowed origin drops the lambda's headers": {
+			cors:           &corsConfig{allowOrigins: []string{"https://other.example.com"}, httpAPI: true},
+			payloadFormat:  payloadFormatV2,
+			expectedOrigin: "",
+		},
+		"rest api keeps the lambda's headers": {
+			cors:           &corsConfig{allowOrigins: []string{"https://app.example.com"}},
+			payloadFormat:  payloadFormatV1,
+			expectedOrigin: "https://lambda.example.com",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(MockLambdaCaller)
+				caller.On("Invoke", mock.Anything).Return(
+					messages.InvokeResponse{
+						Payload: []byte(
+							`{"statusCode":200,"headers":{"Access-Control-Allow-Origin":"https://lambda.example.com"}}`,
+						),
+					},
+					nil,
+				)
+
+				route := apiRoute{method: http.MethodGet, path: "/test", payloadFormat: tc.payloadFormat, cors: tc.cors}
+
+				r := httptest.NewRequest(http.MethodGet, "/test", nil)
+				r.Header.Set("Origin", "https://app.example.com")
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).ServeHTTP(rr, r)
+
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, tc.expectedOrigin, rr.Header().Get("Access-Control-Allow-Origin"))
+			},
+		)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cronMaxYear is the last year AWS cron expressions can schedule in.
+	cronMaxYear = 2199
+	// scheduleAtLayout is the time format of one-time at() expressions.
+	scheduleAtLayout = "2006-01-02T15:04:05"
+)
+
+// scheduleExpression is a rate, cron or at expression of EventBridge rules and schedules.
+type scheduleExpression interface {
+	// next returns the first time after after the expression fires, false when it doesn't fire
+	// again. start is when the schedule started, rate expressions fire in intervals from it.
+	next(start, after time.Time) (time.Time, bool)
+}
+
+// parseScheduleExpression parses rate(5 minutes), cron(0 12 * * ? *) and at(2025-01-01T09:00:00).
+// Cron and at expressions are evaluated in location.
+func parseScheduleExpression(expression string, location *time.Location) (scheduleExpression, error) {
+	kind, args, ok := strings.Cut(strings.TrimSpace(expression), "(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return nil, fmt.Errorf("[in lambdalocal.parseScheduleExpression] invalid expression '%s'", expression)
+	}
+
+	args = strings.TrimSpace(strings.TrimSuffix(args, ")"))
+
+	var (
+		parsed scheduleExpression
+		err    error
+	)
+
+	switch kind {
+	case "rate":
+		parsed, err = parseRate(args)
+	case "cron":
+		parsed, err = parseCron(args, location)
+	case "at":
+		var at time.Time
+
+		at, err = time.ParseInLocation(scheduleAtLayout, args, location)
+		parsed = atExpression(at)
+	default:
+		err = fmt.Errorf("unknown expression type '%s', expected rate, cron or at", kind)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseScheduleExpression] '%s': %w", expression, err)
+	}
+
+	return parsed, nil
+}
+
+type rateExpression time.Duration
+
+func parseRate(args string) (rateExpression, error) {
+	value, unit, _ := strings.Cut(args, " ")
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate value '%s'", value)
+	}
+
+	switch strings.TrimSuffix(strings.TrimSpace(unit), "s") {
+	case "minute":
+		return rateExpression(time.Duration(n) * time.Minute), nil
+	case "hour":
+		return rateExpression(time.Duration(n) * time.Hour), nil
+	case "day":
+		return rateExpression(time.Duration(n) * 24 * time.Hour), nil //nolint:mnd
+	default:
+		return 0, fmt.Errorf("invalid rate unit '%s', expected minutes, hours or days", unit)
+	}
+}
+
+func (r rateExpression) next(start, after time.Time) (time.Time, bool) {
+	interval := time.Duration(r)
+	periods := after.Sub(start)/interval + 1
+
+	return start.Add(periods * interval), true
+}
+
+type atExpression time.Time
+
+func (a atExpression) next(_, after time.Time) (time.Time, bool) {
+	at := time.Time(a)
+
+	return at, at.After(after)
+}
+
+// cronExpression is an AWS cron expression: minutes, hours, day of month, month, day of week and
+// year. Days of the week are 1 (SUN) to 7 (SAT), and one of the day fields must be ?.
+type cronExpression struct {
+	minutes, hours, months map[int]bool
+	years                  map[int]bool
+	// days matches days of the month, nil when the day of the month is ?.
+	days *cronDays
+	// weekdays matches days of the week, nil when the day of the week is ?.
+	weekdays *cronWeekdays
+	location *time.Location
+}
+
+// cronDays is the day of month field. Besides values it can be L, the last day, LW, the last
+// weekday, or NW, the weekday nearest to day N.
+type cronDays struct {
+	values      map[int]bool
+	last        bool
+	lastWeekday bool
+	nearest     int
+}
+
+// cronWeekdays is the day of week field. Besides values it can be NL, the last day N of the month,
+// or N#K, the Kth day N of the month.
+type cronWeekdays struct {
+	values map[int]bool
+	last   int
+	nth    [2]int
+}
+
+//nolint:gochecknoglobals
+var (
+	cronMonthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	cronWeekdayNames = map[string]int{"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7}
+)
+
+func parseCron(args string, location *time.Location) (cronExpression, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 6 { //nolint:mnd
+		return cronExpression{}, fmt.Errorf("expected 6 fields, got %d", len(fields))
+	}
+
+	if (fields[2] == "?") == (fields[4] == "?") {
+		return cronExpression{}, errors.New("one of day of month and day of week must be ?")
+	}
+
+	expression := cronExpression{location: location}
+
+	var err error
+
+	if expression.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil { //nolint:mnd
+		return cronExpression{}, fmt.Errorf("minutes: %w", err)
+	}
+
+	if expression.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil { //nolint:mnd
+		return cronExpression{}, fmt.Errorf("hours: %w", err)
+	}
+
+	if expression.months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil { //nolint:mnd
+		return cronExpression{}, fmt.Errorf("months: %w", err)
+	}
+
+	if expression.years, err = parseCronField(fields[5], 1970, cronMaxYear, nil); err != nil { //nolint:mnd
+		return cronExpression{}, fmt.Errorf("years: %w", err)
+	}
+
+	if fields[2] != "?" {
+		if expression.days, err = parseCronDays(fields[2]); err != nil {
+			return cronExpression{}, fmt.Errorf("day of month: %w", err)
+		}
+	}
+
+	if fields[4] != "?" {
+		if expression.weekdays, err = parseCronWeekdays(fields[4]); err != nil {
+			return cronExpression{}, fmt.Errorf("day of week: %w", err)
+		}
+	}
+
+	return expression, nil
+}
+
+// parseCronField parses a list of values, ranges, * and steps like 0/15 or 1-5/2 between minimum
+// and maximum. names are aliases of values, like JAN.
+func parseCronField(field string, minimum, maximum int, names map[string]int) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToUpper(s)]; ok {
+			return n, nil
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < minimum || n > maximum {
+			return 0, fmt.Errorf("invalid value '%s', expected %d-%d", s, minimum, maximum)
+		}
+
+		return n, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		span, stepText, hasStep := strings.Cut(part, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step '%s'", stepText)
+			}
+		}
+
+		from, to := minimum, maximum
+
+		switch {
+		case span == "*":
+		case strings.Contains(span, "-"):
+			lowText, highText, _ := strings.Cut(span, "-")
+
+			var err error
+			if from, err = value(lowText); err != nil {
+				return nil, err
+			}
+
+			if to, err = value(highText); err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			if from, err = value(span); err != nil {
+				return nil, err
+			}
+
+			// a single value with a step, like 0/15, runs to the maximum
+			if !hasStep {
+				to = from
+			}
+		}
+
+		for n := from; n <= to; n += step {
+			values[n] = true
+		}
+	}
+
+	return values, nil
+}
+
+func parseCronDays(field string) (*cronDays, error) {
+	switch {
+	case field == "L":
+		return &cronDays{last: true}, nil
+	case field == "LW":
+		return &cronDays{lastWeekday: true}, nil
+	case strings.HasSuffix(field, "W"):
+		day, err := strconv.Atoi(strings.TrimSuffix(field, "W"))
+		if err != nil || day < 1 || day > 31 {
+			return nil, fmt.Errorf("invalid nearest weekday '%s'", field)
+		}
+
+		return &cronDays{nearest: day}, nil
+	}
+
+	values, err := parseCronField(field, 1, 31, nil) //nolint:mnd
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronDays{values: values}, nil
+}
+
+func parseCronWeekdays(field string) (*cronWeekdays, error) {
+	weekday := func(s string) (int, error) {
+		values, err := parseCronField(s, 1, 7, cronWeekdayNames) //nolint:mnd
+		if err != nil || len(values) != 1 {
+			return 0, fmt.Errorf("invalid day of week '%s'", s)
+		}
+
+		for day := range values {
+			return day, nil
+		}
+
+		return 0, nil
+	}
+
+	if day, nth, ok := strings.Cut(field, "#"); ok {
+		n, err := weekday(day)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := strconv.Atoi(nth)
+		if err != nil || k < 1 || k > 5 {
+			return nil, fmt.Errorf("invalid occurrence '%s', expected 1-5", nth)
+		}
+
+		return &cronWeekdays{nth: [2]int{n, k}}, nil
+	}
+
+	if day, ok := strings.CutSuffix(field, "L"); ok && day != "" {
+		n, err := weekday(day)
+		if err != nil {
+			return nil, err
+		}
+
+		return &cronWeekdays{last: n}, nil
+	}
+
+	values, err := parseCronField(field, 1, 7, cronWeekdayNames) //nolint:mnd
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronWeekdays{values: values}, nil
+}
+
+func (c cronExpression) next(_, after time.Time) (time.Time, bool) {
+	after = after.In(c.location)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, c.location)
+
+	for ; day.Year() <= cronMaxYear; day = day.AddDate(0, 0, 1) {
+		if !c.years[day.Year()] || !c.months[int(day.Month())] || !c.matchesDay(day) {
+			continue
+		}
+
+		for hour := range 24 {
+			if !c.hours[hour] {
+				continue
+			}
+
+			for minute := range 60 {
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, c.location)
+				if c.minutes[minute] && at.After(after) {
+					return at, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// matchesDay reports if the day fields match day.
+func (c cronExpression) matchesDay(day time.Time) bool {
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+
+	if c.days != nil {
+		switch {
+		case c.days.last:
+			return day.Day() == lastDay
+		case c.days.lastWeekday:
+			return day.Day() == nearestWeekday(day, lastDay, lastDay)
+		case c.days.nearest > 0:
+			return day.Day() == nearestWeekday(day, min(c.days.nearest, lastDay), lastDay)
+		default:
+			return c.days.values[day.Day()]
+		}
+	}
+
+	weekday := int(day.Weekday()) + 1
+
+	switch {
+	case c.weekdays.last > 0:
+		return weekday == c.weekdays.last && day.Day()+7 > lastDay
+	case c.weekdays.nth[0] > 0:
+		return weekday == c.weekdays.nth[0] && (day.Day()-1)/7+1 == c.weekdays.nth[1]
+	default:
+		return c.weekdays.values[weekday]
+	}
+}
+
+// nearestWeekday returns the weekday of the month of day nearest to target, without leaving the
+// month.
+func nearestWeekday(day time.Time, target, lastDay int) int {
+	switch time.Date(day.Year(), day.Month(), target, 0, 0, 0, 0, day.Location()).Weekday() {
+	case time.Saturday:
+		if target == 1 {
+			return target + 2 //nolint:mnd
+		}
+
+		return target - 1
+	case time.Sunday:
+		if target == lastDay {
+			return target - 2 //nolint:mnd
+		}
+
+		return target + 1
+	default:
+		return target
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScheduleExpression(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC) // a Wednesday
+
+	tests := map[string]struct {
+		expression string
+		timezone   string
+		// expected are the next times the expression fires after start, in UTC.
+		expected    []string
+		expectedErr string
+	}{
+		"rate in minutes": {
+			expression: "rate(5 minutes)",
+			expected:   []string{"2024-05-15T10:35:00Z", "2024-05-15T10:40:00Z"},
+		},
+		"rate of one day": {
+			expression: "rate(1 day)",
+			expected:   []string{"2024-05-16T10:30:00Z", "2024-05-17T10:30:00Z"},
+		},
+		"cron every 15 minutes": {
+			expression: "cron(0/15 * * * ? *)",
+			expected:   []string{"2024-05-15T10:45:00Z", "2024-05-15T11:00:00Z", "2024-05-15T11:15:00Z"},
+		},
+		"cron on weekdays": {
+			expression: "cron(0 9 ? * MON-FRI *)",
+			expected:   []string{"2024-05-16T09:00:00Z", "2024-05-17T09:00:00Z", "2024-05-20T09:00:00Z"},
+		},
+		"cron with lists and month names": {
+			expression: "cron(0 8,20 1 JAN,JUN ? *)",
+			expected:   []string{"2024-06-01T08:00:00Z", "2024-06-01T20:00:00Z", "2025-01-01T08:00:00Z"},
+		},
+		"cron on the last day of the month": {
+			expression: "cron(0 0 L * ? *)",
+			expected:   []string{"2024-05-31T00:00:00Z", "2024-06-30T00:00:00Z"},
+		},
+		"cron on the last weekday of the month": {
+			expression: "cron(0 0 LW * ? *)",
+			expected:   []string{"2024-05-31T00:00:00Z", "2024-06-28T00:00:00Z"},
+		},
+		"cron on the weekday nearest to a day": {
+			expression: "cron(0 0 1W * ? *)",
+			expected:   []string{"2024-06-03T00:00:00Z", "2024-07-01T00:00:00Z"},
+		},
+		"cron on the third Friday": {
+			expression: "cron(0 12 ? * 6#3 *)",
+			expected:   []string{"2024-05-17T12:00:00Z", "2024-06-21T12:00:00Z"},
+		},
+		"cron on the last Monday": {
+			expression: "cron(0 12 ? * 2L *)",
+			expected:   []string{"2024-05-27T12:00:00Z", "2024-06-24T12:00:00Z"},
+		},
+		"cron in a year": {
+			expression: "cron(0 0 1 1 ? 2030)",
+			expected:   []string{"2030-01-01T00:00:00Z"},
+		},
+		"cron in a time zone": {
+			expression: "cron(0 9 * * ? *)",
+			timezone:   "Europe/Berlin",
+			expected:   []string{"2024-05-16T07:00:00Z", "2024-05-17T07:00:00Z"},
+		},
+		"at fires once": {
+			expression: "at(2024-05-15T12:00:00)",
+			expected:   []string{"2024-05-15T12:00:00Z"},
+		},
+		"at in the past": {
+			expression: "at(2024-01-01T00:00:00)",
+		},
+		"cron with both days": {
+			expression:  "cron(0 0 1 * MON *)",
+			expectedErr: "one of day of month and day of week must be ?",
+		},
+		"cron with five fields": {
+			expression:  "cron(0 0 * * ?)",
+			expectedErr: "expected 6 fields, got 5",
+		},
+		"cron out of range": {
+			expression:  "cron(0 24 * * ? *)",
+			expectedErr: "hours: invalid value '24', expected 0-23",
+		},
+		"rate in seconds": {
+			expression:  "rate(30 seconds)",
+			expectedErr: "invalid rate unit 'seconds'",
+		},
+		"unknown expression": {
+			expression:  "every(5 minutes)",
+			expectedErr: "unknown expression type 'every'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				location, err := time.LoadLocation(tc.timezone)
+				require.NoError(t, err)
+
+				expression, err := parseScheduleExpression(tc.expression, location)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+
+				var times []string
+
+				for after := start; len(times) <= len(tc.expected); {
+					next, ok := expression.next(start, after)
+					if !ok {
+						break
+					}
+
+					times = append(times, next.UTC().Format(time.RFC3339))
+					after = next
+				}
+
+				assert.Equal(t, tc.expected, times[:min(len(times), len(tc.expected))])
+
+				if len(tc.expected) == 0 {
+					assert.Empty(t, times)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+
+	// maxClockSkew is the clock skew above which signed AWS requests start to fail.
+	maxClockSkew = 5 * time.Minute
+	// clockReferenceURL is requested for its Date header to measure clock skew.
+	clockReferenceURL = "https://lambda.us-east-1.amazonaws.com"
+)
+
+type checkResult struct {
+	name   string
+	status string
+	detail string
+	// hint is shown for failed checks and warnings.
+	hint string
+}
+
+// doctor checks the environment for common causes of failed invocations.
+type doctor struct {
+	protocol          string
+	address           string
+	functionAddresses map[string]string
+	port              string
+	templatePath      string
+	// timeout limits every network check.
+	timeout   time.Duration
+	clockURL  string
+	lookupEnv func(key string) (string, bool)
+	now       func() time.Time
+}
+
+func newDoctor(
+	protocol, address string,
+	functionAddresses map[string]string,
+	port, templatePath string,
+) doctor {
+	return doctor{
+		protocol:          protocol,
+		address:           address,
+		functionAddresses: functionAddresses,
+		port:              port,
+		templatePath:      templatePath,
+		timeout:           2 * time.Second, //nolint:mnd
+		clockURL:          clockReferenceURL,
+		lookupEnv:         os.LookupEnv,
+		now:               time.Now,
+	}
+}
+
+// RunDoctor runs every check, prints the results and returns an error when a check failed.
+func RunDoctor(ctx context.Context, w io.Writer, d doctor) error {
+	results := d.run(ctx)
+
+	failed := 0
+
+	for _, result := range results {
+		_, _ = fmt.Fprintf(w, "%-4s  %-22s %s\n", result.status, result.name, result.detail)
+
+		if result.hint != "" && (result.status == checkFail || result.status == checkWarn) {
+			_, _ = fmt.Fprintf(w, "      %-22s hint: %s\n", "", result.hint)
+		}
+
+		if result.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("[in lambdalocal.RunDoctor] %d of %d checks failed", failed, len(results))
+	}
+
+	return nil
+}
+
+func (d doctor) run(ctx context.Context) []checkResult {
+	results := []checkResult{d.checkServerPortEnv()}
+
+	results = append(results, d.checkLambda("lambda", d.address))
+
+	functions := make([]string, 0, len(d.functionAddresses))
+	for function := range d.functionAddresses {
+		functions = append(functions, function)
+	}
+
+	sort.Strings(functions)
+
+	for _, function := range functions {
+		results = append(results, d.checkLambda("lambda "+function, d.functionAddresses[function]))
+	}
+
+	return append(
+		results,
+		d.checkTemplate(),
+		d.checkAPIPort(),
+		d.checkWildcardListener(),
+		d.checkClockSkew(ctx),
+	)
+}
+
+// checkServerPortEnv compares _LAMBDA_SERVER_PORT with the port of the lambda address.
+func (d doctor) checkServerPortEnv() checkResult {
+	result := checkResult{name: "_LAMBDA_SERVER_PORT"}
+
+	_, port, err := net.SplitHostPort(d.address)
+	if err != nil {
+		result.status = checkFail
+		result.detail = fmt.Sprintf("--address '%s' is not a host:port address", d.address)
+		result.hint = "pass the address of the lambda like localhost:8000"
+
+		return result
+	}
+
+	envPort, ok := d.lookupEnv("_LAMBDA_SERVER_PORT")
+
+	switch {
+	case !ok:
+		result.status = checkPass
+		result.detail = "not set in this shell"
+	case envPort != port:
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("is %s but --address uses port %s", envPort, port)
+		result.hint = fmt.Sprintf(
+			"start the handler with _LAMBDA_SERVER_PORT=%s or pass --address localhost:%s",
+			port,
+			envPort,
+		)
+	default:
+		result.status = checkPass
+		result.detail = "matches --address"
+	}
+
+	return result
+}
+
+// checkLambda checks that an RPC lambda accepts connections on address, or that the address is
+// free to serve the Runtime API on.
+func (d doctor) checkLambda(name, address string) checkResult {
+	result := checkResult{name: name}
+
+	if d.protocol == ProtocolRuntimeAPI {
+		if err := canListen(address); err != nil {
+			result.status = checkFail
+			result.detail = fmt.Sprintf("can't serve the Runtime API on %s: %s", address, err)
+			result.hint = "stop the process using the address or pick another --address"
+
+			return result
+		}
+
+		result.status = checkPass
+		result.detail = address + " is free to serve the Runtime API on"
+
+		return result
+	}
+
+	conn, err := net.DialTimeout("tcp", address, d.timeout)
+	if err != nil {
+		_, port, _ := net.SplitHostPort(address)
+
+		result.status = checkFail
+		result.detail = fmt.Sprintf("nothing is listening on %s", address)
+		result.hint = fmt.Sprintf(
+			"start the handler with _LAMBDA_SERVER_PORT=%s, use --run to let lambdalocal start it, or use "+
+				"--protocol %s for handlers built with lambda.norpc",
+			port,
+			ProtocolRuntimeAPI,
+		)
+
+		return result
+	}
+
+	_ = conn.Close()
+
+	result.status = checkPass
+	result.detail = address + " accepts connections"
+
+	return result
+}
+
+// checkTemplate checks that the template exists and has routes for api mode.
+func (d doctor) checkTemplate() checkResult {
+	result := checkResult{name: "template"}
+
+	if _, err := os.Stat(d.templatePath); err != nil {
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("'%s' does not exist", d.templatePath)
+		result.hint = "pass the path of the SAM template with --template, it is needed for api mode"
+
+		return result
+	}
+
+	routes, err := parseTemplate(d.templatePath, osFileReader{}, nil)
+	if err != nil {
+		result.status = checkFail
+		result.detail = fmt.Sprintf("'%s' can't be parsed: %s", d.templatePath, err)
+		result.hint = "check that the template is valid YAML"
+
+		return result
+	}
+
+	if len(routes) == 0 {
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("'%s' has no Api or HttpApi events", d.templatePath)
+		result.hint = "api mode only serves routes of Api and HttpApi events"
+
+		return result
+	}
+
+	result.status = checkPass
+	result.detail = fmt.Sprintf("'%s' has %d routes", d.templatePath, len(routes))
+
+	return result
+}
+
+// checkAPIPort checks that the port of the local API is free.
+func (d doctor) checkAPIPort() checkResult {
+	result := checkResult{name: "api port"}
+	address := net.JoinHostPort("localhost", d.port)
+
+	if err := canListen(address); err != nil {
+		result.status = checkFail
+		result.detail = fmt.Sprintf("can't listen on %s: %s", address, err)
+		result.hint = "stop the process using the port or pick another api --port"
+
+		return result
+	}
+
+	result.status = checkPass
+	result.detail = address + " is free"
+
+	return result
+}
+
+// checkWildcardListener checks that a listener on 0.0.0.0 can be reached, which fails when a
+// firewall blocks it.
+func (d doctor) checkWildcardListener() checkResult {
+	result := checkResult{name: "listen on 0.0.0.0"}
+
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("can't listen on 0.0.0.0: %s", err)
+		result.hint = "allow local processes to listen on all interfaces in the firewall"
+
+		return result
+	}
+
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", port), d.timeout)
+	if err != nil {
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("a listener on 0.0.0.0:%s can't be reached: %s", port, err)
+		result.hint = "allow incoming connections to local ports in the firewall"
+
+		return result
+	}
+
+	_ = conn.Close()
+
+	result.status = checkPass
+	result.detail = "listeners on all interfaces are reachable"
+
+	return result
+}
+
+// checkClockSkew compares the local clock with the Date header of an AWS endpoint.
+func (d doctor) checkClockSkew(ctx context.Context) checkResult {
+	result := checkResult{name: "clock skew"}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, d.clockURL, nil)
+	if err != nil {
+		result.status = checkSkip
+		result.detail = err.Error()
+
+		return result
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		result.status = checkSkip
+		result.detail = "no reference time, " + d.clockURL + " can't be reached"
+
+		return result
+	}
+
+	_ = response.Body.Close()
+
+	reference, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		result.status = checkSkip
+		result.detail = "no reference time, the response has no Date header"
+
+		return result
+	}
+
+	skew := d.now().Sub(reference).Round(time.Second)
+	if skew.Abs() > maxClockSkew {
+		result.status = checkFail
+		result.detail = fmt.Sprintf("local clock is off by %s", skew)
+		result.hint = "sync the system clock, AWS SDK calls from the handler fail with a skewed clock"
+
+		return result
+	}
+
+	result.status = checkPass
+	result.detail = fmt.Sprintf("local clock is off by %s", skew)
+
+	return result
+}
+
+// canListen checks that address can be listened on.
+func canListen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Err != nil {
+			return errors.New(strings.TrimPrefix(opErr.Err.Error(), "bind: "))
+		}
+
+		return err //nolint:wrapcheck
+	}
+
+	return listener.Close() //nolint:wrapcheck
+}
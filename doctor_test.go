@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDoctor(address string, env map[string]string) doctor {
+	d := newDoctor(ProtocolRPC, address, nil, "8080", "./template.yaml")
+	d.timeout = time.Second
+	d.lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+
+		return value, ok
+	}
+
+	return d
+}
+
+func TestDoctorCheckServerPortEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		address        string
+		env            map[string]string
+		expectedStatus string
+	}{
+		"not set": {address: "localhost:8000", expectedStatus: checkPass},
+		"matching port": {
+			address:        "localhost:8000",
+			env:            map[string]string{"_LAMBDA_SERVER_PORT": "8000"},
+			expectedStatus: checkPass,
+		},
+		"different port": {
+			address:        "localhost:8000",
+			env:            map[string]string{"_LAMBDA_SERVER_PORT": "9000"},
+			expectedStatus: checkWarn,
+		},
+		"invalid address": {address: "localhost", expectedStatus: checkFail},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				result := newTestDoctor(tc.address, tc.env).checkServerPortEnv()
+
+				assert.Equal(t, tc.expectedStatus, result.status, result.detail)
+			},
+		)
+	}
+}
+
+func TestDoctorCheckLambda(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	listening := listener.Addr().String()
+
+	closed, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	notListening := closed.Addr().String()
+	require.NoError(t, closed.Close())
+
+	rpcDoctor := newTestDoctor(listening, nil)
+
+	runtimeAPIDoctor := newTestDoctor(listening, nil)
+	runtimeAPIDoctor.protocol = ProtocolRuntimeAPI
+
+	assert.Equal(t, checkPass, rpcDoctor.checkLambda("lambda", listening).status)
+	assert.Equal(t, checkFail, rpcDoctor.checkLambda("lambda", notListening).status)
+	assert.Equal(t, checkFail, runtimeAPIDoctor.checkLambda("lambda", listening).status)
+	assert.Equal(t, checkPass, runtimeAPIDoctor.checkLambda("lambda", notListening).status)
+}
+
+func TestDoctorCheckTemplate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"routes.yaml": `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Api:
+          Type: Api
+          Properties:
+            Path: /hello
+            Method: get
+`,
+		"no-routes.yaml": "Resources: {}\n",
+		"invalid.yaml":   "Resources: [\n",
+	}
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	tests := map[string]struct {
+		template       string
+		expectedStatus string
+	}{
+		"template with routes":    {template: "routes.yaml", expectedStatus: checkPass},
+		"template without routes": {template: "no-routes.yaml", expectedStatus: checkWarn},
+		"invalid template":        {template: "invalid.yaml", expectedStatus: checkFail},
+		"missing template":        {template: "missing.yaml", expectedStatus: checkWarn},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				d := newTestDoctor("localhost:8000", nil)
+				d.templatePath = filepath.Join(dir, tc.template)
+
+				result := d.checkTemplate()
+
+				assert.Equal(t, tc.expectedStatus, result.status, result.detail)
+			},
+		)
+	}
+}
+
+func TestDoctorCheckClockSkew(t *testing.T) {
+	t.Parallel()
+
+	reference := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Date", reference.Format(http.TimeFormat))
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		now            time.Time
+		clockURL       string
+		expectedStatus string
+	}{
+		"clock in sync":      {now: reference.Add(2 * time.Second), clockURL: server.URL, expectedStatus: checkPass},
+		"skewed clock":       {now: reference.Add(-10 * time.Minute), clockURL: server.URL, expectedStatus: checkFail},
+		"unreachable server": {now: reference, clockURL: "http://localhost:0", expectedStatus: checkSkip},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				d := newTestDoctor("localhost:8000", nil)
+				d.clockURL = tc.clockURL
+				d.now = func() time.Time { return tc.now }
+
+				result := d.checkClockSkew(context.Background())
+
+				assert.Equal(t, tc.expectedStatus, result.status, result.detail)
+			},
+		)
+	}
+}
+
+func TestRunDoctor(t *testing.T) {
+	t.Parallel()
+
+	closed, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	address := closed.Addr().String()
+	require.NoError(t, closed.Close())
+
+	d := newTestDoctor(address, nil)
+	d.port = "0"
+	d.clockURL = "http://localhost:0"
+
+	var out bytes.Buffer
+
+	err = RunDoctor(context.Background(), &out, d)
+
+	require.ErrorContains(t, err, "[in lambdalocal.RunDoctor] 1 of 6 checks failed")
+	assert.Contains(t, out.String(), "FAIL  lambda                 nothing is listening on "+address)
+	assert.Contains(t, out.String(), "hint: start the handler with _LAMBDA_SERVER_PORT=")
+	assert.Contains(t, out.String(), "SKIP  clock skew")
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// dynamoDBMaxRecords is the most records a single GetRecords call returns.
+	dynamoDBMaxRecords = 1000
+	// dynamoDBDefaultBatchSize is the BatchSize of DynamoDB events that don't set one.
+	dynamoDBDefaultBatchSize = 100
+	// dynamoDBPollInterval is how long to wait before polling again shards that had no records.
+	dynamoDBPollInterval = time.Second
+
+	// shard iterator types of GetShardIterator.
+	dynamoDBTrimHorizon      = "TRIM_HORIZON"
+	dynamoDBLatest           = "LATEST"
+	dynamoDBAtSequenceNumber = "AT_SEQUENCE_NUMBER"
+
+	eventTypeDynamoDB = "DynamoDB"
+)
+
+// dynamoDBShard is a shard of a stream, as returned by DescribeStream.
+type dynamoDBShard struct {
+	ShardID       string `json:"ShardId"`       //nolint:tagliatelle
+	ParentShardID string `json:"ParentShardId"` //nolint:tagliatelle
+}
+
+// dynamoDBStreamsClient calls the DynamoDB Streams API, which DynamoDB Local and LocalStack
+// speak too.
+type dynamoDBStreamsClient struct {
+	awsJSONClient
+}
+
+func newDynamoDBStreamsClient(
+	endpoint, region string,
+	credentials awsCredentials,
+	client *http.Client,
+) dynamoDBStreamsClient {
+	return dynamoDBStreamsClient{
+		awsJSONClient{
+			endpoint:    endpoint,
+			region:      region,
+			service:     "dynamodb",
+			target:      "DynamoDBStreams_20120810",
+			credentials: credentials,
+			client:      client,
+			now:         time.Now,
+		},
+	}
+}
+
+// latestStreamARN returns the ARN of the stream of table, with DescribeTable of the DynamoDB API.
+func (c dynamoDBStreamsClient) latestStreamARN(ctx context.Context, table string) (string, error) {
+	tables := c.awsJSONClient
+	tables.target = "DynamoDB_20120810"
+
+	var out struct {
+		Table struct {
+			LatestStreamARN string `json:"LatestStreamArn"` //nolint:tagliatelle
+		} `json:"Table"` //nolint:tagliatelle
+	}
+
+	if err := tables.call(ctx, "DescribeTable", map[string]any{"TableName": table}, &out); err != nil {
+		return "", fmt.Errorf("[in lambdalocal.dynamoDBStreamsClient.latestStreamARN] %w", err)
+	}
+
+	if out.Table.LatestStreamARN == "" {
+		return "", fmt.Errorf(
+			"[in lambdalocal.dynamoDBStreamsClient.latestStreamARN] table '%s' has no stream enabled",
+			table,
+		)
+	}
+
+	return out.Table.LatestStreamARN, nil
+}
+
+// shards returns all shards of the stream, in the order DescribeStream lists them.
+func (c dynamoDBStreamsClient) shards(ctx context.Context, streamARN string) ([]dynamoDBShard, error) {
+	var shards []dynamoDBShard
+
+	in := map[string]any{"StreamArn": streamARN}
+
+	for {
+		var out struct {
+			StreamDescription struct {
+				Shards               []dynamoDBShard `json:"Shards"`               //nolint:tagliatelle
+				LastEvaluatedShardID string          `json:"LastEvaluatedShardId"` //nolint:tagliatelle
+			} `json:"StreamDescription"` //nolint:tagliatelle
+		}
+
+		if err := c.call(ctx, "DescribeStream", in, &out); err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.dynamoDBStreamsClient.shards] %w", err)
+		}
+
+		shards = append(shards, out.StreamDescription.Shards...)
+
+		if out.StreamDescription.LastEvaluatedShardID == "" {
+			return shards, nil
+		}
+
+		in["ExclusiveStartShardId"] = out.StreamDescription.LastEvaluatedShardID
+	}
+}
+
+// shardIterator returns an iterator of iteratorType for the shard. sequenceNumber is used with
+// AT_SEQUENCE_NUMBER.
+func (c dynamoDBStreamsClient) shardIterator(
+	ctx context.Context,
+	streamARN, shardID, iteratorType, sequenceNumber string,
+) (string, error) {
+	in := map[string]any{"StreamArn": streamARN, "ShardId": shardID, "ShardIteratorType": iteratorType}
+	if sequenceNumber != "" {
+		in["SequenceNumber"] = sequenceNumber
+	}
+
+	var out struct {
+		ShardIterator string `json:"ShardIterator"` //nolint:tagliatelle
+	}
+
+	if err := c.call(ctx, "GetShardIterator", in, &out); err != nil {
+		return "", fmt.Errorf("[in lambdalocal.dynamoDBStreamsClient.shardIterator] %w", err)
+	}
+
+	return out.ShardIterator, nil
+}
+
+// records returns up to limit records at iterator and the iterator of the records after them. The
+// next iterator is empty once the shard is closed and read to its end.
+func (c dynamoDBStreamsClient) records(
+	ctx context.Context,
+	iterator string,
+	limit int,
+) ([]events.DynamoDBEventRecord, string, error) {
+	var out struct {
+		Records           []events.DynamoDBEventRecord `json:"Records"`           //nolint:tagliatelle
+		NextShardIterator string                       `json:"NextShardIterator"` //nolint:tagliatelle
+	}
+
+	err := c.call(ctx, "GetRecords", map[string]any{"ShardIterator": iterator, "Limit": limit}, &out)
+	if err != nil {
+		return nil, "", fmt.Errorf("[in lambdalocal.dynamoDBStreamsClient.records] %w", err)
+	}
+
+	return out.Records, out.NextShardIterator, nil
+}
+
+// dynamoDBStreamSource is a DynamoDB event source of a function.
+type dynamoDBStreamSource struct {
+	streamARN string
+	batchSize int
+	// startingPosition is where reading the shards of the stream starts, TRIM_HORIZON or LATEST.
+	startingPosition string
+	// reportBatchItemFailures retries from the first record the lambda reports as failed.
+	reportBatchItemFailures bool
+	// pollInterval is the wait between polls of a stream that had no new records.
+	pollInterval time.Duration
+	// exitWhenEmpty stops polling once the stream has no new records.
+	exitWhenEmpty bool
+}
+
+// dynamoDBShardReader is the position of the event source in a shard.
+type dynamoDBShardReader struct {
+	dynamoDBShard
+	iterator string
+	// done is set once the shard is closed and every record of it was processed.
+	done bool
+}
+
+// RunDynamoDBStream reads the shards of the stream of source and invokes the lambda with batches of
+// up to source.batchSize records of a shard. Like an event source mapping, a batch that fails is
+// retried until it succeeds, which blocks its shard, and parent shards are read before their
+// children. With source.reportBatchItemFailures the batch is retried from the first record in the
+// batchItemFailures of the response.
+func RunDynamoDBStream(
+	ctx context.Context,
+	client dynamoDBStreamsClient,
+	lambdaRPC lambdaCaller,
+	source dynamoDBStreamSource,
+	format responseFormat,
+	logger *slog.Logger,
+) error {
+	// poll until interrupted or terminated
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info(
+		fmt.Sprintf(
+			"Reading %s from %s with batch size %d",
+			source.streamARN,
+			source.startingPosition,
+			source.batchSize,
+		),
+	)
+
+	readers := make(map[string]*dynamoDBShardReader)
+	// shards that exist when polling starts are read from the starting position, shards created
+	// later from their beginning
+	startingPosition := source.startingPosition
+
+	for {
+		shards, err := client.shards(ctx, source.streamARN)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunDynamoDBStream] %w", err)
+		}
+
+		for _, shard := range shards {
+			if _, ok := readers[shard.ShardID]; !ok {
+				iterator, err := client.shardIterator(ctx, source.streamARN, shard.ShardID, startingPosition, "")
+				if err != nil {
+					return fmt.Errorf("[in lambdalocal.RunDynamoDBStream] %w", err)
+				}
+
+				readers[shard.ShardID] = &dynamoDBShardReader{dynamoDBShard: shard, iterator: iterator}
+			}
+		}
+
+		startingPosition = dynamoDBTrimHorizon
+		processed := 0
+
+		for _, shard := range shards {
+			reader := readers[shard.ShardID]
+
+			// children wait for their parent, unless it expired from the stream
+			if parent, ok := readers[reader.ParentShardID]; reader.done || ok && !parent.done {
+				continue
+			}
+
+			n, err := processShard(ctx, client, lambdaRPC, source, reader, format, logger)
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil
+			}
+
+			if err != nil {
+				return fmt.Errorf("[in lambdalocal.RunDynamoDBStream] %w", err)
+			}
+
+			processed += n
+		}
+
+		if processed > 0 {
+			continue
+		}
+
+		if source.exitWhenEmpty {
+			logger.Info("Stream has no new records, exiting")
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(source.pollInterval):
+		}
+	}
+}
+
+// processShard invokes the lambda with the next batch of the shard of reader and returns the
+// number of records in it. A failed batch is read again on the next call.
+func processShard(
+	ctx context.Context,
+	client dynamoDBStreamsClient,
+	lambdaRPC lambdaCaller,
+	source dynamoDBStreamSource,
+	reader *dynamoDBShardReader,
+	format responseFormat,
+	logger *slog.Logger,
+) (int, error) {
+	records, next, err := readBatch(ctx, client, source, reader.iterator)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(records) == 0 {
+		reader.iterator = next
+		reader.done = next == ""
+
+		return 0, nil
+	}
+
+	for i := range records {
+		records[i].EventSourceArn = source.streamARN
+	}
+
+	retryFrom, err := invokeDynamoDB(ctx, lambdaRPC, source, records, format, logger)
+	if err != nil {
+		return 0, err
+	}
+
+	if retryFrom == "" {
+		reader.iterator = next
+		reader.done = next == ""
+
+		return len(records), nil
+	}
+
+	// read the shard again from the first record to retry
+	reader.iterator, err = client.shardIterator(
+		ctx,
+		source.streamARN,
+		reader.ShardID,
+		dynamoDBAtSequenceNumber,
+		retryFrom,
+	)
+
+	return len(records), err
+}
+
+// readBatch reads up to source.batchSize records at iterator. It returns the records and the
+// iterator of the records after them.
+func readBatch(
+	ctx context.Context,
+	client dynamoDBStreamsClient,
+	source dynamoDBStreamSource,
+	iterator string,
+) ([]events.DynamoDBEventRecord, string, error) {
+	var batch []events.DynamoDBEventRecord
+
+	for iterator != "" && len(batch) < source.batchSize {
+		records, next, err := client.records(ctx, iterator, min(dynamoDBMaxRecords, source.batchSize-len(batch)))
+		if err != nil {
+			return nil, "", err
+		}
+
+		batch = append(batch, records...)
+		iterator = next
+
+		if len(records) == 0 {
+			break
+		}
+	}
+
+	return batch, iterator, nil
+}
+
+// invokeDynamoDB invokes the lambda with records and returns the sequence number of the record
+// to retry the batch from, or an empty string when the batch succeeded.
+func invokeDynamoDB(
+	ctx context.Context,
+	lambdaRPC lambdaCaller,
+	source dynamoDBStreamSource,
+	records []events.DynamoDBEventRecord,
+	format responseFormat,
+	logger *slog.Logger,
+) (string, error) {
+	first := records[0].Change.SequenceNumber
+
+	event, err := json.Marshal(events.DynamoDBEvent{Records: records})
+	if err != nil {
+		return "", fmt.Errorf("[in lambdalocal.invokeDynamoDB] marshal event failed: %w", err)
+	}
+
+	invokeResponse, err := lambdaRPC.Invoke(ctx, event)
+	if err != nil {
+		logger.Error(
+			fmt.Sprintf("[in lambdalocal.invokeDynamoDB] invoke failed, %d records will be retried", len(records)),
+			"err",
+			err,
+		)
+
+		return first, nil
+	}
+
+	if err = printResponse(logger, invokeResponse, format); err != nil {
+		return "", fmt.Errorf("[in lambdalocal.invokeDynamoDB] printResponse failed: %w", err)
+	}
+
+	if invokeResponse.Error != nil {
+		logger.Warn(fmt.Sprintf("Lambda returned an error, %d records will be retried", len(records)))
+
+		return first, nil
+	}
+
+	if !source.reportBatchItemFailures {
+		return "", nil
+	}
+
+	sequenceNumbers := make([]string, 0, len(records))
+	for _, record := range records {
+		sequenceNumbers = append(sequenceNumbers, record.Change.SequenceNumber)
+	}
+
+	failed, err := batchItemFailures(invokeResponse.Payload, sequenceNumbers)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("%s, %d records will be retried", err, len(records)))
+
+		return first, nil
+	}
+
+	// like Lambda, the batch is checkpointed before its first failed record
+	for i, sequenceNumber := range sequenceNumbers {
+		if failed[sequenceNumber] {
+			logger.Warn(fmt.Sprintf("Lambda reported failed records, %d records will be retried", len(records)-i))
+
+			return sequenceNumber, nil
+		}
+	}
+
+	return "", nil
+}
+
+// templateDynamoDBSource returns the source with the BatchSize, StartingPosition and
+// FunctionResponseTypes of the DynamoDB event of function in the template at templatePath. The
+// stream is either the ARN of the Stream property or, when it gets the StreamArn of a table of the
+// template, the name of that table to look its stream up with.
+func templateDynamoDBSource(
+	templatePath, function string,
+	reader fileReader,
+	overrides map[string]string,
+) (dynamoDBStreamSource, string, error) {
+	data, err := reader.read(templatePath)
+	if err != nil {
+		return dynamoDBStreamSource{}, "", fmt.Errorf(
+			"[in lambdalocal.templateDynamoDBSource] read template failed: %w",
+			err,
+		)
+	}
+
+	SAMData := samTemplate{}
+	if err = unmarshalTemplate(data, overrides, &SAMData); err != nil {
+		return dynamoDBStreamSource{}, "", fmt.Errorf(
+			"[in lambdalocal.templateDynamoDBSource] unmarshal yaml failed: %w",
+			err,
+		)
+	}
+
+	resource, ok := SAMData.Resources[function]
+	if !ok {
+		return dynamoDBStreamSource{}, "", fmt.Errorf(
+			"[in lambdalocal.templateDynamoDBSource] function '%s' not found in template '%s'",
+			function,
+			templatePath,
+		)
+	}
+
+	for _, event := range resource.Properties.Events {
+		if event.Type != eventTypeDynamoDB {
+			continue
+		}
+
+		source := dynamoDBStreamSource{
+			batchSize:               dynamoDBDefaultBatchSize,
+			startingPosition:        dynamoDBLatest,
+			reportBatchItemFailures: reportsBatchItemFailures(event.Properties.FunctionResponseTypes),
+		}
+
+		if event.Properties.BatchSize > 0 {
+			source.batchSize = event.Properties.BatchSize
+		}
+
+		if event.Properties.StartingPosition != "" {
+			source.startingPosition = event.Properties.StartingPosition
+		}
+
+		if arn, ok := event.Properties.Stream.(string); ok && strings.HasPrefix(arn, "arn:") {
+			source.streamARN = arn
+
+			return source, "", nil
+		}
+
+		table, ok := SAMData.Resources[streamTable(event.Properties.Stream)]
+		if name, isString := table.Properties.TableName.(string); ok && isString && name != "" {
+			return source, name, nil
+		}
+
+		return source, "", nil
+	}
+
+	return dynamoDBStreamSource{}, "", fmt.Errorf(
+		"[in lambdalocal.templateDynamoDBSource] function '%s' has no DynamoDB event",
+		function,
+	)
+}
+
+// streamTable returns the logical ID of the table of a stream ARN written with Fn::GetAtt.
+func streamTable(v any) string {
+	switch value := v.(type) {
+	case string:
+		// the short form !GetAtt Table.StreamArn
+		if table, ok := strings.CutSuffix(value, ".StreamArn"); ok {
+			return table
+		}
+	case []any:
+		// the short form !GetAtt [Table, StreamArn]
+		if len(value) == 2 && value[1] == "StreamArn" { //nolint:mnd
+			table, _ := value[0].(string)
+
+			return table
+		}
+	case map[string]any:
+		if getAtt, ok := value["Fn::GetAtt"]; ok {
+			return streamTable(getAtt)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testStreamARN = "arn:aws:dynamodb:eu-west-1:000000000000:table/orders/stream/2024-01-01T00:00:00.000"
+
+// fakeDynamoDBStream is a stream served with the AWS JSON protocol. Shards are closed, iterators
+// are SHARD:INDEX.
+type fakeDynamoDBStream struct {
+	mu     sync.Mutex
+	shards []dynamoDBShard
+	// records are the sequence numbers of the records of each shard.
+	records map[string][]string
+}
+
+func (f *fakeDynamoDBStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var in struct {
+		ShardID           string `json:"ShardId"`           //nolint:tagliatelle
+		ShardIteratorType string `json:"ShardIteratorType"` //nolint:tagliatelle
+		SequenceNumber    string `json:"SequenceNumber"`    //nolint:tagliatelle
+		ShardIterator     string `json:"ShardIterator"`     //nolint:tagliatelle
+		Limit             int    `json:"Limit"`             //nolint:tagliatelle
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&in)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.DescribeTable":
+		_ = json.NewEncoder(w).Encode(map[string]any{"Table": map[string]any{"LatestStreamArn": testStreamARN}})
+	case "DynamoDBStreams_20120810.DescribeStream":
+		_ = json.NewEncoder(w).Encode(map[string]any{"StreamDescription": map[string]any{"Shards": f.shards}})
+	case "DynamoDBStreams_20120810.GetShardIterator":
+		index := 0
+
+		switch in.ShardIteratorType {
+		case dynamoDBLatest:
+			index = len(f.records[in.ShardID])
+		case dynamoDBAtSequenceNumber:
+			for i, sequenceNumber := range f.records[in.ShardID] {
+				if sequenceNumber == in.SequenceNumber {
+					index = i
+				}
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"ShardIterator": fmt.Sprintf("%s:%d", in.ShardID, index)})
+	case "DynamoDBStreams_20120810.GetRecords":
+		shard, position, _ := strings.Cut(in.ShardIterator, ":")
+		index, _ := strconv.Atoi(position)
+		end := min(index+in.Limit, len(f.records[shard]))
+
+		records := make([]map[string]any, 0, end-index)
+		for _, sequenceNumber := range f.records[shard][index:end] {
+			records = append(
+				records,
+				map[string]any{
+					"eventID":   sequenceNumber,
+					"eventName": "INSERT",
+					"awsRegion": "eu-west-1",
+					"dynamodb": map[string]any{
+						"Keys":           map[string]any{"id": map[string]string{"S": sequenceNumber}},
+						"SequenceNumber": sequenceNumber,
+					},
+				},
+			)
+		}
+
+		out := map[string]any{"Records": records}
+		// shards are closed, their iterator ends after the last record
+		if end < len(f.records[shard]) || len(records) > 0 {
+			out["NextShardIterator"] = fmt.Sprintf("%s:%d", shard, end)
+		}
+
+		_ = json.NewEncoder(w).Encode(out)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb#UnknownOperationException"}`))
+	}
+}
+
+func TestRunDynamoDBStream(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		shards           []dynamoDBShard
+		records          map[string][]string
+		batchSize        int
+		startingPosition string
+		reportFailures   bool
+		// lambdaResponses are the responses to the first invocations, later ones respond with {}.
+		lambdaResponses []messages.InvokeResponse
+		expectedBatches [][]string
+	}{
+		"records are batched": {
+			shards:           []dynamoDBShard{{ShardID: "shard-1"}},
+			records:          map[string][]string{"shard-1": {"1", "2", "3", "4", "5"}},
+			batchSize:        2,
+			startingPosition: dynamoDBTrimHorizon,
+			expectedBatches:  [][]string{{"1", "2"}, {"3", "4"}, {"5"}},
+		},
+		"latest skips existing records": {
+			shards:           []dynamoDBShard{{ShardID: "shard-1"}},
+			records:          map[string][]string{"shard-1": {"1", "2"}},
+			batchSize:        10,
+			startingPosition: dynamoDBLatest,
+		},
+		"parents are read before children": {
+			shards: []dynamoDBShard{{ShardID: "child", ParentShardID: "parent"}, {ShardID: "parent"}},
+			records: map[string][]string{
+				"parent": {"1", "2"},
+				"child":  {"3"},
+			},
+			batchSize:        10,
+			startingPosition: dynamoDBTrimHorizon,
+			expectedBatches:  [][]string{{"1", "2"}, {"3"}},
+		},
+		"failed batches are retried": {
+			shards:           []dynamoDBShard{{ShardID: "shard-1"}},
+			records:          map[string][]string{"shard-1": {"1", "2"}},
+			batchSize:        10,
+			startingPosition: dynamoDBTrimHorizon,
+			lambdaResponses: []messages.InvokeResponse{
+				{Error: &messages.InvokeResponse_Error{Message: "boom"}},
+			},
+			expectedBatches: [][]string{{"1", "2"}, {"1", "2"}},
+		},
+		"batches are retried from the first reported failure": {
+			shards:           []dynamoDBShard{{ShardID: "shard-1"}},
+			records:          map[string][]string{"shard-1": {"1", "2", "3"}},
+			batchSize:        10,
+			startingPosition: dynamoDBTrimHorizon,
+			reportFailures:   true,
+			lambdaResponses: []messages.InvokeResponse{
+				{Payload: []byte(`{"batchItemFailures":[{"itemIdentifier":"3"},{"itemIdentifier":"2"}]}`)},
+			},
+			expectedBatches: [][]string{{"1", "2", "3"}, {"2", "3"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				server := httptest.NewServer(&fakeDynamoDBStream{shards: tc.shards, records: tc.records})
+
+				t.Cleanup(server.Close)
+
+				client := newDynamoDBStreamsClient(
+					server.URL,
+					"eu-west-1",
+					awsCredentials{accessKeyID: "id", secretAccessKey: "secret"},
+					server.Client(),
+				)
+
+				var batches [][]string
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				collect := func(args mock.Arguments) {
+					var event events.DynamoDBEvent
+					require.NoError(t, json.Unmarshal(args.Get(0).([]byte), &event))
+
+					var batch []string
+
+					for _, record := range event.Records {
+						assert.Equal(t, testStreamARN, record.EventSourceArn)
+						assert.Equal(t, record.Change.SequenceNumber, record.Change.Keys["id"].String())
+
+						batch = append(batch, record.Change.SequenceNumber)
+					}
+
+					batches = append(batches, batch)
+				}
+
+				for _, response := range tc.lambdaResponses {
+					mockLambdaRPC.On("Invoke", mock.Anything).Run(collect).Return(response, nil).Once()
+				}
+
+				mockLambdaRPC.On("Invoke", mock.Anything).
+					Run(collect).
+					Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+
+				source := dynamoDBStreamSource{
+					streamARN:               testStreamARN,
+					batchSize:               tc.batchSize,
+					startingPosition:        tc.startingPosition,
+					reportBatchItemFailures: tc.reportFailures,
+					exitWhenEmpty:           true,
+				}
+
+				err := RunDynamoDBStream(
+					context.Background(),
+					client,
+					mockLambdaRPC,
+					source,
+					responseFormat{},
+					slog.Default(),
+				)
+				require.NoError(t, err)
+
+				assert.Equal(t, tc.expectedBatches, batches)
+			},
+		)
+	}
+}
+
+func TestDynamoDBLatestStreamARN(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&fakeDynamoDBStream{})
+
+	t.Cleanup(server.Close)
+
+	client := newDynamoDBStreamsClient(server.URL, "eu-west-1", awsCredentials{}, server.Client())
+
+	arn, err := client.latestStreamARN(context.Background(), "orders")
+	require.NoError(t, err)
+	assert.Equal(t, testStreamARN, arn)
+}
+
+func TestTemplateDynamoDBSource(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template       string
+		expectedSource dynamoDBStreamSource
+		expectedTable  string
+		expectedErr    string
+	}{
+		"stream of a table of the template": {
+			template: `
+Resources:
+  Orders:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      TableName: !Sub ${AWS::StackName}-orders
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Stream:
+          Type: DynamoDB
+          Properties:
+            Stream: !GetAtt Orders.StreamArn
+            StartingPosition: TRIM_HORIZON
+            BatchSize: 5
+            FunctionResponseTypes:
+              - ReportBatchItemFailures
+`,
+			expectedSource: dynamoDBStreamSource{
+				batchSize:               5,
+				startingPosition:        dynamoDBTrimHorizon,
+				reportBatchItemFailures: true,
+			},
+			expectedTable: "lambdalocal-orders",
+		},
+		"stream ARN": {
+			template: `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Stream:
+          Type: DynamoDB
+          Properties:
+            Stream: ` + testStreamARN + `
+`,
+			expectedSource: dynamoDBStreamSource{
+				streamARN:        testStreamARN,
+				batchSize:        dynamoDBDefaultBatchSize,
+				startingPosition: dynamoDBLatest,
+			},
+		},
+		"no DynamoDB event": {
+			template: `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Queue:
+          Type: SQS
+`,
+			expectedErr: "function 'Fn' has no DynamoDB event",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := new(mockOSFileReader)
+				reader.On("read", "template.yaml").Return([]byte(tc.template), nil)
+
+				source, table, err := templateDynamoDBSource(
+					"template.yaml",
+					"Fn",
+					reader,
+					nil,
+				)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedSource, source)
+				assert.Equal(t, tc.expectedTable, table)
+			},
+		)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// envFileKeyRegex matches the names of the variables of a dotenv file.
+var envFileKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`) //nolint:gochecknoglobals
+
+// loadEnvFiles returns the variables of the dotenv files at paths. A variable set by several files
+// gets the value of the last one.
+func loadEnvFiles(paths []string, reader fileReader) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	for _, path := range paths {
+		data, err := reader.read(path)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.loadEnvFiles] read file failed: %w", err)
+		}
+
+		fileVariables, err := parseEnvFile(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.loadEnvFiles] parse '%s' failed: %w", path, err)
+		}
+
+		maps.Copy(variables, fileVariables)
+	}
+
+	return variables, nil
+}
+
+// environ returns variables as the KEY=VALUE entries of a process environment, sorted by key.
+func environ(variables map[string]string) []string {
+	env := make([]string, 0, len(variables))
+	for _, key := range slices.Sorted(maps.Keys(variables)) {
+		env = append(env, key+"="+variables[key])
+	}
+
+	return env
+}
+
+// parseEnvFile parses the KEY=VALUE lines of a dotenv file. Lines starting with # are comments and
+// an `export ` prefix is allowed. Double quoted values are unescaped, like "line\nline", single
+// quoted ones are taken literally, and unquoted ones end at a ` #` comment.
+func parseEnvFile(data string) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+
+		if !ok || !envFileKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE. Got '%s'", i+1, line)
+		}
+
+		value, err := envFileValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: value of %s: %w", i+1, key, err)
+		}
+
+		variables[key] = value
+	}
+
+	return variables, nil
+}
+
+func envFileValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := closingQuote(value)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated double quote in %s", value)
+		}
+
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %s: %w", value, err)
+		}
+
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote in %s", value)
+		}
+
+		return value[1 : end+1], nil
+	default:
+		if comment := strings.Index(value, " #"); comment >= 0 {
+			value = value[:comment]
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// closingQuote returns the index of the double quote closing value, skipping escaped ones, or -1.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
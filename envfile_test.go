@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data     string
+		expected map[string]string
+		wantErr  bool
+	}{
+		"plain values": {
+			data:     "# database\nDB_HOST=localhost\n\nDB_PORT = 5432\n",
+			expected: map[string]string{"DB_HOST": "localhost", "DB_PORT": "5432"},
+		},
+		"export prefix": {
+			data:     "export API_KEY=secret",
+			expected: map[string]string{"API_KEY": "secret"},
+		},
+		"inline comment": {
+			data:     "STAGE=dev # local only\nCOLOR=#fff",
+			expected: map[string]string{"STAGE": "dev", "COLOR": "#fff"},
+		},
+		"double quotes": {
+			data:     `GREETING="hello \"world\"\nbye" # comment`,
+			expected: map[string]string{"GREETING": "hello \"world\"\nbye"},
+		},
+		"single quotes": {
+			data:     `PATTERN='a\nb # c'`,
+			expected: map[string]string{"PATTERN": `a\nb # c`},
+		},
+		"empty value": {
+			data:     "EMPTY=",
+			expected: map[string]string{"EMPTY": ""},
+		},
+		"missing equals": {
+			data:    "DB_HOST",
+			wantErr: true,
+		},
+		"invalid key": {
+			data:    "DB-HOST=localhost",
+			wantErr: true,
+		},
+		"unterminated quote": {
+			data:    `TOKEN="abc`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				variables, err := parseEnvFile(tc.data)
+				if tc.wantErr {
+					assert.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, variables)
+			},
+		)
+	}
+}
+
+func TestLoadEnvFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+
+	require.NoError(t, os.WriteFile(base, []byte("STAGE=dev\nTABLE_NAME=orders\n"), 0o600))
+	require.NoError(t, os.WriteFile(local, []byte("STAGE=local\nSECRET=s3cr3t\n"), 0o600))
+
+	variables, err := loadEnvFiles([]string{base, local}, osFileReader{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SECRET=s3cr3t", "STAGE=local", "TABLE_NAME=orders"}, environ(variables))
+
+	_, err = loadEnvFiles([]string{filepath.Join(dir, "missing")}, osFileReader{})
+	assert.Error(t, err)
+}
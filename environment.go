@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// samEnvironment is the Environment of a function or of the Globals. The variables are decoded as
+// nodes, which keep the tags of the intrinsic functions that couldn't be resolved.
+type samEnvironment struct {
+	Variables map[string]yaml.Node `yaml:"Variables"` //nolint:tagliatelle
+}
+
+// functionEnvironments returns the environment variables of the functions of the template at
+// templatePath, the Variables of their Environment merged over the ones of the Globals. Values
+// that depend on deployed resources, like Fn::GetAtt, can't be resolved locally and are left out.
+// Terraform templates have none.
+func functionEnvironments(
+	templatePath string,
+	reader fileReader,
+	overrides map[string]string,
+) (map[string]map[string]string, error) {
+	yamlFile, err := reader.read(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.functionEnvironments] read file failed: %w", err)
+	}
+
+	environments := make(map[string]map[string]string)
+
+	if isTerraformJSON(yamlFile) {
+		return environments, nil
+	}
+
+	index, err := templates.index(templatePath, yamlFile, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.functionEnvironments] unmarshal yaml failed: %w", err)
+	}
+
+	for name, resource := range index.sam.Resources {
+		if resource.Type != "AWS::Serverless::Function" {
+			continue
+		}
+
+		environment := make(map[string]string)
+
+		for _, variables := range []map[string]yaml.Node{
+			index.sam.Globals.Function.Environment.Variables,
+			resource.Properties.Environment.Variables,
+		} {
+			for key, value := range variables {
+				if value, ok := environmentValue(value); ok {
+					environment[key] = value
+				} else {
+					delete(environment, key)
+				}
+			}
+		}
+
+		environments[name] = environment
+	}
+
+	return environments, nil
+}
+
+// environmentValue returns the value of an environment variable of a template, a scalar that
+// isn't an unresolved intrinsic function like !GetAtt.
+func environmentValue(node yaml.Node) (string, bool) {
+	if node.Kind != yaml.ScalarNode || isIntrinsicTag(node.Tag) {
+		return "", false
+	}
+
+	return node.Value, true
+}
+
+// processEnvironment returns the environment variables of function for the process serving it.
+// Without a function, the process serves every function of the template: it gets the variables of
+// the only one, or the variables all of them set to the same value, like the ones of the Globals.
+func processEnvironment(environments map[string]map[string]string, function string, logger *slog.Logger) []string {
+	var environment map[string]string
+
+	switch {
+	case function != "":
+		environment = environments[function]
+	case len(environments) == 1:
+		for _, only := range environments {
+			environment = only
+		}
+	default:
+		environment = make(map[string]string)
+
+		var differing []string
+
+		for _, variables := range environments {
+			for key, value := range variables {
+				environment[key] = value
+			}
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(environment)) {
+			for _, variables := range environments {
+				if value, ok := variables[key]; !ok || value != environment[key] {
+					differing = append(differing, key)
+					delete(environment, key)
+
+					break
+				}
+			}
+		}
+
+		if len(differing) > 0 {
+			logger.Warn(
+				"Environment variables differ between the functions served by the lambda process, they aren't set",
+				"variables", differing,
+			)
+		}
+	}
+
+	return environ(environment)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionEnvironments(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Parameters:
+  Stage:
+    Type: String
+    Default: dev
+Globals:
+  Function:
+    Environment:
+      Variables:
+        LOG_LEVEL: info
+        STAGE: !Ref Stage
+Resources:
+  Orders:
+    Type: AWS::Serverless::Function
+    Properties:
+      Environment:
+        Variables:
+          LOG_LEVEL: debug
+          TABLE_NAME: !Sub orders-${Stage}
+          PAGE_SIZE: 25
+          CACHE: true
+          QUEUE_ARN: !GetAtt Queue.Arn
+          QUEUE_URL:
+            Fn::GetAtt: [Queue, QueueUrl]
+  Users:
+    Type: AWS::Serverless::Function
+  Queue:
+    Type: AWS::SQS::Queue
+`
+
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(template), 0o600))
+
+	environments, err := functionEnvironments(path, osFileReader{}, map[string]string{"Stage": "prod"})
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		map[string]map[string]string{
+			"Orders": {
+				"LOG_LEVEL":  "debug",
+				"STAGE":      "prod",
+				"TABLE_NAME": "orders-prod",
+				"PAGE_SIZE":  "25",
+				"CACHE":      "true",
+			},
+			"Users": {"LOG_LEVEL": "info", "STAGE": "prod"},
+		},
+		environments,
+	)
+
+	_, err = functionEnvironments(filepath.Join(t.TempDir(), "missing.yaml"), osFileReader{}, nil)
+	assert.Error(t, err)
+}
+
+func TestProcessEnvironment(t *testing.T) {
+	t.Parallel()
+
+	environments := map[string]map[string]string{
+		"Orders": {"STAGE": "prod", "LOG_LEVEL": "debug", "TABLE_NAME": "orders"},
+		"Users":  {"STAGE": "prod", "LOG_LEVEL": "info"},
+	}
+
+	tests := map[string]struct {
+		environments map[string]map[string]string
+		function     string
+		expected     []string
+	}{
+		"function": {
+			environments: environments,
+			function:     "Orders",
+			expected:     []string{"LOG_LEVEL=debug", "STAGE=prod", "TABLE_NAME=orders"},
+		},
+		"only function": {
+			environments: map[string]map[string]string{"Users": environments["Users"]},
+			expected:     []string{"LOG_LEVEL=info", "STAGE=prod"},
+		},
+		"variables shared by every function": {
+			environments: environments,
+			expected:     []string{"STAGE=prod"},
+		},
+		"unknown function": {
+			environments: environments,
+			function:     "Payments",
+			expected:     []string{},
+		},
+		"no functions": {
+			expected: []string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				env := processEnvironment(tc.environments, tc.function, slog.New(slog.DiscardHandler))
+				assert.Equal(t, tc.expected, env)
+			},
+		)
+	}
+}
@@ -2,17 +2,35 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+const (
+	defaultEventFile = "default.json"
+	// stdinEventFile is the --file that reads the event from stdin.
+	stdinEventFile = "-"
 )
 
 func RunLambdaEvent(
-	_ context.Context,
+	ctx context.Context,
 	w io.Writer,
 	lambdaRPC lambdaCaller,
 	event string,
-	parseJSON bool,
+	format responseFormat,
+	failOnError bool,
+	outputFile string,
+	expectation *goldenFile,
 	logger *slog.Logger,
 ) error {
 	_, _ = fmt.Fprintln(w, line)
@@ -21,18 +39,134 @@ func RunLambdaEvent(
 
 	logger.Debug("Invoking lambda event")
 
-	invokeResponse, err := lambdaRPC.Invoke([]byte(event))
+	// Ctrl+C cancels the invocation
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	invokeResponse, err := lambdaRPC.Invoke(ctx, []byte(event))
 	if err != nil {
 		return fmt.Errorf("[in lambdalocal.RunLambdaEvent] invoke failed: %w", err)
 	}
 
-	if err = printResponse(logger, invokeResponse, parseJSON); err != nil {
+	if err = printResponse(logger, invokeResponse, format); err != nil {
 		return fmt.Errorf("[in lambdalocal.RunLambdaEvent] printResponse failed: %w", err)
 	}
 
+	if outputFile != "" {
+		if err = writeResponsePayload(outputFile, invokeResponse); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunLambdaEvent] %w", err)
+		}
+
+		logger.Info("Wrote response payload to " + outputFile)
+	}
+
+	if expectation != nil {
+		if err = expectation.check(w, invokeResponse); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunLambdaEvent] %w", err)
+		}
+
+		logger.Info("Response matches " + expectation.path)
+	}
+
 	logger.Info("Lambda invocation complete, Exiting...")
 
 	_, _ = fmt.Fprintln(w, line)
 
+	if failOnError {
+		if err = invocationFailure(invokeResponse); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunLambdaEvent] %w", err)
+		}
+	}
+
 	return nil
 }
+
+// errInvocationFailed is returned with --fail-on-error when the handler failed.
+var errInvocationFailed = errors.New("invocation failed")
+
+// invocationFailure returns why an invocation failed: the handler returned an error, or an API
+// response with a status code outside of 2xx. It returns nil for successful invocations.
+func invocationFailure(invokeResponse messages.InvokeResponse) error {
+	if invokeResponse.Error != nil {
+		return fmt.Errorf("%w: the handler returned error '%s'", errInvocationFailed, invokeResponse.Error.Message)
+	}
+
+	var response struct {
+		StatusCode *int `json:"statusCode"`
+	}
+
+	if json.Unmarshal(invokeResponse.Payload, &response) != nil || response.StatusCode == nil {
+		return nil
+	}
+
+	if *response.StatusCode < http.StatusOK || *response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: the handler returned status code %d", errInvocationFailed, *response.StatusCode)
+	}
+
+	return nil
+}
+
+// resolveDefaultEvent loads the default event of function. The event is read from the path set in
+// the function's Metadata.LambdaLocal.DefaultEvent key, or from events/<function>/default.json,
+// both relative to the template.
+func resolveDefaultEvent(templatePath, function string, reader fileReader) (string, error) {
+	yamlFile, err := reader.read(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("[in lambdalocal.resolveDefaultEvent] read template failed: %w", err)
+	}
+
+	SAMData := samTemplate{}
+	if err = unmarshalTemplate(yamlFile, nil, &SAMData); err != nil {
+		return "", fmt.Errorf("[in lambdalocal.resolveDefaultEvent] unmarshal yaml failed: %w", err)
+	}
+
+	resource, ok := SAMData.Resources[function]
+	if !ok {
+		return "", fmt.Errorf(
+			"[in lambdalocal.resolveDefaultEvent] function '%s' not found in template '%s'",
+			function,
+			templatePath,
+		)
+	}
+
+	eventPath := resource.Metadata.LambdaLocal.DefaultEvent
+	if eventPath == "" {
+		eventPath = filepath.Join("events", function, defaultEventFile)
+	}
+
+	eventPath = filepath.Join(filepath.Dir(templatePath), eventPath)
+
+	event, err := reader.read(eventPath)
+	if err != nil {
+		return "", fmt.Errorf("[in lambdalocal.resolveDefaultEvent] read default event failed: %w", err)
+	}
+
+	return string(event), nil
+}
+
+// readEvent reads the event of the file at path, or from stdin when path is "-".
+func readEvent(path string, stdin io.Reader, reader fileReader) (string, error) {
+	if path == stdinEventFile {
+		event, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("[in lambdalocal.readEvent] read stdin failed: %w", err)
+		}
+
+		return string(event), nil
+	}
+
+	event, err := reader.read(path)
+	if err != nil {
+		return "", fmt.Errorf("[in lambdalocal.readEvent] read event file failed: %w", err)
+	}
+
+	return string(event), nil
+}
+
+// isPiped reports whether f is a pipe or a redirected file rather than a terminal, so an event
+// piped to lambdalocal can be read from it without waiting for input.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -22,7 +24,7 @@ type MockLambdaCaller struct {
 	mock.Mock
 }
 
-func (m *MockLambdaCaller) Invoke(data []byte) (messages.InvokeResponse, error) {
+func (m *MockLambdaCaller) Invoke(_ context.Context, data []byte, _ ...Option) (messages.InvokeResponse, error) {
 	args := m.Called(data)
 	return args.Get(0).(messages.InvokeResponse), args.Error(1) //nolint:wrapcheck,forcetypeassert
 }
@@ -200,7 +202,17 @@ func TestRunLambdaEvent(t *testing.T) { //nolint:funlen
 
 			mockLambdaRPC.On("Invoke", []byte(tc.event)).Return(tc.invokeResp, tc.invokeErr)
 
-			err := RunLambdaEvent(context.Background(), &buf, mockLambdaRPC, tc.event, tc.parseJSON, logger)
+			err := RunLambdaEvent(
+				context.Background(),
+				&buf,
+				mockLambdaRPC,
+				tc.event,
+				responseFormat{parseJSON: tc.parseJSON},
+				false,
+				"",
+				nil,
+				logger,
+			)
 
 			if tc.expectedErr == nil {
 				require.NoError(t, err)
@@ -218,3 +230,220 @@ func TestRunLambdaEvent(t *testing.T) { //nolint:funlen
 		})
 	}
 }
+
+func TestResolveDefaultEvent(t *testing.T) {
+	t.Parallel()
+
+	template := []byte(`
+Resources:
+  OrderFn:
+    Type: AWS::Serverless::Function
+    Metadata:
+      LambdaLocal:
+        DefaultEvent: fixtures/order.json
+  HelloFn:
+    Type: AWS::Serverless::Function
+`)
+
+	tests := map[string]struct {
+		function       string
+		eventPath      string
+		eventReturn    []any
+		expectedEvent  string
+		expectedErrStr string
+	}{
+		"event from template metadata": {
+			function:      "OrderFn",
+			eventPath:     "project/fixtures/order.json",
+			eventReturn:   []any{[]byte(`{"order": 1}`), nil},
+			expectedEvent: `{"order": 1}`,
+		},
+		"event from events directory convention": {
+			function:      "HelloFn",
+			eventPath:     "project/events/HelloFn/default.json",
+			eventReturn:   []any{[]byte(`{"hello": "world"}`), nil},
+			expectedEvent: `{"hello": "world"}`,
+		},
+		"unknown function": {
+			function:       "MissingFn",
+			expectedErrStr: "function 'MissingFn' not found",
+		},
+		"missing event file": {
+			function:       "HelloFn",
+			eventPath:      "project/events/HelloFn/default.json",
+			eventReturn:    []any{[]byte{}, errors.New("no such file")},
+			expectedErrStr: "read default event failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "project/template.yaml").Return(template, nil).Once()
+
+				if tc.eventPath != "" {
+					mockReader.On("read", tc.eventPath).Return(tc.eventReturn...).Once()
+				}
+
+				event, err := resolveDefaultEvent("project/template.yaml", tc.function, mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedEvent, event)
+				}
+
+				mockReader.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path           string
+		stdin          string
+		fileReturn     []any
+		expectedEvent  string
+		expectedErrStr string
+	}{
+		"file": {
+			path:          "events/order.json",
+			fileReturn:    []any{[]byte(`{"order": 1}`), nil},
+			expectedEvent: `{"order": 1}`,
+		},
+		"stdin": {
+			path:          "-",
+			stdin:         `{"piped": true}`,
+			expectedEvent: `{"piped": true}`,
+		},
+		"missing file": {
+			path:           "events/missing.json",
+			fileReturn:     []any{[]byte{}, errors.New("no such file")},
+			expectedErrStr: "read event file failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				if tc.fileReturn != nil {
+					mockReader.On("read", tc.path).Return(tc.fileReturn...).Once()
+				}
+
+				event, err := readEvent(tc.path, strings.NewReader(tc.stdin), mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedEvent, event)
+				}
+
+				mockReader.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+func TestInvocationFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		response messages.InvokeResponse
+		expected string
+	}{
+		"handler error": {
+			response: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "boom"},
+			},
+			expected: "the handler returned error 'boom'",
+		},
+		"server error status code": {
+			response: messages.InvokeResponse{Payload: []byte(`{"statusCode":500,"body":"oops"}`)},
+			expected: "the handler returned status code 500",
+		},
+		"redirect status code": {
+			response: messages.InvokeResponse{Payload: []byte(`{"statusCode":302}`)},
+			expected: "the handler returned status code 302",
+		},
+		"success status code": {
+			response: messages.InvokeResponse{Payload: []byte(`{"statusCode":201}`)},
+		},
+		"no status code": {
+			response: messages.InvokeResponse{Payload: []byte(`{"message":"hello"}`)},
+		},
+		"non-JSON payload": {
+			response: messages.InvokeResponse{Payload: []byte(`hello`)},
+		},
+		"no payload": {
+			response: messages.InvokeResponse{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				err := invocationFailure(tc.response)
+				if tc.expected == "" {
+					assert.NoError(t, err)
+					return
+				}
+
+				require.ErrorIs(t, err, errInvocationFailed)
+				assert.ErrorContains(t, err, tc.expected)
+			},
+		)
+	}
+}
+
+func TestRunLambdaEventFailOnError(t *testing.T) {
+	t.Parallel()
+
+	response := messages.InvokeResponse{Payload: []byte(`{"statusCode":500}`)}
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	for _, failOnError := range []bool{false, true} {
+		mockLambdaRPC := new(MockLambdaCaller)
+		mockLambdaRPC.On("Invoke", []byte(`{}`)).Return(response, nil)
+
+		err := RunLambdaEvent(
+			context.Background(), &bytes.Buffer{}, mockLambdaRPC, `{}`, responseFormat{}, failOnError, "", nil, logger,
+		)
+		if failOnError {
+			require.ErrorIs(t, err, errInvocationFailed)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestRunLambdaEventOutputFile(t *testing.T) {
+	t.Parallel()
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	mockLambdaRPC.On("Invoke", []byte(`{}`)).Return(messages.InvokeResponse{Payload: []byte(`{"id":1}`)}, nil)
+
+	outputFile := filepath.Join(t.TempDir(), "result.json")
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	err := RunLambdaEvent(
+		context.Background(), &bytes.Buffer{}, mockLambdaRPC, `{}`, responseFormat{}, false, outputFile, nil, logger,
+	)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(data))
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	eventTypeEventBridgeRule = "EventBridgeRule"
+	// eventTypeCloudWatchEvent is the older name of EventBridgeRule events.
+	eventTypeCloudWatchEvent = "CloudWatchEvent"
+	// eventBridgeDefaultBus is the bus of rules and events that don't name one.
+	eventBridgeDefaultBus = "default"
+	// eventBridgeMaxEntries is the maximum number of entries of a PutEvents call.
+	eventBridgeMaxEntries = 10
+	eventBridgePutEvents  = "AWSEvents.PutEvents"
+)
+
+// eventBridgeRule is an EventBridgeRule event of a function of the template.
+type eventBridgeRule struct {
+	name     string
+	function string
+	bus      string
+	pattern  eventPattern
+	// input is the JSON the function is invoked with instead of the event.
+	input string
+	// inputPath selects the part of the event the function is invoked with, like $.detail.
+	inputPath string
+}
+
+// eventBridgeEntry is an entry of a PutEvents call.
+type eventBridgeEntry struct {
+	Source       string   `json:"Source"`       //nolint:tagliatelle
+	DetailType   string   `json:"DetailType"`   //nolint:tagliatelle
+	Detail       string   `json:"Detail"`       //nolint:tagliatelle
+	EventBusName string   `json:"EventBusName"` //nolint:tagliatelle
+	Resources    []string `json:"Resources"`    //nolint:tagliatelle
+	// Time is in epoch seconds, like the timestamps of the AWS JSON protocol.
+	Time *float64 `json:"Time"` //nolint:tagliatelle
+}
+
+// eventBridgeResultEntry is the result of an entry of a PutEvents call.
+type eventBridgeResultEntry struct {
+	EventID      string `json:"EventId,omitempty"`      //nolint:tagliatelle
+	ErrorCode    string `json:"ErrorCode,omitempty"`    //nolint:tagliatelle
+	ErrorMessage string `json:"ErrorMessage,omitempty"` //nolint:tagliatelle
+}
+
+// eventBridgeHandler serves the PutEvents action of the EventBridge API, so SDKs and the AWS CLI
+// can put events with it as their endpoint. Every rule whose bus and pattern match an event
+// invokes its function.
+type eventBridgeHandler struct {
+	rules []eventBridgeRule
+	// lambdaRPC invokes functions without a caller of their own in functionCallers.
+	lambdaRPC       lambdaCaller
+	functionCallers map[string]lambdaCaller
+	format          responseFormat
+	logger          *slog.Logger
+}
+
+func (h eventBridgeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != eventBridgePutEvents {
+		writeEventBridgeError(
+			w,
+			"UnknownOperationException",
+			fmt.Sprintf("operation '%s' is not supported, only PutEvents is", target),
+		)
+
+		return
+	}
+
+	var in struct {
+		Entries []eventBridgeEntry `json:"Entries"` //nolint:tagliatelle
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeEventBridgeError(w, "ValidationException", "invalid request: "+err.Error())
+
+		return
+	}
+
+	if len(in.Entries) == 0 || len(in.Entries) > eventBridgeMaxEntries {
+		writeEventBridgeError(
+			w,
+			"ValidationException",
+			fmt.Sprintf("expected 1 to %d entries, got %d", eventBridgeMaxEntries, len(in.Entries)),
+		)
+
+		return
+	}
+
+	out := struct {
+		FailedEntryCount int                      `json:"FailedEntryCount"` //nolint:tagliatelle
+		Entries          []eventBridgeResultEntry `json:"Entries"`          //nolint:tagliatelle
+	}{Entries: make([]eventBridgeResultEntry, 0, len(in.Entries))}
+
+	for _, entry := range in.Entries {
+		result := h.put(r.Context(), entry)
+		if result.ErrorCode != "" {
+			out.FailedEntryCount++
+		}
+
+		out.Entries = append(out.Entries, result)
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// put invokes the functions of the rules matching entry. Like EventBridge, failed invocations
+// don't fail the entry, they are logged.
+func (h eventBridgeHandler) put(ctx context.Context, entry eventBridgeEntry) eventBridgeResultEntry {
+	if entry.Source == "" || entry.DetailType == "" || entry.Detail == "" {
+		return eventBridgeResultEntry{
+			ErrorCode:    "InvalidArgument",
+			ErrorMessage: "Source, DetailType and Detail are required",
+		}
+	}
+
+	var detail map[string]any
+	if err := json.Unmarshal([]byte(entry.Detail), &detail); err != nil {
+		return eventBridgeResultEntry{ErrorCode: "MalformedDetail", ErrorMessage: "Detail isn't a JSON object"}
+	}
+
+	event := events.EventBridgeEvent{
+		Version:    "0",
+		ID:         uuid.New().String(),
+		DetailType: entry.DetailType,
+		Source:     entry.Source,
+		AccountID:  pseudoParameters["AWS::AccountId"],
+		Time:       time.Now().UTC().Truncate(time.Second),
+		Region:     pseudoParameters["AWS::Region"],
+		Resources:  entry.Resources,
+		Detail:     json.RawMessage(entry.Detail),
+	}
+
+	if entry.Time != nil {
+		seconds, fraction := math.Modf(*entry.Time)
+		event.Time = time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC()
+	}
+
+	if event.Resources == nil {
+		event.Resources = []string{}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return eventBridgeResultEntry{ErrorCode: "InternalException", ErrorMessage: err.Error()}
+	}
+
+	// patterns match the event as JSON, with the field names lambdas see
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+
+	bus := eventBusName(entry.EventBusName)
+	matched := 0
+
+	for _, rule := range h.rules {
+		if rule.bus != bus || !rule.pattern.matches(fields) {
+			continue
+		}
+
+		matched++
+
+		h.invoke(ctx, rule, event.ID, data, fields)
+	}
+
+	if matched == 0 {
+		h.logger.Info(fmt.Sprintf("Event %s from %s matched no rule of bus %s", event.ID, event.Source, bus))
+	}
+
+	return eventBridgeResultEntry{EventID: event.ID}
+}
+
+// invoke invokes the function of rule with the event, or the rule's input.
+func (h eventBridgeHandler) invoke(
+	ctx context.Context,
+	rule eventBridgeRule,
+	id string,
+	data []byte,
+	fields map[string]any,
+) {
+	logger := h.logger.With("rule", rule.name)
+	logger.Info(fmt.Sprintf("Invoking %s with event %s", rule.function, id))
+
+	payload, err := rule.payload(data, fields)
+	if err != nil {
+		logger.Error("[in lambdalocal.eventBridgeHandler.invoke] create input failed", "err", err)
+
+		return
+	}
+
+	caller := h.lambdaRPC
+	if functionCaller, ok := h.functionCallers[rule.function]; ok {
+		caller = functionCaller
+	}
+
+	invokeResponse, err := caller.Invoke(ctx, payload)
+	if err != nil {
+		logger.Error("[in lambdalocal.eventBridgeHandler.invoke] invoke failed", "err", err)
+
+		return
+	}
+
+	if err = printResponse(logger, invokeResponse, h.format); err != nil {
+		logger.Error("[in lambdalocal.eventBridgeHandler.invoke] printResponse failed", "err", err)
+	}
+}
+
+// payload returns what the function of the rule is invoked with: the Input of the rule, the part
+// of the event at its InputPath, or the event.
+func (r eventBridgeRule) payload(data []byte, fields map[string]any) ([]byte, error) {
+	if r.input != "" {
+		return []byte(r.input), nil
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.inputPath, "$"), ".")
+	if path == "" {
+		return data, nil
+	}
+
+	field, err := jsonField(fields, path)
+	if err != nil {
+		return nil, fmt.Errorf("InputPath %s: %w", r.inputPath, err)
+	}
+
+	return json.Marshal(field) //nolint:wrapcheck
+}
+
+// eventBusName returns the name of a bus given by name or ARN.
+func eventBusName(bus string) string {
+	if bus == "" {
+		return eventBridgeDefaultBus
+	}
+
+	// bus ARNs are arn:aws:events:REGION:ACCOUNT:event-bus/NAME
+	if _, name, ok := strings.Cut(bus, ":event-bus/"); ok {
+		return name
+	}
+
+	return bus
+}
+
+// writeEventBridgeError writes an error response of the AWS JSON protocol.
+func writeEventBridgeError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+
+	_ = json.NewEncoder(w).Encode(
+		struct {
+			Type    string `json:"__type"`  //nolint:tagliatelle
+			Message string `json:"message"` //nolint:tagliatelle
+		}{Type: code, Message: message},
+	)
+}
+
+// templateEventBridgeRules returns the EventBridgeRule events of the functions in the template at
+// templatePath, sorted by name. Rules are named by the logical IDs of their function and event.
+// Buses given as a Ref to an AWS::Events::EventBus of the template take its Name.
+func templateEventBridgeRules(
+	templatePath string,
+	reader fileReader,
+	overrides map[string]string,
+) ([]eventBridgeRule, error) {
+	data, err := reader.read(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateEventBridgeRules] read template failed: %w", err)
+	}
+
+	SAMData := samTemplate{}
+	if err = unmarshalTemplate(data, overrides, &SAMData); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateEventBridgeRules] unmarshal yaml failed: %w", err)
+	}
+
+	var rules []eventBridgeRule
+
+	for function, resource := range SAMData.Resources {
+		for name, event := range resource.Properties.Events {
+			if event.Type != eventTypeEventBridgeRule && event.Type != eventTypeCloudWatchEvent {
+				continue
+			}
+
+			pattern, err := parseEventPattern(event.Properties.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"[in lambdalocal.templateEventBridgeRules] Pattern of event '%s' of '%s': %w",
+					name,
+					function,
+					err,
+				)
+			}
+
+			if event.Properties.Input != "" && !json.Valid([]byte(event.Properties.Input)) {
+				return nil, fmt.Errorf(
+					"[in lambdalocal.templateEventBridgeRules] Input of event '%s' of '%s' isn't JSON",
+					name,
+					function,
+				)
+			}
+
+			bus, _ := event.Properties.EventBusName.(string)
+			if busName, ok := SAMData.Resources[bus].Properties.Name.(string); ok && busName != "" {
+				bus = busName
+			}
+
+			rules = append(
+				rules,
+				eventBridgeRule{
+					name:      function + name,
+					function:  function,
+					bus:       eventBusName(bus),
+					pattern:   pattern,
+					input:     event.Properties.Input,
+					inputPath: event.Properties.InputPath,
+				},
+			)
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf(
+			"[in lambdalocal.templateEventBridgeRules] template '%s' has no EventBridgeRule event",
+			templatePath,
+		)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].name < rules[j].name
+	})
+
+	return rules, nil
+}
+
+// RunEventBridge serves handler on addr until interrupted or terminated.
+func RunEventBridge(ctx context.Context, addr string, handler eventBridgeHandler, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunEventBridge] listen on '%s' failed: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second, //nolint:mnd
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDuration)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	for _, rule := range handler.rules {
+		logger.Info(fmt.Sprintf("Rule %s on bus %s invokes %s", rule.name, rule.bus, rule.function))
+	}
+
+	logger.Info(
+		fmt.Sprintf(
+			"Put events to http://%s, like aws events put-events --endpoint-url http://%s",
+			listener.Addr(),
+			listener.Addr(),
+		),
+	)
+
+	if err = server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("[in lambdalocal.RunEventBridge] Serve failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBridgeHandler(t *testing.T) {
+	t.Parallel()
+
+	pattern := func(p string) eventPattern {
+		parsed, err := parseEventPattern(p)
+		require.NoError(t, err)
+
+		return parsed
+	}
+
+	rules := []eventBridgeRule{
+		{
+			name:     "BillingOrderPlaced",
+			function: "Billing",
+			bus:      eventBridgeDefaultBus,
+			pattern:  pattern(`{"source": ["orders"], "detail-type": ["OrderPlaced"]}`),
+		},
+		{
+			name:      "ShippingPaid",
+			function:  "Shipping",
+			bus:       eventBridgeDefaultBus,
+			pattern:   pattern(`{"detail": {"status": ["PAID"]}}`),
+			inputPath: "$.detail",
+		},
+		{
+			name:     "AuditOrders",
+			function: "Audit",
+			bus:      "audit",
+			pattern:  pattern(`{"source": ["orders"]}`),
+			input:    `{"audit":true}`,
+		},
+	}
+
+	tests := map[string]struct {
+		target         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		// expectedInvocations are the payloads each function is invoked with.
+		expectedInvocations map[string]string
+	}{
+		"event fans out to matching rules": {
+			target: eventBridgePutEvents,
+			body: `{"Entries":[` +
+				`{"Source":"orders","DetailType":"OrderPlaced","Detail":"{\"status\":\"PAID\"}"}` +
+				`]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"FailedEntryCount":0`,
+			expectedInvocations: map[string]string{
+				"Billing":  "event",
+				"Shipping": `{"status":"PAID"}`,
+			},
+		},
+		"rules of other buses": {
+			target: eventBridgePutEvents,
+			body: `{"Entries":[{"Source":"orders","DetailType":"OrderPlaced","Detail":"{}",` +
+				`"EventBusName":"arn:aws:events:us-east-1:123456789012:event-bus/audit"}]}`,
+			expectedStatus:      http.StatusOK,
+			expectedInvocations: map[string]string{"Audit": `{"audit":true}`},
+		},
+		"invalid entries fail": {
+			target: eventBridgePutEvents,
+			body: `{"Entries":[{"Source":"orders","DetailType":"OrderPlaced","Detail":"[]"},` +
+				`{"Source":"orders"}]}`,
+			expectedStatus:      http.StatusOK,
+			expectedBody:        `"FailedEntryCount":2`,
+			expectedInvocations: map[string]string{},
+		},
+		"no entries": {
+			target:         eventBridgePutEvents,
+			body:           `{"Entries":[]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "ValidationException",
+		},
+		"unsupported operation": {
+			target:         "AWSEvents.ListRules",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "UnknownOperationException",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				invocations := make(map[string]string)
+
+				callers := make(map[string]lambdaCaller)
+
+				for _, function := range []string{"Billing", "Shipping", "Audit"} {
+					caller := new(MockLambdaCaller)
+					caller.On("Invoke", mock.Anything).
+						Run(
+							func(args mock.Arguments) {
+								payload := string(args.Get(0).([]byte))
+
+								// events are compared by their fields, they have a random id and time
+								var event map[string]any
+								if json.Unmarshal([]byte(payload), &event) == nil && event["detail-type"] != nil {
+									assert.Equal(t, "0", event["version"])
+									assert.Equal(t, "123456789012", event["account"])
+									assert.NotEmpty(t, event["id"])
+
+									payload = "event"
+								}
+
+								invocations[function] = payload
+							},
+						).
+						Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+
+					callers[function] = caller
+				}
+
+				handler := eventBridgeHandler{rules: rules, functionCallers: callers, logger: slog.Default()}
+
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+				req.Header.Set("X-Amz-Target", tc.target)
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				assert.Equal(t, tc.expectedStatus, rec.Code)
+				assert.Contains(t, rec.Body.String(), tc.expectedBody)
+
+				if tc.expectedInvocations != nil {
+					assert.Equal(t, tc.expectedInvocations, invocations)
+				}
+			},
+		)
+	}
+}
+
+func TestTemplateEventBridgeRules(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template      string
+		expectedRules []eventBridgeRule
+		expectedErr   string
+	}{
+		"rules of every function": {
+			template: `
+Resources:
+  OrdersBus:
+    Type: AWS::Events::EventBus
+    Properties:
+      Name: orders-bus
+  Billing:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        OrderPlaced:
+          Type: EventBridgeRule
+          Properties:
+            EventBusName: !Ref OrdersBus
+            Pattern:
+              source: [orders]
+            InputPath: $.detail
+  Audit:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Everything:
+          Type: CloudWatchEvent
+          Properties:
+            Pattern: '{"source": [{"prefix": ""}]}'
+            Input: '{"audit":true}'
+`,
+			expectedRules: []eventBridgeRule{
+				{
+					name:     "AuditEverything",
+					function: "Audit",
+					bus:      eventBridgeDefaultBus,
+					pattern:  eventPattern{"source": []any{map[string]any{"prefix": ""}}},
+					input:    `{"audit":true}`,
+				},
+				{
+					name:      "BillingOrderPlaced",
+					function:  "Billing",
+					bus:       "orders-bus",
+					pattern:   eventPattern{"source": []any{"orders"}},
+					inputPath: "$.detail",
+				},
+			},
+		},
+		"invalid pattern": {
+			template: `
+Resources:
+  Billing:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        OrderPlaced:
+          Type: EventBridgeRule
+          Properties:
+            Pattern:
+              source: orders
+`,
+			expectedErr: "Pattern of event 'OrderPlaced' of 'Billing'",
+		},
+		"no rules": {
+			template: `
+Resources:
+  Billing:
+    Type: AWS::Serverless::Function
+`,
+			expectedErr: "has no EventBridgeRule event",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := new(mockOSFileReader)
+				reader.On("read", "template.yaml").Return([]byte(tc.template), nil)
+
+				rules, err := templateEventBridgeRules("template.yaml", reader, nil)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedRules, rules)
+			},
+		)
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// eventPattern is an EventBridge event pattern. Keys select fields of the event, nested objects
+// select fields of nested objects and arrays list the values that match. A field matches when one
+// of its values matches one of the matchers.
+type eventPattern map[string]any
+
+// parseEventPattern parses a pattern of the template, an object or a JSON string, and checks it
+// only uses the matchers EventBridge supports.
+func parseEventPattern(v any) (eventPattern, error) {
+	data, ok := v.(string)
+	if !ok {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseEventPattern] %w", err)
+		}
+
+		data = string(encoded)
+	}
+
+	// decoding JSON keeps numbers float64 like the fields of events
+	var pattern map[string]any
+	if err := json.Unmarshal([]byte(data), &pattern); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseEventPattern] pattern isn't a JSON object: %w", err)
+	}
+
+	if len(pattern) == 0 {
+		return nil, errors.New("[in lambdalocal.parseEventPattern] pattern is empty")
+	}
+
+	if err := validatePattern(pattern); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseEventPattern] %w", err)
+	}
+
+	return pattern, nil
+}
+
+func validatePattern(pattern map[string]any) error {
+	for key, value := range pattern {
+		switch value := value.(type) {
+		case map[string]any:
+			if err := validatePattern(value); err != nil {
+				return fmt.Errorf("%s.%w", key, err)
+			}
+		case []any:
+			if key == "$or" {
+				for _, alternative := range value {
+					object, ok := alternative.(map[string]any)
+					if !ok {
+						return errors.New("$or must list patterns")
+					}
+
+					if err := validatePattern(object); err != nil {
+						return err
+					}
+				}
+
+				continue
+			}
+
+			for _, matcher := range value {
+				if err := validateMatcher(matcher); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+		default:
+			return fmt.Errorf("%s: expected an array of matchers or a nested pattern", key)
+		}
+	}
+
+	return nil
+}
+
+func validateMatcher(matcher any) error {
+	operators, ok := matcher.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if len(operators) != 1 {
+		return errors.New("matchers must have a single operator")
+	}
+
+	for operator, operand := range operators {
+		switch operator {
+		case "prefix", "suffix":
+			if _, ok := operand.(string); !ok {
+				if _, err := ignoreCaseOperand(operand); err != nil {
+					return fmt.Errorf("%s: %w", operator, err)
+				}
+			}
+		case "equals-ignore-case", "wildcard", "cidr":
+			s, ok := operand.(string)
+			if !ok {
+				return fmt.Errorf("%s expects a string", operator)
+			}
+
+			if _, _, err := net.ParseCIDR(s); operator == "cidr" && err != nil {
+				return fmt.Errorf("cidr: %w", err)
+			}
+		case "exists":
+			if _, ok := operand.(bool); !ok {
+				return errors.New("exists expects true or false")
+			}
+		case "numeric":
+			if _, err := parseNumeric(operand); err != nil {
+				return err
+			}
+		case "anything-but":
+			if err := validateAnythingBut(operand); err != nil {
+				return fmt.Errorf("anything-but: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown matcher '%s'", operator)
+		}
+	}
+
+	return nil
+}
+
+// validateAnythingBut checks the operand of anything-but: values, or a prefix, suffix, wildcard or
+// equals-ignore-case matcher of one or more strings.
+func validateAnythingBut(operand any) error {
+	nested, ok := operand.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if len(nested) != 1 {
+		return errors.New("matchers must have a single operator")
+	}
+
+	for operator, excluded := range nested {
+		switch operator {
+		case "prefix", "suffix", "wildcard", "equals-ignore-case":
+		default:
+			return fmt.Errorf("unsupported matcher '%s'", operator)
+		}
+
+		list, ok := excluded.([]any)
+		if !ok {
+			list = []any{excluded}
+		}
+
+		for _, e := range list {
+			if _, ok := e.(string); !ok {
+				return fmt.Errorf("%s expects strings", operator)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matches reports if event, decoded from JSON, matches the pattern.
+func (p eventPattern) matches(event map[string]any) bool {
+	return matchObject(p, event)
+}
+
+func matchObject(pattern, value map[string]any) bool {
+	for key, sub := range pattern {
+		field, exists := value[key]
+
+		switch sub := sub.(type) {
+		case map[string]any:
+			nested, ok := field.(map[string]any)
+			if !ok || !matchObject(sub, nested) {
+				return false
+			}
+		case []any:
+			if key == "$or" {
+				if !matchAny(sub, value) {
+					return false
+				}
+
+				continue
+			}
+
+			if !matchField(sub, field, exists) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchAny reports if one of the patterns matches value.
+func matchAny(patterns []any, value map[string]any) bool {
+	for _, pattern := range patterns {
+		if matchObject(pattern.(map[string]any), value) { //nolint:forcetypeassert
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchField reports if one of the matchers matches the field, or one of its elements when the
+// field is an array.
+func matchField(matchers []any, field any, exists bool) bool {
+	values := []any{field}
+	if list, ok := field.([]any); ok {
+		values = list
+	}
+
+	for _, matcher := range matchers {
+		if operators, ok := matcher.(map[string]any); ok {
+			if want, ok := operators["exists"]; ok {
+				if want == exists {
+					return true
+				}
+
+				continue
+			}
+		}
+
+		if !exists {
+			continue
+		}
+
+		for _, value := range values {
+			if matchValue(matcher, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchValue(matcher, value any) bool {
+	operators, ok := matcher.(map[string]any)
+	if !ok {
+		return matcher == value
+	}
+
+	for operator, operand := range operators {
+		s, isString := value.(string)
+
+		switch operator {
+		case "prefix":
+			return isString && matchAffix(operand, s, strings.HasPrefix)
+		case "suffix":
+			return isString && matchAffix(operand, s, strings.HasSuffix)
+		case "equals-ignore-case":
+			return isString && strings.EqualFold(s, operand.(string)) //nolint:forcetypeassert
+		case "wildcard":
+			return isString && wildcardRegexp(operand.(string)).MatchString(s) //nolint:forcetypeassert
+		case "cidr":
+			_, network, _ := net.ParseCIDR(operand.(string)) //nolint:forcetypeassert
+			ip := net.ParseIP(s)
+
+			return isString && ip != nil && network.Contains(ip)
+		case "numeric":
+			n, isNumber := value.(float64)
+			conditions, _ := parseNumeric(operand)
+
+			return isNumber && conditions.matches(n)
+		case "anything-but":
+			return !matchAnythingBut(operand, value)
+		}
+	}
+
+	return false
+}
+
+// matchAffix matches s with a prefix or suffix, operand is the affix or
+// {"equals-ignore-case": affix}.
+func matchAffix(operand any, s string, has func(s, affix string) bool) bool {
+	if affix, ok := operand.(string); ok {
+		return has(s, affix)
+	}
+
+	affix, _ := ignoreCaseOperand(operand)
+
+	return has(strings.ToLower(s), strings.ToLower(affix))
+}
+
+// matchAnythingBut reports if value matches what anything-but excludes: a value, one of a list of
+// values, or a prefix, suffix or case insensitive match.
+func matchAnythingBut(operand, value any) bool {
+	switch operand := operand.(type) {
+	case []any:
+		for _, excluded := range operand {
+			if matchAnythingBut(excluded, value) {
+				return true
+			}
+		}
+
+		return false
+	case map[string]any:
+		for operator, excluded := range operand {
+			list, ok := excluded.([]any)
+			if !ok {
+				return matchValue(map[string]any{operator: excluded}, value)
+			}
+
+			for _, e := range list {
+				if matchValue(map[string]any{operator: e}, value) {
+					return true
+				}
+			}
+		}
+
+		return false
+	default:
+		return operand == value
+	}
+}
+
+func ignoreCaseOperand(operand any) (string, error) {
+	object, ok := operand.(map[string]any)
+	if !ok || len(object) != 1 {
+		return "", errors.New("expected a string or {\"equals-ignore-case\": string}")
+	}
+
+	s, ok := object["equals-ignore-case"].(string)
+	if !ok {
+		return "", errors.New("expected a string or {\"equals-ignore-case\": string}")
+	}
+
+	return s, nil
+}
+
+// wildcardRegexp converts a wildcard pattern, where * matches any characters, to a regexp.
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// numericCondition compares numbers with an operator like >=.
+type numericCondition struct {
+	operator string
+	operand  float64
+}
+
+type numericConditions []numericCondition
+
+// parseNumeric parses the operand of a numeric matcher, like [">", 0, "<=", 5].
+func parseNumeric(operand any) (numericConditions, error) {
+	list, ok := operand.([]any)
+	if !ok || len(list) == 0 || len(list)%2 != 0 {
+		return nil, errors.New("numeric expects operator and number pairs, like [\">\", 0, \"<=\", 5]")
+	}
+
+	conditions := make(numericConditions, 0, len(list)/2) //nolint:mnd
+
+	for i := 0; i < len(list); i += 2 {
+		operator, ok := list[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("numeric: invalid operator %v", list[i])
+		}
+
+		switch operator {
+		case "=", "<", "<=", ">", ">=":
+		default:
+			return nil, fmt.Errorf("numeric: unknown operator '%s'", operator)
+		}
+
+		n, ok := list[i+1].(float64)
+		if !ok {
+			return nil, fmt.Errorf("numeric: %v isn't a number", list[i+1])
+		}
+
+		conditions = append(conditions, numericCondition{operator: operator, operand: n})
+	}
+
+	return conditions, nil
+}
+
+func (c numericConditions) matches(n float64) bool {
+	for _, condition := range c {
+		var ok bool
+
+		switch condition.operator {
+		case "=":
+			ok = n == condition.operand
+		case "<":
+			ok = n < condition.operand
+		case "<=":
+			ok = n <= condition.operand
+		case ">":
+			ok = n > condition.operand
+		case ">=":
+			ok = n >= condition.operand
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
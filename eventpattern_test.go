@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventPatternMatches(t *testing.T) {
+	t.Parallel()
+
+	event := `{
+		"source": "orders",
+		"detail-type": "OrderPlaced",
+		"resources": ["arn:aws:s3:::bucket/photo.png"],
+		"detail": {
+			"status": "PAID",
+			"total": 42.5,
+			"items": ["book", "pen"],
+			"customer": {"country": "DE", "ip": "10.0.1.7"},
+			"coupon": null
+		}
+	}`
+
+	tests := map[string]struct {
+		pattern  string
+		expected bool
+	}{
+		"source and detail type": {
+			pattern:  `{"source": ["orders"], "detail-type": ["OrderPlaced", "OrderCancelled"]}`,
+			expected: true,
+		},
+		"other source": {
+			pattern: `{"source": ["payments"]}`,
+		},
+		"nested detail": {
+			pattern:  `{"detail": {"customer": {"country": ["DE", "AT"]}}}`,
+			expected: true,
+		},
+		"array fields match any element": {
+			pattern:  `{"detail": {"items": ["pen"]}}`,
+			expected: true,
+		},
+		"null": {
+			pattern:  `{"detail": {"coupon": [null]}}`,
+			expected: true,
+		},
+		"prefix": {
+			pattern:  `{"detail-type": [{"prefix": "Order"}]}`,
+			expected: true,
+		},
+		"suffix ignoring case": {
+			pattern:  `{"detail-type": [{"suffix": {"equals-ignore-case": "PLACED"}}]}`,
+			expected: true,
+		},
+		"equals ignoring case": {
+			pattern:  `{"detail": {"status": [{"equals-ignore-case": "paid"}]}}`,
+			expected: true,
+		},
+		"wildcard": {
+			pattern:  `{"resources": [{"wildcard": "arn:aws:s3:::bucket/*.png"}]}`,
+			expected: true,
+		},
+		"anything but a value": {
+			pattern: `{"detail": {"status": [{"anything-but": "PAID"}]}}`,
+		},
+		"anything but a list": {
+			pattern:  `{"detail": {"status": [{"anything-but": ["PENDING", "FAILED"]}]}}`,
+			expected: true,
+		},
+		"anything but a prefix": {
+			pattern: `{"detail": {"status": [{"anything-but": {"prefix": "PA"}}]}}`,
+		},
+		"numeric range": {
+			pattern:  `{"detail": {"total": [{"numeric": [">", 10, "<=", 42.5]}]}}`,
+			expected: true,
+		},
+		"numeric out of range": {
+			pattern: `{"detail": {"total": [{"numeric": ["<", 10]}]}}`,
+		},
+		"cidr": {
+			pattern:  `{"detail": {"customer": {"ip": [{"cidr": "10.0.0.0/16"}]}}}`,
+			expected: true,
+		},
+		"exists": {
+			pattern:  `{"detail": {"status": [{"exists": true}], "refund": [{"exists": false}]}}`,
+			expected: true,
+		},
+		"missing field": {
+			pattern: `{"detail": {"refund": ["full"]}}`,
+		},
+		"or": {
+			pattern:  `{"$or": [{"source": ["payments"]}, {"detail": {"status": ["PAID"]}}]}`,
+			expected: true,
+		},
+		"all fields have to match": {
+			pattern: `{"source": ["orders"], "detail": {"status": ["PENDING"]}}`,
+		},
+	}
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal([]byte(event), &fields))
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				pattern, err := parseEventPattern(tc.pattern)
+				require.NoError(t, err)
+
+				assert.Equal(t, tc.expected, pattern.matches(fields))
+			},
+		)
+	}
+}
+
+func TestParseEventPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pattern     any
+		expectedErr string
+	}{
+		"object of the template": {
+			pattern: map[string]any{"source": []any{"orders"}, "detail": map[string]any{"total": []any{1}}},
+		},
+		"empty": {
+			expectedErr: "pattern is empty",
+		},
+		"not an object": {
+			pattern:     `["orders"]`,
+			expectedErr: "pattern isn't a JSON object",
+		},
+		"value that isn't an array": {
+			pattern:     `{"source": "orders"}`,
+			expectedErr: "source: expected an array of matchers or a nested pattern",
+		},
+		"unknown matcher": {
+			pattern:     `{"detail": {"status": [{"regex": ".*"}]}}`,
+			expectedErr: "detail.status: unknown matcher 'regex'",
+		},
+		"invalid numeric": {
+			pattern:     `{"total": [{"numeric": [">"]}]}`,
+			expectedErr: "numeric expects operator and number pairs",
+		},
+		"invalid cidr": {
+			pattern:     `{"ip": [{"cidr": "10.0.0.0"}]}`,
+			expectedErr: "cidr:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				_, err := parseEventPattern(tc.pattern)
+				if tc.expectedErr == "" {
+					require.NoError(t, err)
+
+					return
+				}
+
+				require.ErrorContains(t, err, tc.expectedErr)
+			},
+		)
+	}
+}
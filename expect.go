@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// errGoldenMismatch is returned by event --expect when the response doesn't match the golden file.
+var errGoldenMismatch = errors.New("response doesn't match the golden file")
+
+const (
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+	ansiReset = "\033[0m"
+)
+
+// goldenFile is the payload a response is expected to match. Fields at the ignored paths, like
+// timestamps and request ids, are left out of the comparison.
+type goldenFile struct {
+	path     string
+	expected any
+	ignore   [][]string
+}
+
+// loadGoldenFile reads the golden file at path. ignore holds the dot separated paths of the fields
+// that aren't compared, like body.createdAt or body.items.*.id.
+func loadGoldenFile(path string, ignore []string, reader fileReader) (*goldenFile, error) {
+	data, err := reader.read(path)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.loadGoldenFile] read golden file failed: %w", err)
+	}
+
+	golden := &goldenFile{path: path}
+
+	for _, field := range ignore {
+		golden.ignore = append(golden.ignore, strings.Split(field, "."))
+	}
+
+	if golden.expected, err = golden.normalize(data); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.loadGoldenFile] golden file '%s' isn't JSON: %w", path, err)
+	}
+
+	return golden, nil
+}
+
+// normalize decodes data for the comparison. String values holding JSON objects or arrays, like
+// the body of API responses, are decoded too, so golden files can spell them either way.
+func (g *goldenFile) normalize(data []byte) (any, error) {
+	var value any
+	if err := unmarshalJSON(data, &value); err != nil {
+		return nil, err
+	}
+
+	value = decodeInnerJSON(value)
+
+	for _, path := range g.ignore {
+		value = removeField(value, path)
+	}
+
+	return value, nil
+}
+
+// check compares the payload of invokeResponse with the golden file. When they differ the diff is
+// written to w and errGoldenMismatch is returned.
+func (g *goldenFile) check(w io.Writer, invokeResponse messages.InvokeResponse) error {
+	data, err := responsePayload(invokeResponse)
+	if err != nil {
+		return err
+	}
+
+	actual, err := g.normalize(data)
+	if err != nil {
+		actual = string(data)
+	}
+
+	expectedJSON, err := json.MarshalIndent(g.expected, "", "    ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.goldenFile.check] marshal expected payload failed: %w", err)
+	}
+
+	actualJSON, err := json.MarshalIndent(actual, "", "    ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.goldenFile.check] marshal payload failed: %w", err)
+	}
+
+	// maps are marshalled with sorted keys, so equal payloads are equal JSON
+	if string(expectedJSON) == string(actualJSON) {
+		return nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(
+		difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expectedJSON) + "\n"),
+			B:        difflib.SplitLines(string(actualJSON) + "\n"),
+			FromFile: g.path,
+			ToFile:   "response",
+			Context:  3, //nolint:mnd
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.goldenFile.check] diff failed: %w", err)
+	}
+
+	_, _ = io.WriteString(w, colorDiff(diff))
+
+	return fmt.Errorf("%w '%s'", errGoldenMismatch, g.path)
+}
+
+// colorDiff colors the removed lines of a unified diff red, the added ones green and the hunk
+// headers cyan.
+func colorDiff(diff string) string {
+	var builder strings.Builder
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		color := ""
+
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		case strings.HasPrefix(line, "-"):
+			color = ansiRed
+		case strings.HasPrefix(line, "+"):
+			color = ansiGreen
+		case strings.HasPrefix(line, "@@"):
+			color = ansiCyan
+		}
+
+		if color == "" {
+			builder.WriteString(line)
+
+			continue
+		}
+
+		builder.WriteString(color + strings.TrimSuffix(line, "\n") + ansiReset)
+
+		if strings.HasSuffix(line, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// decodeInnerJSON replaces the strings of value that hold a JSON object or array with their
+// decoded value.
+func decodeInnerJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			v[key] = decodeInnerJSON(field)
+		}
+	case []any:
+		for i, element := range v {
+			v[i] = decodeInnerJSON(element)
+		}
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return v
+		}
+
+		var inner any
+		if unmarshalJSON([]byte(trimmed), &inner) != nil {
+			return v
+		}
+
+		return decodeInnerJSON(inner)
+	}
+
+	return value
+}
+
+// removeField removes the field at path from value, * matches every key of an object or element
+// of an array, and numbers match the element at that index.
+func removeField(value any, path []string) any {
+	if len(path) == 0 {
+		return value
+	}
+
+	key, rest := path[0], path[1:]
+
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if key != "*" && key != name {
+				continue
+			}
+
+			if len(rest) == 0 {
+				delete(v, name)
+			} else {
+				v[name] = removeField(field, rest)
+			}
+		}
+	case []any:
+		for i, element := range v {
+			if key != "*" && key != strconv.Itoa(i) {
+				continue
+			}
+
+			// removed elements are compared as null, keeping the index of the others
+			if len(rest) == 0 {
+				v[i] = nil
+			} else {
+				v[i] = removeField(element, rest)
+			}
+		}
+	}
+
+	return value
+}
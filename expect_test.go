@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoldenFileCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		golden   string
+		ignore   []string
+		response messages.InvokeResponse
+		diff     []string
+	}{
+		"equal with another key order": {
+			golden: `{"statusCode":200,"body":"{\"id\":9007199254740993,\"name\":\"order\"}"}`,
+			response: messages.InvokeResponse{
+				Payload: []byte(`{"body":"{\"name\":\"order\",\"id\":9007199254740993}","statusCode":200}`),
+			},
+		},
+		"inner JSON spelled as an object": {
+			golden:   `{"statusCode":200,"body":{"id":1}}`,
+			response: messages.InvokeResponse{Payload: []byte(`{"statusCode":200,"body":"{\"id\":1}"}`)},
+		},
+		"64-bit ids differ": {
+			golden:   `{"id":9007199254740993}`,
+			response: messages.InvokeResponse{Payload: []byte(`{"id":9007199254740992}`)},
+			diff:     []string{`-    "id": 9007199254740993`, `+    "id": 9007199254740992`},
+		},
+		"ignored fields": {
+			golden: `{"statusCode":200,"headers":{"Date":"Mon"},"body":{"requestId":"a","items":[{"id":1,"at":"x"}]}}`,
+			ignore: []string{"headers.Date", "body.requestId", "body.items.*.at"},
+			response: messages.InvokeResponse{
+				Payload: []byte(
+					`{"statusCode":200,"headers":{"Date":"Tue"},` +
+						`"body":"{\"requestId\":\"b\",\"items\":[{\"id\":1,\"at\":\"y\"}]}"}`,
+				),
+			},
+		},
+		"ignored array element": {
+			golden:   `{"items":[1,2,3]}`,
+			ignore:   []string{"items.1"},
+			response: messages.InvokeResponse{Payload: []byte(`{"items":[1,5,3]}`)},
+		},
+		"status code differs": {
+			golden:   `{"statusCode":200,"body":{"id":1}}`,
+			ignore:   []string{"body.id"},
+			response: messages.InvokeResponse{Payload: []byte(`{"statusCode":500,"body":"{\"id\":2}"}`)},
+			diff:     []string{`-    "statusCode": 200`, `+    "statusCode": 500`},
+		},
+		"handler error": {
+			golden: `{"statusCode":200}`,
+			response: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "boom", Type: "errorString"},
+			},
+			diff: []string{`+    "errorMessage": "boom",`},
+		},
+		"non-JSON payload": {
+			golden:   `{"statusCode":200}`,
+			response: messages.InvokeResponse{Payload: []byte(`hello`)},
+			diff:     []string{`+"hello"`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := &mockOSFileReader{}
+				reader.On("read", "expected.json").Return([]byte(tc.golden), nil)
+
+				golden, err := loadGoldenFile("expected.json", tc.ignore, reader)
+				require.NoError(t, err)
+
+				var out bytes.Buffer
+
+				err = golden.check(&out, tc.response)
+				if len(tc.diff) == 0 {
+					require.NoError(t, err)
+					assert.Empty(t, out.String())
+
+					return
+				}
+
+				require.ErrorIs(t, err, errGoldenMismatch)
+				assert.Contains(t, out.String(), "--- expected.json")
+
+				for _, line := range tc.diff {
+					assert.Contains(t, out.String(), line)
+				}
+			},
+		)
+	}
+}
+
+func TestLoadGoldenFileNotJSON(t *testing.T) {
+	t.Parallel()
+
+	reader := &mockOSFileReader{}
+	reader.On("read", "expected.json").Return([]byte(`{"statusCode":`), nil)
+
+	_, err := loadGoldenFile("expected.json", nil, reader)
+	assert.ErrorContains(t, err, "golden file 'expected.json' isn't JSON")
+}
+
+func TestColorDiff(t *testing.T) {
+	t.Parallel()
+
+	diff := "--- expected.json\n+++ response\n@@ -1,3 +1,3 @@\n {\n-    \"id\": 1\n+    \"id\": 2\n }\n"
+
+	assert.Equal(
+		t,
+		"--- expected.json\n+++ response\n"+ansiCyan+"@@ -1,3 +1,3 @@"+ansiReset+"\n {\n"+
+			ansiRed+"-    \"id\": 1"+ansiReset+"\n"+ansiGreen+"+    \"id\": 2"+ansiReset+"\n }\n",
+		colorDiff(diff),
+	)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+)
+
+const (
+	extensionAPIPrefix = "/2020-01-01/extension"
+
+	extensionEventInvoke   = "INVOKE"
+	extensionEventShutdown = "SHUTDOWN"
+
+	// extensionShutdownTimeout is how long extensions get to handle the SHUTDOWN event.
+	extensionShutdownTimeout = 2 * time.Second
+	// extensionEventBuffer is the number of events queued for an extension that isn't polling.
+	extensionEventBuffer = 64
+	// extensionFunctionName is reported to extensions as the name of the function.
+	extensionFunctionName = "lambdalocal"
+)
+
+// extensionEvent is an event returned to an extension by /event/next.
+type extensionEvent struct {
+	EventType          string            `json:"eventType"`
+	DeadlineMs         int64             `json:"deadlineMs"`
+	RequestID          string            `json:"requestId,omitempty"`
+	InvokedFunctionArn string            `json:"invokedFunctionArn,omitempty"`
+	Tracing            *extensionTracing `json:"tracing,omitempty"`
+	ShutdownReason     string            `json:"shutdownReason,omitempty"`
+}
+
+type extensionTracing struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// extension is an extension registered with the Extensions API.
+type extension struct {
+	name string
+	// events are the event types the extension registered for.
+	events map[string]bool
+	queue  chan extensionEvent
+	// shutdownDelivered is set once the SHUTDOWN event is returned to the extension.
+	shutdownDelivered atomic.Bool
+	// handled is closed when the extension asks for another event after SHUTDOWN.
+	handled     chan struct{}
+	handledOnce sync.Once
+}
+
+// extensionsAPI emulates the Lambda Extensions API next to the Runtime API, so handlers packaged
+// with extensions don't hang waiting for it during initialization.
+type extensionsAPI struct {
+	mu         sync.Mutex
+	extensions map[string]*extension
+	// done is closed once extensions have handled the SHUTDOWN event.
+	done chan struct{}
+	// telemetry records the platform.extension event of registered extensions.
+	telemetry *telemetryAPI
+	logger    *slog.Logger
+}
+
+func newExtensionsAPI(logger *slog.Logger) *extensionsAPI {
+	return &extensionsAPI{
+		extensions: make(map[string]*extension),
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+}
+
+// handle registers the Extensions API routes on router.
+func (e *extensionsAPI) handle(router *http.ServeMux) {
+	router.HandleFunc("POST "+extensionAPIPrefix+"/register", e.handleRegister)
+	router.HandleFunc("GET "+extensionAPIPrefix+"/event/next", e.handleNext)
+	router.HandleFunc("POST "+extensionAPIPrefix+"/init/error", e.handleError)
+	router.HandleFunc("POST "+extensionAPIPrefix+"/exit/error", e.handleError)
+}
+
+func (e *extensionsAPI) handleRegister(w http.ResponseWriter, r *http.Request) {
+	name := r.Header.Get("Lambda-Extension-Name")
+	if name == "" {
+		http.Error(w, "missing Lambda-Extension-Name header", http.StatusBadRequest)
+
+		return
+	}
+
+	var body struct {
+		Events []string `json:"events"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid register request: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	ext := &extension{
+		name:    name,
+		events:  make(map[string]bool, len(body.Events)),
+		queue:   make(chan extensionEvent, extensionEventBuffer),
+		handled: make(chan struct{}),
+	}
+
+	for _, event := range body.Events {
+		if event != extensionEventInvoke && event != extensionEventShutdown {
+			http.Error(w, fmt.Sprintf("unknown event type '%s'", event), http.StatusBadRequest)
+
+			return
+		}
+
+		ext.events[event] = true
+	}
+
+	id := uuid.New().String()
+
+	e.mu.Lock()
+	e.extensions[id] = ext
+	e.mu.Unlock()
+
+	e.logger.Info(fmt.Sprintf("Extension %s registered for %v", name, body.Events))
+
+	if e.telemetry != nil {
+		e.telemetry.emit("platform.extension", map[string]any{"name": name, "state": "Ready", "events": body.Events})
+	}
+
+	w.Header().Set("Lambda-Extension-Identifier", id)
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(
+		map[string]string{
+			"functionName":    extensionFunctionName,
+			"functionVersion": "$LATEST",
+			"handler":         extensionFunctionName,
+		},
+	)
+}
+
+func (e *extensionsAPI) handleNext(w http.ResponseWriter, r *http.Request) {
+	ext, ok := e.extension(r)
+	if !ok {
+		http.Error(w, "unknown extension identifier", http.StatusForbidden)
+
+		return
+	}
+
+	if ext.shutdownDelivered.Load() {
+		ext.handledOnce.Do(func() { close(ext.handled) })
+	}
+
+	var event extensionEvent
+
+	select {
+	case event = <-ext.queue:
+	case <-e.done:
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	if event.EventType == extensionEventShutdown {
+		ext.shutdownDelivered.Store(true)
+	}
+
+	w.Header().Set("Lambda-Extension-Event-Identifier", uuid.New().String())
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(event)
+}
+
+func (e *extensionsAPI) handleError(w http.ResponseWriter, r *http.Request) {
+	ext, ok := e.extension(r)
+	if !ok {
+		http.Error(w, "unknown extension identifier", http.StatusForbidden)
+
+		return
+	}
+
+	runtimeErr := parseRuntimeError(r)
+
+	e.logger.Error(
+		fmt.Sprintf("Extension %s failed: %s", ext.name, runtimeErr.Message),
+		"errorType",
+		r.Header.Get("Lambda-Extension-Function-Error-Type"),
+	)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (e *extensionsAPI) extension(r *http.Request) (*extension, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ext, ok := e.extensions[r.Header.Get("Lambda-Extension-Identifier")]
+
+	return ext, ok
+}
+
+// invoke sends the INVOKE event of request to the extensions registered for it.
+func (e *extensionsAPI) invoke(request messages.InvokeRequest) {
+	deadline := time.Unix(request.Deadline.Seconds, request.Deadline.Nanos)
+
+	event := extensionEvent{
+		EventType:          extensionEventInvoke,
+		DeadlineMs:         deadline.UnixMilli(),
+		RequestID:          request.RequestId,
+		InvokedFunctionArn: request.InvokedFunctionArn,
+	}
+
+	if request.XAmznTraceId != "" {
+		event.Tracing = &extensionTracing{Type: "X-Amzn-Trace-Id", Value: request.XAmznTraceId}
+	}
+
+	e.send(event)
+}
+
+// shutdown sends the SHUTDOWN event to the extensions registered for it and waits up to
+// extensionShutdownTimeout for them to ask for another event, which marks it as handled.
+func (e *extensionsAPI) shutdown() {
+	deadline := time.Now().Add(extensionShutdownTimeout)
+
+	waiting := e.send(
+		extensionEvent{
+			EventType:      extensionEventShutdown,
+			DeadlineMs:     deadline.UnixMilli(),
+			ShutdownReason: "spindown",
+		},
+	)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	for _, ext := range waiting {
+		select {
+		case <-ext.handled:
+		case <-ctx.Done():
+			e.logger.Warn("Extension " + ext.name + " did not handle SHUTDOWN in time")
+		}
+	}
+
+	close(e.done)
+}
+
+// send queues event for every extension registered for its type and returns those extensions.
+func (e *extensionsAPI) send(event extensionEvent) []*extension {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var sent []*extension
+
+	for _, ext := range e.extensions {
+		if !ext.events[event.EventType] {
+			continue
+		}
+
+		select {
+		case ext.queue <- event:
+			sent = append(sent, ext)
+		default:
+			e.logger.Warn(fmt.Sprintf("Extension %s isn't polling, dropped %s event", ext.name, event.EventType))
+		}
+	}
+
+	return sent
+}
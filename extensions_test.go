@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerExtension registers an extension for events and returns its identifier.
+func registerExtension(t *testing.T, address, events string) (string, int) {
+	t.Helper()
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://"+address+extensionAPIPrefix+"/register",
+		strings.NewReader(`{"events":`+events+`}`),
+	)
+	require.NoError(t, err)
+
+	req.Header.Set("Lambda-Extension-Name", "test-extension")
+
+	resp, err := runtimeClient.Do(req)
+	require.NoError(t, err)
+
+	_ = resp.Body.Close()
+
+	return resp.Header.Get("Lambda-Extension-Identifier"), resp.StatusCode
+}
+
+// nextExtensionEvent asks for the next event of the extension with id.
+func nextExtensionEvent(address, id string) (extensionEvent, error) {
+	req, err := http.NewRequest(http.MethodGet, "http://"+address+extensionAPIPrefix+"/event/next", nil)
+	if err != nil {
+		return extensionEvent{}, err //nolint:wrapcheck
+	}
+
+	req.Header.Set("Lambda-Extension-Identifier", id)
+
+	resp, err := runtimeClient.Do(req)
+	if err != nil {
+		return extensionEvent{}, err //nolint:wrapcheck
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var event extensionEvent
+
+	err = json.NewDecoder(resp.Body).Decode(&event)
+
+	return event, err //nolint:wrapcheck
+}
+
+func TestExtensionsAPI(t *testing.T) {
+	t.Parallel()
+
+	runtimeAPI := NewRuntimeAPIClient("localhost:0", 5*time.Second, slog.Default())
+
+	stop, err := runtimeAPI.Start()
+	require.NoError(t, err)
+
+	address := runtimeAPI.address
+
+	id, status := registerExtension(t, address, `["INVOKE","SHUTDOWN"]`)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, id)
+
+	_, status = registerExtension(t, address, `["UNKNOWN"]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, err = nextExtensionEvent(address, "unknown")
+	require.Error(t, err, "unknown extensions get no event")
+
+	events := make(chan extensionEvent, 2)
+
+	go func() {
+		for {
+			event, err := nextExtensionEvent(address, id)
+			if err != nil {
+				close(events)
+
+				return
+			}
+
+			events <- event
+		}
+	}()
+
+	go runtimeRoundTrip(t, address, "response", `{"statusCode":200}`)
+
+	_, err = runtimeAPI.Invoke(context.Background(), []byte(`{}`), WithRequestID("invoke-id"))
+	require.NoError(t, err)
+
+	invoke := <-events
+	assert.Equal(t, extensionEventInvoke, invoke.EventType)
+	assert.Equal(t, "invoke-id", invoke.RequestID)
+	assert.Positive(t, invoke.DeadlineMs)
+
+	start := time.Now()
+
+	stop()
+
+	shutdown := <-events
+	assert.Equal(t, extensionEventShutdown, shutdown.EventType)
+	assert.Equal(t, "spindown", shutdown.ShutdownReason)
+	// the extension asked for its next event right away, so stop didn't wait for the timeout
+	assert.Less(t, time.Since(start), extensionShutdownTimeout)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// faultInjection slows down and fails requests of the routes on purpose, so clients can be tested
+// against slow and failing lambdas without changing the handler. The zero value injects nothing.
+type faultInjection struct {
+	// minLatency and maxLatency bound the latency added before invoking the lambda.
+	minLatency time.Duration
+	maxLatency time.Duration
+	// errorRate is the share of requests, between 0 and 1, failed instead of invoking the lambda.
+	errorRate float64
+}
+
+// parseLatencyRange parses the latency of --inject-latency, a duration like 200ms or a range of
+// durations like 200ms..2s.
+func parseLatencyRange(s string) (time.Duration, time.Duration, error) {
+	low, high, isRange := strings.Cut(s, "..")
+
+	minimum, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil {
+		return 0, 0, fmt.Errorf("[in lambdalocal.parseLatencyRange] invalid latency '%s': %w", s, err)
+	}
+
+	maximum := minimum
+
+	if isRange {
+		if maximum, err = time.ParseDuration(strings.TrimSpace(high)); err != nil {
+			return 0, 0, fmt.Errorf("[in lambdalocal.parseLatencyRange] invalid latency '%s': %w", s, err)
+		}
+	}
+
+	if minimum < 0 || maximum < minimum {
+		return 0, 0, fmt.Errorf(
+			"[in lambdalocal.parseLatencyRange] expected a latency like 200ms or 200ms..2s. Got '%s'",
+			s,
+		)
+	}
+
+	return minimum, maximum, nil
+}
+
+// latency returns a random latency between the bounds.
+func (f faultInjection) latency() time.Duration {
+	if f.maxLatency <= f.minLatency {
+		return f.minLatency
+	}
+
+	return f.minLatency + rand.N(f.maxLatency-f.minLatency+1) //nolint:gosec
+}
+
+// delay waits for the injected latency, or until ctx is done.
+func (f faultInjection) delay(ctx context.Context) (time.Duration, error) {
+	latency := f.latency()
+	if latency <= 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return latency, nil
+	case <-ctx.Done():
+		return latency, fmt.Errorf("[in lambdalocal.faultInjection.delay] %w", ctx.Err())
+	}
+}
+
+// fails reports whether the request is failed instead of invoking the lambda.
+func (f faultInjection) fails() bool {
+	return f.errorRate > 0 && rand.Float64() < f.errorRate //nolint:gosec
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLatencyRange(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		latency     string
+		expectedMin time.Duration
+		expectedMax time.Duration
+		expectedErr bool
+	}{
+		"fixed": {
+			latency:     "500ms",
+			expectedMin: 500 * time.Millisecond,
+			expectedMax: 500 * time.Millisecond,
+		},
+		"range":             {latency: "200ms..2s", expectedMin: 200 * time.Millisecond, expectedMax: 2 * time.Second},
+		"range with spaces": {latency: "1s .. 3s", expectedMin: time.Second, expectedMax: 3 * time.Second},
+		"reversed range":    {latency: "2s..200ms", expectedErr: true},
+		"negative":          {latency: "-1s", expectedErr: true},
+		"missing maximum":   {latency: "200ms..", expectedErr: true},
+		"not a duration":    {latency: "slow", expectedErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				minimum, maximum, err := parseLatencyRange(tc.latency)
+				if tc.expectedErr {
+					assert.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedMin, minimum)
+				assert.Equal(t, tc.expectedMax, maximum)
+			},
+		)
+	}
+}
+
+func TestFaultInjectionLatency(t *testing.T) {
+	t.Parallel()
+
+	faults := faultInjection{minLatency: 10 * time.Millisecond, maxLatency: 20 * time.Millisecond}
+
+	for range 100 {
+		latency := faults.latency()
+		assert.GreaterOrEqual(t, latency, faults.minLatency)
+		assert.LessOrEqual(t, latency, faults.maxLatency)
+	}
+
+	assert.Zero(t, faultInjection{}.latency())
+}
+
+func TestFaultInjectionDelayCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := faultInjection{minLatency: time.Hour, maxLatency: time.Hour}.delay(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGatewayHandlerFaults(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		faults         faultInjection
+		expectedStatus int
+		expectInvoke   bool
+		minDuration    time.Duration
+	}{
+		"no faults": {
+			expectedStatus: http.StatusOK,
+			expectInvoke:   true,
+		},
+		"latency": {
+			faults:         faultInjection{minLatency: 20 * time.Millisecond, maxLatency: 20 * time.Millisecond},
+			expectedStatus: http.StatusOK,
+			expectInvoke:   true,
+			minDuration:    20 * time.Millisecond,
+		},
+		"errors": {
+			faults:         faultInjection{errorRate: 1},
+			expectedStatus: http.StatusBadGateway,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(recordingLambdaCaller)
+				route := apiRoute{
+					method:        http.MethodGet,
+					path:          "/test",
+					payloadFormat: payloadFormatV2,
+					faults:        tc.faults,
+				}
+
+				start := time.Now()
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+					ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectInvoke, caller.data != nil)
+				assert.GreaterOrEqual(t, time.Since(start), tc.minDuration)
+			},
+		)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"cmp"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// sampleRequestID is the request id of generated events, like the samples of the AWS docs.
+	sampleRequestID = "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"
+	// sampleSourceIP is the caller of generated events.
+	sampleSourceIP = "203.0.113.1"
+)
+
+// eventFields are the fields of generated events that can be substituted.
+type eventFields struct {
+	bucket string
+	key    string
+	// body is the body, message or data of the event. Each event has a default.
+	body   string
+	region string
+	now    time.Time
+}
+
+// eventGenerator returns a sample event with fields substituted.
+type eventGenerator func(fields eventFields) (any, error)
+
+// eventGenerators are the generators of sample events, by service and event type.
+var eventGenerators = map[string]map[string]eventGenerator{ //nolint:gochecknoglobals
+	"apigateway": {
+		"aws-proxy":      apiGatewayProxyEvent,
+		"http-api-proxy": apiGatewayHTTPAPIEvent,
+	},
+	"cognito": {
+		"pre-signup":        cognitoPreSignupEvent,
+		"post-confirmation": cognitoPostConfirmationEvent,
+	},
+	"dynamodb": {
+		"update": dynamoDBUpdateEvent,
+	},
+	"eventbridge": {
+		"event":           eventBridgeSampleEvent,
+		"scheduled-event": eventBridgeScheduledEvent,
+	},
+	"kinesis": {
+		"get-records": kinesisGetRecordsEvent,
+	},
+	"s3": {
+		"put":    s3PutEvent,
+		"delete": s3DeleteEvent,
+	},
+	"sns": {
+		"notification": snsNotificationEvent,
+	},
+	"sqs": {
+		"receive-message": sqsReceiveMessageEvent,
+	},
+}
+
+// RunGenerateEvent writes a sample event of the type typ of service to w. Without a service or
+// type, the services or the types of the service are listed.
+func RunGenerateEvent(w io.Writer, service, typ string, fields eventFields) error {
+	if service == "" {
+		_, _ = fmt.Fprintln(w, "Services:\n  "+strings.Join(slices.Sorted(maps.Keys(eventGenerators)), "\n  "))
+
+		return nil
+	}
+
+	generators, ok := eventGenerators[service]
+	if !ok {
+		return fmt.Errorf(
+			"[in lambdalocal.RunGenerateEvent] unknown service '%s', must be one of %s",
+			service,
+			strings.Join(slices.Sorted(maps.Keys(eventGenerators)), ", "),
+		)
+	}
+
+	if typ == "" {
+		_, _ = fmt.Fprintf(
+			w,
+			"Event types of %s:\n  %s\n",
+			service,
+			strings.Join(slices.Sorted(maps.Keys(generators)), "\n  "),
+		)
+
+		return nil
+	}
+
+	generator, ok := generators[typ]
+	if !ok {
+		return fmt.Errorf(
+			"[in lambdalocal.RunGenerateEvent] unknown event type '%s' of %s, must be one of %s",
+			typ,
+			service,
+			strings.Join(slices.Sorted(maps.Keys(generators)), ", "),
+		)
+	}
+
+	event, err := generator(fields)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunGenerateEvent] %w", err)
+	}
+
+	out, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunGenerateEvent] marshal event failed: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(w, string(out))
+
+	return nil
+}
+
+// arn returns the ARN of a resource of service in the region of the fields and the local account.
+func (f eventFields) arn(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, f.region, localAccountID, resource)
+}
+
+// apiGatewayProxyEvent returns a REST API proxy integration event, payload format 1.0.
+func apiGatewayProxyEvent(fields eventFields) (any, error) {
+	body := cmp.Or(fields.body, `{"message": "hello world"}`)
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        "/{proxy+}",
+		Path:                            "/path/to/resource",
+		HTTPMethod:                      "POST",
+		Headers:                         map[string]string{"Content-Type": "application/json"},
+		MultiValueHeaders:               map[string][]string{"Content-Type": {"application/json"}},
+		QueryStringParameters:           map[string]string{"foo": "bar"},
+		MultiValueQueryStringParameters: map[string][]string{"foo": {"bar"}},
+		PathParameters:                  map[string]string{"proxy": "path/to/resource"},
+		StageVariables:                  map[string]string{},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    localAccountID,
+			ResourceID:   "123456",
+			Stage:        "prod",
+			DomainName:   localAPIID + ".execute-api." + fields.region + ".amazonaws.com",
+			DomainPrefix: localAPIID,
+			RequestID:    sampleRequestID,
+			Protocol:     "HTTP/1.1",
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sampleSourceIP,
+				UserAgent: "Custom User Agent String",
+			},
+			ResourcePath:     "/{proxy+}",
+			Path:             "/prod/path/to/resource",
+			HTTPMethod:       "POST",
+			RequestTime:      fields.now.Format("02/Jan/2006:15:04:05 -0700"),
+			RequestTimeEpoch: fields.now.UnixMilli(),
+			APIID:            localAPIID,
+		},
+		Body: body,
+	}, nil
+}
+
+// apiGatewayHTTPAPIEvent returns an HTTP API event of payload format 2.0.
+func apiGatewayHTTPAPIEvent(fields eventFields) (any, error) {
+	body := cmp.Or(fields.body, `{"message": "hello world"}`)
+
+	return events.APIGatewayV2HTTPRequest{
+		Version:               payloadFormatV2,
+		RouteKey:              "POST /path/to/resource",
+		RawPath:               "/path/to/resource",
+		RawQueryString:        "foo=bar",
+		Headers:               map[string]string{"content-type": "application/json"},
+		QueryStringParameters: map[string]string{"foo": "bar"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:     "POST /path/to/resource",
+			AccountID:    localAccountID,
+			Stage:        "$default",
+			RequestID:    sampleRequestID,
+			APIID:        localAPIID,
+			DomainName:   localAPIID + ".execute-api." + fields.region + ".amazonaws.com",
+			DomainPrefix: localAPIID,
+			Time:         fields.now.Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch:    fields.now.UnixMilli(),
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:    "POST",
+				Path:      "/path/to/resource",
+				Protocol:  "HTTP/1.1",
+				SourceIP:  sampleSourceIP,
+				UserAgent: "Custom User Agent String",
+			},
+		},
+		Body: body,
+	}, nil
+}
+
+// cognitoHeader returns the fields shared by Cognito user pool trigger events.
+func cognitoHeader(fields eventFields, triggerSource string) events.CognitoEventUserPoolsHeader {
+	return events.CognitoEventUserPoolsHeader{
+		Version:       "1",
+		TriggerSource: triggerSource,
+		Region:        fields.region,
+		UserPoolID:    fields.region + "_EXAMPLE",
+		CallerContext: events.CognitoEventUserPoolsCallerContext{
+			AWSSDKVersion: "aws-sdk-unknown-unknown",
+			ClientID:      "1example23456789",
+		},
+		UserName: "testuser",
+	}
+}
+
+// cognitoPreSignupEvent returns the event of a Cognito user pool pre sign-up trigger.
+func cognitoPreSignupEvent(fields eventFields) (any, error) {
+	return events.CognitoEventUserPoolsPreSignup{
+		CognitoEventUserPoolsHeader: cognitoHeader(fields, "PreSignUp_SignUp"),
+		Request: events.CognitoEventUserPoolsPreSignupRequest{
+			UserAttributes: map[string]string{"email": "user@example.com", "phone_number": "+12065550100"},
+			ValidationData: map[string]string{},
+			ClientMetadata: map[string]string{},
+		},
+	}, nil
+}
+
+// cognitoPostConfirmationEvent returns the event of a Cognito user pool post confirmation trigger.
+func cognitoPostConfirmationEvent(fields eventFields) (any, error) {
+	return events.CognitoEventUserPoolsPostConfirmation{
+		CognitoEventUserPoolsHeader: cognitoHeader(fields, "PostConfirmation_ConfirmSignUp"),
+		Request: events.CognitoEventUserPoolsPostConfirmationRequest{
+			UserAttributes: map[string]string{
+				"sub":            "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d",
+				"email":          "user@example.com",
+				"email_verified": "true",
+			},
+			ClientMetadata: map[string]string{},
+		},
+	}, nil
+}
+
+// dynamoDBUpdateEvent returns a DynamoDB stream event inserting an item with the body as Message.
+func dynamoDBUpdateEvent(fields eventFields) (any, error) {
+	message := cmp.Or(fields.body, "New item!")
+
+	return events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{
+			{
+				AWSRegion: fields.region,
+				Change: events.DynamoDBStreamRecord{
+					ApproximateCreationDateTime: events.SecondsEpochTime{Time: fields.now},
+					Keys: map[string]events.DynamoDBAttributeValue{
+						"Id": events.NewNumberAttribute("101"),
+					},
+					NewImage: map[string]events.DynamoDBAttributeValue{
+						"Id":      events.NewNumberAttribute("101"),
+						"Message": events.NewStringAttribute(message),
+					},
+					SequenceNumber: "111",
+					SizeBytes:      26, //nolint:mnd
+					StreamViewType: "NEW_AND_OLD_IMAGES",
+				},
+				EventID:        "1",
+				EventName:      "INSERT",
+				EventSource:    "aws:dynamodb",
+				EventVersion:   "1.0",
+				EventSourceArn: fields.arn("dynamodb", "table/ExampleTable/stream/2015-06-27T00:48:05.899"),
+			},
+		},
+	}, nil
+}
+
+// eventBridgeSampleEvent returns an EventBridge event with the body as detail.
+func eventBridgeSampleEvent(fields eventFields) (any, error) {
+	detail := cmp.Or(fields.body, `{"orderId": "1234"}`)
+	if !json.Valid([]byte(detail)) {
+		return nil, fmt.Errorf("the body of EventBridge events must be JSON, got '%s'", detail)
+	}
+
+	return events.EventBridgeEvent{
+		Version:    "0",
+		ID:         sampleRequestID,
+		DetailType: "Order Placed",
+		Source:     "com.example.orders",
+		AccountID:  localAccountID,
+		Time:       fields.now,
+		Region:     fields.region,
+		Resources:  []string{},
+		Detail:     json.RawMessage(detail),
+	}, nil
+}
+
+// eventBridgeScheduledEvent returns the event of an EventBridge schedule.
+func eventBridgeScheduledEvent(fields eventFields) (any, error) {
+	return events.EventBridgeEvent{
+		Version:    "0",
+		ID:         sampleRequestID,
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		AccountID:  localAccountID,
+		Time:       fields.now,
+		Region:     fields.region,
+		Resources:  []string{fields.arn("events", "rule/ExampleRule")},
+		Detail:     json.RawMessage(`{}`),
+	}, nil
+}
+
+// kinesisGetRecordsEvent returns a Kinesis stream event with the body as data.
+func kinesisGetRecordsEvent(fields eventFields) (any, error) {
+	data := cmp.Or(fields.body, "Hello, this is a test.")
+
+	return events.KinesisEvent{
+		Records: []events.KinesisEventRecord{
+			{
+				AwsRegion:         fields.region,
+				EventID:           "shardId-000000000006:49590338271490256608559692538361571095921575989136588898",
+				EventName:         "aws:kinesis:record",
+				EventSource:       "aws:kinesis",
+				EventSourceArn:    fields.arn("kinesis", "stream/example-stream"),
+				EventVersion:      "1.0",
+				InvokeIdentityArn: fields.arn("iam", "role/lambda-role"),
+				Kinesis: events.KinesisRecord{
+					ApproximateArrivalTimestamp: events.SecondsEpochTime{Time: fields.now},
+					Data:                        []byte(data),
+					PartitionKey:                "1",
+					SequenceNumber:              "49590338271490256608559692538361571095921575989136588898",
+					KinesisSchemaVersion:        "1.0",
+				},
+			},
+		},
+	}, nil
+}
+
+// s3Event returns an S3 notification of eventName for the key in the bucket of the fields.
+func s3Event(fields eventFields, eventName string) events.S3Event {
+	return events.S3Event{
+		Records: []events.S3EventRecord{
+			{
+				EventVersion:      "2.1",
+				EventSource:       "aws:s3",
+				AWSRegion:         fields.region,
+				EventTime:         fields.now,
+				EventName:         eventName,
+				PrincipalID:       events.S3UserIdentity{PrincipalID: "EXAMPLE"},
+				RequestParameters: events.S3RequestParameters{SourceIPAddress: sampleSourceIP},
+				ResponseElements: map[string]string{
+					"x-amz-request-id": "EXAMPLE123456789",
+					"x-amz-id-2":       "EXAMPLE123/5678abcdefghijklambdaisawesome/mnopqrstuvwxyzABCDEFGH",
+				},
+				S3: events.S3Entity{
+					SchemaVersion:   "1.0",
+					ConfigurationID: "testConfigRule",
+					Bucket: events.S3Bucket{
+						Name:          fields.bucket,
+						OwnerIdentity: events.S3UserIdentity{PrincipalID: "EXAMPLE"},
+						Arn:           "arn:aws:s3:::" + fields.bucket,
+					},
+					Object: events.S3Object{
+						Key:       fields.key,
+						Sequencer: "0A1B2C3D4E5F678901",
+					},
+				},
+			},
+		},
+	}
+}
+
+// s3PutEvent returns the S3 notification of a created object.
+func s3PutEvent(fields eventFields) (any, error) {
+	event := s3Event(fields, "ObjectCreated:Put")
+	event.Records[0].S3.Object.Size = 1024
+	event.Records[0].S3.Object.ETag = "0123456789abcdef0123456789abcdef"
+
+	return event, nil
+}
+
+// s3DeleteEvent returns the S3 notification of a deleted object.
+func s3DeleteEvent(fields eventFields) (any, error) {
+	return s3Event(fields, "ObjectRemoved:Delete"), nil
+}
+
+// snsNotificationEvent returns an SNS event with the body as message.
+func snsNotificationEvent(fields eventFields) (any, error) {
+	return snsEvent(
+		snsMessage{
+			TopicARN:          fields.arn("sns", "ExampleTopic"),
+			MessageID:         "95df01b4-ee98-5cb9-9903-4c221d41eb5e",
+			Subject:           "example subject",
+			Message:           cmp.Or(fields.body, "Hello from SNS!"),
+			Timestamp:         fields.now,
+			MessageAttributes: map[string]snsMessageAttribute{},
+		},
+	), nil
+}
+
+// sqsReceiveMessageEvent returns an SQS event with the body as message body.
+func sqsReceiveMessageEvent(fields eventFields) (any, error) {
+	queue := sqsQueue{arn: fields.arn("sqs", "ExampleQueue"), region: fields.region}
+	body := cmp.Or(fields.body, "Hello from SQS!")
+
+	return sqsEvent(
+		queue,
+		[]sqsMessage{
+			{
+				MessageID:     "19dd0b57-b21e-4ac1-bd88-01bbb068cb78",
+				ReceiptHandle: "MessageReceiptHandle",
+				Body:          body,
+				MD5OfBody:     fmt.Sprintf("%x", md5.Sum([]byte(body))), //nolint:gosec
+				Attributes: map[string]string{
+					"ApproximateReceiveCount":          "1",
+					"SentTimestamp":                    fmt.Sprint(fields.now.UnixMilli()),
+					"SenderId":                         localAccountID,
+					"ApproximateFirstReceiveTimestamp": fmt.Sprint(fields.now.UnixMilli()),
+				},
+			},
+		},
+	), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGenerateEvent(t *testing.T) {
+	t.Parallel()
+
+	fields := eventFields{
+		bucket: "uploads",
+		key:    "images/cat.png",
+		region: "eu-west-1",
+		now:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		service       string
+		typ           string
+		body          string
+		expectedParts []string
+		expectedErr   string
+	}{
+		"services": {
+			expectedParts: []string{"Services:\n  apigateway\n  cognito\n", "  sqs\n"},
+		},
+		"event types": {
+			service:       "s3",
+			expectedParts: []string{"Event types of s3:\n  delete\n  put\n"},
+		},
+		"s3 put": {
+			service: "s3",
+			typ:     "put",
+			expectedParts: []string{
+				`"name": "uploads"`,
+				`"arn": "arn:aws:s3:::uploads"`,
+				`"key": "images/cat.png"`,
+				`"eventName": "ObjectCreated:Put"`,
+				`"awsRegion": "eu-west-1"`,
+				`"eventTime": "2024-05-01T12:00:00Z"`,
+			},
+		},
+		"sqs receive message": {
+			service: "sqs",
+			typ:     "receive-message",
+			body:    "order 42",
+			expectedParts: []string{
+				`"body": "order 42"`,
+				`"md5OfBody": "6fe5d55dde2e15cca02ebd1b84b858ff"`,
+				`"eventSourceARN": "arn:aws:sqs:eu-west-1:123456789012:ExampleQueue"`,
+			},
+		},
+		"sns default body": {
+			service: "sns",
+			typ:     "notification",
+			expectedParts: []string{
+				`"Message": "Hello from SNS!"`,
+				`"TopicArn": "arn:aws:sns:eu-west-1:123456789012:ExampleTopic"`,
+			},
+		},
+		"api gateway body": {
+			service:       "apigateway",
+			typ:           "http-api-proxy",
+			body:          `{"name":"cat"}`,
+			expectedParts: []string{`"body": "{\"name\":\"cat\"}"`, `"version": "2.0"`},
+		},
+		"eventbridge detail": {
+			service:       "eventbridge",
+			typ:           "event",
+			body:          `{"orderId":"42"}`,
+			expectedParts: []string{`"orderId": "42"`},
+		},
+		"eventbridge detail not json": {
+			service:     "eventbridge",
+			typ:         "event",
+			body:        "order 42",
+			expectedErr: "the body of EventBridge events must be JSON",
+		},
+		"unknown service": {
+			service:     "lambda",
+			expectedErr: "unknown service 'lambda'",
+		},
+		"unknown event type": {
+			service:     "s3",
+			typ:         "copy",
+			expectedErr: "unknown event type 'copy' of s3, must be one of delete, put",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				eventFields := fields
+				eventFields.body = tc.body
+
+				var out bytes.Buffer
+
+				err := RunGenerateEvent(&out, tc.service, tc.typ, eventFields)
+				if tc.expectedErr != "" {
+					assert.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+
+				if tc.typ != "" {
+					assert.True(t, json.Valid(out.Bytes()))
+				}
+
+				for _, part := range tc.expectedParts {
+					assert.Contains(t, out.String(), part)
+				}
+			},
+		)
+	}
+}
+
+func TestEventGenerators(t *testing.T) {
+	t.Parallel()
+
+	fields := eventFields{bucket: "example-bucket", key: "test/key", region: sqsDefaultRegion, now: time.Now()}
+
+	for service, generators := range eventGenerators {
+		for typ := range generators {
+			t.Run(
+				service+" "+typ, func(t *testing.T) {
+					t.Parallel()
+
+					var out bytes.Buffer
+
+					require.NoError(t, RunGenerateEvent(&out, service, typ, fields))
+					assert.True(t, json.Valid(out.Bytes()))
+				},
+			)
+		}
+	}
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	grpcContentType = "application/grpc"
+	// grpcHTTPRuleField is the field number of the google.api.http option of methods.
+	grpcHTTPRuleField = 72295728
+	// grpcMaxMessageSize is the largest request message accepted, the default of gRPC servers.
+	grpcMaxMessageSize = 4 << 20
+)
+
+// gRPC status codes, https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcUnknown            = 2
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcAborted            = 10
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// grpcTranscoder accepts gRPC requests and transcodes them into the HTTP requests a transcoding
+// proxy like Envoy's gRPC-JSON transcoder sends to the API, so they reach the lambda as JSON
+// events. Responses are transcoded back into gRPC messages.
+type grpcTranscoder struct {
+	// methods are the unary methods of the descriptors, keyed by their gRPC path like
+	// "/bookstore.Bookstore/GetShelf".
+	methods map[string]grpcMethod
+}
+
+type grpcMethod struct {
+	desc protoreflect.MethodDescriptor
+	rule httpRule
+}
+
+// httpRule is the google.api.http option of a method. Methods without one are transcoded to a
+// POST of the whole message to their gRPC path, like Envoy does.
+type httpRule struct {
+	method string
+	// path is the path template, like "/v1/shelves/{shelf}/books/{book.id}".
+	path string
+	// body is the field sent as the request body, "*" for all fields not bound by the path.
+	body string
+	// responseBody is the field of the response message the response body is, the whole message
+	// when unset.
+	responseBody string
+}
+
+// loadGRPCTranscoder reads the FileDescriptorSet at path, as written by
+// `protoc --include_imports --descriptor_set_out` or `buf build -o`.
+func loadGRPCTranscoder(path string, reader fileReader) (*grpcTranscoder, error) {
+	data, err := reader.read(path)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.loadGRPCTranscoder] read file failed: %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.loadGRPCTranscoder] %s isn't a FileDescriptorSet: %w", path, err)
+	}
+
+	transcoder, err := newGRPCTranscoder(set)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.loadGRPCTranscoder] %w", err)
+	}
+
+	return transcoder, nil
+}
+
+func newGRPCTranscoder(set *descriptorpb.FileDescriptorSet) (*grpcTranscoder, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors: %w", err)
+	}
+
+	transcoder := &grpcTranscoder{methods: make(map[string]grpcMethod)}
+
+	files.RangeFiles(
+		func(file protoreflect.FileDescriptor) bool {
+			for i := range file.Services().Len() {
+				service := file.Services().Get(i)
+
+				for j := range service.Methods().Len() {
+					desc := service.Methods().Get(j)
+					path := fmt.Sprintf("/%s/%s", service.FullName(), desc.Name())
+
+					rule, ok := parseHTTPRule(desc)
+					if !ok {
+						rule = httpRule{method: http.MethodPost, path: path, body: "*"}
+					}
+
+					transcoder.methods[path] = grpcMethod{desc: desc, rule: rule}
+				}
+			}
+
+			return true
+		},
+	)
+
+	if len(transcoder.methods) == 0 {
+		return nil, errors.New("descriptors have no services")
+	}
+
+	return transcoder, nil
+}
+
+// paths returns the gRPC paths of the methods in order.
+func (t *grpcTranscoder) paths() []string {
+	paths := make([]string, 0, len(t.methods))
+	for path := range t.methods {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// handler serves gRPC requests with the transcoded requests of next, other requests are passed to
+// next as they are.
+func (t *grpcTranscoder) handler(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.Header.Get("Content-Type"), grpcContentType) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			method, ok := t.methods[r.URL.Path]
+			if !ok {
+				writeGRPCResponse(w, nil, grpcUnimplemented, "unknown method "+r.URL.Path)
+
+				return
+			}
+
+			if method.desc.IsStreamingClient() || method.desc.IsStreamingServer() {
+				writeGRPCResponse(w, nil, grpcUnimplemented, "streaming methods aren't supported")
+
+				return
+			}
+
+			data, err := readGRPCMessage(r.Body)
+			if err != nil {
+				writeGRPCResponse(w, nil, grpcInvalidArgument, err.Error())
+
+				return
+			}
+
+			input := dynamicpb.NewMessage(method.desc.Input())
+			if err = proto.Unmarshal(data, input); err != nil {
+				writeGRPCResponse(w, nil, grpcInvalidArgument, "invalid request message: "+err.Error())
+
+				return
+			}
+
+			req, err := method.request(r, input)
+			if err != nil {
+				writeGRPCResponse(w, nil, grpcInvalidArgument, err.Error())
+
+				return
+			}
+
+			logger.Debug(
+				fmt.Sprintf("Transcoded gRPC %s to %s %s", r.URL.Path, req.Method, req.URL.RequestURI()),
+			)
+
+			recorder := httptest.NewRecorder()
+			next.ServeHTTP(recorder, req)
+
+			if recorder.Code < 200 || recorder.Code > 299 {
+				writeGRPCResponse(w, nil, grpcCode(recorder.Code), grpcErrorMessage(recorder))
+
+				return
+			}
+
+			output, err := method.response(recorder.Body.Bytes())
+			if err != nil {
+				writeGRPCResponse(w, nil, grpcInternal, err.Error())
+
+				return
+			}
+
+			writeGRPCResponse(w, output, grpcOK, "")
+		},
+	)
+}
+
+// request transcodes the input message of a gRPC request r into the HTTP request of the method's
+// rule. Fields bound by the path are taken out of the body, fields in neither the path nor the
+// body are sent as query parameters.
+func (m grpcMethod) request(r *http.Request, input *dynamicpb.Message) (*http.Request, error) {
+	remaining := proto.Clone(input)
+
+	path, err := expandPathTemplate(m.rule.path, input, remaining)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+
+	query := url.Values{}
+
+	switch m.rule.body {
+	case "":
+		query = queryParameters(remaining)
+	case "*":
+		if body, err = protojson.Marshal(remaining); err != nil {
+			return nil, fmt.Errorf("marshal request body failed: %w", err)
+		}
+	default:
+		field := remaining.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(m.rule.body))
+		if field == nil {
+			return nil, fmt.Errorf("body field '%s' isn't a field of %s", m.rule.body, input.Descriptor().FullName())
+		}
+
+		if body, err = marshalField(remaining, field); err != nil {
+			return nil, err
+		}
+
+		remaining.ProtoReflect().Clear(field)
+		query = queryParameters(remaining)
+	}
+
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), m.rule.method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("transcode request failed: %w", err)
+	}
+
+	// metadata is passed on as headers, like transcoding proxies do
+	for key, values := range r.Header {
+		lower := strings.ToLower(key)
+		if lower == "content-type" || lower == "te" || strings.HasPrefix(lower, "grpc-") {
+			continue
+		}
+
+		req.Header[key] = values
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Host = r.Host
+	req.RemoteAddr = r.RemoteAddr
+
+	return req, nil
+}
+
+// response transcodes the JSON body of an HTTP response into the output message of the method.
+func (m grpcMethod) response(body []byte) ([]byte, error) {
+	output := dynamicpb.NewMessage(m.desc.Output())
+
+	if m.rule.responseBody != "" {
+		field := output.Descriptor().Fields().ByName(protoreflect.Name(m.rule.responseBody))
+		if field == nil {
+			return nil, fmt.Errorf(
+				"response body field '%s' isn't a field of %s",
+				m.rule.responseBody,
+				output.Descriptor().FullName(),
+			)
+		}
+
+		if len(bytes.TrimSpace(body)) == 0 {
+			body = []byte("null")
+		}
+
+		body = fmt.Appendf(nil, `{%q:%s}`, field.JSONName(), body)
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, output); err != nil {
+			return nil, fmt.Errorf("lambda response isn't a %s: %w", output.Descriptor().FullName(), err)
+		}
+	}
+
+	data, err := proto.Marshal(output)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response message failed: %w", err)
+	}
+
+	return data, nil
+}
+
+var pathVariable = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?}`) //nolint:gochecknoglobals
+
+// expandPathTemplate replaces the variables of template with the fields of input they name,
+// clearing them in remaining.
+func expandPathTemplate(template string, input, remaining proto.Message) (string, error) {
+	var err error
+
+	path := pathVariable.ReplaceAllStringFunc(
+		template,
+		func(variable string) string {
+			match := pathVariable.FindStringSubmatch(variable)
+
+			value, clear, fieldErr := fieldValue(input.ProtoReflect(), remaining.ProtoReflect(), match[1])
+			if fieldErr != nil {
+				err = fieldErr
+
+				return variable
+			}
+
+			clear()
+
+			// multi segment variables like {name=shelves/*} keep their slashes
+			if strings.Contains(match[2], "/") || strings.Contains(match[2], "**") {
+				segments := strings.Split(value, "/")
+				for i, segment := range segments {
+					segments[i] = url.PathEscape(segment)
+				}
+
+				return strings.Join(segments, "/")
+			}
+
+			return url.PathEscape(value)
+		},
+	)
+
+	return path, err
+}
+
+// fieldValue returns the value of the scalar field at the dotted path of message as a string,
+// and a func clearing it in remaining.
+func fieldValue(message, remaining protoreflect.Message, path string) (string, func(), error) {
+	names := strings.Split(path, ".")
+
+	for i, name := range names {
+		field := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.IsList() || field.IsMap() {
+			return "", nil, fmt.Errorf("path variable '%s' isn't a field of %s", path, message.Descriptor().FullName())
+		}
+
+		if i < len(names)-1 {
+			if field.Message() == nil {
+				return "", nil, fmt.Errorf(
+					"path variable '%s' isn't a field of %s",
+					path,
+					message.Descriptor().FullName(),
+				)
+			}
+
+			message = message.Get(field).Message()
+			remaining = remaining.Mutable(field).Message()
+
+			continue
+		}
+
+		target := remaining
+
+		return scalarString(field, message.Get(field)), func() { target.Clear(field) }, nil
+	}
+
+	return "", nil, fmt.Errorf("empty path variable in '%s'", path)
+}
+
+// queryParameters returns the populated scalar fields of message as query parameters, nested
+// messages with dotted names.
+func queryParameters(message proto.Message) url.Values {
+	query := url.Values{}
+
+	var add func(prefix string, m protoreflect.Message)
+
+	add = func(prefix string, m protoreflect.Message) {
+		m.Range(
+			func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+				name := prefix + string(field.Name())
+
+				switch {
+				case field.IsMap():
+				case field.IsList():
+					if field.Message() == nil {
+						for i := range value.List().Len() {
+							query.Add(name, scalarString(field, value.List().Get(i)))
+						}
+					}
+				case field.Message() != nil:
+					add(name+".", value.Message())
+				default:
+					query.Add(name, scalarString(field, value))
+				}
+
+				return true
+			},
+		)
+	}
+
+	add("", message.ProtoReflect())
+
+	return query
+}
+
+func scalarString(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch field.Kind() { //nolint:exhaustive
+	case protoreflect.EnumKind:
+		if enum := field.Enum().Values().ByNumber(value.Enum()); enum != nil {
+			return string(enum.Name())
+		}
+
+		return strconv.Itoa(int(value.Enum()))
+	case protoreflect.BytesKind:
+		return string(value.Bytes())
+	default:
+		return value.String()
+	}
+}
+
+// marshalField returns the JSON of field of message.
+func marshalField(message proto.Message, field protoreflect.FieldDescriptor) ([]byte, error) {
+	wrapper := dynamicpb.NewMessage(message.ProtoReflect().Descriptor())
+	wrapper.Set(field, message.ProtoReflect().Get(field))
+
+	data, err := protojson.Marshal(wrapper)
+	if err != nil {
+		return nil, fmt.Errorf("marshal body field failed: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("marshal body field failed: %w", err)
+	}
+
+	return fields[field.JSONName()], nil
+}
+
+// parseHTTPRule returns the google.api.http option of method. The option isn't a registered
+// extension, so it is read from the unknown fields of the method's options.
+func parseHTTPRule(method protoreflect.MethodDescriptor) (httpRule, bool) {
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok || options == nil {
+		return httpRule{}, false
+	}
+
+	var rule httpRule
+
+	found := false
+
+	consumeFields(
+		options.ProtoReflect().GetUnknown(),
+		func(num protowire.Number, value []byte) {
+			if num == grpcHTTPRuleField {
+				rule, found = decodeHTTPRule(value), true
+			}
+		},
+	)
+
+	return rule, found && rule.path != ""
+}
+
+// decodeHTTPRule decodes a google.api.HttpRule message.
+func decodeHTTPRule(data []byte) httpRule {
+	var rule httpRule
+
+	methods := map[protowire.Number]string{
+		2: http.MethodGet,    //nolint:mnd
+		3: http.MethodPut,    //nolint:mnd
+		4: http.MethodPost,   //nolint:mnd
+		5: http.MethodDelete, //nolint:mnd
+		6: http.MethodPatch,  //nolint:mnd
+	}
+
+	consumeFields(
+		data,
+		func(num protowire.Number, value []byte) {
+			switch num {
+			case 7: //nolint:mnd
+				rule.body = string(value)
+			case 8: //nolint:mnd
+				// custom patterns hold their method in kind and the template in path
+				consumeFields(
+					value,
+					func(num protowire.Number, value []byte) {
+						switch num {
+						case 1:
+							rule.method = string(value)
+						case 2: //nolint:mnd
+							rule.path = string(value)
+						}
+					},
+				)
+			case 12: //nolint:mnd
+				rule.responseBody = string(value)
+			default:
+				if method, ok := methods[num]; ok {
+					rule.method, rule.path = method, string(value)
+				}
+			}
+		},
+	)
+
+	return rule
+}
+
+// consumeFields calls fn with the length delimited fields of the protobuf message data, other
+// fields are skipped.
+func consumeFields(data []byte, fn func(num protowire.Number, value []byte)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return
+		}
+
+		data = data[n:]
+
+		if typ == protowire.BytesType {
+			value, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return
+			}
+
+			fn(num, value)
+
+			data = data[m:]
+
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return
+		}
+
+		data = data[m:]
+	}
+}
+
+// readGRPCMessage reads the single length prefixed message of a unary gRPC request body.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, 5) //nolint:mnd
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("read message failed: %w", err)
+	}
+
+	if prefix[0] != 0 {
+		return nil, errors.New("compressed messages aren't supported")
+	}
+
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d", size, grpcMaxMessageSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("read message failed: %w", err)
+	}
+
+	return data, nil
+}
+
+// writeGRPCResponse writes the response of a gRPC call ending with status, with message when the
+// call succeeded.
+func writeGRPCResponse(w http.ResponseWriter, message []byte, status int, statusMessage string) {
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	if status == grpcOK {
+		prefix := make([]byte, 5) //nolint:mnd
+
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(message))) //nolint:gosec
+
+		_, _ = w.Write(prefix)
+		_, _ = w.Write(message)
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(status))
+
+	if statusMessage != "" {
+		w.Header().Set("Grpc-Message", encodeGRPCMessage(statusMessage))
+	}
+}
+
+// encodeGRPCMessage percent encodes the bytes of message that aren't printable ASCII, as gRPC
+// requires for grpc-message.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+
+	for i := range len(message) {
+		c := message[i]
+		if c < ' ' || c > '~' || c == '%' {
+			_, _ = fmt.Fprintf(&b, "%%%02X", c)
+
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// grpcCode maps the HTTP status of a lambda response to a gRPC status code, like transcoding
+// proxies do.
+func grpcCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcNotFound
+	case http.StatusConflict:
+		return grpcAborted
+	case http.StatusPreconditionFailed:
+		return grpcFailedPrecondition
+	case http.StatusTooManyRequests:
+		return grpcResourceExhausted
+	case 499: //nolint:mnd
+		return grpcCanceled
+	case http.StatusNotImplemented:
+		return grpcUnimplemented
+	case http.StatusServiceUnavailable:
+		return grpcUnavailable
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	}
+
+	if status >= http.StatusInternalServerError {
+		return grpcInternal
+	}
+
+	return grpcUnknown
+}
+
+// grpcErrorMessage returns the body of a failed response, or its status text when it has none.
+// The message of JSON errors like {"message": "..."} is returned on its own.
+func grpcErrorMessage(recorder *httptest.ResponseRecorder) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err == nil && body.Message != "" {
+		return body.Message
+	}
+
+	if message := strings.TrimSpace(recorder.Body.String()); message != "" {
+		return message
+	}
+
+	return http.StatusText(recorder.Code)
+}
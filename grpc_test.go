@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestGRPCTranscoder(t *testing.T) {
+	t.Parallel()
+
+	transcoder, err := newGRPCTranscoder(bookstoreDescriptors())
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		path           string
+		request        string
+		responseStatus int
+		responseBody   string
+		// expectedRequest is the method, URL and body of the transcoded request.
+		expectedRequest string
+		expectedStatus  string
+		expectedMessage string
+		expectedOutput  string
+	}{
+		"path variables and query parameters": {
+			path:            "/bookstore.Bookstore/GetShelf",
+			request:         `{"shelf": "7", "view": "FULL"}`,
+			responseStatus:  http.StatusOK,
+			responseBody:    `{"id": "7", "theme": "sci-fi", "unknown": true}`,
+			expectedRequest: "GET /v1/shelves/7?view=FULL ",
+			expectedStatus:  "0",
+			expectedOutput:  `{"id": "7", "theme": "sci-fi"}`,
+		},
+		"body field": {
+			path:            "/bookstore.Bookstore/CreateBook",
+			request:         `{"shelf": "1", "book": {"title": "Dune"}}`,
+			responseStatus:  http.StatusCreated,
+			responseBody:    `"Dune"`,
+			expectedRequest: `POST /v1/shelves/1/books {"title":"Dune"}`,
+			expectedStatus:  "0",
+			expectedOutput:  `{"title": "Dune"}`,
+		},
+		"method without http rule": {
+			path:            "/bookstore.Bookstore/Echo",
+			request:         `{"id": "3"}`,
+			responseStatus:  http.StatusOK,
+			expectedRequest: `POST /bookstore.Bookstore/Echo {"id":"3"}`,
+			expectedStatus:  "0",
+			expectedOutput:  `{}`,
+		},
+		"failed response": {
+			path:            "/bookstore.Bookstore/GetShelf",
+			request:         `{"shelf": "8"}`,
+			responseStatus:  http.StatusNotFound,
+			responseBody:    "shelf 8 not found\n",
+			expectedRequest: "GET /v1/shelves/8 ",
+			expectedStatus:  "5",
+			expectedMessage: "shelf 8 not found",
+		},
+		"unknown method": {
+			path:            "/bookstore.Bookstore/DeleteShelf",
+			request:         `{}`,
+			expectedStatus:  "12",
+			expectedMessage: "unknown method /bookstore.Bookstore/DeleteShelf",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var transcoded string
+
+				next := http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						body, _ := io.ReadAll(r.Body)
+						transcoded = r.Method + " " + r.URL.RequestURI() + " " + string(body)
+
+						w.WriteHeader(tc.responseStatus)
+						_, _ = w.Write([]byte(tc.responseBody))
+					},
+				)
+
+				method := transcoder.methods[tc.path]
+
+				var input proto.Message = &descriptorpb.FileDescriptorSet{}
+				if method.desc != nil {
+					input = grpcTestMessage(t, method.desc.Input(), tc.request)
+				}
+
+				req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(grpcFrame(t, input)))
+				req.Header.Set("Content-Type", "application/grpc+proto")
+
+				rec := httptest.NewRecorder()
+				transcoder.handler(next, slog.Default()).ServeHTTP(rec, req)
+
+				res := rec.Result()
+				_ = res.Body.Close()
+
+				assert.Equal(t, tc.expectedRequest, transcoded)
+				assert.Equal(t, grpcContentType, res.Header.Get("Content-Type"))
+				assert.Equal(t, tc.expectedStatus, res.Trailer.Get("Grpc-Status"))
+				assert.Equal(t, tc.expectedMessage, res.Trailer.Get("Grpc-Message"))
+
+				if tc.expectedOutput == "" {
+					assert.Empty(t, rec.Body.Bytes())
+
+					return
+				}
+
+				output := dynamicpb.NewMessage(method.desc.Output())
+				require.NoError(t, proto.Unmarshal(rec.Body.Bytes()[5:], output))
+				assert.True(
+					t,
+					proto.Equal(grpcTestMessage(t, method.desc.Output(), tc.expectedOutput), output),
+					"unexpected output %v",
+					output,
+				)
+			},
+		)
+	}
+}
+
+func TestGRPCTranscoderPassesOtherRequests(t *testing.T) {
+	t.Parallel()
+
+	transcoder, err := newGRPCTranscoder(bookstoreDescriptors())
+	require.NoError(t, err)
+
+	next := http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/shelves/1", nil)
+	rec := httptest.NewRecorder()
+	transcoder.handler(next, slog.Default()).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+}
+
+func TestGRPCCode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[int]int{
+		http.StatusBadRequest:         grpcInvalidArgument,
+		http.StatusUnauthorized:       grpcUnauthenticated,
+		http.StatusTooManyRequests:    grpcResourceExhausted,
+		http.StatusBadGateway:         grpcInternal,
+		http.StatusGatewayTimeout:     grpcDeadlineExceeded,
+		http.StatusTemporaryRedirect:  grpcUnknown,
+		http.StatusServiceUnavailable: grpcUnavailable,
+	}
+
+	for status, expected := range tests {
+		assert.Equal(t, expected, grpcCode(status), status)
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "100%25 f%C3%A4iled%0A", encodeGRPCMessage("100% fäiled\n"))
+}
+
+// bookstoreDescriptors returns the descriptors of a bookstore service, with google.api.http options
+// on GetShelf and CreateBook.
+func bookstoreDescriptors() *descriptorpb.FileDescriptorSet {
+	type fieldType = descriptorpb.FieldDescriptorProto_Type
+
+	field := func(name string, number int32, kind fieldType) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     kind.Enum(),
+		}
+	}
+
+	book := field("book", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	book.TypeName = proto.String(".bookstore.Book")
+
+	// httpOption returns method options with a google.api.http option of the HttpRule fields
+	httpOption := func(fields map[protowire.Number]string) *descriptorpb.MethodOptions {
+		var rule []byte
+		for number, value := range fields {
+			rule = protowire.AppendTag(rule, number, protowire.BytesType)
+			rule = protowire.AppendString(rule, value)
+		}
+
+		options := &descriptorpb.MethodOptions{}
+		options.ProtoReflect().SetUnknown(
+			protowire.AppendBytes(protowire.AppendTag(nil, grpcHTTPRuleField, protowire.BytesType), rule),
+		)
+
+		return options
+	}
+
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("bookstore.proto"),
+				Package: proto.String("bookstore"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("GetShelfRequest"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("shelf", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+							field("view", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						},
+					},
+					{
+						Name: proto.String("Shelf"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+							field("theme", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						},
+					},
+					{
+						Name: proto.String("CreateBookRequest"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("shelf", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+							book,
+						},
+					},
+					{
+						Name: proto.String("Book"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("title", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						},
+					},
+				},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{
+						Name: proto.String("Bookstore"),
+						Method: []*descriptorpb.MethodDescriptorProto{
+							{
+								Name:       proto.String("GetShelf"),
+								InputType:  proto.String(".bookstore.GetShelfRequest"),
+								OutputType: proto.String(".bookstore.Shelf"),
+								Options:    httpOption(map[protowire.Number]string{2: "/v1/shelves/{shelf}"}),
+							},
+							{
+								Name:       proto.String("CreateBook"),
+								InputType:  proto.String(".bookstore.CreateBookRequest"),
+								OutputType: proto.String(".bookstore.Book"),
+								Options: httpOption(
+									map[protowire.Number]string{4: "/v1/shelves/{shelf}/books", 7: "book", 12: "title"},
+								),
+							},
+							{
+								Name:       proto.String("Echo"),
+								InputType:  proto.String(".bookstore.Shelf"),
+								OutputType: proto.String(".bookstore.Shelf"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func grpcTestMessage(t *testing.T, desc protoreflect.MessageDescriptor, data string) proto.Message {
+	t.Helper()
+
+	message := dynamicpb.NewMessage(desc)
+	require.NoError(t, protojson.Unmarshal([]byte(data), message))
+
+	return message
+}
+
+// grpcFrame returns message as the length prefixed body of a gRPC request.
+func grpcFrame(t *testing.T, message proto.Message) string {
+	t.Helper()
+
+	data, err := proto.Marshal(message)
+	require.NoError(t, err)
+
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data))) //nolint:gosec
+
+	return string(prefix) + string(data)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"go.starlark.net/lib/json"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+const (
+	// hookRequest is called with the event and route before the lambda is invoked.
+	hookRequest = "on_request"
+	// hookResponse is called with the event, response payload and route after the lambda responded.
+	hookResponse = "on_response"
+)
+
+// hookScript is a Starlark script of request and response hooks.
+type hookScript struct {
+	name string
+	// globals are frozen after the script ran, so hooks can be called concurrently.
+	globals starlark.StringDict
+}
+
+// scriptHooks are the hook scripts invocations pass through, in order. They are a lighter
+// alternative to WASM plugins: a script defines on_request(event, route), which returns the event
+// to invoke the lambda with or respond(payload) to answer without invoking it, and
+// on_response(event, response, route), which returns the response. Hooks returning None keep the
+// event or response. Outside the api command route is empty.
+type scriptHooks struct {
+	scripts []*hookScript
+	logger  *slog.Logger
+}
+
+// loadHooks runs the hook scripts at paths.
+func loadHooks(paths []string, reader fileReader, logger *slog.Logger) (*scriptHooks, error) {
+	hooks := &scriptHooks{logger: logger}
+
+	for _, path := range paths {
+		src, err := reader.read(path)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.loadHooks] read hook '%s' failed: %w", path, err)
+		}
+
+		script := &hookScript{name: filepath.Base(path)}
+
+		script.globals, err = starlark.ExecFileOptions(
+			&syntax.FileOptions{},
+			hooks.thread(script.name),
+			path,
+			src,
+			hookPredeclared(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.loadHooks] hook '%s': %w", path, err)
+		}
+
+		if !script.globals.Has(hookRequest) && !script.globals.Has(hookResponse) {
+			return nil, fmt.Errorf(
+				"[in lambdalocal.loadHooks] hook '%s' must define %s or %s",
+				path,
+				hookRequest,
+				hookResponse,
+			)
+		}
+
+		script.globals.Freeze()
+		hooks.scripts = append(hooks.scripts, script)
+	}
+
+	return hooks, nil
+}
+
+// thread returns a thread to run a script on, its print statements are logged.
+func (h *scriptHooks) thread(name string) *starlark.Thread {
+	return &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			h.logger.Info(msg, "hook", name)
+		},
+	}
+}
+
+// caller returns a lambdaCaller that passes events and responses of caller through the hooks,
+// for invocations of route. Without hooks caller is returned as is.
+func (h *scriptHooks) caller(caller lambdaCaller, route string) lambdaCaller {
+	if h == nil || len(h.scripts) == 0 {
+		return caller
+	}
+
+	return hookCaller{lambdaCaller: caller, hooks: h, route: route}
+}
+
+type hookCaller struct {
+	lambdaCaller
+	hooks *scriptHooks
+	route string
+}
+
+func (c hookCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	route := starlark.String(c.route)
+
+	for _, script := range c.hooks.scripts {
+		event, err := c.hooks.decode(script.name, data)
+		if err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.hookCaller.Invoke] %w", err)
+		}
+
+		result, err := c.hooks.call(script, hookRequest, event, route)
+		if err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.hookCaller.Invoke] %w", err)
+		}
+
+		// respond(payload) answers in place of the lambda
+		if mocked, ok := result.(*hookMockedResponse); ok {
+			payload, err := c.hooks.encode(script.name, mocked.payload)
+			if err != nil {
+				return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.hookCaller.Invoke] %w", err)
+			}
+
+			c.hooks.logger.Info("Hook responded without invoking the lambda", "hook", script.name)
+
+			return messages.InvokeResponse{Payload: payload}, nil
+		}
+
+		if result != starlark.None {
+			if data, err = c.hooks.encode(script.name, result); err != nil {
+				return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.hookCaller.Invoke] %w", err)
+			}
+		}
+	}
+
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+	if err != nil || response.Error != nil {
+		return response, err //nolint:wrapcheck
+	}
+
+	for _, script := range c.hooks.scripts {
+		if response.Payload, err = c.respond(script, data, response.Payload, route); err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.hookCaller.Invoke] %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// respond passes the response payload to the on_response hook of script and returns the payload
+// it returned.
+func (c hookCaller) respond(script *hookScript, data, payload []byte, route starlark.String) ([]byte, error) {
+	if !script.globals.Has(hookResponse) {
+		return payload, nil
+	}
+
+	event, err := c.hooks.decode(script.name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.hooks.decode(script.name, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.hooks.call(script, hookResponse, event, response, route)
+	if err != nil || result == starlark.None {
+		return payload, err
+	}
+
+	return c.hooks.encode(script.name, result)
+}
+
+// call calls hook of script with args. Scripts without hook return None.
+func (h *scriptHooks) call(script *hookScript, hook string, args ...starlark.Value) (starlark.Value, error) {
+	function, ok := script.globals[hook]
+	if !ok {
+		return starlark.None, nil
+	}
+
+	result, err := starlark.Call(h.thread(script.name), function, args, nil)
+	if err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return nil, fmt.Errorf("hook %s failed in %s: %s", script.name, hook, evalErr.Backtrace())
+		}
+
+		return nil, fmt.Errorf("hook %s failed in %s: %w", script.name, hook, err)
+	}
+
+	return result, nil
+}
+
+// decode decodes the JSON payload into a Starlark value. An empty payload is None.
+func (h *scriptHooks) decode(name string, payload []byte) (starlark.Value, error) {
+	if len(payload) == 0 {
+		return starlark.None, nil
+	}
+
+	decode := json.Module.Members["decode"]
+
+	value, err := starlark.Call(h.thread(name), decode, starlark.Tuple{starlark.String(payload)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hook %s can't decode the payload: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// encode encodes value as JSON.
+func (h *scriptHooks) encode(name string, value starlark.Value) ([]byte, error) {
+	encoded, err := starlark.Call(h.thread(name), json.Module.Members["encode"], starlark.Tuple{value}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hook %s returned a value that isn't JSON: %w", name, err)
+	}
+
+	return []byte(encoded.(starlark.String)), nil //nolint:forcetypeassert
+}
+
+// hookMockedResponse is returned by respond(payload) to answer without invoking the lambda.
+type hookMockedResponse struct {
+	payload starlark.Value
+}
+
+func (r *hookMockedResponse) String() string       { return fmt.Sprintf("respond(%s)", r.payload) }
+func (r *hookMockedResponse) Type() string         { return "response" }
+func (r *hookMockedResponse) Freeze()              { r.payload.Freeze() }
+func (r *hookMockedResponse) Truth() starlark.Bool { return starlark.True }
+func (r *hookMockedResponse) Hash() (uint32, error) {
+	return 0, errors.New("unhashable type: response")
+}
+
+// hookPredeclared returns the names available to hook scripts: the json, base64 and time modules
+// and respond.
+func hookPredeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"json": json.Module,
+		"time": starlarktime.Module,
+		"base64": &starlarkstruct.Module{
+			Name: "base64",
+			Members: starlark.StringDict{
+				"encode": starlark.NewBuiltin("base64.encode", base64Encode),
+				"decode": starlark.NewBuiltin("base64.decode", base64Decode),
+			},
+		},
+		"respond": starlark.NewBuiltin("respond", respond),
+	}
+}
+
+// respond returns its payload as the response of the invocation, the lambda isn't invoked.
+func respond(
+	_ *starlark.Thread,
+	b *starlark.Builtin,
+	args starlark.Tuple,
+	kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var payload starlark.Value
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &payload); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &hookMockedResponse{payload: payload}, nil
+}
+
+// base64Encode encodes a string with the standard base64 encoding, like the bodies of binary
+// events and responses.
+func base64Encode(
+	_ *starlark.Thread,
+	b *starlark.Builtin,
+	args starlark.Tuple,
+	kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var s string
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return starlark.String(base64.StdEncoding.EncodeToString([]byte(s))), nil
+}
+
+// base64Decode decodes a string encoded with the standard base64 encoding.
+func base64Decode(
+	_ *starlark.Thread,
+	b *starlark.Builtin,
+	args starlark.Tuple,
+	kwargs []starlark.Tuple,
+) (starlark.Value, error) {
+	var s string
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	return starlark.String(decoded), nil
+}
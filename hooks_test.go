@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookCaller(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		scripts          []string
+		route            string
+		lambdaResponse   messages.InvokeResponse
+		lambdaErr        error
+		expectedEvent    string
+		expectedResponse messages.InvokeResponse
+		expectedErrStr   string
+	}{
+		"event and response are transformed": {
+			scripts: []string{`
+def on_request(event, route):
+    event["route"] = route
+    return event
+
+def on_response(event, response, route):
+    response["body"] = base64.encode(json.encode({"path": event["path"]}))
+    return response
+`},
+			route:            "GET /users",
+			lambdaResponse:   messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)},
+			expectedEvent:    `{"path":"/","route":"GET /users"}`,
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{"body":"eyJwYXRoIjoiLyJ9","statusCode":200}`)},
+		},
+		"None keeps the event and response": {
+			scripts: []string{`
+def on_request(event, route):
+    print("invoking", route)
+
+def on_response(event, response, route):
+    return None
+`},
+			lambdaResponse:   messages.InvokeResponse{Payload: []byte(`{"statusCode": 200}`)},
+			expectedEvent:    `{"path":"/"}`,
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{"statusCode": 200}`)},
+		},
+		"respond answers without invoking the lambda": {
+			scripts: []string{`
+def on_request(event, route):
+    if route == "GET /health":
+        return respond({"statusCode": 200, "body": "ok"})
+`},
+			route:            "GET /health",
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{"body":"ok","statusCode":200}`)},
+		},
+		"scripts run in order": {
+			scripts: []string{
+				`
+def on_request(event, route):
+    return {"first": True}
+`,
+				`
+def on_request(event, route):
+    event["second"] = time.parse_duration("1s") == time.second
+    return event
+`,
+			},
+			lambdaResponse:   messages.InvokeResponse{Payload: []byte(`{}`)},
+			expectedEvent:    `{"first":true,"second":true}`,
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{}`)},
+		},
+		"function errors are not transformed": {
+			scripts: []string{`
+def on_response(event, response, route):
+    return {"changed": True}
+`},
+			lambdaResponse:   messages.InvokeResponse{Error: &messages.InvokeResponse_Error{Message: "boom"}},
+			expectedEvent:    `{"path":"/"}`,
+			expectedResponse: messages.InvokeResponse{Error: &messages.InvokeResponse_Error{Message: "boom"}},
+		},
+		"invoke errors are returned": {
+			scripts: []string{`
+def on_request(event, route):
+    return event
+`},
+			lambdaErr:      errors.New("connection refused"),
+			expectedEvent:  `{"path":"/"}`,
+			expectedErrStr: "connection refused",
+		},
+		"failing hook fails the invocation": {
+			scripts: []string{`
+def on_request(event, route):
+    fail("no tenant")
+`},
+			expectedErrStr: "[in lambdalocal.hookCaller.Invoke] hook hook-0.star failed in on_request",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+
+				paths := make([]string, 0, len(tc.scripts))
+				for i, script := range tc.scripts {
+					path := "hook-" + strconv.Itoa(i) + ".star"
+					mockReader.On("read", path).Return([]byte(script), nil)
+
+					paths = append(paths, path)
+				}
+
+				hooks, err := loadHooks(paths, mockReader, slog.Default())
+				require.NoError(t, err)
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				if tc.expectedEvent != "" {
+					mockLambdaRPC.On("Invoke", []byte(tc.expectedEvent)).Return(tc.lambdaResponse, tc.lambdaErr)
+				}
+
+				response, err := hooks.caller(mockLambdaRPC, tc.route).
+					Invoke(context.Background(), []byte(`{"path":"/"}`))
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedResponse, response)
+				mockLambdaRPC.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+func TestLoadHooks(t *testing.T) {
+	t.Parallel()
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "invalid.star").Return([]byte("def on_request("), nil)
+	mockReader.On("read", "empty.star").Return([]byte("x = 1"), nil)
+
+	_, err := loadHooks([]string{"invalid.star"}, mockReader, slog.Default())
+	assert.ErrorContains(t, err, "[in lambdalocal.loadHooks] hook 'invalid.star':")
+
+	_, err = loadHooks([]string{"empty.star"}, mockReader, slog.Default())
+	assert.ErrorContains(t, err, "hook 'empty.star' must define on_request or on_response")
+
+	hooks, err := loadHooks(nil, mockReader, slog.Default())
+	require.NoError(t, err)
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	assert.Equal(t, mockLambdaRPC, hooks.caller(mockLambdaRPC, ""), "callers are kept without hooks")
+}
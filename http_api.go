@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+)
+
+const (
+	// payloadFormatV1 is the REST API (and HttpApi 1.0) proxy event format.
+	payloadFormatV1 = "1.0"
+	// payloadFormatV2 is the HttpApi 2.0 proxy event format.
+	payloadFormatV2 = "2.0"
+
+	localAccountID = "123456789012"
+	localAPIID     = "local"
+)
+
+type httpAPIEvent struct {
+	Version               string                `json:"version"`
+	RouteKey              string                `json:"routeKey"`
+	RawPath               string                `json:"rawPath"`
+	RawQueryString        string                `json:"rawQueryString"`
+	Cookies               []string              `json:"cookies,omitempty"`
+	Headers               map[string]string     `json:"headers"`
+	QueryStringParameters map[string]string     `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string     `json:"pathParameters,omitempty"`
+	RequestContext        httpAPIRequestContext `json:"requestContext"`
+	Body                  string                `json:"body,omitempty"`
+	IsBase64Encoded       bool                  `json:"isBase64Encoded"`
+}
+
+type httpAPIRequestContext struct {
+	AccountID    string                    `json:"accountId"`
+	APIID        string                    `json:"apiId"`
+	Authorizer   *httpAPIAuthorizerContext `json:"authorizer,omitempty"`
+	DomainName   string                    `json:"domainName"`
+	DomainPrefix string                    `json:"domainPrefix"`
+	HTTP         httpAPIRequestContextHTTP `json:"http"`
+	RequestID    string                    `json:"requestId"`
+	RouteKey     string                    `json:"routeKey"`
+	Stage        string                    `json:"stage"`
+	Time         string                    `json:"time"`
+	TimeEpoch    int64                     `json:"timeEpoch"`
+}
+
+// httpAPIAuthorizerContext holds the claims of a JWT authorizer, or the IAM identity of the caller,
+// in payload format 2.0 events.
+type httpAPIAuthorizerContext struct {
+	JWT *httpAPIJWTAuthorizer `json:"jwt,omitempty"`
+	IAM *httpAPIIAMAuthorizer `json:"iam,omitempty"`
+}
+
+type httpAPIJWTAuthorizer struct {
+	Claims map[string]string `json:"claims"`
+	Scopes []string          `json:"scopes"`
+}
+
+type httpAPIRequestContextHTTP struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+// parseHTTPAPIRequest builds an HttpApi payload format 2.0 event from r.
+func parseHTTPAPIRequest(r *http.Request, pathParamKeys []string, route apiRoute) ([]byte, error) {
+	requestBody, err := readRequestBody(r.Body, bodyMemoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseHTTPAPIRequest] failed to read request body: %w", err)
+	}
+	defer func() {
+		_ = requestBody.Close()
+	}()
+
+	pathParams := make(map[string]string, len(pathParamKeys))
+
+	for _, paramKey := range pathParamKeys {
+		paramValue := r.PathValue(paramKey)
+		if paramValue != "" {
+			pathParams[paramKey] = paramValue
+		}
+	}
+
+	// 2.0 headers are lower case and multiple values are joined with commas, cookies are moved to
+	// their own field
+	headers := make(map[string]string, len(r.Header))
+
+	var cookies []string
+
+	for key, values := range r.Header {
+		if key == "Cookie" {
+			for _, value := range values {
+				for _, cookie := range strings.Split(value, ";") {
+					cookies = append(cookies, strings.TrimSpace(cookie))
+				}
+			}
+
+			continue
+		}
+
+		headers[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+
+	query := r.URL.Query()
+	queryStringParameters := make(map[string]string, len(query))
+
+	for key, values := range query {
+		queryStringParameters[key] = strings.Join(values, ",")
+	}
+
+	body, isBase64Encoded := requestBody.field(isBinaryHTTPAPIRequest(r.Header, route.binaryMediaTypes))
+
+	now := time.Now()
+	routeKey := route.routeKey()
+	domainName := r.Host
+
+	caller := route.identity.resolve(r)
+
+	var authorizer *httpAPIAuthorizerContext
+	if claims := jwtClaimsFrom(r.Context()); claims != nil {
+		authorizer = &httpAPIAuthorizerContext{
+			JWT: &httpAPIJWTAuthorizer{Claims: claims.flatClaims(), Scopes: claims.scopes},
+		}
+	}
+
+	if caller.userARN != "" {
+		if authorizer == nil {
+			authorizer = &httpAPIAuthorizerContext{}
+		}
+
+		authorizer.IAM = &httpAPIIAMAuthorizer{AccountID: localAccountID, UserARN: caller.userARN}
+	}
+
+	eventByte, err := requestBody.marshal(
+		httpAPIEvent{
+			Version:               payloadFormatV2,
+			RouteKey:              routeKey,
+			RawPath:               r.URL.Path,
+			RawQueryString:        r.URL.RawQuery,
+			Cookies:               cookies,
+			Headers:               headers,
+			QueryStringParameters: queryStringParameters,
+			PathParameters:        pathParams,
+			RequestContext: httpAPIRequestContext{
+				AccountID:    localAccountID,
+				APIID:        localAPIID,
+				Authorizer:   authorizer,
+				DomainName:   domainName,
+				DomainPrefix: strings.Split(domainName, ".")[0],
+				HTTP: httpAPIRequestContextHTTP{
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Protocol:  r.Proto,
+					SourceIP:  caller.sourceIP,
+					UserAgent: caller.userAgent,
+				},
+				RequestID: requestID(r),
+				RouteKey:  routeKey,
+				Stage:     "$default",
+				Time:      now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+				TimeEpoch: now.UnixMilli(),
+			},
+			Body:            body,
+			IsBase64Encoded: isBase64Encoded,
+		},
+		isBase64Encoded,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseHTTPAPIRequest] marshal event failed: %w", err)
+	}
+
+	return eventByte, nil
+}
+
+// returnHTTPAPIResponse writes a payload format 2.0 response. Like API Gateway, a payload that
+// isn't an object with a statusCode is returned as a 200 JSON body.
+func returnHTTPAPIResponse(w http.ResponseWriter, invokeResponse messages.InvokeResponse) error {
+	return writeHTTPAPIResponse(w, invokeResponse, returnHTTPResponse)
+}
+
+// writeHTTPAPIResponse writes a payload format 2.0 response, with returnResponse for payloads in
+// the response format.
+func writeHTTPAPIResponse(
+	w http.ResponseWriter,
+	invokeResponse messages.InvokeResponse,
+	returnResponse func(http.ResponseWriter, messages.InvokeResponse) error,
+) error {
+	if invokeResponse.Error != nil {
+		return returnResponse(w, invokeResponse)
+	}
+
+	shape := struct {
+		StatusCode *int `json:"statusCode"`
+	}{}
+
+	if err := json.Unmarshal(invokeResponse.Payload, &shape); err != nil || shape.StatusCode == nil {
+		w.Header().Set("Content-Type", "application/json")
+
+		return writeBody(w, http.StatusOK, invokeResponse.Payload)
+	}
+
+	return returnResponse(w, invokeResponse)
+}
+
+// requestID returns the correlation id set by the gateway handler, or a new id when the request
+// didn't pass through it.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+
+	return uuid.New().String()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPAPIRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method   string
+		target   string
+		body     string
+		headers  map[string][]string
+		route    apiRoute
+		expected events.APIGatewayV2HTTPRequest
+	}{
+		"GET request with query params and cookies": {
+			method: http.MethodGet,
+			target: "/users?id=123&tag=a&tag=b",
+			headers: map[string][]string{
+				"Content-Type": {"application/json"},
+				"Accept":       {"text/html", "application/json"},
+				"Cookie":       {"session=abc; theme=dark"},
+			},
+			route: apiRoute{method: http.MethodGet, path: "/users", payloadFormat: payloadFormatV2},
+			expected: events.APIGatewayV2HTTPRequest{
+				Version:        payloadFormatV2,
+				RouteKey:       "GET /users",
+				RawPath:        "/users",
+				RawQueryString: "id=123&tag=a&tag=b",
+				Cookies:        []string{"session=abc", "theme=dark"},
+				Headers: map[string]string{
+					"content-type": "application/json",
+					"accept":       "text/html,application/json",
+				},
+				QueryStringParameters: map[string]string{"id": "123", "tag": "a,b"},
+			},
+		},
+		"POST request with binary body": {
+			method:  http.MethodPost,
+			target:  "/upload",
+			body:    "\x89PNG",
+			headers: map[string][]string{"Content-Type": {"image/png"}},
+			route:   apiRoute{method: http.MethodPost, path: "/upload", payloadFormat: payloadFormatV2},
+			expected: events.APIGatewayV2HTTPRequest{
+				Version:         payloadFormatV2,
+				RouteKey:        "POST /upload",
+				RawPath:         "/upload",
+				Headers:         map[string]string{"content-type": "image/png"},
+				Body:            "iVBORw==",
+				IsBase64Encoded: true,
+			},
+		},
+		"POST request with body": {
+			method: http.MethodPost,
+			target: "/users",
+			body:   `{"name": "test"}`,
+			route:  apiRoute{method: http.MethodPost, path: "/users", payloadFormat: payloadFormatV2},
+			expected: events.APIGatewayV2HTTPRequest{
+				Version:  payloadFormatV2,
+				RouteKey: "POST /users",
+				RawPath:  "/users",
+				Headers:  map[string]string{},
+				Body:     `{"name": "test"}`,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				req := httptest.NewRequest(tc.method, tc.target, bytes.NewReader([]byte(tc.body)))
+				req.Header = tc.headers
+
+				eventByte, err := parseHTTPAPIRequest(req, nil, tc.route)
+				require.NoError(t, err)
+
+				var actual events.APIGatewayV2HTTPRequest
+				require.NoError(t, json.Unmarshal(eventByte, &actual))
+
+				assert.Equal(t, tc.route.routeKey(), actual.RequestContext.RouteKey)
+				assert.Equal(t, tc.method, actual.RequestContext.HTTP.Method)
+				assert.Equal(t, "192.0.2.1", actual.RequestContext.HTTP.SourceIP)
+				assert.NotEmpty(t, actual.RequestContext.RequestID)
+
+				actual.RequestContext = events.APIGatewayV2HTTPRequestContext{}
+				assert.Equal(t, tc.expected, actual)
+			},
+		)
+	}
+}
+
+func TestParseHTTPAPIRequestIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		identity          callerIdentity
+		expectedSourceIP  string
+		expectedUserAgent string
+		expectedUserARN   string
+	}{
+		"identity of the request": {
+			expectedSourceIP:  "192.0.2.1",
+			expectedUserAgent: "curl/8.0",
+		},
+		"overrides": {
+			identity: callerIdentity{
+				sourceIP:  "203.0.113.7",
+				userAgent: "Mobile/1.0",
+				userARN:   "arn:aws:iam::123456789012:user/alice",
+			},
+			expectedSourceIP:  "203.0.113.7",
+			expectedUserAgent: "Mobile/1.0",
+			expectedUserARN:   "arn:aws:iam::123456789012:user/alice",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				req := httptest.NewRequest(http.MethodGet, "/users", nil)
+				req.Header.Set("User-Agent", "curl/8.0")
+
+				route := apiRoute{
+					method:        http.MethodGet,
+					path:          "/users",
+					payloadFormat: payloadFormatV2,
+					identity:      tc.identity,
+				}
+
+				eventByte, err := parseHTTPAPIRequest(req, nil, route)
+				require.NoError(t, err)
+
+				var actual events.APIGatewayV2HTTPRequest
+				require.NoError(t, json.Unmarshal(eventByte, &actual))
+
+				assert.Equal(t, tc.expectedSourceIP, actual.RequestContext.HTTP.SourceIP)
+				assert.Equal(t, tc.expectedUserAgent, actual.RequestContext.HTTP.UserAgent)
+
+				if tc.expectedUserARN == "" {
+					assert.Nil(t, actual.RequestContext.Authorizer)
+
+					return
+				}
+
+				require.NotNil(t, actual.RequestContext.Authorizer)
+				assert.Equal(t, tc.expectedUserARN, actual.RequestContext.Authorizer.IAM.UserARN)
+			},
+		)
+	}
+}
+
+func TestReturnHTTPAPIResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payload         string
+		expectedStatus  int
+		expectedBody    string
+		expectedCookies []string
+	}{
+		"structured response": {
+			payload:         `{"statusCode": 201, "body": "created", "cookies": ["a=1", "b=2"]}`,
+			expectedStatus:  http.StatusCreated,
+			expectedBody:    "created",
+			expectedCookies: []string{"a=1", "b=2"},
+		},
+		"inferred response from object": {
+			payload:        `{"message": "hello"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message": "hello"}`,
+		},
+		"inferred response from string": {
+			payload:        `"hello"`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"hello"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				rec := httptest.NewRecorder()
+				require.NoError(t, returnHTTPAPIResponse(rec, messages.InvokeResponse{Payload: []byte(tc.payload)}))
+
+				result := rec.Result()
+				defer func() {
+					_ = result.Body.Close()
+				}()
+
+				body, _ := io.ReadAll(result.Body)
+
+				assert.Equal(t, tc.expectedStatus, result.StatusCode)
+				assert.Equal(t, tc.expectedBody, string(body))
+				assert.Equal(t, tc.expectedCookies, result.Header.Values("Set-Cookie"))
+			},
+		)
+	}
+}
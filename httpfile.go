@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// httpFileVariableRegex matches {{name}} and system variables like {{$processEnv HOME}}.
+var httpFileVariableRegex = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`) //nolint:gochecknoglobals
+
+// httpFileRequestLineRegex matches request lines like "POST /orders HTTP/1.1". Lines with only a
+// URL are GET requests.
+var httpFileRequestLineRegex = regexp.MustCompile( //nolint:gochecknoglobals
+	`^(?:(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|CONNECT|TRACE)\s+)?(\S+)(?:\s+HTTP/[\d.]+)?$`,
+)
+
+// httpFileMaxDepth limits how deep variables referencing other variables are resolved.
+const httpFileMaxDepth = 10
+
+// parseHTTPFile reads the requests of a .http or .rest file in the VS Code REST Client format.
+// Requests are separated by ###, file variables are declared with `@name = value` and overridden by
+// variables. {{$dotenv NAME}} is the variable NAME of dotenv, the variables of the --env-file files.
+// URLs starting with / are sent to baseURL, and a body of `< ./file` is read from the file relative
+// to the .http file.
+func parseHTTPFile(path string, reader fileReader, variables, dotenv map[string]string, baseURL string) (
+	[]collectionRequest,
+	error,
+) {
+	data, err := reader.read(path)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.parseHTTPFile] read file failed: %w", err)
+	}
+
+	fileVariables := make(map[string]string)
+
+	var requests []collectionRequest
+
+	for i, block := range splitHTTPFile(string(data)) {
+		request, ok := parseHTTPFileRequest(block, fileVariables)
+		if !ok {
+			continue
+		}
+
+		if request.name == "" {
+			request.name = fmt.Sprintf("request %d", i+1)
+		}
+
+		if bodyFile, ok := strings.CutPrefix(request.body, "< "); ok && !strings.Contains(bodyFile, "\n") {
+			body, err := reader.read(filepath.Join(filepath.Dir(path), strings.TrimSpace(bodyFile)))
+			if err != nil {
+				return nil, fmt.Errorf("[in lambdalocal.parseHTTPFile] read body of '%s' failed: %w", request.name, err)
+			}
+
+			request.body = string(body)
+		}
+
+		requests = append(requests, request)
+	}
+
+	for key, value := range variables {
+		fileVariables[key] = value
+	}
+
+	for i, request := range requests {
+		requests[i].url = resolveHTTPFileVariables(request.url, fileVariables, dotenv, 0)
+		requests[i].body = resolveHTTPFileVariables(request.body, fileVariables, dotenv, 0)
+
+		for j, header := range request.headers {
+			requests[i].headers[j][1] = resolveHTTPFileVariables(header[1], fileVariables, dotenv, 0)
+		}
+
+		if strings.HasPrefix(requests[i].url, "/") {
+			requests[i].url = strings.TrimSuffix(baseURL, "/") + requests[i].url
+		}
+	}
+
+	return requests, nil
+}
+
+// splitHTTPFile splits a .http file into the blocks between ### separators. The text after ###
+// names the request of the block.
+func splitHTTPFile(data string) []string {
+	var (
+		blocks []string
+		block  strings.Builder
+	)
+
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if name, ok := strings.CutPrefix(line, "###"); ok {
+			blocks = append(blocks, block.String())
+			block.Reset()
+
+			if name = strings.TrimSpace(name); name != "" {
+				block.WriteString("# @name " + name + "\n")
+			}
+
+			continue
+		}
+
+		block.WriteString(line + "\n")
+	}
+
+	return append(blocks, block.String())
+}
+
+// parseHTTPFileRequest parses the request of a block and adds the block's file variables to
+// variables. Blocks with only comments and variables have no request.
+func parseHTTPFileRequest(block string, variables map[string]string) (collectionRequest, bool) {
+	var (
+		request   collectionRequest
+		found     bool
+		inHeaders bool
+		body      []string
+	)
+
+	for _, line := range strings.Split(block, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case inHeaders:
+			if trimmed == "" {
+				inHeaders = false
+				body = []string{}
+
+				continue
+			}
+
+			if isHTTPFileComment(trimmed) {
+				continue
+			}
+
+			// query parameters can continue on the following lines
+			if strings.HasPrefix(trimmed, "?") || strings.HasPrefix(trimmed, "&") {
+				request.url += trimmed
+
+				continue
+			}
+
+			if key, value, ok := strings.Cut(trimmed, ":"); ok {
+				request.headers = append(request.headers, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+			}
+		case found:
+			body = append(body, line)
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "@"):
+			if key, value, ok := strings.Cut(trimmed[1:], "="); ok {
+				variables[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		case isHTTPFileComment(trimmed):
+			comment := strings.TrimSpace(strings.TrimLeft(trimmed, "#/"))
+			if name, ok := strings.CutPrefix(comment, "@name "); ok {
+				request.name = strings.TrimSpace(name)
+			}
+		default:
+			match := httpFileRequestLineRegex.FindStringSubmatch(trimmed)
+			if match == nil {
+				return collectionRequest{}, false
+			}
+
+			request.method = match[1]
+			if request.method == "" {
+				request.method = "GET"
+			}
+
+			request.url = match[2]
+			found = true
+			inHeaders = true
+		}
+	}
+
+	request.body = strings.TrimRight(strings.Join(body, "\n"), "\n")
+
+	return request, found
+}
+
+func isHTTPFileComment(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
+}
+
+// resolveHTTPFileVariables replaces the variables of value. Unknown variables are kept.
+func resolveHTTPFileVariables(value string, variables, dotenv map[string]string, depth int) string {
+	if depth > httpFileMaxDepth {
+		return value
+	}
+
+	return httpFileVariableRegex.ReplaceAllStringFunc(
+		value, func(match string) string {
+			name := httpFileVariableRegex.FindStringSubmatch(match)[1]
+
+			if strings.HasPrefix(name, "$") {
+				if resolved, ok := httpFileSystemVariable(name, dotenv); ok {
+					return resolved
+				}
+
+				return match
+			}
+
+			resolved, ok := variables[name]
+			if !ok {
+				return match
+			}
+
+			return resolveHTTPFileVariables(resolved, variables, dotenv, depth+1)
+		},
+	)
+}
+
+// httpFileSystemVariable resolves the system variables $guid, $timestamp, $processEnv NAME and
+// $dotenv NAME, the variable NAME of dotenv.
+func httpFileSystemVariable(name string, dotenv map[string]string) (string, bool) {
+	fields := strings.Fields(name)
+
+	switch {
+	case fields[0] == "$guid":
+		return uuid.NewString(), true
+	case fields[0] == "$timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), true
+	case fields[0] == "$processEnv" && len(fields) == 2: //nolint:mnd
+		return os.Getenv(fields[1]), true
+	case fields[0] == "$dotenv" && len(fields) == 2: //nolint:mnd
+		return dotenv[fields[1]], true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		file             string
+		variables        map[string]string
+		dotenv           map[string]string
+		bodyFile         []any
+		expectedRequests []collectionRequest
+		expectedErrStr   string
+	}{
+		"requests with variables": {
+			file: `@host = http://localhost:9000
+@token = abc
+@auth = Bearer {{token}}
+
+### Create order
+POST {{host}}/orders HTTP/1.1
+Content-Type: application/json
+Authorization: {{auth}}
+
+{
+  "item": "book"
+}
+
+###
+# @name list
+// list the orders
+GET /orders
+    ?limit=10
+    &offset={{offset}}
+`,
+			variables: map[string]string{"token": "override"},
+			expectedRequests: []collectionRequest{
+				{
+					name:   "Create order",
+					method: "POST",
+					url:    "http://localhost:9000/orders",
+					headers: [][2]string{
+						{"Content-Type", "application/json"},
+						{"Authorization", "Bearer override"},
+					},
+					body: "{\n  \"item\": \"book\"\n}",
+				},
+				{
+					name:   "list",
+					method: "GET",
+					url:    "http://localhost:8080/orders?limit=10&offset={{offset}}",
+				},
+			},
+		},
+		"url only": {
+			file: "http://localhost:8080/hello\n",
+			expectedRequests: []collectionRequest{
+				{name: "request 1", method: "GET", url: "http://localhost:8080/hello"},
+			},
+		},
+		"dotenv variables": {
+			file:   "GET /orders\nAuthorization: Bearer {{$dotenv API_TOKEN}}\n",
+			dotenv: map[string]string{"API_TOKEN": "s3cr3t"},
+			expectedRequests: []collectionRequest{
+				{
+					name:    "request 1",
+					method:  "GET",
+					url:     "http://localhost:8080/orders",
+					headers: [][2]string{{"Authorization", "Bearer s3cr3t"}},
+				},
+			},
+		},
+		"body from file": {
+			file:     "POST /orders\n\n< ./order.json\n",
+			bodyFile: []any{[]byte(`{"item":"pen"}`), nil},
+			expectedRequests: []collectionRequest{
+				{name: "request 1", method: "POST", url: "http://localhost:8080/orders", body: `{"item":"pen"}`},
+			},
+		},
+		"missing body file": {
+			file:           "POST /orders\n\n< ./order.json\n",
+			bodyFile:       []any{[]byte{}, errors.New("file not found")},
+			expectedErrStr: "[in lambdalocal.parseHTTPFile] read body of 'request 1' failed: file not found",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "requests/api.http").Return([]byte(tc.file), nil).Once()
+
+				if tc.bodyFile != nil {
+					mockReader.On("read", "requests/order.json").Return(tc.bodyFile...).Once()
+				}
+
+				requests, err := parseHTTPFile(
+					"requests/api.http",
+					mockReader,
+					tc.variables,
+					tc.dotenv,
+					"http://localhost:8080/",
+				)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedRequests, requests)
+				mockReader.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+func TestParseHTTPFileReadError(t *testing.T) {
+	t.Parallel()
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", mock.Anything).Return([]byte{}, errors.New("file not found")).Once()
+
+	_, err := parseHTTPFile("api.http", mockReader, nil, nil, "http://localhost:8080")
+	assert.ErrorContains(t, err, "[in lambdalocal.parseHTTPFile] read file failed: file not found")
+}
+
+func TestResolveHTTPFileVariables(t *testing.T) {
+	t.Setenv("LAMBDALOCAL_TEST_TOKEN", "secret")
+
+	variables := map[string]string{"a": "{{b}}", "b": "{{a}}", "token": "{{$processEnv LAMBDALOCAL_TEST_TOKEN}}"}
+
+	assert.Equal(t, "Bearer secret", resolveHTTPFileVariables("Bearer {{token}}", variables, nil, 0))
+	assert.Equal(t, "{{$unknown}}", resolveHTTPFileVariables("{{$unknown}}", variables, nil, 0))
+	dotenv := map[string]string{"TABLE": "orders"}
+
+	assert.Equal(t, "orders", resolveHTTPFileVariables("{{$dotenv TABLE}}", variables, dotenv, 0))
+	assert.Empty(t, resolveHTTPFileVariables("{{$dotenv TABLE}}", variables, nil, 0))
+	assert.Len(t, resolveHTTPFileVariables("{{$guid}}", variables, nil, 0), 36)
+	// variables referencing each other stop resolving
+	assert.Contains(t, resolveHTTPFileVariables("{{a}}", variables, nil, 0), "{{")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"cmp"
+	"net"
+	"net/http"
+)
+
+// callerIdentity is the identity of the caller of a request as it appears in the events, the
+// --identity flags override the one of the request.
+type callerIdentity struct {
+	sourceIP  string
+	userAgent string
+	userARN   string
+}
+
+// resolve returns the identity of the caller of r, with the overrides of c.
+func (c callerIdentity) resolve(r *http.Request) callerIdentity {
+	sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	return callerIdentity{
+		sourceIP:  cmp.Or(c.sourceIP, sourceIP),
+		userAgent: cmp.Or(c.userAgent, r.UserAgent()),
+		userARN:   c.userARN,
+	}
+}
+
+// apiIdentity is the requestContext.identity of payload format 1.0 events.
+type apiIdentity struct {
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+	UserARN   string `json:"userArn,omitempty"`
+}
+
+// httpAPIIAMAuthorizer is the requestContext.authorizer.iam of payload format 2.0 events, only set
+// with --identity-user-arn.
+type httpAPIIAMAuthorizer struct {
+	AccountID string `json:"accountId"`
+	UserARN   string `json:"userArn"`
+}
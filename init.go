@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateNames are the file names of SAM templates looked for by init, in order of preference.
+var templateNames = []string{"template.yaml", "template.yml"} //nolint:gochecknoglobals
+
+const lambdaImport = "github.com/aws/aws-lambda-go/lambda"
+
+// initProject is what init found when inspecting a repository.
+type initProject struct {
+	// module is the module path from go.mod, empty when there is no go.mod.
+	module string
+	// templatePath is the path of the SAM template relative to the repository.
+	templatePath string
+	// handlers are the directories of main packages importing aws-lambda-go/lambda.
+	handlers []string
+	// functions are the functions of the template, sorted by logical ID.
+	functions []initFunction
+}
+
+type initFunction struct {
+	name string
+	// codeURI is the CodeUri of the function when it is a local path.
+	codeURI string
+	// route is the first API route of the function, nil when it has none.
+	route *apiRoute
+}
+
+// initTemplate holds the parts of a SAM template that init needs besides the routes.
+type initTemplate struct {
+	Resources map[string]struct {
+		Type       string `yaml:"Type"` //nolint:tagliatelle
+		Properties struct {
+			CodeURI any `yaml:"CodeUri"` //nolint:tagliatelle
+		} `yaml:"Properties"` //nolint:tagliatelle
+	} `yaml:"Resources"` //nolint:tagliatelle
+}
+
+// RunInit inspects the repository in dir, asks the questions init needs on in and writes a
+// starter project config and an example event for every function. With assumeYes the detected
+// defaults are used without asking, and with force existing files are overwritten.
+func RunInit(in io.Reader, w io.Writer, dir string, assumeYes, force bool) error {
+	project, err := inspectProject(dir)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunInit] inspectProject failed: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(w, "Inspecting "+dir)
+
+	if project.module != "" {
+		_, _ = fmt.Fprintln(w, "  go module:        "+project.module)
+	}
+
+	_, _ = fmt.Fprintf(w, "  handler packages: %s\n", strings.Join(orNone(project.handlers), ", "))
+
+	prompt := newInitPrompt(in, w, assumeYes)
+
+	templatePath := prompt.ask("SAM template", project.templatePath)
+	if templatePath == "" {
+		return errors.New("[in lambdalocal.RunInit] no SAM template found")
+	}
+
+	if templatePath != project.templatePath {
+		if project.functions, err = templateFunctions(dir, templatePath); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunInit] templateFunctions failed: %w", err)
+		}
+	}
+
+	if len(project.functions) == 0 {
+		return fmt.Errorf("[in lambdalocal.RunInit] no functions found in template '%s'", templatePath)
+	}
+
+	config := projectConfig{Functions: make(map[string]functionConfig, len(project.functions))}
+	handlers := make(map[string]string, len(project.functions))
+
+	for i, function := range project.functions {
+		_, _ = fmt.Fprintln(w, "Function "+function.name)
+
+		config.Functions[function.name] = functionConfig{
+			Address: prompt.ask("  address of the running lambda", "localhost:"+strconv.Itoa(8000+i)), //nolint:mnd
+		}
+		handlers[function.name] = prompt.ask("  handler package", project.handlerFor(function))
+	}
+
+	writeEvents := prompt.confirm("Write example events")
+
+	configPath := filepath.Join(dir, defaultConfigPath)
+	if err = writeInitFile(w, configPath, renderInitConfig(config, handlers), force); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunInit] write config failed: %w", err)
+	}
+
+	if !writeEvents {
+		return nil
+	}
+
+	templateDir := filepath.Dir(filepath.Join(dir, templatePath))
+
+	for _, function := range project.functions {
+		event, err := exampleEvent(function.route)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunInit] exampleEvent failed: %w", err)
+		}
+
+		eventPath := filepath.Join(templateDir, "events", function.name, defaultEventFile)
+		if err = writeInitFile(w, eventPath, event, force); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunInit] write event failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// inspectProject looks for the go module, SAM template and lambda handler packages in dir.
+func inspectProject(dir string) (initProject, error) {
+	var project initProject
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err == nil {
+		project.module = modulePath(goMod)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return initProject{}, fmt.Errorf("[in lambdalocal.inspectProject] read go.mod failed: %w", err)
+	}
+
+	project.handlers, err = findHandlers(dir)
+	if err != nil {
+		return initProject{}, fmt.Errorf("[in lambdalocal.inspectProject] findHandlers failed: %w", err)
+	}
+
+	project.templatePath, err = findTemplate(dir)
+	if err != nil {
+		return initProject{}, fmt.Errorf("[in lambdalocal.inspectProject] findTemplate failed: %w", err)
+	}
+
+	if project.templatePath != "" {
+		project.functions, err = templateFunctions(dir, project.templatePath)
+		if err != nil {
+			return initProject{}, fmt.Errorf("[in lambdalocal.inspectProject] templateFunctions failed: %w", err)
+		}
+	}
+
+	return project, nil
+}
+
+// modulePath returns the module path declared in a go.mod file.
+func modulePath(goMod []byte) string {
+	for _, line := range strings.Split(string(goMod), "\n") {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+
+	return ""
+}
+
+// findTemplate returns the path, relative to dir, of the first SAM template found in dir or one
+// of its subdirectories.
+func findTemplate(dir string) (string, error) {
+	var found string
+
+	err := filepath.WalkDir(
+		dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() {
+				if path != dir && skipDir(entry.Name()) {
+					return filepath.SkipDir
+				}
+
+				for _, name := range templateNames {
+					if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+						found, _ = filepath.Rel(dir, filepath.Join(path, name))
+
+						return filepath.SkipAll
+					}
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	return found, nil
+}
+
+// findHandlers returns the directories, relative to dir, of main packages importing
+// aws-lambda-go/lambda.
+func findHandlers(dir string) ([]string, error) {
+	handlers := make(map[string]bool)
+
+	err := filepath.WalkDir(
+		dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() {
+				if path != dir && skipDir(entry.Name()) {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+
+			source, err := os.ReadFile(path)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			if isLambdaMain(string(source)) {
+				rel, _ := filepath.Rel(dir, filepath.Dir(path))
+				handlers["./"+filepath.ToSlash(rel)] = true
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	result := make([]string, 0, len(handlers))
+	for handler := range handlers {
+		result = append(result, strings.TrimSuffix(handler, "/."))
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// skipDir reports whether a directory is skipped while inspecting a repository.
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "testdata"
+}
+
+func isLambdaMain(source string) bool {
+	return strings.Contains(source, "package main") && strings.Contains(source, `"`+lambdaImport+`"`)
+}
+
+// templateFunctions returns the functions of the template at templatePath, relative to dir.
+func templateFunctions(dir, templatePath string) ([]initFunction, error) {
+	path := filepath.Join(dir, templatePath)
+
+	routes, err := parseTemplate(path, osFileReader{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateFunctions] parseTemplate failed: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateFunctions] read template failed: %w", err)
+	}
+
+	var template initTemplate
+	if err = unmarshalTemplate(data, nil, &template); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateFunctions] unmarshal yaml failed: %w", err)
+	}
+
+	var functions []initFunction
+
+	for name, resource := range template.Resources {
+		if resource.Type != "AWS::Serverless::Function" {
+			continue
+		}
+
+		function := initFunction{name: name}
+
+		// CodeUri is a map when the code is in S3
+		if codeURI, ok := resource.Properties.CodeURI.(string); ok {
+			function.codeURI = filepath.ToSlash(filepath.Join(filepath.Dir(templatePath), codeURI))
+		}
+
+		for _, route := range routes {
+			if route.function == name {
+				function.route = &route
+
+				break
+			}
+		}
+
+		functions = append(functions, function)
+	}
+
+	sort.Slice(functions, func(i, j int) bool { return functions[i].name < functions[j].name })
+
+	return functions, nil
+}
+
+// handlerFor returns the handler package of the function, matched by its CodeUri. A single
+// handler package is used for every function.
+func (p initProject) handlerFor(function initFunction) string {
+	for _, handler := range p.handlers {
+		if function.codeURI != "" && filepath.Clean(handler) == filepath.Clean(function.codeURI) {
+			return handler
+		}
+	}
+
+	if len(p.handlers) == 1 {
+		return p.handlers[0]
+	}
+
+	return ""
+}
+
+// renderInitConfig renders the project config with a comment showing how to start each handler.
+func renderInitConfig(config projectConfig, handlers map[string]string) []byte {
+	names := make([]string, 0, len(config.Functions))
+	for name := range config.Functions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	b.WriteString("# lambdalocal project config, see https://github.com/j-d-ha/lambdalocal#project-config\n")
+	b.WriteString("functions:\n")
+
+	for _, name := range names {
+		address := config.Functions[name].Address
+
+		b.WriteString("  " + name + ":\n")
+
+		if handler := handlers[name]; handler != "" {
+			fmt.Fprintf(&b, "    # start with: lambdalocal --address %s --run \"go run %s\" ...\n", address, handler)
+		}
+
+		b.WriteString("    address: " + address + "\n")
+	}
+
+	return []byte(b.String())
+}
+
+// exampleEvent returns an event for the route in the payload format of the route. Functions
+// without a route get an empty object.
+func exampleEvent(route *apiRoute) ([]byte, error) {
+	if route == nil {
+		return []byte("{}\n"), nil
+	}
+
+	method := route.method
+	if method == "" {
+		method = "GET"
+	}
+
+	path := greedyParamRegex.ReplaceAllString(route.path, "{$1}")
+	headers := map[string]string{"Content-Type": "application/json"}
+
+	var event any = genericAPIEvent{
+		Resource:                        route.path,
+		Path:                            path,
+		HTTPMethod:                      method,
+		Headers:                         headers,
+		MultiValueHeaders:               map[string][]string{"Content-Type": {"application/json"}},
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: map[string][]string{},
+		PathParameters:                  map[string]string{},
+	}
+
+	if route.payloadFormat == payloadFormatV2 {
+		event = httpAPIEvent{
+			Version:  payloadFormatV2,
+			RouteKey: route.routeKey(),
+			RawPath:  path,
+			Headers:  map[string]string{"content-type": "application/json"},
+			RequestContext: httpAPIRequestContext{
+				AccountID: localAccountID,
+				APIID:     localAPIID,
+				HTTP:      httpAPIRequestContextHTTP{Method: method, Path: path},
+				RouteKey:  route.routeKey(),
+				Stage:     "$default",
+			},
+		}
+	}
+
+	out, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.exampleEvent] marshal event failed: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
+
+// writeInitFile writes a file created by init. Existing files are kept unless force is set.
+func writeInitFile(w io.Writer, path string, data []byte, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		_, _ = fmt.Fprintln(w, "Skipped "+path+", it already exists. Use --force to overwrite it.")
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:mnd
+		return err //nolint:wrapcheck
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil { //nolint:mnd,gosec
+		return err //nolint:wrapcheck
+	}
+
+	_, _ = fmt.Fprintln(w, "Wrote "+path)
+
+	return nil
+}
+
+func orNone(values []string) []string {
+	if len(values) == 0 {
+		return []string{"none found"}
+	}
+
+	return values
+}
+
+// initPrompt asks the questions of init, answering them with their defaults when assumeYes is set
+// or the input is exhausted.
+type initPrompt struct {
+	scanner   *bufio.Scanner
+	w         io.Writer
+	assumeYes bool
+}
+
+func newInitPrompt(in io.Reader, w io.Writer, assumeYes bool) *initPrompt {
+	return &initPrompt{scanner: bufio.NewScanner(in), w: w, assumeYes: assumeYes}
+}
+
+// ask asks a question and returns the answer, or def for an empty answer.
+func (p *initPrompt) ask(question, def string) string {
+	if p.assumeYes {
+		return def
+	}
+
+	_, _ = fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+
+	if !p.scanner.Scan() {
+		_, _ = fmt.Fprintln(p.w)
+
+		return def
+	}
+
+	if answer := strings.TrimSpace(p.scanner.Text()); answer != "" {
+		return answer
+	}
+
+	return def
+}
+
+// confirm asks a yes/no question that defaults to yes.
+func (p *initPrompt) confirm(question string) bool {
+	answer := strings.ToLower(p.ask(question+" (y/n)", "y"))
+
+	return answer == "y" || answer == "yes"
+}
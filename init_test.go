@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const initTestTemplate = `
+Resources:
+  OrderFn:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: orders/
+      Events:
+        Api:
+          Type: HttpApi
+          Properties:
+            Path: /orders/{id}
+            Method: get
+  HelloFn:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: hello/
+      Events:
+        Api:
+          Type: Api
+          Properties:
+            Path: /hello
+            Method: post
+  WorkerFn:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri:
+        Bucket: artifacts
+        Key: worker.zip
+`
+
+const initTestHandler = `package main
+
+import "github.com/aws/aws-lambda-go/lambda"
+
+func main() { lambda.Start(func() error { return nil }) }
+`
+
+// newInitTestProject creates a project with a go module, two handler packages and a template.
+func newInitTestProject(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod":                "module example.com/shop\n\ngo 1.22\n",
+		"sam/template.yaml":     initTestTemplate,
+		"sam/orders/main.go":    initTestHandler,
+		"sam/hello/main.go":     initTestHandler,
+		"sam/hello/hello.go":    "package main\n",
+		"internal/util/util.go": "package util\n",
+		".git/hooks/ignored.go": initTestHandler,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+func TestInspectProject(t *testing.T) {
+	t.Parallel()
+
+	dir := newInitTestProject(t)
+
+	project, err := inspectProject(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com/shop", project.module)
+	assert.Equal(t, filepath.Join("sam", "template.yaml"), project.templatePath)
+	assert.Equal(t, []string{"./sam/hello", "./sam/orders"}, project.handlers)
+
+	names := make([]string, 0, len(project.functions))
+	for _, function := range project.functions {
+		names = append(names, function.name)
+	}
+
+	assert.Equal(t, []string{"HelloFn", "OrderFn", "WorkerFn"}, names)
+	assert.Equal(t, "./sam/hello", project.handlerFor(project.functions[0]))
+	assert.Equal(t, "./sam/orders", project.handlerFor(project.functions[1]))
+	assert.Equal(t, "", project.handlerFor(project.functions[2]))
+}
+
+func TestRunInit(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input          string
+		assumeYes      bool
+		expectedConfig string
+		expectEvents   bool
+	}{
+		"detected defaults": {
+			assumeYes: true,
+			expectedConfig: `# lambdalocal project config, see https://github.com/j-d-ha/lambdalocal#project-config
+functions:
+  HelloFn:
+    # start with: lambdalocal --address localhost:8000 --run "go run ./sam/hello" ...
+    address: localhost:8000
+  OrderFn:
+    # start with: lambdalocal --address localhost:8001 --run "go run ./sam/orders" ...
+    address: localhost:8001
+  WorkerFn:
+    address: localhost:8002
+`,
+			expectEvents: true,
+		},
+		"answered questions": {
+			input: strings.Join(
+				[]string{
+					"",               // template
+					"localhost:9000", // HelloFn address
+					"",               // HelloFn handler
+					"",               // OrderFn address
+					"./cmd/orders",   // OrderFn handler
+					"",               // WorkerFn address
+					"",               // WorkerFn handler
+					"n",              // events
+				}, "\n",
+			),
+			expectedConfig: `# lambdalocal project config, see https://github.com/j-d-ha/lambdalocal#project-config
+functions:
+  HelloFn:
+    # start with: lambdalocal --address localhost:9000 --run "go run ./sam/hello" ...
+    address: localhost:9000
+  OrderFn:
+    # start with: lambdalocal --address localhost:8001 --run "go run ./cmd/orders" ...
+    address: localhost:8001
+  WorkerFn:
+    address: localhost:8002
+`,
+			expectEvents: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				dir := newInitTestProject(t)
+
+				var out bytes.Buffer
+				require.NoError(t, RunInit(strings.NewReader(tc.input), &out, dir, tc.assumeYes, false))
+
+				config, err := os.ReadFile(filepath.Join(dir, "lambdalocal.yaml"))
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedConfig, string(config))
+
+				loaded, err := loadProjectConfig(filepath.Join(dir, "lambdalocal.yaml"), osFileReader{})
+				require.NoError(t, err)
+				assert.Len(t, loaded.Functions, 3)
+
+				for _, function := range []string{"HelloFn", "OrderFn", "WorkerFn"} {
+					_, err = os.Stat(filepath.Join(dir, "sam", "events", function, defaultEventFile))
+					assert.Equal(t, tc.expectEvents, err == nil, function)
+				}
+			},
+		)
+	}
+}
+
+func TestRunInitKeepsExistingFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := newInitTestProject(t)
+	configPath := filepath.Join(dir, "lambdalocal.yaml")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("functions: {}\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, RunInit(strings.NewReader(""), &out, dir, true, false))
+
+	config, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "functions: {}\n", string(config))
+	assert.Contains(t, out.String(), "Skipped "+configPath)
+
+	require.NoError(t, RunInit(strings.NewReader(""), &out, dir, true, true))
+
+	config, err = os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "HelloFn:")
+}
+
+func TestExampleEvent(t *testing.T) {
+	t.Parallel()
+
+	event, err := exampleEvent(nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(event))
+
+	event, err = exampleEvent(&apiRoute{method: "POST", path: "/hello", payloadFormat: payloadFormatV1})
+	require.NoError(t, err)
+
+	var v1 genericAPIEvent
+	require.NoError(t, json.Unmarshal(event, &v1))
+	assert.Equal(t, "POST", v1.HTTPMethod)
+	assert.Equal(t, "/hello", v1.Path)
+
+	event, err = exampleEvent(&apiRoute{path: "/files/{path+}", payloadFormat: payloadFormatV2})
+	require.NoError(t, err)
+
+	var v2 httpAPIEvent
+	require.NoError(t, json.Unmarshal(event, &v2))
+	assert.Equal(t, "ANY /files/{path+}", v2.RouteKey)
+	assert.Equal(t, "GET", v2.RequestContext.HTTP.Method)
+	assert.Equal(t, "/files/{path}", v2.RawPath)
+}
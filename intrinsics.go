@@ -0,0 +1,472 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	intrinsicRef       = "Ref"
+	intrinsicCondition = "Condition"
+	// noValue removes the property it is the value of.
+	noValue = "AWS::NoValue"
+)
+
+// pseudoParameters are the values of the AWS pseudo parameters used locally.
+var pseudoParameters = map[string]string{ //nolint:gochecknoglobals
+	"AWS::AccountId":        "123456789012",
+	"AWS::Region":           "us-east-1",
+	"AWS::Partition":        "aws",
+	"AWS::StackName":        "lambdalocal",
+	"AWS::StackId":          "arn:aws:cloudformation:us-east-1:123456789012:stack/lambdalocal/local",
+	"AWS::URLSuffix":        "amazonaws.com",
+	"AWS::NotificationARNs": "",
+}
+
+// subVariableRegex matches the ${Name} and ${!Literal} variables of Fn::Sub.
+var subVariableRegex = regexp.MustCompile(`\$\{([^}]*)\}`) //nolint:gochecknoglobals
+
+// unmarshalTemplate decodes a template into out after resolving the intrinsic functions that can be
+// resolved locally: Ref to parameters and pseudo parameters, Fn::Sub, Fn::If, Fn::Join,
+// Fn::Select, Fn::Split and Fn::FindInMap. Parameters take their value from overrides or their
+// Default, Ref to a resource is its logical ID, and resources with a false Condition are removed.
+// Functions that depend on deployed resources, like Fn::GetAtt, are left as they are.
+func unmarshalTemplate(data []byte, overrides map[string]string, out any) error {
+	resolved, err := resolveTemplate(data, overrides)
+	if err != nil {
+		return err
+	}
+
+	if resolved == nil {
+		return yaml.Unmarshal(data, out) //nolint:wrapcheck
+	}
+
+	return resolved.Decode(out) //nolint:wrapcheck
+}
+
+// resolveTemplate returns the root of a template with its intrinsic functions resolved, like
+// unmarshalTemplate, or nil when the template isn't a mapping.
+func resolveTemplate(data []byte, overrides map[string]string) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, nil //nolint:nilnil
+	}
+
+	root := document.Content[0]
+	resolver := newIntrinsicResolver(root, overrides)
+
+	resolved, _ := resolver.resolve(root)
+	resolver.removeConditionalResources(resolved)
+
+	return resolved, nil
+}
+
+type intrinsicResolver struct {
+	parameters map[string]string
+	resources  map[string]bool
+	conditions map[string]*yaml.Node
+	mappings   *yaml.Node
+	// evaluated caches conditions, and guards against conditions that reference themselves.
+	evaluated map[string]*bool
+}
+
+func newIntrinsicResolver(root *yaml.Node, overrides map[string]string) *intrinsicResolver {
+	r := &intrinsicResolver{
+		parameters: make(map[string]string),
+		resources:  make(map[string]bool),
+		conditions: make(map[string]*yaml.Node),
+		evaluated:  make(map[string]*bool),
+	}
+
+	for name, value := range pseudoParameters {
+		r.parameters[name] = value
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section := root.Content[i+1]
+
+		switch root.Content[i].Value {
+		case "Parameters":
+			forEachMapping(section, func(name string, parameter *yaml.Node) {
+				if value := mappingValue(parameter, "Default"); value != nil && value.Kind == yaml.ScalarNode {
+					r.parameters[name] = value.Value
+				}
+			})
+		case "Resources":
+			forEachMapping(section, func(name string, _ *yaml.Node) {
+				r.resources[name] = true
+			})
+		case "Conditions":
+			forEachMapping(section, func(name string, condition *yaml.Node) {
+				r.conditions[name] = condition
+			})
+		case "Mappings":
+			r.mappings = section
+		}
+	}
+
+	for name, value := range overrides {
+		r.parameters[name] = value
+	}
+
+	return r
+}
+
+// resolve returns node with its intrinsic functions resolved. ok is false when node is a Ref to
+// AWS::NoValue and should be removed.
+func (r *intrinsicResolver) resolve(node *yaml.Node) (*yaml.Node, bool) {
+	if name, args, isFunction := intrinsicFunction(node); isFunction {
+		return r.resolveFunction(node, name, args)
+	}
+
+	switch node.Kind { //nolint:exhaustive
+	case yaml.MappingNode:
+		resolved := *node
+		resolved.Content = make([]*yaml.Node, 0, len(node.Content))
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if value, ok := r.resolve(node.Content[i+1]); ok {
+				resolved.Content = append(resolved.Content, node.Content[i], value)
+			}
+		}
+
+		return &resolved, true
+	case yaml.SequenceNode:
+		resolved := *node
+		resolved.Content = make([]*yaml.Node, 0, len(node.Content))
+
+		for _, item := range node.Content {
+			if value, ok := r.resolve(item); ok {
+				resolved.Content = append(resolved.Content, value)
+			}
+		}
+
+		return &resolved, true
+	default:
+		return node, true
+	}
+}
+
+// intrinsicFunction returns the name and arguments of an intrinsic function written in the short
+// form, like !Sub, or the long form, like {Fn::Sub: ...}.
+func intrinsicFunction(node *yaml.Node) (string, *yaml.Node, bool) {
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		args := *node
+		args.Tag = ""
+
+		switch name := node.Tag[1:]; name {
+		case intrinsicRef, intrinsicCondition:
+			return name, &args, true
+		default:
+			return "Fn::" + name, &args, true
+		}
+	}
+
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 { //nolint:mnd
+		return "", nil, false
+	}
+
+	name := node.Content[0].Value
+	if name != intrinsicRef && !strings.HasPrefix(name, "Fn::") {
+		return "", nil, false
+	}
+
+	return name, node.Content[1], true
+}
+
+//nolint:cyclop
+func (r *intrinsicResolver) resolveFunction(node *yaml.Node, name string, args *yaml.Node) (*yaml.Node, bool) {
+	switch name {
+	case intrinsicRef:
+		if args.Value == noValue {
+			return nil, false
+		}
+
+		if value, ok := r.parameters[args.Value]; ok {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: value}, true
+		}
+
+		if r.resources[args.Value] {
+			return stringNode(args.Value), true
+		}
+	case "Fn::Sub":
+		return r.sub(args), true
+	case "Fn::If":
+		if args.Kind == yaml.SequenceNode && len(args.Content) == 3 { //nolint:mnd
+			branch := args.Content[2]
+			if r.condition(args.Content[0].Value) {
+				branch = args.Content[1]
+			}
+
+			return r.resolve(branch)
+		}
+	case "Fn::Join":
+		if args.Kind == yaml.SequenceNode && len(args.Content) == 2 { //nolint:mnd
+			if parts, ok := r.list(args.Content[1]); ok {
+				return stringNode(strings.Join(parts, r.scalar(args.Content[0]))), true
+			}
+		}
+	case "Fn::Select":
+		if args.Kind == yaml.SequenceNode && len(args.Content) == 2 { //nolint:mnd
+			index, err := strconv.Atoi(r.scalar(args.Content[0]))
+			if list, _ := r.resolve(args.Content[1]); err == nil && list.Kind == yaml.SequenceNode &&
+				index >= 0 && index < len(list.Content) {
+				return list.Content[index], true
+			}
+		}
+	case "Fn::Split":
+		if values, ok := r.strings(args); ok && len(values) == 2 { //nolint:mnd
+			split := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			for _, value := range strings.Split(values[1], values[0]) {
+				split.Content = append(split.Content, stringNode(value))
+			}
+
+			return split, true
+		}
+	case "Fn::FindInMap":
+		if values, ok := r.strings(args); ok && len(values) == 3 { //nolint:mnd
+			mapping := mappingValue(mappingValue(r.mappings, values[0]), values[1])
+			if value := mappingValue(mapping, values[2]); value != nil {
+				return r.resolve(value)
+			}
+		}
+	}
+
+	// functions that can't be resolved locally are kept, with their arguments resolved
+	if node.Kind == yaml.MappingNode {
+		resolvedArgs, _ := r.resolve(args)
+		resolved := *node
+		resolved.Content = []*yaml.Node{node.Content[0], resolvedArgs}
+
+		return &resolved, true
+	}
+
+	return node, true
+}
+
+// sub resolves Fn::Sub. Variables that can't be resolved locally, like ${Function.Arn}, are kept.
+func (r *intrinsicResolver) sub(args *yaml.Node) *yaml.Node {
+	template := args
+	variables := make(map[string]string)
+
+	if args.Kind == yaml.SequenceNode && len(args.Content) > 0 {
+		template = args.Content[0]
+
+		if len(args.Content) > 1 {
+			forEachMapping(args.Content[1], func(name string, value *yaml.Node) {
+				if resolved, _ := r.resolve(value); resolved != nil && resolved.Kind == yaml.ScalarNode {
+					variables[name] = resolved.Value
+				}
+			})
+		}
+	}
+
+	value := subVariableRegex.ReplaceAllStringFunc(
+		template.Value, func(match string) string {
+			name := match[2 : len(match)-1]
+
+			if literal, ok := strings.CutPrefix(name, "!"); ok {
+				return "${" + literal + "}"
+			}
+
+			if value, ok := variables[name]; ok {
+				return value
+			}
+
+			if value, ok := r.parameters[name]; ok {
+				return value
+			}
+
+			if r.resources[name] {
+				return name
+			}
+
+			return match
+		},
+	)
+
+	return stringNode(value)
+}
+
+// condition evaluates the condition name. Conditions that can't be evaluated locally are false.
+func (r *intrinsicResolver) condition(name string) bool {
+	if result, ok := r.evaluated[name]; ok {
+		return result != nil && *result
+	}
+
+	// a condition referencing itself is false
+	r.evaluated[name] = nil
+
+	result := r.evaluate(r.conditions[name])
+	r.evaluated[name] = &result
+
+	return result
+}
+
+func (r *intrinsicResolver) evaluate(node *yaml.Node) bool {
+	if node == nil {
+		return false
+	}
+
+	name, args, ok := intrinsicFunction(node)
+	if !ok {
+		return node.Value == "true"
+	}
+
+	switch name {
+	case intrinsicCondition:
+		return r.condition(args.Value)
+	case "Fn::Equals":
+		values, ok := r.strings(args)
+
+		return ok && len(values) == 2 && values[0] == values[1]
+	case "Fn::Not":
+		return args.Kind == yaml.SequenceNode && len(args.Content) == 1 && !r.evaluate(args.Content[0])
+	case "Fn::And":
+		for _, item := range args.Content {
+			if !r.evaluate(item) {
+				return false
+			}
+		}
+
+		return len(args.Content) > 0
+	case "Fn::Or":
+		for _, item := range args.Content {
+			if r.evaluate(item) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return false
+	}
+}
+
+// removeConditionalResources removes the resources whose Condition is false.
+func (r *intrinsicResolver) removeConditionalResources(root *yaml.Node) {
+	resources := mappingValue(root, "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return
+	}
+
+	content := make([]*yaml.Node, 0, len(resources.Content))
+
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		condition := mappingValue(resources.Content[i+1], intrinsicCondition)
+		if condition == nil || r.condition(condition.Value) {
+			content = append(content, resources.Content[i], resources.Content[i+1])
+		}
+	}
+
+	resources.Content = content
+}
+
+// strings resolves the items of a sequence to strings. ok is false when an item isn't a string.
+func (r *intrinsicResolver) strings(node *yaml.Node) ([]string, bool) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, false
+	}
+
+	values := make([]string, 0, len(node.Content))
+
+	for _, item := range node.Content {
+		resolved, ok := r.resolve(item)
+		if !ok || resolved.Kind != yaml.ScalarNode || isIntrinsicTag(resolved.Tag) {
+			return nil, false
+		}
+
+		values = append(values, resolved.Value)
+	}
+
+	return values, true
+}
+
+// list resolves a sequence, or a function returning one, to strings.
+func (r *intrinsicResolver) list(node *yaml.Node) ([]string, bool) {
+	resolved, ok := r.resolve(node)
+	if !ok {
+		return nil, false
+	}
+
+	return r.strings(resolved)
+}
+
+// scalar returns the resolved value of a scalar node, or "" for other nodes.
+func (r *intrinsicResolver) scalar(node *yaml.Node) string {
+	resolved, ok := r.resolve(node)
+	if !ok || resolved.Kind != yaml.ScalarNode {
+		return ""
+	}
+
+	return resolved.Value
+}
+
+func isIntrinsicTag(tag string) bool {
+	return strings.HasPrefix(tag, "!") && !strings.HasPrefix(tag, "!!")
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func forEachMapping(node *yaml.Node, fn func(key string, value *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i].Value, node.Content[i+1])
+	}
+}
+
+// parseParameterOverrides parses --parameter-overrides values, either KEY=VALUE or the
+// ParameterKey=KEY,ParameterValue=VALUE form of the SAM CLI.
+func parseParameterOverrides(values []string) (map[string]string, error) {
+	overrides := make(map[string]string, len(values))
+
+	for _, value := range values {
+		if rest, ok := strings.CutPrefix(value, "ParameterKey="); ok {
+			key, parameterValue, ok := strings.Cut(rest, ",ParameterValue=")
+			if !ok {
+				return nil, fmt.Errorf("[in lambdalocal.parseParameterOverrides] invalid override '%s'", value)
+			}
+
+			overrides[key] = parameterValue
+
+			continue
+		}
+
+		key, parameterValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("[in lambdalocal.parseParameterOverrides] invalid override '%s'", value)
+		}
+
+		overrides[key] = parameterValue
+	}
+
+	return overrides, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalTemplate(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Parameters:
+  Stage:
+    Type: String
+    Default: dev
+  MaxAge:
+    Type: Number
+    Default: 600
+Conditions:
+  IsProd: !Equals [!Ref Stage, prod]
+  IsNotProd: !Not [!Condition IsProd]
+  IsProdOrTest: !Or [!Condition IsProd, !Equals [!Ref Stage, test]]
+Mappings:
+  Stages:
+    dev:
+      Domain: dev.example.com
+    prod:
+      Domain: example.com
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      values:
+        sub: !Sub /${Stage}/hello
+        subVariables: !Sub
+          - ${Domain}/${AWS::Region}/${!Literal}
+          - Domain: !FindInMap [Stages, !Ref Stage, Domain]
+        subResource: functions/${Fn.Arn}/invocations
+        longSub:
+          Fn::Sub: arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${Fn.Arn}/invocations
+        ref: !Ref Fn
+        number: !Ref MaxAge
+        if: !If [IsProd, prod-only, !Ref Stage]
+        notProd: !If [IsNotProd, true, false]
+        prodOrTest: !If [IsProdOrTest, true, false]
+        join: !Join ["-", [a, !Ref Stage, c]]
+        select: !Select [1, !Split [",", "x,y,z"]]
+        getAtt: !GetAtt Fn.Arn
+        removed: !If [IsProd, kept, !Ref AWS::NoValue]
+        transform:
+          Fn::Transform:
+            Name: AWS::Include
+            Parameters:
+              Location: !Sub ./${Stage}/openapi.yaml
+  ProdOnly:
+    Type: AWS::Serverless::Function
+    Condition: IsProd
+`
+
+	tests := map[string]struct {
+		overrides         map[string]string
+		expectedValues    map[string]any
+		expectedResources []string
+	}{
+		"parameter defaults": {
+			overrides: nil,
+			expectedValues: map[string]any{
+				"sub":          "/dev/hello",
+				"subVariables": "dev.example.com/us-east-1/${Literal}",
+				"subResource":  "functions/${Fn.Arn}/invocations",
+				"longSub":      "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/${Fn.Arn}/invocations",
+				"ref":          "Fn",
+				"number":       600,
+				"if":           "dev",
+				"notProd":      true,
+				"prodOrTest":   false,
+				"join":         "a-dev-c",
+				"select":       "y",
+				"getAtt":       "Fn.Arn",
+				"transform": map[string]any{
+					"Fn::Transform": map[string]any{
+						"Name":       "AWS::Include",
+						"Parameters": map[string]any{"Location": "./dev/openapi.yaml"},
+					},
+				},
+			},
+			expectedResources: []string{"Fn"},
+		},
+		"parameter overrides": {
+			overrides: map[string]string{"Stage": "prod"},
+			expectedValues: map[string]any{
+				"sub":          "/prod/hello",
+				"subVariables": "example.com/us-east-1/${Literal}",
+				"subResource":  "functions/${Fn.Arn}/invocations",
+				"longSub":      "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/${Fn.Arn}/invocations",
+				"ref":          "Fn",
+				"number":       600,
+				"if":           "prod-only",
+				"notProd":      false,
+				"prodOrTest":   true,
+				"join":         "a-prod-c",
+				"select":       "y",
+				"getAtt":       "Fn.Arn",
+				"removed":      "kept",
+				"transform": map[string]any{
+					"Fn::Transform": map[string]any{
+						"Name":       "AWS::Include",
+						"Parameters": map[string]any{"Location": "./prod/openapi.yaml"},
+					},
+				},
+			},
+			expectedResources: []string{"Fn", "ProdOnly"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var out struct {
+					Resources map[string]struct {
+						Properties struct {
+							Values map[string]any `yaml:"values"`
+						} `yaml:"Properties"` //nolint:tagliatelle
+					} `yaml:"Resources"` //nolint:tagliatelle
+				}
+
+				require.NoError(t, unmarshalTemplate([]byte(template), tc.overrides, &out))
+
+				assert.Equal(t, tc.expectedValues, out.Resources["Fn"].Properties.Values)
+
+				resources := make([]string, 0, len(out.Resources))
+				for resource := range out.Resources {
+					resources = append(resources, resource)
+				}
+
+				assert.ElementsMatch(t, tc.expectedResources, resources)
+			},
+		)
+	}
+}
+
+func TestUnmarshalTemplateInvalid(t *testing.T) {
+	t.Parallel()
+
+	var out map[string]any
+
+	require.Error(t, unmarshalTemplate([]byte("Resources: ["), nil, &out))
+	require.NoError(t, unmarshalTemplate([]byte(""), nil, &out))
+}
+
+func TestParseParameterOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		values         []string
+		expected       map[string]string
+		expectedErrStr string
+	}{
+		"key value": {
+			values:   []string{"Stage=prod", "Empty=", "Url=https://example.com/?a=b"},
+			expected: map[string]string{"Stage": "prod", "Empty": "", "Url": "https://example.com/?a=b"},
+		},
+		"sam cli form": {
+			values:   []string{"ParameterKey=Stage,ParameterValue=prod"},
+			expected: map[string]string{"Stage": "prod"},
+		},
+		"missing value": {
+			values:         []string{"Stage"},
+			expectedErrStr: "[in lambdalocal.parseParameterOverrides] invalid override 'Stage'",
+		},
+		"invalid sam cli form": {
+			values:         []string{"ParameterKey=Stage"},
+			expectedErrStr: "[in lambdalocal.parseParameterOverrides] invalid override 'ParameterKey=Stage'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				overrides, err := parseParameterOverrides(tc.values)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, overrides)
+			},
+		)
+	}
+}
@@ -1,60 +1,247 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
 	"net/rpc"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/google/uuid"
 )
 
-type Option func(*LambdaRPCClient)
+type Option func(*invokeOptions)
+
+// errInvocationTimeout is returned by Invoke when the lambda didn't respond within the execution
+// limit.
+var errInvocationTimeout = errors.New("invocation timed out")
+
+// invokeOptions holds the settings shared by the lambdaCaller implementations.
+type invokeOptions struct {
+	// serviceMethod is the name of the RPC method that is called
+	serviceMethod string
+	// clientContext is the JSON encoded lambdacontext.ClientContext sent with every invocation.
+	clientContext []byte
+	// requestID is the request id of a single invocation. A random id is used when empty.
+	requestID string
+	// connectWait is how long refused connections to an RPC lambda are retried.
+	connectWait time.Duration
+	// functionARN is the InvokedFunctionArn of every invocation.
+	functionARN string
+	// traceID is the XAmznTraceId of every invocation.
+	traceID string
+	// reportWriter receives the START, END and REPORT lines of the invocations, none are printed
+	// without it.
+	reportWriter io.Writer
+	// memorySize is the memory size in MB of the function in the REPORT lines.
+	memorySize int
+	// initDuration is how long the lambda took to start before a cold started invocation, 0 for
+	// warm invocations.
+	initDuration time.Duration
+}
+
+// newRequest builds the InvokeRequest for a single invocation.
+func (o invokeOptions) newRequest(data []byte, executionLimit time.Duration) messages.InvokeRequest {
+	deadline := time.Now().Add(executionLimit)
+
+	requestID := o.requestID
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	return messages.InvokeRequest{
+		Payload:   data,
+		RequestId: requestID,
+		Deadline: messages.InvokeRequest_Timestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+		ClientContext:      o.clientContext,
+		InvokedFunctionArn: o.functionARN,
+		XAmznTraceId:       o.traceID,
+	}
+}
 
 type LambdaRPCClient struct {
+	invokeOptions
 	// address is the address of the locally running lambda.
 	address string
 	// executionLimit is the maximum allowed duration of the lambda request
 	executionLimit time.Duration
-	// serviceMethod is the name of the RPC method that is called
-	serviceMethod string
 }
 
 // WithServiceMethod sets the service method for the RPC call.
 func WithServiceMethod(serviceMethod string) Option {
-	return func(lambda *LambdaRPCClient) {
-		lambda.serviceMethod = serviceMethod
+	return func(options *invokeOptions) {
+		options.serviceMethod = serviceMethod
 	}
 }
 
-// NewLambdaLambdaRPCClient is a constructor for LambdaRPCClient struct.
-func NewLambdaLambdaRPCClient(address string, executionLimit time.Duration, options ...Option) LambdaRPCClient {
-	lambdaRPC := LambdaRPCClient{
-		address:        address,
-		executionLimit: executionLimit,
-		serviceMethod:  "Function.Invoke",
+// WithClientContextCustom sets the custom map of the client context sent with every invocation.
+// Handlers can read the values with the contextenv package.
+func WithClientContextCustom(custom map[string]string) Option {
+	return func(options *invokeOptions) {
+		if len(custom) == 0 {
+			return
+		}
+
+		options.clientContext, _ = json.Marshal(lambdacontext.ClientContext{Custom: custom})
+	}
+}
+
+// WithClientContext sets the JSON encoded lambdacontext.ClientContext of a single invocation, like
+// the one an SDK sends with its invoke call.
+func WithClientContext(clientContext []byte) Option {
+	return func(options *invokeOptions) {
+		options.clientContext = clientContext
+	}
+}
+
+// WithRequestID sets the request id of a single invocation, so it can be correlated with the API
+// request that caused it.
+func WithRequestID(requestID string) Option {
+	return func(options *invokeOptions) {
+		options.requestID = requestID
+	}
+}
+
+// WithConnectWait retries connections the lambda refuses, like while it is starting, for up to
+// wait. Only used with the RPC protocol.
+func WithConnectWait(wait time.Duration) Option {
+	return func(options *invokeOptions) {
+		options.connectWait = wait
+	}
+}
+
+// WithFunctionARN sets the InvokedFunctionArn of the invocations, for handlers that read their own
+// ARN or alias from the lambda context.
+func WithFunctionARN(arn string) Option {
+	return func(options *invokeOptions) {
+		options.functionARN = arn
+	}
+}
+
+// WithTraceID sets the X-Ray trace header of the invocations, the XAmznTraceId of the request.
+func WithTraceID(traceID string) Option {
+	return func(options *invokeOptions) {
+		options.traceID = traceID
+	}
+}
+
+// WithInitDuration sets how long the lambda took to start before a single cold started invocation,
+// reported as the Init Duration of its REPORT line.
+func WithInitDuration(initDuration time.Duration) Option {
+	return func(options *invokeOptions) {
+		options.initDuration = initDuration
+	}
+}
+
+// qualifiedFunctionARN returns the ARN of the invoked function: arn, or the ARN of the function
+// named name in the local account and region. A qualifier, a version or alias, replaces the one
+// of the ARN.
+func qualifiedFunctionARN(arn, name, qualifier string) string {
+	if arn == "" && name != "" {
+		arn = fmt.Sprintf(
+			"arn:aws:lambda:%s:%s:function:%s",
+			pseudoParameters["AWS::Region"],
+			pseudoParameters["AWS::AccountId"],
+			name,
+		)
+	}
+
+	if arn == "" || qualifier == "" {
+		return arn
+	}
+
+	// arn:partition:lambda:region:account:function:name[:qualifier]
+	parts := strings.SplitN(arn, ":", 8) //nolint:mnd
+	if len(parts) == 8 {                 //nolint:mnd
+		parts = parts[:7]
+	}
+
+	return strings.Join(append(parts, qualifier), ":")
+}
+
+// WithReportLines prints the START, END and REPORT lines of every invocation to w, like Lambda
+// writes them to CloudWatch Logs.
+func WithReportLines(w io.Writer) Option {
+	return func(options *invokeOptions) {
+		options.reportWriter = w
+	}
+}
+
+// WithMemorySize sets the memory size in MB of the function in the REPORT lines. 0 keeps the
+// current size.
+func WithMemorySize(memorySize int) Option {
+	return func(options *invokeOptions) {
+		if memorySize > 0 {
+			options.memorySize = memorySize
+		}
 	}
+}
 
+func newInvokeOptions(options []Option) invokeOptions {
+	return invokeOptions{
+		serviceMethod: "Function.Invoke",
+	}.with(options)
+}
+
+// with returns a copy of the options with the given options applied.
+func (o invokeOptions) with(options []Option) invokeOptions {
 	for _, option := range options {
-		option(&lambdaRPC)
+		option(&o)
 	}
 
-	return lambdaRPC
+	return o
 }
 
-// Invoke sends an RPC request to invoke a lambda function with the given payload data.
-func (l LambdaRPCClient) Invoke(data []byte) (messages.InvokeResponse, error) {
-	deadline := time.Now().Add(l.executionLimit)
-	request := messages.InvokeRequest{
-		Payload:   data,
-		RequestId: uuid.New().String(),
-		Deadline: messages.InvokeRequest_Timestamp{
-			Seconds: deadline.Unix(),
-			Nanos:   int64(deadline.Nanosecond()),
-		},
+// NewLambdaLambdaRPCClient is a constructor for LambdaRPCClient struct.
+func NewLambdaLambdaRPCClient(address string, executionLimit time.Duration, options ...Option) LambdaRPCClient {
+	return LambdaRPCClient{
+		invokeOptions:  newInvokeOptions(options),
+		address:        address,
+		executionLimit: executionLimit,
 	}
+}
+
+// Invoke sends an RPC request to invoke a lambda function with the given payload data.
+func (l LambdaRPCClient) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	invokeOpts := l.with(options)
+
+	// the messages are only used during the call, they are reused by the next invocations
+	request := invokeRequestPool.Get().(*messages.InvokeRequest) //nolint:forcetypeassert
+	*request = invokeOpts.newRequest(data, l.executionLimit)
+
+	response := invokeResponsePool.Get().(*messages.InvokeResponse) //nolint:forcetypeassert
+
+	// the messages of an abandoned call may still be used by the client, they aren't reused
+	abandoned := false
 
-	client, err := rpc.Dial("tcp", l.address)
+	defer func() {
+		if abandoned {
+			return
+		}
+
+		// cleared so the payloads are released, and the response payload isn't decoded into the
+		// slice returned to the caller
+		*request, *response = messages.InvokeRequest{}, messages.InvokeResponse{}
+		invokeRequestPool.Put(request)
+		invokeResponsePool.Put(response)
+	}()
+
+	conn, err := dialLambda(ctx, l.address, invokeOpts.connectWait)
 	if err != nil {
 		return messages.InvokeResponse{}, fmt.Errorf(
 			"[in lambdalocal.invoke] rpcDial error, address '%s': %w",
@@ -63,19 +250,448 @@ func (l LambdaRPCClient) Invoke(data []byte) (messages.InvokeResponse, error) {
 		)
 	}
 
+	// an invocation that never reached the lambda has no report, like in Lambda
+	report := invokeOpts.startReport(request.RequestId)
+	defer report.end()
+
+	client := rpc.NewClient(conn)
+
 	defer func() {
 		_ = client.Close()
 	}()
 
-	var response messages.InvokeResponse
+	// the deadline of the request is only advisory, a hanging handler is abandoned after the limit
+	timer := time.NewTimer(l.executionLimit)
+	defer timer.Stop()
+
+	select {
+	case call := <-client.Go(invokeOpts.serviceMethod, request, response, make(chan *rpc.Call, 1)).Done:
+		err = call.Error
+	case <-timer.C:
+		abandoned = true
+
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.invoke] %w: lambda did not respond within %s",
+			errInvocationTimeout,
+			l.executionLimit,
+		)
+	case <-ctx.Done():
+		// closing the client drops the connection, the handler's response is discarded
+		abandoned = true
+
+		return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.invoke] invocation canceled: %w", ctx.Err())
+	}
 
-	err = client.Call(l.serviceMethod, request, &response)
 	if err != nil {
+		if drift := rpcDrift(invokeOpts.serviceMethod, err); drift != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.invoke] %w", drift)
+		}
+
 		return messages.InvokeResponse{}, fmt.Errorf(
 			"[in lambdalocal.invoke] client.Call error: %w",
 			err,
 		)
 	}
 
-	return response, nil
+	return *response, nil
+}
+
+const (
+	// ProtocolRPC invokes the lambda using the net/rpc protocol of aws-lambda-go.
+	ProtocolRPC = "rpc"
+	// ProtocolRuntimeAPI invokes the lambda by emulating the AWS Lambda Runtime API.
+	ProtocolRuntimeAPI = "runtime-api"
+
+	runtimeAPIPrefix = "/2018-06-01/runtime"
+)
+
+// newLambdaCaller creates the lambdaCaller for protocol. The returned function releases any
+// resources held by the caller.
+func newLambdaCaller(
+	protocol, address string,
+	executionLimit time.Duration,
+	logger *slog.Logger,
+	options ...Option,
+) (lambdaCaller, func(), error) {
+	switch protocol {
+	case ProtocolRPC:
+		return NewLambdaLambdaRPCClient(address, executionLimit, options...), func() {}, nil
+	case ProtocolRuntimeAPI:
+		runtimeAPI := NewRuntimeAPIClient(address, executionLimit, logger, options...)
+
+		stop, err := runtimeAPI.Start()
+		if err != nil {
+			return nil, nil, fmt.Errorf("[in lambdalocal.newLambdaCaller] start runtime API failed: %w", err)
+		}
+
+		return runtimeAPI, stop, nil
+	default:
+		return nil, nil, fmt.Errorf("[in lambdalocal.newLambdaCaller] unknown protocol '%s'", protocol)
+	}
+}
+
+// newFunctionCallers creates a lambdaCaller for every function in addresses, keyed by function.
+// The returned function releases the resources of all callers.
+func newFunctionCallers(
+	protocol string,
+	addresses map[string]string,
+	executionLimit time.Duration,
+	logger *slog.Logger,
+	options ...Option,
+) (map[string]lambdaCaller, func(), error) {
+	callers := make(map[string]lambdaCaller, len(addresses))
+
+	var closers []func()
+
+	closeAll := func() {
+		for _, closeCaller := range closers {
+			closeCaller()
+		}
+	}
+
+	for function, address := range addresses {
+		caller, closeCaller, err := newLambdaCaller(protocol, address, executionLimit, logger, options...)
+		if err != nil {
+			closeAll()
+
+			return nil, nil, fmt.Errorf("[in lambdalocal.newFunctionCallers] function '%s': %w", function, err)
+		}
+
+		callers[function] = caller
+		closers = append(closers, closeCaller)
+	}
+
+	return callers, closeAll, nil
+}
+
+type runtimeInvocation struct {
+	request  messages.InvokeRequest
+	response chan messages.InvokeResponse
+	// started is when the runtime picked up the invocation.
+	started time.Time
+}
+
+// RuntimeAPIClient invokes a lambda by serving the AWS Lambda Runtime API on address and handing
+// out invocations to the runtime polling it.
+type RuntimeAPIClient struct {
+	invokeOptions
+	// address is the address the Runtime API is served on.
+	address string
+	// executionLimit is the maximum allowed duration of the lambda request
+	executionLimit time.Duration
+	// queue holds invocations waiting to be picked up by the runtime.
+	queue chan *runtimeInvocation
+	// mu guards inFlight.
+	mu sync.Mutex
+	// inFlight holds invocations handed to the runtime, keyed by request id.
+	inFlight map[string]*runtimeInvocation
+	// extensions serves the Extensions API next to the Runtime API.
+	extensions *extensionsAPI
+	// telemetry serves the Telemetry API and records the platform events of invocations.
+	telemetry *telemetryAPI
+	// invoked is closed on the first invocation, which restores a SnapStart snapshot.
+	invoked     chan struct{}
+	invokedOnce sync.Once
+	// restoring is set while the runtime runs its afterRestore hooks.
+	restoring atomic.Bool
+	server    *http.Server
+	logger    *slog.Logger
+}
+
+// NewRuntimeAPIClient is a constructor for RuntimeAPIClient struct.
+func NewRuntimeAPIClient(
+	address string,
+	executionLimit time.Duration,
+	logger *slog.Logger,
+	options ...Option,
+) *RuntimeAPIClient {
+	extensions := newExtensionsAPI(logger)
+	extensions.telemetry = newTelemetryAPI(extensions, logger)
+
+	return &RuntimeAPIClient{
+		invokeOptions:  newInvokeOptions(options),
+		address:        address,
+		executionLimit: executionLimit,
+		queue:          make(chan *runtimeInvocation),
+		inFlight:       make(map[string]*runtimeInvocation),
+		extensions:     extensions,
+		telemetry:      extensions.telemetry,
+		invoked:        make(chan struct{}),
+		logger:         logger,
+	}
+}
+
+// Start begins serving the Runtime API. The returned function stops the server.
+func (l *RuntimeAPIClient) Start() (func(), error) {
+	listener, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.RuntimeAPIClient.Start] listen on '%s' failed: %w", l.address, err)
+	}
+
+	// record the resolved address so port 0 can be used
+	l.address = listener.Addr().String()
+
+	l.server = &http.Server{
+		Handler:           l.handler(),
+		ReadHeaderTimeout: 5 * time.Second, //nolint:mnd
+	}
+
+	l.logger.Info("Runtime API listening, start lambda with AWS_LAMBDA_RUNTIME_API=" + l.address)
+
+	go func() {
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.logger.Error("[in lambdalocal.RuntimeAPIClient.Start] Serve failed", "err", err)
+		}
+	}()
+
+	return func() {
+		l.telemetry.shutdown()
+		l.extensions.shutdown()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownDuration)
+		defer cancel()
+
+		_ = l.server.Shutdown(ctx)
+	}, nil
+}
+
+// Invoke queues an invocation for the runtime and waits for it to post a response or error.
+func (l *RuntimeAPIClient) Invoke(
+	ctx context.Context,
+	data []byte,
+	options ...Option,
+) (messages.InvokeResponse, error) {
+	l.invokedOnce.Do(func() { close(l.invoked) })
+
+	invokeOpts := l.with(options)
+
+	invocation := &runtimeInvocation{
+		request:  invokeOpts.newRequest(data, l.executionLimit),
+		response: make(chan messages.InvokeResponse, 1),
+	}
+
+	timer := time.NewTimer(l.executionLimit)
+	defer timer.Stop()
+
+	select {
+	case l.queue <- invocation:
+	case <-timer.C:
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.RuntimeAPIClient.Invoke] no runtime polled '%s' for the next invocation within %s",
+			l.address,
+			l.executionLimit,
+		)
+	case <-ctx.Done():
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.RuntimeAPIClient.Invoke] invocation canceled: %w",
+			ctx.Err(),
+		)
+	}
+
+	// the runtime picked up the invocation
+	report := invokeOpts.startReport(invocation.request.RequestId)
+	defer report.end()
+
+	select {
+	case response := <-invocation.response:
+		return response, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		delete(l.inFlight, invocation.request.RequestId)
+		l.mu.Unlock()
+
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.RuntimeAPIClient.Invoke] invocation canceled: %w",
+			ctx.Err(),
+		)
+	case <-timer.C:
+		l.mu.Lock()
+		delete(l.inFlight, invocation.request.RequestId)
+		l.mu.Unlock()
+
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.RuntimeAPIClient.Invoke] %w: runtime did not respond within %s",
+			errInvocationTimeout,
+			l.executionLimit,
+		)
+	}
+}
+
+func (l *RuntimeAPIClient) handler() http.Handler {
+	router := http.NewServeMux()
+
+	router.HandleFunc("GET "+runtimeAPIPrefix+"/invocation/next", l.handleNext)
+	router.HandleFunc("POST "+runtimeAPIPrefix+"/invocation/{id}/response", l.handleResponse)
+	router.HandleFunc("POST "+runtimeAPIPrefix+"/invocation/{id}/error", l.handleError)
+	router.HandleFunc("POST "+runtimeAPIPrefix+"/init/error", l.handleInitError)
+	router.HandleFunc("GET "+runtimeAPIPrefix+"/restore/next", l.handleRestoreNext)
+	router.HandleFunc("POST "+runtimeAPIPrefix+"/restore/error", l.handleRestoreError)
+
+	l.extensions.handle(router)
+	l.telemetry.handle(router)
+
+	return router
+}
+
+func (l *RuntimeAPIClient) handleNext(w http.ResponseWriter, r *http.Request) {
+	var invocation *runtimeInvocation
+
+	select {
+	case invocation = <-l.queue:
+	case <-r.Context().Done():
+		return
+	}
+
+	if l.restoring.CompareAndSwap(true, false) {
+		l.logger.Info("Snapshot restored, afterRestore hooks ran")
+		l.telemetry.emit("platform.restoreRuntimeDone", map[string]any{"status": "success"})
+	}
+
+	invocation.started = time.Now()
+
+	l.mu.Lock()
+	l.inFlight[invocation.request.RequestId] = invocation
+	l.mu.Unlock()
+
+	l.extensions.invoke(invocation.request)
+	l.telemetry.emit(
+		"platform.start",
+		map[string]any{"requestId": invocation.request.RequestId, "version": "$LATEST"},
+	)
+
+	deadline := time.Unix(invocation.request.Deadline.Seconds, invocation.request.Deadline.Nanos)
+
+	w.Header().Set("Lambda-Runtime-Aws-Request-Id", invocation.request.RequestId)
+	w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(deadline.UnixMilli(), 10))
+	w.Header().Set("Lambda-Runtime-Invoked-Function-Arn", invocation.request.InvokedFunctionArn)
+	w.Header().Set("Lambda-Runtime-Trace-Id", invocation.request.XAmznTraceId)
+
+	if len(invocation.request.ClientContext) > 0 {
+		w.Header().Set("Lambda-Runtime-Client-Context", string(invocation.request.ClientContext))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(invocation.request.Payload)
+}
+
+func (l *RuntimeAPIClient) handleResponse(w http.ResponseWriter, r *http.Request) {
+	invocation, ok := l.takeInFlight(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "unknown request id", http.StatusBadRequest)
+
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		l.logger.Error("[in lambdalocal.RuntimeAPIClient.handleResponse] failed to read body", "err", err)
+	}
+
+	l.done(invocation, "success")
+
+	invocation.response <- messages.InvokeResponse{Payload: body}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (l *RuntimeAPIClient) handleError(w http.ResponseWriter, r *http.Request) {
+	invocation, ok := l.takeInFlight(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "unknown request id", http.StatusBadRequest)
+
+		return
+	}
+
+	l.done(invocation, "error")
+
+	invocation.response <- messages.InvokeResponse{Error: parseRuntimeError(r)}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (l *RuntimeAPIClient) handleInitError(w http.ResponseWriter, r *http.Request) {
+	runtimeErr := parseRuntimeError(r)
+
+	l.logger.Error("Lambda runtime failed to initialize: " + runtimeErr.Message)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// done records the platform.runtimeDone and platform.report events of invocation.
+func (l *RuntimeAPIClient) done(invocation *runtimeInvocation, status string) {
+	duration := float64(time.Since(invocation.started).Microseconds()) / 1000 //nolint:mnd
+
+	l.telemetry.emit(
+		"platform.runtimeDone",
+		map[string]any{
+			"requestId": invocation.request.RequestId,
+			"status":    status,
+			"metrics":   map[string]any{"durationMs": duration},
+		},
+	)
+	l.telemetry.emit(
+		"platform.report",
+		map[string]any{
+			"requestId": invocation.request.RequestId,
+			"status":    status,
+			"metrics": map[string]any{
+				"durationMs":       duration,
+				"billedDurationMs": math.Ceil(duration),
+			},
+		},
+	)
+}
+
+func (l *RuntimeAPIClient) takeInFlight(requestID string) (*runtimeInvocation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	invocation, ok := l.inFlight[requestID]
+	delete(l.inFlight, requestID)
+
+	return invocation, ok
+}
+
+// parseRuntimeError reads an error posted by a runtime. Runtimes other than Go report the stack
+// trace as a list of strings, so both shapes are accepted.
+func parseRuntimeError(r *http.Request) *messages.InvokeResponse_Error {
+	runtimeErr := struct {
+		Message    string          `json:"errorMessage"`
+		Type       string          `json:"errorType"`
+		StackTrace json.RawMessage `json:"stackTrace"`
+	}{}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil || json.Unmarshal(body, &runtimeErr) != nil {
+		return &messages.InvokeResponse_Error{
+			Message: string(body),
+			Type:    r.Header.Get("Lambda-Runtime-Function-Error-Type"),
+		}
+	}
+
+	invokeErr := &messages.InvokeResponse_Error{
+		Message: runtimeErr.Message,
+		Type:    runtimeErr.Type,
+	}
+
+	var frames []*messages.InvokeResponse_Error_StackFrame
+	if err = json.Unmarshal(runtimeErr.StackTrace, &frames); err == nil {
+		invokeErr.StackTrace = frames
+
+		return invokeErr
+	}
+
+	var lines []string
+	if err = json.Unmarshal(runtimeErr.StackTrace, &lines); err == nil {
+		for _, stackLine := range lines {
+			invokeErr.StackTrace = append(
+				invokeErr.StackTrace,
+				&messages.InvokeResponse_Error_StackFrame{Label: stackLine},
+			)
+		}
+	}
+
+	return invokeErr
 }
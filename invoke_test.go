@@ -5,14 +5,19 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/rpc"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockFunction struct {
@@ -82,6 +87,20 @@ func TestLambdaRPC_Invoke(t *testing.T) { //nolint:nolintlint,paralleltest,cyclo
 
 	go mustStartRPCServer(ctx, mockService, "localhost:8000")
 
+	// wait for the server to accept connections before invoking it
+	require.Eventually(
+		t, func() bool {
+			conn, err := net.Dial("tcp", "localhost:8000")
+			if err != nil {
+				return false
+			}
+
+			_ = conn.Close()
+
+			return true
+		}, time.Second, 10*time.Millisecond,
+	)
+
 	tests := map[string]struct {
 		mockReturn     func()
 		inputAddress   string
@@ -149,7 +168,7 @@ func TestLambdaRPC_Invoke(t *testing.T) { //nolint:nolintlint,paralleltest,cyclo
 					WithServiceMethod(tc.serviceMethod),
 				)
 
-				output, err := lambdaRPC.Invoke(tc.input)
+				output, err := lambdaRPC.Invoke(context.Background(), tc.input)
 
 				assert.Equal(t, tc.expectedOutput, output)
 
@@ -162,3 +181,343 @@ func TestLambdaRPC_Invoke(t *testing.T) { //nolint:nolintlint,paralleltest,cyclo
 		)
 	}
 }
+
+// runtimeClient doesn't keep connections alive. A spare connection dialed while another one is
+// reused would sit unused and hold up the shutdown of the Runtime API server for seconds.
+//
+//nolint:gochecknoglobals
+var runtimeClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// runtimeRoundTrip acts as a runtime polling the Runtime API at address once and answering the
+// invocation by posting body to the given result endpoint.
+func runtimeRoundTrip(t *testing.T, address, result, body string) {
+	t.Helper()
+
+	next, err := runtimeClient.Get("http://" + address + runtimeAPIPrefix + "/invocation/next") //nolint:noctx
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_ = next.Body.Close()
+
+	requestID := next.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+	resp, err := runtimeClient.Post( //nolint:noctx
+		"http://"+address+runtimeAPIPrefix+"/invocation/"+requestID+"/"+result,
+		"application/json",
+		strings.NewReader(body),
+	)
+	if assert.NoError(t, err) {
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	}
+}
+
+func TestRuntimeAPIClient_Invoke(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		result         string
+		body           string
+		executionLimit time.Duration
+		expectedOutput messages.InvokeResponse
+		expectError    bool
+	}{
+		"successful invocation": {
+			result:         "response",
+			body:           `{"statusCode":200}`,
+			executionLimit: time.Second * 5,
+			expectedOutput: messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)},
+		},
+		"go runtime error": {
+			result:         "error",
+			body:           `{"errorMessage":"boom","errorType":"errorString","stackTrace":[{"path":"main.go","line":5,"label":"main"}]}`, //nolint:lll
+			executionLimit: time.Second * 5,
+			expectedOutput: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{
+					Message: "boom",
+					Type:    "errorString",
+					StackTrace: []*messages.InvokeResponse_Error_StackFrame{
+						{Path: "main.go", Line: 5, Label: "main"},
+					},
+				},
+			},
+		},
+		"node runtime error": {
+			result:         "error",
+			body:           `{"errorMessage":"boom","errorType":"Error","stackTrace":["at handler (index.js:1:1)"]}`,
+			executionLimit: time.Second * 5,
+			expectedOutput: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{
+					Message: "boom",
+					Type:    "Error",
+					StackTrace: []*messages.InvokeResponse_Error_StackFrame{
+						{Label: "at handler (index.js:1:1)"},
+					},
+				},
+			},
+		},
+		"no runtime polling": {
+			executionLimit: time.Millisecond * 50,
+			expectedOutput: messages.InvokeResponse{},
+			expectError:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				runtimeAPI := NewRuntimeAPIClient("localhost:0", tc.executionLimit, slog.Default())
+
+				stop, err := runtimeAPI.Start()
+				require.NoError(t, err)
+
+				defer stop()
+
+				if tc.result != "" {
+					go runtimeRoundTrip(t, runtimeAPI.address, tc.result, tc.body)
+				}
+
+				output, err := runtimeAPI.Invoke(context.Background(), []byte(`{}`))
+
+				assert.Equal(t, tc.expectedOutput, output)
+
+				if tc.expectError {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+			},
+		)
+	}
+}
+
+func TestInvokeOptionsNewRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options               []Option
+		expectedClientContext string
+		expectedRequestID     string
+		expectedFunctionARN   string
+		expectedTraceID       string
+	}{
+		"no client context": {
+			options:               nil,
+			expectedClientContext: "",
+		},
+		"client context custom values": {
+			options:               []Option{WithClientContextCustom(map[string]string{"FEATURE": "on"})},
+			expectedClientContext: `{"Client":{"installation_id":"","app_title":"","app_version_code":"","app_package_name":""},"env":null,"custom":{"FEATURE":"on"}}`, //nolint:lll
+		},
+		"request id": {
+			options:           []Option{WithRequestID("correlation-id")},
+			expectedRequestID: "correlation-id",
+		},
+		"function arn and trace id": {
+			options: []Option{
+				WithFunctionARN("arn:aws:lambda:us-east-1:123456789012:function:orders:live"),
+				WithTraceID("Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1"),
+			},
+			expectedFunctionARN: "arn:aws:lambda:us-east-1:123456789012:function:orders:live",
+			expectedTraceID:     "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				request := newInvokeOptions(tc.options).newRequest([]byte("test"), time.Second)
+
+				assert.Equal(t, []byte("test"), request.Payload)
+				assert.NotEmpty(t, request.RequestId)
+				assert.Equal(t, tc.expectedClientContext, string(request.ClientContext))
+				assert.Equal(t, tc.expectedFunctionARN, request.InvokedFunctionArn)
+				assert.Equal(t, tc.expectedTraceID, request.XAmznTraceId)
+
+				if tc.expectedRequestID != "" {
+					assert.Equal(t, tc.expectedRequestID, request.RequestId)
+				}
+			},
+		)
+	}
+}
+
+func TestQualifiedFunctionARN(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		arn       string
+		name      string
+		qualifier string
+		expected  string
+	}{
+		"none": {},
+		"name": {
+			name:     "orders",
+			expected: "arn:aws:lambda:us-east-1:123456789012:function:orders",
+		},
+		"name and version": {
+			name:      "orders",
+			qualifier: "3",
+			expected:  "arn:aws:lambda:us-east-1:123456789012:function:orders:3",
+		},
+		"arn": {
+			arn:      "arn:aws:lambda:eu-west-1:111122223333:function:orders",
+			expected: "arn:aws:lambda:eu-west-1:111122223333:function:orders",
+		},
+		"arn and alias": {
+			arn:       "arn:aws:lambda:eu-west-1:111122223333:function:orders",
+			qualifier: "live",
+			expected:  "arn:aws:lambda:eu-west-1:111122223333:function:orders:live",
+		},
+		"qualifier replaces the one of the arn": {
+			arn:       "arn:aws:lambda:eu-west-1:111122223333:function:orders:1",
+			qualifier: "$LATEST",
+			expected:  "arn:aws:lambda:eu-west-1:111122223333:function:orders:$LATEST",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, qualifiedFunctionARN(tc.arn, tc.name, tc.qualifier))
+			},
+		)
+	}
+}
+
+// echoFunction is a lambda served over RPC that responds with the payload it is invoked with.
+type echoFunction struct{}
+
+func (echoFunction) Invoke(request *messages.InvokeRequest, response *messages.InvokeResponse) error {
+	response.Payload = request.Payload
+
+	return nil
+}
+
+// startEchoFunction serves echoFunction on a random port and returns its address.
+func startEchoFunction(tb testing.TB) string {
+	tb.Helper()
+
+	server := rpc.NewServer()
+	require.NoError(tb, server.RegisterName("Function", echoFunction{}))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(tb, err)
+
+	tb.Cleanup(func() { _ = listener.Close() })
+
+	go server.Accept(listener)
+
+	return listener.Addr().String()
+}
+
+func TestLambdaRPCClientPooledMessages(t *testing.T) {
+	t.Parallel()
+
+	client := NewLambdaLambdaRPCClient(startEchoFunction(t), time.Second)
+
+	first, err := client.Invoke(context.Background(), []byte(`{"invocation":1}`))
+	require.NoError(t, err)
+
+	second, err := client.Invoke(context.Background(), []byte(`{"invocation":2}`))
+	require.NoError(t, err)
+
+	// the messages are reused, the payloads returned earlier are not
+	assert.JSONEq(t, `{"invocation":1}`, string(first.Payload))
+	assert.JSONEq(t, `{"invocation":2}`, string(second.Payload))
+}
+
+// hangingFunction is a lambda served over RPC that never responds until release is closed.
+type hangingFunction struct {
+	release chan struct{}
+}
+
+func (f hangingFunction) Invoke(_ *messages.InvokeRequest, _ *messages.InvokeResponse) error {
+	<-f.release
+
+	return nil
+}
+
+func TestLambdaRPCClientTimeout(t *testing.T) {
+	t.Parallel()
+
+	function := hangingFunction{release: make(chan struct{})}
+	defer close(function.release)
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("Function", function))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go server.Accept(listener)
+
+	client := NewLambdaLambdaRPCClient(listener.Addr().String(), 50*time.Millisecond)
+
+	start := time.Now()
+	_, err = client.Invoke(context.Background(), []byte(`{}`))
+
+	require.ErrorIs(t, err, errInvocationTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// API Gateway answers integrations that time out with a 504
+	rr := httptest.NewRecorder()
+	route := apiRoute{method: http.MethodGet, path: "/slow", payloadFormat: payloadFormatV2}
+	gatewayHandler(client, responseFormat{}, route, nil, slog.New(slog.DiscardHandler)).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Endpoint request timed out")
+}
+
+func TestLambdaRPCClientCanceled(t *testing.T) {
+	t.Parallel()
+
+	function := hangingFunction{release: make(chan struct{})}
+	defer close(function.release)
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("Function", function))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go server.Accept(listener)
+
+	client := NewLambdaLambdaRPCClient(listener.Addr().String(), time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.Invoke(ctx, []byte(`{}`))
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, errInvocationTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func BenchmarkLambdaRPCClientInvoke(b *testing.B) {
+	client := NewLambdaLambdaRPCClient(startEchoFunction(b), time.Second)
+	payload := []byte(`{"path":"/orders/1234","httpMethod":"GET","body":"{\"note\":\"x\"}"}`)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := client.Invoke(context.Background(), payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
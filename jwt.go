@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// noAuthorizer disables the default authorizer for an event.
+	noAuthorizer = "NONE"
+	// defaultIdentitySource is the header JWT authorizers read the token from.
+	defaultIdentitySource = "$request.header.Authorization"
+	// serverlessHTTPAPIType is the resource type whose Auth configures authorizers.
+	serverlessHTTPAPIType = "AWS::Serverless::HttpApi"
+)
+
+var (
+	// errUnauthorized is returned for missing or invalid tokens, API Gateway answers with a 401.
+	errUnauthorized = errors.New("unauthorized")
+	// errForbidden is returned for valid tokens without a required scope, API Gateway answers
+	// with a 403.
+	errForbidden = errors.New("forbidden")
+)
+
+// samHTTPAPIAuth is the Auth property of AWS::Serverless::HttpApi resources and Globals.HttpApi.
+type samHTTPAPIAuth struct {
+	DefaultAuthorizer string `yaml:"DefaultAuthorizer"` //nolint:tagliatelle
+	Authorizers       map[string]struct {
+		IdentitySource   string `yaml:"IdentitySource"` //nolint:tagliatelle
+		JwtConfiguration *struct {
+			// Issuer and Audience are often intrinsic functions, only plain strings are used.
+			Issuer   any `yaml:"issuer"`
+			Audience any `yaml:"audience"`
+		} `yaml:"JwtConfiguration"` //nolint:tagliatelle
+	} `yaml:"Authorizers"` //nolint:tagliatelle
+}
+
+// samEventAuth is the Auth property of HttpApi events.
+type samEventAuth struct {
+	Authorizer          string   `yaml:"Authorizer"`          //nolint:tagliatelle
+	AuthorizationScopes []string `yaml:"AuthorizationScopes"` //nolint:tagliatelle
+}
+
+// jwtAuthorizer is the JWT authorizer protecting an HttpApi route.
+type jwtAuthorizer struct {
+	name string
+	// header is the request header the bearer token is read from.
+	header   string
+	issuer   string
+	audience []string
+	// scopes are the route's AuthorizationScopes, a token needs one of them.
+	scopes []string
+}
+
+// jwtConfig holds the JWT flags. issuer and audience override the template's JwtConfiguration,
+// which often can't be resolved locally.
+type jwtConfig struct {
+	issuer         string
+	audience       []string
+	insecureDecode bool
+}
+
+// apply returns the authorizer with the overrides of c.
+func (c jwtConfig) apply(authorizer jwtAuthorizer) jwtAuthorizer {
+	if c.issuer != "" {
+		authorizer.issuer = c.issuer
+	}
+
+	if len(c.audience) > 0 {
+		authorizer.audience = c.audience
+	}
+
+	return authorizer
+}
+
+// httpAPIAuthorizer returns the JWT authorizer of an HttpApi event, or nil when the route isn't
+// protected by one. The authorizer is looked up on the HttpApi referenced by apiID, or on
+// Globals.HttpApi for the implicit API.
+func httpAPIAuthorizer(
+	resources map[string]samHTTPAPIAuth,
+	globals samHTTPAPIAuth,
+	apiID any,
+	eventAuth samEventAuth,
+) *jwtAuthorizer {
+	auth := globals
+	if api, ok := resources[refName(apiID)]; ok {
+		auth = api
+	}
+
+	name := eventAuth.Authorizer
+	if name == "" {
+		name = auth.DefaultAuthorizer
+	}
+
+	if name == "" || name == noAuthorizer {
+		return nil
+	}
+
+	config, ok := auth.Authorizers[name]
+	if !ok || config.JwtConfiguration == nil {
+		return nil
+	}
+
+	identitySource := config.IdentitySource
+	if identitySource == "" {
+		identitySource = defaultIdentitySource
+	}
+
+	issuer, _ := config.JwtConfiguration.Issuer.(string)
+
+	return &jwtAuthorizer{
+		name:     name,
+		header:   strings.TrimPrefix(identitySource, "$request.header."),
+		issuer:   issuer,
+		audience: templateStrings(config.JwtConfiguration.Audience),
+		scopes:   eventAuth.AuthorizationScopes,
+	}
+}
+
+// refName returns the logical ID of a `!Ref Name` or `{Ref: Name}` value.
+func refName(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case map[string]any:
+		name, _ := value["Ref"].(string)
+
+		return name
+	default:
+		return ""
+	}
+}
+
+// templateStrings returns the plain strings of a template or claim value that is a string or a
+// list. Intrinsic functions and other values are dropped.
+func templateStrings(v any) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// jwtClaims are the claims of an authorized token, as they are added to the event.
+type jwtClaims struct {
+	claims map[string]any
+	scopes []string
+}
+
+type jwtClaimsKey struct{}
+
+// withJWTClaims returns a copy of ctx carrying the claims of the request's token.
+func withJWTClaims(ctx context.Context, claims *jwtClaims) context.Context {
+	return context.WithValue(ctx, jwtClaimsKey{}, claims)
+}
+
+// jwtClaimsFrom returns the claims added by the gateway handler, or nil for unprotected routes.
+func jwtClaimsFrom(ctx context.Context) *jwtClaims {
+	claims, _ := ctx.Value(jwtClaimsKey{}).(*jwtClaims)
+
+	return claims
+}
+
+// flatClaims returns the claims as strings, the way API Gateway passes them to the lambda. Lists
+// become "[a b]" and objects JSON.
+func (c *jwtClaims) flatClaims() map[string]string {
+	flat := make(map[string]string, len(c.claims))
+
+	for key, value := range c.claims {
+		flat[key] = claimString(value)
+	}
+
+	return flat
+}
+
+func claimString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, claimString(item))
+		}
+
+		return "[" + strings.Join(items, " ") + "]"
+	default:
+		out, _ := json.Marshal(v)
+
+		return string(out)
+	}
+}
+
+// jwtValidator validates bearer tokens like an API Gateway JWT authorizer. Signing keys are read
+// from the JWKS of the issuer's OpenID configuration and cached per issuer.
+type jwtValidator struct {
+	// insecureDecode skips the signature check so hand-made tokens can be used.
+	insecureDecode bool
+	client         *http.Client
+	now            func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]map[string]crypto.PublicKey
+}
+
+func newJWTValidator(insecureDecode bool) *jwtValidator {
+	return &jwtValidator{
+		insecureDecode: insecureDecode,
+		client:         &http.Client{Timeout: 5 * time.Second}, //nolint:mnd
+		now:            time.Now,
+		keys:           make(map[string]map[string]crypto.PublicKey),
+	}
+}
+
+// authorize validates the token of r for authorizer and returns its claims. The error wraps
+// errUnauthorized or errForbidden.
+func (v *jwtValidator) authorize(r *http.Request, authorizer jwtAuthorizer) (*jwtClaims, error) {
+	// like API Gateway, the token is accepted with or without the Bearer prefix
+	token := strings.TrimPrefix(r.Header.Get(authorizer.header), "Bearer ")
+	if token == "" {
+		return nil, fmt.Errorf("%w: no token in the %s header", errUnauthorized, authorizer.header)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:mnd
+		return nil, fmt.Errorf("%w: token is not a JWT", errUnauthorized)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %w", errUnauthorized, err)
+	}
+
+	claims := make(map[string]any)
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid claims: %w", errUnauthorized, err)
+	}
+
+	if !v.insecureDecode {
+		if err := v.verifySignature(r.Context(), authorizer.issuer, header.Alg, header.Kid, parts); err != nil {
+			return nil, fmt.Errorf("%w: %w", errUnauthorized, err)
+		}
+	}
+
+	if err := v.checkClaims(claims, authorizer); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnauthorized, err)
+	}
+
+	scopes := tokenScopes(claims)
+
+	if len(authorizer.scopes) == 0 {
+		return &jwtClaims{claims: claims}, nil
+	}
+
+	for _, scope := range authorizer.scopes {
+		for _, tokenScope := range scopes {
+			if scope == tokenScope {
+				return &jwtClaims{claims: claims, scopes: scopes}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: token has none of the scopes %v", errForbidden, authorizer.scopes)
+}
+
+// checkClaims checks the time claims, the issuer and the audience. Like API Gateway, the audience
+// is matched against aud or, for access tokens without aud, client_id.
+func (v *jwtValidator) checkClaims(claims map[string]any, authorizer jwtAuthorizer) error {
+	now := v.now().Unix()
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return errors.New("token has no exp claim")
+	}
+
+	if now >= exp {
+		return errors.New("token is expired")
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now < nbf {
+		return errors.New("token is not valid yet")
+	}
+
+	if iat, ok := numericClaim(claims, "iat"); ok && now < iat {
+		return errors.New("token is issued in the future")
+	}
+
+	if iss, _ := claims["iss"].(string); authorizer.issuer != "" && iss != authorizer.issuer {
+		return fmt.Errorf("issuer '%s' is not '%s'", iss, authorizer.issuer)
+	}
+
+	if len(authorizer.audience) == 0 {
+		return nil
+	}
+
+	audience := templateStrings(claims["aud"])
+	if len(audience) == 0 {
+		audience = templateStrings(claims["client_id"])
+	}
+
+	for _, expected := range authorizer.audience {
+		for _, aud := range audience {
+			if aud == expected {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("audience %v is not one of %v", audience, authorizer.audience)
+}
+
+func (v *jwtValidator) verifySignature(ctx context.Context, issuer, alg, kid string, parts []string) error {
+	if issuer == "" {
+		return errors.New("the authorizer has no issuer to read signing keys from, set --jwt-issuer")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	key, err := v.signingKey(ctx, issuer, kid)
+	if err != nil {
+		return err
+	}
+
+	return verifyJWTSignature(alg, key, []byte(parts[0]+"."+parts[1]), signature)
+}
+
+// signingKey returns the key with kid from the issuer's JWKS. The JWKS is fetched again when the
+// key isn't cached, which picks up rotated keys.
+func (v *jwtValidator) signingKey(ctx context.Context, issuer, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[issuer][kid]; ok {
+		return key, nil
+	}
+
+	keys, err := v.fetchKeys(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("read signing keys of '%s' failed: %w", issuer, err)
+	}
+
+	v.keys[issuer] = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("issuer '%s' has no signing key '%s'", issuer, kid)
+	}
+
+	return key, nil
+}
+
+func (v *jwtValidator) fetchKeys(ctx context.Context, issuer string) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"` //nolint:tagliatelle
+	}
+
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		return nil, err
+	}
+
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("OpenID configuration has no jwks_uri")
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := v.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+
+	for _, key := range jwks.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[key.Kid] = publicKey
+	}
+
+	return keys, nil
+}
+
+func (v *jwtValidator) getJSON(ctx context.Context, url string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, response.Status)
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(target); err != nil {
+		return fmt.Errorf("decode %s failed: %w", url, err)
+	}
+
+	return nil
+}
+
+// jwk is a JSON Web Key with the fields of RSA and EC public keys.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}
+
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+	}
+}
+
+// verifyJWTSignature checks an RS256/384/512 or ES256/384/512 signature of signingInput.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	var (
+		hash   crypto.Hash
+		digest []byte
+	)
+
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		sum := sha256.Sum256(signingInput)
+		hash, digest = crypto.SHA256, sum[:]
+	case "384":
+		sum := sha512.Sum384(signingInput)
+		hash, digest = crypto.SHA384, sum[:]
+	case "512":
+		sum := sha512.Sum512(signingInput)
+		hash, digest = crypto.SHA512, sum[:]
+	default:
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm '%s' doesn't match the RSA key", alg)
+		}
+
+		if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm '%s' doesn't match the EC key", alg)
+		}
+
+		size := len(signature) / 2 //nolint:mnd
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key")
+	}
+
+	return nil
+}
+
+func decodeJWTPart(part string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	return decoder.Decode(target) //nolint:wrapcheck
+}
+
+func numericClaim(claims map[string]any, name string) (int64, bool) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	value, err := number.Float64()
+	if err != nil {
+		return 0, false
+	}
+
+	return int64(value), true
+}
+
+// tokenScopes returns the scopes of the space separated scope claim, or of the scp claim used by
+// some identity providers.
+func tokenScopes(claims map[string]any) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	if scope, ok := claims["scp"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	return templateStrings(claims["scp"])
+}
+
+// writeAuthorizerError writes the response API Gateway returns for a rejected token.
+func writeAuthorizerError(w http.ResponseWriter, err error) {
+	status, message := http.StatusUnauthorized, "Unauthorized"
+	if errors.Is(err, errForbidden) {
+		status, message = http.StatusForbidden, "Forbidden"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"message":"%s"}`, message)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIssuer serves an OpenID configuration and JWKS with an RSA and an EC key.
+type testIssuer struct {
+	url    string
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.url + "/jwks.json"})
+		},
+	)
+	mux.HandleFunc(
+		"GET /jwks.json", func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode(
+				map[string]any{
+					"keys": []jwk{
+						{
+							Kid: "rsa",
+							Kty: "RSA",
+							N:   encode(rsaKey.N.Bytes()),
+							E:   encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+						},
+						{
+							Kid: "ec",
+							Kty: "EC",
+							Crv: "P-256",
+							X:   encode(ecKey.X.FillBytes(make([]byte, 32))),
+							Y:   encode(ecKey.Y.FillBytes(make([]byte, 32))),
+						},
+					},
+				},
+			)
+		},
+	)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	issuer.url = server.URL
+
+	return issuer
+}
+
+// token signs claims with the key kid, "rsa" or "ec". Other kids produce an unsigned token.
+func (i *testIssuer) token(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+
+	alg := map[string]string{"rsa": "RS256", "ec": "ES256"}[kid]
+	if alg == "" {
+		alg = "none"
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+
+	switch kid {
+	case "rsa":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ec":
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		require.NoError(t, err)
+
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTValidatorAuthorize(t *testing.T) {
+	t.Parallel()
+
+	issuer := newTestIssuer(t)
+	now := time.Unix(1_700_000_000, 0)
+	expired := now.Add(-time.Second).Unix()
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   issuer.url,
+			"aud":   "client",
+			"sub":   "user-1",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Add(-time.Minute).Unix(),
+			"scope": "orders/read profile",
+		}
+
+		for key, value := range overrides {
+			if value == nil {
+				delete(c, key)
+
+				continue
+			}
+
+			c[key] = value
+		}
+
+		return c
+	}
+
+	authorizer := jwtAuthorizer{
+		name:     "OAuth",
+		header:   "Authorization",
+		issuer:   issuer.url,
+		audience: []string{"client"},
+	}
+
+	tests := map[string]struct {
+		header         string
+		authorizer     jwtAuthorizer
+		insecureDecode bool
+		expectedErr    error
+		expectedScopes []string
+	}{
+		"valid RS256 token": {
+			header:     "Bearer " + issuer.token(t, "rsa", claims(nil)),
+			authorizer: authorizer,
+		},
+		"valid ES256 token without Bearer prefix": {
+			header:     issuer.token(t, "ec", claims(nil)),
+			authorizer: authorizer,
+		},
+		"client_id matches the audience": {
+			header:     "Bearer " + issuer.token(t, "rsa", claims(map[string]any{"aud": nil, "client_id": "client"})),
+			authorizer: authorizer,
+		},
+		"token with a required scope": {
+			header: "Bearer " + issuer.token(t, "rsa", claims(nil)),
+			authorizer: func() jwtAuthorizer {
+				a := authorizer
+				a.scopes = []string{"orders/write", "orders/read"}
+
+				return a
+			}(),
+			expectedScopes: []string{"orders/read", "profile"},
+		},
+		"token without a required scope": {
+			header: "Bearer " + issuer.token(t, "rsa", claims(nil)),
+			authorizer: func() jwtAuthorizer {
+				a := authorizer
+				a.scopes = []string{"orders/write"}
+
+				return a
+			}(),
+			expectedErr: errForbidden,
+		},
+		"missing token": {
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"expired token": {
+			header:      "Bearer " + issuer.token(t, "rsa", claims(map[string]any{"exp": expired})),
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"token without exp": {
+			header:      "Bearer " + issuer.token(t, "rsa", claims(map[string]any{"exp": nil})),
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"wrong issuer": {
+			header:      "Bearer " + issuer.token(t, "rsa", claims(map[string]any{"iss": "https://other.example.com"})),
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"wrong audience": {
+			header:      "Bearer " + issuer.token(t, "rsa", claims(map[string]any{"aud": []string{"other"}})),
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"unsigned token": {
+			header:      "Bearer " + issuer.token(t, "none", claims(nil)),
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"tampered token": {
+			header:      "Bearer " + issuer.token(t, "rsa", claims(nil)) + "x",
+			authorizer:  authorizer,
+			expectedErr: errUnauthorized,
+		},
+		"unsigned token with insecure decode": {
+			header:         "Bearer " + issuer.token(t, "none", claims(nil)),
+			authorizer:     authorizer,
+			insecureDecode: true,
+		},
+		"expired token with insecure decode": {
+			header:         "Bearer " + issuer.token(t, "none", claims(map[string]any{"exp": expired})),
+			authorizer:     authorizer,
+			insecureDecode: true,
+			expectedErr:    errUnauthorized,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				validator := newJWTValidator(tc.insecureDecode)
+				validator.now = func() time.Time { return now }
+
+				r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+				if tc.header != "" {
+					r.Header.Set("Authorization", tc.header)
+				}
+
+				result, err := validator.authorize(r, tc.authorizer)
+
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "user-1", result.flatClaims()["sub"])
+				assert.Equal(t, tc.expectedScopes, result.scopes)
+			},
+		)
+	}
+}
+
+func TestJWTClaimsFlatClaims(t *testing.T) {
+	t.Parallel()
+
+	claims := jwtClaims{
+		claims: map[string]any{
+			"sub":            "user-1",
+			"exp":            json.Number("1700000000"),
+			"email_verified": true,
+			"cognito:groups": []any{"admin", "users"},
+			"address":        map[string]any{"country": "NL"},
+		},
+	}
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"sub":            "user-1",
+			"exp":            "1700000000",
+			"email_verified": "true",
+			"cognito:groups": "[admin users]",
+			"address":        `{"country":"NL"}`,
+		},
+		claims.flatClaims(),
+	)
+}
+
+func TestGatewayHandlerJWTAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	issuer := newTestIssuer(t)
+	authorizer := &jwtAuthorizer{name: "OAuth", header: "Authorization", issuer: issuer.url}
+	token := issuer.token(
+		t,
+		"rsa",
+		map[string]any{"iss": issuer.url, "sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()},
+	)
+
+	tests := map[string]struct {
+		payloadFormat  string
+		header         string
+		expectedStatus int
+		eventSubject   func(data []byte) string
+	}{
+		"rejected token": {
+			payloadFormat:  payloadFormatV2,
+			header:         "Bearer invalid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"claims in payload format 2.0 events": {
+			payloadFormat:  payloadFormatV2,
+			header:         "Bearer " + token,
+			expectedStatus: http.StatusOK,
+			eventSubject: func(data []byte) string {
+				var event httpAPIEvent
+				if err := json.Unmarshal(data, &event); err != nil || event.RequestContext.Authorizer == nil {
+					return ""
+				}
+
+				return event.RequestContext.Authorizer.JWT.Claims["sub"]
+			},
+		},
+		"claims in payload format 1.0 events": {
+			payloadFormat:  payloadFormatV1,
+			header:         "Bearer " + token,
+			expectedStatus: http.StatusOK,
+			eventSubject: func(data []byte) string {
+				var event genericAPIEvent
+				if err := json.Unmarshal(data, &event); err != nil || event.RequestContext.Authorizer == nil {
+					return ""
+				}
+
+				return event.RequestContext.Authorizer.Claims["sub"]
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(recordingLambdaCaller)
+				route := apiRoute{
+					method:        http.MethodGet,
+					path:          "/orders",
+					payloadFormat: tc.payloadFormat,
+					authorizer:    authorizer,
+				}
+
+				r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+				r.Header.Set("Authorization", tc.header)
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, newJWTValidator(false), slog.Default()).ServeHTTP(rr, r)
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+
+				if tc.eventSubject == nil {
+					assert.JSONEq(t, `{"message":"Unauthorized"}`, rr.Body.String())
+					assert.Nil(t, caller.data)
+
+					return
+				}
+
+				assert.Equal(t, "user-1", tc.eventSubject(caller.data))
+			},
+		)
+	}
+}
+
+func TestJWTConfigApply(t *testing.T) {
+	t.Parallel()
+
+	authorizer := jwtAuthorizer{name: "OAuth", issuer: "${Issuer}", audience: []string{"${Client}"}}
+
+	assert.Equal(t, authorizer, jwtConfig{}.apply(authorizer))
+	assert.Equal(
+		t,
+		jwtAuthorizer{name: "OAuth", issuer: "https://issuer.example.com", audience: []string{"a", "b"}},
+		jwtConfig{issuer: "https://issuer.example.com", audience: []string{"a", "b"}}.apply(authorizer),
+	)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// lambdaInvokePath is the path of the Invoke action of the Lambda API.
+	lambdaInvokePath = "/2015-03-31/functions/{name}/invocations"
+	// lambdaInvocationTypeDryRun only checks that the function can be invoked.
+	lambdaInvocationTypeDryRun = "DryRun"
+)
+
+// lambdaAPIHandler serves the Invoke action of the Lambda API, so code invoking other functions
+// with an SDK's Lambda client can use the local API as its endpoint and reach the locally running
+// handlers.
+type lambdaAPIHandler struct {
+	// functions are the functions that can be invoked. Every name invokes lambdaRPC without them.
+	functions []string
+	// lambdaRPC invokes functions without a caller of their own in functionCallers.
+	lambdaRPC       lambdaCaller
+	functionCallers map[string]lambdaCaller
+	// async queues the invocations of the Event invocation type.
+	async  *asyncInvoker
+	format responseFormat
+	// limits rejects payloads larger than the payload limit of Lambda.
+	limits requestLimits
+	// concurrency throttles the invocations beyond the reserved concurrency of their function.
+	concurrency *reservedConcurrency
+	logger      *slog.Logger
+}
+
+// newLambdaAPIHandler returns the handler of the Lambda API for the functions of routes and
+// functionCallers.
+func newLambdaAPIHandler(
+	lambdaRPC lambdaCaller,
+	functionCallers map[string]lambdaCaller,
+	routes []apiRoute,
+	async *asyncInvoker,
+	format responseFormat,
+	logger *slog.Logger,
+) lambdaAPIHandler {
+	var functions []string
+
+	for _, route := range routes {
+		if route.function != "" && !slices.Contains(functions, route.function) {
+			functions = append(functions, route.function)
+		}
+	}
+
+	for function := range functionCallers {
+		if !slices.Contains(functions, function) {
+			functions = append(functions, function)
+		}
+	}
+
+	return lambdaAPIHandler{
+		functions:       functions,
+		lambdaRPC:       lambdaRPC,
+		functionCallers: functionCallers,
+		async:           async,
+		format:          format,
+		logger:          logger,
+	}
+}
+
+func (h lambdaAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := uuid.New().String()
+	w.Header().Set("X-Amzn-Requestid", requestID)
+
+	function := lambdaFunctionName(r.PathValue("name"))
+	logger := h.logger.With("requestId", requestID, "function", function)
+
+	if len(h.functions) > 0 && !slices.Contains(h.functions, function) {
+		writeLambdaAPIError(
+			w,
+			http.StatusNotFound,
+			"ResourceNotFoundException",
+			fmt.Sprintf("Function not found: %s", lambdaFunctionARN(function)),
+		)
+
+		return
+	}
+
+	body := r.Body
+	if h.limits.payload > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.limits.payload)
+	}
+
+	payload, err := io.ReadAll(body)
+	if isBodyTooLarge(err) {
+		writeLambdaAPIError(
+			w,
+			http.StatusRequestEntityTooLarge,
+			"RequestEntityTooLargeException",
+			h.limits.payloadMessage(),
+		)
+
+		return
+	}
+
+	if err != nil {
+		writeLambdaAPIError(w, http.StatusBadRequest, "InvalidRequestContentException", "read payload failed")
+
+		return
+	}
+
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	if !json.Valid(payload) {
+		writeLambdaAPIError(
+			w,
+			http.StatusBadRequest,
+			"InvalidRequestContentException",
+			"Could not parse request body into json",
+		)
+
+		return
+	}
+
+	options := []Option{WithRequestID(requestID)}
+
+	// the trace of SDK clients in instrumented handlers is continued
+	if traceHeader := r.Header.Get(traceIDHeader); traceHeader != "" {
+		options = append(options, WithTraceID(requestTraceHeader(traceHeader, time.Now())))
+	}
+
+	// the client context is sent base64 encoded, like the SDKs do
+	if clientContext := r.Header.Get("X-Amz-Client-Context"); clientContext != "" {
+		data, decodeErr := base64.StdEncoding.DecodeString(clientContext)
+		if decodeErr != nil || !json.Valid(data) {
+			writeLambdaAPIError(
+				w,
+				http.StatusBadRequest,
+				"InvalidRequestContentException",
+				"Client context must be a valid Base64-encoded JSON object.",
+			)
+
+			return
+		}
+
+		options = append(options, WithClientContext(data))
+	}
+
+	caller := h.lambdaRPC
+	if functionCaller, ok := h.functionCallers[function]; ok {
+		caller = functionCaller
+	}
+
+	caller = h.concurrency.caller(caller, function)
+
+	w.Header().Set("X-Amz-Executed-Version", "$LATEST")
+
+	switch invocationType := r.Header.Get("X-Amz-Invocation-Type"); invocationType {
+	case "", invocationTypeRequestResponse:
+	case invocationTypeEvent:
+		logger.Info("Invoke API queued invocation")
+
+		_, _ = h.async.caller(caller).Invoke(r.Context(), payload, options...)
+
+		w.WriteHeader(http.StatusAccepted)
+
+		return
+	case lambdaInvocationTypeDryRun:
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	default:
+		writeLambdaAPIError(
+			w,
+			http.StatusBadRequest,
+			"InvalidParameterValueException",
+			fmt.Sprintf("unsupported invocation type '%s'", invocationType),
+		)
+
+		return
+	}
+
+	fmt.Println(line) //nolint:forbidigo
+	logger.Info("Invoke API invocation")
+
+	if logger.Enabled(r.Context(), slog.LevelDebug) {
+		logger.Debug("Request headers", "headers", h.format.redaction.requestHeaders(r.Header))
+	}
+
+	invokeResponse, err := caller.Invoke(r.Context(), payload, options...)
+	if errors.Is(err, errFunctionThrottled) {
+		logger.Warn("[in lambdalocal.lambdaAPIHandler.ServeHTTP] invocation throttled", "err", err)
+		writeLambdaAPIError(w, http.StatusTooManyRequests, "TooManyRequestsException", lambdaThrottledMessage)
+
+		return
+	}
+
+	if err != nil {
+		logger.Error("[in lambdalocal.lambdaAPIHandler.ServeHTTP] invoke failed", "err", err)
+		logRPCDrift(logger, err)
+		writeLambdaAPIError(w, http.StatusBadGateway, "ServiceException", err.Error())
+
+		return
+	}
+
+	if err = printResponse(logger, invokeResponse, h.format); err != nil {
+		logger.Error("[in lambdalocal.lambdaAPIHandler.ServeHTTP] printResponse failed", "err", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// function errors are answered with a 200 and the error as payload
+	if invokeResponse.Error != nil {
+		w.Header().Set("X-Amz-Function-Error", "Unhandled")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(invokeResponse.Error)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(invokeResponse.Payload)
+}
+
+// lambdaFunctionName returns the name of a function given by name, partial ARN or ARN, without
+// its qualifier.
+func lambdaFunctionName(function string) string {
+	// ARNs are arn:aws:lambda:REGION:ACCOUNT:function:NAME[:QUALIFIER], partial ARNs
+	// ACCOUNT:function:NAME
+	if _, name, ok := strings.Cut(function, "function:"); ok {
+		function = name
+	}
+
+	name, _, _ := strings.Cut(function, ":")
+
+	return name
+}
+
+// lambdaFunctionARN returns the ARN of function in the local account.
+func lambdaFunctionARN(function string) string {
+	return fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", sqsDefaultRegion, localAccountID, function)
+}
+
+// writeLambdaAPIError writes an error response of the Lambda API, which SDKs read the error code
+// of from the X-Amzn-Errortype header.
+func writeLambdaAPIError(w http.ResponseWriter, status int, code, message string) {
+	errorType := "User"
+	if status >= http.StatusInternalServerError {
+		errorType = "Service"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Amzn-Errortype", code)
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(
+		struct {
+			Type    string `json:"Type"`    //nolint:tagliatelle
+			Message string `json:"message"` //nolint:tagliatelle
+		}{Type: errorType, Message: message},
+	)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLambdaAPIHandler(t *testing.T) {
+	t.Parallel()
+
+	clientContext := `{"custom":{"tenant":"acme"}}`
+
+	tests := map[string]struct {
+		name           string
+		invocationType string
+		clientContext  string
+		response       messages.InvokeResponse
+		// expectedCaller is the caller expected to be invoked, "" when none is.
+		expectedCaller        string
+		expectedStatus        int
+		expectedErrorType     string
+		expectedFunctionError string
+		expectedBody          string
+		expectedClientContext string
+	}{
+		"function name": {
+			name:           "orders",
+			response:       messages.InvokeResponse{Payload: []byte(`{"id":1}`)},
+			expectedCaller: "lambdaRPC",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1}`,
+		},
+		"function with its own address by ARN": {
+			name:           "arn:aws:lambda:us-east-1:123456789012:function:payments:live",
+			response:       messages.InvokeResponse{Payload: []byte(`"paid"`)},
+			expectedCaller: "payments",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"paid"`,
+		},
+		"client context": {
+			name:                  "orders",
+			clientContext:         base64.StdEncoding.EncodeToString([]byte(clientContext)),
+			response:              messages.InvokeResponse{Payload: []byte(`{}`)},
+			expectedCaller:        "lambdaRPC",
+			expectedStatus:        http.StatusOK,
+			expectedBody:          `{}`,
+			expectedClientContext: clientContext,
+		},
+		"function error": {
+			name: "orders",
+			response: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Type: "errorString", Message: "boom"},
+			},
+			expectedCaller:        "lambdaRPC",
+			expectedStatus:        http.StatusOK,
+			expectedFunctionError: "Unhandled",
+			expectedBody:          `{"errorMessage":"boom","errorType":"errorString"}`,
+		},
+		"unknown function": {
+			name:              "customers",
+			expectedStatus:    http.StatusNotFound,
+			expectedErrorType: "ResourceNotFoundException",
+			expectedBody: `{"Type":"User",` +
+				`"message":"Function not found: arn:aws:lambda:us-east-1:123456789012:function:customers"}`,
+		},
+		"event invocation": {
+			name:           "orders",
+			invocationType: invocationTypeEvent,
+			response:       messages.InvokeResponse{Payload: []byte(`{}`)},
+			expectedCaller: "lambdaRPC",
+			expectedStatus: http.StatusAccepted,
+		},
+		"dry run": {
+			name:           "orders",
+			invocationType: lambdaInvocationTypeDryRun,
+			expectedStatus: http.StatusNoContent,
+		},
+		"invalid client context": {
+			name:              "orders",
+			clientContext:     "not base64",
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorType: "InvalidRequestContentException",
+			expectedBody:      `{"Type":"User","message":"Client context must be a valid Base64-encoded JSON object."}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				callers := map[string]*flakyLambdaCaller{
+					"lambdaRPC": {responses: []messages.InvokeResponse{tc.response}, errs: []error{nil}},
+					"payments":  {responses: []messages.InvokeResponse{tc.response}, errs: []error{nil}},
+				}
+
+				async := newAsyncInvoker(asyncPolicy{}, nil, slog.Default())
+				handler := newLambdaAPIHandler(
+					callers["lambdaRPC"],
+					map[string]lambdaCaller{"payments": callers["payments"]},
+					[]apiRoute{{method: http.MethodGet, path: "/orders", function: "orders"}},
+					async,
+					responseFormat{},
+					slog.Default(),
+				)
+
+				router := http.NewServeMux()
+				router.Handle("POST "+lambdaInvokePath, handler)
+
+				req := httptest.NewRequest(
+					http.MethodPost,
+					"/2015-03-31/functions/"+tc.name+"/invocations",
+					strings.NewReader(`{"id":1}`),
+				)
+				req.Header.Set("X-Amz-Invocation-Type", tc.invocationType)
+				req.Header.Set("X-Amz-Client-Context", tc.clientContext)
+
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				async.Wait()
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectedErrorType, rr.Header().Get("X-Amzn-Errortype"))
+				assert.Equal(t, tc.expectedFunctionError, rr.Header().Get("X-Amz-Function-Error"))
+
+				if tc.expectedBody == "" {
+					assert.Empty(t, rr.Body.String())
+				} else {
+					assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+				}
+
+				for callerName, caller := range callers {
+					if callerName != tc.expectedCaller {
+						assert.Empty(t, caller.requestIDs, callerName)
+
+						continue
+					}
+
+					require.Len(t, caller.requestIDs, 1)
+					assert.Equal(t, rr.Header().Get("X-Amzn-Requestid"), caller.requestIDs[0])
+					assert.Equal(t, tc.expectedClientContext, caller.clientContexts[0])
+				}
+			},
+		)
+	}
+}
+
+func TestLambdaFunctionName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"orders": "orders",
+		"arn:aws:lambda:us-east-1:123456789012:function:orders":         "orders",
+		"arn:aws:lambda:us-east-1:123456789012:function:orders:$LATEST": "orders",
+		"123456789012:function:orders":                                  "orders",
+		"orders:live":                                                   "orders",
+	}
+
+	for function, expected := range tests {
+		assert.Equal(t, expected, lambdaFunctionName(function), function)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// apiGatewayMaxRequestSize is the size limit of API Gateway for request bodies.
+	apiGatewayMaxRequestSize = 10 << 20
+	// lambdaMaxPayloadSize is the size limit of Lambda for the payloads of synchronous invocations.
+	lambdaMaxPayloadSize = 6 << 20
+)
+
+// requestLimits are the size limits the deployed services enforce, so requests that would be
+// rejected once deployed are rejected locally too. Limits of 0 aren't enforced.
+type requestLimits struct {
+	// request is the maximum size in bytes of request bodies.
+	request int64
+	// payload is the maximum size in bytes of the events and payloads the lambda is invoked with.
+	payload int64
+}
+
+// limitBody rejects requests with a body larger than the request limit, and limits the body of
+// r to it for bodies of unknown size. It reports whether the request can go on.
+func (l requestLimits) limitBody(w http.ResponseWriter, r *http.Request) bool {
+	if l.request <= 0 {
+		return true
+	}
+
+	if r.ContentLength > l.request {
+		writeGatewayError(w, r, gatewayMessageTooLong, http.StatusRequestEntityTooLarge)
+
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, l.request)
+
+	return true
+}
+
+// exceedsPayload reports whether a payload of size bytes exceeds the payload limit.
+func (l requestLimits) exceedsPayload(size int) bool {
+	return l.payload > 0 && int64(size) > l.payload
+}
+
+// payloadMessage returns the message Lambda rejects payloads exceeding the payload limit with.
+func (l requestLimits) payloadMessage() string {
+	return fmt.Sprintf("Request must be smaller than %d bytes for the InvokeFunction operation", l.payload)
+}
+
+// isBodyTooLarge reports whether err is caused by a body exceeding the limit of limitBody.
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayHandlerLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		limits requestLimits
+		body   string
+		// chunked sends the body without a Content-Length.
+		chunked        bool
+		expectedStatus int
+		expectedBody   string
+		expectInvoke   bool
+	}{
+		"within the limits": {
+			limits:         requestLimits{request: 100, payload: 10_000},
+			body:           strings.Repeat("a", 100),
+			expectedStatus: http.StatusOK,
+			expectInvoke:   true,
+		},
+		"request too large": {
+			limits:         requestLimits{request: 100, payload: 10_000},
+			body:           strings.Repeat("a", 101),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"message":"Request Too Long"}`,
+		},
+		"chunked request too large": {
+			limits:         requestLimits{request: 100, payload: 10_000},
+			body:           strings.Repeat("a", 101),
+			chunked:        true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"message":"Request Too Long"}`,
+		},
+		"event too large": {
+			limits:         requestLimits{request: 1000, payload: 500},
+			body:           strings.Repeat("a", 400),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"message":"Request must be smaller than 500 bytes for the InvokeFunction operation"}`,
+		},
+		"no limits": {
+			body:           strings.Repeat("a", 1000),
+			chunked:        true,
+			expectedStatus: http.StatusOK,
+			expectInvoke:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(recordingLambdaCaller)
+				route := apiRoute{
+					method:        http.MethodPost,
+					path:          "/test",
+					payloadFormat: payloadFormatV2,
+					limits:        tc.limits,
+				}
+
+				var body io.Reader = strings.NewReader(tc.body)
+				if tc.chunked {
+					// hides the size of the body from httptest.NewRequest
+					body = io.MultiReader(body)
+				}
+
+				rr := httptest.NewRecorder()
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+					ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/test", body))
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectInvoke, caller.data != nil)
+
+				if tc.expectedBody != "" {
+					assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+				}
+			},
+		)
+	}
+}
+
+func TestLambdaAPIHandlerLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payload           string
+		expectedStatus    int
+		expectedErrorType string
+		expectInvoke      bool
+	}{
+		"within the limit": {
+			payload:        `"` + strings.Repeat("a", 98) + `"`,
+			expectedStatus: http.StatusOK,
+			expectInvoke:   true,
+		},
+		"payload too large": {
+			payload:           `"` + strings.Repeat("a", 99) + `"`,
+			expectedStatus:    http.StatusRequestEntityTooLarge,
+			expectedErrorType: "RequestEntityTooLargeException",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := &flakyLambdaCaller{
+					responses: []messages.InvokeResponse{{Payload: []byte(`{}`)}},
+					errs:      []error{nil},
+				}
+
+				handler := newLambdaAPIHandler(caller, nil, nil, nil, responseFormat{}, slog.Default())
+				handler.limits = requestLimits{payload: 100}
+
+				router := http.NewServeMux()
+				router.Handle("POST "+lambdaInvokePath, handler)
+
+				req := httptest.NewRequest(
+					http.MethodPost,
+					"/2015-03-31/functions/orders/invocations",
+					strings.NewReader(tc.payload),
+				)
+
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectedErrorType, rr.Header().Get("X-Amzn-Errortype"))
+
+				if tc.expectInvoke {
+					require.Len(t, caller.requestIDs, 1)
+				} else {
+					assert.Empty(t, caller.requestIDs)
+					assert.JSONEq(
+						t,
+						`{"Type":"User","message":"Request must be smaller than 100 bytes for the InvokeFunction `+
+							`operation"}`,
+						rr.Body.String(),
+					)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// lockFileName is the registry of the lambdalocal instances of a project, next to its config.
+	lockFileName = ".lambdalocal.lock"
+	// shutdownPath is the control API endpoint that stops the server, used by --takeover.
+	shutdownPath = "/__lambdalocal/shutdown"
+	// controlTokenHeader carries the token of an instance, which authorizes control API requests.
+	controlTokenHeader = "X-Lambdalocal-Token"
+	// takeoverTimeout is how long --takeover waits for the old instance to exit.
+	takeoverTimeout = 10 * time.Second
+)
+
+// lambdalocalInstance is a running api command, as registered in the lock file.
+type lambdalocalInstance struct {
+	PID       int       `json:"pid"`
+	Address   string    `json:"address"`
+	Template  string    `json:"template"`
+	Token     string    `json:"token"`
+	StartedAt time.Time `json:"startedAt"`
+	// TLS is set for instances serving https.
+	TLS bool `json:"tls,omitempty"`
+}
+
+// instanceRegistry coordinates the instances of a project through its lock file, so a second
+// instance warns about conflicts instead of failing with "address already in use".
+type instanceRegistry struct {
+	path string
+	// alive reports if the process of an instance still runs, instances of processes that don't
+	// are dropped.
+	alive  func(pid int) bool
+	client *http.Client
+	logger *slog.Logger
+}
+
+// newLambdalocalInstance returns the instance of this process serving template on addr, with a new
+// control token.
+func newLambdalocalInstance(addr, template string) lambdalocalInstance {
+	if abs, err := filepath.Abs(template); err == nil {
+		template = abs
+	}
+
+	return lambdalocalInstance{
+		PID:       os.Getpid(),
+		Address:   addr,
+		Template:  template,
+		Token:     uuid.New().String(),
+		StartedAt: time.Now().UTC(),
+	}
+}
+
+func newInstanceRegistry(configPath string, logger *slog.Logger) instanceRegistry {
+	return instanceRegistry{
+		path:  filepath.Join(filepath.Dir(configPath), lockFileName),
+		alive: processAlive,
+		client: &http.Client{
+			Timeout: 5 * time.Second, //nolint:mnd
+			// instances serving https may use self-signed certificates, the token authorizes the request
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, //nolint:gosec
+		},
+		logger: logger,
+	}
+}
+
+// register adds self to the lock file. An instance on the same address is an error, unless
+// takeover is set, in which case it is shut down through its control API. Instances serving the
+// same template on other addresses are warned about. The returned func removes self again.
+func (r instanceRegistry) register(
+	ctx context.Context,
+	self lambdalocalInstance,
+	takeover bool,
+) (func(), error) {
+	instances, err := r.read()
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.instanceRegistry.register] %w", err)
+	}
+
+	for _, instance := range instances {
+		switch {
+		case instance.Address == self.Address && !takeover:
+			return nil, fmt.Errorf(
+				"[in lambdalocal.instanceRegistry.register] lambdalocal (pid %d) already serves %s, "+
+					"stop it or start with --takeover",
+				instance.PID,
+				instance.Address,
+			)
+		case instance.Address == self.Address:
+			if err = r.takeOver(ctx, instance); err != nil {
+				return nil, fmt.Errorf("[in lambdalocal.instanceRegistry.register] %w", err)
+			}
+		case instance.Template == self.Template:
+			r.logger.Warn(
+				fmt.Sprintf(
+					"lambdalocal (pid %d) serves the same template on %s, requests may go to either",
+					instance.PID,
+					instance.Address,
+				),
+			)
+		}
+	}
+
+	if err = r.update(func(instances []lambdalocalInstance) []lambdalocalInstance {
+		return append(instances, self)
+	}); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.instanceRegistry.register] %w", err)
+	}
+
+	unregister := func() {
+		err := r.update(func(instances []lambdalocalInstance) []lambdalocalInstance {
+			return slices.DeleteFunc(instances, func(instance lambdalocalInstance) bool {
+				return instance.PID == self.PID
+			})
+		})
+		if err != nil {
+			r.logger.Warn("[in lambdalocal.instanceRegistry] unregister failed", "err", err)
+		}
+	}
+
+	return unregister, nil
+}
+
+// takeOver shuts instance down through its control API and waits for its process to exit.
+func (r instanceRegistry) takeOver(ctx context.Context, instance lambdalocalInstance) error {
+	r.logger.Info(fmt.Sprintf("Taking over %s from lambdalocal (pid %d)", instance.Address, instance.PID))
+
+	scheme := "http"
+	if instance.TLS {
+		scheme = "https"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+"://"+instance.Address+shutdownPath, nil)
+	if err != nil {
+		return fmt.Errorf("takeover failed: %w", err)
+	}
+
+	req.Header.Set(controlTokenHeader, instance.Token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("takeover of pid %d failed: %w", instance.PID, err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("takeover of pid %d failed with status %d", instance.PID, resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(takeoverTimeout)
+
+	for r.alive(instance.PID) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("lambdalocal (pid %d) didn't exit within %s", instance.PID, takeoverTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case <-time.After(100 * time.Millisecond): //nolint:mnd
+		}
+	}
+
+	return nil
+}
+
+// read returns the instances of the lock file whose process still runs.
+func (r instanceRegistry) read() ([]lambdalocalInstance, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read lock file failed: %w", err)
+	}
+
+	var instances []lambdalocalInstance
+	if err = json.Unmarshal(data, &instances); err != nil {
+		r.logger.Warn(fmt.Sprintf("ignoring invalid lock file %s", r.path), "err", err)
+
+		return nil, nil
+	}
+
+	return slices.DeleteFunc(instances, func(instance lambdalocalInstance) bool {
+		return !r.alive(instance.PID)
+	}), nil
+}
+
+// update rewrites the lock file with the instances returned by change. The file is removed once
+// no instance is left.
+func (r instanceRegistry) update(change func([]lambdalocalInstance) []lambdalocalInstance) error {
+	instances, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	instances = change(instances)
+	if len(instances) == 0 {
+		if err = os.Remove(r.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove lock file failed: %w", err)
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal lock file failed: %w", err)
+	}
+
+	// write and rename, so other instances never read a partial file
+	tmp := r.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("write lock file failed: %w", err)
+	}
+
+	if err = os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("write lock file failed: %w", err)
+	}
+
+	return nil
+}
+
+// shutdownHandler answers control API shutdown requests carrying token by calling shutdown.
+func shutdownHandler(token string, shutdown func(), logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(controlTokenHeader)), []byte(token)) != 1 {
+				writeGatewayError(w, r, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+				return
+			}
+
+			logger.Info("Shutdown requested by another lambdalocal instance")
+			w.WriteHeader(http.StatusAccepted)
+
+			shutdown()
+		},
+	)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceRegistryRegister(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		// running are the instances in the lock file, instances with a negative PID have exited.
+		running           []lambdalocalInstance
+		expectedErr       string
+		expectedInstances []int
+	}{
+		"first instance": {
+			expectedInstances: []int{1},
+		},
+		"instances on other ports": {
+			running:           []lambdalocalInstance{{PID: 2, Address: "localhost:9090", Template: "/p/template.yaml"}},
+			expectedInstances: []int{2, 1},
+		},
+		"instance on the same port": {
+			running:     []lambdalocalInstance{{PID: 2, Address: "localhost:8080"}},
+			expectedErr: "lambdalocal (pid 2) already serves localhost:8080, stop it or start with --takeover",
+		},
+		"exited instances are dropped": {
+			running:           []lambdalocalInstance{{PID: -2, Address: "localhost:8080"}},
+			expectedInstances: []int{1},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				registry := testInstanceRegistry(t, tc.running)
+
+				unregister, err := registry.register(
+					context.Background(),
+					lambdalocalInstance{PID: 1, Address: "localhost:8080", Template: "/p/template.yaml"},
+					false,
+				)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedInstances, registeredPIDs(t, registry))
+
+				unregister()
+
+				if len(tc.running) == 0 || tc.running[0].PID < 0 {
+					assert.NoFileExists(t, registry.path)
+				} else {
+					assert.Equal(t, tc.expectedInstances[:1], registeredPIDs(t, registry))
+				}
+			},
+		)
+	}
+}
+
+func TestInstanceRegistryTakeover(t *testing.T) {
+	t.Parallel()
+
+	for name, useTLS := range map[string]bool{"http": false, "https": true} {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var exited atomic.Bool
+
+				handler := shutdownHandler("secret", func() { exited.Store(true) }, slog.Default())
+
+				// instances serving https are taken over despite their self-signed certificates
+				var server *httptest.Server
+				if useTLS {
+					server = httptest.NewTLSServer(handler)
+				} else {
+					server = httptest.NewServer(handler)
+				}
+
+				t.Cleanup(server.Close)
+
+				address := strings.TrimPrefix(strings.TrimPrefix(server.URL, "http://"), "https://")
+
+				registry := testInstanceRegistry(
+					t,
+					[]lambdalocalInstance{{PID: 2, Address: address, Token: "secret", TLS: useTLS}},
+				)
+				registry.alive = func(pid int) bool {
+					return pid == 1 || !exited.Load()
+				}
+
+				unregister, err := registry.register(
+					context.Background(),
+					lambdalocalInstance{PID: 1, Address: address, TLS: useTLS},
+					true,
+				)
+				require.NoError(t, err)
+
+				defer unregister()
+
+				assert.True(t, exited.Load())
+				assert.Equal(t, []int{1}, registeredPIDs(t, registry))
+			},
+		)
+	}
+}
+
+func TestShutdownHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		token            string
+		expectedStatus   int
+		expectedShutdown bool
+	}{
+		"token of the instance": {
+			token:            "secret",
+			expectedStatus:   http.StatusAccepted,
+			expectedShutdown: true,
+		},
+		"other token": {
+			token:          "guess",
+			expectedStatus: http.StatusForbidden,
+		},
+		"no token": {
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				shutdown := false
+				handler := shutdownHandler("secret", func() { shutdown = true }, slog.Default())
+
+				req := httptest.NewRequest(http.MethodPost, shutdownPath, nil)
+				if tc.token != "" {
+					req.Header.Set(controlTokenHeader, tc.token)
+				}
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				assert.Equal(t, tc.expectedStatus, rec.Code)
+				assert.Equal(t, tc.expectedShutdown, shutdown)
+			},
+		)
+	}
+}
+
+// testInstanceRegistry returns a registry with a lock file of the running instances in a temp dir.
+// Processes with a positive PID are alive.
+func testInstanceRegistry(t *testing.T, running []lambdalocalInstance) instanceRegistry {
+	t.Helper()
+
+	registry := newInstanceRegistry(filepath.Join(t.TempDir(), "lambdalocal.yaml"), slog.Default())
+	registry.alive = func(pid int) bool { return pid > 0 }
+
+	if len(running) > 0 {
+		data, err := json.Marshal(running)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(registry.path, data, 0o600))
+	}
+
+	return registry
+}
+
+func registeredPIDs(t *testing.T, registry instanceRegistry) []int {
+	t.Helper()
+
+	instances, err := registry.read()
+	require.NoError(t, err)
+
+	pids := make([]int, 0, len(instances))
+	for _, instance := range instances {
+		pids = append(pids, instance.PID)
+	}
+
+	return pids
+}
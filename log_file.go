@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+const (
+	// logFileDefaultMaxSize is the size in MB of the --log-file before it is rotated.
+	logFileDefaultMaxSize = 10
+	// logFileDefaultMaxBackups is the number of rotated log files kept.
+	logFileDefaultMaxBackups = 3
+)
+
+// ansiEscapeRegex matches the color codes of the console output, they are left out of the log file.
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`) //nolint:gochecknoglobals
+
+// logTee writes the output of lambdalocal to the console and, once opened, to the --log-file too.
+type logTee struct {
+	console io.Writer
+
+	mu   sync.Mutex
+	file *rotatingFile
+}
+
+// open tees the output to the file at path, rotated like rotatingFile.
+func (t *logTee) open(path string, maxSize int64, maxBackups int) error {
+	file, err := openRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.file = file
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *logTee) Write(p []byte) (int, error) {
+	n, err := t.console.Write(p)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// a log file that can't be written never fails the console output
+	if t.file != nil {
+		_, _ = t.file.Write(ansiEscapeRegex.ReplaceAll(p, nil))
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// Close closes the log file.
+func (t *logTee) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+
+	return t.file.Close()
+}
+
+// rotatingFile is a file that is rotated once it grows past maxSize bytes: path is renamed to
+// path.1, path.1 to path.2 and so on, keeping up to maxBackups rotated files.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens the file at path for appending, creating it and its directory when they
+// don't exist.
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:mnd
+		return nil, fmt.Errorf("[in lambdalocal.openRotatingFile] create directory failed: %w", err)
+	}
+
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.openFile(); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.openRotatingFile] %w", err)
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) openFile() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:mnd,gosec
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return err //nolint:wrapcheck
+	}
+
+	r.file, r.size = file, info.Size()
+
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p would grow it past maxSize. Writes are
+// never split, so a file only exceeds maxSize when a single write does.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// rotate renames the file and its backups, dropping the oldest backup, and opens a new file.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("close log file failed: %w", err)
+	}
+
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove log file failed: %w", err)
+		}
+
+		return r.openFile()
+	}
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate log file failed: %w", err)
+		}
+	}
+
+	if err := os.Rename(r.path, r.backup(1)); err != nil {
+		return fmt.Errorf("rotate log file failed: %w", err)
+	}
+
+	return r.openFile()
+}
+
+// backup returns the path of the ith rotated file.
+func (r *rotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close() //nolint:wrapcheck
+}
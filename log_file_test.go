@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogTee(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "lambdalocal.log")
+
+	var console bytes.Buffer
+
+	tee := &logTee{console: &console}
+
+	// output before the file is opened only goes to the console
+	_, err := tee.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, tee.open(path, 1024, 1))
+
+	_, err = tee.Write([]byte("\x1b[2m08:04:36.419\x1b[0m \x1b[92mINF\x1b[0m Starting server\n"))
+	require.NoError(t, err)
+	require.NoError(t, tee.Close())
+
+	assert.Equal(t, "before\n\x1b[2m08:04:36.419\x1b[0m \x1b[92mINF\x1b[0m Starting server\n", console.String())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "08:04:36.419 INF Starting server\n", string(data))
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxBackups int
+		want       []string
+	}{
+		"no backups": {
+			maxBackups: 0,
+			want:       []string{"eeee\n"},
+		},
+		"one backup": {
+			maxBackups: 1,
+			want:       []string{"eeee\n", "dddd\n"},
+		},
+		"two backups": {
+			maxBackups: 2,
+			want:       []string{"eeee\n", "dddd\n", "cccc\n"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				path := filepath.Join(t.TempDir(), "lambdalocal.log")
+
+				file, err := openRotatingFile(path, 8, tc.maxBackups)
+				require.NoError(t, err)
+
+				for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n"} {
+					_, err := file.Write([]byte(line))
+					require.NoError(t, err)
+				}
+
+				require.NoError(t, file.Close())
+
+				for i, want := range tc.want {
+					name := path
+					if i > 0 {
+						name = file.backup(i)
+					}
+
+					data, err := os.ReadFile(name)
+					require.NoError(t, err)
+					assert.Equal(t, want, string(data), name)
+				}
+
+				assert.NoFileExists(t, file.backup(len(tc.want)))
+			},
+		)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "lambdalocal.log")
+	require.NoError(t, os.WriteFile(path, []byte("aaaa\n"), 0o600))
+
+	// the size of the existing file counts towards the rotation
+	file, err := openRotatingFile(path, 8, 1)
+	require.NoError(t, err)
+
+	_, err = file.Write([]byte("bbbb\n"))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "bbbb\n", string(data))
+
+	data, err = os.ReadFile(file.backup(1))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa\n", string(data))
+}
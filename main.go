@@ -1,14 +1,20 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +27,11 @@ var line = strings.Repeat("-", 75) //nolint:gochecknoglobals,mnd
 func main() {
 	ctx := context.Background()
 	if err := run(ctx, os.Stdout); err != nil {
+		var drift *rpcDriftError
+		if errors.As(err, &drift) {
+			_, _ = fmt.Fprintln(os.Stderr, rpcCompatibilityTable())
+		}
+
 		log.Fatal(err)
 	}
 }
@@ -28,6 +39,14 @@ func main() {
 func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 	logLevel := slog.LevelInfo
 
+	// the output is teed to the --log-file, which is opened before the command runs
+	output := &logTee{console: w}
+	defer func() {
+		_ = output.Close()
+	}()
+
+	w = output
+
 	cmd := &cli.Command{
 		Usage: "A tool for invoking AWS Lambdas locally",
 		Flags: []cli.Flag{
@@ -35,7 +54,8 @@ func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 				Name:    "address",
 				Aliases: []string{"a"},
 				Value:   "localhost:8000",
-				Usage:   "Address of locally running lambda. Port can be set with env var _LAMBDA_SERVER_PORT.",
+				Usage: "Address of locally running lambda. Port can be set with env var _LAMBDA_SERVER_PORT. " +
+					"With --protocol runtime-api, the address the Runtime API is served on.",
 			},
 			&cli.BoolFlag{
 				Name:    "parse-json",
@@ -43,12 +63,229 @@ func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 				Value:   false,
 				Usage:   "Parse response values like 'body' as JSON.",
 			},
+			&cli.BoolFlag{
+				Name:  "preserve-numbers",
+				Value: true,
+				Usage: "Print the numbers of payloads as returned, so 64-bit IDs aren't rounded. On by default, " +
+					"--preserve-numbers=false prints them as float64 like encoding/json does.",
+			},
+			&cli.StringFlag{
+				Name:  "key-order",
+				Value: keyOrderInsertion,
+				Usage: fmt.Sprintf(
+					"Order of the keys of printed payloads, '%s' as returned by the lambda or '%s', so payloads of "+
+						"different runs diff cleanly.",
+					keyOrderInsertion,
+					keyOrderSorted,
+				),
+				Action: func(_ context.Context, _ *cli.Command, v string) error {
+					if v != keyOrderInsertion && v != keyOrderSorted {
+						return fmt.Errorf(
+							"key order must be '%s' or '%s'. Got %v",
+							keyOrderInsertion,
+							keyOrderSorted,
+							v,
+						)
+					}
+
+					return nil
+				},
+			},
 			&cli.IntFlag{
 				Name:    "executionLimit",
 				Aliases: []string{"e"},
 				Value:   5, //nolint:mnd
 				Usage:   "Execution time limit for this lambda in seconds.",
 			},
+			&cli.DurationFlag{
+				Name:    "wait",
+				Aliases: []string{"connect-retries"},
+				Usage: "Retry connections the lambda refuses with exponential backoff for up to `DURATION`, e.g. " +
+					"30s, so lambdalocal can start before the handler process. api waits for the lambda on startup.",
+			},
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Value:   defaultConfigPath,
+				Usage:   "Path to the lambdalocal project config. Ignored when the file doesn't exist.",
+			},
+			&cli.StringMapFlag{
+				Name: "context-env",
+				Usage: "`KEY=VALUE` placed in the custom map of the invocation's client context. Can be repeated. " +
+					"Handlers read the values with github.com/j-d-ha/lambdalocal/contextenv.",
+			},
+			&cli.StringFlag{
+				Name:  "function-arn",
+				Usage: "`ARN` of the invoked function, the InvokedFunctionArn of the invocations.",
+				Action: func(_ context.Context, _ *cli.Command, v string) error {
+					if !strings.HasPrefix(v, "arn:") || !strings.Contains(v, ":lambda:") {
+						return fmt.Errorf("function arn must be the ARN of a lambda function. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name: "function-name",
+				Usage: "`NAME` of the invoked function, the InvokedFunctionArn of the invocations is its ARN in " +
+					"the local account and region.",
+				Action: func(_ context.Context, cmd *cli.Command, _ string) error {
+					if cmd.String("function-arn") != "" {
+						return errors.New("--function-name can't be combined with --function-arn")
+					}
+
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "qualifier",
+				Usage: "Version or alias `QUALIFIER` of the invoked function, appended to its InvokedFunctionArn.",
+				Action: func(_ context.Context, cmd *cli.Command, v string) error {
+					if !qualifierRegex.MatchString(v) {
+						return fmt.Errorf("qualifier must be a version, an alias or $LATEST. Got %v", v)
+					}
+
+					if cmd.String("function-arn") == "" && cmd.String("function-name") == "" {
+						return errors.New("--qualifier needs --function-name or --function-arn")
+					}
+
+					return nil
+				},
+			},
+			&cli.StringSliceFlag{
+				Name:  "redact-headers",
+				Value: defaultRedactHeaders,
+				Usage: "`HEADER` whose value is redacted from the logged requests and payloads. Replaces the " +
+					"defaults when set, '' redacts no headers. Can be repeated.",
+			},
+			&cli.StringSliceFlag{
+				Name: "redact-json-paths",
+				Usage: "Dot separated `PATH` of a field redacted from the logged payloads, '*' matches any key or " +
+					"array element. Fields of string values holding JSON, like body, are matched too. Can be " +
+					"repeated.",
+			},
+			&cli.IntFlag{
+				Name: "max-log-body",
+				Usage: "Truncate the payloads printed to the logs to `BYTES`, like large base64 bodies. The " +
+					"responses of the api are sent whole. 0 prints payloads whole.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v < 0 {
+						return fmt.Errorf("expected zero or more bytes. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name: "log-file",
+				Usage: "Write the output, the logs and the payloads of the lambda, to the file at `PATH` too. The " +
+					"file is rotated once it reaches --log-file-max-size.",
+			},
+			&cli.IntFlag{
+				Name:  "log-file-max-size",
+				Value: logFileDefaultMaxSize,
+				Usage: "Size in `MB` of the --log-file before it is rotated to PATH.1.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v <= 0 {
+						return fmt.Errorf("expected a positive size. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.IntFlag{
+				Name:  "log-file-max-backups",
+				Value: logFileDefaultMaxBackups,
+				Usage: "Number of rotated log files kept, older ones are deleted.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v < 0 {
+						return fmt.Errorf("expected zero or more files. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name: "report-lines",
+				Usage: "Print the START, END and REPORT lines of every invocation like Lambda writes them to " +
+					"CloudWatch Logs, with the MemorySize of the function in the template.",
+			},
+			&cli.StringFlag{
+				Name: "trace-id",
+				Usage: "X-Ray trace `HEADER` of the invocations, the XAmznTraceId, " +
+					"e.g. Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1. api requests get a trace of their own.",
+			},
+			&cli.StringFlag{
+				Name:    "run",
+				Aliases: []string{"exec"},
+				Usage: "Shell `COMMAND` that starts the lambda, e.g. \"go run ./cmd/fn\". The process is " +
+					"started with _LAMBDA_SERVER_PORT set from --address, or AWS_LAMBDA_RUNTIME_API with " +
+					"--protocol runtime-api, and stopped on exit.",
+			},
+			&cli.StringSliceFlag{
+				Name: "parameter-overrides",
+				Usage: "`KEY=VALUE` setting the template parameter KEY, used to resolve !Ref and !Sub. Can be " +
+					"repeated, the ParameterKey=KEY,ParameterValue=VALUE form is accepted too.",
+			},
+			&cli.StringSliceFlag{
+				Name: "env-file",
+				Usage: "Load the KEY=VALUE lines of the dotenv `FILE`, e.g. .env.local, into the environment of the " +
+					"--run process, overriding the variables of the template, and the {{$dotenv NAME}} variables of " +
+					".http files. Can be repeated, later files win.",
+			},
+			&cli.StringFlag{
+				Name: "stats-file",
+				Usage: "Record invocation counts and latencies per route in `FILE`, shown by the stats command. " +
+					"Overrides statsFile of the config, nothing is recorded without either.",
+			},
+			&cli.StringFlag{
+				Name: "store",
+				Usage: "`LOCATION` of the data lambdalocal keeps, like the --stats-file and the recordings of api " +
+					"--record: a directory, sqlite://PATH for a SQLite database or s3://BUCKET/PREFIX for a bucket " +
+					"shared by a team. Overrides store of the config, files are kept next to the --stats-file and " +
+					"in " + defaultStoreDir + " without either.",
+			},
+			&cli.DurationFlag{
+				Name: "record-max-age",
+				Usage: "Delete the recordings of api --record older than `DURATION` on start and with record prune. " +
+					"0 keeps them.",
+				Action: func(_ context.Context, _ *cli.Command, v time.Duration) error {
+					if v < 0 {
+						return fmt.Errorf("expected zero or a positive duration. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.IntFlag{
+				Name: "record-max-size",
+				Usage: "Delete the oldest recordings of api --record beyond `MB` on start and with record prune. " +
+					"0 keeps them.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v < 0 {
+						return fmt.Errorf("expected zero or more MB. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.IntFlag{
+				Name: "record-max-count",
+				Usage: "Delete the oldest recordings of api --record beyond `COUNT` on start and with record prune. " +
+					"0 keeps them.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v < 0 {
+						return fmt.Errorf("expected zero or more recordings. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.StringSliceFlag{
+				Name: "plugin",
+				Usage: "WASM `FILE` that transforms events before and responses after every invocation, run in " +
+					"order after the plugins of the config. Can be repeated.",
+			},
 			&cli.BoolFlag{
 				Name:    "verbose",
 				Aliases: []string{"v"},
@@ -62,20 +299,67 @@ func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 				},
 			},
 		},
+		Before: func(_ context.Context, cmd *cli.Command) error {
+			path := cmd.String("log-file")
+			if path == "" {
+				return nil
+			}
+
+			err := output.open(
+				path,
+				cmd.Int("log-file-max-size")*1024*1024, //nolint:mnd
+				int(cmd.Int("log-file-max-backups")),
+			)
+			if err != nil {
+				return fmt.Errorf("[in run] open log file failed: %w", err)
+			}
+
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "api",
 				Usage: "Run local API and invoke lambda with requests",
 				Flags: []cli.Flag{
+					protocolFlag(),
 					&cli.StringFlag{
 						Name:    "port",
 						Aliases: []string{"p"},
 						Value:   "8080",
-						Usage:   "Port for local API Gateway. Must be a string of four digits .",
+						Usage: "Port for local API Gateway, from 1 to 65535. 0 picks a free port, which is logged at " +
+							"startup.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if err := validatePort(v); err != nil {
+								return fmt.Errorf("expected a port from 1 to 65535, or 0 for a free port. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name: "listen",
+						Usage: "Serve the local API Gateway on the unix domain socket at unix:`PATH`, or on a " +
+							"HOST:PORT, instead of --host and --port.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if path, ok := strings.CutPrefix(v, unixSocketPrefix); ok && path != "" {
+								return nil
+							}
+
+							if err := validateAddress(v); err != nil || strings.HasPrefix(v, unixSocketPrefix) {
+								return fmt.Errorf("expected unix:PATH or HOST:PORT. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:  "host",
+						Value: "localhost",
+						Usage: "`HOST` the local API Gateway listens on, a host name or an IPv4 or IPv6 address. " +
+							"0.0.0.0 or :: serve it on all interfaces, for other containers or devices on the LAN.",
 						Action: func(_ context.Context, _ *cli.Command, v string) error {
-							re := regexp.MustCompile(`^\d{4}$`)
-							if re.MatchString(v) {
-								return fmt.Errorf("expected 4 consecutive digets. Got %v", v)
+							if _, err := parseHost(v); err != nil {
+								return fmt.Errorf("expected a host name or IP address without port. Got %v", v)
 							}
 
 							return nil
@@ -85,7 +369,8 @@ func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 						Name:    "template",
 						Aliases: []string{"t"},
 						Value:   "./template.yaml",
-						Usage:   "Path to AWS SAM template.yaml.",
+						Usage: "Path to AWS SAM template.yaml, or to the output of `terraform show -json` for a " +
+							"Terraform plan or state.",
 						Action: func(_ context.Context, _ *cli.Command, v string) error {
 							_, err := os.Stat(v)
 							if os.IsNotExist(err) {
@@ -95,124 +380,2457 @@ func run(ctx context.Context, w io.Writer) error { //nolint:funlen,cyclop
 							return nil
 						},
 					},
-				},
-				Action: func(ctx context.Context, cmd *cli.Command) error {
-					// get flags
-					executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
-					lambdaAddress := cmd.String("address")
-					port := cmd.String("port")
-					template := cmd.String("template")
-					parseJSON := cmd.Bool("parse-json")
-
-					logger := slog.New(
-						tint.NewHandler(
-							w, &tint.Options{
-								Level:      logLevel,
-								TimeFormat: "15:04:05.000",
-							},
-						),
-					)
-
-					// create lambda client
-					lambdaRPC := NewLambdaLambdaRPCClient(lambdaAddress, executionLimit)
-
-					// run local API gateway
-					if err := RunLambdaAPI(ctx, w, lambdaRPC, port, template, parseJSON, logger); err != nil {
-						return fmt.Errorf("[in run.api] RunLambdaAPI failed: %w", err)
-					}
-
-					return nil
-				},
-			},
-			{
-				Name:  "event",
-				Usage: "Invoke lambda with JSON event",
-				Flags: []cli.Flag{
+					&cli.StringMapFlag{
+						Name: "function-address",
+						Usage: "`FUNCTION=ADDRESS` sending routes of the function with this logical ID to the lambda " +
+							"at ADDRESS instead of --address. Can be repeated.",
+					},
 					&cli.StringFlag{
-						Name:    "file",
-						Aliases: []string{"f"},
-						Usage:   "Load event from `FILE_PATH`.",
+						Name: "payload-format",
+						Usage: fmt.Sprintf(
+							"Event payload format sent to the lambda, '%s' (REST API) or '%s' (HTTP API). "+
+								"Defaults to '%s' for HttpApi events and '%s' otherwise.",
+							payloadFormatV1,
+							payloadFormatV2,
+							payloadFormatV2,
+							payloadFormatV1,
+						),
 						Action: func(_ context.Context, _ *cli.Command, v string) error {
-							_, err := os.Stat(v)
-							if os.IsNotExist(err) {
-								return fmt.Errorf("event file '%v' does not exist", v)
+							if v != payloadFormatV1 && v != payloadFormatV2 {
+								return fmt.Errorf(
+									"payload format must be '%s' or '%s'. Got %v",
+									payloadFormatV1,
+									payloadFormatV2,
+									v,
+								)
 							}
 
 							return nil
 						},
 					},
-					&cli.StringFlag{
-						Name:    "string",
-						Aliases: []string{"e"},
-						Usage:   "Lambda event as a `STRING` to invoke.",
+					&cli.DurationFlag{
+						Name:  "read-timeout",
+						Usage: "Maximum duration for reading an entire request, including the body. 0 means no limit.",
 					},
-				},
-				Before: func(_ context.Context, cmd *cli.Command) error {
-					filePath := cmd.String("file")
-					event := cmd.String("string")
+					&cli.DurationFlag{
+						Name: "write-timeout",
+						Usage: "Maximum duration before timing out writes of the response, including the lambda " +
+							"invocation. 0 means no limit.",
+					},
+					&cli.DurationFlag{
+						Name: "idle-timeout",
+						Usage: "Maximum duration to wait for the next request on a keep-alive connection. 0 uses " +
+							"--read-timeout.",
+					},
+					&cli.IntFlag{
+						Name:  "max-header-bytes",
+						Value: http.DefaultMaxHeaderBytes,
+						Usage: "Maximum size of request headers in bytes.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive number of bytes. Got %v", v)
+							}
 
-					// validate that both event and file-event not set
-					if filePath != "" && event != "" {
-						return errors.New("'file-event' and 'event' are mutually exclusive")
-					}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Value: workerPoolDefaultWorkers,
+						Usage: "Number of lambda invocations of the routes run at once.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive number of workers. Got %v", v)
+							}
 
-					// if file path, read file to string
-					if filePath != "" {
-						file, err := os.Open(filePath)
-						if err != nil {
-							return fmt.Errorf("[in run.event] failed to open event file: %w", err)
-						}
-						defer func() {
-							_ = file.Close()
-						}()
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "queue-size",
+						Value: workerPoolDefaultQueueSize,
+						Usage: "Number of invocations waiting for a worker before requests are answered with a 429. " +
+							"0 rejects every request while all workers are busy.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more queued invocations. Got %v", v)
+							}
 
-						bytes, err := io.ReadAll(file)
-						if err != nil {
-							return fmt.Errorf("[in run.event] failed to read event file: %w", err)
-						}
+							return nil
+						},
+					},
+					&cli.DurationFlag{
+						Name:  "queue-timeout",
+						Value: workerPoolDefaultQueueTimeout,
+						Usage: "Maximum duration an invocation waits for a worker before its request is answered " +
+							"with a 429. 0 means no limit.",
+					},
+					&cli.StringFlag{
+						Name: "inject-latency",
+						Usage: "Add a random latency in `RANGE`, like 200ms..2s, or a fixed one like 500ms, before " +
+							"invoking the lambda of every request.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							_, _, err := parseLatencyRange(v)
 
-						if err = cmd.Set("string", string(bytes)); err != nil {
-							return fmt.Errorf("[in run.event] failed to set value for key 'string': %w", err)
-						}
+							return err
+						},
+					},
+					&cli.FloatFlag{
+						Name: "inject-error-rate",
+						Usage: "Answer the `RATE` of requests, between 0 and 1, with a 502 instead of invoking the " +
+							"lambda, like a failing lambda.",
+						Action: func(_ context.Context, _ *cli.Command, v float64) error {
+							if v < 0 || v > 1 {
+								return fmt.Errorf("expected a rate between 0 and 1. Got %v", v)
+							}
 
-						return nil
-					}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name: "reserved-concurrency",
+						Usage: "Maximum number of invocations of each function running at once, further invocations " +
+							"are throttled with a 429 like Lambda does. 0 means no limit.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more invocations. Got %v", v)
+							}
 
-					return nil
-				},
-				Action: func(ctx context.Context, cmd *cli.Command) error {
-					// get flags
-					executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
-					lambdaAddress := cmd.String("address")
-					event := cmd.String("string")
-					parseJSON := cmd.Bool("parse-json")
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name: "output-dir",
+						Usage: "Write the raw payload returned by the lambda for every request to `DIRECTORY`, in a " +
+							"file named by its request id.",
+					},
+					&cli.StringSliceFlag{
+						Name: "binary-media-types",
+						Usage: "Add `MEDIA_TYPE`s, like image/png or application/*, to the BinaryMediaTypes of every " +
+							"route. Request bodies of these types, and compressed bodies, are base64 encoded in the " +
+							"event.",
+					},
+					&cli.StringFlag{
+						Name:  "identity-source-ip",
+						Usage: "Source `IP` of the caller in the events, in place of the address of the client.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if net.ParseIP(v) == nil {
+								return fmt.Errorf("identity source ip must be an IP address. Got %v", v)
+							}
 
-					logger := slog.New(
-						tint.NewHandler(
-							w, &tint.Options{
-								Level:      logLevel,
-								TimeFormat: "15:04:05.000",
-							},
-						),
-					)
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:  "identity-user-agent",
+						Usage: "`USER_AGENT` of the caller in the events, in place of the one of the client.",
+					},
+					&cli.StringFlag{
+						Name: "identity-user-arn",
+						Usage: "`ARN` of the IAM user or role calling the API, set as " +
+							"requestContext.identity.userArn of 1.0 events and requestContext.authorizer.iam.userArn " +
+							"of 2.0 events.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if !strings.HasPrefix(v, "arn:") {
+								return fmt.Errorf("identity user arn must be an ARN. Got %v", v)
+							}
 
-					// create lambda client
-					lambdaRPC := NewLambdaLambdaRPCClient(lambdaAddress, executionLimit)
+							return nil
+						},
+					},
+					&cli.BoolFlag{
+						Name: "raw-passthrough",
+						Usage: "Return the body of the lambda's responses byte for byte, only the statusCode, " +
+							"headers and cookies of the payload are decoded.",
+					},
+					&cli.BoolFlag{
+						Name: "warmup",
+						Usage: "Invoke every route once on startup, with a GET request or the function's warmupEvent " +
+							"from the config, so the first real request isn't slowed by initialization.",
+					},
+					&cli.IntFlag{
+						Name:  "history-size",
+						Value: defaultHistorySize,
+						Usage: "Number of invocations, with their payloads, kept for the control API at " +
+							controlAPIPrefix + "/invocations. 0 keeps none.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more invocations. Got %v", v)
+							}
 
-					// invoke lambda with event
-					if err := RunLambdaEvent(ctx, w, lambdaRPC, event, parseJSON, logger); err != nil {
-						return fmt.Errorf("[in run.event] RunLambdaEvent failed: %w", err)
-					}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "history-max-size",
+						Value: defaultHistoryMaxSize,
+						Usage: "Size in `MB` of the invocations kept for the control API with their payloads, the " +
+							"oldest are dropped beyond it. Payloads of larger invocations aren't kept.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive size. Got %v", v)
+							}
 
-					return nil
-				},
-			},
-		},
-	}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "max-request-size",
+						Value: apiGatewayMaxRequestSize,
+						Usage: "Maximum size in `BYTES` of request bodies, larger requests are answered with 413 " +
+							"like API Gateway does. 0 accepts any size.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more bytes. Got %v", v)
+							}
 
-	if err := cmd.Run(ctx, os.Args); err != nil {
-		return fmt.Errorf("[in run] Run failed: %w", err)
-	}
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "max-payload-size",
+						Value: lambdaMaxPayloadSize,
+						Usage: "Maximum size in `BYTES` of the events and Lambda API payloads the lambda is invoked " +
+							"with, larger ones are answered with 413 like Lambda rejects them. 0 accepts any size.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more bytes. Got %v", v)
+							}
 
-	return nil
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name: "tls-cert",
+						Usage: "Serve the local API Gateway over https with the PEM certificate in `FILE`, with " +
+							"--tls-key.",
+					},
+					&cli.StringFlag{
+						Name:  "tls-key",
+						Usage: "PEM private key in `FILE` of the certificate of --tls-cert.",
+					},
+					&cli.BoolFlag{
+						Name: "tls-self-signed",
+						Usage: "Serve the local API Gateway over https with a throwaway self-signed certificate for " +
+							"localhost and --host, generated on startup.",
+					},
+					&cli.BoolFlag{
+						Name: "disable-keepalive",
+						Usage: "Close every connection after its response, like clients that open a fresh connection " +
+							"per request.",
+					},
+					&cli.BoolFlag{
+						Name: "record",
+						Usage: "Record the invocations kept for the control API in the --store, " + defaultStoreDir +
+							" without one, so they outlive the process and are listed again on the next start.",
+					},
+					&cli.StringSliceFlag{
+						Name: "record-encrypt",
+						Usage: "Encrypt the recordings of --record with age for `age:RECIPIENT`, an age X25519 " +
+							"public key. Repeat it for several recipients.",
+						Action: func(_ context.Context, _ *cli.Command, v []string) error {
+							for _, value := range v {
+								if _, err := parseAgeRecipient(value); err != nil {
+									return fmt.Errorf("expected age:RECIPIENT. Got %v", value)
+								}
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name: "record-identity",
+						Usage: "age identity `FILE` that decrypts the recordings of earlier runs encrypted with " +
+							"--record-encrypt, so they are listed again.",
+					},
+					&cli.StringFlag{
+						Name: "jwt-issuer",
+						Usage: "Issuer expected by the JWT authorizers of HttpApi routes, overriding the template's " +
+							"JwtConfiguration. Signing keys are read from its OpenID configuration.",
+					},
+					&cli.StringSliceFlag{
+						Name: "jwt-audience",
+						Usage: "Audience accepted by the JWT authorizers, overriding the template's " +
+							"JwtConfiguration. Can be repeated.",
+					},
+					&cli.BoolFlag{
+						Name: "jwt-insecure-decode",
+						Usage: "Skip the signature check of JWTs so hand-made tokens can be used. Claims are still " +
+							"checked.",
+					},
+					&cli.StringFlag{
+						Name:  "invocation-type",
+						Value: invocationTypeRequestResponse,
+						Usage: fmt.Sprintf(
+							"'%s' answers requests with the lambda's response, '%s' answers them with a 202 right "+
+								"away and invokes the lambda asynchronously, retrying failed invocations.",
+							invocationTypeRequestResponse,
+							invocationTypeEvent,
+						),
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if v != invocationTypeRequestResponse && v != invocationTypeEvent {
+								return fmt.Errorf(
+									"invocation type must be '%s' or '%s'. Got %v",
+									invocationTypeRequestResponse,
+									invocationTypeEvent,
+									v,
+								)
+							}
+
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "retry-attempts",
+						Value: asyncDefaultRetries,
+						Usage: "How often failed asynchronous invocations are retried.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v < 0 {
+								return fmt.Errorf("expected zero or more retries. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.DurationFlag{
+						Name:  "retry-backoff",
+						Value: asyncDefaultBackoff,
+						Usage: "Delay before the first retry of a failed asynchronous invocation, doubled for every " +
+							"further retry.",
+					},
+					&cli.StringFlag{
+						Name: "dlq",
+						Usage: "Dead-letter queue of asynchronous invocations that failed every attempt, a " +
+							"`DIRECTORY` or the URL of an SQS queue.",
+					},
+					&cli.StringFlag{
+						Name: "grpc-descriptor",
+						Usage: "Experimental: accept gRPC requests of the services in the FileDescriptorSet at " +
+							"`FILE_PATH`, transcoded into requests of the routes like a gRPC-JSON transcoding proxy.",
+					},
+					&cli.StringFlag{
+						Name:  "default-error-content-type",
+						Value: errorContentTypeJSON,
+						Usage: "Content type of lambdalocal's own errors for clients whose Accept header takes any, " +
+							"one of " + strings.Join(errorContentTypes, ", ") + ".",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if !slices.Contains(errorContentTypes, v) {
+								return fmt.Errorf(
+									"default error content type must be one of %s. Got %v",
+									strings.Join(errorContentTypes, ", "),
+									v,
+								)
+							}
+
+							return nil
+						},
+					},
+					&cli.BoolFlag{
+						Name: "takeover",
+						Usage: "Shut down the lambdalocal instance of the project that serves the same port, through " +
+							"its control API, instead of failing.",
+					},
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Rebuild and restart the lambda started with --run when its sources change.",
+					},
+					&cli.StringFlag{
+						Name:  "watch-dir",
+						Value: ".",
+						Usage: "Source `DIRECTORY` watched with --watch.",
+					},
+					&cli.StringSliceFlag{
+						Name:  "watch-pattern",
+						Value: []string{"*.go", "go.mod", "go.sum"},
+						Usage: "File name `PATTERN` that triggers a rebuild with --watch. Can be repeated.",
+					},
+					&cli.StringFlag{
+						Name: "build",
+						Usage: "Shell `COMMAND` run before restarting the lambda with --watch, e.g. " +
+							"\"go build -o bin/fn ./cmd/fn\".",
+					},
+					&cli.IntFlag{
+						Name:  "processes",
+						Value: 1,
+						Usage: "Number of warm processes of the lambda started with --run, invocations go to the " +
+							"least busy one. Reproduces concurrency bugs like shared state and init races.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive number of processes. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name: "cold-start",
+						Usage: "Restart the lambda started with --run before `always` or a `percent=N` of the " +
+							"invocations, reporting the Init Duration of the cold starts.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							_, err := parseColdStart(v)
+
+							return err
+						},
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					// get flags
+					executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
+					lambdaAddress := cmd.String("address")
+					host, _ := parseHost(cmd.String("host"))
+					listenAddr := cmp.Or(cmd.String("listen"), net.JoinHostPort(host, cmd.String("port")))
+					template := cmd.String("template")
+					format := newResponseFormat(cmd)
+
+					logger := slog.New(
+						tint.NewHandler(
+							w, &tint.Options{
+								Level:      logLevel,
+								TimeFormat: "15:04:05.000",
+							},
+						),
+					)
+
+					config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+					if err != nil {
+						return fmt.Errorf("[in run.api] loadProjectConfig failed: %w", err)
+					}
+
+					functionAddresses := config.functionAddresses()
+					for function, address := range cmd.StringMap("function-address") {
+						functionAddresses[function] = address
+					}
+
+					// the invocations kept for the control API are recorded in the store with --record
+					var recordings store
+					if cmd.Bool("record") {
+						recordings, err = openStore(cmp.Or(config.store(cmd.String("store")), defaultStoreDir))
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+
+						if len(cmd.StringSlice("record-encrypt")) > 0 {
+							recordings, err = newAgeStore(
+								recordings,
+								cmd.StringSlice("record-encrypt"),
+								cmd.String("record-identity"),
+							)
+							if err != nil {
+								return fmt.Errorf("[in run.api] %w", err)
+							}
+						}
+					}
+
+					parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+					if err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+
+					var warmupEvents map[string]string
+					if cmd.Bool("warmup") {
+						warmupEvents, err = config.warmupEvents(cmd.String("config"), osFileReader{})
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+					}
+
+					// the policy was validated by its flag
+					var coldStart coldStartPolicy
+					if value := cmd.String("cold-start"); value != "" {
+						if coldStart, err = parseColdStart(value); err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+					}
+
+					// validate the combined config and flags before starting anything
+					runSettings := settings{
+						protocol:          cmd.String("protocol"),
+						address:           lambdaAddress,
+						executionLimit:    executionLimit,
+						run:               cmd.String("run"),
+						functionAddresses: functionAddresses,
+						api: &apiSettings{
+							watch:          cmd.Bool("watch"),
+							watchDir:       cmd.String("watch-dir"),
+							build:          cmd.String("build"),
+							recordEncrypt:  len(cmd.StringSlice("record-encrypt")) > 0,
+							recordIdentity: cmd.String("record-identity"),
+							coldStart:      coldStart,
+							processes:      int(cmd.Int("processes")),
+							server: serverConfig{
+								readTimeout:      cmd.Duration("read-timeout"),
+								writeTimeout:     cmd.Duration("write-timeout"),
+								idleTimeout:      cmd.Duration("idle-timeout"),
+								maxHeaderBytes:   int(cmd.Int("max-header-bytes")),
+								disableKeepAlive: cmd.Bool("disable-keepalive"),
+								recordings:       recordings,
+								latencyBudgets:   config.LatencyBudgets,
+								warmup:           cmd.Bool("warmup"),
+								warmupEvents:     warmupEvents,
+								rawPassthrough:   cmd.Bool("raw-passthrough"),
+								historySize:      int(cmd.Int("history-size")),
+								historyMaxBytes:  cmd.Int("history-max-size") * 1024 * 1024, //nolint:mnd
+								limits: requestLimits{
+									request: cmd.Int("max-request-size"),
+									payload: cmd.Int("max-payload-size"),
+								},
+								binaryMediaTypes: cmd.StringSlice("binary-media-types"),
+								tls: tlsConfig{
+									certFile:   cmd.String("tls-cert"),
+									keyFile:    cmd.String("tls-key"),
+									selfSigned: cmd.Bool("tls-self-signed"),
+								},
+								identity: callerIdentity{
+									sourceIP:  cmd.String("identity-source-ip"),
+									userAgent: cmd.String("identity-user-agent"),
+									userARN:   cmd.String("identity-user-arn"),
+								},
+							},
+							jwt: jwtConfig{
+								issuer:         cmd.String("jwt-issuer"),
+								audience:       cmd.StringSlice("jwt-audience"),
+								insecureDecode: cmd.Bool("jwt-insecure-decode"),
+							},
+						},
+					}
+
+					if err = runSettings.validate(); err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+
+					// recordings beyond the retention are deleted once the settings are valid
+					if recordings != nil {
+						pruned, err := newRecordRetention(cmd).prune(recordings, time.Now())
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+
+						if pruned > 0 {
+							logger.Info("Deleted recordings beyond the retention", "count", pruned)
+						}
+					}
+
+					// register in the lock file of the project, taking over the port with --takeover. A free
+					// port picked by the system can't conflict with other instances, and unix domain sockets
+					// are checked when they are listened on
+					instance := newLambdalocalInstance(reachableAddress(listenAddr), template)
+					instance.TLS = runSettings.api.server.tls.enabled()
+
+					network, address := listenNetwork(listenAddr)
+					_, port, _ := net.SplitHostPort(address)
+
+					if n, _ := strconv.Atoi(port); network == "tcp" && n != 0 {
+						unregister, err := newInstanceRegistry(cmd.String("config"), logger).
+							register(ctx, instance, cmd.Bool("takeover"))
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+						defer unregister()
+					}
+
+					runSettings.api.server.controlToken = instance.Token
+					runSettings.api.server.errorContentType = cmd.String("default-error-content-type")
+
+					// create lambda client
+					lambdaRPC, closeLambda, err := newLambdaCaller(
+						runSettings.protocol,
+						lambdaAddress,
+						executionLimit,
+						logger,
+						lambdaOptions(cmd, w)...,
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.api] newLambdaCaller failed: %w", err)
+					}
+					defer closeLambda()
+
+					// create a lambda client for each function with its own address
+					functionCallers, closeFunctions, err := newFunctionCallers(
+						runSettings.protocol,
+						functionAddresses,
+						executionLimit,
+						logger,
+						lambdaOptions(cmd, w)...,
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.api] newFunctionCallers failed: %w", err)
+					}
+					defer closeFunctions()
+
+					// the lambda process gets the environment variables of the template, like when deployed
+					lambdaEnv, err := managedLambdaEnv(cmd, "", logger)
+					if err != nil {
+						return fmt.Errorf("[in run.api] managedLambdaEnv failed: %w", err)
+					}
+
+					// start lambda process when managed by lambdalocal, restarting it on changes when watching
+					// and before cold starts
+					if cmd.Bool("watch") || coldStart.enabled() {
+						watcher := newLambdaWatcher(
+							lambdaRPC,
+							cmd.String("watch-dir"),
+							cmd.StringSlice("watch-pattern"),
+							cmd.String("build"),
+							cmd.String("run"),
+							lambdaAddress,
+							runSettings.protocol,
+							logger,
+						)
+						watcher.env = lambdaEnv
+						watcher.coldStart = coldStart
+
+						if err = watcher.Start(ctx); err != nil {
+							return fmt.Errorf("[in run.api] watcher.Start failed: %w", err)
+						}
+						defer watcher.Stop()
+
+						watchCtx, cancelWatch := context.WithCancel(ctx)
+						defer cancelWatch()
+
+						if cmd.Bool("watch") {
+							go func() {
+								if err := watcher.Watch(watchCtx); err != nil {
+									logger.Error("[in run.api] watcher.Watch failed", "err", err)
+								}
+							}()
+						}
+
+						lambdaRPC = watcher
+					} else if processes := runSettings.api.processes; processes > 1 {
+						// several warm processes take the invocations, like provisioned concurrency
+						pool, err := startProcessPool(
+							ctx,
+							cmd.String("run"),
+							lambdaAddress,
+							runSettings.protocol,
+							processes,
+							lambdaEnv,
+							logger,
+						)
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+						defer pool.Stop()
+
+						// the processes of the Runtime API take the invocations as they poll it
+						if runSettings.protocol == ProtocolRPC {
+							callers := make([]lambdaCaller, 0, len(pool.addresses))
+							for _, address := range pool.addresses {
+								callers = append(
+									callers,
+									NewLambdaLambdaRPCClient(address, executionLimit, lambdaOptions(cmd, w)...),
+								)
+							}
+
+							lambdaRPC = newBalancedCaller(callers)
+						}
+					} else {
+						stopLambda, err := startManagedLambda(
+							ctx,
+							cmd.String("run"),
+							lambdaAddress,
+							runSettings.protocol,
+							lambdaEnv,
+							logger,
+						)
+						if err != nil {
+							return fmt.Errorf("[in run.api] startManagedLambda failed: %w", err)
+						}
+						defer stopLambda()
+					}
+
+					// the lambda may be started after lambdalocal, wait for it before serving
+					if wait := cmd.Duration("wait"); wait > 0 && runSettings.protocol == ProtocolRPC &&
+						runSettings.api.processes <= 1 {
+						if err = waitForLambda(ctx, lambdaAddress, wait, logger); err != nil {
+							logger.Warn("[in run.api] lambda isn't ready, serving anyway", "err", err)
+						}
+					}
+
+					// pass events and responses through the WASM plugins
+					plugins, err := loadPlugins(
+						ctx,
+						config.pluginPaths(cmd.String("config"), cmd.StringSlice("plugin")),
+						osFileReader{},
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+					defer plugins.Close(ctx)
+
+					lambdaRPC = plugins.caller(lambdaRPC)
+					for function, caller := range functionCallers {
+						functionCallers[function] = plugins.caller(caller)
+					}
+
+					// answer the mock routes of the config with their templates
+					if runSettings.api.server.mocks, err = parseMocks(config.Mocks); err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+
+					// send requests matching the routing rules of the config to their targets
+					if runSettings.api.server.routing, err = parseRouting(config.Routing, config.Sticky); err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+
+					// pass invocations of every route through the hook scripts
+					runSettings.api.server.hooks, err = loadHooks(
+						config.hookPaths(cmd.String("config")),
+						osFileReader{},
+						logger,
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.api] %w", err)
+					}
+
+					// transcode gRPC requests when descriptors are set
+					if descriptor := cmd.String("grpc-descriptor"); descriptor != "" {
+						runSettings.api.server.grpc, err = loadGRPCTranscoder(descriptor, osFileReader{})
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+					}
+
+					// write the payload of every invocation when asked to
+					if outputDir := cmd.String("output-dir"); outputDir != "" {
+						if runSettings.api.server.payloads, err = newPayloadDump(outputDir, logger); err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+					}
+
+					// run the invocations of the routes on a bounded pool of workers, closed after the
+					// asynchronous invocations finished
+					runSettings.api.server.workers = newWorkerPool(
+						int(cmd.Int("workers")),
+						int(cmd.Int("queue-size")),
+						cmd.Duration("queue-timeout"),
+						logger,
+					)
+					defer runSettings.api.server.workers.Close()
+
+					// throttle the invocations of functions beyond their reserved concurrency
+					if limit := cmd.Int("reserved-concurrency"); limit > 0 {
+						runSettings.api.server.concurrency = newReservedConcurrency(int(limit), logger)
+					}
+
+					// slow down and fail requests on purpose, the latency was validated by its flag
+					runSettings.api.server.faults.errorRate = cmd.Float("inject-error-rate")
+					if latency := cmd.String("inject-latency"); latency != "" {
+						faults := &runSettings.api.server.faults
+						if faults.minLatency, faults.maxLatency, err = parseLatencyRange(latency); err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+					}
+
+					// invoke the lambdas asynchronously with the Event invocation type
+					if cmd.String("invocation-type") == invocationTypeEvent {
+						var dlq deadLetterQueue
+						if target := cmd.String("dlq"); target != "" {
+							if dlq, err = newDeadLetterQueue(target, os.LookupEnv); err != nil {
+								return fmt.Errorf("[in run.api] %w", err)
+							}
+						}
+
+						runSettings.api.server.async = newAsyncInvoker(
+							asyncPolicy{
+								retries: int(cmd.Int("retry-attempts")),
+								backoff: cmd.Duration("retry-backoff"),
+							},
+							dlq,
+							logger,
+						)
+						defer runSettings.api.server.async.Wait()
+					}
+
+					// record usage stats when opted in
+					var stats *statsRecorder
+					if statsFile := config.statsFile(cmd.String("stats-file")); statsFile != "" {
+						statsStore, key, err := storeFor(config.store(cmd.String("store")), statsFile)
+						if err != nil {
+							return fmt.Errorf("[in run.api] %w", err)
+						}
+
+						stats = newStatsRecorder(statsStore, key, logger)
+					}
+
+					// run local API gateway
+					if err = RunLambdaAPI(
+						ctx,
+						w,
+						lambdaRPC,
+						functionCallers,
+						listenAddr,
+						template,
+						cmd.String("payload-format"),
+						parameterOverrides,
+						runSettings.api.jwt,
+						runSettings.api.server,
+						stats,
+						format,
+						logger,
+					); err != nil {
+						return fmt.Errorf("[in run.api] RunLambdaAPI failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "event",
+				Usage: "Invoke lambda with JSON event",
+				Flags: []cli.Flag{
+					protocolFlag(),
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "Load event from `FILE_PATH`, or from stdin with -.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if v == stdinEventFile {
+								return nil
+							}
+
+							_, err := os.Stat(v)
+							if os.IsNotExist(err) {
+								return fmt.Errorf("event file '%v' does not exist", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:    "string",
+						Aliases: []string{"e"},
+						Usage:   "Lambda event as a `STRING` to invoke.",
+					},
+					&cli.StringFlag{
+						Name: "function",
+						Usage: "Logical ID of the function in the template. Without --file or --string its default " +
+							"event is used. Without --address the address is taken from the function's entry in the " +
+							"config.",
+					},
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Value:   "./template.yaml",
+						Usage:   "Path to AWS SAM template.yaml, used with --function.",
+					},
+					&cli.StringFlag{
+						Name: "output-file",
+						Usage: "Write the raw payload returned by the lambda to `FILE_PATH`, the error for failed " +
+							"invocations.",
+					},
+					&cli.StringFlag{
+						Name: "expect",
+						Usage: "Compare the payload returned by the lambda with the golden JSON file at `FILE_PATH`, " +
+							"exiting with a non-zero code and a diff when they differ.",
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							_, err := os.Stat(v)
+							if os.IsNotExist(err) {
+								return fmt.Errorf("golden file '%v' does not exist", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringSliceFlag{
+						Name: "expect-ignore",
+						Usage: "Dot separated `PATH` of a field left out of the --expect comparison, like " +
+							"body.createdAt. * matches every key or array element. Can be repeated.",
+					},
+					&cli.BoolFlag{
+						Name: "fail-on-error",
+						Usage: "Exit with a non-zero code when the handler returns an error, or an API response with " +
+							"a status code outside of 2xx.",
+					},
+					&cli.IntFlag{
+						Name:  "repeat",
+						Value: 1,
+						Usage: "Invoke the lambda `N` times and print a summary of the latencies and errors instead " +
+							"of the responses.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive number of invocations. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Value: 1,
+						Usage: "Number of invocations of --repeat run at once.",
+						Action: func(_ context.Context, _ *cli.Command, v int64) error {
+							if v <= 0 {
+								return fmt.Errorf("expected a positive concurrency. Got %v", v)
+							}
+
+							return nil
+						},
+					},
+				},
+				Before: func(_ context.Context, cmd *cli.Command) error {
+					filePath := cmd.String("file")
+					event := cmd.String("string")
+					function := cmd.String("function")
+
+					// the responses of benchmark runs are summarized instead of kept
+					if cmd.Int("repeat") > 1 && (cmd.String("output-file") != "" || cmd.String("expect") != "") {
+						return errors.New("--output-file and --expect can't be used with --repeat")
+					}
+
+					// validate that both event and file-event not set
+					if filePath != "" && event != "" {
+						return errors.New("'file-event' and 'event' are mutually exclusive")
+					}
+
+					// without an event or function, an event piped to stdin is read like --file -
+					if filePath == "" && event == "" && function == "" && isPiped(os.Stdin) {
+						filePath = stdinEventFile
+					}
+
+					// if file path, read file to string
+					if filePath != "" {
+						fileEvent, err := readEvent(filePath, os.Stdin, osFileReader{})
+						if err != nil {
+							return fmt.Errorf("[in run.event] %w", err)
+						}
+
+						if err = cmd.Set("string", fileEvent); err != nil {
+							return fmt.Errorf("[in run.event] failed to set value for key 'string': %w", err)
+						}
+
+						return nil
+					}
+
+					// if only a function is given, use its default event
+					if event == "" && function != "" {
+						defaultEvent, err := resolveDefaultEvent(cmd.String("template"), function, osFileReader{})
+						if err != nil {
+							return fmt.Errorf("[in run.event] resolveDefaultEvent failed: %w", err)
+						}
+
+						if err = cmd.Set("string", defaultEvent); err != nil {
+							return fmt.Errorf("[in run.event] failed to set value for key 'string': %w", err)
+						}
+					}
+
+					return nil
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					// get flags
+					executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
+					lambdaAddress := cmd.String("address")
+					event := cmd.String("string")
+					format := newResponseFormat(cmd)
+
+					config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+					if err != nil {
+						return fmt.Errorf("[in run.event] loadProjectConfig failed: %w", err)
+					}
+
+					// resolve the address of the selected function from the project config
+					if function := cmd.String("function"); function != "" && !cmd.IsSet("address") {
+						lambdaAddress = config.functionAddress(function, lambdaAddress)
+					}
+
+					// validate the combined config and flags before starting anything
+					runSettings := settings{
+						protocol:          cmd.String("protocol"),
+						address:           lambdaAddress,
+						executionLimit:    executionLimit,
+						run:               cmd.String("run"),
+						functionAddresses: config.functionAddresses(),
+					}
+
+					if err = runSettings.validate(); err != nil {
+						return fmt.Errorf("[in run.event] %w", err)
+					}
+
+					logger := slog.New(
+						tint.NewHandler(
+							w, &tint.Options{
+								Level:      logLevel,
+								TimeFormat: "15:04:05.000",
+							},
+						),
+					)
+
+					options := lambdaOptions(cmd, w)
+
+					// the REPORT lines of a function show its memory size, or the default without a template
+					if function := cmd.String("function"); function != "" && cmd.Bool("report-lines") {
+						memorySizes, _ := functionMemorySizes(cmd.String("template"), osFileReader{}, nil)
+						options = append(options, WithMemorySize(memorySizes[function]))
+					}
+
+					// create lambda client
+					lambdaRPC, closeLambda, err := newLambdaCaller(
+						cmd.String("protocol"),
+						lambdaAddress,
+						executionLimit,
+						logger,
+						options...,
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.event] newLambdaCaller failed: %w", err)
+					}
+					defer closeLambda()
+
+					// pass events and responses through the WASM plugins
+					plugins, err := loadPlugins(
+						ctx,
+						config.pluginPaths(cmd.String("config"), cmd.StringSlice("plugin")),
+						osFileReader{},
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.event] %w", err)
+					}
+					defer plugins.Close(ctx)
+
+					lambdaRPC = plugins.caller(lambdaRPC)
+
+					// record usage stats when opted in, events count under the function they were sent to
+					if statsFile := config.statsFile(cmd.String("stats-file")); statsFile != "" {
+						statsStore, key, err := storeFor(config.store(cmd.String("store")), statsFile)
+						if err != nil {
+							return fmt.Errorf("[in run.event] %w", err)
+						}
+
+						route := strings.TrimSpace("event " + cmd.String("function"))
+						lambdaRPC = newStatsRecorder(statsStore, key, logger).caller(lambdaRPC, route)
+					}
+
+					// pass the invocation through the hook scripts
+					hooks, err := loadHooks(config.hookPaths(cmd.String("config")), osFileReader{}, logger)
+					if err != nil {
+						return fmt.Errorf("[in run.event] %w", err)
+					}
+
+					lambdaRPC = hooks.caller(lambdaRPC, "")
+
+					// compare the response with a golden file when asked to
+					var expectation *goldenFile
+					if expect := cmd.String("expect"); expect != "" {
+						expectation, err = loadGoldenFile(expect, cmd.StringSlice("expect-ignore"), osFileReader{})
+						if err != nil {
+							return fmt.Errorf("[in run.event] %w", err)
+						}
+					}
+
+					lambdaEnv, err := managedLambdaEnv(cmd, cmd.String("function"), logger)
+					if err != nil {
+						return fmt.Errorf("[in run.event] managedLambdaEnv failed: %w", err)
+					}
+
+					// start lambda process when managed by lambdalocal
+					stopLambda, err := startManagedLambda(
+						ctx,
+						cmd.String("run"),
+						lambdaAddress,
+						runSettings.protocol,
+						lambdaEnv,
+						logger,
+					)
+					if err != nil {
+						return fmt.Errorf("[in run.event] startManagedLambda failed: %w", err)
+					}
+					defer stopLambda()
+
+					// invoke lambda repeatedly and summarize the latencies
+					if repeat := int(cmd.Int("repeat")); repeat > 1 {
+						if err = RunLambdaBenchmark(
+							ctx,
+							w,
+							lambdaRPC,
+							event,
+							repeat,
+							int(cmd.Int("concurrency")),
+							cmd.Bool("fail-on-error"),
+							logger,
+						); err != nil {
+							return fmt.Errorf("[in run.event] RunLambdaBenchmark failed: %w", err)
+						}
+
+						return nil
+					}
+
+					// invoke lambda with event
+					if err = RunLambdaEvent(
+						ctx,
+						w,
+						lambdaRPC,
+						event,
+						format,
+						cmd.Bool("fail-on-error"),
+						cmd.String("output-file"),
+						expectation,
+						logger,
+					); err != nil {
+						return fmt.Errorf("[in run.event] RunLambdaEvent failed: %w", err)
+					}
+
+					return nil
+				},
+				Commands: []*cli.Command{
+					anonymizeCommand(w),
+				},
+			},
+			initCommand(w),
+			scaffoldCommand(w),
+			generateEventCommand(w),
+			doctorCommand(w),
+			statsCommand(w),
+			recordCommand(w),
+			collectionCommand(w),
+			requestCommand(w),
+			sqsCommand(w, &logLevel),
+			snsCommand(w, &logLevel),
+			dynamodbCommand(w, &logLevel),
+			scheduleCommand(w, &logLevel),
+			eventbridgeCommand(w, &logLevel),
+			workspaceCommand(w, &logLevel),
+		},
+	}
+
+	if err := cmd.Run(ctx, os.Args); err != nil {
+		return fmt.Errorf("[in run] Run failed: %w", err)
+	}
+
+	return nil
+}
+
+// newResponseFormat returns how payloads are printed, from the global --parse-json, --key-order,
+// redaction, --max-log-body and --preserve-numbers flags.
+func newResponseFormat(cmd *cli.Command) responseFormat {
+	return responseFormat{
+		parseJSON:    cmd.Bool("parse-json"),
+		sortKeys:     cmd.String("key-order") == keyOrderSorted,
+		redaction:    newRedaction(cmd.StringSlice("redact-headers"), cmd.StringSlice("redact-json-paths")),
+		maxLogBody:   int(cmd.Int("max-log-body")),
+		floatNumbers: !cmd.Bool("preserve-numbers"),
+	}
+}
+
+// qualifierRegex matches the versions and aliases of functions.
+var qualifierRegex = regexp.MustCompile(`^(\$LATEST|[a-zA-Z0-9-_]+)$`) //nolint:gochecknoglobals
+
+// managedLambdaEnv returns the environment variables of function in the template for the lambda
+// started with --run, those of every function it serves without one, followed by the variables of
+// the --env-file files. It returns nil without --run.
+func managedLambdaEnv(cmd *cli.Command, function string, logger *slog.Logger) ([]string, error) {
+	if cmd.String("run") == "" {
+		return nil, nil
+	}
+
+	overrides, _ := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+
+	var env []string
+
+	environments, err := functionEnvironments(cmd.String("template"), osFileReader{}, overrides)
+	if err != nil {
+		logger.Debug("Lambda process started without the environment variables of the template", "err", err)
+	} else {
+		env = processEnvironment(environments, function, logger)
+	}
+
+	// the variables of the env files come last, so they override the ones of the template
+	envFileVariables, err := loadEnvFiles(cmd.StringSlice("env-file"), osFileReader{})
+	if err != nil {
+		return nil, fmt.Errorf("loadEnvFiles failed: %w", err)
+	}
+
+	return append(env, environ(envFileVariables)...), nil
+}
+
+// lambdaOptions returns the options of the lambda callers set by the global flags. Report lines are
+// printed to w.
+func lambdaOptions(cmd *cli.Command, w io.Writer) []Option {
+	options := []Option{
+		WithClientContextCustom(cmd.StringMap("context-env")),
+		WithConnectWait(cmd.Duration("wait")),
+		WithFunctionARN(
+			qualifiedFunctionARN(cmd.String("function-arn"), cmd.String("function-name"), cmd.String("qualifier")),
+		),
+		WithTraceID(cmd.String("trace-id")),
+	}
+
+	if cmd.Bool("report-lines") {
+		options = append(options, WithReportLines(w))
+	}
+
+	return options
+}
+
+// eventSourceLambda is the lambda invoked by an event source command.
+type eventSourceLambda struct {
+	// caller invokes the lambda, passing events and responses through the plugins.
+	caller  lambdaCaller
+	plugins *plugins
+	// functionAddresses are the addresses of the functions in the config and --function-address.
+	functionAddresses map[string]string
+	// close stops the lambda started with --run and releases the caller and the plugins.
+	close func()
+}
+
+// startEventSourceLambda validates the global flags of an event source command and returns the
+// lambda at --address, or at the address of --function in the config, starting it with --run.
+func startEventSourceLambda(
+	ctx context.Context,
+	cmd *cli.Command,
+	w io.Writer,
+	logger *slog.Logger,
+) (eventSourceLambda, error) {
+	lambdaAddress := cmd.String("address")
+	executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
+
+	config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+	if err != nil {
+		return eventSourceLambda{}, fmt.Errorf("loadProjectConfig failed: %w", err)
+	}
+
+	// resolve the address of the selected function from the project config
+	if function := cmd.String("function"); function != "" && !cmd.IsSet("address") {
+		lambdaAddress = config.functionAddress(function, lambdaAddress)
+	}
+
+	// addresses of the flag override the ones of the config
+	functionAddresses := config.functionAddresses()
+	for function, address := range cmd.StringMap("function-address") {
+		functionAddresses[function] = address
+	}
+
+	runSettings := settings{
+		protocol:          cmd.String("protocol"),
+		address:           lambdaAddress,
+		executionLimit:    executionLimit,
+		run:               cmd.String("run"),
+		functionAddresses: functionAddresses,
+	}
+
+	if err = runSettings.validate(); err != nil {
+		return eventSourceLambda{}, err
+	}
+
+	lambdaRPC, closeLambda, err := newLambdaCaller(
+		runSettings.protocol,
+		lambdaAddress,
+		executionLimit,
+		logger,
+		lambdaOptions(cmd, w)...,
+	)
+	if err != nil {
+		return eventSourceLambda{}, fmt.Errorf("newLambdaCaller failed: %w", err)
+	}
+
+	// pass events and responses through the WASM plugins
+	plugins, err := loadPlugins(
+		ctx,
+		config.pluginPaths(cmd.String("config"), cmd.StringSlice("plugin")),
+		osFileReader{},
+	)
+	if err != nil {
+		closeLambda()
+
+		return eventSourceLambda{}, err
+	}
+
+	lambdaEnv, err := managedLambdaEnv(cmd, cmd.String("function"), logger)
+	if err != nil {
+		plugins.Close(ctx)
+		closeLambda()
+
+		return eventSourceLambda{}, fmt.Errorf("managedLambdaEnv failed: %w", err)
+	}
+
+	// start lambda process when managed by lambdalocal
+	stopLambda, err := startManagedLambda(
+		ctx,
+		cmd.String("run"),
+		lambdaAddress,
+		runSettings.protocol,
+		lambdaEnv,
+		logger,
+	)
+	if err != nil {
+		plugins.Close(ctx)
+		closeLambda()
+
+		return eventSourceLambda{}, fmt.Errorf("startManagedLambda failed: %w", err)
+	}
+
+	return eventSourceLambda{
+		caller:            plugins.caller(lambdaRPC),
+		plugins:           plugins,
+		functionAddresses: functionAddresses,
+		close: func() {
+			stopLambda()
+			plugins.Close(ctx)
+			closeLambda()
+		},
+	}, nil
+}
+
+// protocolFlag returns the flag selecting how the lambda is invoked. It is shared by the api and
+// event commands.
+func protocolFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:  "protocol",
+		Value: ProtocolRPC,
+		Usage: fmt.Sprintf(
+			"Protocol used to invoke the lambda, '%s' or '%s'. Use '%s' for handlers built with "+
+				"lambda.norpc or non-Go runtimes.",
+			ProtocolRPC,
+			ProtocolRuntimeAPI,
+			ProtocolRuntimeAPI,
+		),
+		Action: func(_ context.Context, _ *cli.Command, v string) error {
+			if v != ProtocolRPC && v != ProtocolRuntimeAPI {
+				return fmt.Errorf("protocol must be '%s' or '%s'. Got %v", ProtocolRPC, ProtocolRuntimeAPI, v)
+			}
+
+			return nil
+		},
+	}
+}
+
+// newRecordRetention returns the retention of the recordings, from the global --record-max-age,
+// --record-max-size and --record-max-count flags.
+func newRecordRetention(cmd *cli.Command) recordRetention {
+	return recordRetention{
+		maxAge:   cmd.Duration("record-max-age"),
+		maxBytes: cmd.Int("record-max-size") * 1024 * 1024, //nolint:mnd
+		maxCount: int(cmd.Int("record-max-count")),
+	}
+}
+
+// anonymizeCommand returns the `event anonymize` command.
+func anonymizeCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "anonymize",
+		Usage: "Rewrite sensitive fields of an event so it can be committed as a fixture",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "rules",
+				Aliases:  []string{"r"},
+				Required: true,
+				Usage:    "Load anonymization rules from `FILE_PATH`.",
+			},
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Required: true,
+				Usage:    "Load event from `FILE_PATH`.",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the anonymized event to `FILE_PATH` instead of stdout.",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			rules, err := parseAnonymizeRules(cmd.String("rules"), osFileReader{})
+			if err != nil {
+				return fmt.Errorf("[in run.anonymize] parseAnonymizeRules failed: %w", err)
+			}
+
+			event, err := os.ReadFile(cmd.String("file"))
+			if err != nil {
+				return fmt.Errorf("[in run.anonymize] failed to read event file: %w", err)
+			}
+
+			out := w
+
+			if outputPath := cmd.String("output"); outputPath != "" {
+				file, err := os.Create(outputPath)
+				if err != nil {
+					return fmt.Errorf("[in run.anonymize] failed to create output file: %w", err)
+				}
+				defer func() {
+					_ = file.Close()
+				}()
+
+				out = file
+			}
+
+			if err = RunAnonymizeEvent(out, string(event), rules); err != nil {
+				return fmt.Errorf("[in run.anonymize] RunAnonymizeEvent failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// initCommand returns the `init` command.
+func initCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "init",
+		Usage: "Write a starter lambdalocal.yaml and example events for the project",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "dir",
+				Value: ".",
+				Usage: "Root `DIRECTORY` of the project to inspect.",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Use the detected defaults without asking.",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Overwrite existing config and event files.",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := RunInit(os.Stdin, w, cmd.String("dir"), cmd.Bool("yes"), cmd.Bool("force")); err != nil {
+				return fmt.Errorf("[in run.init] RunInit failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// scaffoldCommand returns the `scaffold` command.
+func scaffoldCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:      "scaffold",
+		Usage:     "Write a runnable example project: handler, template.yaml, events and lambdalocal.yaml",
+		ArgsUsage: "api|sqs|stream",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "dir",
+				Value: ".",
+				Usage: "`DIRECTORY` to write the project to.",
+			},
+			&cli.StringFlag{
+				Name: "module",
+				Usage: "Module `PATH` of the go.mod written when the directory has none. Defaults to " +
+					"example.com/<dir>.",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Overwrite existing files.",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if cmd.Args().Len() != 1 {
+				return errors.New("[in run.scaffold] expected one kind: api, sqs or stream")
+			}
+
+			err := RunScaffold(w, cmd.Args().First(), cmd.String("dir"), cmd.String("module"), cmd.Bool("force"))
+			if err != nil {
+				return fmt.Errorf("[in run.scaffold] RunScaffold failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// generateEventCommand returns the `generate-event` command.
+func generateEventCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:      "generate-event",
+		Usage:     "Write a sample event of an AWS service, ready to edit and send with event",
+		ArgsUsage: "[SERVICE] [TYPE]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "bucket",
+				Value: "example-bucket",
+				Usage: "`BUCKET` of S3 events.",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Value: "test/key",
+				Usage: "Object `KEY` of S3 events.",
+			},
+			&cli.StringFlag{
+				Name:  "body",
+				Usage: "`BODY` of the event: the request body, message, record data or EventBridge detail.",
+			},
+			&cli.StringFlag{
+				Name:  "region",
+				Value: sqsDefaultRegion,
+				Usage: "AWS `REGION` of the event's ARNs.",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			fields := eventFields{
+				bucket: cmd.String("bucket"),
+				key:    cmd.String("key"),
+				body:   cmd.String("body"),
+				region: cmd.String("region"),
+				now:    time.Now().UTC().Truncate(time.Second),
+			}
+
+			if err := RunGenerateEvent(w, cmd.Args().Get(0), cmd.Args().Get(1), fields); err != nil {
+				return fmt.Errorf("[in run.generate-event] RunGenerateEvent failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// doctorCommand returns the `doctor` command.
+func doctorCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check the environment for common causes of failed invocations",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name:    "port",
+				Aliases: []string{"p"},
+				Value:   "8080",
+				Usage:   "Port of the local API Gateway checked for conflicts.",
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+			if err != nil {
+				return fmt.Errorf("[in run.doctor] loadProjectConfig failed: %w", err)
+			}
+
+			d := newDoctor(
+				cmd.String("protocol"),
+				cmd.String("address"),
+				config.functionAddresses(),
+				cmd.String("port"),
+				cmd.String("template"),
+			)
+
+			if err = RunDoctor(ctx, w, d); err != nil {
+				return fmt.Errorf("[in run.doctor] RunDoctor failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// statsCommand returns the `stats` command.
+func statsCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "stats",
+		Usage: "Show the local usage stats recorded with --stats-file",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "days",
+				Value: 7, //nolint:mnd
+				Usage: "Number of days up to today to show.",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v <= 0 {
+						return fmt.Errorf("expected a positive number of days. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml, its routes are listed even when they weren't invoked.",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+			if err != nil {
+				return fmt.Errorf("[in run.stats] loadProjectConfig failed: %w", err)
+			}
+
+			statsFile := config.statsFile(cmd.String("stats-file"))
+			if statsFile == "" {
+				return errors.New("[in run.stats] no stats are recorded, set --stats-file or statsFile in the config")
+			}
+
+			statsStore, key, err := storeFor(config.store(cmd.String("store")), statsFile)
+			if err != nil {
+				return fmt.Errorf("[in run.stats] %w", err)
+			}
+
+			stats, err := loadUsageStats(key, statsStore)
+			if err != nil {
+				return fmt.Errorf("[in run.stats] loadUsageStats failed: %w", err)
+			}
+
+			parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+			if err != nil {
+				return fmt.Errorf("[in run.stats] %w", err)
+			}
+
+			// the template is optional, without it only invoked routes are listed
+			var templateRoutes []string
+
+			if _, err = os.Stat(cmd.String("template")); err == nil {
+				routes, err := parseTemplate(cmd.String("template"), osFileReader{}, parameterOverrides)
+				if err != nil {
+					return fmt.Errorf("[in run.stats] parseTemplate failed: %w", err)
+				}
+
+				for _, route := range routes {
+					templateRoutes = append(templateRoutes, route.routeKey())
+				}
+			}
+
+			if err = RunStats(w, stats, templateRoutes, int(cmd.Int("days")), time.Now()); err != nil {
+				return fmt.Errorf("[in run.stats] RunStats failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// recordCommand returns the `record` command with its prune subcommand.
+func recordCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "record",
+		Usage: "Manage the recordings of api --record",
+		Commands: []*cli.Command{
+			{
+				Name: "prune",
+				Usage: "Delete the recordings beyond --record-max-age, --record-max-size and --record-max-count, " +
+					"like api --record does on start",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					retention := newRecordRetention(cmd)
+					if !retention.enabled() {
+						return errors.New(
+							"[in run.record.prune] no retention is set, set --record-max-age, --record-max-size " +
+								"or --record-max-count",
+						)
+					}
+
+					config, err := loadProjectConfig(cmd.String("config"), osFileReader{})
+					if err != nil {
+						return fmt.Errorf("[in run.record.prune] loadProjectConfig failed: %w", err)
+					}
+
+					recordings, err := openStore(cmp.Or(config.store(cmd.String("store")), defaultStoreDir))
+					if err != nil {
+						return fmt.Errorf("[in run.record.prune] %w", err)
+					}
+
+					if err = RunRecordPrune(w, recordings, retention, time.Now()); err != nil {
+						return fmt.Errorf("[in run.record.prune] RunRecordPrune failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// collectionCommand returns the `collection` command with its export and run subcommands.
+func collectionCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "collection",
+		Usage: "Export the routes as a Postman or Insomnia collection, or run the requests of one",
+		Commands: []*cli.Command{
+			{
+				Name:  "export",
+				Usage: "Write the routes of the template as a Postman collection or Insomnia export",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Value:   "./template.yaml",
+						Usage:   "Path to AWS SAM template.yaml.",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: collectionFormatPostman,
+						Usage: fmt.Sprintf(
+							"Collection format, '%s' or '%s'.",
+							collectionFormatPostman,
+							collectionFormatInsomnia,
+						),
+						Action: func(_ context.Context, _ *cli.Command, v string) error {
+							if v != collectionFormatPostman && v != collectionFormatInsomnia {
+								return fmt.Errorf(
+									"format must be '%s' or '%s'. Got %v",
+									collectionFormatPostman,
+									collectionFormatInsomnia,
+									v,
+								)
+							}
+
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "lambdalocal",
+						Usage: "Name of the collection.",
+					},
+					&cli.StringFlag{
+						Name:  "base-url",
+						Value: "http://localhost:8080",
+						Usage: "Value of the baseUrl variable the requests are sent to.",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write the collection to `FILE_PATH` instead of stdout.",
+					},
+				},
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					templatePath := cmd.String("template")
+
+					parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+					if err != nil {
+						return fmt.Errorf("[in run.collection] %w", err)
+					}
+
+					routes, err := parseTemplate(templatePath, osFileReader{}, parameterOverrides)
+					if err != nil {
+						return fmt.Errorf("[in run.collection] parseTemplate failed: %w", err)
+					}
+
+					// the bodies of the functions' default events are the example bodies
+					examples := make(map[string]string)
+
+					for _, route := range routes {
+						if _, ok := examples[route.function]; ok {
+							continue
+						}
+
+						event, err := resolveDefaultEvent(templatePath, route.function, osFileReader{})
+						if err != nil {
+							examples[route.function] = ""
+
+							continue
+						}
+
+						examples[route.function] = collectionExampleBody(event)
+					}
+
+					out := w
+
+					if outputPath := cmd.String("output"); outputPath != "" {
+						file, err := os.Create(outputPath)
+						if err != nil {
+							return fmt.Errorf("[in run.collection] failed to create output file: %w", err)
+						}
+						defer func() {
+							_ = file.Close()
+						}()
+
+						out = file
+					}
+
+					if err = RunCollectionExport(
+						out,
+						routes,
+						cmd.String("format"),
+						cmd.String("name"),
+						cmd.String("base-url"),
+						examples,
+					); err != nil {
+						return fmt.Errorf("[in run.collection] RunCollectionExport failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "run",
+				Usage: "Send the requests of a Postman collection or Insomnia export to the local API",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Required: true,
+						Usage:    "Load the collection from `FILE_PATH`.",
+					},
+					&cli.StringFlag{
+						Name:  "base-url",
+						Value: "http://localhost:8080",
+						Usage: "Address of the local API, replacing the baseUrl variable of the collection.",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					data, err := os.ReadFile(cmd.String("file"))
+					if err != nil {
+						return fmt.Errorf("[in run.collection] failed to read collection file: %w", err)
+					}
+
+					requests, err := parseCollection(data, cmd.String("base-url"))
+					if err != nil {
+						return fmt.Errorf("[in run.collection] parseCollection failed: %w", err)
+					}
+
+					client := &http.Client{Timeout: time.Duration(cmd.Int("executionLimit")) * time.Second}
+
+					if err = RunCollection(ctx, w, client, requests); err != nil {
+						return fmt.Errorf("[in run.collection] RunCollection failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// requestCommand returns the `request` command.
+func requestCommand(w io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "request",
+		Usage: "Send the requests of a .http or .rest file to the local API",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Required: true,
+				Usage:    "Load the requests from `FILE_PATH`, in the VS Code REST Client format.",
+			},
+			&cli.StringMapFlag{
+				Name:  "var",
+				Usage: "`KEY=VALUE` overriding the file variable KEY. Can be repeated.",
+			},
+			&cli.StringFlag{
+				Name:  "base-url",
+				Value: "http://localhost:8080",
+				Usage: "Address of the local API that URLs starting with / are sent to.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			dotenv, err := loadEnvFiles(cmd.StringSlice("env-file"), osFileReader{})
+			if err != nil {
+				return fmt.Errorf("[in run.request] loadEnvFiles failed: %w", err)
+			}
+
+			requests, err := parseHTTPFile(
+				cmd.String("file"),
+				osFileReader{},
+				cmd.StringMap("var"),
+				dotenv,
+				cmd.String("base-url"),
+			)
+			if err != nil {
+				return fmt.Errorf("[in run.request] parseHTTPFile failed: %w", err)
+			}
+
+			client := &http.Client{Timeout: time.Duration(cmd.Int("executionLimit")) * time.Second}
+
+			if err = RunCollection(ctx, w, client, requests); err != nil {
+				return fmt.Errorf("[in run.request] RunCollection failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// sqsCommand returns the `sqs` command. logLevel is set by the global --verbose flag.
+func sqsCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "sqs",
+		Usage: "Invoke lambda with the messages of an SQS queue, like an SQS event source",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name:     "queue-url",
+				Required: true,
+				Usage:    "`URL` of the queue to poll.",
+			},
+			&cli.StringFlag{
+				Name: "endpoint",
+				Usage: "SQS endpoint, like http://localhost:9324 for ElasticMQ or http://localhost:4566 for " +
+					"LocalStack. Defaults to the host of --queue-url.",
+			},
+			&cli.StringFlag{
+				Name:  "region",
+				Usage: "Region of the queue when its URL doesn't name one. Defaults to AWS_REGION.",
+			},
+			&cli.StringFlag{
+				Name: "function",
+				Usage: "Logical ID of the function in the template, its SQS event sets the batch size. Without " +
+					"--address the address is taken from the function's entry in the config.",
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml, used with --function.",
+			},
+			&cli.IntFlag{
+				Name: "batch-size",
+				Usage: "Maximum number of messages per invocation, overriding the BatchSize of the template. " +
+					"(default: 10)",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v <= 0 {
+						return fmt.Errorf("expected a positive batch size. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.DurationFlag{
+				Name:  "wait-time",
+				Value: 20 * time.Second, //nolint:mnd
+				Usage: "Long polling time of ReceiveMessage, up to 20s.",
+			},
+			&cli.BoolFlag{
+				Name: "report-batch-item-failures",
+				Usage: "Delete only the messages missing from the batchItemFailures of the response, overriding " +
+					"the FunctionResponseTypes of the template.",
+			},
+			&cli.BoolFlag{
+				Name:  "exit-when-empty",
+				Usage: "Exit once the queue has no messages instead of polling until interrupted.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
+			function := cmd.String("function")
+
+			logger := slog.New(
+				tint.NewHandler(
+					w, &tint.Options{
+						Level:      *logLevel,
+						TimeFormat: "15:04:05.000",
+					},
+				),
+			)
+
+			lambda, err := startEventSourceLambda(ctx, cmd, w, logger)
+			if err != nil {
+				return fmt.Errorf("[in run.sqs] %w", err)
+			}
+			defer lambda.close()
+
+			region := cmd.String("region")
+			if region == "" {
+				region = os.Getenv("AWS_REGION")
+			}
+
+			queue, err := newSQSQueue(cmd.String("queue-url"), region)
+			if err != nil {
+				return fmt.Errorf("[in run.sqs] %w", err)
+			}
+
+			source := sqsSource{batchSize: sqsDefaultBatchSize}
+
+			if function != "" {
+				parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+				if err != nil {
+					return fmt.Errorf("[in run.sqs] %w", err)
+				}
+
+				source, err = templateSQSSource(cmd.String("template"), function, osFileReader{}, parameterOverrides)
+				if err != nil {
+					return fmt.Errorf("[in run.sqs] %w", err)
+				}
+			}
+
+			if cmd.IsSet("batch-size") {
+				source.batchSize = int(cmd.Int("batch-size"))
+			}
+
+			if cmd.IsSet("report-batch-item-failures") {
+				source.reportBatchItemFailures = cmd.Bool("report-batch-item-failures")
+			}
+
+			endpoint := cmd.String("endpoint")
+			if endpoint == "" {
+				queueURL, _ := url.Parse(queue.url)
+				endpoint = queueURL.Scheme + "://" + queueURL.Host
+			}
+
+			credentials, err := credentialsFromEnv(os.LookupEnv, cmd.IsSet("endpoint"))
+			if err != nil {
+				return fmt.Errorf("[in run.sqs] %w", err)
+			}
+
+			client := newSQSClient(
+				endpoint,
+				queue.region,
+				credentials,
+				&http.Client{Timeout: cmd.Duration("wait-time") + executionLimit},
+			)
+
+			source.queue = queue
+			source.wait = min(cmd.Duration("wait-time"), 20*time.Second) //nolint:mnd
+			source.exitWhenEmpty = cmd.Bool("exit-when-empty")
+
+			if err = RunSQS(ctx, client, lambda.caller, source, newResponseFormat(cmd), logger); err != nil {
+				return fmt.Errorf("[in run.sqs] RunSQS failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func snsCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "sns",
+		Usage: "Invoke lambda with the messages published to a local SNS endpoint, like an SNS subscription",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name:    "port",
+				Aliases: []string{"p"},
+				Value:   "9911",
+				Usage:   "Port the SNS endpoint listens on, for Publish requests and HTTP subscriptions.",
+			},
+			&cli.StringFlag{
+				Name:  "topic-arn",
+				Value: snsDefaultTopicARN,
+				Usage: "`ARN` of the topic of messages published without TopicArn.",
+			},
+			&cli.StringFlag{
+				Name: "function",
+				Usage: "Logical ID of the function in the template. Without --address the address is taken from " +
+					"the function's entry in the config.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			logger := slog.New(
+				tint.NewHandler(
+					w, &tint.Options{
+						Level:      *logLevel,
+						TimeFormat: "15:04:05.000",
+					},
+				),
+			)
+
+			lambda, err := startEventSourceLambda(ctx, cmd, w, logger)
+			if err != nil {
+				return fmt.Errorf("[in run.sns] %w", err)
+			}
+			defer lambda.close()
+
+			handler := snsHandler{
+				lambdaRPC: lambda.caller,
+				topicARN:  cmd.String("topic-arn"),
+				format:    newResponseFormat(cmd),
+				client:    &http.Client{Timeout: 10 * time.Second}, //nolint:mnd
+				logger:    logger,
+			}
+
+			if err = RunSNS(ctx, "localhost:"+cmd.String("port"), handler, logger); err != nil {
+				return fmt.Errorf("[in run.sns] RunSNS failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func dynamodbCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "dynamodb",
+		Usage: "Invoke lambda with the records of a DynamoDB stream, like a DynamoDB event source",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name:     "endpoint",
+				Required: true,
+				Usage: "DynamoDB endpoint serving the DynamoDB and DynamoDB Streams APIs, like http://localhost:8000 " +
+					"for DynamoDB Local or http://localhost:4566 for LocalStack.",
+			},
+			&cli.StringFlag{
+				Name:  "stream-arn",
+				Usage: "`ARN` of the stream to read, overriding the Stream of the template.",
+			},
+			&cli.StringFlag{
+				Name:  "table",
+				Usage: "Name of the table whose latest stream is read, overriding the Stream of the template.",
+			},
+			&cli.StringFlag{
+				Name:  "region",
+				Usage: "Region of the stream when its ARN doesn't name one. Defaults to AWS_REGION.",
+			},
+			&cli.StringFlag{
+				Name: "function",
+				Usage: "Logical ID of the function in the template, its DynamoDB event sets the stream and batch " +
+					"size. Without --address the address is taken from the function's entry in the config.",
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml, used with --function.",
+			},
+			&cli.IntFlag{
+				Name: "batch-size",
+				Usage: "Maximum number of records per invocation, overriding the BatchSize of the template. " +
+					"(default: 100)",
+				Action: func(_ context.Context, _ *cli.Command, v int64) error {
+					if v <= 0 {
+						return fmt.Errorf("expected a positive batch size. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name: "starting-position",
+				Usage: "Where to start reading the stream, TRIM_HORIZON or LATEST, overriding the StartingPosition " +
+					"of the template. (default: LATEST)",
+				Action: func(_ context.Context, _ *cli.Command, v string) error {
+					if v != dynamoDBTrimHorizon && v != dynamoDBLatest {
+						return fmt.Errorf("expected TRIM_HORIZON or LATEST. Got %s", v)
+					}
+
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name: "report-batch-item-failures",
+				Usage: "Retry batches from the first record in the batchItemFailures of the response, overriding " +
+					"the FunctionResponseTypes of the template.",
+			},
+			&cli.BoolFlag{
+				Name:  "exit-when-empty",
+				Usage: "Exit once the stream has no new records instead of polling until interrupted.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			executionLimit := time.Duration(cmd.Int("executionLimit")) * time.Second
+			function := cmd.String("function")
+
+			logger := slog.New(
+				tint.NewHandler(
+					w, &tint.Options{
+						Level:      *logLevel,
+						TimeFormat: "15:04:05.000",
+					},
+				),
+			)
+
+			lambda, err := startEventSourceLambda(ctx, cmd, w, logger)
+			if err != nil {
+				return fmt.Errorf("[in run.dynamodb] %w", err)
+			}
+			defer lambda.close()
+
+			source := dynamoDBStreamSource{batchSize: dynamoDBDefaultBatchSize, startingPosition: dynamoDBLatest}
+			table := cmd.String("table")
+
+			if function != "" {
+				parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+				if err != nil {
+					return fmt.Errorf("[in run.dynamodb] %w", err)
+				}
+
+				var templateTable string
+
+				source, templateTable, err = templateDynamoDBSource(
+					cmd.String("template"),
+					function,
+					osFileReader{},
+					parameterOverrides,
+				)
+				if err != nil {
+					return fmt.Errorf("[in run.dynamodb] %w", err)
+				}
+
+				if !cmd.IsSet("table") {
+					table = templateTable
+				}
+			}
+
+			if cmd.IsSet("stream-arn") || cmd.IsSet("table") {
+				source.streamARN = cmd.String("stream-arn")
+			}
+
+			if source.streamARN == "" && table == "" {
+				return errors.New("[in run.dynamodb] set --stream-arn or --table, or --function with a DynamoDB event")
+			}
+
+			if cmd.IsSet("batch-size") {
+				source.batchSize = int(cmd.Int("batch-size"))
+			}
+
+			if cmd.IsSet("starting-position") {
+				source.startingPosition = cmd.String("starting-position")
+			}
+
+			if cmd.IsSet("report-batch-item-failures") {
+				source.reportBatchItemFailures = cmd.Bool("report-batch-item-failures")
+			}
+
+			region := cmd.String("region")
+			if region == "" {
+				region = cmp.Or(os.Getenv("AWS_REGION"), sqsDefaultRegion)
+			}
+
+			// stream ARNs are arn:aws:dynamodb:REGION:ACCOUNT:table/NAME/stream/LABEL
+			if parts := strings.Split(source.streamARN, ":"); len(parts) > 3 && parts[3] != "" { //nolint:mnd
+				region = parts[3]
+			}
+
+			credentials, err := credentialsFromEnv(os.LookupEnv, true)
+			if err != nil {
+				return fmt.Errorf("[in run.dynamodb] %w", err)
+			}
+
+			client := newDynamoDBStreamsClient(
+				cmd.String("endpoint"),
+				region,
+				credentials,
+				&http.Client{Timeout: executionLimit},
+			)
+
+			if source.streamARN == "" {
+				if source.streamARN, err = client.latestStreamARN(ctx, table); err != nil {
+					return fmt.Errorf("[in run.dynamodb] %w", err)
+				}
+			}
+
+			source.pollInterval = dynamoDBPollInterval
+			source.exitWhenEmpty = cmd.Bool("exit-when-empty")
+
+			if err = RunDynamoDBStream(ctx, client, lambda.caller, source, newResponseFormat(cmd), logger); err != nil {
+				return fmt.Errorf("[in run.dynamodb] RunDynamoDBStream failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func scheduleCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "schedule",
+		Usage: "Invoke lambda on the Schedule and ScheduleV2 events of the template, like EventBridge",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name: "function",
+				Usage: "Logical ID of the function in the template, its Schedule and ScheduleV2 events are run. " +
+					"Without --address the address is taken from the function's entry in the config.",
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml, used with --function.",
+			},
+			&cli.StringFlag{
+				Name: "expression",
+				Usage: "Schedule expression to run instead of the events of the template, like 'rate(5 minutes)', " +
+					"'cron(0 12 * * ? *)' or 'at(2025-01-01T09:00:00)', evaluated in UTC.",
+			},
+			&cli.FloatFlag{
+				Name:  "accelerate",
+				Value: 1,
+				Usage: "Run the schedules `FACTOR` times faster than the wall clock, 60 makes a minute pass every " +
+					"second.",
+				Action: func(_ context.Context, _ *cli.Command, v float64) error {
+					if v <= 0 {
+						return fmt.Errorf("expected a positive factor. Got %v", v)
+					}
+
+					return nil
+				},
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			function := cmd.String("function")
+
+			logger := slog.New(
+				tint.NewHandler(
+					w, &tint.Options{
+						Level:      *logLevel,
+						TimeFormat: "15:04:05.000",
+					},
+				),
+			)
+
+			lambda, err := startEventSourceLambda(ctx, cmd, w, logger)
+			if err != nil {
+				return fmt.Errorf("[in run.schedule] %w", err)
+			}
+			defer lambda.close()
+
+			var sources []scheduleSource
+
+			switch {
+			case cmd.IsSet("expression"):
+				sources = []scheduleSource{{name: cmp.Or(function, "schedule"), expression: cmd.String("expression")}}
+			case function != "":
+				parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+				if err != nil {
+					return fmt.Errorf("[in run.schedule] %w", err)
+				}
+
+				sources, err = templateSchedules(cmd.String("template"), function, osFileReader{}, parameterOverrides)
+				if err != nil {
+					return fmt.Errorf("[in run.schedule] %w", err)
+				}
+			default:
+				return errors.New(
+					"[in run.schedule] set --expression, or --function with a Schedule or ScheduleV2 event",
+				)
+			}
+
+			schedules, err := parseSchedules(sources)
+			if err != nil {
+				return fmt.Errorf("[in run.schedule] %w", err)
+			}
+
+			clock := newScheduleClock(cmd.Float("accelerate"))
+
+			if err = RunSchedules(ctx, lambda.caller, schedules, clock, newResponseFormat(cmd), logger); err != nil {
+				return fmt.Errorf("[in run.schedule] RunSchedules failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func eventbridgeCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "eventbridge",
+		Usage: "Invoke the lambdas whose EventBridgeRule patterns match the events put to a local EventBridge endpoint",
+		Flags: []cli.Flag{
+			protocolFlag(),
+			&cli.StringFlag{
+				Name:    "port",
+				Aliases: []string{"p"},
+				Value:   "9912",
+				Usage:   "Port the EventBridge endpoint listens on, for PutEvents requests.",
+			},
+			&cli.StringFlag{
+				Name:    "template",
+				Aliases: []string{"t"},
+				Value:   "./template.yaml",
+				Usage:   "Path to AWS SAM template.yaml, its EventBridgeRule events are the rules.",
+			},
+			&cli.StringMapFlag{
+				Name: "function-address",
+				Usage: "`FUNCTION=ADDRESS` invoking the function with this logical ID at ADDRESS instead of " +
+					"--address. Can be repeated.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			logger := slog.New(
+				tint.NewHandler(
+					w, &tint.Options{
+						Level:      *logLevel,
+						TimeFormat: "15:04:05.000",
+					},
+				),
+			)
+
+			lambda, err := startEventSourceLambda(ctx, cmd, w, logger)
+			if err != nil {
+				return fmt.Errorf("[in run.eventbridge] %w", err)
+			}
+			defer lambda.close()
+
+			parameterOverrides, err := parseParameterOverrides(cmd.StringSlice("parameter-overrides"))
+			if err != nil {
+				return fmt.Errorf("[in run.eventbridge] %w", err)
+			}
+
+			rules, err := templateEventBridgeRules(cmd.String("template"), osFileReader{}, parameterOverrides)
+			if err != nil {
+				return fmt.Errorf("[in run.eventbridge] %w", err)
+			}
+
+			// create a lambda client for each function with its own address
+			functionCallers, closeFunctions, err := newFunctionCallers(
+				cmd.String("protocol"),
+				lambda.functionAddresses,
+				time.Duration(cmd.Int("executionLimit"))*time.Second,
+				logger,
+				lambdaOptions(cmd, w)...,
+			)
+			if err != nil {
+				return fmt.Errorf("[in run.eventbridge] newFunctionCallers failed: %w", err)
+			}
+			defer closeFunctions()
+
+			for function, caller := range functionCallers {
+				functionCallers[function] = lambda.plugins.caller(caller)
+			}
+
+			handler := eventBridgeHandler{
+				rules:           rules,
+				lambdaRPC:       lambda.caller,
+				functionCallers: functionCallers,
+				format:          newResponseFormat(cmd),
+				logger:          logger,
+			}
+
+			if err = RunEventBridge(ctx, "localhost:"+cmd.String("port"), handler, logger); err != nil {
+				return fmt.Errorf("[in run.eventbridge] RunEventBridge failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func workspaceCommand(w io.Writer, logLevel *slog.Level) *cli.Command {
+	return &cli.Command{
+		Name:  "workspace",
+		Usage: "Run the services of a monorepo listed in a workspace file",
+		Commands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "Start a supervised api gateway for every service of the workspace, with a combined log",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Value:   defaultWorkspacePath,
+						Usage:   "Path to the workspace file listing the services.",
+					},
+					&cli.StringSliceFlag{
+						Name:  "service",
+						Usage: "Name of a service to start instead of all of them. Can be repeated.",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					logger := slog.New(
+						tint.NewHandler(
+							w, &tint.Options{
+								Level:      *logLevel,
+								TimeFormat: "15:04:05.000",
+							},
+						),
+					)
+
+					ws, err := loadWorkspace(cmd.String("file"), osFileReader{})
+					if err != nil {
+						return fmt.Errorf("[in run.workspace] %w", err)
+					}
+
+					if ws, err = ws.selectServices(cmd.StringSlice("service")); err != nil {
+						return fmt.Errorf("[in run.workspace] %w", err)
+					}
+
+					// every gateway is a lambdalocal api process of its own
+					executable, err := os.Executable()
+					if err != nil {
+						return fmt.Errorf("[in run.workspace] failed to find the lambdalocal executable: %w", err)
+					}
+
+					if err = RunWorkspace(ctx, w, ws, executable, cmd.Bool("verbose"), logger); err != nil {
+						return fmt.Errorf("[in run.workspace] RunWorkspace failed: %w", err)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
 }
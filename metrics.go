@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// metricsPath is the path of the metrics endpoint served next to the template routes.
+const metricsPath = "/__lambdalocal/metrics"
+
+// connMetrics tracks the connections of the local API server through http.Server.ConnState.
+type connMetrics struct {
+	mu               sync.Mutex
+	now              func() time.Time
+	disableKeepAlive bool
+	open             map[net.Conn]*connStats
+	total            int
+	requests         int
+	reusedRequests   int
+	closed           int
+	closedLifetime   time.Duration
+	maxLifetime      time.Duration
+	// budgets are reported with the connection metrics.
+	budgets *latencyBudgets
+	// workers are reported with the connection metrics, nil without a worker pool.
+	workers *workerPool
+}
+
+type connStats struct {
+	opened   time.Time
+	state    http.ConnState
+	requests int
+}
+
+type metricsSnapshot struct {
+	Connections    connectionMetrics        `json:"connections"`
+	Requests       requestMetrics           `json:"requests"`
+	LatencyBudgets map[string]budgetMetrics `json:"latencyBudgets,omitempty"`
+	WorkerPool     *workerPoolMetrics       `json:"workerPool,omitempty"`
+}
+
+type connectionMetrics struct {
+	Open             int              `json:"open"`
+	Total            int              `json:"total"`
+	Closed           int              `json:"closed"`
+	DisableKeepAlive bool             `json:"disableKeepAlive"`
+	AvgLifetimeMs    int64            `json:"avgLifetimeMs"`
+	MaxLifetimeMs    int64            `json:"maxLifetimeMs"`
+	OpenConnections  []openConnection `json:"openConnections"`
+}
+
+type openConnection struct {
+	RemoteAddr string `json:"remoteAddr"`
+	AgeMs      int64  `json:"ageMs"`
+	Requests   int    `json:"requests"`
+}
+
+type requestMetrics struct {
+	Total     int     `json:"total"`
+	Reused    int     `json:"reused"`
+	ReuseRate float64 `json:"reuseRate"`
+}
+
+func newConnMetrics(disableKeepAlive bool) *connMetrics {
+	return &connMetrics{
+		now:              time.Now,
+		disableKeepAlive: disableKeepAlive,
+		open:             make(map[net.Conn]*connStats),
+	}
+}
+
+// connState records a connection state change. A request arriving on an idle connection is
+// counted as a keep-alive reuse.
+func (m *connMetrics) connState(conn net.Conn, state http.ConnState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		m.total++
+		m.open[conn] = &connStats{opened: m.now(), state: state}
+	case http.StateActive:
+		stats, ok := m.open[conn]
+		if !ok {
+			return
+		}
+
+		m.requests++
+		if stats.state == http.StateIdle {
+			m.reusedRequests++
+		}
+
+		stats.requests++
+		stats.state = state
+	case http.StateIdle:
+		if stats, ok := m.open[conn]; ok {
+			stats.state = state
+		}
+	case http.StateHijacked, http.StateClosed:
+		stats, ok := m.open[conn]
+		if !ok {
+			return
+		}
+
+		lifetime := m.now().Sub(stats.opened)
+
+		m.closed++
+		m.closedLifetime += lifetime
+		m.maxLifetime = max(m.maxLifetime, lifetime)
+
+		delete(m.open, conn)
+	}
+}
+
+func (m *connMetrics) snapshot() metricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	snapshot := metricsSnapshot{
+		Connections: connectionMetrics{
+			Open:             len(m.open),
+			Total:            m.total,
+			Closed:           m.closed,
+			DisableKeepAlive: m.disableKeepAlive,
+			MaxLifetimeMs:    m.maxLifetime.Milliseconds(),
+			OpenConnections:  make([]openConnection, 0, len(m.open)),
+		},
+		Requests: requestMetrics{
+			Total:  m.requests,
+			Reused: m.reusedRequests,
+		},
+	}
+
+	if m.budgets != nil {
+		snapshot.LatencyBudgets = m.budgets.snapshot()
+	}
+
+	if m.workers != nil {
+		workers := m.workers.snapshot()
+		snapshot.WorkerPool = &workers
+	}
+
+	if m.closed > 0 {
+		snapshot.Connections.AvgLifetimeMs = (m.closedLifetime / time.Duration(m.closed)).Milliseconds()
+	}
+
+	if m.requests > 0 {
+		snapshot.Requests.ReuseRate = float64(m.reusedRequests) / float64(m.requests)
+	}
+
+	for conn, stats := range m.open {
+		snapshot.Connections.OpenConnections = append(
+			snapshot.Connections.OpenConnections, openConnection{
+				RemoteAddr: conn.RemoteAddr().String(),
+				AgeMs:      now.Sub(stats.opened).Milliseconds(),
+				Requests:   stats.requests,
+			},
+		)
+	}
+
+	// oldest connections first
+	sort.Slice(
+		snapshot.Connections.OpenConnections, func(i, j int) bool {
+			return snapshot.Connections.OpenConnections[i].AgeMs > snapshot.Connections.OpenConnections[j].AgeMs
+		},
+	)
+
+	return snapshot
+}
+
+// ServeHTTP returns the current metrics as JSON.
+func (m *connMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	_ = encoder.Encode(m.snapshot())
+}
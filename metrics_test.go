@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnMetrics(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	metrics := newConnMetrics(false)
+	metrics.now = func() time.Time { return now }
+
+	first, _ := net.Pipe()
+	second, _ := net.Pipe()
+
+	// first connection serves two requests and is closed after 3 seconds
+	metrics.connState(first, http.StateNew)
+	metrics.connState(first, http.StateActive)
+	metrics.connState(first, http.StateIdle)
+	metrics.connState(first, http.StateActive)
+	metrics.connState(first, http.StateIdle)
+
+	// second connection serves one request and stays open
+	now = start.Add(time.Second)
+	metrics.connState(second, http.StateNew)
+	metrics.connState(second, http.StateActive)
+
+	now = start.Add(3 * time.Second)
+	metrics.connState(first, http.StateClosed)
+
+	now = start.Add(4 * time.Second)
+
+	snapshot := metrics.snapshot()
+
+	assert.Equal(
+		t, metricsSnapshot{
+			Connections: connectionMetrics{
+				Open:          1,
+				Total:         2,
+				Closed:        1,
+				AvgLifetimeMs: 3000,
+				MaxLifetimeMs: 3000,
+				OpenConnections: []openConnection{
+					{RemoteAddr: "pipe", AgeMs: 3000, Requests: 1},
+				},
+			},
+			Requests: requestMetrics{
+				Total:     3,
+				Reused:    1,
+				ReuseRate: 1.0 / 3,
+			},
+		}, snapshot,
+	)
+}
+
+func TestConnMetrics_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	metrics := newConnMetrics(true)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var snapshot metricsSnapshot
+
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &snapshot))
+	assert.True(t, snapshot.Connections.DisableKeepAlive)
+	assert.Equal(t, []openConnection{}, snapshot.Connections.OpenConnections)
+}
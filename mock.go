@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mockResponse is the response of a mock route in the project config. Body and header values are
+// Go templates executed with the mockRequest.
+type mockResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// mockRequest is the data mock templates are executed with.
+type mockRequest struct {
+	Method         string
+	Path           string
+	PathParameters map[string]string
+	Query          url.Values
+	Headers        http.Header
+	Body           string
+	// JSON is the decoded body, nil when the body isn't JSON.
+	JSON any
+}
+
+// mockRoute is a route answered with a templated response instead of invoking a lambda.
+type mockRoute struct {
+	route   apiRoute
+	status  int
+	headers map[string]*template.Template
+	body    *template.Template
+}
+
+// mockFuncs are the helper functions of mock templates.
+func mockFuncs() template.FuncMap {
+	return template.FuncMap{
+		"uuid": func() string {
+			return uuid.New().String()
+		},
+		"now": time.Now,
+		// randomInt returns a random integer between minimum and maximum, inclusive.
+		"randomInt": func(minimum, maximum int) (int, error) {
+			if maximum < minimum {
+				return 0, fmt.Errorf("randomInt: maximum %d is less than minimum %d", maximum, minimum)
+			}
+
+			return minimum + rand.IntN(maximum-minimum+1), nil //nolint:gosec
+		},
+	}
+}
+
+// parseMocks parses the mocks of the project config, keyed by route like "GET /users/{id}". Routes
+// without a method, or with ANY, match every method, and $default matches requests no other route
+// matches.
+func parseMocks(mocks map[string]mockResponse) ([]mockRoute, error) {
+	routes := make([]mockRoute, 0, len(mocks))
+
+	for key, mock := range mocks {
+		route, err := mockRouteOf(key)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseMocks] %w", err)
+		}
+
+		parsed, err := parseMock(route, mock)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseMocks] mock '%s': %w", key, err)
+		}
+
+		routes = append(routes, parsed)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].route.routeKey() < routes[j].route.routeKey()
+	})
+
+	return routes, nil
+}
+
+// parseMock parses the templates of mock, which answers requests of route.
+func parseMock(route apiRoute, mock mockResponse) (mockRoute, error) {
+	body, err := template.New("body").Funcs(mockFuncs()).Parse(mock.Body)
+	if err != nil {
+		return mockRoute{}, fmt.Errorf("body: %w", err)
+	}
+
+	parsed := mockRoute{
+		route:   route,
+		status:  cmp.Or(mock.Status, http.StatusOK),
+		headers: make(map[string]*template.Template, len(mock.Headers)),
+		body:    body,
+	}
+
+	for name, value := range mock.Headers {
+		if parsed.headers[name], err = template.New(name).Funcs(mockFuncs()).Parse(value); err != nil {
+			return mockRoute{}, fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+
+	return parsed, nil
+}
+
+// mockRouteOf returns the route of a mock key.
+func mockRouteOf(key string) (apiRoute, error) {
+	if key == defaultRouteKey {
+		return apiRoute{path: defaultRoutePath}, nil
+	}
+
+	method, path, found := strings.Cut(key, " ")
+	if !found {
+		method, path = "", key
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return apiRoute{}, fmt.Errorf("invalid mock route '%s', expected like 'GET /users/{id}'", key)
+	}
+
+	return apiRoute{method: routeMethod(method), path: path}, nil
+}
+
+// mockFor returns the mock of route, if there is one.
+func mockFor(mocks []mockRoute, route apiRoute) (mockRoute, bool) {
+	for _, mock := range mocks {
+		if mock.route.routeKey() == route.routeKey() {
+			return mock, true
+		}
+	}
+
+	return mockRoute{}, false
+}
+
+// handler answers requests of the mock route with its response.
+func (m mockRoute) handler(logger *slog.Logger) http.Handler {
+	var pathParamKeys []string
+
+	for _, match := range regexp.MustCompile(`{([^}]*)}`).FindAllStringSubmatch(m.route.path, -1) {
+		pathParamKeys = append(pathParamKeys, strings.TrimSuffix(match[1], "+"))
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requestID := uuid.New().String()
+			w.Header().Set(requestIDHeader, requestID)
+
+			logger := logger.With("requestId", requestID)
+			logger.Info("Mocking response for: " + r.URL.Path)
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				logger.Error("[in lambdalocal.mockRoute.handler] read body failed", "err", err)
+				writeGatewayError(w, r, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+				return
+			}
+
+			request := mockRequest{
+				Method:         r.Method,
+				Path:           r.URL.Path,
+				PathParameters: make(map[string]string, len(pathParamKeys)),
+				Query:          r.URL.Query(),
+				Headers:        r.Header,
+				Body:           string(body),
+			}
+
+			for _, key := range pathParamKeys {
+				request.PathParameters[key] = r.PathValue(key)
+			}
+
+			// numbers are kept as json.Number, so 64-bit IDs are echoed as they were sent
+			_ = unmarshalJSON(body, &request.JSON)
+
+			headers := make(map[string]string, len(m.headers))
+
+			for name, value := range m.headers {
+				if headers[name], err = executeMockTemplate(value, request); err != nil {
+					logger.Error(
+						"[in lambdalocal.mockRoute.handler] header template failed",
+						"header", name,
+						"err", err,
+					)
+					writeGatewayError(w, r, gatewayMessageInternal, http.StatusInternalServerError)
+
+					return
+				}
+			}
+
+			response, err := executeMockTemplate(m.body, request)
+			if err != nil {
+				logger.Error("[in lambdalocal.mockRoute.handler] body template failed", "err", err)
+				writeGatewayError(w, r, gatewayMessageInternal, http.StatusInternalServerError)
+
+				return
+			}
+
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+
+			w.WriteHeader(m.status)
+			_, _ = w.Write([]byte(response))
+		},
+	)
+}
+
+func executeMockTemplate(tmpl *template.Template, request mockRequest) (string, error) {
+	var out bytes.Buffer
+
+	if err := tmpl.Execute(&out, request); err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMocks(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mocks          map[string]mockResponse
+		expectedRoutes []string
+		expectedErrStr string
+	}{
+		"routes are parsed and sorted": {
+			mocks: map[string]mockResponse{
+				"GET /users/{id}": {Body: "{}"},
+				"ANY /orders":     {Body: "{}"},
+				"/health":         {Body: "ok"},
+				"$default":        {Status: http.StatusNotFound},
+			},
+			expectedRoutes: []string{"$default", "ANY /health", "ANY /orders", "GET /users/{id}"},
+		},
+		"invalid route": {
+			mocks:          map[string]mockResponse{"GET users": {}},
+			expectedErrStr: "invalid mock route 'GET users'",
+		},
+		"invalid body template": {
+			mocks:          map[string]mockResponse{"GET /users": {Body: "{{ .Path"}},
+			expectedErrStr: "mock 'GET /users': body:",
+		},
+		"invalid header template": {
+			mocks: map[string]mockResponse{
+				"GET /users": {Headers: map[string]string{"Location": "{{ unknown }}"}},
+			},
+			expectedErrStr: "mock 'GET /users': header Location:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mocks, err := parseMocks(tc.mocks)
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+
+				routes := make([]string, 0, len(mocks))
+				for _, mock := range mocks {
+					routes = append(routes, mock.route.routeKey())
+				}
+
+				assert.Equal(t, tc.expectedRoutes, routes)
+			},
+		)
+	}
+}
+
+func TestMockRouteHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mock            mockResponse
+		request         *http.Request
+		expectedStatus  int
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		"request fields": {
+			mock: mockResponse{
+				Status:  http.StatusCreated,
+				Headers: map[string]string{"Location": "/users/{{ .PathParameters.id }}"},
+				Body: `{"id":"{{ .PathParameters.id }}","page":"{{ .Query.Get "page" }}",` +
+					`"name":"{{ .JSON.name }}","tenant":"{{ .Headers.Get "x-tenant" }}","method":"{{ .Method }}"}`,
+			},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/users/42?page=2", strings.NewReader(`{"name":"ada"}`))
+				r.Header.Set("X-Tenant", "acme")
+
+				return r
+			}(),
+			expectedStatus:  http.StatusCreated,
+			expectedBody:    `{"id":"42","page":"2","name":"ada","tenant":"acme","method":"POST"}`,
+			expectedHeaders: map[string]string{"Location": "/users/42"},
+		},
+		"json numbers": {
+			mock: mockResponse{Body: `{"id":{{ .JSON.id }},"price":{{ .JSON.price }}}`},
+			request: httptest.NewRequest(
+				http.MethodPost,
+				"/users/1",
+				strings.NewReader(`{"id":12345678901234567890,"price":1.10}`),
+			),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":12345678901234567890,"price":1.10}`,
+		},
+		"helper funcs": {
+			mock: mockResponse{
+				Body: `{{ uuid }} {{ now.Year }} {{ randomInt 7 7 }} {{ .Body }}`,
+			},
+			request:        httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("not json")),
+			expectedStatus: http.StatusOK,
+		},
+		"failing template": {
+			mock:           mockResponse{Body: `{{ randomInt 2 1 }}`},
+			request:        httptest.NewRequest(http.MethodGet, "/users/1", nil),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"message":"Internal server error"}` + "\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mocks, err := parseMocks(map[string]mockResponse{"/users/{id}": tc.mock})
+				require.NoError(t, err)
+
+				router := http.NewServeMux()
+				router.Handle(mocks[0].route.muxPattern(), mocks[0].handler(slog.Default()))
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, tc.request)
+
+				assert.Equal(t, tc.expectedStatus, w.Code)
+				assert.NotEmpty(t, w.Header().Get(requestIDHeader))
+
+				for header, value := range tc.expectedHeaders {
+					assert.Equal(t, value, w.Header().Get(header))
+				}
+
+				if tc.expectedBody != "" {
+					assert.Equal(t, tc.expectedBody, w.Body.String())
+
+					return
+				}
+
+				fields := strings.Fields(w.Body.String())
+				require.Len(t, fields, 5)
+				assert.NoError(t, uuid.Validate(fields[0]))
+				assert.Equal(t, "7", fields[2])
+				assert.Equal(t, "not json", strings.Join(fields[3:], " "))
+			},
+		)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// content types of the errors lambdalocal answers requests with itself
+const (
+	errorContentTypeJSON = "application/json"
+	errorContentTypeHTML = "text/html"
+	errorContentTypeText = "text/plain"
+)
+
+// messages of the errors API Gateway answers with
+const (
+	gatewayMessageMissingToken = "Missing Authentication Token"
+	gatewayMessageBadRequest   = "Bad Request"
+	gatewayMessageTooLong      = "Request Too Long"
+	gatewayMessageInternal     = "Internal server error"
+	gatewayMessageTimeout      = "Endpoint request timed out"
+)
+
+// errorContentTypes are the content types of errors, in order of preference between equally
+// acceptable ones.
+var errorContentTypes = []string{ //nolint:gochecknoglobals
+	errorContentTypeJSON,
+	errorContentTypeHTML,
+	errorContentTypeText,
+}
+
+type errorContentTypeKey struct{}
+
+// negotiatedErrors returns router answering requests that don't match a route with an error of the
+// content type accepted by the client, and makes contentType the content type of errors for
+// clients that accept any. Like API Gateway, unknown paths and methods are answered with 403
+// Missing Authentication Token.
+func negotiatedErrors(router *http.ServeMux, contentType string) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), errorContentTypeKey{}, contentType))
+
+			if _, pattern := router.Handler(r); pattern != "" {
+				router.ServeHTTP(w, r)
+
+				return
+			}
+
+			// the router knows whether the path is unknown or the method isn't allowed
+			headers := headerWriter{header: make(http.Header)}
+			router.ServeHTTP(headers, r)
+
+			if allow := headers.header.Get("Allow"); allow != "" {
+				w.Header().Set("Allow", allow)
+			}
+
+			writeGatewayError(w, r, gatewayMessageMissingToken, http.StatusForbidden)
+		},
+	)
+}
+
+// headerWriter keeps the headers a handler sets and discards its status and body.
+type headerWriter struct {
+	header http.Header
+}
+
+func (w headerWriter) Header() http.Header {
+	return w.header
+}
+
+func (w headerWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w headerWriter) WriteHeader(int) {}
+
+// writeGatewayError replies to r with an error of lambdalocal itself, like http.Error, as JSON,
+// HTML or plain text depending on the Accept header of r. JSON errors have the shape of API
+// Gateway's, {"message": "..."}.
+func writeGatewayError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	defaultContentType, _ := r.Context().Value(errorContentTypeKey{}).(string)
+
+	contentType := negotiateContentType(
+		r.Header.Get("Accept"),
+		errorContentTypes,
+		cmp.Or(defaultContentType, errorContentTypeJSON),
+	)
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	switch contentType {
+	case errorContentTypeJSON:
+		w.Header().Set("Content-Type", errorContentTypeJSON)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+	case errorContentTypeHTML:
+		title := fmt.Sprintf("%d %s", status, http.StatusText(status))
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(
+			w,
+			"<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1><p>%s</p></body></html>\n",
+			title,
+			title,
+			html.EscapeString(message),
+		)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintln(w, message)
+	}
+}
+
+// negotiateContentType returns the one of offers the accept header prefers, fallback when the
+// header accepts any of them equally or none of them.
+func negotiateContentType(accept string, offers []string, fallback string) string {
+	if strings.TrimSpace(accept) == "" {
+		return fallback
+	}
+
+	best, bestQuality := fallback, 0.0
+
+	for _, offer := range offers {
+		quality := acceptQuality(accept, offer)
+		if quality > bestQuality || (quality == bestQuality && quality > 0 && offer == fallback) {
+			best, bestQuality = offer, quality
+		}
+	}
+
+	return best
+}
+
+// acceptQuality returns the quality the accept header gives contentType, taken from its most
+// specific media range matching it. It is 0 when no range matches.
+func acceptQuality(accept, contentType string) float64 {
+	typ, _, _ := strings.Cut(contentType, "/")
+
+	quality, specificity := 0.0, -1
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		var rangeSpecificity int
+
+		switch {
+		case mediaType == contentType:
+			rangeSpecificity = 2
+		case mediaType == typ+"/*":
+			rangeSpecificity = 1
+		case mediaType == "*/*":
+			rangeSpecificity = 0
+		default:
+			continue
+		}
+
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		specificity, quality = rangeSpecificity, 1
+
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				quality = 0
+			}
+		}
+	}
+
+	return quality
+}
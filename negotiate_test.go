@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accept   string
+		fallback string
+		expected string
+	}{
+		"no accept header": {
+			fallback: errorContentTypeText,
+			expected: errorContentTypeText,
+		},
+		"any": {
+			accept:   "*/*",
+			fallback: errorContentTypeJSON,
+			expected: errorContentTypeJSON,
+		},
+		"browser": {
+			accept:   "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			fallback: errorContentTypeText,
+			expected: errorContentTypeHTML,
+		},
+		"sdk": {
+			accept:   "application/json",
+			fallback: errorContentTypeText,
+			expected: errorContentTypeJSON,
+		},
+		"quality": {
+			accept:   "text/plain;q=0.5, application/json;q=0.9",
+			fallback: errorContentTypeText,
+			expected: errorContentTypeJSON,
+		},
+		"type wildcard": {
+			accept:   "text/*",
+			fallback: errorContentTypeJSON,
+			expected: errorContentTypeHTML,
+		},
+		"type wildcard with fallback": {
+			accept:   "text/*",
+			fallback: errorContentTypeText,
+			expected: errorContentTypeText,
+		},
+		"more specific range wins": {
+			accept:   "text/*, text/html;q=0",
+			fallback: errorContentTypeJSON,
+			expected: errorContentTypeText,
+		},
+		"nothing acceptable": {
+			accept:   "image/png",
+			fallback: errorContentTypeHTML,
+			expected: errorContentTypeHTML,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, negotiateContentType(tc.accept, errorContentTypes, tc.fallback))
+			},
+		)
+	}
+}
+
+func TestNegotiatedErrors(t *testing.T) {
+	t.Parallel()
+
+	router := http.NewServeMux()
+	router.Handle(
+		"GET /orders",
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				writeGatewayError(w, r, "Service Unavailable", http.StatusServiceUnavailable)
+			},
+		),
+	)
+
+	tests := map[string]struct {
+		defaultContentType  string
+		method              string
+		path                string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedAllow       string
+		expectedBody        string
+	}{
+		"default content type": {
+			defaultContentType:  errorContentTypeJSON,
+			method:              http.MethodGet,
+			path:                "/orders",
+			accept:              "*/*",
+			expectedStatus:      http.StatusServiceUnavailable,
+			expectedContentType: "application/json",
+			expectedBody:        `{"message":"Service Unavailable"}` + "\n",
+		},
+		"plain text": {
+			defaultContentType:  errorContentTypeJSON,
+			method:              http.MethodGet,
+			path:                "/orders",
+			accept:              "text/plain",
+			expectedStatus:      http.StatusServiceUnavailable,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "Service Unavailable\n",
+		},
+		"unknown route": {
+			defaultContentType:  errorContentTypeText,
+			method:              http.MethodGet,
+			path:                "/customers",
+			accept:              "application/json",
+			expectedStatus:      http.StatusForbidden,
+			expectedContentType: "application/json",
+			expectedBody:        `{"message":"Missing Authentication Token"}` + "\n",
+		},
+		"method not allowed": {
+			defaultContentType:  errorContentTypeText,
+			method:              http.MethodPost,
+			path:                "/orders",
+			accept:              "text/html",
+			expectedStatus:      http.StatusForbidden,
+			expectedContentType: "text/html; charset=utf-8",
+			expectedAllow:       "GET, HEAD",
+			expectedBody: "<!DOCTYPE html>\n<html><head><title>403 Forbidden</title></head>" +
+				"<body><h1>403 Forbidden</h1><p>Missing Authentication Token</p></body></html>\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				req := httptest.NewRequest(tc.method, tc.path, nil)
+				req.Header.Set("Accept", tc.accept)
+
+				rr := httptest.NewRecorder()
+				negotiatedErrors(router, tc.defaultContentType).ServeHTTP(rr, req)
+
+				assert.Equal(t, tc.expectedStatus, rr.Code)
+				assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedAllow, rr.Header().Get("Allow"))
+				assert.Equal(t, tc.expectedBody, rr.Body.String())
+			},
+		)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	serverlessApplicationType = "AWS::Serverless::Application"
+	cfnStackType              = "AWS::CloudFormation::Stack"
+
+	// maxNestedStackDepth limits how deep nested stacks are followed.
+	maxNestedStackDepth = 10
+)
+
+// nestedStack is a nested stack or application with a local template.
+type nestedStack struct {
+	name string
+	path string
+	// parameters are the Parameters passed to the nested template.
+	parameters map[string]string
+}
+
+// nestedStacks returns the AWS::Serverless::Application and AWS::CloudFormation::Stack resources of
+// a template whose template is a local file, relative to templatePath. Applications from the
+// Serverless Application Repository and templates in S3 are skipped.
+func nestedStacks(templatePath string, template samTemplate) []nestedStack {
+	var stacks []nestedStack
+
+	for name, resource := range template.Resources {
+		var location string
+
+		switch resource.Type {
+		case serverlessApplicationType:
+			// a map is an ApplicationId and SemanticVersion of the Serverless Application Repository
+			location, _ = resource.Properties.Location.(string)
+		case cfnStackType:
+			location = resource.Properties.TemplateURL
+		default:
+			continue
+		}
+
+		if location == "" || strings.Contains(location, "://") {
+			continue
+		}
+
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(filepath.Dir(templatePath), location)
+		}
+
+		stacks = append(
+			stacks, nestedStack{
+				name:       name,
+				path:       location,
+				parameters: nestedStackParameters(resource.Properties.Parameters),
+			},
+		)
+	}
+
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].name < stacks[j].name })
+
+	return stacks
+}
+
+// nestedStackParameters converts the Parameters of a nested stack to parameter overrides. Lists are
+// passed like CommaDelimitedList parameters, values that aren't resolved locally, like !GetAtt,
+// are left to the defaults of the nested template.
+func nestedStackParameters(parameters map[string]any) map[string]string {
+	overrides := make(map[string]string, len(parameters))
+
+	for name, value := range parameters {
+		switch v := value.(type) {
+		case string, int, float64, bool:
+			overrides[name] = fmt.Sprint(v)
+		case []any:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+
+			overrides[name] = strings.Join(values, ",")
+		}
+	}
+
+	return overrides
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplateNestedStacks(t *testing.T) {
+	t.Parallel()
+
+	parent := `
+Parameters:
+  Stage:
+    Type: String
+    Default: dev
+Resources:
+  HelloFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Hello:
+          Type: Api
+          Properties:
+            Path: /hello
+            Method: get
+  Orders:
+    Type: AWS::Serverless::Application
+    Properties:
+      Location: ./orders/template.yaml
+      Parameters:
+        Stage: !Ref Stage
+        Origins: [a.example.com, b.example.com]
+  Users:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: users.yaml
+  Repository:
+    Type: AWS::Serverless::Application
+    Properties:
+      Location:
+        ApplicationId: arn:aws:serverlessrepo:us-east-1:123456789012:applications/app
+        SemanticVersion: 1.0.0
+  Remote:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: https://s3.amazonaws.com/bucket/template.yaml
+`
+
+	orders := `
+Parameters:
+  Stage:
+    Type: String
+  Version:
+    Type: String
+    Default: "2.0"
+Resources:
+  OrderFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Orders:
+          Type: HttpApi
+          Properties:
+            Path: !Sub /${Stage}/orders
+            Method: post
+            PayloadFormatVersion: !Ref Version
+`
+
+	users := `
+Resources:
+  UserFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Users:
+          Type: Api
+          Properties:
+            Path: /users
+            Method: get
+`
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "stack/template.yaml").Return([]byte(parent), nil).Once()
+	mockReader.On("read", "stack/orders/template.yaml").Return([]byte(orders), nil).Once()
+	mockReader.On("read", "stack/users.yaml").Return([]byte(users), nil).Once()
+
+	routes, err := parseTemplate("stack/template.yaml", mockReader, map[string]string{"Stage": "prod"})
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]apiRoute{
+			{method: "GET", path: "/hello", function: "HelloFunction", payloadFormat: payloadFormatV1},
+			{method: "POST", path: "/prod/orders", function: "OrderFunction", payloadFormat: payloadFormatV2},
+			{method: "GET", path: "/users", function: "UserFunction", payloadFormat: payloadFormatV1},
+		},
+		routes,
+	)
+	mockReader.AssertExpectations(t)
+}
+
+func TestParseTemplateNestedStackCycle(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Resources:
+  Self:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: template.yaml
+`
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "template.yaml").Return([]byte(template), nil)
+
+	_, err := parseTemplate("template.yaml", mockReader, nil)
+
+	assert.ErrorContains(t, err, "nested stacks are more than 10 levels deep, does template.yaml nest itself?")
+}
+
+func TestNestedStackParameters(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(
+		t,
+		map[string]string{"Stage": "prod", "Count": "2", "Enabled": "true", "Origins": "a.example.com,b.example.com"},
+		nestedStackParameters(
+			map[string]any{
+				"Stage":   "prod",
+				"Count":   2,
+				"Enabled": true,
+				"Origins": []any{"a.example.com", "b.example.com"},
+				"Arn":     map[string]any{"Fn::GetAtt": []any{"Fn", "Arn"}},
+			},
+		),
+	)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// openAPIAnyMethod is the operation of OpenAPI paths that accepts every method.
+	openAPIAnyMethod = "x-amazon-apigateway-any-method"
+	// openAPIIntegration is the extension that connects an operation to its lambda.
+	openAPIIntegration = "x-amazon-apigateway-integration"
+	// openAPIBinaryMediaTypes is the OpenAPI equivalent of the BinaryMediaTypes property.
+	openAPIBinaryMediaTypes = "x-amazon-apigateway-binary-media-types"
+	// openAPIProxyIntegration is the only integration type lambdalocal can emulate.
+	openAPIProxyIntegration = "aws_proxy"
+)
+
+// openAPIMethods are the operations of an OpenAPI path item that become routes.
+var openAPIMethods = map[string]string{ //nolint:gochecknoglobals
+	"get":            "GET",
+	"put":            "PUT",
+	"post":           "POST",
+	"delete":         "DELETE",
+	"options":        "OPTIONS",
+	"head":           "HEAD",
+	"patch":          "PATCH",
+	openAPIAnyMethod: "",
+}
+
+// integrationFunctionRegex finds the function of an integration uri written with Fn::Sub, like
+// arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${HelloFn.Arn}/invocations.
+var integrationFunctionRegex = regexp.MustCompile( //nolint:gochecknoglobals
+	`functions/\$\{([A-Za-z0-9]+)(?:\.Arn)?\}/invocations`,
+)
+
+// openAPIOperation is an operation of an OpenAPI definition that invokes a lambda.
+type openAPIOperation struct {
+	// method is empty for x-amazon-apigateway-any-method.
+	method   string
+	path     string
+	function string
+	// payloadFormatVersion is only set by HttpApi integrations.
+	payloadFormatVersion string
+}
+
+// openAPIDefinition returns the OpenAPI definition of an API resource, from its DefinitionBody or
+// from a local DefinitionUri or AWS::Include location relative to the template. Definitions in S3
+// can't be read and return nil.
+func openAPIDefinition(templatePath string, body, uri any, reader fileReader) (map[string]any, error) {
+	if definition, ok := body.(map[string]any); ok {
+		transform, ok := definition["Fn::Transform"].(map[string]any)
+		if !ok {
+			return definition, nil
+		}
+
+		// DefinitionBody: {Fn::Transform: {Name: AWS::Include, Parameters: {Location: ./api.yaml}}}
+		parameters, _ := transform["Parameters"].(map[string]any)
+		uri = parameters["Location"]
+	}
+
+	location, ok := uri.(string)
+	if !ok || location == "" || strings.HasPrefix(location, "s3://") {
+		return nil, nil //nolint:nilnil
+	}
+
+	data, err := reader.read(filepath.Join(filepath.Dir(templatePath), location))
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.openAPIDefinition] read file failed: %w", err)
+	}
+
+	var definition map[string]any
+	if err = yaml.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.openAPIDefinition] unmarshal definition failed: %w", err)
+	}
+
+	return definition, nil
+}
+
+// openAPIOperations returns the operations of a definition with a lambda proxy integration, sorted
+// by path and method. Operations of other integrations are skipped.
+func openAPIOperations(definition map[string]any) []openAPIOperation {
+	paths, _ := definition["paths"].(map[string]any)
+
+	var operations []openAPIOperation
+
+	for path, item := range paths {
+		methods, _ := item.(map[string]any)
+
+		for key, value := range methods {
+			method, ok := openAPIMethods[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+
+			operation, _ := value.(map[string]any)
+			integration, _ := operation[openAPIIntegration].(map[string]any)
+
+			integrationType, _ := integration["type"].(string)
+			if !strings.EqualFold(integrationType, openAPIProxyIntegration) {
+				continue
+			}
+
+			function := integrationFunction(integration["uri"])
+			if function == "" {
+				continue
+			}
+
+			payloadFormatVersion, _ := integration["payloadFormatVersion"].(string)
+
+			operations = append(
+				operations, openAPIOperation{
+					method:               method,
+					path:                 path,
+					function:             function,
+					payloadFormatVersion: payloadFormatVersion,
+				},
+			)
+		}
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].path != operations[j].path {
+			return operations[i].path < operations[j].path
+		}
+
+		return operations[i].method < operations[j].method
+	})
+
+	return operations
+}
+
+// integrationFunction returns the logical ID of the function an integration uri invokes. The uri
+// is written with Fn::Sub, or Fn::Join with Fn::GetAtt, plain ARNs can't be mapped to a function.
+func integrationFunction(uri any) string {
+	switch value := uri.(type) {
+	case string:
+		if match := integrationFunctionRegex.FindStringSubmatch(value); match != nil {
+			return match[1]
+		}
+	case []any:
+		// the short form of Fn::Sub with variables
+		if len(value) > 0 {
+			return integrationFunction(value[0])
+		}
+	case map[string]any:
+		if sub, ok := value["Fn::Sub"]; ok {
+			return integrationFunction(sub)
+		}
+
+		join, _ := value["Fn::Join"].([]any)
+		if len(join) != 2 { //nolint:mnd
+			return ""
+		}
+
+		parts, _ := join[1].([]any)
+		for _, part := range parts {
+			partMap, _ := part.(map[string]any)
+
+			switch getAtt := partMap["Fn::GetAtt"].(type) {
+			case []any:
+				if len(getAtt) > 0 {
+					name, _ := getAtt[0].(string)
+
+					return name
+				}
+			case string:
+				return strings.TrimSuffix(getAtt, ".Arn")
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDefinition(t *testing.T) {
+	t.Parallel()
+
+	definitionFile := []byte(`
+openapi: "3.0.1"
+paths:
+  /hello:
+    get: {}
+`)
+
+	tests := map[string]struct {
+		body           any
+		uri            any
+		mockInput      string
+		mockReturn     []any
+		expectedPaths  []string
+		expectedErrStr string
+	}{
+		"definition body": {
+			body:          map[string]any{"paths": map[string]any{"/inline": map[string]any{}}},
+			expectedPaths: []string{"/inline"},
+		},
+		"definition uri": {
+			uri:           "api/openapi.yaml",
+			mockInput:     "templates/api/openapi.yaml",
+			mockReturn:    []any{definitionFile, nil},
+			expectedPaths: []string{"/hello"},
+		},
+		"aws include": {
+			body: map[string]any{
+				"Fn::Transform": map[string]any{
+					"Name":       "AWS::Include",
+					"Parameters": map[string]any{"Location": "openapi.yaml"},
+				},
+			},
+			mockInput:     "templates/openapi.yaml",
+			mockReturn:    []any{definitionFile, nil},
+			expectedPaths: []string{"/hello"},
+		},
+		"definition in s3": {
+			uri:           "s3://bucket/openapi.yaml",
+			expectedPaths: nil,
+		},
+		"no definition": {
+			expectedPaths: nil,
+		},
+		"missing file": {
+			uri:            "openapi.yaml",
+			mockInput:      "templates/openapi.yaml",
+			mockReturn:     []any{[]byte{}, errors.New("file not found")},
+			expectedErrStr: "[in lambdalocal.openAPIDefinition] read file failed: file not found",
+		},
+		"invalid file": {
+			uri:            "openapi.yaml",
+			mockInput:      "templates/openapi.yaml",
+			mockReturn:     []any{[]byte("paths: ["), nil},
+			expectedErrStr: "[in lambdalocal.openAPIDefinition] unmarshal definition failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				if tc.mockInput != "" {
+					mockReader.On("read", tc.mockInput).Return(tc.mockReturn...).Once()
+				}
+
+				definition, err := openAPIDefinition("templates/template.yaml", tc.body, tc.uri, mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				mockReader.AssertExpectations(t)
+
+				var paths []string
+
+				if pathItems, ok := definition["paths"].(map[string]any); ok {
+					for path := range pathItems {
+						paths = append(paths, path)
+					}
+				}
+
+				assert.Equal(t, tc.expectedPaths, paths)
+			},
+		)
+	}
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	t.Parallel()
+
+	integration := func(uri any) map[string]any {
+		return map[string]any{
+			openAPIIntegration: map[string]any{"type": "AWS_PROXY", "uri": uri},
+		}
+	}
+
+	definition := map[string]any{
+		"paths": map[string]any{
+			"/orders": map[string]any{
+				"parameters": []any{},
+				"post": integration(
+					"arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${OrderFn.Arn}/invocations",
+				),
+				"get": integration("functions/${OrderFn}/invocations"),
+				"options": map[string]any{
+					openAPIIntegration: map[string]any{"type": "mock"},
+				},
+			},
+			"/{proxy+}": map[string]any{
+				openAPIAnyMethod: map[string]any{
+					openAPIIntegration: map[string]any{
+						"type":                 "aws_proxy",
+						"payloadFormatVersion": "2.0",
+						"uri":                  "arn:aws:lambda:us-east-1:123456789012:function:Unknown",
+					},
+				},
+			},
+			"/users": map[string]any{
+				"delete": integration(map[string]any{
+					"Fn::Join": []any{"", []any{
+						"arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/",
+						map[string]any{"Fn::GetAtt": []any{"UserFn", "Arn"}},
+						"/invocations",
+					}},
+				}),
+			},
+		},
+	}
+
+	assert.Equal(
+		t,
+		[]openAPIOperation{
+			{method: "GET", path: "/orders", function: "OrderFn"},
+			{method: "POST", path: "/orders", function: "OrderFn"},
+			{method: "DELETE", path: "/users", function: "UserFn"},
+		},
+		openAPIOperations(definition),
+	)
+}
+
+func TestIntegrationFunction(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uri      any
+		expected string
+	}{
+		"sub": {
+			uri:      "arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${HelloFn.Arn}/invocations",
+			expected: "HelloFn",
+		},
+		"sub with variables": {
+			uri: []any{
+				"arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${HelloFn.Arn}/invocations",
+				map[string]any{},
+			},
+			expected: "HelloFn",
+		},
+		"long form sub": {
+			uri: map[string]any{
+				"Fn::Sub": "arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/" +
+					"${HelloFn.Arn}/invocations",
+			},
+			expected: "HelloFn",
+		},
+		"join with short get att": {
+			uri: map[string]any{
+				"Fn::Join": []any{"", []any{"functions/", map[string]any{"Fn::GetAtt": "HelloFn.Arn"}, "/invocations"}},
+			},
+			expected: "HelloFn",
+		},
+		"plain arn": {
+			uri: "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/" +
+				"arn:aws:lambda:us-east-1:123456789012:function:hello/invocations",
+			expected: "",
+		},
+		"missing": {
+			uri:      nil,
+			expected: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, integrationFunction(tc.uri))
+			},
+		)
+	}
+}
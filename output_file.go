@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+)
+
+// responsePayload returns the payload of an invocation as the Lambda API returns it, the error of
+// failed invocations.
+func responsePayload(invokeResponse messages.InvokeResponse) ([]byte, error) {
+	if invokeResponse.Error == nil {
+		return invokeResponse.Payload, nil
+	}
+
+	data, err := json.Marshal(invokeResponse.Error)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.responsePayload] marshal error failed: %w", err)
+	}
+
+	return data, nil
+}
+
+// writeResponsePayload writes the raw payload of invokeResponse to path, so it can be kept as a
+// golden file or inspected outside the logs.
+func writeResponsePayload(path string, invokeResponse messages.InvokeResponse) error {
+	data, err := responsePayload(invokeResponse)
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(path, data, 0o644); err != nil { //nolint:mnd,gosec
+		return fmt.Errorf("[in lambdalocal.writeResponsePayload] write '%s' failed: %w", path, err)
+	}
+
+	return nil
+}
+
+// payloadDump writes the payload of every invocation of the routes to a directory, in a file
+// named by the request id of the invocation.
+type payloadDump struct {
+	dir    string
+	logger *slog.Logger
+}
+
+func newPayloadDump(dir string, logger *slog.Logger) (*payloadDump, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:mnd
+		return nil, fmt.Errorf("[in lambdalocal.newPayloadDump] create '%s' failed: %w", dir, err)
+	}
+
+	return &payloadDump{dir: dir, logger: logger}, nil
+}
+
+// caller returns a caller that writes the payloads of the invocations of caller.
+func (d *payloadDump) caller(caller lambdaCaller) lambdaCaller {
+	return payloadDumpCaller{lambdaCaller: caller, dump: d}
+}
+
+type payloadDumpCaller struct {
+	lambdaCaller
+	dump *payloadDump
+}
+
+func (c payloadDumpCaller) Invoke(
+	ctx context.Context,
+	data []byte,
+	options ...Option,
+) (messages.InvokeResponse, error) {
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+	if err != nil {
+		return response, err //nolint:wrapcheck
+	}
+
+	requestID := newInvokeOptions(options).requestID
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	// the request is answered even when its payload can't be written
+	path := filepath.Join(c.dump.dir, requestID+".json")
+	if err = writeResponsePayload(path, response); err != nil {
+		c.dump.logger.Error("[in lambdalocal.payloadDumpCaller.Invoke] writeResponsePayload failed", "err", err)
+	} else {
+		c.dump.logger.Debug("Wrote response payload", "path", path)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResponsePayload(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		response messages.InvokeResponse
+		expected string
+	}{
+		"payload is written as returned": {
+			response: messages.InvokeResponse{Payload: []byte(`{"id":9007199254740993,  "b":1,"a":2}`)},
+			expected: `{"id":9007199254740993,  "b":1,"a":2}`,
+		},
+		"error": {
+			response: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "boom", Type: "errorString"},
+			},
+			expected: `{"errorMessage":"boom","errorType":"errorString"}`,
+		},
+		"no payload": {
+			response: messages.InvokeResponse{},
+			expected: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				path := filepath.Join(t.TempDir(), "result.json")
+				require.NoError(t, writeResponsePayload(path, tc.response))
+
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, string(data))
+			},
+		)
+	}
+}
+
+func TestPayloadDumpCaller(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "payloads")
+
+	dump, err := newPayloadDump(dir, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	caller := dump.caller(staticLambdaCaller{response: messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}})
+
+	response, err := caller.Invoke(context.Background(), []byte(`{}`), WithRequestID("request-1"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"statusCode":200}`, string(response.Payload))
+
+	data, err := os.ReadFile(filepath.Join(dir, "request-1.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"statusCode":200}`, string(data))
+
+	// failed invocations have no payload to write
+	mockLambdaRPC := new(MockLambdaCaller)
+	mockLambdaRPC.On("Invoke", []byte(`{}`)).Return(messages.InvokeResponse{}, errors.New("connection refused"))
+
+	_, err = dump.caller(mockLambdaRPC).Invoke(context.Background(), []byte(`{}`), WithRequestID("request-2"))
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "request-2.json"))
+}
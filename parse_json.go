@@ -1 +1,183 @@
 package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+// unmarshalJSON decodes data into v like json.Unmarshal, but keeps numbers as json.Number so
+// integers beyond the 53 bits of a float64, like 64-bit IDs, are printed as they were returned.
+func unmarshalJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// like json.Unmarshal, data must hold a single value
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid character after top-level value")
+	}
+
+	return nil
+}
+
+// orderedField is a key and value of a JSON object.
+type orderedField struct {
+	key   string
+	value any
+}
+
+// orderedObject is a JSON object that keeps the order of its keys, unlike map[string]any which is
+// encoded with sorted keys.
+type orderedObject []orderedField
+
+// MarshalJSON encodes the object with its keys in order.
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// sortKeys sorts the keys of value and of the objects it holds.
+func sortKeys(value any) any {
+	switch v := value.(type) {
+	case orderedObject:
+		for i := range v {
+			v[i].value = sortKeys(v[i].value)
+		}
+
+		slices.SortStableFunc(
+			v, func(a, b orderedField) int {
+				return strings.Compare(a.key, b.key)
+			},
+		)
+	case []any:
+		for i := range v {
+			v[i] = sortKeys(v[i])
+		}
+	}
+
+	return value
+}
+
+// floatNumbers replaces the json.Number values of a value decoded with unmarshalOrderedJSON with
+// float64, like json.Unmarshal decodes them.
+func floatNumbers(value any) any {
+	switch v := value.(type) {
+	case orderedObject:
+		for i := range v {
+			v[i].value = floatNumbers(v[i].value)
+		}
+	case []any:
+		for i := range v {
+			v[i] = floatNumbers(v[i])
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+
+	return value
+}
+
+// unmarshalOrderedJSON decodes data like unmarshalJSON, with objects decoded into an orderedObject
+// so they are printed with their keys in the order they were returned.
+func unmarshalOrderedJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodeOrderedJSON(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// like json.Unmarshal, data must hold a single value
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid character after top-level value")
+	}
+
+	return value, nil
+}
+
+func decodeOrderedJSON(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		object := orderedObject{}
+
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			object = append(object, orderedField{key: key.(string), value: value}) //nolint:forcetypeassert
+		}
+
+		// the closing brace
+		_, err = decoder.Token()
+
+		return object, err //nolint:wrapcheck
+	default:
+		array := []any{}
+
+		for decoder.More() {
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		// the closing bracket
+		_, err = decoder.Token()
+
+		return array, err //nolint:wrapcheck
+	}
+}
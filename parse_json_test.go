@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data     string
+		expected any
+		wantErr  bool
+	}{
+		"64-bit integers": {
+			data: `{"id":9007199254740993,"max":18446744073709551615,"negative":-9223372036854775808}`,
+			expected: map[string]any{
+				"id":       json.Number("9007199254740993"),
+				"max":      json.Number("18446744073709551615"),
+				"negative": json.Number("-9223372036854775808"),
+			},
+		},
+		"floats keep their digits": {
+			data:     `[1.10, 1e21, 0.1]`,
+			expected: []any{json.Number("1.10"), json.Number("1e21"), json.Number("0.1")},
+		},
+		"nested": {
+			data:     `{"items":[{"id":1234567890123456789}]}`,
+			expected: map[string]any{"items": []any{map[string]any{"id": json.Number("1234567890123456789")}}},
+		},
+		"trailing whitespace": {
+			data:     "{\"id\":1}\n",
+			expected: map[string]any{"id": json.Number("1")},
+		},
+		"trailing value": {
+			data:    `{"id":1} {"id":2}`,
+			wantErr: true,
+		},
+		"invalid": {
+			data:    `{"id":`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var actual any
+
+				err := unmarshalJSON([]byte(tc.data), &actual)
+				if tc.wantErr {
+					assert.Error(t, err)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+
+				// encoding the decoded value again returns the numbers as they were
+				encoded, err := json.Marshal(actual)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.data, string(encoded))
+			},
+		)
+	}
+}
+
+func TestUnmarshalOrderedJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data     string
+		sorted   string
+		expected string
+	}{
+		"keys keep their order": {
+			data:     `{"b":1,"a":{"d":[{"f":1,"e":2}],"c":null},"id":9007199254740993}`,
+			expected: `{"b":1,"a":{"d":[{"f":1,"e":2}],"c":null},"id":9007199254740993}`,
+			sorted:   `{"a":{"c":null,"d":[{"e":2,"f":1}]},"b":1,"id":9007199254740993}`,
+		},
+		"array": {
+			data:     `[{"b":true,"a":"x"},2]`,
+			expected: `[{"b":true,"a":"x"},2]`,
+			sorted:   `[{"a":"x","b":true},2]`,
+		},
+		"empty object and array": {
+			data:     `{"b":{},"a":[]}`,
+			expected: `{"b":{},"a":[]}`,
+			sorted:   `{"a":[],"b":{}}`,
+		},
+		"escaped keys": {
+			data:     `{"é\"":1,"a\\b":2}`,
+			expected: `{"é\"":1,"a\\b":2}`,
+			sorted:   `{"a\\b":2,"é\"":1}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				value, err := unmarshalOrderedJSON([]byte(tc.data))
+				require.NoError(t, err)
+
+				encoded, err := json.Marshal(value)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, string(encoded))
+
+				sorted, err := json.Marshal(sortKeys(value))
+				require.NoError(t, err)
+				assert.Equal(t, tc.sorted, string(sorted))
+			},
+		)
+	}
+}
+
+func TestUnmarshalOrderedJSONInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{`{"a":`, `{"a":1}}`, `[1,]`, ``} {
+		_, err := unmarshalOrderedJSON([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
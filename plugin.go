@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// pluginHookEvent transforms the event before the lambda is invoked with it.
+	pluginHookEvent = "transform_event"
+	// pluginHookResponse transforms the payload the lambda responded with.
+	pluginHookResponse = "transform_response"
+	// pluginAlloc allocates memory in a plugin for the payload passed to a hook.
+	pluginAlloc = "alloc"
+)
+
+// wasmPlugin is a WASM module that transforms events and responses. Plugins export memory, an
+// alloc(size i32) i32 function and the hooks they implement, transform_event and
+// transform_response. A hook is called with the pointer and length of the payload and returns the
+// pointer of the new payload in the upper and its length in the lower 32 bits of an i64, or 0 to
+// keep the payload. A hook fails the invocation by trapping.
+type wasmPlugin struct {
+	name   string
+	module api.Module
+	// mu serializes calls, a module instance isn't safe for concurrent use.
+	mu sync.Mutex
+}
+
+// plugins is the chain of WASM plugins invocations pass through, in order.
+type plugins struct {
+	runtime wazero.Runtime
+	chain   []*wasmPlugin
+}
+
+// loadPlugins compiles and instantiates the WASM plugins at paths. WASI is available to them, so
+// plugins built for wasip1 work, and their start function is not run, they are used as reactors.
+func loadPlugins(ctx context.Context, paths []string, reader fileReader) (*plugins, error) {
+	if len(paths) == 0 {
+		return &plugins{}, nil
+	}
+
+	runtime := wazero.NewRuntime(ctx)
+	loaded := &plugins{runtime: runtime}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		loaded.Close(ctx)
+
+		return nil, fmt.Errorf("[in lambdalocal.loadPlugins] instantiate WASI failed: %w", err)
+	}
+
+	for _, path := range paths {
+		plugin, err := loaded.load(ctx, path, reader)
+		if err != nil {
+			loaded.Close(ctx)
+
+			return nil, fmt.Errorf("[in lambdalocal.loadPlugins] plugin '%s': %w", path, err)
+		}
+
+		loaded.chain = append(loaded.chain, plugin)
+	}
+
+	return loaded, nil
+}
+
+func (p *plugins) load(ctx context.Context, path string, reader fileReader) (*wasmPlugin, error) {
+	data, err := reader.read(path)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+
+	compiled, err := p.runtime.CompileModule(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("compile failed: %w", err)
+	}
+
+	name := filepath.Base(path)
+
+	module, err := p.runtime.InstantiateModule(
+		ctx,
+		compiled,
+		wazero.NewModuleConfig().WithName(name).WithStartFunctions("_initialize"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("instantiate failed: %w", err)
+	}
+
+	if module.Memory() == nil || module.ExportedFunction(pluginAlloc) == nil {
+		return nil, errors.New("plugins must export memory and alloc")
+	}
+
+	if module.ExportedFunction(pluginHookEvent) == nil && module.ExportedFunction(pluginHookResponse) == nil {
+		return nil, fmt.Errorf("plugins must export %s or %s", pluginHookEvent, pluginHookResponse)
+	}
+
+	return &wasmPlugin{name: name, module: module}, nil
+}
+
+// Close releases the plugins.
+func (p *plugins) Close(ctx context.Context) {
+	if p.runtime != nil {
+		_ = p.runtime.Close(ctx)
+	}
+}
+
+// caller returns a lambdaCaller that passes events and responses of caller through the plugins.
+// Without plugins caller is returned as is.
+func (p *plugins) caller(caller lambdaCaller) lambdaCaller {
+	if len(p.chain) == 0 {
+		return caller
+	}
+
+	return pluginCaller{lambdaCaller: caller, plugins: p}
+}
+
+type pluginCaller struct {
+	lambdaCaller
+	plugins *plugins
+}
+
+func (c pluginCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	for _, plugin := range c.plugins.chain {
+		var err error
+
+		if data, err = plugin.call(ctx, pluginHookEvent, data); err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.pluginCaller.Invoke] %w", err)
+		}
+	}
+
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+	if err != nil || response.Error != nil {
+		return response, err //nolint:wrapcheck
+	}
+
+	for _, plugin := range c.plugins.chain {
+		if response.Payload, err = plugin.call(ctx, pluginHookResponse, response.Payload); err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.pluginCaller.Invoke] %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// call passes payload to hook and returns the payload it returned. Plugins without hook keep the
+// payload.
+func (p *wasmPlugin) call(ctx context.Context, hook string, payload []byte) ([]byte, error) {
+	function := p.module.ExportedFunction(hook)
+	if function == nil {
+		return payload, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	allocated, err := p.module.ExportedFunction(pluginAlloc).Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed to allocate %d bytes: %w", p.name, len(payload), err)
+	}
+
+	ptr := uint32(allocated[0])
+
+	if !p.module.Memory().Write(ptr, payload) {
+		return nil, fmt.Errorf("plugin %s allocated memory out of range", p.name)
+	}
+
+	results, err := function.Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed in %s: %w", p.name, hook, err)
+	}
+
+	if results[0] == 0 {
+		return payload, nil
+	}
+
+	transformed, ok := p.module.Memory().Read(uint32(results[0]>>32), uint32(results[0])) //nolint:mnd
+	if !ok {
+		return nil, fmt.Errorf("plugin %s returned a payload out of range from %s", p.name, hook)
+	}
+
+	// the memory belongs to the plugin, it may be reused by the next call
+	return append([]byte(nil), transformed...), nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPlugin assembles a WASM plugin whose transform_event returns event, or traps when event is
+// empty, and whose transform_response returns the response it is called with.
+func testPlugin(event string) []byte {
+	uleb := func(n uint64) []byte {
+		var out []byte
+
+		for {
+			b := byte(n & 0x7f)
+			n >>= 7
+
+			if n == 0 {
+				return append(out, b)
+			}
+
+			out = append(out, b|0x80)
+		}
+	}
+
+	vec := func(items ...[]byte) []byte {
+		out := uleb(uint64(len(items)))
+		for _, item := range items {
+			out = append(out, item...)
+		}
+
+		return out
+	}
+
+	name := func(s string) []byte {
+		return append(uleb(uint64(len(s))), s...)
+	}
+
+	section := func(id byte, content []byte) []byte {
+		return append(append([]byte{id}, uleb(uint64(len(content)))...), content...)
+	}
+
+	body := func(code ...byte) []byte {
+		code = append([]byte{0x00}, code...) // no locals
+
+		return append(uleb(uint64(len(code))), code...)
+	}
+
+	// the event is stored at offset 16, allocations start at 1024
+	eventHook := body(0x00, 0x0b) // unreachable
+	if event != "" {
+		// i64.const 16<<32 | len(event), small enough to be encoded as an unsigned LEB128
+		eventHook = body(append(append([]byte{0x42}, uleb(16<<32|uint64(len(event)))...), 0x0b)...)
+	}
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(1, vec(
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},       // (i32) -> i32
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e}, // (i32, i32) -> i64
+	))...)
+	module = append(module, section(3, vec([]byte{0x00}, []byte{0x01}, []byte{0x01}))...)
+	module = append(module, section(5, vec([]byte{0x00, 0x01}))...)
+	module = append(module, section(6, vec([]byte{0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b}))...)
+	module = append(module, section(7, vec(
+		append(name("memory"), 0x02, 0x00),
+		append(name(pluginAlloc), 0x00, 0x00),
+		append(name(pluginHookEvent), 0x00, 0x01),
+		append(name(pluginHookResponse), 0x00, 0x02),
+	))...)
+	module = append(module, section(10, vec(
+		// alloc bumps the heap pointer and returns its previous value
+		body(0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b),
+		eventHook,
+		// returns ptr<<32 | len
+		body(0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b),
+	))...)
+	module = append(module, section(11, vec(append([]byte{0x00, 0x41, 0x10, 0x0b}, name(event)...)))...)
+
+	return module
+}
+
+func TestPluginCaller(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		plugins          map[string][]byte
+		lambdaResponse   messages.InvokeResponse
+		lambdaErr        error
+		expectedEvent    string
+		expectedResponse messages.InvokeResponse
+		expectedErrStr   string
+	}{
+		"event and response are transformed": {
+			plugins:          map[string][]byte{"tenant.wasm": testPlugin(`{"tenant":"acme"}`)},
+			lambdaResponse:   messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)},
+			expectedEvent:    `{"tenant":"acme"}`,
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)},
+		},
+		"plugins run in order": {
+			plugins: map[string][]byte{
+				"first.wasm":  testPlugin(`{"first":true}`),
+				"second.wasm": testPlugin(`{"second":true}`),
+			},
+			lambdaResponse:   messages.InvokeResponse{Payload: []byte(`{}`)},
+			expectedEvent:    `{"second":true}`,
+			expectedResponse: messages.InvokeResponse{Payload: []byte(`{}`)},
+		},
+		"function errors are not transformed": {
+			plugins: map[string][]byte{"tenant.wasm": testPlugin(`{"tenant":"acme"}`)},
+			lambdaResponse: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "boom"},
+			},
+			expectedEvent: `{"tenant":"acme"}`,
+			expectedResponse: messages.InvokeResponse{
+				Error: &messages.InvokeResponse_Error{Message: "boom"},
+			},
+		},
+		"invoke errors are returned": {
+			plugins:        map[string][]byte{"tenant.wasm": testPlugin(`{"tenant":"acme"}`)},
+			lambdaErr:      errors.New("connection refused"),
+			expectedEvent:  `{"tenant":"acme"}`,
+			expectedErrStr: "connection refused",
+		},
+		"trapping plugin fails the invocation": {
+			plugins:        map[string][]byte{"trap.wasm": testPlugin("")},
+			expectedErrStr: "[in lambdalocal.pluginCaller.Invoke] plugin trap.wasm failed in transform_event",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				ctx := context.Background()
+
+				var paths []string
+
+				mockReader := new(mockOSFileReader)
+
+				for _, path := range []string{"first.wasm", "second.wasm", "tenant.wasm", "trap.wasm"} {
+					if module, ok := tc.plugins[path]; ok {
+						mockReader.On("read", path).Return(module, nil)
+
+						paths = append(paths, path)
+					}
+				}
+
+				plugins, err := loadPlugins(ctx, paths, mockReader)
+				require.NoError(t, err)
+				t.Cleanup(func() { plugins.Close(ctx) })
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				if tc.expectedEvent != "" {
+					mockLambdaRPC.On("Invoke", []byte(tc.expectedEvent)).Return(tc.lambdaResponse, tc.lambdaErr)
+				}
+
+				response, err := plugins.caller(mockLambdaRPC).Invoke(context.Background(), []byte(`{"path":"/"}`))
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedResponse, response)
+				mockLambdaRPC.AssertExpectations(t)
+			},
+		)
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "invalid.wasm").Return([]byte("not wasm"), nil)
+
+	_, err := loadPlugins(ctx, []string{"invalid.wasm"}, mockReader)
+	assert.ErrorContains(t, err, "[in lambdalocal.loadPlugins] plugin 'invalid.wasm': compile failed")
+
+	plugins, err := loadPlugins(ctx, nil, mockReader)
+	require.NoError(t, err)
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	assert.Equal(t, mockLambdaRPC, plugins.caller(mockLambdaRPC), "callers are kept without plugins")
+
+	plugins.Close(ctx)
+}
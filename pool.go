@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// maxPooledBufferSize is the capacity above which buffers aren't returned to the pool, so one large
+// request doesn't keep its memory for the life of the process.
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool holds the buffers events, bodies and logged payloads are built in on the hot path of
+// an invocation.
+var bufferPool = sync.Pool{ //nolint:gochecknoglobals
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool. It is returned with putBuffer once nothing
+// refers to its bytes anymore.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert
+}
+
+// putBuffer returns buf to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// invokeRequestPool and invokeResponsePool hold the messages of RPC invocations, which are only
+// used for the duration of the call.
+var (
+	invokeRequestPool = sync.Pool{ //nolint:gochecknoglobals
+		New: func() any {
+			return new(messages.InvokeRequest)
+		},
+	}
+	invokeResponsePool = sync.Pool{ //nolint:gochecknoglobals
+		New: func() any {
+			return new(messages.InvokeResponse)
+		},
+	}
+)
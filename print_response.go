@@ -1,18 +1,43 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
 )
 
+const (
+	// keyOrderInsertion prints the keys of payloads in the order the lambda returned them.
+	keyOrderInsertion = "insertion"
+	// keyOrderSorted prints the keys of payloads sorted, so payloads of different runs diff cleanly.
+	keyOrderSorted = "sorted"
+)
+
+// responseFormat is how the payloads returned by the lambda are printed.
+type responseFormat struct {
+	// parseJSON prints the string values of the payload that hold JSON, like the body of API
+	// responses, as JSON.
+	parseJSON bool
+	// sortKeys prints the keys of objects sorted instead of in the order they were returned.
+	sortKeys bool
+	// redaction is left out of the printed payloads and the logged requests.
+	redaction redaction
+	// maxLogBody is the size in bytes printed payloads are truncated to, 0 prints them whole.
+	maxLogBody int
+	// floatNumbers prints numbers decoded as float64 like encoding/json, rounding integers beyond
+	// 53 bits, instead of as they were returned.
+	floatNumbers bool
+}
+
 func printResponse(
 	logger *slog.Logger,
 	invokeResponse messages.InvokeResponse,
-	parseJSON bool,
+	format responseFormat,
 ) error {
 	logger.Debug("Handling lambda event response")
 
@@ -34,37 +59,114 @@ func printResponse(
 		return nil
 	}
 
-	response := make(map[string]any)
-	if err := json.Unmarshal(invokeResponse.Payload, &response); err != nil {
-		logger.Info("Lambda returned non-JSON payload:\n" + string(invokeResponse.Payload))
-		return nil //nolint:nilerr
+	// formatting the payload is most of the cost of an invocation, and wasted when it isn't logged
+	if !logger.Enabled(context.Background(), slog.LevelInfo) {
+		return nil
+	}
+
+	// without parsing inner JSON, sorting keys or redacting the payload is indented as it is,
+	// instead of decoded and encoded again
+	if !format.parseJSON && !format.sortKeys && !format.floatNumbers &&
+		!format.redaction.matches(invokeResponse.Payload) {
+		out := getBuffer()
+		defer putBuffer(out)
+
+		if json.Indent(out, invokeResponse.Payload, "", "    ") != nil {
+			logger.Info("Lambda returned non-JSON payload:\n" + format.logBody(invokeResponse.Payload))
+			return nil
+		}
+
+		logger.Info("Lambda returned JSON payload:\n" + format.logBody(out.Bytes()))
+
+		return nil
 	}
 
-	if parseJSON {
-		response = parseInnerJSON(response)
+	response, err := decodePayload(invokeResponse.Payload, format)
+	if err != nil {
+		logger.Info("Lambda returned non-JSON payload:\n" + format.logBody(invokeResponse.Payload))
+		return nil //nolint:nilerr
 	}
 
+	response, _ = format.redaction.payload(response)
+
 	out, err := json.MarshalIndent(response, "", "    ")
 	if err != nil {
 		return fmt.Errorf("[in lambdalocal.printResponse] MarshalIndent response failed: %w", err)
 	}
 
-	logger.Info("Lambda returned JSON payload:\n" + string(out))
+	logger.Info("Lambda returned JSON payload:\n" + format.logBody(out))
 
 	return nil
 }
 
+// logBody returns a payload for printing, truncated to maxLogBody bytes with a marker of how many
+// bytes were left out. Characters are never cut in half.
+func (f responseFormat) logBody(payload []byte) string {
+	if f.maxLogBody <= 0 || len(payload) <= f.maxLogBody {
+		return string(payload)
+	}
+
+	size := f.maxLogBody
+	for size > 0 && !utf8.RuneStart(payload[size]) {
+		size--
+	}
+
+	return fmt.Sprintf("%s... (%d bytes truncated)", payload[:size], len(payload)-size)
+}
+
+// decodePayload decodes a payload for printing in format, keeping the order of its keys unless
+// they are sorted.
+func decodePayload(payload []byte, format responseFormat) (any, error) {
+	value, err := unmarshalOrderedJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if format.parseJSON {
+		value = parseInnerValue(value)
+	}
+
+	if format.floatNumbers {
+		value = floatNumbers(value)
+	}
+
+	if format.sortKeys {
+		value = sortKeys(value)
+	}
+
+	return value, nil
+}
+
+// parseInnerValue parses the strings holding JSON of a payload of any JSON value: the fields of an
+// object, the elements of an array and its objects, or a string.
+func parseInnerValue(value any) any {
+	switch v := value.(type) {
+	case orderedObject:
+		return parseInnerJSON(v)
+	case []any:
+		for i := range v {
+			v[i] = parseInnerValue(v[i])
+		}
+	case string:
+		if inner, err := unmarshalOrderedJSON([]byte(v)); err == nil {
+			return inner
+		}
+	}
+
+	return value
+}
+
 // parseInnerJSON walks all key value pairs on response and attempt to unmarshal
 // strings to JSON.
-func parseInnerJSON(data map[string]any) map[string]any {
-	for k, v := range data {
-		if vv, ok := v.(string); ok {
-			newJSON := any(nil)
-			if err := json.Unmarshal([]byte(vv), &newJSON); err != nil {
+func parseInnerJSON(data orderedObject) orderedObject {
+	for i, field := range data {
+		if vv, ok := field.value.(string); ok {
+			newJSON, err := unmarshalOrderedJSON([]byte(vv))
+			if err != nil {
 				continue
 			}
 
-			data[k] = newJSON
+			data[i].value = newJSON
 		}
 	}
 
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"testing"
 
+	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test cases struct
 type testCase struct {
-	input    map[string]any
-	expected map[string]any
+	input    orderedObject
+	expected orderedObject
 }
 
 func TestParseInnerJSON(t *testing.T) {
@@ -17,56 +22,64 @@ func TestParseInnerJSON(t *testing.T) {
 
 	tests := map[string]testCase{
 		"nested JSON string": {
-			input: map[string]any{
-				"key1": `{"nestedKey1": "value1", "nestedKey2": 2}`,
-				"key2": "plain string",
+			input: orderedObject{
+				{key: "key1", value: `{"nestedKey1": "value1", "nestedKey2": 2}`},
+				{key: "key2", value: "plain string"},
 			},
-			expected: map[string]any{
-				"key1": map[string]any{
-					"nestedKey1": "value1",
-					"nestedKey2": 2.0,
-				},
-				"key2": "plain string",
+			expected: orderedObject{
+				{key: "key1", value: orderedObject{
+					{key: "nestedKey1", value: "value1"},
+					{key: "nestedKey2", value: json.Number("2")},
+				}},
+				{key: "key2", value: "plain string"},
 			},
 		},
 		"invalid JSON string": {
-			input: map[string]any{
-				"key1": `{"nestedKey1": "value1", "nestedKey2": 2`,
-				"key2": "plain string",
+			input: orderedObject{
+				{key: "key1", value: `{"nestedKey1": "value1", "nestedKey2": 2`},
+				{key: "key2", value: "plain string"},
 			},
-			expected: map[string]any{
-				"key1": `{"nestedKey1": "value1", "nestedKey2": 2`,
-				"key2": "plain string",
+			expected: orderedObject{
+				{key: "key1", value: `{"nestedKey1": "value1", "nestedKey2": 2`},
+				{key: "key2", value: "plain string"},
 			},
 		},
 		"non-string value": {
-			input: map[string]any{
-				"key1": 123,
-				"key2": "plain string",
+			input: orderedObject{
+				{key: "key1", value: 123},
+				{key: "key2", value: "plain string"},
 			},
-			expected: map[string]any{
-				"key1": 123,
-				"key2": "plain string",
+			expected: orderedObject{
+				{key: "key1", value: 123},
+				{key: "key2", value: "plain string"},
 			},
 		},
 		"array inside a string": {
-			input: map[string]any{
-				"key1": `["value1", "value2", "value3"]`,
-				"key2": "plain string",
+			input: orderedObject{
+				{key: "key1", value: `["value1", "value2", "value3"]`},
+				{key: "key2", value: "plain string"},
 			},
-			expected: map[string]any{
-				"key1": []any{"value1", "value2", "value3"},
-				"key2": "plain string",
+			expected: orderedObject{
+				{key: "key1", value: []any{"value1", "value2", "value3"}},
+				{key: "key2", value: "plain string"},
+			},
+		},
+		"64-bit integer in a string": {
+			input: orderedObject{
+				{key: "key1", value: `{"id": 9223372036854775807}`},
+			},
+			expected: orderedObject{
+				{key: "key1", value: orderedObject{{key: "id", value: json.Number("9223372036854775807")}}},
 			},
 		},
 		"array as a value that's a string": {
-			input: map[string]any{
-				"key1": "[1, 2, 3]",
-				"key2": `["a", "b", "c"]`,
+			input: orderedObject{
+				{key: "key1", value: "[1, 2, 3]"},
+				{key: "key2", value: `["a", "b", "c"]`},
 			},
-			expected: map[string]any{
-				"key1": []any{1.0, 2.0, 3.0},
-				"key2": []any{"a", "b", "c"},
+			expected: orderedObject{
+				{key: "key1", value: []any{json.Number("1"), json.Number("2"), json.Number("3")}},
+				{key: "key2", value: []any{"a", "b", "c"}},
 			},
 		},
 	}
@@ -82,3 +95,175 @@ func TestParseInnerJSON(t *testing.T) {
 		)
 	}
 }
+
+func TestPrintResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payload  string
+		format   responseFormat
+		logLevel slog.Level
+		expected string
+	}{
+		"object keeps its key order": {
+			payload:  `{"statusCode":200,"body":"{\"b\":1,\"a\":2}"}`,
+			expected: "{\n    \"statusCode\": 200,\n    \"body\": \"{\\\"b\\\":1,\\\"a\\\":2}\"\n}",
+		},
+		"inner JSON": {
+			payload:  `{"statusCode":200,"body":"{\"b\":1,\"a\":2}"}`,
+			format:   responseFormat{parseJSON: true},
+			expected: "\"body\": {\n        \"b\": 1,\n        \"a\": 2\n    }",
+		},
+		"sorted keys": {
+			payload:  `{"statusCode":200,"headers":{"X-B":"b","X-A":"a"},"body":"{\"b\":1,\"a\":2}"}`,
+			format:   responseFormat{sortKeys: true},
+			expected: "{\n    \"body\": \"{\\\"b\\\":1,\\\"a\\\":2}\",\n    \"headers\": {\n        \"X-A\": \"a\",\n",
+		},
+		"sorted keys of inner JSON": {
+			payload: `{"statusCode":200,"body":"{\"b\":1,\"a\":[{\"d\":1,\"c\":2}]}"}`,
+			format:  responseFormat{parseJSON: true, sortKeys: true},
+			expected: "\"a\": [\n            {\n                \"c\": 2,\n                \"d\": 1\n            }\n" +
+				"        ],\n        \"b\": 1",
+		},
+		"array": {
+			payload:  `[1,2]`,
+			expected: "Lambda returned JSON payload:\n[\n    1,\n    2\n]",
+		},
+		"string": {
+			payload:  `"done"`,
+			expected: "Lambda returned JSON payload:\n\"done\"",
+		},
+		"number": {
+			payload:  `42`,
+			format:   responseFormat{sortKeys: true},
+			expected: "Lambda returned JSON payload:\n42",
+		},
+		"inner JSON of an array": {
+			payload: `[{"body":"{\"a\":1}"},"[2]"]`,
+			format:  responseFormat{parseJSON: true},
+			expected: "[\n    {\n        \"body\": {\n            \"a\": 1\n        }\n    },\n    [\n        2\n" +
+				"    ]\n]",
+		},
+		"truncated": {
+			payload:  `{"body":"aGVsbG8gd29ybGQ="}`,
+			format:   responseFormat{maxLogBody: 20},
+			expected: "Lambda returned JSON payload:\n{\n    \"body\": \"aGVsb... (14 bytes truncated)",
+		},
+		"truncated non-JSON": {
+			payload:  `plain text`,
+			format:   responseFormat{maxLogBody: 5},
+			expected: "Lambda returned non-JSON payload:\nplain... (5 bytes truncated)",
+		},
+		"inner JSON of a string": {
+			payload:  `"{\"b\":1,\"a\":2}"`,
+			format:   responseFormat{parseJSON: true, sortKeys: true},
+			expected: "Lambda returned JSON payload:\n{\n    \"a\": 2,\n    \"b\": 1\n}",
+		},
+		"invalid": {
+			payload:  `{"statusCode":`,
+			expected: "Lambda returned non-JSON payload:\n{\"statusCode\":",
+		},
+		"not logged": {
+			payload:  `{"statusCode":200}`,
+			logLevel: slog.LevelWarn,
+		},
+		"64-bit ids": {
+			payload:  `{"id":9007199254740993,"body":"{\"orderId\":18446744073709551615}"}`,
+			expected: "\"id\": 9007199254740993",
+		},
+		"64-bit ids of inner JSON": {
+			payload: `{"id":9007199254740993,"body":"{\"orderId\":18446744073709551615,\"price\":1.10}"}`,
+			format:  responseFormat{parseJSON: true},
+			expected: "\"id\": 9007199254740993,\n    \"body\": {\n        \"orderId\": 18446744073709551615,\n" +
+				"        \"price\": 1.10\n    }",
+		},
+		"float numbers": {
+			payload:  `{"id":9007199254740993,"body":"{\"price\":1.10}"}`,
+			format:   responseFormat{parseJSON: true, floatNumbers: true},
+			expected: "\"id\": 9007199254740992,\n    \"body\": {\n        \"price\": 1.1\n    }",
+		},
+		"redacted header": {
+			payload: `{"statusCode":200,"headers":{"X-Api-Key":"abc"}}`,
+			format:  responseFormat{redaction: newRedaction(defaultRedactHeaders, nil)},
+			expected: "{\n    \"statusCode\": 200,\n    \"headers\": {\n        \"X-Api-Key\": \"[REDACTED]\"\n" +
+				"    }\n}",
+		},
+		"redacted path of inner JSON": {
+			payload:  `{"statusCode":200,"body":"{\"user\":{\"email\":\"a@example.com\"}}"}`,
+			format:   responseFormat{redaction: newRedaction(nil, []string{"body.user.email"})},
+			expected: "\"body\": \"{\\\"user\\\":{\\\"email\\\":\\\"[REDACTED]\\\"}}\"",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var out bytes.Buffer
+
+				logger := slog.New(
+					slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: tc.logLevel}),
+				)
+
+				require.NoError(
+					t,
+					printResponse(logger, messages.InvokeResponse{Payload: []byte(tc.payload)}, tc.format),
+				)
+
+				if tc.expected == "" {
+					assert.Empty(t, out.String())
+
+					return
+				}
+
+				var record struct {
+					Msg string `json:"msg"`
+				}
+				require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+				assert.Contains(t, record.Msg, tc.expected)
+			},
+		)
+	}
+}
+
+func TestLogBody(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxLogBody int
+		payload    string
+		expected   string
+	}{
+		"unlimited": {
+			payload:  "hello world",
+			expected: "hello world",
+		},
+		"shorter": {
+			maxLogBody: 11,
+			payload:    "hello world",
+			expected:   "hello world",
+		},
+		"longer": {
+			maxLogBody: 5,
+			payload:    "hello world",
+			expected:   "hello... (6 bytes truncated)",
+		},
+		"multi-byte character": {
+			maxLogBody: 2,
+			payload:    "aé",
+			expected:   "a... (2 bytes truncated)",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				format := responseFormat{maxLogBody: tc.maxLogBody}
+				assert.Equal(t, tc.expected, format.logBody([]byte(tc.payload)))
+			},
+		)
+	}
+}
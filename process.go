@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	processStartTimeout = 60 * time.Second
+	processStopTimeout  = 5 * time.Second
+)
+
+// lambdaProcess is a handler process launched and supervised by lambdalocal.
+type lambdaProcess struct {
+	// command is the shell command used to launch the handler.
+	command string
+	// env holds extra environment variables set on the process.
+	env []string
+	// unset holds environment variables of lambdalocal that aren't passed to the process.
+	unset  []string
+	logger *slog.Logger
+	cmd    *exec.Cmd
+	// exited is closed once the process has exited.
+	exited chan struct{}
+	// output is used to wait for stdout and stderr to be drained.
+	output sync.WaitGroup
+	// stopping is set once Stop has been called so the expected exit isn't reported as a failure.
+	stopping atomic.Bool
+}
+
+// startManagedLambda launches command as the lambda handler when it is set, pointing it at
+// address. With ProtocolRPC the handler listens on address, with ProtocolRuntimeAPI it polls the
+// Runtime API served on address, which is how handlers built with lambda.norpc are run. env, the
+// variables of the function in the template and of the env files, is set on the process. The
+// returned function stops the process.
+func startManagedLambda(
+	ctx context.Context,
+	command, address, protocol string,
+	env []string,
+	logger *slog.Logger,
+) (func(), error) {
+	if command == "" {
+		return func() {}, nil
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.startManagedLambda] invalid address '%s': %w", address, err)
+	}
+
+	// the address of the lambda comes last, so the variables of the template can't override it
+	process := &lambdaProcess{
+		command: command,
+		env:     append(slices.Clone(env), "_LAMBDA_SERVER_PORT="+port),
+		logger:  logger,
+	}
+
+	// aws-lambda-go prefers RPC when _LAMBDA_SERVER_PORT is set, so only the Runtime API is set
+	if protocol == ProtocolRuntimeAPI {
+		process.env = append(slices.Clone(env), "AWS_LAMBDA_RUNTIME_API="+address)
+		process.unset = []string{"_LAMBDA_SERVER_PORT"}
+	}
+
+	if err = process.Start(); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.startManagedLambda] start failed: %w", err)
+	}
+
+	// lambdalocal serves the Runtime API itself, invocations wait until the runtime polls it
+	if protocol == ProtocolRuntimeAPI {
+		return process.Stop, nil
+	}
+
+	if err = process.waitForListener(ctx, address, processStartTimeout); err != nil {
+		process.Stop()
+
+		return nil, fmt.Errorf("[in lambdalocal.startManagedLambda] lambda did not start: %w", err)
+	}
+
+	return process.Stop, nil
+}
+
+// Start launches the process and forwards its stdout and stderr to the logger.
+func (p *lambdaProcess) Start() error {
+	p.cmd = shellCommand(p.command)
+	p.cmd.Env = append(p.environ(), p.env...)
+	p.exited = make(chan struct{})
+
+	setProcessGroup(p.cmd)
+
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaProcess.Start] stdout pipe failed: %w", err)
+	}
+
+	stderr, err := p.cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaProcess.Start] stderr pipe failed: %w", err)
+	}
+
+	p.logger.Info("Starting lambda process: " + p.command)
+
+	if err = p.cmd.Start(); err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaProcess.Start] start '%s' failed: %w", p.command, err)
+	}
+
+	p.output.Add(2) //nolint:mnd
+
+	go p.forward(stdout, "stdout")
+	go p.forward(stderr, "stderr")
+
+	go func() {
+		p.output.Wait()
+
+		err := p.cmd.Wait()
+		if err != nil && !p.stopping.Load() {
+			p.logger.Warn("Lambda process exited", "err", err)
+		} else {
+			p.logger.Info("Lambda process exited")
+		}
+
+		close(p.exited)
+	}()
+
+	return nil
+}
+
+// Stop terminates the process, killing it if it doesn't exit within processStopTimeout.
+func (p *lambdaProcess) Stop() {
+	select {
+	case <-p.exited:
+		return
+	default:
+	}
+
+	p.logger.Info("Stopping lambda process")
+	p.stopping.Store(true)
+
+	if err := terminateProcess(p.cmd); err != nil {
+		p.logger.Debug("Terminate lambda process failed", "err", err)
+	}
+
+	select {
+	case <-p.exited:
+	case <-time.After(processStopTimeout):
+		p.logger.Warn("Lambda process did not exit in time, killing it")
+
+		_ = killProcess(p.cmd)
+
+		<-p.exited
+	}
+}
+
+// environ returns the environment of lambdalocal without the variables in unset.
+func (p *lambdaProcess) environ() []string {
+	environ := os.Environ()
+
+	return slices.DeleteFunc(
+		environ, func(variable string) bool {
+			key, _, _ := strings.Cut(variable, "=")
+
+			return slices.Contains(p.unset, key)
+		},
+	)
+}
+
+func (p *lambdaProcess) forward(r io.Reader, stream string) {
+	defer p.output.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.logger.Info(scanner.Text(), "lambda", stream)
+	}
+}
+
+// waitForListener blocks until address accepts connections, the process exits, or timeout passes.
+func (p *lambdaProcess) waitForListener(ctx context.Context, address string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// polled often, the Init Duration of cold starts is measured by it
+	ticker := time.NewTicker(10 * time.Millisecond) //nolint:mnd
+	defer ticker.Stop()
+
+	for {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err == nil {
+			_ = conn.Close()
+
+			return nil
+		}
+
+		select {
+		case <-p.exited:
+			return errors.New("process exited before listening on " + address)
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", address, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// processPool is several warm processes of the managed lambda, like the provisioned concurrency of
+// a deployed function, so invocations run concurrently in separate processes instead of one.
+type processPool struct {
+	// addresses are the addresses the processes are invoked on. With ProtocolRPC every process
+	// listens on its own, with ProtocolRuntimeAPI they all poll the Runtime API.
+	addresses []string
+	stops     []func()
+}
+
+// startProcessPool launches processes of command with the variables of env. With ProtocolRPC each
+// one listens on a free port of the host of address, with ProtocolRuntimeAPI they take the
+// invocations of the Runtime API served on address as they poll it.
+func startProcessPool(
+	ctx context.Context,
+	command, address, protocol string,
+	processes int,
+	env []string,
+	logger *slog.Logger,
+) (*processPool, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.startProcessPool] invalid address '%s': %w", address, err)
+	}
+
+	pool := &processPool{}
+
+	for i := range processes {
+		processAddress := address
+
+		if protocol == ProtocolRPC {
+			if processAddress, err = unusedAddress(host); err != nil {
+				pool.Stop()
+
+				return nil, fmt.Errorf("[in lambdalocal.startProcessPool] %w", err)
+			}
+		}
+
+		stop, err := startManagedLambda(ctx, command, processAddress, protocol, env, logger.With("process", i+1))
+		if err != nil {
+			pool.Stop()
+
+			return nil, fmt.Errorf("[in lambdalocal.startProcessPool] process %d: %w", i+1, err)
+		}
+
+		pool.addresses = append(pool.addresses, processAddress)
+		pool.stops = append(pool.stops, stop)
+	}
+
+	return pool, nil
+}
+
+// Stop stops all processes of the pool.
+func (p *processPool) Stop() {
+	var wg sync.WaitGroup
+
+	for _, stop := range p.stops {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			stop()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// unusedAddress returns an address on host with a port nothing listens on.
+func unusedAddress(host string) (string, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return "", fmt.Errorf("[in lambdalocal.unusedAddress] find free port failed: %w", err)
+	}
+
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	return address, nil
+}
+
+// balancedCaller spreads invocations across callers, each one goes to the caller with the fewest
+// invocations running.
+type balancedCaller struct {
+	callers []lambdaCaller
+
+	mu sync.Mutex
+	// active counts the running invocations of each caller.
+	active []int
+	// next is where the search for the least busy caller starts, so idle callers take turns.
+	next int
+}
+
+func newBalancedCaller(callers []lambdaCaller) *balancedCaller {
+	return &balancedCaller{callers: callers, active: make([]int, len(callers))}
+}
+
+// acquire picks the caller of the next invocation.
+func (b *balancedCaller) acquire() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	picked := b.next
+
+	for i := range b.callers {
+		if candidate := (b.next + i) % len(b.callers); b.active[candidate] < b.active[picked] {
+			picked = candidate
+		}
+	}
+
+	b.active[picked]++
+	b.next = (picked + 1) % len(b.callers)
+
+	return picked
+}
+
+func (b *balancedCaller) release(i int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.active[i]--
+}
+
+func (b *balancedCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	i := b.acquire()
+	defer b.release(i)
+
+	return b.callers[i].Invoke(ctx, data, options...) //nolint:wrapcheck
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancedCaller(t *testing.T) {
+	t.Parallel()
+
+	lambdas := []*blockingLambdaCaller{newBlockingLambdaCaller(), newBlockingLambdaCaller()}
+	caller := newBalancedCaller([]lambdaCaller{lambdas[0], lambdas[1]})
+
+	var wg sync.WaitGroup
+
+	invoke := func() {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := caller.Invoke(context.Background(), []byte(`{}`))
+			assert.NoError(t, err)
+		}()
+	}
+
+	// concurrent invocations go to the idle process
+	invoke()
+	<-lambdas[0].started
+
+	invoke()
+	<-lambdas[1].started
+
+	close(lambdas[0].release)
+	close(lambdas[1].release)
+	wg.Wait()
+
+	// idle processes take turns
+	for _, lambda := range lambdas {
+		_, err := caller.Invoke(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+		assert.Len(t, lambda.started, 1)
+	}
+}
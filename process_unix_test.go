@@ -0,0 +1,274 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lmittmann/tint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for use by the logger and the test at the same time.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p) //nolint:wrapcheck
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func newTestLogger(w *syncBuffer) *slog.Logger {
+	return slog.New(tint.NewHandler(w, &tint.Options{TimeFormat: "0", NoColor: true}))
+}
+
+func TestLambdaProcess(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		command        string
+		expectedOutput []string
+	}{
+		"forwards stdout and stderr": {
+			command: `echo "port $_LAMBDA_SERVER_PORT"; echo oops >&2; sleep 30`,
+			expectedOutput: []string{
+				"INF port 8001 lambda=stdout",
+				"INF oops lambda=stderr",
+			},
+		},
+		"stops child processes": {
+			command:        `sh -c 'sleep 30'`,
+			expectedOutput: []string{"INF Stopping lambda process"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var buf syncBuffer
+
+				process := &lambdaProcess{
+					command: tc.command,
+					env:     []string{"_LAMBDA_SERVER_PORT=8001"},
+					logger:  newTestLogger(&buf),
+				}
+
+				require.NoError(t, process.Start())
+
+				time.Sleep(100 * time.Millisecond)
+
+				done := make(chan struct{})
+
+				go func() {
+					process.Stop()
+					close(done)
+				}()
+
+				select {
+				case <-done:
+				case <-time.After(processStopTimeout):
+					t.Fatal("process was not stopped")
+				}
+
+				for _, line := range tc.expectedOutput {
+					assert.Contains(t, buf.String(), line)
+				}
+
+				assert.NotContains(t, buf.String(), "WRN")
+			},
+		)
+	}
+}
+
+func TestStartManagedLambda(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	var buf syncBuffer
+
+	stop, err := startManagedLambda(
+		context.Background(),
+		"sleep 30",
+		listener.Addr().String(),
+		ProtocolRPC,
+		nil,
+		newTestLogger(&buf),
+	)
+	require.NoError(t, err)
+
+	stop()
+
+	_, err = startManagedLambda(context.Background(), "exit 1", "localhost:1", ProtocolRPC, nil, newTestLogger(&buf))
+	assert.ErrorContains(t, err, "process exited before listening")
+
+	stop, err = startManagedLambda(context.Background(), "", "", ProtocolRPC, nil, newTestLogger(&buf))
+	require.NoError(t, err)
+
+	stop()
+}
+
+func TestStartManagedLambdaRuntimeAPI(t *testing.T) {
+	t.Parallel()
+
+	var buf syncBuffer
+
+	// nothing listens on the address, the runtime polls the Runtime API served by lambdalocal. The
+	// variables of the template don't override its address.
+	stop, err := startManagedLambda(
+		context.Background(),
+		`echo "api $AWS_LAMBDA_RUNTIME_API port ${_LAMBDA_SERVER_PORT:-unset} table $TABLE_NAME"; sleep 30`,
+		"localhost:9001",
+		ProtocolRuntimeAPI,
+		[]string{"AWS_LAMBDA_RUNTIME_API=localhost:1", "TABLE_NAME=orders"},
+		newTestLogger(&buf),
+	)
+	require.NoError(t, err)
+
+	assert.Eventually(
+		t,
+		func() bool {
+			return strings.Contains(buf.String(), "INF api localhost:9001 port unset table orders lambda=stdout")
+		},
+		time.Second,
+		10*time.Millisecond,
+	)
+
+	stop()
+}
+
+func TestLambdaProcessEnviron(t *testing.T) {
+	t.Parallel()
+
+	process := &lambdaProcess{unset: []string{"PATH"}}
+
+	for _, variable := range process.environ() {
+		assert.False(t, strings.HasPrefix(variable, "PATH="), variable)
+	}
+}
+
+func TestLambdaWatcherColdStart(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	var buf syncBuffer
+
+	caller := new(recordingLambdaCaller)
+	watcher := newLambdaWatcher(
+		caller,
+		".",
+		nil,
+		"",
+		"sleep 30",
+		listener.Addr().String(),
+		ProtocolRPC,
+		newTestLogger(&buf),
+	)
+	watcher.coldStart = coldStartPolicy{percent: 100}
+
+	require.NoError(t, watcher.Start(context.Background()))
+	defer watcher.Stop()
+
+	// every invocation restarts the process and reports how long it took to start
+	for range 2 {
+		_, err = watcher.Invoke(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+		assert.Positive(t, caller.options.initDuration)
+	}
+
+	assert.Equal(t, 3, strings.Count(buf.String(), "Starting lambda process: sleep 30"))
+
+	// warm invocations reuse the process
+	watcher.coldStart = coldStartPolicy{}
+
+	_, err = watcher.Invoke(context.Background(), []byte(`{}`))
+	require.NoError(t, err)
+	assert.Zero(t, caller.options.initDuration)
+
+	// the first invocation of a process restarted on a change reports how long it took to start
+	require.NoError(t, watcher.restart(context.Background()))
+
+	for _, wantInit := range []bool{true, false} {
+		_, err = watcher.Invoke(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+		assert.Equal(t, wantInit, caller.options.initDuration > 0)
+	}
+}
+
+func TestLambdaWatcherRestartBuildFailed(t *testing.T) {
+	t.Parallel()
+
+	watcher := newLambdaWatcher(nil, ".", nil, "exit 1", "sleep 30", "localhost:0", ProtocolRPC, slog.Default())
+
+	// the running process is kept, it was never stopped
+	stopped := false
+	watcher.stop = func() { stopped = true }
+
+	err := watcher.restart(context.Background())
+	require.ErrorIs(t, err, errBuildFailed)
+	assert.False(t, stopped)
+}
+
+func TestStartProcessPool(t *testing.T) {
+	t.Parallel()
+
+	var buf syncBuffer
+
+	// the processes poll the same Runtime API
+	pool, err := startProcessPool(
+		context.Background(),
+		"sleep 30",
+		"localhost:9001",
+		ProtocolRuntimeAPI,
+		3,
+		nil,
+		newTestLogger(&buf),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"localhost:9001", "localhost:9001", "localhost:9001"}, pool.addresses)
+
+	for process := range 3 {
+		assert.Contains(t, buf.String(), fmt.Sprintf("INF Starting lambda process: sleep 30 process=%d", process+1))
+	}
+
+	pool.Stop()
+
+	assert.Equal(t, 3, strings.Count(buf.String(), "INF Stopping lambda process"))
+
+	_, err = startProcessPool(context.Background(), "exit 1", "localhost:1", ProtocolRPC, 2, nil, newTestLogger(&buf))
+	assert.ErrorContains(t, err, "process 1: [in lambdalocal.startManagedLambda] lambda did not start")
+}
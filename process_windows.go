@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+func setProcessGroup(_ *exec.Cmd) {}
+
+func terminateProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill() //nolint:wrapcheck
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill() //nolint:wrapcheck
+}
+
+// processAlive reports if the process with pid is running.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	_ = process.Release()
+
+	return true
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// rawAPIResponse is a lambda API response with its body left as returned by the lambda.
+type rawAPIResponse struct {
+	genericAPIResponse
+	Body json.RawMessage `json:"body"`
+}
+
+// returnRawHTTPResponse writes a lambda API response like returnHTTPResponse, but only the
+// statusCode, headers and cookies of the payload are decoded. The body is written byte for byte.
+func returnRawHTTPResponse(w http.ResponseWriter, invokeResponse messages.InvokeResponse) error {
+	if invokeResponse.Error != nil {
+		return returnHTTPResponse(w, invokeResponse)
+	}
+
+	APIResponse := rawAPIResponse{}
+
+	if err := json.Unmarshal(invokeResponse.Payload, &APIResponse); err != nil {
+		return fmt.Errorf("[in lambdalocal.returnRawHTTPResponse] Unmarshal payload failed: %w", err)
+	}
+
+	body, err := rawBody(APIResponse.Body)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.returnRawHTTPResponse] %w", err)
+	}
+
+	if APIResponse.IsBase64Encoded {
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(body)))
+
+		n, err := base64.StdEncoding.Decode(decoded, body)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.returnRawHTTPResponse] decode base64 body failed: %w", err)
+		}
+
+		body = decoded[:n]
+	}
+
+	return writeAPIResponse(w, APIResponse.genericAPIResponse, body)
+}
+
+// returnRawHTTPAPIResponse writes a payload format 2.0 response with returnRawHTTPResponse.
+// Payloads without a statusCode are already returned as is.
+func returnRawHTTPAPIResponse(w http.ResponseWriter, invokeResponse messages.InvokeResponse) error {
+	return writeHTTPAPIResponse(w, invokeResponse, returnRawHTTPResponse)
+}
+
+// rawBody returns the bytes of the body field of a response. A string body is unescaped without
+// replacing invalid UTF-8 or lone surrogates, any other value is returned as it is in the payload.
+func rawBody(raw json.RawMessage) ([]byte, error) {
+	raw = bytes.TrimSpace(raw)
+
+	switch {
+	case len(raw) == 0, string(raw) == "null":
+		return nil, nil
+	case raw[0] != '"':
+		return raw, nil
+	}
+
+	return unquoteJSONString(raw)
+}
+
+// unquoteJSONString decodes the JSON string literal quoted. Unlike encoding/json, bytes that
+// aren't valid UTF-8 are kept and lone surrogates are encoded as is, so no byte of the body
+// returned by the lambda is altered.
+func unquoteJSONString(quoted []byte) ([]byte, error) {
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return nil, fmt.Errorf("[in lambdalocal.unquoteJSONString] invalid string %s", quoted)
+	}
+
+	s := quoted[1 : len(quoted)-1]
+	// most bodies have few escapes
+	if bytes.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+
+	out := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return nil, fmt.Errorf("[in lambdalocal.unquoteJSONString] unterminated escape in %s", quoted)
+		}
+
+		i++
+
+		switch s[i] {
+		case '"', '\\', '/':
+			out = append(out, s[i])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := unicodeEscape(s[i+1:])
+			if !ok {
+				return nil, fmt.Errorf("[in lambdalocal.unquoteJSONString] invalid unicode escape in %s", quoted)
+			}
+
+			i += 4 //nolint:mnd
+
+			// a surrogate pair is one character
+			if utf16.IsSurrogate(r) && len(s) > i+2 && s[i+1] == '\\' && s[i+2] == 'u' {
+				if low, ok := unicodeEscape(s[i+3:]); ok {
+					if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+						out = utf8.AppendRune(out, pair)
+						i += 6 //nolint:mnd
+
+						continue
+					}
+				}
+			}
+
+			out = appendCodeUnit(out, r)
+		default:
+			return nil, fmt.Errorf("[in lambdalocal.unquoteJSONString] invalid escape \\%c in %s", s[i], quoted)
+		}
+	}
+
+	return out, nil
+}
+
+// unicodeEscape parses the 4 hex digits at the start of s.
+func unicodeEscape(s []byte) (rune, bool) {
+	if len(s) < 4 { //nolint:mnd
+		return 0, false
+	}
+
+	r, err := strconv.ParseUint(string(s[:4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return rune(r), true
+}
+
+// appendCodeUnit appends the UTF-8 encoding of r, encoding lone surrogates like any other code
+// point of the basic multilingual plane instead of replacing them with U+FFFD.
+func appendCodeUnit(out []byte, r rune) []byte {
+	if !utf16.IsSurrogate(r) {
+		return utf8.AppendRune(out, r)
+	}
+
+	//nolint:mnd
+	return append(out, byte(0xE0|r>>12), byte(0x80|(r>>6)&0x3F), byte(0x80|r&0x3F))
+}
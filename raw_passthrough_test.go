@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturnRawHTTPResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		payload         string
+		payloadFormat   string
+		expectedStatus  int
+		expectedHeaders map[string]string
+		expectedBody    string
+		expectError     bool
+	}{
+		"string body keeps whitespace, order and numbers": {
+			payload:        `{"statusCode":200,"body":"{ \"b\": 1.10,\n  \"a\": 1e3 }"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "{ \"b\": 1.10,\n  \"a\": 1e3 }",
+		},
+		"escapes": {
+			payload:        `{"statusCode":200,"body":"\"\\\/\b\f\n\r\té😀"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "\"\\/\b\f\n\r\té😀",
+		},
+		"lone surrogate isn't replaced": {
+			payload:        `{"statusCode":200,"body":"a\ud800b"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "a\xed\xa0\x80b",
+		},
+		"invalid UTF-8 isn't replaced": {
+			payload:        "{\"statusCode\":200,\"body\":\"a\xffb\"}",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "a\xffb",
+		},
+		"object body": {
+			payload:        `{"statusCode":201,"body":{"b": 1.0, "a": [1,2]}}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"b": 1.0, "a": [1,2]}`,
+		},
+		"null body": {
+			payload:        `{"statusCode":204,"body":null}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		"headers": {
+			payload: `{"statusCode":200,"headers":{"Content-Type":"text/plain"},` +
+				`"multiValueHeaders":{"X-Id":["1","2"]},"body":"ok"}`,
+			expectedStatus:  http.StatusOK,
+			expectedHeaders: map[string]string{"Content-Type": "text/plain", "X-Id": "1"},
+			expectedBody:    "ok",
+		},
+		"base64 encoded body": {
+			payload:        `{"statusCode":200,"body":"eyJhIjogMS4wfQ==","isBase64Encoded":true}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"a": 1.0}`,
+		},
+		"payload format 2.0 without statusCode": {
+			payload:        `{ "a": 1.0 }`,
+			payloadFormat:  payloadFormatV2,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{ "a": 1.0 }`,
+		},
+		"payload format 2.0": {
+			payload:        `{"statusCode":200,"body":"{\"a\":  1.0}","cookies":["a=1"]}`,
+			payloadFormat:  payloadFormatV2,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"a":  1.0}`,
+		},
+		"invalid escape": {
+			payload:     `{"statusCode":200,"body":"\x"}`,
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				returnResponse := returnRawHTTPResponse
+				if tc.payloadFormat == payloadFormatV2 {
+					returnResponse = returnRawHTTPAPIResponse
+				}
+
+				recorder := httptest.NewRecorder()
+
+				err := returnResponse(recorder, messages.InvokeResponse{Payload: []byte(tc.payload)})
+				if tc.expectError {
+					require.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedStatus, recorder.Code)
+				assert.Equal(t, tc.expectedBody, recorder.Body.String())
+
+				for key, value := range tc.expectedHeaders {
+					assert.Equal(t, value, recorder.Header().Get(key))
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// recordingsPrefix is the prefix of the keys of the recorded invocations in the store.
+	recordingsPrefix = "recordings/"
+	// recordingTimeFormat is the start time in the keys of the recordings, it sorts like the time.
+	recordingTimeFormat = "20060102T150405.000000000Z"
+)
+
+// recordingKey returns the key of the recording of the invocation with the request id id. Keys sort
+// by start time, the request id is reduced to characters that are safe in file names.
+func recordingKey(id string, startedAt time.Time) string {
+	id = strings.Map(
+		func(r rune) rune {
+			if r == '-' || r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return r
+			}
+
+			return '_'
+		},
+		id,
+	)
+
+	return recordingsPrefix + startedAt.UTC().Format(recordingTimeFormat) + "-" + id + ".json"
+}
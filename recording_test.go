@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordingKey(t *testing.T) {
+	t.Parallel()
+
+	startedAt := time.Date(2024, 3, 1, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	assert.Equal(t, "recordings/20240301T093000.000000000Z-a_1.json", recordingKey("a/1", startedAt))
+	assert.Equal(t, "recordings/20240301T093000.000000000Z-req-_1.json", recordingKey("req-_1", startedAt))
+}
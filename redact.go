@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// redactedValue replaces the values of redacted headers and fields in the logs.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactHeaders are the headers left out of the logs unless --redact-headers is set.
+var defaultRedactHeaders = []string{"Authorization", "Cookie", "x-api-key"} //nolint:gochecknoglobals
+
+// redaction is what is left out of the logged requests and payloads, so tokens and PII don't end
+// up in terminals and log files. The zero value redacts nothing.
+type redaction struct {
+	// headers are the lower case names of the redacted headers.
+	headers []string
+	// paths are the dot separated paths of the redacted fields of payloads, `*` matches any key or
+	// array element.
+	paths [][]string
+}
+
+// newRedaction returns the redaction of the headers and the JSON paths. Empty names and paths are
+// ignored, so an empty --redact-headers redacts no headers.
+func newRedaction(headers, paths []string) redaction {
+	var r redaction
+
+	for _, header := range headers {
+		if header = strings.TrimSpace(header); header != "" {
+			r.headers = append(r.headers, strings.ToLower(header))
+		}
+	}
+
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			r.paths = append(r.paths, strings.Split(path, "."))
+		}
+	}
+
+	return r
+}
+
+// redactsHeader reports whether the header name is redacted.
+func (r redaction) redactsHeader(name string) bool {
+	return slices.Contains(r.headers, strings.ToLower(name))
+}
+
+// requestHeaders returns the headers of a request for logging, with the values of redacted headers
+// replaced.
+func (r redaction) requestHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+
+	for name, values := range header {
+		if r.redactsHeader(name) {
+			headers[name] = redactedValue
+			continue
+		}
+
+		headers[name] = strings.Join(values, ",")
+	}
+
+	return headers
+}
+
+// matches reports whether the payload may hold something redacted. It is cheaper than decoding the
+// payload, which is skipped for payloads that don't.
+func (r redaction) matches(payload []byte) bool {
+	if len(r.paths) > 0 {
+		return true
+	}
+
+	if len(r.headers) == 0 {
+		return false
+	}
+
+	lower := bytes.ToLower(payload)
+
+	for _, header := range r.headers {
+		if bytes.Contains(lower, []byte(header)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// payload redacts a payload decoded with unmarshalOrderedJSON: the values of the redacted headers
+// in its headers and multiValueHeaders objects, the cookies when Cookie is redacted, and the fields
+// at the redacted paths. Strings that
+// hold JSON, like the body of API events and responses, are redacted too. It reports whether
+// anything was redacted.
+func (r redaction) payload(value any) (any, bool) {
+	value, redacted := r.redactHeaders(value)
+
+	for _, path := range r.paths {
+		var ok bool
+		if value, ok = redactPath(value, path); ok {
+			redacted = true
+		}
+	}
+
+	return value, redacted
+}
+
+// redactHeaders redacts the values of the redacted headers of the headers objects in value.
+func (r redaction) redactHeaders(value any) (any, bool) {
+	if len(r.headers) == 0 {
+		return value, false
+	}
+
+	redacted := false
+
+	switch v := value.(type) {
+	case orderedObject:
+		for i, field := range v {
+			var ok bool
+
+			if key := strings.ToLower(field.key); key == "headers" || key == "multivalueheaders" {
+				if headers, isObject := field.value.(orderedObject); isObject {
+					for j := range headers {
+						if r.redactsHeader(headers[j].key) {
+							headers[j].value, ok = redactedValue, true
+						}
+					}
+				}
+			}
+
+			// the cookies of HttpApi events and responses are the Cookie header
+			if cookies, isArray := field.value.([]any); isArray && field.key == "cookies" && r.redactsHeader("cookie") {
+				for j := range cookies {
+					cookies[j], ok = redactedValue, true
+				}
+			}
+
+			if !ok {
+				v[i].value, ok = r.redactHeaders(field.value)
+			}
+
+			redacted = redacted || ok
+		}
+	case []any:
+		for i := range v {
+			var ok bool
+			v[i], ok = r.redactHeaders(v[i])
+			redacted = redacted || ok
+		}
+	case string:
+		return redactString(v, r.redactHeaders)
+	}
+
+	return value, redacted
+}
+
+// redactPath replaces the fields of value at path.
+func redactPath(value any, path []string) (any, bool) {
+	if len(path) == 0 {
+		return redactedValue, true
+	}
+
+	key, rest := path[0], path[1:]
+	redacted := false
+
+	switch v := value.(type) {
+	case orderedObject:
+		for i, field := range v {
+			if key == "*" || key == field.key {
+				var ok bool
+				v[i].value, ok = redactPath(field.value, rest)
+				redacted = redacted || ok
+			}
+		}
+	case []any:
+		for i := range v {
+			if key == "*" || key == strconv.Itoa(i) {
+				var ok bool
+				v[i], ok = redactPath(v[i], rest)
+				redacted = redacted || ok
+			}
+		}
+	case string:
+		return redactString(v, func(inner any) (any, bool) {
+			return redactPath(inner, path)
+		})
+	}
+
+	return value, redacted
+}
+
+// redactString redacts the JSON object or array held by s with redact, and returns it encoded
+// again. Other strings are returned as they are.
+func redactString(s string, redact func(any) (any, bool)) (any, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return s, false
+	}
+
+	inner, err := unmarshalOrderedJSON([]byte(trimmed))
+	if err != nil {
+		return s, false
+	}
+
+	inner, redacted := redact(inner)
+	if !redacted {
+		return s, false
+	}
+
+	data, err := json.Marshal(inner)
+	if err != nil {
+		return s, false
+	}
+
+	return string(data), true
+}
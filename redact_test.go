@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactionPayload(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		redaction    redaction
+		payload      string
+		want         string
+		wantRedacted bool
+	}{
+		"headers": {
+			redaction: newRedaction(defaultRedactHeaders, nil),
+			payload: `{"headers":{"authorization":"Bearer abc","accept":"*/*"},` +
+				`"multiValueHeaders":{"Cookie":["a=b"]},"cookies":["a=b"]}`,
+			want: `{"headers":{"authorization":"[REDACTED]","accept":"*/*"},` +
+				`"multiValueHeaders":{"Cookie":"[REDACTED]"},"cookies":["[REDACTED]"]}`,
+			wantRedacted: true,
+		},
+		"headers of inner JSON": {
+			redaction:    newRedaction(defaultRedactHeaders, nil),
+			payload:      `{"body":"{\"event\":{\"headers\":{\"x-api-key\":\"abc\"}}}"}`,
+			want:         `{"body":"{\"event\":{\"headers\":{\"x-api-key\":\"[REDACTED]\"}}}"}`,
+			wantRedacted: true,
+		},
+		"fields named like headers outside of headers": {
+			redaction: newRedaction(defaultRedactHeaders, nil),
+			payload:   `{"authorization":"abc","body":"authorization"}`,
+			want:      `{"authorization":"abc","body":"authorization"}`,
+		},
+		"paths": {
+			redaction: newRedaction(nil, []string{"user.email", "items.*.card", "tokens.0"}),
+			payload:   `{"user":{"email":"a@example.com","name":"a"},"items":[{"card":"4111"}],"tokens":["a","b"]}`,
+			want: `{"user":{"email":"[REDACTED]","name":"a"},"items":[{"card":"[REDACTED]"}],` +
+				`"tokens":["[REDACTED]","b"]}`,
+			wantRedacted: true,
+		},
+		"path of inner JSON": {
+			redaction:    newRedaction(nil, []string{"body.password"}),
+			payload:      `{"statusCode":200,"body":"{\"password\":\"hunter2\"}"}`,
+			want:         `{"statusCode":200,"body":"{\"password\":\"[REDACTED]\"}"}`,
+			wantRedacted: true,
+		},
+		"missing path": {
+			redaction: newRedaction(nil, []string{"user.email"}),
+			payload:   `{"user":"a","body":"{\"user\":1}"}`,
+			want:      `{"user":"a","body":"{\"user\":1}"}`,
+		},
+		"no headers": {
+			redaction: newRedaction([]string{""}, nil),
+			payload:   `{"headers":{"authorization":"Bearer abc"}}`,
+			want:      `{"headers":{"authorization":"Bearer abc"}}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				value, err := unmarshalOrderedJSON([]byte(tc.payload))
+				require.NoError(t, err)
+
+				value, redacted := tc.redaction.payload(value)
+				assert.Equal(t, tc.wantRedacted, redacted)
+
+				out, err := json.Marshal(value)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.want, string(out))
+			},
+		)
+	}
+}
+
+func TestRedactionMatches(t *testing.T) {
+	t.Parallel()
+
+	headers := newRedaction(defaultRedactHeaders, nil)
+
+	assert.True(t, headers.matches([]byte(`{"headers":{"X-API-KEY":"abc"}}`)))
+	assert.False(t, headers.matches([]byte(`{"statusCode":200}`)))
+	assert.True(t, newRedaction(nil, []string{"a"}).matches([]byte(`{}`)))
+	assert.False(t, redaction{}.matches([]byte(`{"headers":{"authorization":"abc"}}`)))
+}
+
+func TestRedactionRequestHeaders(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abc")
+	header.Add("Accept", "text/html")
+	header.Add("Accept", "application/json")
+
+	assert.Equal(
+		t,
+		map[string]string{"Authorization": redactedValue, "Accept": "text/html,application/json"},
+		newRedaction(defaultRedactHeaders, nil).requestHeaders(header),
+	)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// defaultMemorySize is the MemorySize in MB of functions that don't set one, like in Lambda.
+const defaultMemorySize = 128
+
+// invocationReport prints the START, END and REPORT lines of an invocation, like the ones Lambda
+// writes to CloudWatch Logs.
+type invocationReport struct {
+	w          io.Writer
+	requestID  string
+	memorySize int
+	// initDuration is the Init Duration of cold started invocations, 0 for warm ones.
+	initDuration time.Duration
+	start        time.Time
+}
+
+// startReport prints the START line of the invocation with the request id requestID. It returns
+// nil, which prints nothing, without WithReportLines.
+func (o invokeOptions) startReport(requestID string) *invocationReport {
+	if o.reportWriter == nil {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(o.reportWriter, "START RequestId: %s Version: $LATEST\n", requestID)
+
+	memorySize := o.memorySize
+	if memorySize <= 0 {
+		memorySize = defaultMemorySize
+	}
+
+	return &invocationReport{
+		w:            o.reportWriter,
+		requestID:    requestID,
+		memorySize:   memorySize,
+		initDuration: o.initDuration,
+		start:        time.Now(),
+	}
+}
+
+// end prints the END and REPORT lines of the invocation. The billed duration is rounded up to the
+// next millisecond. Cold started invocations end with their Init Duration, like in Lambda.
+func (r *invocationReport) end() {
+	if r == nil {
+		return
+	}
+
+	duration := float64(time.Since(r.start)) / float64(time.Millisecond)
+
+	var initDuration string
+	if r.initDuration > 0 {
+		initDuration = fmt.Sprintf("\tInit Duration: %.2f ms", float64(r.initDuration)/float64(time.Millisecond))
+	}
+
+	_, _ = fmt.Fprintf(r.w, "END RequestId: %s\n", r.requestID)
+	_, _ = fmt.Fprintf(
+		r.w,
+		"REPORT RequestId: %s\tDuration: %.2f ms\tBilled Duration: %d ms\tMemory Size: %d MB%s\n",
+		r.requestID,
+		duration,
+		int64(math.Ceil(duration)),
+		r.memorySize,
+		initDuration,
+	)
+}
+
+// functionMemorySizes returns the MemorySize in MB of the functions of the template at
+// templatePath that set one, in their properties or the Globals. Terraform templates have none.
+func functionMemorySizes(
+	templatePath string,
+	reader fileReader,
+	overrides map[string]string,
+) (map[string]int, error) {
+	yamlFile, err := reader.read(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.functionMemorySizes] read file failed: %w", err)
+	}
+
+	memorySizes := make(map[string]int)
+
+	if isTerraformJSON(yamlFile) {
+		return memorySizes, nil
+	}
+
+	index, err := templates.index(templatePath, yamlFile, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.functionMemorySizes] unmarshal yaml failed: %w", err)
+	}
+
+	globalSize, _ := templateInt(index.sam.Globals.Function.MemorySize)
+
+	for name, resource := range index.sam.Resources {
+		if resource.Type != "AWS::Serverless::Function" {
+			continue
+		}
+
+		if size, ok := templateInt(resource.Properties.MemorySize); ok {
+			memorySizes[name] = size
+		} else if globalSize > 0 {
+			memorySizes[name] = globalSize
+		}
+	}
+
+	return memorySizes, nil
+}
+
+// templateInt returns the number of a template value, a number or a string resolved from a
+// parameter.
+func templateInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvocationReport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options        []Option
+		invokeOptions  []Option
+		wantMemorySize string
+	}{
+		"default memory size": {
+			wantMemorySize: "Memory Size: 128 MB",
+		},
+		"memory size of the function": {
+			options:        []Option{WithMemorySize(1024)},
+			wantMemorySize: "Memory Size: 1024 MB",
+		},
+		"cold start": {
+			invokeOptions:  []Option{WithInitDuration(231260 * time.Microsecond)},
+			wantMemorySize: "Memory Size: 128 MB\tInit Duration: 231.26 ms",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var out bytes.Buffer
+
+				client := NewLambdaLambdaRPCClient(
+					startEchoFunction(t),
+					time.Second,
+					append([]Option{WithReportLines(&out)}, tc.options...)...,
+				)
+
+				_, err := client.Invoke(
+					context.Background(),
+					[]byte(`{}`),
+					append([]Option{WithRequestID("8f5c1a2e")}, tc.invokeOptions...)...,
+				)
+				require.NoError(t, err)
+
+				assert.Regexp(
+					t,
+					`^START RequestId: 8f5c1a2e Version: \$LATEST\n`+
+						`END RequestId: 8f5c1a2e\n`+
+						`REPORT RequestId: 8f5c1a2e\tDuration: \d+\.\d{2} ms\tBilled Duration: \d+ ms\t`+
+						tc.wantMemorySize+`\n$`,
+					out.String(),
+				)
+			},
+		)
+	}
+}
+
+func TestInvocationReportDisabled(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, newInvokeOptions(nil).startReport("8f5c1a2e"))
+
+	// a nil report prints nothing
+	var report *invocationReport
+	report.end()
+}
+
+func TestInvocationReportUnreachableLambda(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	// nothing listens on the address, the invocation never reaches a lambda
+	client := NewLambdaLambdaRPCClient("127.0.0.1:1", time.Second, WithReportLines(&out))
+
+	_, err := client.Invoke(context.Background(), []byte(`{}`), WithRequestID("8f5c1a2e"))
+	require.Error(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestFunctionMemorySizes(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Parameters:
+  Memory:
+    Type: Number
+    Default: 2048
+Globals:
+  Function:
+    MemorySize: 256
+Resources:
+  Small:
+    Type: AWS::Serverless::Function
+    Properties:
+      MemorySize: 512
+  Global:
+    Type: AWS::Serverless::Function
+  Parameter:
+    Type: AWS::Serverless::Function
+    Properties:
+      MemorySize: !Ref Memory
+  Queue:
+    Type: AWS::SQS::Queue
+`
+
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(template), 0o600))
+
+	memorySizes, err := functionMemorySizes(path, osFileReader{}, map[string]string{"Memory": "3008"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"Small": 512, "Global": 256, "Parameter": 3008}, memorySizes)
+}
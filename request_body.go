@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// bodyMemoryLimit is the size of the request bodies held in memory while an event is built. Larger
+// bodies, like test uploads of hundreds of MB, are spilled to a temporary file and streamed into
+// the event.
+const bodyMemoryLimit = 10 << 20
+
+// requestBody is the body of a request. Bodies up to the memory limit are held in memory, larger
+// ones are spilled to a temporary file that Close removes.
+type requestBody struct {
+	data []byte
+	// buffer holds data, it is returned to the pool by Close.
+	buffer *bytes.Buffer
+	file   *os.File
+	size   int64
+	// validUTF8 is only tracked for spilled bodies, data is checked when it is encoded.
+	validUTF8 bool
+	// placeholder is the body field of events with a spilled body, marshal replaces it.
+	placeholder string
+}
+
+// readRequestBody reads r, holding at most memoryLimit bytes in memory.
+func readRequestBody(r io.Reader, memoryLimit int64) (*requestBody, error) {
+	buf := getBuffer()
+
+	if _, err := buf.ReadFrom(io.LimitReader(r, memoryLimit+1)); err != nil {
+		putBuffer(buf)
+
+		return nil, fmt.Errorf("[in lambdalocal.readRequestBody] read body failed: %w", err)
+	}
+
+	if int64(buf.Len()) <= memoryLimit {
+		return &requestBody{data: buf.Bytes(), buffer: buf, size: int64(buf.Len())}, nil
+	}
+
+	defer putBuffer(buf)
+
+	file, err := os.CreateTemp("", "lambdalocal-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.readRequestBody] create temporary file failed: %w", err)
+	}
+
+	body := &requestBody{file: file, placeholder: "lambdalocal-body-" + uuid.New().String()}
+	validator := &utf8Validator{valid: true}
+
+	size, err := io.Copy(io.MultiWriter(file, validator), io.MultiReader(buf, r))
+	if err != nil {
+		_ = body.Close()
+
+		return nil, fmt.Errorf("[in lambdalocal.readRequestBody] spill body failed: %w", err)
+	}
+
+	body.size = size
+	body.validUTF8 = validator.done()
+
+	return body, nil
+}
+
+// Close removes the temporary file of a spilled body, or returns the buffer of a body held in
+// memory to the pool. Events built with the body don't refer to either.
+func (b *requestBody) Close() error {
+	if b.buffer != nil {
+		putBuffer(b.buffer)
+		b.buffer, b.data = nil, nil
+	}
+
+	if b.file == nil {
+		return nil
+	}
+
+	return errors.Join(b.file.Close(), os.Remove(b.file.Name())) //nolint:wrapcheck
+}
+
+// field returns the value of the body field of an event, and whether it is base64 encoded, like
+// encodeBody. Spilled bodies return a placeholder that marshal streams the body into.
+func (b *requestBody) field(binary bool) (string, bool) {
+	if b.file == nil {
+		return encodeBody(b.data, binary)
+	}
+
+	return b.placeholder, binary || !b.validUTF8
+}
+
+// marshal returns the JSON of an event whose body field was set with field. The spilled body is
+// encoded straight into the JSON, without holding the body or its encoding in memory twice.
+func (b *requestBody) marshal(event any, base64Encoded bool) ([]byte, error) {
+	eventByte, err := marshalEvent(event)
+	if err != nil || b.file == nil {
+		return eventByte, err
+	}
+
+	placeholder := []byte(`"` + b.placeholder + `"`)
+
+	i := bytes.Index(eventByte, placeholder)
+	if i < 0 {
+		return nil, errors.New("[in lambdalocal.requestBody.marshal] event has no body field")
+	}
+
+	var buf bytes.Buffer
+
+	// base64 has an exact size, text grows by its escaped characters
+	buf.Grow(len(eventByte) + base64.StdEncoding.EncodedLen(int(b.size)))
+	buf.Write(eventByte[:i+1])
+
+	body := io.NewSectionReader(b.file, 0, b.size)
+
+	if base64Encoded {
+		encoder := base64.NewEncoder(base64.StdEncoding, &buf)
+		if _, err = io.Copy(encoder, body); err == nil {
+			err = encoder.Close()
+		}
+	} else {
+		_, err = io.Copy(&jsonStringWriter{buf: &buf}, body)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.requestBody.marshal] encode body failed: %w", err)
+	}
+
+	buf.Write(eventByte[i+len(placeholder)-1:])
+
+	return buf.Bytes(), nil
+}
+
+// incompleteRune returns the length of the incomplete UTF-8 sequence p ends with, which is written
+// with the next chunk.
+func incompleteRune(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return 0
+			}
+
+			return len(p) - i
+		}
+	}
+
+	return 0
+}
+
+// utf8Validator checks that what is written to it is valid UTF-8, across the chunks it is written
+// in.
+type utf8Validator struct {
+	pending []byte
+	valid   bool
+}
+
+func (v *utf8Validator) Write(p []byte) (int, error) {
+	data := append(v.pending, p...)
+	complete := len(data) - incompleteRune(data)
+
+	v.valid = v.valid && utf8.Valid(data[:complete])
+	v.pending = append(v.pending[:0], data[complete:]...)
+
+	return len(p), nil
+}
+
+// done reports whether everything written was valid UTF-8.
+func (v *utf8Validator) done() bool {
+	return v.valid && len(v.pending) == 0
+}
+
+// jsonStringWriter writes valid UTF-8 to buf escaped as the content of a JSON string, without
+// escaping HTML like marshalEvent.
+type jsonStringWriter struct {
+	buf     *bytes.Buffer
+	pending []byte
+}
+
+func (j *jsonStringWriter) Write(p []byte) (int, error) {
+	data := append(j.pending, p...)
+	complete := len(data) - incompleteRune(data)
+
+	appendJSONString(j.buf, data[:complete])
+	j.pending = append(j.pending[:0], data[complete:]...)
+
+	return len(p), nil
+}
+
+// appendJSONString writes s to buf with the characters JSON strings can't contain escaped, and the
+// line and paragraph separators that encoding/json escapes too.
+func appendJSONString(buf *bytes.Buffer, s []byte) {
+	const hex = "0123456789abcdef"
+
+	start := 0
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c >= utf8.RuneSelf:
+			// U+2028 and U+2029 are E2 80 A8 and E2 80 A9
+			if c != 0xE2 || i+2 >= len(s) || s[i+1] != 0x80 || (s[i+2] != 0xA8 && s[i+2] != 0xA9) {
+				i++
+
+				continue
+			}
+
+			buf.Write(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hex[s[i+2]&0xF])
+
+			i += 3
+			start = i
+
+			continue
+		case c >= ' ' && c != '"' && c != '\\':
+			i++
+
+			continue
+		}
+
+		buf.Write(s[start:i])
+
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xF])
+		}
+
+		i++
+		start = i
+	}
+
+	buf.Write(s[start:])
+}
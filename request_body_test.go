@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBody(t *testing.T) {
+	t.Parallel()
+
+	// multi-byte characters and escapes of the text cross the chunks the body is copied in
+	text := strings.Repeat("héllo \"wörld\" \\ <a&b>\n\t\x01 €😀   ", 5000)
+
+	tests := map[string]struct {
+		body        string
+		binary      bool
+		memoryLimit int64
+		spilled     bool
+	}{
+		"small text": {
+			body:        `{"message":"<hello>"}`,
+			memoryLimit: bodyMemoryLimit,
+		},
+		"small binary": {
+			body:        "\x89PNG\r\n",
+			binary:      true,
+			memoryLimit: bodyMemoryLimit,
+		},
+		"spilled text": {
+			body:        text,
+			memoryLimit: 1024,
+			spilled:     true,
+		},
+		"spilled binary": {
+			body:        text,
+			binary:      true,
+			memoryLimit: 1024,
+			spilled:     true,
+		},
+		"spilled invalid utf-8": {
+			body:        text + "\xff",
+			memoryLimit: 1024,
+			spilled:     true,
+		},
+		"body at the memory limit": {
+			body:        "0123456789",
+			memoryLimit: 10,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				body, err := readRequestBody(strings.NewReader(tc.body), tc.memoryLimit)
+				require.NoError(t, err)
+
+				assert.Equal(t, tc.spilled, body.file != nil)
+
+				field, isBase64Encoded := body.field(tc.binary)
+
+				eventByte, err := body.marshal(
+					genericAPIEvent{Path: "/upload", Body: field, IsBase64Encoded: isBase64Encoded},
+					isBase64Encoded,
+				)
+				require.NoError(t, err)
+				require.NoError(t, body.Close())
+
+				// the event is the one built from the body in memory
+				expectedBody, expectedBase64Encoded := encodeBody([]byte(tc.body), tc.binary)
+
+				var event genericAPIEvent
+				require.NoError(t, json.Unmarshal(eventByte, &event))
+
+				assert.Equal(t, "/upload", event.Path)
+				assert.Equal(t, expectedBase64Encoded, event.IsBase64Encoded)
+				assert.Equal(t, expectedBody, event.Body)
+
+				if body.file != nil {
+					_, err = os.Stat(body.file.Name())
+					assert.True(t, os.IsNotExist(err))
+				}
+			},
+		)
+	}
+}
+
+func TestJSONStringWriter(t *testing.T) {
+	t.Parallel()
+
+	text := "quote \" backslash \\ <html> & newline \n tab \t bell \x07 é € 😀    "
+
+	var buf bytes.Buffer
+
+	buf.WriteByte('"')
+
+	// written a byte at a time, every multi-byte character is split
+	writer := &jsonStringWriter{buf: &buf}
+	for i := range len(text) {
+		_, _ = writer.Write([]byte{text[i]})
+	}
+
+	buf.WriteByte('"')
+
+	expected, err := marshalEvent(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, string(expected), buf.String())
+}
+
+func TestUTF8Validator(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		text     string
+		expected bool
+	}{
+		"ascii":           {text: "hello", expected: true},
+		"multi-byte":      {text: "é € 😀", expected: true},
+		"invalid byte":    {text: "hello \xff", expected: false},
+		"truncated rune":  {text: "hello \xf0\x9f\x98", expected: false},
+		"invalid in rune": {text: "\xe2\x28\xa1", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				validator := &utf8Validator{valid: true}
+				for i := range len(tc.text) {
+					_, _ = validator.Write([]byte{tc.text[i]})
+				}
+
+				assert.Equal(t, tc.expected, validator.done())
+			},
+		)
+	}
+}
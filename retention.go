@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// recordRetention limits the recordings kept in the store, the newest are kept. Zero values don't
+// limit.
+type recordRetention struct {
+	maxAge   time.Duration
+	maxBytes int64
+	maxCount int
+}
+
+func (r recordRetention) enabled() bool {
+	return r.maxAge > 0 || r.maxBytes > 0 || r.maxCount > 0
+}
+
+// prune deletes the recordings of s beyond the retention and returns the number deleted. The age
+// of a recording is the start of its invocation, from its key.
+func (r recordRetention) prune(s store, now time.Time) (int, error) {
+	if !r.enabled() {
+		return 0, nil
+	}
+
+	entries, err := s.list(recordingsPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("[in lambdalocal.recordRetention.prune] list failed: %w", err)
+	}
+
+	var (
+		count, deleted int
+		size           int64
+	)
+
+	// keys start with the start time, so the newest recordings are listed last
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		startedAt, ok := recordingTime(entry.key)
+		if !ok {
+			continue
+		}
+
+		count++
+		size += entry.size
+
+		if (r.maxCount <= 0 || count <= r.maxCount) &&
+			(r.maxBytes <= 0 || size <= r.maxBytes) &&
+			(r.maxAge <= 0 || now.Sub(startedAt) <= r.maxAge) {
+			continue
+		}
+
+		if err = s.delete(entry.key); err != nil {
+			return deleted, fmt.Errorf("[in lambdalocal.recordRetention.prune] delete '%s' failed: %w", entry.key, err)
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// recordingTime returns the start time of the invocation of the recording at key, false for keys
+// that aren't recordings.
+func recordingTime(key string) (time.Time, bool) {
+	name := strings.TrimPrefix(key, recordingsPrefix)
+	if len(name) < len(recordingTimeFormat) {
+		return time.Time{}, false
+	}
+
+	startedAt, err := time.Parse(recordingTimeFormat, name[:len(recordingTimeFormat)])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return startedAt, true
+}
+
+// RunRecordPrune deletes the recordings of s beyond retention and writes how many were deleted
+// to w.
+func RunRecordPrune(w io.Writer, s store, retention recordRetention, now time.Time) error {
+	deleted, err := retention.prune(s, now)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunRecordPrune] %w", err)
+	}
+
+	if _, err = fmt.Fprintf(w, "Deleted %d recordings\n", deleted); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunRecordPrune] write failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordRetentionPrune(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		retention recordRetention
+		wantIDs   []string
+	}{
+		"no retention": {
+			retention: recordRetention{},
+			wantIDs:   []string{"1", "2", "3", "4"},
+		},
+		"max age": {
+			retention: recordRetention{maxAge: 3 * 24 * time.Hour},
+			wantIDs:   []string{"3", "4"},
+		},
+		"max count": {
+			retention: recordRetention{maxCount: 3},
+			wantIDs:   []string{"2", "3", "4"},
+		},
+		"max size": {
+			retention: recordRetention{maxBytes: 250},
+			wantIDs:   []string{"4"},
+		},
+		"strictest limit": {
+			retention: recordRetention{maxAge: 10 * 24 * time.Hour, maxCount: 2},
+			wantIDs:   []string{"3", "4"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				s := newMemoryStore()
+				for i, day := range []int{1, 5, 8, 10} {
+					key := recordingKey(string(rune('1'+i)), time.Date(2024, 3, day, 9, 0, 0, 0, time.UTC))
+					require.NoError(t, s.write(key, bytes.Repeat([]byte("x"), 200)))
+				}
+
+				// entries that aren't recordings are kept
+				require.NoError(t, s.write(recordingsPrefix+"notes.txt", []byte("notes")))
+
+				deleted, err := tt.retention.prune(s, now)
+				require.NoError(t, err)
+				assert.Equal(t, 4-len(tt.wantIDs), deleted)
+
+				entries, err := s.list(recordingsPrefix)
+				require.NoError(t, err)
+
+				var ids []string
+				for _, entry := range entries {
+					if _, id, ok := strings.Cut(entry.key, "Z-"); ok {
+						ids = append(ids, strings.TrimSuffix(id, ".json"))
+					}
+				}
+
+				assert.Equal(t, tt.wantIDs, ids)
+				assert.Len(t, entries, len(ids)+1)
+			},
+		)
+	}
+}
+
+func TestRecordingTime(t *testing.T) {
+	t.Parallel()
+
+	startedAt := time.Date(2024, 3, 1, 9, 30, 0, 1500, time.UTC)
+
+	got, ok := recordingTime(recordingKey("abc", startedAt))
+	require.True(t, ok)
+	assert.Equal(t, startedAt, got)
+
+	_, ok = recordingTime(recordingsPrefix + "notes.txt")
+	assert.False(t, ok)
+}
+
+func TestRunRecordPrune(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+	require.NoError(t, s.write(recordingKey("old", time.Unix(0, 0)), []byte("{}")))
+	require.NoError(t, s.write(recordingKey("new", time.Now()), []byte("{}")))
+
+	var w bytes.Buffer
+	require.NoError(t, RunRecordPrune(&w, s, recordRetention{maxAge: time.Hour}, time.Now()))
+	assert.Equal(t, "Deleted 1 recordings\n", w.String())
+
+	entries, err := s.list(recordingsPrefix)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].key, "-new.json")
+}
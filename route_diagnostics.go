@@ -0,0 +1,159 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// routeDiagnostic tells why a resource of a template, or an event of a function, didn't become a
+// route.
+type routeDiagnostic struct {
+	// resource is the path of the resource in the template, like Resources.Hello.Events.Api, or
+	// empty for the template itself.
+	resource string
+	reason   string
+}
+
+func (d routeDiagnostic) String() string {
+	return cmp.Or(d.resource, "template") + ": " + d.reason
+}
+
+// routeDiagnostics explains why parseTemplate finds no routes in the template at templatePath: it
+// returns why each of its resources and events, and those of its nested stacks, was skipped.
+func routeDiagnostics(templatePath string, reader fileReader, overrides map[string]string) []routeDiagnostic {
+	return stackRouteDiagnostics(templatePath, reader, overrides, "", 0)
+}
+
+// stackRouteDiagnostics returns the diagnostics of a nested stack depth levels down, prefix is the
+// path of its resource in the parent template.
+func stackRouteDiagnostics(
+	templatePath string,
+	reader fileReader,
+	overrides map[string]string,
+	prefix string,
+	depth int,
+) []routeDiagnostic {
+	data, err := reader.read(templatePath)
+	if err != nil {
+		return []routeDiagnostic{{resource: prefix, reason: "can't be read: " + err.Error()}}
+	}
+
+	if isTerraformJSON(data) {
+		return []routeDiagnostic{
+			{
+				resource: prefix,
+				reason: fmt.Sprintf(
+					"Terraform JSON without %s resources targeting an AWS_PROXY %s, or %s resources with a target",
+					terraformRouteType,
+					terraformIntegrationType,
+					terraformHTTPAPIType,
+				),
+			},
+		}
+	}
+
+	index, err := templates.index(templatePath, data, overrides)
+	if err != nil {
+		return []routeDiagnostic{{resource: prefix, reason: "can't be parsed: " + err.Error()}}
+	}
+
+	SAMData := index.sam
+
+	if len(SAMData.Resources) == 0 {
+		return []routeDiagnostic{{resource: prefix, reason: "the template has no Resources"}}
+	}
+
+	// nested stacks are only followed to local templates
+	stacks := make(map[string]nestedStack)
+	for _, stack := range nestedStacks(templatePath, SAMData) {
+		stacks[stack.name] = stack
+	}
+
+	var diagnostics []routeDiagnostic
+
+	for _, name := range slices.Sorted(maps.Keys(SAMData.Resources)) {
+		resource := SAMData.Resources[name]
+		path := strings.TrimPrefix(prefix+".Resources."+name, ".")
+
+		if stack, ok := stacks[name]; ok && depth < maxNestedStackDepth {
+			diagnostics = append(
+				diagnostics,
+				stackRouteDiagnostics(stack.path, reader, stack.parameters, path, depth+1)...,
+			)
+
+			continue
+		}
+
+		if len(resource.Properties.Events) == 0 {
+			diagnostics = append(
+				diagnostics,
+				routeDiagnostic{resource: path, reason: resourceSkipReason(resource.Type)},
+			)
+
+			continue
+		}
+
+		for _, eventName := range slices.Sorted(maps.Keys(resource.Properties.Events)) {
+			event := resource.Properties.Events[eventName]
+
+			reason := fmt.Sprintf("%s events aren't routes, only Api and HttpApi events are", event.Type)
+
+			switch {
+			case event.Type == "":
+				reason = "the event has no Type"
+			case strings.EqualFold(event.Type, eventTypeAPI) && event.Type != eventTypeAPI,
+				strings.EqualFold(event.Type, eventTypeHTTPAPI) && event.Type != eventTypeHTTPAPI:
+				reason = fmt.Sprintf("event types are case sensitive, '%s' isn't Api or HttpApi", event.Type)
+			case event.Type == eventTypeAPI && SAMData.Resources[refName(event.Properties.RestAPIID)].Type ==
+				serverlessHTTPAPIType:
+				reason = fmt.Sprintf(
+					"the Api event references the HttpApi '%s', use an HttpApi event",
+					refName(event.Properties.RestAPIID),
+				)
+			case event.Type == eventTypeHTTPAPI && SAMData.Resources[refName(event.Properties.APIID)].Type ==
+				serverlessAPIType:
+				reason = fmt.Sprintf(
+					"the HttpApi event references the Api '%s', use an Api event",
+					refName(event.Properties.APIID),
+				)
+			case event.Type == eventTypeAPI && event.Properties.Path == "":
+				reason = "the Api event has no Path"
+			case event.Type == eventTypeAPI && event.Properties.Method == "":
+				reason = "the Api event has no Method"
+			case event.Type == eventTypeAPI || event.Type == eventTypeHTTPAPI:
+				reason = "the event is a route"
+			}
+
+			diagnostics = append(diagnostics, routeDiagnostic{resource: path + ".Events." + eventName, reason: reason})
+		}
+	}
+
+	return diagnostics
+}
+
+// resourceSkipReason returns why a resource of type typ without events didn't become a route.
+func resourceSkipReason(typ string) string {
+	switch typ {
+	case "":
+		return "the resource has no Type"
+	case "AWS::Serverless::Function":
+		return "the function has no Events, only its Api and HttpApi events become routes"
+	case serverlessAPIType, serverlessHTTPAPIType:
+		return "no function has an event for the API, and its OpenAPI definition has no operations with an " +
+			"aws_proxy integration of a function"
+	case cfnRouteType:
+		return "the route has no Target of an AWS_PROXY " + cfnIntegrationType +
+			" referencing a function with Fn::GetAtt"
+	case cfnMethodType:
+		return "the method has no AWS_PROXY Integration referencing a function with Fn::GetAtt"
+	case cfnHTTPAPIType:
+		return "the API has no routes and no Target of a quick create API"
+	case serverlessApplicationType, cfnStackType:
+		return "the nested template isn't a local file, only local templates are read"
+	}
+
+	return fmt.Sprintf("%s resources aren't routes", typ)
+}
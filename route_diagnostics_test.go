@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template string
+		// nested are the templates of nested stacks, by path.
+		nested              map[string]string
+		err                 error
+		expectedDiagnostics []string
+	}{
+		"skipped resources and events": {
+			template: `
+Resources:
+  Orders:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Queue:
+          Type: SQS
+        Api:
+          Type: HTTPApi
+        Untyped:
+          Properties:
+            Path: /orders
+  Payments:
+    Type: AWS::Serverless::Function
+  Table:
+    Type: AWS::DynamoDB::Table
+  Route:
+    Type: AWS::ApiGatewayV2::Route
+    Properties:
+      RouteKey: GET /orders
+  Child:
+    Type: AWS::Serverless::Application
+    Properties:
+      Location:
+        ApplicationId: arn:aws:serverlessrepo:us-east-1:123456789012:applications/child
+        SemanticVersion: 1.0.0
+`,
+			expectedDiagnostics: []string{
+				"Resources.Child: the nested template isn't a local file, only local templates are read",
+				"Resources.Orders.Events.Api: event types are case sensitive, 'HTTPApi' isn't Api or HttpApi",
+				"Resources.Orders.Events.Queue: SQS events aren't routes, only Api and HttpApi events are",
+				"Resources.Orders.Events.Untyped: the event has no Type",
+				"Resources.Payments: the function has no Events, only its Api and HttpApi events become routes",
+				"Resources.Route: the route has no Target of an AWS_PROXY AWS::ApiGatewayV2::Integration " +
+					"referencing a function with Fn::GetAtt",
+				"Resources.Table: AWS::DynamoDB::Table resources aren't routes",
+			},
+		},
+		"nested stack": {
+			template: `
+Resources:
+  Child:
+    Type: AWS::CloudFormation::Stack
+    Properties:
+      TemplateURL: child.yaml
+`,
+			nested: map[string]string{"child.yaml": "Resources:\n  Hello:\n    Type: AWS::Serverless::Function\n"},
+			expectedDiagnostics: []string{
+				"Resources.Child.Resources.Hello: the function has no Events, only its Api and HttpApi events " +
+					"become routes",
+			},
+		},
+		"no resources": {
+			template:            "Parameters: {}",
+			expectedDiagnostics: []string{"template: the template has no Resources"},
+		},
+		"unreadable template": {
+			err:                 errors.New("no such file"),
+			expectedDiagnostics: []string{"template: can't be read: no such file"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := new(mockOSFileReader)
+				reader.On("read", "template.yaml").Return([]byte(tc.template), tc.err)
+
+				for path, template := range tc.nested {
+					reader.On("read", path).Return([]byte(template), nil)
+				}
+
+				var diagnostics []string
+				for _, diagnostic := range routeDiagnostics("template.yaml", reader, nil) {
+					diagnostics = append(diagnostics, diagnostic.String())
+				}
+
+				assert.Equal(t, tc.expectedDiagnostics, diagnostics)
+			},
+		)
+	}
+}
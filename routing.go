@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// routingRule sends the requests of a route that match its conditions to another target, a
+// function of the template or a mock, like feature flags or canary releases do.
+type routingRule struct {
+	When routingCondition `yaml:"when"`
+	// Function is the logical ID of the function to invoke, with the address of its entry in the
+	// config.
+	Function string `yaml:"function"`
+	// Mock answers with a templated response instead of invoking a function.
+	Mock *mockResponse `yaml:"mock"`
+}
+
+// routingCondition matches requests. All of its matchers have to match, a condition without
+// matchers matches every request.
+type routingCondition struct {
+	// Headers match the values of request headers.
+	Headers map[string]string `yaml:"headers"`
+	// Query matches the values of query string parameters.
+	Query map[string]string `yaml:"query"`
+	// Body matches fields of JSON bodies by their dotted path, like user.plan or items.0.sku.
+	Body map[string]string `yaml:"body"`
+}
+
+// conditionalRoute is a parsed routingRule.
+type conditionalRoute struct {
+	condition routingCondition
+	function  string
+	mock      *mockRoute
+}
+
+// stickySession names the cookie or header that identifies the session of a client. Requests of
+// a session always go to the same of the targets whose conditions match, picked by consistent
+// hashing, so a client keeps its side during an A/B comparison.
+type stickySession struct {
+	Cookie string `yaml:"cookie"`
+	Header string `yaml:"header"`
+}
+
+// conditionalRoutes are the routing rules of the project config.
+type conditionalRoutes struct {
+	// rules are the rules of each route key, in order.
+	rules  map[string][]conditionalRoute
+	sticky stickySession
+}
+
+// parseRouting parses the routing rules of the project config, keyed by route like mocks.
+func parseRouting(routing map[string][]routingRule, sticky stickySession) (conditionalRoutes, error) {
+	routes := conditionalRoutes{rules: make(map[string][]conditionalRoute, len(routing)), sticky: sticky}
+
+	for key, rules := range routing {
+		route, err := mockRouteOf(key)
+		if err != nil {
+			return conditionalRoutes{}, fmt.Errorf("[in lambdalocal.parseRouting] %w", err)
+		}
+
+		for i, rule := range rules {
+			parsed := conditionalRoute{condition: rule.When, function: rule.Function}
+
+			if (rule.Function == "") == (rule.Mock == nil) {
+				return conditionalRoutes{}, fmt.Errorf(
+					"[in lambdalocal.parseRouting] rule %d of '%s' must set either function or mock",
+					i,
+					key,
+				)
+			}
+
+			if rule.Mock != nil {
+				mock, err := parseMock(route, *rule.Mock)
+				if err != nil {
+					return conditionalRoutes{}, fmt.Errorf(
+						"[in lambdalocal.parseRouting] mock of rule %d of '%s': %w",
+						i,
+						key,
+						err,
+					)
+				}
+
+				parsed.mock = &mock
+			}
+
+			routes.rules[route.routeKey()] = append(routes.rules[route.routeKey()], parsed)
+		}
+	}
+
+	return routes, nil
+}
+
+// handler returns a handler that sends requests of route to the target of the first rule whose
+// condition matches, and the others to fallback. Requests of a sticky session go to one of the
+// matching targets and fallback instead, the same for every request of the session.
+// functionHandler returns the handler invoking a function for the route.
+func (c conditionalRoutes) handler(
+	route apiRoute,
+	fallback http.Handler,
+	functionHandler func(function string) http.Handler,
+	logger *slog.Logger,
+) http.Handler {
+	rules := c.rules[route.routeKey()]
+	if len(rules) == 0 {
+		return fallback
+	}
+
+	targets := make([]http.Handler, 0, len(rules))
+	readBody := false
+
+	for _, rule := range rules {
+		if rule.mock != nil {
+			targets = append(targets, rule.mock.handler(logger))
+		} else {
+			targets = append(targets, functionHandler(rule.function))
+		}
+
+		readBody = readBody || len(rule.condition.Body) > 0
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var body any
+
+			// the body is decoded for the matchers and restored for the target
+			if readBody {
+				data, err := io.ReadAll(r.Body)
+				if err != nil {
+					logger.Error("[in lambdalocal.conditionalRoutes.handler] read body failed", "err", err)
+					writeGatewayError(w, r, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(data))
+				_ = json.Unmarshal(data, &body)
+			}
+
+			var matching []int
+
+			for i, rule := range rules {
+				if rule.condition.matches(r, body) {
+					matching = append(matching, i)
+				}
+			}
+
+			if session := c.sticky.session(r); session != "" && len(matching) > 0 {
+				i := stickyTarget(session, rules, matching)
+				if i < 0 {
+					logger.Info(
+						fmt.Sprintf("Routing session %s to the route's target of %s", session, route.routeKey()),
+					)
+					fallback.ServeHTTP(w, r)
+
+					return
+				}
+
+				logger.Info(
+					fmt.Sprintf(
+						"Routing session %s to %s by rule %d of %s",
+						session,
+						rules[i].target(),
+						i,
+						route.routeKey(),
+					),
+				)
+				targets[i].ServeHTTP(w, r)
+
+				return
+			}
+
+			if len(matching) > 0 {
+				i := matching[0]
+				logger.Info(
+					fmt.Sprintf("Routing request to %s by rule %d of %s", rules[i].target(), i, route.routeKey()),
+				)
+				targets[i].ServeHTTP(w, r)
+
+				return
+			}
+
+			fallback.ServeHTTP(w, r)
+		},
+	)
+}
+
+// session returns the session of the request, empty when it has none or sessions aren't sticky.
+func (s stickySession) session(r *http.Request) string {
+	if s.Cookie != "" {
+		if cookie, err := r.Cookie(s.Cookie); err == nil && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+
+	if s.Header != "" {
+		return r.Header.Get(s.Header)
+	}
+
+	return ""
+}
+
+// stickyTarget picks the target of session among the matching rules and the route's own target,
+// returned as -1. Rendezvous hashing keeps most sessions on their target when rules are added or
+// removed.
+func stickyTarget(session string, rules []conditionalRoute, matching []int) int {
+	score := func(target string) uint64 {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(session + "\x00" + target))
+
+		return hash.Sum64()
+	}
+
+	picked, best := -1, score("route")
+
+	for _, i := range matching {
+		target := rules[i].target()
+		if rules[i].mock != nil {
+			target += " " + strconv.Itoa(i)
+		}
+
+		if s := score(target); s > best {
+			picked, best = i, s
+		}
+	}
+
+	return picked
+}
+
+// target describes the target of the rule for logs.
+func (r conditionalRoute) target() string {
+	if r.mock != nil {
+		return "mock"
+	}
+
+	return "function " + r.function
+}
+
+// matches reports if the request with the decoded JSON body matches the condition.
+func (c routingCondition) matches(r *http.Request, body any) bool {
+	for name, value := range c.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+
+	query := r.URL.Query()
+	for name, value := range c.Query {
+		if !query.Has(name) || query.Get(name) != value {
+			return false
+		}
+	}
+
+	for path, value := range c.Body {
+		field, err := jsonField(body, path)
+		if err != nil || fmt.Sprint(field) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// jsonField returns the field of a decoded JSON value at the dotted path. Array elements are
+// selected by their index.
+func jsonField(value any, path string) (any, error) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			field, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("no field '%s'", key)
+			}
+
+			value = field
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("no element '%s'", key)
+			}
+
+			value = v[i]
+		default:
+			return nil, errors.New("not an object or array")
+		}
+	}
+
+	return value, nil
+}
+
+// unknownRoutes returns the routes with rules that aren't in routes, sorted.
+func (c conditionalRoutes) unknownRoutes(routes []string) []string {
+	known := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		known[route] = true
+	}
+
+	var unknown []string
+
+	for route := range c.rules {
+		if !known[route] {
+			unknown = append(unknown, route)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
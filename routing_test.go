@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRouting(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		routing        map[string][]routingRule
+		expectedRoutes []string
+		expectedErrStr string
+	}{
+		"rules are keyed by route": {
+			routing: map[string][]routingRule{
+				"/users":          {{Function: "UsersV2"}},
+				"GET /users/{id}": {{Mock: &mockResponse{Body: "{}"}}, {Function: "UsersV2"}},
+			},
+			expectedRoutes: []string{"ANY /users", "GET /users/{id}"},
+		},
+		"invalid route": {
+			routing:        map[string][]routingRule{"users": {{Function: "UsersV2"}}},
+			expectedErrStr: "invalid mock route 'users'",
+		},
+		"rule without target": {
+			routing:        map[string][]routingRule{"GET /users": {{}}},
+			expectedErrStr: "rule 0 of 'GET /users' must set either function or mock",
+		},
+		"rule with both targets": {
+			routing: map[string][]routingRule{
+				"GET /users": {{Function: "UsersV2", Mock: &mockResponse{}}},
+			},
+			expectedErrStr: "rule 0 of 'GET /users' must set either function or mock",
+		},
+		"invalid mock": {
+			routing: map[string][]routingRule{
+				"GET /users": {{Mock: &mockResponse{Body: "{{ .Path"}}},
+			},
+			expectedErrStr: "mock of rule 0 of 'GET /users': body:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				routing, err := parseRouting(tc.routing, stickySession{})
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Empty(t, routing.unknownRoutes(tc.expectedRoutes))
+				assert.Len(t, routing.rules, len(tc.expectedRoutes))
+			},
+		)
+	}
+}
+
+func TestConditionalRoutesHandler(t *testing.T) {
+	t.Parallel()
+
+	routing, err := parseRouting(
+		map[string][]routingRule{
+			"POST /users": {
+				{When: routingCondition{Headers: map[string]string{"X-Canary": "true"}}, Function: "Canary"},
+				{When: routingCondition{Query: map[string]string{"beta": "1"}}, Function: "Beta"},
+				{
+					When: routingCondition{Body: map[string]string{"user.plan": "pro", "items.1.qty": "2"}},
+					Mock: &mockResponse{Body: `mock {{ .JSON.user.plan }}`},
+				},
+			},
+		},
+		stickySession{},
+	)
+	require.NoError(t, err)
+
+	// targets answer with their name and the body they received
+	target := func(name string) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				_, _ = w.Write([]byte(name + " " + string(body)))
+			},
+		)
+	}
+
+	route := apiRoute{method: http.MethodPost, path: "/users"}
+	handler := routing.handler(
+		route,
+		target("fallback"),
+		func(function string) http.Handler { return target(function) },
+		slog.Default(),
+	)
+
+	tests := map[string]struct {
+		url          string
+		headers      map[string]string
+		body         string
+		expectedBody string
+	}{
+		"header": {
+			url:          "/users",
+			headers:      map[string]string{"X-Canary": "true"},
+			body:         `{}`,
+			expectedBody: "Canary {}",
+		},
+		"query": {
+			url:          "/users?beta=1",
+			body:         `{"user":{"plan":"free"}}`,
+			expectedBody: `Beta {"user":{"plan":"free"}}`,
+		},
+		"first matching rule wins": {
+			url:          "/users?beta=1",
+			headers:      map[string]string{"X-Canary": "true"},
+			expectedBody: "Canary ",
+		},
+		"body": {
+			url:          "/users",
+			body:         `{"user":{"plan":"pro"},"items":[{"qty":1},{"qty":2}]}`,
+			expectedBody: "mock pro",
+		},
+		"partial body match falls back": {
+			url:          "/users",
+			body:         `{"user":{"plan":"pro"},"items":[{"qty":1}]}`,
+			expectedBody: `fallback {"user":{"plan":"pro"},"items":[{"qty":1}]}`,
+		},
+		"no match": {
+			url:          "/users?beta=2",
+			headers:      map[string]string{"X-Canary": "false"},
+			body:         `not json`,
+			expectedBody: "fallback not json",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				r := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(tc.body))
+				for header, value := range tc.headers {
+					r.Header.Set(header, value)
+				}
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				assert.Equal(t, tc.expectedBody, w.Body.String())
+			},
+		)
+	}
+
+	// routes without rules keep their handler
+	w := httptest.NewRecorder()
+	routing.handler(apiRoute{method: http.MethodGet, path: "/users"}, target("fallback"), nil, slog.Default()).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, "fallback ", w.Body.String())
+}
+
+func TestConditionalRoutesStickySessions(t *testing.T) {
+	t.Parallel()
+
+	routing, err := parseRouting(
+		map[string][]routingRule{
+			"GET /users": {
+				{Function: "UsersV2"},
+				{When: routingCondition{Query: map[string]string{"beta": "1"}}, Function: "Beta"},
+			},
+		},
+		stickySession{Cookie: "session", Header: "X-Session-Id"},
+	)
+	require.NoError(t, err)
+
+	target := func(name string) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(name))
+			},
+		)
+	}
+
+	handler := routing.handler(
+		apiRoute{method: http.MethodGet, path: "/users"},
+		target("Users"),
+		func(function string) http.Handler { return target(function) },
+		slog.Default(),
+	)
+
+	serve := func(url string, header http.Header) string {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		r.Header = header
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Body.String()
+	}
+
+	// without a session the first matching rule wins
+	assert.Equal(t, "UsersV2", serve("/users", http.Header{}))
+
+	// sessions are split between the matching targets and the route's target, and keep theirs
+	seen := make(map[string]bool)
+
+	for i := range 50 {
+		session := http.Header{"X-Session-Id": {fmt.Sprint("session-", i)}}
+		picked := serve("/users", session)
+
+		for range 3 {
+			assert.Equal(t, picked, serve("/users", session))
+		}
+
+		seen[picked] = true
+	}
+
+	assert.Equal(t, map[string]bool{"Users": true, "UsersV2": true}, seen)
+
+	// the cookie identifies the session before the header
+	both := http.Header{"Cookie": {"session=abc"}, "X-Session-Id": {"xyz"}}
+	assert.Equal(t, serve("/users", http.Header{"Cookie": {"session=abc"}}), serve("/users", both))
+
+	// only targets whose condition matches are picked
+	for i := range 50 {
+		picked := serve("/users", http.Header{"X-Session-Id": {fmt.Sprint("session-", i)}})
+		assert.NotEqual(t, "Beta", picked)
+	}
+}
+
+func TestConditionalRoutesUnknownRoutes(t *testing.T) {
+	t.Parallel()
+
+	routing := conditionalRoutes{
+		rules: map[string][]conditionalRoute{"GET /users": nil, "GET /orders": nil, "$default": nil},
+	}
+
+	assert.Equal(t, []string{"$default", "GET /orders"}, routing.unknownRoutes([]string{"GET /users"}))
+}
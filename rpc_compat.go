@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/rpc"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// lambdaGoModule is the module whose messages package defines the RPC protocol of Go lambdas.
+const lambdaGoModule = "github.com/aws/aws-lambda-go"
+
+// rpcCompatibilityOnce limits the compatibility table to once per process in api mode.
+var rpcCompatibilityOnce sync.Once //nolint:gochecknoglobals
+
+// rpcDriftError is returned by LambdaRPCClient when a call fails because the process on the other
+// end doesn't speak the RPC protocol of the messages package lambdalocal is built with.
+type rpcDriftError struct {
+	serviceMethod string
+	hint          string
+	err           error
+}
+
+func (e *rpcDriftError) Error() string {
+	return fmt.Sprintf(
+		"lambda RPC protocol mismatch calling %s: %s. lambdalocal speaks the RPC protocol of aws-lambda-go %s, %s",
+		e.serviceMethod,
+		e.err,
+		lambdaGoVersion(),
+		e.hint,
+	)
+}
+
+func (e *rpcDriftError) Unwrap() error {
+	return e.err
+}
+
+// rpcDrift returns an rpcDriftError when err of a client.Call is caused by a protocol mismatch
+// with the handler, like a missing service or a gob decoding error, and nil otherwise.
+func rpcDrift(serviceMethod string, err error) error {
+	var (
+		serverErr rpc.ServerError
+		hint      string
+	)
+
+	switch {
+	case errors.As(err, &serverErr) && strings.Contains(string(serverErr), "can't find"):
+		hint = fmt.Sprintf(
+			"but the process on the address doesn't serve %s. Handlers built with -tags lambda.norpc or "+
+				"with another runtime need --protocol %s",
+			serviceMethod,
+			ProtocolRuntimeAPI,
+		)
+	case strings.Contains(err.Error(), "gob:"):
+		hint = fmt.Sprintf(
+			"but the handler encodes messages differently. Update %s in the handler's go.mod to %s, or "+
+				"use --protocol %s",
+			lambdaGoModule,
+			lambdaGoVersion(),
+			ProtocolRuntimeAPI,
+		)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF), errors.Is(err, rpc.ErrShutdown):
+		hint = fmt.Sprintf(
+			"but the handler closed the connection during the call. Check its output for a panic, or "+
+				"use --protocol %s if it isn't a Go handler served over RPC",
+			ProtocolRuntimeAPI,
+		)
+	default:
+		return nil
+	}
+
+	return &rpcDriftError{serviceMethod: serviceMethod, hint: hint, err: err}
+}
+
+// lambdaGoVersion returns the version of aws-lambda-go lambdalocal is built with.
+func lambdaGoVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown version)"
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == lambdaGoModule {
+			return dep.Version
+		}
+	}
+
+	return "(unknown version)"
+}
+
+// rpcCompatibilityTable describes which handlers can be invoked over RPC.
+func rpcCompatibilityTable() string {
+	rows := [][2]string{
+		{"aws-lambda-go of the handler", "invoke with"},
+		{"v1.0.0 to v1.27.x", "--protocol " + ProtocolRPC},
+		{"v1.28.0 and later", "--protocol " + ProtocolRPC + ", unless built with -tags lambda.norpc"},
+		{"built with -tags lambda.norpc", "--protocol " + ProtocolRuntimeAPI},
+		{"other runtimes", "--protocol " + ProtocolRuntimeAPI},
+	}
+
+	var b strings.Builder
+
+	_, _ = fmt.Fprintf(&b, "lambdalocal is built with %s %s.\n", lambdaGoModule, lambdaGoVersion())
+
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(&b, "  %-31s %s\n", row[0], row[1])
+	}
+
+	b.WriteString("Fields of the messages only one side knows about are dropped, so handlers of any v1 release " +
+		"work as long as they serve Function.Invoke.")
+
+	return b.String()
+}
+
+// logRPCDrift logs the compatibility table the first time an invocation fails with a protocol
+// mismatch.
+func logRPCDrift(logger *slog.Logger, err error) {
+	var drift *rpcDriftError
+	if !errors.As(err, &drift) {
+		return
+	}
+
+	rpcCompatibilityOnce.Do(
+		func() {
+			logger.Warn("lambda RPC compatibility:\n" + rpcCompatibilityTable())
+		},
+	)
+}
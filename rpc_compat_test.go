@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type otherService struct{}
+
+func (otherService) Ping(_ *struct{}, _ *struct{}) error {
+	return nil
+}
+
+func TestRPCDrift(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err          error
+		expectedHint string
+	}{
+		"missing service": {
+			err:          rpc.ServerError("rpc: can't find service Function.Invoke"),
+			expectedHint: "doesn't serve Function.Invoke",
+		},
+		"gob error": {
+			err:          fmt.Errorf("read failed: %w", errors.New("gob: type mismatch in decoder")),
+			expectedHint: "encodes messages differently",
+		},
+		"closed connection": {
+			err:          io.ErrUnexpectedEOF,
+			expectedHint: "closed the connection during the call",
+		},
+		"handler error": {
+			err: rpc.ServerError("handler failed"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				err := rpcDrift("Function.Invoke", tc.err)
+
+				if tc.expectedHint == "" {
+					assert.NoError(t, err)
+
+					return
+				}
+
+				var drift *rpcDriftError
+
+				require.ErrorAs(t, err, &drift)
+				assert.Contains(t, drift.Error(), tc.expectedHint)
+				assert.Contains(t, drift.Error(), "lambda RPC protocol mismatch calling Function.Invoke")
+				assert.ErrorIs(t, err, tc.err)
+			},
+		)
+	}
+}
+
+func TestLambdaRPCClientDrift(t *testing.T) {
+	t.Parallel()
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("Other", otherService{}))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go server.Accept(listener)
+
+	client := NewLambdaLambdaRPCClient(listener.Addr().String(), time.Second)
+
+	_, err = client.Invoke(context.Background(), []byte(`{}`))
+
+	var drift *rpcDriftError
+
+	require.ErrorAs(t, err, &drift)
+	assert.ErrorContains(t, err, "[in lambdalocal.invoke] lambda RPC protocol mismatch calling Function.Invoke")
+	assert.ErrorContains(t, err, "--protocol runtime-api")
+}
+
+func TestRPCCompatibilityTable(t *testing.T) {
+	t.Parallel()
+
+	table := rpcCompatibilityTable()
+
+	assert.Contains(t, table, "lambdalocal is built with "+lambdaGoModule)
+	assert.Contains(t, table, "built with -tags lambda.norpc   --protocol runtime-api")
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// scaffoldAddress is the address the handler of a scaffolded project listens on, the default
+// --address so the printed commands work without flags.
+const scaffoldAddress = "localhost:8000"
+
+// scaffoldLambdaVersion is the aws-lambda-go version required by scaffolded projects.
+const scaffoldLambdaVersion = "v1.47.0"
+
+// scaffoldKind is a project that scaffold can generate.
+type scaffoldKind struct {
+	// function is the logical ID of the function in the template.
+	function string
+	// handler is the directory of the handler package.
+	handler string
+	source  string
+	// resources are the resources of the template besides the function, with their indentation.
+	resources string
+	// events are the event sources of the function, with their indentation.
+	events string
+	// event returns the default event of the function.
+	event func() (any, error)
+	// usage is the command that runs the default event, or starts the local loop.
+	usage string
+}
+
+// scaffoldKinds are the projects of scaffold, by kind.
+var scaffoldKinds = map[string]scaffoldKind{ //nolint:gochecknoglobals
+	"api": {
+		function: "ApiFunction",
+		handler:  "cmd/api",
+		source:   scaffoldAPISource,
+		events: `        Hello:
+          Type: HttpApi
+          Properties:
+            Path: /hello/{name}
+            Method: get
+`,
+		event: func() (any, error) {
+			route := httpAPIRoute("/hello/{name}", "get", "")
+
+			event, err := exampleEvent(&route)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.RawMessage(event), nil
+		},
+		usage: `lambdalocal --run "go run ./cmd/api" api
+  curl localhost:8080/hello/world`,
+	},
+	"sqs": {
+		function: "QueueFunction",
+		handler:  "cmd/queue",
+		source:   scaffoldSQSSource,
+		resources: `  Queue:
+    Type: AWS::SQS::Queue
+`,
+		events: `        Messages:
+          Type: SQS
+          Properties:
+            Queue: !GetAtt Queue.Arn
+            BatchSize: 10
+            FunctionResponseTypes:
+              - ReportBatchItemFailures
+`,
+		event: func() (any, error) {
+			return sqsReceiveMessageEvent(scaffoldEventFields())
+		},
+		usage: `lambdalocal --run "go run ./cmd/queue" event --function QueueFunction`,
+	},
+	"stream": {
+		function: "StreamFunction",
+		handler:  "cmd/stream",
+		source:   scaffoldStreamSource,
+		resources: `  Table:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      BillingMode: PAY_PER_REQUEST
+      AttributeDefinitions:
+        - AttributeName: Id
+          AttributeType: N
+      KeySchema:
+        - AttributeName: Id
+          KeyType: HASH
+      StreamSpecification:
+        StreamViewType: NEW_AND_OLD_IMAGES
+`,
+		events: `        Changes:
+          Type: DynamoDB
+          Properties:
+            Stream: !GetAtt Table.StreamArn
+            StartingPosition: TRIM_HORIZON
+            BatchSize: 100
+`,
+		event: func() (any, error) {
+			return dynamoDBUpdateEvent(scaffoldEventFields())
+		},
+		usage: `lambdalocal --run "go run ./cmd/stream" event --function StreamFunction`,
+	},
+}
+
+// scaffoldFile is a file of a scaffolded project, its path is relative to the project.
+type scaffoldFile struct {
+	path string
+	data []byte
+}
+
+// RunScaffold writes a runnable example project of kind to dir: a handler using aws-lambda-go, a
+// SAM template, the default event of the function and a lambdalocal.yaml wired to the handler.
+// module is the module path of the go.mod written when dir has none. Existing files are kept
+// unless force is set.
+func RunScaffold(w io.Writer, kind, dir, module string, force bool) error {
+	scaffold, ok := scaffoldKinds[kind]
+	if !ok {
+		return fmt.Errorf(
+			"[in lambdalocal.RunScaffold] unknown kind '%s', must be one of %s",
+			kind,
+			strings.Join(slices.Sorted(maps.Keys(scaffoldKinds)), ", "),
+		)
+	}
+
+	event, err := scaffold.event()
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunScaffold] generate event failed: %w", err)
+	}
+
+	eventData, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunScaffold] marshal event failed: %w", err)
+	}
+
+	// an existing module gets the handler as a new package
+	_, err = os.Stat(filepath.Join(dir, "go.mod"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("[in lambdalocal.RunScaffold] stat go.mod failed: %w", err)
+	}
+
+	writeGoMod := err != nil
+
+	if writeGoMod && module == "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunScaffold] resolve directory failed: %w", err)
+		}
+
+		module = "example.com/" + filepath.Base(absDir)
+	}
+
+	config := projectConfig{
+		Functions: map[string]functionConfig{scaffold.function: {Address: scaffoldAddress}},
+	}
+
+	files := []scaffoldFile{
+		{filepath.Join(scaffold.handler, "main.go"), []byte(scaffold.source)},
+		{"template.yaml", []byte(scaffold.template())},
+		{filepath.Join("events", scaffold.function, defaultEventFile), append(eventData, '\n')},
+		{defaultConfigPath, renderInitConfig(config, map[string]string{scaffold.function: "./" + scaffold.handler})},
+	}
+
+	if writeGoMod {
+		goMod := fmt.Sprintf(
+			"module %s\n\ngo 1.24\n\nrequire github.com/aws/aws-lambda-go %s\n",
+			module,
+			scaffoldLambdaVersion,
+		)
+		files = append(files, scaffoldFile{"go.mod", []byte(goMod)})
+	}
+
+	for _, file := range files {
+		if err = writeInitFile(w, filepath.Join(dir, file.path), file.data, force); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunScaffold] write %s failed: %w", file.path, err)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "\nNext steps:")
+
+	if dir != "." {
+		_, _ = fmt.Fprintln(w, "  cd "+dir)
+	}
+
+	if writeGoMod {
+		_, _ = fmt.Fprintln(w, "  go mod tidy")
+	} else {
+		_, _ = fmt.Fprintln(w, "  go get github.com/aws/aws-lambda-go@"+scaffoldLambdaVersion)
+	}
+
+	_, _ = fmt.Fprintln(w, "  "+scaffold.usage)
+
+	return nil
+}
+
+// template returns the SAM template of the project.
+func (s scaffoldKind) template() string {
+	var b strings.Builder
+
+	b.WriteString("AWSTemplateFormatVersion: '2010-09-09'\n")
+	b.WriteString("Transform: AWS::Serverless-2016-10-31\n")
+	b.WriteString("Resources:\n")
+	b.WriteString(s.resources)
+	b.WriteString("  " + s.function + ":\n")
+	b.WriteString("    Type: AWS::Serverless::Function\n")
+	b.WriteString("    Metadata:\n")
+	b.WriteString("      BuildMethod: go1.x\n")
+	b.WriteString("    Properties:\n")
+	b.WriteString("      CodeUri: " + s.handler + "/\n")
+	b.WriteString("      Handler: bootstrap\n")
+	b.WriteString("      Runtime: provided.al2023\n")
+	b.WriteString("      Events:\n")
+	b.WriteString(s.events)
+
+	return b.String()
+}
+
+// scaffoldEventFields are the fields of the default events of scaffolded projects.
+func scaffoldEventFields() eventFields {
+	return eventFields{region: sqsDefaultRegion, now: time.Now().UTC().Truncate(time.Second)}
+}
+
+const scaffoldAPISource = `package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func handler(_ context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	name := request.PathParameters["name"]
+	if name == "" {
+		name = "world"
+	}
+
+	body, err := json.Marshal(map[string]string{"message": "Hello, " + name + "!"})
+	if err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
+`
+
+const scaffoldSQSSource = `package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// handler processes a batch of messages. Messages that fail are reported back, so only they are
+// retried.
+func handler(_ context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var response events.SQSEventResponse
+
+	for _, message := range event.Records {
+		if message.Body == "" {
+			log.Printf("message %s has no body", message.MessageId)
+
+			response.BatchItemFailures = append(
+				response.BatchItemFailures,
+				events.SQSBatchItemFailure{ItemIdentifier: message.MessageId},
+			)
+
+			continue
+		}
+
+		log.Printf("message %s: %s", message.MessageId, message.Body)
+	}
+
+	return response, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
+`
+
+const scaffoldStreamSource = `package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func handler(_ context.Context, event events.DynamoDBEvent) error {
+	for _, record := range event.Records {
+		keys, err := json.Marshal(record.Change.Keys)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("%s %s", record.EventName, keys)
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
+`
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunScaffold(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		kind      string
+		function  string
+		handler   string
+		wantRoute bool
+	}{
+		"api": {
+			kind:      "api",
+			function:  "ApiFunction",
+			handler:   "./cmd/api",
+			wantRoute: true,
+		},
+		"sqs": {
+			kind:     "sqs",
+			function: "QueueFunction",
+			handler:  "./cmd/queue",
+		},
+		"stream": {
+			kind:     "stream",
+			function: "StreamFunction",
+			handler:  "./cmd/stream",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				dir := t.TempDir()
+
+				var out bytes.Buffer
+				require.NoError(t, RunScaffold(&out, tt.kind, dir, "example.com/demo", false))
+				assert.Contains(t, out.String(), "Next steps:")
+
+				goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+				require.NoError(t, err)
+				assert.Equal(t, "example.com/demo", modulePath(goMod))
+
+				// init recognizes the handler and wires it to the function of the template
+				project, err := inspectProject(dir)
+				require.NoError(t, err)
+				assert.Equal(t, []string{tt.handler}, project.handlers)
+				assert.Equal(t, "template.yaml", project.templatePath)
+				require.Len(t, project.functions, 1)
+				assert.Equal(t, tt.function, project.functions[0].name)
+				assert.Equal(t, tt.handler, project.handlerFor(project.functions[0]))
+				assert.Equal(t, tt.wantRoute, project.functions[0].route != nil)
+
+				config, err := loadProjectConfig(filepath.Join(dir, defaultConfigPath), osFileReader{})
+				require.NoError(t, err)
+				assert.Equal(t, scaffoldAddress, config.functionAddress(tt.function, ""))
+
+				event, err := resolveDefaultEvent(filepath.Join(dir, "template.yaml"), tt.function, osFileReader{})
+				require.NoError(t, err)
+				assert.True(t, json.Valid([]byte(event)))
+			},
+		)
+	}
+}
+
+func TestRunScaffoldExistingModule(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	goMod := "module example.com/shop\n\ngo 1.24\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "template.yaml"), []byte("Resources: {}\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, RunScaffold(&out, "sqs", dir, "", false))
+
+	// the module and the existing template are kept
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, goMod, string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, "template.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "Resources: {}\n", string(data))
+	assert.Contains(t, out.String(), "Skipped "+filepath.Join(dir, "template.yaml"))
+	assert.Contains(t, out.String(), "go get github.com/aws/aws-lambda-go@")
+}
+
+func TestRunScaffoldUnknownKind(t *testing.T) {
+	t.Parallel()
+
+	err := RunScaffold(&bytes.Buffer{}, "kinesis", t.TempDir(), "", false)
+	require.ErrorContains(t, err, "unknown kind 'kinesis', must be one of api, sqs, stream")
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	// embedded so ScheduleExpressionTimezone works without a time zone database
+	_ "time/tzdata"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	eventTypeSchedule   = "Schedule"
+	eventTypeScheduleV2 = "ScheduleV2"
+	scheduleDisabled    = "DISABLED"
+)
+
+// scheduleSource is a Schedule event, an EventBridge rule, or a ScheduleV2 event, an EventBridge
+// Scheduler schedule, of a function.
+type scheduleSource struct {
+	name       string
+	expression string
+	// timezone is the IANA time zone cron and at expressions are evaluated in, UTC when empty.
+	timezone string
+	// input is the JSON the lambda is invoked with instead of the scheduled event.
+	input     string
+	scheduler bool
+	disabled  bool
+}
+
+// schedule is a scheduleSource with its parsed expression.
+type schedule struct {
+	scheduleSource
+	expression scheduleExpression
+}
+
+// parseSchedules parses the expressions of sources.
+func parseSchedules(sources []scheduleSource) ([]schedule, error) {
+	schedules := make([]schedule, 0, len(sources))
+
+	for _, source := range sources {
+		location, err := time.LoadLocation(source.timezone)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseSchedules] schedule '%s': %w", source.name, err)
+		}
+
+		expression, err := parseScheduleExpression(source.expression, location)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.parseSchedules] schedule '%s': %w", source.name, err)
+		}
+
+		schedules = append(schedules, schedule{scheduleSource: source, expression: expression})
+	}
+
+	return schedules, nil
+}
+
+// scheduleClock is the clock schedules run on. It starts at the time schedules start and runs
+// factor times faster than the wall clock, so schedules can be tested without waiting for them.
+type scheduleClock struct {
+	start  time.Time
+	factor float64
+	now    func() time.Time
+}
+
+func newScheduleClock(factor float64) scheduleClock {
+	return scheduleClock{start: time.Now(), factor: factor, now: time.Now}
+}
+
+// time returns the current time of the clock.
+func (c scheduleClock) time() time.Time {
+	return c.start.Add(time.Duration(float64(c.now().Sub(c.start)) * c.factor))
+}
+
+// until returns the wall clock duration until the clock reaches t.
+func (c scheduleClock) until(t time.Time) time.Duration {
+	return time.Duration(float64(t.Sub(c.time())) / c.factor)
+}
+
+// RunSchedules invokes the lambda whenever one of the schedules fires, until interrupted or none
+// of them fires again. Like EventBridge, the lambda is invoked with a scheduled event, or the
+// Input of the schedule, and failed invocations aren't retried.
+func RunSchedules(
+	ctx context.Context,
+	lambdaRPC lambdaCaller,
+	schedules []schedule,
+	clock scheduleClock,
+	format responseFormat,
+	logger *slog.Logger,
+) error {
+	// run until interrupted or terminated
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if clock.factor != 1 {
+		logger.Info(fmt.Sprintf("Running schedules %gx faster", clock.factor))
+	}
+
+	var wg sync.WaitGroup
+
+	for _, s := range schedules {
+		if s.disabled {
+			logger.Info(fmt.Sprintf("Schedule %s is disabled, skipping", s.name))
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			runSchedule(ctx, lambdaRPC, s, clock, format, logger.With("schedule", s.name))
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// runSchedule invokes the lambda each time s fires, until ctx is done or s doesn't fire again.
+func runSchedule(
+	ctx context.Context,
+	lambdaRPC lambdaCaller,
+	s schedule,
+	clock scheduleClock,
+	format responseFormat,
+	logger *slog.Logger,
+) {
+	after := clock.start
+
+	for {
+		at, ok := s.expression.next(clock.start, after)
+		if !ok {
+			logger.Info(fmt.Sprintf("Schedule %s doesn't fire again", s.name))
+
+			return
+		}
+
+		logger.Info(fmt.Sprintf("Schedule %s fires next at %s", s.name, at.Format(time.RFC3339)))
+
+		timer := time.NewTimer(clock.until(at))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		after = at
+
+		event, err := scheduledEvent(s.scheduleSource, at)
+		if err != nil {
+			logger.Error("[in lambdalocal.runSchedule] create event failed", "err", err)
+
+			return
+		}
+
+		invokeResponse, err := lambdaRPC.Invoke(ctx, event)
+		if err != nil {
+			logger.Error("[in lambdalocal.runSchedule] invoke failed", "err", err)
+
+			continue
+		}
+
+		if err = printResponse(logger, invokeResponse, format); err != nil {
+			logger.Error("[in lambdalocal.runSchedule] printResponse failed", "err", err)
+		}
+	}
+}
+
+// scheduledEvent returns the event the lambda is invoked with when source fires at at: its Input
+// or the scheduled event of EventBridge rules or EventBridge Scheduler.
+func scheduledEvent(source scheduleSource, at time.Time) ([]byte, error) {
+	if source.input != "" {
+		return []byte(source.input), nil
+	}
+
+	region := pseudoParameters["AWS::Region"]
+	account := pseudoParameters["AWS::AccountId"]
+
+	event := events.CloudWatchEvent{
+		Version:    "0",
+		ID:         uuid.New().String(),
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		AccountID:  account,
+		Time:       at.UTC().Truncate(time.Second),
+		Region:     region,
+		Resources:  []string{fmt.Sprintf("arn:aws:events:%s:%s:rule/%s", region, account, source.name)},
+		Detail:     json.RawMessage(`{}`),
+	}
+
+	if source.scheduler {
+		event.Source = "aws.scheduler"
+		event.Resources = []string{
+			fmt.Sprintf("arn:aws:scheduler:%s:%s:schedule/default/%s", region, account, source.name),
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.scheduledEvent] %w", err)
+	}
+
+	return data, nil
+}
+
+// templateSchedules returns the Schedule and ScheduleV2 events of function in the template at
+// templatePath, sorted by name. Schedules are named by their Name, or the logical IDs of the
+// function and event.
+func templateSchedules(
+	templatePath, function string,
+	reader fileReader,
+	overrides map[string]string,
+) ([]scheduleSource, error) {
+	data, err := reader.read(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateSchedules] read template failed: %w", err)
+	}
+
+	SAMData := samTemplate{}
+	if err = unmarshalTemplate(data, overrides, &SAMData); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.templateSchedules] unmarshal yaml failed: %w", err)
+	}
+
+	resource, ok := SAMData.Resources[function]
+	if !ok {
+		return nil, fmt.Errorf(
+			"[in lambdalocal.templateSchedules] function '%s' not found in template '%s'",
+			function,
+			templatePath,
+		)
+	}
+
+	var sources []scheduleSource
+
+	for name, event := range resource.Properties.Events {
+		properties := event.Properties
+
+		source := scheduleSource{
+			name:     function + name,
+			input:    properties.Input,
+			disabled: properties.State == scheduleDisabled || properties.Enabled != nil && !*properties.Enabled,
+		}
+
+		if properties.Name != "" {
+			source.name = properties.Name
+		}
+
+		switch event.Type {
+		case eventTypeSchedule:
+			source.expression = properties.Schedule
+		case eventTypeScheduleV2:
+			source.expression = properties.ScheduleExpression
+			source.timezone = properties.ScheduleExpressionTimezone
+			source.scheduler = true
+		default:
+			continue
+		}
+
+		if source.input != "" && !json.Valid([]byte(source.input)) {
+			return nil, fmt.Errorf("[in lambdalocal.templateSchedules] Input of event '%s' isn't JSON", name)
+		}
+
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf(
+			"[in lambdalocal.templateSchedules] function '%s' has no Schedule or ScheduleV2 event",
+			function,
+		)
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].name < sources[j].name
+	})
+
+	return sources, nil
+}
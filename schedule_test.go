@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSchedules(t *testing.T) {
+	t.Parallel()
+
+	schedules, err := parseSchedules(
+		[]scheduleSource{
+			{name: "every-minute", expression: "rate(1 minute)"},
+			{name: "with-input", expression: "rate(2 minutes)", input: `{"job":"cleanup"}`},
+			{name: "disabled", expression: "rate(1 minute)", disabled: true},
+		},
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu          sync.Mutex
+		invocations []string
+	)
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	mockLambdaRPC.On("Invoke", mock.Anything).
+		Run(
+			func(args mock.Arguments) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				invocations = append(invocations, string(args.Get(0).([]byte)))
+				if len(invocations) == 3 {
+					cancel()
+				}
+			},
+		).
+		Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+
+	// a minute passes every 20ms
+	clock := newScheduleClock(3000)
+
+	require.NoError(t, RunSchedules(ctx, mockLambdaRPC, schedules, clock, responseFormat{}, slog.Default()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, invocations, 3)
+	assert.Contains(t, invocations, `{"job":"cleanup"}`)
+}
+
+func TestScheduledEvent(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		source            scheduleSource
+		expectedSource    string
+		expectedResources []string
+	}{
+		"EventBridge rule": {
+			source:            scheduleSource{name: "nightly"},
+			expectedSource:    "aws.events",
+			expectedResources: []string{"arn:aws:events:us-east-1:123456789012:rule/nightly"},
+		},
+		"EventBridge Scheduler schedule": {
+			source:            scheduleSource{name: "nightly", scheduler: true},
+			expectedSource:    "aws.scheduler",
+			expectedResources: []string{"arn:aws:scheduler:us-east-1:123456789012:schedule/default/nightly"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				data, err := scheduledEvent(tc.source, at)
+				require.NoError(t, err)
+
+				var event events.CloudWatchEvent
+				require.NoError(t, json.Unmarshal(data, &event))
+
+				assert.Equal(t, "Scheduled Event", event.DetailType)
+				assert.Equal(t, tc.expectedSource, event.Source)
+				assert.Equal(t, tc.expectedResources, event.Resources)
+				assert.Equal(t, at, event.Time)
+				assert.JSONEq(t, `{}`, string(event.Detail))
+				assert.NotEmpty(t, event.ID)
+			},
+		)
+	}
+}
+
+func TestTemplateSchedules(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template        string
+		expectedSources []scheduleSource
+		expectedErr     string
+	}{
+		"schedule events": {
+			template: `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Nightly:
+          Type: Schedule
+          Properties:
+            Schedule: cron(0 2 * * ? *)
+            Input: '{"job":"cleanup"}'
+        Report:
+          Type: ScheduleV2
+          Properties:
+            Name: weekly-report
+            ScheduleExpression: cron(0 9 ? * MON *)
+            ScheduleExpressionTimezone: Europe/Berlin
+        Paused:
+          Type: Schedule
+          Properties:
+            Schedule: rate(1 hour)
+            Enabled: false
+        Api:
+          Type: HttpApi
+`,
+			expectedSources: []scheduleSource{
+				{name: "FnNightly", expression: "cron(0 2 * * ? *)", input: `{"job":"cleanup"}`},
+				{name: "FnPaused", expression: "rate(1 hour)", disabled: true},
+				{
+					name:       "weekly-report",
+					expression: "cron(0 9 ? * MON *)",
+					timezone:   "Europe/Berlin",
+					scheduler:  true,
+				},
+			},
+		},
+		"input that isn't JSON": {
+			template: `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Nightly:
+          Type: Schedule
+          Properties:
+            Schedule: rate(1 day)
+            Input: cleanup
+`,
+			expectedErr: "Input of event 'Nightly' isn't JSON",
+		},
+		"no schedule event": {
+			template: `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Api:
+          Type: HttpApi
+`,
+			expectedErr: "function 'Fn' has no Schedule or ScheduleV2 event",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := new(mockOSFileReader)
+				reader.On("read", "template.yaml").Return([]byte(tc.template), nil)
+
+				sources, err := templateSchedules("template.yaml", "Fn", reader, nil)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedSources, sources)
+			},
+		)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings is the configuration of a run, combined from the project config and the flags so it
+// can be validated as a whole before anything is started.
+type settings struct {
+	protocol       string
+	address        string
+	executionLimit time.Duration
+	run            string
+	// functionAddresses are the addresses of functions that don't use address.
+	functionAddresses map[string]string
+	// api is only set in api mode.
+	api *apiSettings
+}
+
+type apiSettings struct {
+	watch    bool
+	watchDir string
+	build    string
+	// recordEncrypt is set when the recordings are encrypted with --record-encrypt.
+	recordEncrypt  bool
+	recordIdentity string
+	// coldStart selects the invocations the lambda process is restarted for.
+	coldStart coldStartPolicy
+	// processes is the number of processes of the lambda started with --run.
+	processes int
+	server    serverConfig
+	jwt       jwtConfig
+}
+
+// validationError lists every problem found while validating settings.
+type validationError struct {
+	problems []string
+}
+
+func (e *validationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.problems, "\n  - ")
+}
+
+// validate checks the settings for values and combinations that can't work. All problems are
+// reported at once in a validationError.
+func (s settings) validate() error {
+	var problems []string
+
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if s.protocol != ProtocolRPC && s.protocol != ProtocolRuntimeAPI {
+		addf("--protocol must be '%s' or '%s', got '%s'", ProtocolRPC, ProtocolRuntimeAPI, s.protocol)
+	}
+
+	if err := validateAddress(s.address); err != nil {
+		addf("--address: %s", err)
+	}
+
+	if s.executionLimit <= 0 {
+		addf("--executionLimit must be positive, got %s", s.executionLimit)
+	}
+
+	functions := make([]string, 0, len(s.functionAddresses))
+	for function := range s.functionAddresses {
+		functions = append(functions, function)
+	}
+
+	sort.Strings(functions)
+
+	// in api mode a Runtime API is served on every address at once, so each address can only be
+	// used once
+	served := map[string]string{s.address: "--address"}
+
+	for _, function := range functions {
+		address := s.functionAddresses[function]
+
+		if err := validateAddress(address); err != nil {
+			addf("address of function '%s': %s", function, err)
+
+			continue
+		}
+
+		if s.protocol != ProtocolRuntimeAPI || s.api == nil {
+			continue
+		}
+
+		if other, ok := served[address]; ok {
+			addf(
+				"address of function '%s' is '%s', which is already used by %s. With --protocol %s every "+
+					"function needs its own address",
+				function,
+				address,
+				other,
+				ProtocolRuntimeAPI,
+			)
+
+			continue
+		}
+
+		served[address] = fmt.Sprintf("function '%s'", function)
+	}
+
+	if s.api != nil {
+		problems = append(problems, s.api.validate(s.run)...)
+	}
+
+	if len(problems) > 0 {
+		return &validationError{problems: problems}
+	}
+
+	return nil
+}
+
+func (a apiSettings) validate(run string) []string {
+	var problems []string
+
+	if a.watch && run == "" {
+		problems = append(problems, "--watch requires --run, the command that starts the lambda")
+	}
+
+	if a.server.recordings != nil && a.server.historySize == 0 {
+		problems = append(problems, "--record requires a --history-size, the invocations that are recorded")
+	}
+
+	if a.recordEncrypt && a.server.recordings == nil {
+		problems = append(problems, "--record-encrypt requires --record, the recordings that are encrypted")
+	}
+
+	if a.recordIdentity != "" && !a.recordEncrypt {
+		problems = append(problems, "--record-identity requires --record-encrypt, the encryption of the recordings")
+	}
+
+	if a.coldStart.enabled() && run == "" {
+		problems = append(problems, "--cold-start requires --run, the command that starts the lambda")
+	}
+
+	if a.processes > 1 {
+		switch {
+		case run == "":
+			problems = append(problems, "--processes requires --run, the command that starts the lambda")
+		case a.watch || a.coldStart.enabled():
+			problems = append(problems, "--processes can't be combined with --watch and --cold-start")
+		}
+	}
+
+	if a.build != "" && !a.watch {
+		problems = append(problems, "--build is only used with --watch")
+	}
+
+	if a.watch {
+		if info, err := os.Stat(a.watchDir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("--watch-dir '%s' is not a directory", a.watchDir))
+		}
+	}
+
+	timeouts := []struct {
+		flag  string
+		value time.Duration
+	}{
+		{flag: "--read-timeout", value: a.server.readTimeout},
+		{flag: "--write-timeout", value: a.server.writeTimeout},
+		{flag: "--idle-timeout", value: a.server.idleTimeout},
+	}
+
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s must not be negative, got %s", timeout.flag, timeout.value))
+		}
+	}
+
+	budgets := make([]string, 0, len(a.server.latencyBudgets))
+	for route := range a.server.latencyBudgets {
+		budgets = append(budgets, route)
+	}
+
+	sort.Strings(budgets)
+
+	for _, route := range budgets {
+		if budget := a.server.latencyBudgets[route]; budget <= 0 {
+			problems = append(problems, fmt.Sprintf("latency budget of '%s' must be positive, got %s", route, budget))
+		}
+	}
+
+	problems = append(problems, a.server.tls.validate()...)
+
+	// signing keys are discovered below the issuer, so it has to be a URL
+	if a.jwt.issuer != "" {
+		u, err := url.Parse(a.jwt.issuer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("--jwt-issuer '%s' is not an http(s) URL", a.jwt.issuer))
+		}
+	}
+
+	return problems
+}
+
+// validateAddress checks that address is a host:port pair with a valid port.
+func validateAddress(address string) error {
+	if address == "" {
+		return errors.New("address is empty")
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a host:port address", address)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 { //nolint:mnd
+		return fmt.Errorf("'%s' has an invalid port", address)
+	}
+
+	return nil
+}
+
+// validatePort checks that port is a TCP port, or 0 for a free port picked by the system.
+func validatePort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 { //nolint:mnd
+		return fmt.Errorf("'%s' is not a port", port)
+	}
+
+	return nil
+}
+
+// parseHost returns the host name or IP address the api is served on, without the brackets of
+// IPv6 literals, so it can be joined with the port.
+func parseHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if host == "" {
+		return "", errors.New("host is empty")
+	}
+
+	// only IPv6 literals contain colons, a port is set with --port
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return "", fmt.Errorf("'%s' is not a host name or IP address", host)
+	}
+
+	return host, nil
+}
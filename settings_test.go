@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() settings {
+		return settings{
+			protocol:       ProtocolRPC,
+			address:        "localhost:8000",
+			executionLimit: 5 * time.Second,
+		}
+	}
+
+	tests := map[string]struct {
+		settings         func() settings
+		expectedProblems []string
+	}{
+		"valid event settings": {
+			settings: valid,
+		},
+		"valid api settings": {
+			settings: func() settings {
+				s := valid()
+				s.run = "go run ./cmd/fn"
+				s.functionAddresses = map[string]string{"OrderFn": "localhost:8002"}
+				s.api = &apiSettings{watch: true, watchDir: ".", build: "go build ./cmd/fn"}
+
+				return s
+			},
+		},
+		"rpc functions may share the address": {
+			settings: func() settings {
+				s := valid()
+				s.functionAddresses = map[string]string{"OrderFn": "localhost:8000"}
+				s.api = &apiSettings{}
+
+				return s
+			},
+		},
+		"runtime api function address in event mode": {
+			settings: func() settings {
+				s := valid()
+				s.protocol = ProtocolRuntimeAPI
+				s.functionAddresses = map[string]string{"OrderFn": "localhost:8000"}
+
+				return s
+			},
+		},
+		"every problem is reported": {
+			settings: func() settings {
+				return settings{
+					protocol:       "grpc",
+					address:        "localhost",
+					executionLimit: 0,
+					functionAddresses: map[string]string{
+						"HelloFn": "",
+						"OrderFn": "localhost:99999",
+					},
+					api: &apiSettings{
+						watch:    true,
+						watchDir: "./does-not-exist",
+						server:   serverConfig{readTimeout: -time.Second},
+					},
+				}
+			},
+			expectedProblems: []string{
+				"--protocol must be 'rpc' or 'runtime-api', got 'grpc'",
+				"--address: 'localhost' is not a host:port address",
+				"--executionLimit must be positive, got 0s",
+				"address of function 'HelloFn': address is empty",
+				"address of function 'OrderFn': 'localhost:99999' has an invalid port",
+				"--watch requires --run, the command that starts the lambda",
+				"--watch-dir './does-not-exist' is not a directory",
+				"--read-timeout must not be negative, got -1s",
+			},
+		},
+		"latency budgets must be positive": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{
+					server: serverConfig{
+						latencyBudgets: map[string]time.Duration{"GET /users": 0, "GET /orders": time.Second},
+					},
+				}
+
+				return s
+			},
+			expectedProblems: []string{
+				"latency budget of 'GET /users' must be positive, got 0s",
+			},
+		},
+		"jwt issuer must be a url": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{jwt: jwtConfig{issuer: "cognito-idp.us-east-1.amazonaws.com/pool"}}
+
+				return s
+			},
+			expectedProblems: []string{
+				"--jwt-issuer 'cognito-idp.us-east-1.amazonaws.com/pool' is not an http(s) URL",
+			},
+		},
+		"runtime api addresses must be unique in api mode": {
+			settings: func() settings {
+				s := valid()
+				s.protocol = ProtocolRuntimeAPI
+				s.functionAddresses = map[string]string{
+					"HelloFn": "localhost:8000",
+					"OrderFn": "localhost:8002",
+					"UserFn":  "localhost:8002",
+				}
+				s.api = &apiSettings{build: "go build ./cmd/fn"}
+
+				return s
+			},
+			expectedProblems: []string{
+				"address of function 'HelloFn' is 'localhost:8000', which is already used by --address. " +
+					"With --protocol runtime-api every function needs its own address",
+				"address of function 'UserFn' is 'localhost:8002', which is already used by function 'OrderFn'. " +
+					"With --protocol runtime-api every function needs its own address",
+				"--build is only used with --watch",
+			},
+		},
+		"--record without a history": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{server: serverConfig{recordings: newMemoryStore()}}
+
+				return s
+			},
+			expectedProblems: []string{"--record requires a --history-size, the invocations that are recorded"},
+		},
+		"encryption without --record": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{recordEncrypt: true}
+
+				return s
+			},
+			expectedProblems: []string{"--record-encrypt requires --record, the recordings that are encrypted"},
+		},
+		"identity without --record-encrypt": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{recordIdentity: "key.txt"}
+
+				return s
+			},
+			expectedProblems: []string{"--record-identity requires --record-encrypt, the encryption of the recordings"},
+		},
+		"managed processes without --run": {
+			settings: func() settings {
+				s := valid()
+				s.api = &apiSettings{coldStart: coldStartPolicy{percent: 100}, processes: 2}
+
+				return s
+			},
+			expectedProblems: []string{
+				"--cold-start requires --run, the command that starts the lambda",
+				"--processes requires --run, the command that starts the lambda",
+			},
+		},
+		"processes with cold starts": {
+			settings: func() settings {
+				s := valid()
+				s.run = "go run ./cmd/fn"
+				s.api = &apiSettings{coldStart: coldStartPolicy{percent: 100}, processes: 2}
+
+				return s
+			},
+			expectedProblems: []string{"--processes can't be combined with --watch and --cold-start"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				err := tc.settings().validate()
+
+				if tc.expectedProblems == nil {
+					require.NoError(t, err)
+
+					return
+				}
+
+				var validationErr *validationError
+
+				require.True(t, errors.As(err, &validationErr))
+				assert.Equal(t, tc.expectedProblems, validationErr.problems)
+			},
+		)
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	t.Parallel()
+
+	err := &validationError{problems: []string{"first problem", "second problem"}}
+
+	assert.Equal(t, "invalid configuration:\n  - first problem\n  - second problem", err.Error())
+}
+
+func TestValidatePort(t *testing.T) {
+	t.Parallel()
+
+	for _, port := range []string{"80", "443", "8080", "18080", "65535", "0"} {
+		assert.NoError(t, validatePort(port), port)
+	}
+
+	for _, port := range []string{"", "-1", "65536", "http", "80a"} {
+		assert.Error(t, validatePort(port), port)
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		host     string
+		expected string
+		wantErr  bool
+	}{
+		"host name":      {host: "localhost", expected: "localhost"},
+		"all IPv4":       {host: "0.0.0.0", expected: "0.0.0.0"},
+		"IPv6":           {host: "::1", expected: "::1"},
+		"bracketed IPv6": {host: "[::]", expected: "::"},
+		"empty":          {host: "", wantErr: true},
+		"with port":      {host: "localhost:8080", wantErr: true},
+		"IPv6 with port": {host: "[::1]:8080", wantErr: true},
+		"empty brackets": {host: "[]", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				host, err := parseHost(tc.host)
+				if tc.wantErr {
+					require.Error(t, err)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, host)
+			},
+		)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	// localCredentialsID is used to sign requests to local endpoints when no credentials are set,
+	// emulators like ElasticMQ and LocalStack accept any.
+	localCredentialsID = "lambdalocal"
+)
+
+// awsCredentials signs requests to AWS APIs with Signature Version 4.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// credentialsFromEnv reads the credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables. Without them local endpoints get placeholder
+// credentials, AWS endpoints fail.
+func credentialsFromEnv(lookupEnv func(string) (string, bool), local bool) (awsCredentials, error) {
+	accessKeyID, _ := lookupEnv("AWS_ACCESS_KEY_ID")
+	secretAccessKey, _ := lookupEnv("AWS_SECRET_ACCESS_KEY")
+	sessionToken, _ := lookupEnv("AWS_SESSION_TOKEN")
+
+	if accessKeyID == "" || secretAccessKey == "" {
+		if !local {
+			return awsCredentials{}, errors.New(
+				"[in lambdalocal.credentialsFromEnv] AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set",
+			)
+		}
+
+		return awsCredentials{accessKeyID: localCredentialsID, secretAccessKey: localCredentialsID}, nil
+	}
+
+	return awsCredentials{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+	}, nil
+}
+
+// sign adds the X-Amz-Date and Authorization headers of Signature Version 4 to r. body is the
+// payload of r, every header set on r is signed.
+func (c awsCredentials) sign(r *http.Request, body []byte, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	date := amzDate[:8]
+
+	r.Header.Set("X-Amz-Date", amzDate)
+
+	if c.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join(
+		[]string{
+			r.Method,
+			path,
+			canonicalQuery(r.URL.Query()),
+			canonicalHeaders.String(),
+			signedHeaders,
+			sha256Hex(body),
+		},
+		"\n",
+	)
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + c.secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	r.Header.Set(
+		"Authorization",
+		fmt.Sprintf(
+			"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			sigV4Algorithm,
+			c.accessKeyID,
+			scope,
+			signedHeaders,
+			hex.EncodeToString(hmacSHA256(key, stringToSign)),
+		),
+	)
+}
+
+// canonicalQuery returns the query sorted by key and value, encoded like Signature Version 4
+// expects.
+func canonicalQuery(query url.Values) string {
+	var params []string
+
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// sigV4Escape percent encodes everything but unreserved characters, spaces included.
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
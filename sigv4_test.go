@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSCredentialsSign(t *testing.T) {
+	t.Parallel()
+
+	// get-vanilla of the Signature Version 4 test suite
+	r, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	credentials := awsCredentials{
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	credentials.sign(r, nil, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", r.Header.Get("X-Amz-Date"))
+	assert.Equal(
+		t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		r.Header.Get("Authorization"),
+	)
+}
+
+func TestCredentialsFromEnv(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":     "id",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_SESSION_TOKEN":     "token",
+	}
+
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+
+		return value, ok
+	}
+
+	noEnv := func(string) (string, bool) { return "", false }
+
+	credentials, err := credentialsFromEnv(lookupEnv, false)
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{accessKeyID: "id", secretAccessKey: "secret", sessionToken: "token"}, credentials)
+
+	credentials, err = credentialsFromEnv(noEnv, true)
+	require.NoError(t, err)
+	assert.Equal(t, localCredentialsID, credentials.accessKeyID)
+
+	_, err = credentialsFromEnv(noEnv, false)
+	assert.ErrorContains(t, err, "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+}
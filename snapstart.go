@@ -0,0 +1,43 @@
+package main
+
+import "net/http"
+
+// handleRestoreNext is called by runtimes started with AWS_LAMBDA_INITIALIZATION_TYPE=snap-start
+// once initialization and their beforeCheckpoint hooks are done. Lambda takes the snapshot then
+// and restores it when the function is first invoked, so the response waits for the first
+// invocation. The runtime runs its afterRestore hooks before it polls for that invocation.
+func (l *RuntimeAPIClient) handleRestoreNext(w http.ResponseWriter, r *http.Request) {
+	l.logger.Info("Runtime checkpointed after its beforeCheckpoint hooks, restoring on the first invocation")
+
+	select {
+	case <-l.invoked:
+	case <-r.Context().Done():
+		return
+	}
+
+	l.restoring.Store(true)
+
+	l.logger.Info("Restoring snapshot, running afterRestore hooks")
+	l.telemetry.emit(
+		"platform.restoreStart",
+		map[string]any{"functionName": extensionFunctionName, "functionVersion": "$LATEST"},
+	)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRestoreError is called by runtimes whose afterRestore hooks failed.
+func (l *RuntimeAPIClient) handleRestoreError(w http.ResponseWriter, r *http.Request) {
+	runtimeErr := parseRuntimeError(r)
+
+	l.restoring.Store(false)
+
+	l.logger.Error(
+		"Lambda runtime failed to restore, afterRestore hooks failed: "+runtimeErr.Message,
+		"errorType",
+		runtimeErr.Type,
+	)
+	l.telemetry.emit("platform.restoreRuntimeDone", map[string]any{"status": "failure", "errorType": runtimeErr.Type})
+
+	w.WriteHeader(http.StatusAccepted)
+}
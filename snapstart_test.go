@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeAPIClientRestore(t *testing.T) {
+	t.Parallel()
+
+	runtimeAPI := NewRuntimeAPIClient("localhost:0", 5*time.Second, slog.Default())
+
+	stop, err := runtimeAPI.Start()
+	require.NoError(t, err)
+	t.Cleanup(stop)
+
+	address := runtimeAPI.address
+	restored := make(chan time.Time, 1)
+
+	// the runtime asks for a checkpoint after initialization, then polls for the invocation
+	go func() {
+		resp, err := runtimeClient.Get("http://" + address + runtimeAPIPrefix + "/restore/next") //nolint:noctx
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		restored <- time.Now()
+
+		runtimeRoundTrip(t, address, "response", `{"statusCode":200}`)
+	}()
+
+	// the snapshot is only restored once the function is invoked
+	select {
+	case <-restored:
+		t.Fatal("restored before the first invocation")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	invoked := time.Now()
+
+	response, err := runtimeAPI.Invoke(context.Background(), []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"statusCode":200}`, string(response.Payload))
+
+	assert.False(t, (<-restored).Before(invoked))
+	assert.False(t, runtimeAPI.restoring.Load(), "the restore is done once the runtime polls")
+
+	resp, err := runtimeClient.Post( //nolint:noctx
+		"http://"+address+runtimeAPIPrefix+"/restore/error",
+		"application/json",
+		strings.NewReader(`{"errorMessage":"hook failed","errorType":"RuntimeError"}`),
+	)
+	require.NoError(t, err)
+
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
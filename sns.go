@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// snsDefaultTopicARN is the topic of messages published without one.
+	snsDefaultTopicARN = "arn:aws:sns:us-east-1:000000000000:lambdalocal"
+	// snsXMLNamespace is the namespace of the responses of the SNS Query API.
+	snsXMLNamespace = "http://sns.amazonaws.com/doc/2010-03-31/"
+)
+
+// snsMessageAttribute is a message attribute as SNS passes it to lambdas and HTTP subscriptions.
+type snsMessageAttribute struct {
+	Type  string `json:"Type"`  //nolint:tagliatelle
+	Value string `json:"Value"` //nolint:tagliatelle
+}
+
+// snsMessage is a message published to a topic.
+type snsMessage struct {
+	MessageID         string                         `json:"MessageId"`         //nolint:tagliatelle
+	TopicARN          string                         `json:"TopicArn"`          //nolint:tagliatelle
+	Subject           string                         `json:"Subject"`           //nolint:tagliatelle
+	Message           string                         `json:"Message"`           //nolint:tagliatelle
+	Timestamp         time.Time                      `json:"Timestamp"`         //nolint:tagliatelle
+	MessageAttributes map[string]snsMessageAttribute `json:"MessageAttributes"` //nolint:tagliatelle
+}
+
+// snsEvent wraps message in the event lambda receives from an SNS subscription.
+func snsEvent(message snsMessage) events.SNSEvent {
+	attributes := make(map[string]any, len(message.MessageAttributes))
+	for name, attribute := range message.MessageAttributes {
+		attributes[name] = attribute
+	}
+
+	return events.SNSEvent{
+		Records: []events.SNSEventRecord{
+			{
+				EventVersion: "1.0",
+				// subscriptions are named after a UUID, derive one per topic so it is stable
+				EventSubscriptionArn: message.TopicARN + ":" +
+					uuid.NewSHA1(uuid.NameSpaceURL, []byte(message.TopicARN)).String(),
+				EventSource: "aws:sns",
+				SNS: events.SNSEntity{
+					MessageID:         message.MessageID,
+					Type:              "Notification",
+					TopicArn:          message.TopicARN,
+					MessageAttributes: attributes,
+					SignatureVersion:  "1",
+					Timestamp:         message.Timestamp,
+					Message:           message.Message,
+					Subject:           message.Subject,
+				},
+			},
+		},
+	}
+}
+
+// snsHandler invokes the lambda with the messages published to it. It accepts the Publish action
+// of the SNS Query API, so SDKs and the AWS CLI can publish with it as their endpoint, and the
+// notifications of an HTTP subscription, so topics of LocalStack or AWS can deliver to it.
+type snsHandler struct {
+	lambdaRPC lambdaCaller
+	// topicARN is the topic of published messages that don't name one.
+	topicARN string
+	format   responseFormat
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+func (h snsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Header.Get("X-Amz-Sns-Message-Type") {
+	case "":
+		h.handlePublish(w, r)
+	case "Notification":
+		var message snsMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			http.Error(w, "invalid notification: "+err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		h.invoke(r.Context(), message)
+	case "SubscriptionConfirmation":
+		h.confirmSubscription(r)
+	default:
+		h.logger.Info("Ignoring SNS message of type " + r.Header.Get("X-Amz-Sns-Message-Type"))
+	}
+}
+
+// handlePublish handles the Publish action of the SNS Query API.
+func (h snsHandler) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeSNSError(w, "InvalidParameter", "invalid request: "+err.Error())
+
+		return
+	}
+
+	if action := r.Form.Get("Action"); action != "Publish" {
+		writeSNSError(w, "InvalidAction", fmt.Sprintf("action '%s' is not supported, only Publish is", action))
+
+		return
+	}
+
+	message := snsMessage{
+		MessageID:         uuid.New().String(),
+		TopicARN:          r.Form.Get("TopicArn"),
+		Subject:           r.Form.Get("Subject"),
+		Message:           r.Form.Get("Message"),
+		Timestamp:         time.Now().UTC(),
+		MessageAttributes: make(map[string]snsMessageAttribute),
+	}
+
+	if message.TopicARN == "" {
+		message.TopicARN = r.Form.Get("TargetArn")
+	}
+
+	if message.TopicARN == "" {
+		message.TopicARN = h.topicARN
+	}
+
+	// attributes are flattened like MessageAttributes.entry.1.Name and .Value.StringValue
+	for i := 1; ; i++ {
+		prefix := "MessageAttributes.entry." + strconv.Itoa(i) + "."
+
+		name := r.Form.Get(prefix + "Name")
+		if name == "" {
+			break
+		}
+
+		attribute := snsMessageAttribute{
+			Type:  r.Form.Get(prefix + "Value.DataType"),
+			Value: r.Form.Get(prefix + "Value.StringValue"),
+		}
+
+		if attribute.Type == "Binary" {
+			attribute.Value = r.Form.Get(prefix + "Value.BinaryValue")
+		}
+
+		message.MessageAttributes[name] = attribute
+	}
+
+	h.invoke(r.Context(), message)
+
+	w.Header().Set("Content-Type", "text/xml")
+
+	_ = xml.NewEncoder(w).Encode(
+		struct {
+			XMLName   xml.Name `xml:"PublishResponse"`
+			Namespace string   `xml:"xmlns,attr"`
+			MessageID string   `xml:"PublishResult>MessageId"`
+			RequestID string   `xml:"ResponseMetadata>RequestId"`
+		}{
+			Namespace: snsXMLNamespace,
+			MessageID: message.MessageID,
+			RequestID: uuid.New().String(),
+		},
+	)
+}
+
+// confirmSubscription confirms the HTTP subscription of a topic by visiting its SubscribeURL.
+func (h snsHandler) confirmSubscription(r *http.Request) {
+	var confirmation struct {
+		TopicARN     string `json:"TopicArn"`     //nolint:tagliatelle
+		SubscribeURL string `json:"SubscribeURL"` //nolint:tagliatelle
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.confirmSubscription] invalid confirmation", "err", err)
+
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, confirmation.SubscribeURL, nil)
+	if err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.confirmSubscription] invalid SubscribeURL", "err", err)
+
+		return
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.confirmSubscription] confirm failed", "err", err)
+
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	h.logger.Info("Confirmed subscription to " + confirmation.TopicARN)
+}
+
+// invoke invokes the lambda with message. Like SNS, failed invocations don't fail the publish,
+// they are logged.
+func (h snsHandler) invoke(ctx context.Context, message snsMessage) {
+	h.logger.Info(fmt.Sprintf("Invoking lambda with message %s of %s", message.MessageID, message.TopicARN))
+
+	event, err := json.Marshal(snsEvent(message))
+	if err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.invoke] marshal event failed", "err", err)
+
+		return
+	}
+
+	invokeResponse, err := h.lambdaRPC.Invoke(ctx, event)
+	if err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.invoke] invoke failed", "err", err)
+
+		return
+	}
+
+	if err = printResponse(h.logger, invokeResponse, h.format); err != nil {
+		h.logger.Error("[in lambdalocal.snsHandler.invoke] printResponse failed", "err", err)
+	}
+}
+
+// writeSNSError writes an error response of the SNS Query API.
+func writeSNSError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+
+	_ = xml.NewEncoder(w).Encode(
+		struct {
+			XMLName   xml.Name `xml:"ErrorResponse"`
+			Namespace string   `xml:"xmlns,attr"`
+			Type      string   `xml:"Error>Type"`
+			Code      string   `xml:"Error>Code"`
+			Message   string   `xml:"Error>Message"`
+			RequestID string   `xml:"RequestId"`
+		}{
+			Namespace: snsXMLNamespace,
+			Type:      "Sender",
+			Code:      code,
+			Message:   message,
+			RequestID: uuid.New().String(),
+		},
+	)
+}
+
+// RunSNS serves handler on addr until interrupted or terminated.
+func RunSNS(ctx context.Context, addr string, handler snsHandler, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.RunSNS] listen on '%s' failed: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second, //nolint:mnd
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDuration)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info(
+		fmt.Sprintf(
+			"Publish to http://%s, like aws sns publish --endpoint-url http://%s",
+			listener.Addr(),
+			listener.Addr(),
+		),
+	)
+
+	if err = server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("[in lambdalocal.RunSNS] Serve failed: %w", err)
+	}
+
+	return nil
+}
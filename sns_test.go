@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNSHandler(t *testing.T) {
+	t.Parallel()
+
+	publish := url.Values{
+		"Action":                         {"Publish"},
+		"TopicArn":                       {"arn:aws:sns:eu-west-1:123456789012:orders"},
+		"Subject":                        {"order"},
+		"Message":                        {`{"id":1}`},
+		"MessageAttributes.entry.1.Name": {"tenant"},
+		"MessageAttributes.entry.1.Value.DataType":    {"String"},
+		"MessageAttributes.entry.1.Value.StringValue": {"acme"},
+		"MessageAttributes.entry.2.Name":              {"signature"},
+		"MessageAttributes.entry.2.Value.DataType":    {"Binary"},
+		"MessageAttributes.entry.2.Value.BinaryValue": {"AQI="},
+		"MessageAttributes.entry.2.Value.StringValue": {"ignored"},
+		"MessageAttributes.entry.4.Name":              {"skipped"},
+		"MessageAttributes.entry.4.Value.DataType":    {"String"},
+		"MessageAttributes.entry.4.Value.StringValue": {"not after a gap"},
+	}
+
+	tests := map[string]struct {
+		messageType     string
+		body            string
+		expectedStatus  int
+		expectedBody    string
+		expectedMessage *events.SNSEntity
+	}{
+		"publish": {
+			body:           publish.Encode(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<PublishResult><MessageId>",
+			expectedMessage: &events.SNSEntity{
+				Type:     "Notification",
+				TopicArn: "arn:aws:sns:eu-west-1:123456789012:orders",
+				Subject:  "order",
+				Message:  `{"id":1}`,
+				MessageAttributes: map[string]any{
+					"tenant":    map[string]any{"Type": "String", "Value": "acme"},
+					"signature": map[string]any{"Type": "Binary", "Value": "AQI="},
+				},
+				SignatureVersion: "1",
+			},
+		},
+		"publish without topic": {
+			body:           url.Values{"Action": {"Publish"}, "Message": {"hello"}}.Encode(),
+			expectedStatus: http.StatusOK,
+			expectedMessage: &events.SNSEntity{
+				Type:              "Notification",
+				TopicArn:          snsDefaultTopicARN,
+				Message:           "hello",
+				MessageAttributes: map[string]any{},
+				SignatureVersion:  "1",
+			},
+		},
+		"unsupported action": {
+			body:           url.Values{"Action": {"CreateTopic"}}.Encode(),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "<Code>InvalidAction</Code>",
+		},
+		"http subscription notification": {
+			messageType: "Notification",
+			body: `{"Type":"Notification","MessageId":"message-id","TopicArn":"arn:aws:sns:us-east-1:000000000000:t",` +
+				`"Message":"hello","Timestamp":"2024-05-01T10:00:00Z",` +
+				`"MessageAttributes":{"tenant":{"Type":"String","Value":"acme"}}}`,
+			expectedStatus: http.StatusOK,
+			expectedMessage: &events.SNSEntity{
+				MessageID:         "message-id",
+				Type:              "Notification",
+				TopicArn:          "arn:aws:sns:us-east-1:000000000000:t",
+				Message:           "hello",
+				MessageAttributes: map[string]any{"tenant": map[string]any{"Type": "String", "Value": "acme"}},
+				SignatureVersion:  "1",
+			},
+		},
+		"invalid notification": {
+			messageType:    "Notification",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var event events.SNSEvent
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				mockLambdaRPC.On("Invoke", mock.Anything).
+					Run(func(args mock.Arguments) { require.NoError(t, json.Unmarshal(args.Get(0).([]byte), &event)) }).
+					Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+
+				handler := snsHandler{lambdaRPC: mockLambdaRPC, topicARN: snsDefaultTopicARN, logger: slog.Default()}
+
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				if tc.messageType != "" {
+					req.Header.Set("X-Amz-Sns-Message-Type", tc.messageType)
+				}
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				assert.Equal(t, tc.expectedStatus, rec.Code)
+				assert.Contains(t, rec.Body.String(), tc.expectedBody)
+
+				if tc.expectedMessage == nil {
+					mockLambdaRPC.AssertNotCalled(t, "Invoke", mock.Anything)
+
+					return
+				}
+
+				require.Len(t, event.Records, 1)
+
+				record := event.Records[0]
+				assert.Equal(t, "aws:sns", record.EventSource)
+				assert.True(t, strings.HasPrefix(record.EventSubscriptionArn, tc.expectedMessage.TopicArn+":"))
+				assert.False(t, record.SNS.Timestamp.IsZero())
+
+				if tc.expectedMessage.MessageID == "" {
+					assert.NotEmpty(t, record.SNS.MessageID)
+					record.SNS.MessageID = ""
+				}
+
+				record.SNS.Timestamp = tc.expectedMessage.Timestamp
+				assert.Equal(t, *tc.expectedMessage, record.SNS)
+			},
+		)
+	}
+}
+
+func TestSNSHandlerConfirmSubscription(t *testing.T) {
+	t.Parallel()
+
+	confirmed := make(chan string, 1)
+
+	sns := httptest.NewServer(
+		http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { confirmed <- r.URL.Query().Get("Token") }),
+	)
+	t.Cleanup(sns.Close)
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	handler := snsHandler{lambdaRPC: mockLambdaRPC, client: sns.Client(), logger: slog.Default()}
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{"Type":"SubscriptionConfirmation","SubscribeURL":"`+sns.URL+`/?Token=token"}`),
+	)
+	req.Header.Set("X-Amz-Sns-Message-Type", "SubscriptionConfirmation")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "token", <-confirmed)
+	mockLambdaRPC.AssertNotCalled(t, "Invoke", mock.Anything)
+}
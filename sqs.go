@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// sqsMaxMessages is the most messages a single ReceiveMessage or DeleteMessageBatch call handles.
+	sqsMaxMessages = 10
+	// sqsDefaultBatchSize is the BatchSize of SQS events that don't set one.
+	sqsDefaultBatchSize = 10
+	// sqsDefaultRegion is used when the region is neither in the queue URL nor configured.
+	sqsDefaultRegion = "us-east-1"
+
+	eventTypeSQS = "SQS"
+)
+
+// sqsMessage is a message returned by ReceiveMessage.
+type sqsMessage struct {
+	MessageID         string            `json:"MessageId"`              //nolint:tagliatelle
+	ReceiptHandle     string            `json:"ReceiptHandle"`          //nolint:tagliatelle
+	MD5OfBody         string            `json:"MD5OfBody"`              //nolint:tagliatelle
+	Body              string            `json:"Body"`                   //nolint:tagliatelle
+	Attributes        map[string]string `json:"Attributes"`             //nolint:tagliatelle
+	MD5OfAttributes   string            `json:"MD5OfMessageAttributes"` //nolint:tagliatelle
+	MessageAttributes map[string]struct {
+		StringValue *string `json:"StringValue"` //nolint:tagliatelle
+		BinaryValue string  `json:"BinaryValue"` //nolint:tagliatelle
+		DataType    string  `json:"DataType"`    //nolint:tagliatelle
+	} `json:"MessageAttributes"` //nolint:tagliatelle
+}
+
+// sqsClient calls the SQS API with the AWS JSON protocol, which ElasticMQ and LocalStack speak too.
+type sqsClient struct {
+	awsJSONClient
+}
+
+func newSQSClient(endpoint, region string, credentials awsCredentials, client *http.Client) sqsClient {
+	return sqsClient{
+		awsJSONClient{
+			endpoint:    endpoint,
+			region:      region,
+			service:     "sqs",
+			target:      "AmazonSQS",
+			credentials: credentials,
+			client:      client,
+			now:         time.Now,
+		},
+	}
+}
+
+// sqsQueue is a queue polled for an SQS event source.
+type sqsQueue struct {
+	url string
+	// arn is the event source ARN of the messages, derived from the queue URL.
+	arn    string
+	region string
+}
+
+// newSQSQueue parses the URL of a queue, like
+// https://sqs.us-east-1.amazonaws.com/123456789012/orders. region is used when the host doesn't
+// name one, like local endpoints.
+func newSQSQueue(queueURL, region string) (sqsQueue, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return sqsQueue{}, fmt.Errorf("[in lambdalocal.newSQSQueue] invalid queue URL '%s'", queueURL)
+	}
+
+	// hosts of AWS are sqs.REGION.amazonaws.com
+	if parts := strings.Split(u.Hostname(), "."); len(parts) > 2 && parts[0] == "sqs" { //nolint:mnd
+		region = parts[1]
+	}
+
+	if region == "" {
+		region = sqsDefaultRegion
+	}
+
+	account, name, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+
+	return sqsQueue{
+		url:    queueURL,
+		arn:    fmt.Sprintf("arn:aws:sqs:%s:%s:%s", region, account, name),
+		region: region,
+	}, nil
+}
+
+// receive returns up to maxMessages messages, waiting up to wait for the first one.
+func (c sqsClient) receive(
+	ctx context.Context,
+	queue sqsQueue,
+	maxMessages int,
+	wait time.Duration,
+) ([]sqsMessage, error) {
+	var out struct {
+		Messages []sqsMessage `json:"Messages"` //nolint:tagliatelle
+	}
+
+	err := c.call(
+		ctx,
+		"ReceiveMessage",
+		map[string]any{
+			"QueueUrl":              queue.url,
+			"MaxNumberOfMessages":   maxMessages,
+			"WaitTimeSeconds":       int(wait.Seconds()),
+			"AttributeNames":        []string{"All"},
+			"MessageAttributeNames": []string{"All"},
+		},
+		&out,
+	)
+
+	return out.Messages, err
+}
+
+// delete deletes messages from the queue. It returns an error naming the messages that weren't
+// deleted.
+func (c sqsClient) delete(ctx context.Context, queue sqsQueue, messages []sqsMessage) error {
+	var failed []string
+
+	for start := 0; start < len(messages); start += sqsMaxMessages {
+		batch := messages[start:min(start+sqsMaxMessages, len(messages))]
+
+		entries := make([]map[string]string, 0, len(batch))
+		for i, message := range batch {
+			entries = append(entries, map[string]string{"Id": strconv.Itoa(i), "ReceiptHandle": message.ReceiptHandle})
+		}
+
+		var out struct {
+			Failed []struct {
+				ID      string `json:"Id"`      //nolint:tagliatelle
+				Message string `json:"Message"` //nolint:tagliatelle
+			} `json:"Failed"` //nolint:tagliatelle
+		}
+
+		err := c.call(ctx, "DeleteMessageBatch", map[string]any{"QueueUrl": queue.url, "Entries": entries}, &out)
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.sqsClient.delete] %w", err)
+		}
+
+		for _, entry := range out.Failed {
+			if i, err := strconv.Atoi(entry.ID); err == nil && i < len(batch) {
+				failed = append(failed, batch[i].MessageID+": "+entry.Message)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("[in lambdalocal.sqsClient.delete] delete failed for %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// send sends a message with body and string attributes to the queue.
+func (c sqsClient) send(ctx context.Context, queue sqsQueue, body string, attributes map[string]string) error {
+	messageAttributes := make(map[string]any, len(attributes))
+	for name, value := range attributes {
+		messageAttributes[name] = map[string]string{"DataType": "String", "StringValue": value}
+	}
+
+	var out struct {
+		MessageID string `json:"MessageId"` //nolint:tagliatelle
+	}
+
+	err := c.call(
+		ctx,
+		"SendMessage",
+		map[string]any{"QueueUrl": queue.url, "MessageBody": body, "MessageAttributes": messageAttributes},
+		&out,
+	)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.sqsClient.send] %w", err)
+	}
+
+	return nil
+}
+
+// sqsEvent wraps messages in the event lambda receives from an SQS event source.
+func sqsEvent(queue sqsQueue, messages []sqsMessage) events.SQSEvent {
+	event := events.SQSEvent{Records: make([]events.SQSMessage, 0, len(messages))}
+
+	for _, message := range messages {
+		record := events.SQSMessage{
+			MessageId:              message.MessageID,
+			ReceiptHandle:          message.ReceiptHandle,
+			Body:                   message.Body,
+			Md5OfBody:              message.MD5OfBody,
+			Md5OfMessageAttributes: message.MD5OfAttributes,
+			Attributes:             message.Attributes,
+			MessageAttributes:      make(map[string]events.SQSMessageAttribute, len(message.MessageAttributes)),
+			EventSourceARN:         queue.arn,
+			EventSource:            "aws:sqs",
+			AWSRegion:              queue.region,
+		}
+
+		if record.Attributes == nil {
+			record.Attributes = map[string]string{}
+		}
+
+		for name, attribute := range message.MessageAttributes {
+			value := events.SQSMessageAttribute{StringValue: attribute.StringValue, DataType: attribute.DataType}
+			value.BinaryValue, _ = base64.StdEncoding.DecodeString(attribute.BinaryValue)
+
+			record.MessageAttributes[name] = value
+		}
+
+		event.Records = append(event.Records, record)
+	}
+
+	return event
+}
+
+// sqsSource is an SQS event source of a function.
+type sqsSource struct {
+	queue     sqsQueue
+	batchSize int
+	// wait is the long polling time of ReceiveMessage.
+	wait time.Duration
+	// exitWhenEmpty stops polling once the queue has no messages.
+	exitWhenEmpty bool
+	// reportBatchItemFailures deletes only the messages the lambda doesn't report as failed.
+	reportBatchItemFailures bool
+}
+
+// RunSQS polls the queue of source and invokes the lambda with batches of up to source.batchSize
+// messages. Messages of successful invocations are deleted, the others become visible again after
+// the visibility timeout of the queue, like with a real event source mapping. With
+// source.reportBatchItemFailures the messages in the batchItemFailures of the response are kept.
+func RunSQS(
+	ctx context.Context,
+	client sqsClient,
+	lambdaRPC lambdaCaller,
+	source sqsSource,
+	format responseFormat,
+	logger *slog.Logger,
+) error {
+	// poll until interrupted or terminated
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info(fmt.Sprintf("Polling %s with batch size %d", source.queue.url, source.batchSize))
+
+	for {
+		messages, err := receiveBatch(ctx, client, source)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunSQS] %w", err)
+		}
+
+		if len(messages) == 0 {
+			if source.exitWhenEmpty {
+				logger.Info("Queue is empty, exiting")
+
+				return nil
+			}
+
+			continue
+		}
+
+		event, err := json.Marshal(sqsEvent(source.queue, messages))
+		if err != nil {
+			return fmt.Errorf("[in lambdalocal.RunSQS] marshal event failed: %w", err)
+		}
+
+		invokeResponse, err := lambdaRPC.Invoke(ctx, event)
+		if err != nil {
+			logger.Error(
+				fmt.Sprintf("[in lambdalocal.RunSQS] invoke failed, %d messages will be retried", len(messages)),
+				"err",
+				err,
+			)
+
+			continue
+		}
+
+		if err = printResponse(logger, invokeResponse, format); err != nil {
+			return fmt.Errorf("[in lambdalocal.RunSQS] printResponse failed: %w", err)
+		}
+
+		if invokeResponse.Error != nil {
+			logger.Warn(fmt.Sprintf("Lambda returned an error, %d messages will be retried", len(messages)))
+
+			continue
+		}
+
+		if source.reportBatchItemFailures {
+			received := len(messages)
+
+			messages, err = succeededMessages(invokeResponse.Payload, messages)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("%s, %d messages will be retried", err, received))
+
+				continue
+			}
+
+			if failed := received - len(messages); failed > 0 {
+				logger.Warn(fmt.Sprintf("Lambda reported %d failed messages, they will be retried", failed))
+			}
+		}
+
+		if len(messages) == 0 {
+			continue
+		}
+
+		if err = client.delete(ctx, source.queue, messages); err != nil {
+			logger.Error("[in lambdalocal.RunSQS] delete failed", "err", err)
+
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Deleted %d messages", len(messages)))
+	}
+}
+
+// receiveBatch receives up to source.batchSize messages. Only the first ReceiveMessage call waits
+// for messages, the batch is filled with the messages available right away.
+func receiveBatch(ctx context.Context, client sqsClient, source sqsSource) ([]sqsMessage, error) {
+	var batch []sqsMessage
+
+	wait := source.wait
+
+	for len(batch) < source.batchSize {
+		messages, err := client.receive(ctx, source.queue, min(sqsMaxMessages, source.batchSize-len(batch)), wait)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(messages) == 0 {
+			break
+		}
+
+		batch = append(batch, messages...)
+		wait = 0
+	}
+
+	return batch, nil
+}
+
+// succeededMessages returns the messages of the batch that aren't in the batchItemFailures of
+// payload.
+func succeededMessages(payload []byte, messages []sqsMessage) ([]sqsMessage, error) {
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.MessageID)
+	}
+
+	failed, err := batchItemFailures(payload, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var succeeded []sqsMessage
+
+	for _, message := range messages {
+		if !failed[message.MessageID] {
+			succeeded = append(succeeded, message)
+		}
+	}
+
+	return succeeded, nil
+}
+
+// templateSQSSource returns the source with the BatchSize and FunctionResponseTypes of the SQS
+// event of function in the template at templatePath.
+func templateSQSSource(
+	templatePath, function string,
+	reader fileReader,
+	overrides map[string]string,
+) (sqsSource, error) {
+	data, err := reader.read(templatePath)
+	if err != nil {
+		return sqsSource{}, fmt.Errorf("[in lambdalocal.templateSQSSource] read template failed: %w", err)
+	}
+
+	SAMData := samTemplate{}
+	if err = unmarshalTemplate(data, overrides, &SAMData); err != nil {
+		return sqsSource{}, fmt.Errorf("[in lambdalocal.templateSQSSource] unmarshal yaml failed: %w", err)
+	}
+
+	resource, ok := SAMData.Resources[function]
+	if !ok {
+		return sqsSource{}, fmt.Errorf(
+			"[in lambdalocal.templateSQSSource] function '%s' not found in template '%s'",
+			function,
+			templatePath,
+		)
+	}
+
+	for _, event := range resource.Properties.Events {
+		if event.Type != eventTypeSQS {
+			continue
+		}
+
+		source := sqsSource{
+			batchSize:               sqsDefaultBatchSize,
+			reportBatchItemFailures: reportsBatchItemFailures(event.Properties.FunctionResponseTypes),
+		}
+
+		if event.Properties.BatchSize > 0 {
+			source.batchSize = event.Properties.BatchSize
+		}
+
+		return source, nil
+	}
+
+	return sqsSource{}, fmt.Errorf("[in lambdalocal.templateSQSSource] function '%s' has no SQS event", function)
+}
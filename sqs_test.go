@@ -0,0 +1,346 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSQS is an SQS queue served with the AWS JSON protocol. Received messages are hidden until
+// they are deleted, like with a long visibility timeout.
+type fakeSQS struct {
+	mu       sync.Mutex
+	messages []sqsMessage
+	inFlight map[string]sqsMessage
+	deleted  []string
+}
+
+func newFakeSQS(bodies ...string) *fakeSQS {
+	f := &fakeSQS{inFlight: make(map[string]sqsMessage)}
+
+	for i, body := range bodies {
+		f.messages = append(
+			f.messages, sqsMessage{
+				MessageID:     "message-" + strconv.Itoa(i),
+				ReceiptHandle: "receipt-" + strconv.Itoa(i),
+				Body:          body,
+			},
+		)
+	}
+
+	return f
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm) {
+		w.WriteHeader(http.StatusForbidden)
+
+		return
+	}
+
+	var in struct {
+		MaxNumberOfMessages int `json:"MaxNumberOfMessages"` //nolint:tagliatelle
+		Entries             []struct {
+			ID            string `json:"Id"`            //nolint:tagliatelle
+			ReceiptHandle string `json:"ReceiptHandle"` //nolint:tagliatelle
+		} `json:"Entries"` //nolint:tagliatelle
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&in)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSQS.ReceiveMessage":
+		n := min(in.MaxNumberOfMessages, len(f.messages))
+		received := f.messages[:n]
+		f.messages = f.messages[n:]
+
+		for _, message := range received {
+			f.inFlight[message.ReceiptHandle] = message
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"Messages": received})
+	case "AmazonSQS.DeleteMessageBatch":
+		for _, entry := range in.Entries {
+			f.deleted = append(f.deleted, f.inFlight[entry.ReceiptHandle].MessageID)
+			delete(f.inFlight, entry.ReceiptHandle)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"Successful": in.Entries})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.sqs#InvalidAction","message":"unknown action"}`))
+	}
+}
+
+func TestRunSQS(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		bodies              []string
+		batchSize           int
+		reportFailures      bool
+		lambdaPayload       string
+		lambdaError         *messages.InvokeResponse_Error
+		expectedInvocations int
+		expectedDeleted     int
+	}{
+		"batches are deleted on success": {
+			bodies:              []string{"a", "b", "c", "d", "e"},
+			batchSize:           3,
+			expectedInvocations: 2,
+			expectedDeleted:     5,
+		},
+		"batches larger than a receive": {
+			bodies:              strings.Split("abcdefghijklmnopqrstuvwxy", ""),
+			batchSize:           20,
+			expectedInvocations: 2,
+			expectedDeleted:     25,
+		},
+		"failed batches are kept": {
+			bodies:              []string{"a", "b"},
+			batchSize:           10,
+			lambdaError:         &messages.InvokeResponse_Error{Message: "boom"},
+			expectedInvocations: 1,
+			expectedDeleted:     0,
+		},
+		"reported failures are kept": {
+			bodies:              []string{"a", "b", "c"},
+			batchSize:           10,
+			reportFailures:      true,
+			lambdaPayload:       `{"batchItemFailures":[{"itemIdentifier":"message-1"}]}`,
+			expectedInvocations: 1,
+			expectedDeleted:     2,
+		},
+		"invalid partial batch response keeps the batch": {
+			bodies:              []string{"a", "b"},
+			batchSize:           10,
+			reportFailures:      true,
+			lambdaPayload:       `{"batchItemFailures":[{"itemIdentifier":"unknown"}]}`,
+			expectedInvocations: 1,
+			expectedDeleted:     0,
+		},
+		"failures are ignored without ReportBatchItemFailures": {
+			bodies:              []string{"a", "b"},
+			batchSize:           10,
+			lambdaPayload:       `{"batchItemFailures":[{"itemIdentifier":"message-1"}]}`,
+			expectedInvocations: 1,
+			expectedDeleted:     2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				fake := newFakeSQS(tc.bodies...)
+				server := httptest.NewServer(fake)
+
+				t.Cleanup(server.Close)
+
+				queue, err := newSQSQueue(server.URL+"/000000000000/orders", "eu-west-1")
+				require.NoError(t, err)
+
+				client := newSQSClient(
+					server.URL,
+					queue.region,
+					awsCredentials{accessKeyID: "id", secretAccessKey: "secret"},
+					server.Client(),
+				)
+
+				var batches []events.SQSEvent
+
+				mockLambdaRPC := new(MockLambdaCaller)
+				mockLambdaRPC.On("Invoke", mock.Anything).
+					Run(
+						func(args mock.Arguments) {
+							var event events.SQSEvent
+							require.NoError(t, json.Unmarshal(args.Get(0).([]byte), &event))
+
+							batches = append(batches, event)
+						},
+					).
+					Return(
+						messages.InvokeResponse{Payload: []byte(cmp.Or(tc.lambdaPayload, `{}`)), Error: tc.lambdaError},
+						nil,
+					)
+
+				source := sqsSource{
+					queue:                   queue,
+					batchSize:               tc.batchSize,
+					exitWhenEmpty:           true,
+					reportBatchItemFailures: tc.reportFailures,
+				}
+
+				err = RunSQS(context.Background(), client, mockLambdaRPC, source, responseFormat{}, slog.Default())
+				require.NoError(t, err)
+
+				require.Len(t, batches, tc.expectedInvocations)
+				assert.Len(t, fake.deleted, tc.expectedDeleted)
+
+				record := batches[0].Records[0]
+				assert.Equal(t, "message-0", record.MessageId)
+				assert.Equal(t, "aws:sqs", record.EventSource)
+				assert.Equal(t, "arn:aws:sqs:eu-west-1:000000000000:orders", record.EventSourceARN)
+				assert.Equal(t, "eu-west-1", record.AWSRegion)
+			},
+		)
+	}
+}
+
+func TestSQSClientError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newFakeSQS())
+	t.Cleanup(server.Close)
+
+	client := newSQSClient(server.URL, "", awsCredentials{}, server.Client())
+
+	var out any
+
+	err := client.call(context.Background(), "Unknown", map[string]any{}, &out)
+
+	assert.ErrorContains(t, err, "Unknown failed with status 400: com.amazonaws.sqs#InvalidAction unknown action")
+}
+
+func TestNewSQSQueue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		url            string
+		region         string
+		expected       sqsQueue
+		expectedErrStr string
+	}{
+		"aws queue": {
+			url:    "https://sqs.eu-central-1.amazonaws.com/123456789012/orders",
+			region: "us-west-2",
+			expected: sqsQueue{
+				url:    "https://sqs.eu-central-1.amazonaws.com/123456789012/orders",
+				arn:    "arn:aws:sqs:eu-central-1:123456789012:orders",
+				region: "eu-central-1",
+			},
+		},
+		"local queue": {
+			url: "http://localhost:9324/000000000000/orders",
+			expected: sqsQueue{
+				url:    "http://localhost:9324/000000000000/orders",
+				arn:    "arn:aws:sqs:us-east-1:000000000000:orders",
+				region: "us-east-1",
+			},
+		},
+		"invalid url": {
+			url:            "orders",
+			expectedErrStr: "[in lambdalocal.newSQSQueue] invalid queue URL 'orders'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				queue, err := newSQSQueue(tc.url, tc.region)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, queue)
+			},
+		)
+	}
+}
+
+func TestTemplateSQSSource(t *testing.T) {
+	t.Parallel()
+
+	template := `
+Resources:
+  OrderFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Orders:
+          Type: SQS
+          Properties:
+            Queue: !GetAtt OrderQueue.Arn
+            BatchSize: 25
+            FunctionResponseTypes:
+              - ReportBatchItemFailures
+  DefaultFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Orders:
+          Type: SQS
+          Properties:
+            Queue: !GetAtt OrderQueue.Arn
+  ApiFunction:
+    Type: AWS::Serverless::Function
+`
+
+	tests := map[string]struct {
+		function       string
+		expected       sqsSource
+		expectedErrStr string
+	}{
+		"batch size": {
+			function: "OrderFunction",
+			expected: sqsSource{batchSize: 25, reportBatchItemFailures: true},
+		},
+		"default batch size": {
+			function: "DefaultFunction",
+			expected: sqsSource{batchSize: sqsDefaultBatchSize},
+		},
+		"no sqs event": {
+			function:       "ApiFunction",
+			expectedErrStr: "[in lambdalocal.templateSQSSource] function 'ApiFunction' has no SQS event",
+		},
+		"missing function": {
+			function:       "MissingFunction",
+			expectedErrStr: "[in lambdalocal.templateSQSSource] function 'MissingFunction' not found",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "template.yaml").Return([]byte(template), nil)
+
+				source, err := templateSQSSource("template.yaml", tc.function, mockReader, nil)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, source)
+			},
+		)
+	}
+}
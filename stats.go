@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+const statsDayFormat = "2006-01-02"
+
+// usageStats is the format of the local stats file. Nothing in it leaves the machine.
+type usageStats struct {
+	// Days holds the stats of every route keyed by day and route.
+	Days map[string]map[string]*routeStats `json:"days"`
+}
+
+type routeStats struct {
+	Invocations    int64   `json:"invocations"`
+	Errors         int64   `json:"errors"`
+	TotalLatencyMs float64 `json:"totalLatencyMs"`
+}
+
+func (s *routeStats) add(other routeStats) {
+	s.Invocations += other.Invocations
+	s.Errors += other.Errors
+	s.TotalLatencyMs += other.TotalLatencyMs
+}
+
+// avgLatency returns the average latency, or "-" without invocations.
+func (s routeStats) avgLatency() string {
+	if s.Invocations == 0 {
+		return "-"
+	}
+
+	avg := time.Duration(s.TotalLatencyMs / float64(s.Invocations) * float64(time.Millisecond))
+
+	return avg.Round(time.Millisecond).String()
+}
+
+// loadUsageStats reads the stats file at statsPath. A missing file results in empty stats.
+func loadUsageStats(statsPath string, reader fileReader) (usageStats, error) {
+	stats := usageStats{Days: make(map[string]map[string]*routeStats)}
+
+	data, err := reader.read(statsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	}
+
+	if err != nil {
+		return stats, fmt.Errorf("[in lambdalocal.loadUsageStats] read file failed: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("[in lambdalocal.loadUsageStats] unmarshal stats failed: %w", err)
+	}
+
+	if stats.Days == nil {
+		stats.Days = make(map[string]map[string]*routeStats)
+	}
+
+	return stats, nil
+}
+
+// statsRecorder adds invocations to the stats in store. Every invocation is merged into the stats
+// as they are stored, so several lambdalocal processes can share them.
+type statsRecorder struct {
+	store  store
+	key    string
+	now    func() time.Time
+	logger *slog.Logger
+
+	mu sync.Mutex
+}
+
+// newStatsRecorder records stats in s under key.
+func newStatsRecorder(s store, key string, logger *slog.Logger) *statsRecorder {
+	return &statsRecorder{
+		store:  s,
+		key:    key,
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// record adds an invocation of route to today's stats.
+func (s *statsRecorder) record(route string, latency time.Duration, failed bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, err := loadUsageStats(s.key, s.store)
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.statsRecorder.record] loadUsageStats failed: %w", err)
+	}
+
+	day := s.now().Format(statsDayFormat)
+	if stats.Days[day] == nil {
+		stats.Days[day] = make(map[string]*routeStats)
+	}
+
+	if stats.Days[day][route] == nil {
+		stats.Days[day][route] = &routeStats{}
+	}
+
+	invocation := routeStats{Invocations: 1, TotalLatencyMs: float64(latency) / float64(time.Millisecond)}
+	if failed {
+		invocation.Errors = 1
+	}
+
+	stats.Days[day][route].add(invocation)
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.statsRecorder.record] marshal stats failed: %w", err)
+	}
+
+	if err = s.store.write(s.key, data); err != nil {
+		return fmt.Errorf("[in lambdalocal.statsRecorder.record] write failed: %w", err)
+	}
+
+	return nil
+}
+
+// caller returns a lambdaCaller that records the invocations of caller for route.
+func (s *statsRecorder) caller(caller lambdaCaller, route string) lambdaCaller {
+	return statsCaller{lambdaCaller: caller, recorder: s, route: route}
+}
+
+type statsCaller struct {
+	lambdaCaller
+	recorder *statsRecorder
+	route    string
+}
+
+func (c statsCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	start := time.Now()
+	response, err := c.lambdaCaller.Invoke(ctx, data, options...)
+
+	// a stats file that can't be written never fails the invocation
+	failed := err != nil || response.Error != nil
+	if recordErr := c.recorder.record(c.route, time.Since(start), failed); recordErr != nil {
+		c.recorder.logger.Warn("[in lambdalocal.statsCaller.Invoke] record failed", "err", recordErr)
+	}
+
+	return response, err //nolint:wrapcheck
+}
+
+// RunStats prints the invocations of the last days up to today, per day and per route. Routes of
+// the template that weren't invoked are listed too, so the output shows which routes are tested
+// locally.
+func RunStats(w io.Writer, stats usageStats, templateRoutes []string, days int, today time.Time) error {
+	if days <= 0 {
+		return fmt.Errorf("[in lambdalocal.RunStats] days must be positive, got %d", days)
+	}
+
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = today.AddDate(0, 0, i-days+1).Format(statsDayFormat)
+	}
+
+	routes := make(map[string]*routeStats)
+	for _, route := range templateRoutes {
+		routes[route] = &routeStats{}
+	}
+
+	_, _ = fmt.Fprintf(w, "Local invocations from %s to %s\n\n", dates[0], dates[len(dates)-1])
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd
+	_, _ = fmt.Fprintln(tw, "DAY\tINVOCATIONS\tERRORS\tAVG LATENCY")
+
+	for _, date := range dates {
+		total := routeStats{}
+
+		for route, stats := range stats.Days[date] {
+			total.add(*stats)
+
+			if routes[route] == nil {
+				routes[route] = &routeStats{}
+			}
+
+			routes[route].add(*stats)
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", date, total.Invocations, total.Errors, total.avgLatency())
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunStats] Flush failed: %w", err)
+	}
+
+	if len(routes) == 0 {
+		return nil
+	}
+
+	// most invoked routes first
+	names := make([]string, 0, len(routes))
+	for route := range routes {
+		names = append(names, route)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if routes[names[i]].Invocations != routes[names[j]].Invocations {
+			return routes[names[i]].Invocations > routes[names[j]].Invocations
+		}
+
+		return names[i] < names[j]
+	})
+
+	_, _ = fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:mnd
+	_, _ = fmt.Fprintln(tw, "ROUTE\tINVOCATIONS\tERRORS\tAVG LATENCY\tPER DAY")
+
+	for _, route := range names {
+		daily := make([]int64, len(dates))
+		for i, date := range dates {
+			if dayStats := stats.Days[date][route]; dayStats != nil {
+				daily[i] = dayStats.Invocations
+			}
+		}
+
+		total := routes[route]
+		_, _ = fmt.Fprintf(
+			tw,
+			"%s\t%d\t%d\t%s\t%s\n",
+			route,
+			total.Invocations,
+			total.Errors,
+			total.avgLatency(),
+			sparkline(daily),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("[in lambdalocal.RunStats] Flush failed: %w", err)
+	}
+
+	if len(templateRoutes) > 0 {
+		invoked := 0
+
+		for _, route := range templateRoutes {
+			if routes[route].Invocations > 0 {
+				invoked++
+			}
+		}
+
+		_, _ = fmt.Fprintf(w, "\n%d of %d template routes invoked\n", invoked, len(templateRoutes))
+	}
+
+	return nil
+}
+
+// sparkline draws counts as bars scaled to the largest count. Days without invocations are dots.
+func sparkline(counts []int64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+
+	var largest int64
+	for _, count := range counts {
+		largest = max(largest, count)
+	}
+
+	var b strings.Builder
+
+	for _, count := range counts {
+		if count == 0 {
+			b.WriteRune('·')
+
+			continue
+		}
+
+		level := int(math.Ceil(float64(count)/float64(largest)*float64(len(bars)))) - 1
+		b.WriteRune(bars[level])
+	}
+
+	return b.String()
+}
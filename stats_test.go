@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCaller(t *testing.T) {
+	t.Parallel()
+
+	statsFile := filepath.Join(t.TempDir(), "stats", "stats.json")
+	day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	statsStore, key, err := storeFor("", statsFile)
+	require.NoError(t, err)
+
+	recorder := newStatsRecorder(statsStore, key, slog.Default())
+	recorder.now = func() time.Time { return day }
+
+	caller := new(MockLambdaCaller)
+	caller.On("Invoke", []byte(`{"ok":true}`)).Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil)
+	caller.On("Invoke", []byte(`{"fail":true}`)).
+		Return(messages.InvokeResponse{Error: &messages.InvokeResponse_Error{Message: "failed"}}, nil)
+	caller.On("Invoke", mock.Anything).Return(messages.InvokeResponse{}, errors.New("connection refused"))
+
+	hello := recorder.caller(caller, "GET /hello")
+	order := recorder.caller(caller, "POST /order")
+
+	_, err = hello.Invoke(context.Background(), []byte(`{"ok":true}`))
+	require.NoError(t, err)
+	_, err = hello.Invoke(context.Background(), []byte(`{"fail":true}`))
+	require.NoError(t, err)
+	_, err = order.Invoke(context.Background(), []byte(`{}`))
+	require.Error(t, err)
+
+	// invocations of the next day are kept apart
+	recorder.now = func() time.Time { return day.AddDate(0, 0, 1) }
+	_, err = hello.Invoke(context.Background(), []byte(`{"ok":true}`))
+	require.NoError(t, err)
+
+	stats, err := loadUsageStats(statsFile, osFileReader{})
+	require.NoError(t, err)
+
+	require.Len(t, stats.Days, 2)
+	assert.Equal(t, int64(2), stats.Days["2024-03-01"]["GET /hello"].Invocations)
+	assert.Equal(t, int64(1), stats.Days["2024-03-01"]["GET /hello"].Errors)
+	assert.Equal(t, int64(1), stats.Days["2024-03-01"]["POST /order"].Errors)
+	assert.Equal(t, int64(1), stats.Days["2024-03-02"]["GET /hello"].Invocations)
+}
+
+func TestLoadUsageStats(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mockReturn     []any
+		expectedDays   int
+		expectedErrStr string
+	}{
+		"missing file": {
+			mockReturn:   []any{[]byte{}, fs.ErrNotExist},
+			expectedDays: 0,
+		},
+		"stats file": {
+			mockReturn:   []any{[]byte(`{"days":{"2024-03-01":{"GET /hello":{"invocations":1}}}}`), nil},
+			expectedDays: 1,
+		},
+		"invalid file": {
+			mockReturn:     []any{[]byte(`{`), nil},
+			expectedErrStr: "[in lambdalocal.loadUsageStats] unmarshal stats failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				mockReader := new(mockOSFileReader)
+				mockReader.On("read", "stats.json").Return(tc.mockReturn...).Once()
+
+				stats, err := loadUsageStats("stats.json", mockReader)
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Len(t, stats.Days, tc.expectedDays)
+			},
+		)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	t.Parallel()
+
+	stats := usageStats{
+		Days: map[string]map[string]*routeStats{
+			"2024-03-01": {
+				"GET /hello":    {Invocations: 4, TotalLatencyMs: 100},
+				"event HelloFn": {Invocations: 1, Errors: 1, TotalLatencyMs: 5},
+			},
+			"2024-03-03": {
+				"GET /hello": {Invocations: 1, TotalLatencyMs: 50},
+			},
+			// outside of the shown days
+			"2024-02-01": {
+				"GET /hello": {Invocations: 100},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+
+	err := RunStats(
+		&out,
+		stats,
+		[]string{"GET /hello", "POST /order"},
+		3,
+		time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		`Local invocations from 2024-03-01 to 2024-03-03
+
+DAY         INVOCATIONS  ERRORS  AVG LATENCY
+2024-03-01  5            1       21ms
+2024-03-02  0            0       -
+2024-03-03  1            0       50ms
+
+ROUTE          INVOCATIONS  ERRORS  AVG LATENCY  PER DAY
+GET /hello     5            0       30ms         █·▂
+event HelloFn  1            1       5ms          █··
+POST /order    0            0       -            ···
+
+1 of 2 template routes invoked
+`,
+		out.String(),
+	)
+}
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", sparkline(nil))
+	assert.Equal(t, "···", sparkline([]int64{0, 0, 0}))
+	assert.Equal(t, "▁▄█·", sparkline([]int64{1, 4, 8, 0}))
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultStoreDir is the store of the data that isn't kept next to a file, like recordings,
+	// without --store.
+	defaultStoreDir = ".lambdalocal"
+
+	storeSchemeFile   = "file://"
+	storeSchemeSQLite = "sqlite://"
+	storeSchemeS3     = "s3://"
+)
+
+// store persists the local data of lambdalocal, like the usage stats, by key. Keys are slash
+// separated paths, e.g. stats.json.
+type store interface {
+	// read returns the data stored under key, or an error wrapping fs.ErrNotExist.
+	read(key string) ([]byte, error)
+	// write replaces the data stored under key.
+	write(key string, data []byte) error
+	// delete removes the data stored under key. A missing key isn't an error.
+	delete(key string) error
+	// list returns the entries whose key starts with prefix, sorted by key.
+	list(prefix string) ([]storeEntry, error)
+}
+
+// storeEntry is an entry returned by store.list.
+type storeEntry struct {
+	key  string
+	size int64
+}
+
+// openStore opens the store at location: sqlite://PATH for a SQLite database, s3://BUCKET/PREFIX
+// for an S3 bucket shared by a team, or a directory, optionally prefixed with file://.
+func openStore(location string) (store, error) {
+	switch {
+	case strings.HasPrefix(location, storeSchemeSQLite):
+		s, err := openSQLiteStore(strings.TrimPrefix(location, storeSchemeSQLite))
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.openStore] %w", err)
+		}
+
+		return s, nil
+	case strings.HasPrefix(location, storeSchemeS3):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, storeSchemeS3), "/")
+
+		s, err := openS3Store(bucket, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.openStore] %w", err)
+		}
+
+		return s, nil
+	case strings.Contains(location, "://") && !strings.HasPrefix(location, storeSchemeFile):
+		return nil, fmt.Errorf(
+			"[in lambdalocal.openStore] unsupported store '%s', expected a directory, sqlite://PATH or s3://BUCKET",
+			location,
+		)
+	default:
+		return fileStore{dir: strings.TrimPrefix(location, storeSchemeFile)}, nil
+	}
+}
+
+// storeFor returns the store that holds the file at path and its key in it. Without a store
+// location, the file is stored in its directory. With one, path is the key in that store.
+func storeFor(location, path string) (store, string, error) {
+	if location == "" {
+		return fileStore{dir: filepath.Dir(path)}, filepath.Base(path), nil
+	}
+
+	s, err := openStore(location)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s, filepath.ToSlash(path), nil
+}
+
+// fileStore stores every key as a file below dir.
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) read(key string) ([]byte, error) {
+	return os.ReadFile(s.path(key)) //nolint:wrapcheck
+}
+
+func (s fileStore) write(key string, data []byte) error {
+	return writeFileAtomic(s.path(key), data)
+}
+
+func (s fileStore) delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err //nolint:wrapcheck
+	}
+
+	return nil
+}
+
+func (s fileStore) list(prefix string) ([]storeEntry, error) {
+	var entries []storeEntry
+
+	err := filepath.WalkDir(
+		s.dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// a store nothing was written to yet is empty
+				if path == s.dir && errors.Is(err, fs.ErrNotExist) {
+					return filepath.SkipAll
+				}
+
+				return err
+			}
+
+			rel, err := filepath.Rel(s.dir, path)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			// the temporary files of writeFileAtomic aren't entries
+			key := filepath.ToSlash(rel)
+			if entry.IsDir() || !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, ".tmp") {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			entries = append(entries, storeEntry{key: key, size: info.Size()})
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	slices.SortFunc(
+		entries, func(a, b storeEntry) int {
+			return strings.Compare(a.key, b.key)
+		},
+	)
+
+	return entries, nil
+}
+
+func (s fileStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// memoryStore keeps the data in memory, for data that doesn't outlive the process.
+type memoryStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string][]byte)}
+}
+
+func (s *memoryStore) read(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.data[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return data, nil
+}
+
+func (s *memoryStore) write(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = data
+
+	return nil
+}
+
+func (s *memoryStore) delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, key)
+
+	return nil
+}
+
+func (s *memoryStore) list(prefix string) ([]storeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []storeEntry
+
+	for key, data := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, storeEntry{key: key, size: int64(len(data))})
+		}
+	}
+
+	slices.SortFunc(
+		entries, func(a, b storeEntry) int {
+			return strings.Compare(a.key, b.key)
+		},
+	)
+
+	return entries, nil
+}
+
+// writeFileAtomic replaces the file at path with data, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:mnd
+		return err //nolint:wrapcheck
+	}
+
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+
+		return err //nolint:wrapcheck
+	}
+
+	if err = file.Close(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return os.Rename(file.Name(), path) //nolint:wrapcheck
+}
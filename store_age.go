@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ageRecipientPrefix prefixes the recipients of --record-encrypt, which names the encryption.
+const ageRecipientPrefix = "age:"
+
+// ageStore encrypts the data of a store with age, so recorded payloads holding sensitive data
+// aren't readable at rest. The data is also encrypted for a key generated for the process, so the
+// process reads back what it wrote without the identity of a recipient. Data written by earlier
+// processes is read with identities.
+type ageStore struct {
+	store
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// newAgeStore returns s encrypting for the age:RECIPIENT recipients and decrypting with the
+// identities of identityFile, when set.
+func newAgeStore(s store, recipients []string, identityFile string) (*ageStore, error) {
+	session, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.newAgeStore] generate key failed: %w", err)
+	}
+
+	encrypted := &ageStore{
+		store:      s,
+		recipients: []age.Recipient{session.Recipient()},
+		identities: []age.Identity{session},
+	}
+
+	for _, value := range recipients {
+		recipient, err := parseAgeRecipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.newAgeStore] %w", err)
+		}
+
+		encrypted.recipients = append(encrypted.recipients, recipient)
+	}
+
+	if identityFile != "" {
+		data, err := os.ReadFile(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("[in lambdalocal.newAgeStore] read identity file failed: %w", err)
+		}
+
+		identities, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"[in lambdalocal.newAgeStore] parse identity file '%s' failed: %w",
+				identityFile,
+				err,
+			)
+		}
+
+		encrypted.identities = append(encrypted.identities, identities...)
+	}
+
+	return encrypted, nil
+}
+
+// parseAgeRecipient parses an age:RECIPIENT value, an age X25519 public key like age1....
+func parseAgeRecipient(value string) (*age.X25519Recipient, error) {
+	key, ok := strings.CutPrefix(value, ageRecipientPrefix)
+	if !ok {
+		return nil, fmt.Errorf("expected age:RECIPIENT, got '%s'", value)
+	}
+
+	recipient, err := age.ParseX25519Recipient(key)
+	if err != nil {
+		return nil, fmt.Errorf("parse recipient '%s' failed: %w", value, err)
+	}
+
+	return recipient, nil
+}
+
+func (s *ageStore) read(key string) ([]byte, error) {
+	data, err := s.store.read(key)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	reader, err := age.Decrypt(bytes.NewReader(data), s.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt '%s' failed: %w", key, err)
+	}
+
+	return io.ReadAll(reader) //nolint:wrapcheck
+}
+
+func (s *ageStore) write(key string, data []byte) error {
+	var encrypted bytes.Buffer
+
+	writer, err := age.Encrypt(&encrypted, s.recipients...)
+	if err != nil {
+		return fmt.Errorf("encrypt '%s' failed: %w", key, err)
+	}
+
+	if _, err = writer.Write(data); err != nil {
+		return fmt.Errorf("encrypt '%s' failed: %w", key, err)
+	}
+
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("encrypt '%s' failed: %w", key, err)
+	}
+
+	return s.store.write(key, encrypted.Bytes()) //nolint:wrapcheck
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgeStore(t *testing.T) {
+	t.Parallel()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	identityFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600))
+
+	s := newMemoryStore()
+
+	encrypted, err := newAgeStore(s, []string{"age:" + identity.Recipient().String()}, "")
+	require.NoError(t, err)
+
+	require.NoError(t, encrypted.write("recordings/a.json", []byte(`{"password":"secret"}`)))
+
+	// the store only holds the encrypted data, which the process reads back
+	data, err := s.read("recordings/a.json")
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	data, err = encrypted.read("recordings/a.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"secret"}`, string(data))
+
+	_, err = encrypted.read("recordings/missing.json")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// a later process reads it with the identity of the recipient only
+	later, err := newAgeStore(s, []string{"age:" + identity.Recipient().String()}, "")
+	require.NoError(t, err)
+
+	_, err = later.read("recordings/a.json")
+	require.ErrorContains(t, err, "decrypt 'recordings/a.json' failed")
+
+	later, err = newAgeStore(s, []string{"age:" + identity.Recipient().String()}, identityFile)
+	require.NoError(t, err)
+
+	data, err = later.read("recordings/a.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"secret"}`, string(data))
+}
+
+func TestParseAgeRecipient(t *testing.T) {
+	t.Parallel()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	recipient, err := parseAgeRecipient("age:" + identity.Recipient().String())
+	require.NoError(t, err)
+	assert.Equal(t, identity.Recipient().String(), recipient.String())
+
+	_, err = parseAgeRecipient(identity.Recipient().String())
+	require.ErrorContains(t, err, "expected age:RECIPIENT")
+
+	_, err = parseAgeRecipient("age:age1invalid")
+	require.ErrorContains(t, err, "parse recipient 'age:age1invalid' failed")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3StoreTimeout bounds every request to S3, so an unreachable bucket doesn't hang invocations.
+const s3StoreTimeout = 30 * time.Second
+
+// s3API is the part of the S3 client used by s3Store.
+type s3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(
+		ctx context.Context,
+		params *s3.DeleteObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(
+		ctx context.Context,
+		params *s3.ListObjectsV2Input,
+		optFns ...func(*s3.Options),
+	) (*s3.ListObjectsV2Output, error)
+}
+
+// s3Store stores every key as an object of bucket below prefix, so a team can share the data. The
+// credentials, region and endpoint are read like the AWS CLI does, from the environment and the
+// shared config files.
+type s3Store struct {
+	client s3API
+	bucket string
+	prefix string
+}
+
+func openS3Store(bucket, prefix string) (*s3Store, error) {
+	if bucket == "" {
+		return nil, errors.New("s3 store requires a bucket, e.g. s3://my-bucket/lambdalocal")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3StoreTimeout)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config failed: %w", err)
+	}
+
+	return &s3Store{client: s3.NewFromConfig(cfg), bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (s *s3Store) read(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3StoreTimeout)
+	defer cancel()
+
+	output, err := s.client.GetObject(
+		ctx,
+		&s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(key))},
+	)
+	if noSuchKey := (*types.NoSuchKey)(nil); errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("read '%s': %w", key, fs.ErrNotExist)
+	}
+
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	defer func() {
+		_ = output.Body.Close()
+	}()
+
+	return io.ReadAll(output.Body) //nolint:wrapcheck
+}
+
+func (s *s3Store) write(key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3StoreTimeout)
+	defer cancel()
+
+	_, err := s.client.PutObject(
+		ctx,
+		&s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(key)), Body: bytes.NewReader(data)},
+	)
+
+	return err //nolint:wrapcheck
+}
+
+func (s *s3Store) delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3StoreTimeout)
+	defer cancel()
+
+	_, err := s.client.DeleteObject(
+		ctx,
+		&s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(key))},
+	)
+
+	return err //nolint:wrapcheck
+}
+
+func (s *s3Store) list(prefix string) ([]storeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3StoreTimeout)
+	defer cancel()
+
+	var entries []storeEntry
+
+	// S3 lists keys in UTF-8 binary order, like the other stores sort them
+	paginator := s3.NewListObjectsV2Paginator(
+		s.client,
+		&s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(s.key(prefix))},
+	)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+
+			entries = append(entries, storeEntry{key: key, size: aws.ToInt64(object.Size)})
+		}
+	}
+
+	return entries, nil
+}
+
+// key returns the object key of key.
+func (s *s3Store) key(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+
+	return s.prefix + "/" + key
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	// registers the sqlite driver of database/sql
+	_ "modernc.org/sqlite"
+)
+
+// sqliteBusyTimeout makes writers wait for the lock held by other lambdalocal processes sharing
+// the database instead of failing right away.
+const sqliteBusyTimeout = "_pragma=busy_timeout(5000)"
+
+// sqliteStore stores every key as a row of a SQLite database, one file that scales to many more
+// entries than a directory.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore opens the database at path, creating it when it doesn't exist.
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?"+sqliteBusyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database '%s' failed: %w", path, err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS entries (key TEXT PRIMARY KEY, data BLOB NOT NULL)`)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("create table of sqlite database '%s' failed: %w", path, err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) read(key string) ([]byte, error) {
+	var data []byte
+
+	err := s.db.QueryRow(`SELECT data FROM entries WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("read '%s': %w", key, fs.ErrNotExist)
+	}
+
+	return data, err //nolint:wrapcheck
+}
+
+func (s *sqliteStore) write(key string, data []byte) error {
+	_, err := s.db.Exec(
+		`INSERT INTO entries (key, data) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET data = excluded.data`,
+		key,
+		data,
+	)
+
+	return err //nolint:wrapcheck
+}
+
+func (s *sqliteStore) delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM entries WHERE key = ?`, key)
+
+	return err //nolint:wrapcheck
+}
+
+func (s *sqliteStore) list(prefix string) ([]storeEntry, error) {
+	rows, err := s.db.Query(
+		`SELECT key, length(data) FROM entries WHERE substr(key, 1, length(?1)) = ?1 ORDER BY key`,
+		prefix,
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var entries []storeEntry
+
+	for rows.Next() {
+		var entry storeEntry
+		if err = rows.Scan(&entry.key, &entry.size); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err() //nolint:wrapcheck
+}
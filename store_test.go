@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]func(t *testing.T) store{
+		"file": func(t *testing.T) store {
+			t.Helper()
+
+			return fileStore{dir: filepath.Join(t.TempDir(), "nested")}
+		},
+		"sqlite": func(t *testing.T) store {
+			t.Helper()
+
+			s, err := openStore("sqlite://" + filepath.Join(t.TempDir(), "lambdalocal.db"))
+			require.NoError(t, err)
+
+			return s
+		},
+		"s3": func(t *testing.T) store {
+			t.Helper()
+
+			return newTestS3Store(t, "team/lambdalocal")
+		},
+		"memory": func(t *testing.T) store {
+			t.Helper()
+
+			return newMemoryStore()
+		},
+	}
+
+	for name, newStore := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				s := newStore(t)
+
+				_, err := s.read("stats.json")
+				require.ErrorIs(t, err, fs.ErrNotExist)
+
+				entries, err := s.list("")
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+
+				require.NoError(t, s.write("stats.json", []byte("first")))
+				require.NoError(t, s.write("stats.json", []byte("second")))
+				require.NoError(t, s.write("history/b.json", []byte("bb")))
+				require.NoError(t, s.write("history/a.json", []byte("a")))
+
+				data, err := s.read("stats.json")
+				require.NoError(t, err)
+				assert.Equal(t, "second", string(data))
+
+				entries, err = s.list("history/")
+				require.NoError(t, err)
+				assert.Equal(
+					t,
+					[]storeEntry{{key: "history/a.json", size: 1}, {key: "history/b.json", size: 2}},
+					entries,
+				)
+
+				require.NoError(t, s.delete("history/a.json"))
+				require.NoError(t, s.delete("history/missing.json"))
+
+				entries, err = s.list("")
+				require.NoError(t, err)
+				assert.Equal(t, []storeEntry{{key: "history/b.json", size: 2}, {key: "stats.json", size: 6}}, entries)
+			},
+		)
+	}
+}
+
+func TestFileStoreAtomicWrites(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "nested")
+	s := fileStore{dir: dir}
+
+	require.NoError(t, s.write("stats.json", []byte("first")))
+	require.NoError(t, s.write("stats.json", []byte("second")))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestOpenStore(t *testing.T) {
+	t.Parallel()
+
+	s, err := openStore("file://.lambdalocal")
+	require.NoError(t, err)
+	assert.Equal(t, fileStore{dir: ".lambdalocal"}, s)
+
+	s, err = openStore(".lambdalocal")
+	require.NoError(t, err)
+	assert.Equal(t, fileStore{dir: ".lambdalocal"}, s)
+
+	_, err = openStore("redis://localhost:6379")
+	require.ErrorContains(t, err, "unsupported store 'redis://localhost:6379'")
+
+	_, err = openStore("s3://")
+	require.ErrorContains(t, err, "requires a bucket")
+
+	s, key, err := storeFor("", filepath.Join("data", "stats.json"))
+	require.NoError(t, err)
+	assert.Equal(t, fileStore{dir: "data"}, s)
+	assert.Equal(t, "stats.json", key)
+
+	_, key, err = storeFor(".lambdalocal", filepath.Join("data", "stats.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "data/stats.json", key)
+}
+
+func TestStatsRecorderStore(t *testing.T) {
+	t.Parallel()
+
+	s := newMemoryStore()
+
+	recorder := newStatsRecorder(s, "stats.json", slog.Default())
+	recorder.now = func() time.Time { return time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, recorder.record("GET /hello", 10*time.Millisecond, false))
+	require.NoError(t, recorder.record("GET /hello", 20*time.Millisecond, true))
+
+	stats, err := loadUsageStats("stats.json", s)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		&routeStats{Invocations: 2, Errors: 1, TotalLatencyMs: 30},
+		stats.Days["2024-03-01"]["GET /hello"],
+	)
+}
+
+// newTestS3Store returns an s3Store of a bucket served by a fake S3 that keeps the objects in
+// memory.
+func newTestS3Store(t *testing.T, prefix string) *s3Store {
+	t.Helper()
+
+	objects := newMemoryStore()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+
+				switch {
+				case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+					entries, _ := objects.list(r.URL.Query().Get("prefix"))
+
+					type content struct {
+						Key  string
+						Size int64
+					}
+
+					result := struct {
+						XMLName     xml.Name `xml:"ListBucketResult"`
+						Name        string
+						IsTruncated bool
+						Contents    []content
+					}{Name: "bucket"}
+
+					for _, entry := range entries {
+						result.Contents = append(result.Contents, content{Key: entry.key, Size: entry.size})
+					}
+
+					_ = xml.NewEncoder(w).Encode(result)
+				case r.Method == http.MethodGet:
+					data, err := objects.read(key)
+					if err != nil {
+						w.WriteHeader(http.StatusNotFound)
+						_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+
+						return
+					}
+
+					_, _ = w.Write(data)
+				case r.Method == http.MethodPut:
+					data, _ := io.ReadAll(r.Body)
+					_ = objects.write(key, data)
+				case r.Method == http.MethodDelete:
+					_ = objects.delete(key)
+					w.WriteHeader(http.StatusNoContent)
+				}
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	client := s3.New(
+		s3.Options{
+			BaseEndpoint: aws.String(server.URL),
+			Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+			Region:       "us-east-1",
+			UsePathStyle: true,
+		},
+	)
+
+	return &s3Store{client: client, bucket: "bucket", prefix: prefix}
+}
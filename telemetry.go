@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	telemetryAPIPath = "/2022-07-01/telemetry"
+
+	telemetryTypePlatform  = "platform"
+	telemetryTypeFunction  = "function"
+	telemetryTypeExtension = "extension"
+
+	// defaults of the buffering configuration, like Lambda's.
+	telemetryDefaultMaxItems = 1000
+	telemetryDefaultMaxBytes = 256 * 1024
+	telemetryDefaultTimeout  = time.Second
+
+	// telemetrySandboxHost is the host extensions listen on inside Lambda, which is the local
+	// machine when running locally.
+	telemetrySandboxHost = "sandbox.localdomain"
+	// telemetryPostTimeout limits how long a subscriber gets to accept a batch.
+	telemetryPostTimeout = 5 * time.Second
+)
+
+// telemetryEvent is a record of the Telemetry API.
+type telemetryEvent struct {
+	Time   string `json:"time"`
+	Type   string `json:"type"`
+	Record any    `json:"record"`
+}
+
+// telemetrySubscription is the subscription of an extension to the Telemetry API. Events are
+// buffered and sent to its destination in batches.
+type telemetrySubscription struct {
+	extension   string
+	types       map[string]bool
+	destination string
+	maxItems    int
+	maxBytes    int
+	timeout     time.Duration
+	mu          sync.Mutex
+	buffer      []telemetryEvent
+	size        int
+	// full is signaled when the buffer reaches maxItems or maxBytes.
+	full chan struct{}
+}
+
+// telemetryAPI emulates the Lambda Telemetry API. Extensions of observability vendors subscribe
+// to it to receive platform events, and every event is logged at debug level as well, so the
+// lifecycle of invocations can be followed locally without an extension.
+type telemetryAPI struct {
+	mu            sync.Mutex
+	subscriptions []*telemetrySubscription
+	extensions    *extensionsAPI
+	client        *http.Client
+	// done is closed on shutdown to stop delivering events.
+	done      chan struct{}
+	delivered sync.WaitGroup
+	logger    *slog.Logger
+}
+
+func newTelemetryAPI(extensions *extensionsAPI, logger *slog.Logger) *telemetryAPI {
+	return &telemetryAPI{
+		extensions: extensions,
+		client:     &http.Client{Timeout: telemetryPostTimeout},
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+}
+
+// handle registers the Telemetry API route on router.
+func (t *telemetryAPI) handle(router *http.ServeMux) {
+	router.HandleFunc("PUT "+telemetryAPIPath, t.handleSubscribe)
+}
+
+func (t *telemetryAPI) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	ext, ok := t.extensions.extension(r)
+	if !ok {
+		http.Error(w, "unknown extension identifier", http.StatusForbidden)
+
+		return
+	}
+
+	var body struct {
+		Types     []string `json:"types"`
+		Buffering struct {
+			MaxItems  int `json:"maxItems"`
+			MaxBytes  int `json:"maxBytes"`
+			TimeoutMs int `json:"timeoutMs"`
+		} `json:"buffering"`
+		Destination struct {
+			Protocol string `json:"protocol"`
+			URI      string `json:"URI"` //nolint:tagliatelle
+		} `json:"destination"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid subscription: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if body.Destination.Protocol != "HTTP" {
+		http.Error(
+			w,
+			fmt.Sprintf("destination protocol '%s' is not supported, use HTTP", body.Destination.Protocol),
+			http.StatusBadRequest,
+		)
+
+		return
+	}
+
+	destination, err := telemetryDestination(body.Destination.URI)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	subscription := &telemetrySubscription{
+		extension:   ext.name,
+		types:       make(map[string]bool, len(body.Types)),
+		destination: destination,
+		maxItems:    cmp.Or(body.Buffering.MaxItems, telemetryDefaultMaxItems),
+		maxBytes:    cmp.Or(body.Buffering.MaxBytes, telemetryDefaultMaxBytes),
+		timeout:     telemetryDefaultTimeout,
+		full:        make(chan struct{}, 1),
+	}
+
+	if body.Buffering.TimeoutMs > 0 {
+		subscription.timeout = time.Duration(body.Buffering.TimeoutMs) * time.Millisecond
+	}
+
+	for _, eventType := range body.Types {
+		switch eventType {
+		case telemetryTypePlatform, telemetryTypeFunction, telemetryTypeExtension:
+			subscription.types[eventType] = true
+		default:
+			http.Error(w, fmt.Sprintf("unknown telemetry type '%s'", eventType), http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	t.mu.Lock()
+	t.subscriptions = append(t.subscriptions, subscription)
+	t.mu.Unlock()
+
+	t.delivered.Add(1)
+
+	go t.deliver(subscription)
+
+	t.emit(
+		"platform.telemetrySubscription",
+		map[string]any{"name": ext.name, "state": "Subscribed", "types": body.Types},
+	)
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+// emit logs the event of eventType and buffers it for the subscriptions of its type.
+func (t *telemetryAPI) emit(eventType string, record any) {
+	event := telemetryEvent{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Type:   eventType,
+		Record: record,
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.logger.Error("[in lambdalocal.telemetryAPI.emit] marshal event failed", "err", err)
+
+		return
+	}
+
+	t.logger.Debug("Telemetry "+eventType, "record", string(data))
+
+	category, _, _ := strings.Cut(eventType, ".")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, subscription := range t.subscriptions {
+		if !subscription.types[category] {
+			continue
+		}
+
+		subscription.mu.Lock()
+		subscription.buffer = append(subscription.buffer, event)
+		subscription.size += len(data)
+		full := len(subscription.buffer) >= subscription.maxItems || subscription.size >= subscription.maxBytes
+		subscription.mu.Unlock()
+
+		if full {
+			select {
+			case subscription.full <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// deliver sends the buffered events of subscription every timeout, or sooner once the buffer is
+// full, until shutdown.
+func (t *telemetryAPI) deliver(subscription *telemetrySubscription) {
+	defer t.delivered.Done()
+
+	ticker := time.NewTicker(subscription.timeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-subscription.full:
+		case <-t.done:
+			t.flush(subscription)
+
+			return
+		}
+
+		t.flush(subscription)
+	}
+}
+
+// flush posts the buffered events of subscription to its destination.
+func (t *telemetryAPI) flush(subscription *telemetrySubscription) {
+	subscription.mu.Lock()
+	batch := subscription.buffer
+	subscription.buffer = nil
+	subscription.size = 0
+	subscription.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		t.logger.Error("[in lambdalocal.telemetryAPI.flush] marshal events failed", "err", err)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryPostTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.destination, bytes.NewReader(data))
+	if err != nil {
+		t.logger.Error("[in lambdalocal.telemetryAPI.flush] create request failed", "err", err)
+
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.logger.Warn(
+			fmt.Sprintf("Extension %s did not accept %d telemetry events", subscription.extension, len(batch)),
+			"err",
+			err,
+		)
+
+		return
+	}
+
+	_ = resp.Body.Close()
+}
+
+// shutdown delivers the events still buffered and stops delivering new ones.
+func (t *telemetryAPI) shutdown() {
+	close(t.done)
+	t.delivered.Wait()
+}
+
+// telemetryDestination returns the URI an extension receives telemetry on, with the sandbox host
+// of Lambda replaced by localhost.
+func telemetryDestination(uri string) (string, error) {
+	destination, err := url.Parse(uri)
+	if err != nil || destination.Host == "" {
+		return "", fmt.Errorf("invalid destination URI '%s'", uri)
+	}
+
+	if destination.Hostname() == telemetrySandboxHost {
+		destination.Host = strings.Replace(destination.Host, telemetrySandboxHost, "localhost", 1)
+	}
+
+	return destination.String(), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeTelemetry subscribes the extension with id to the Telemetry API and returns the status.
+func subscribeTelemetry(t *testing.T, address, id, body string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+address+telemetryAPIPath, strings.NewReader(body))
+	require.NoError(t, err)
+
+	req.Header.Set("Lambda-Extension-Identifier", id)
+
+	resp, err := runtimeClient.Do(req)
+	require.NoError(t, err)
+
+	_ = resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestTelemetryAPI(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		received []telemetryEvent
+	)
+
+	destination := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				var batch []telemetryEvent
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+
+				mu.Lock()
+				received = append(received, batch...)
+				mu.Unlock()
+			},
+		),
+	)
+	t.Cleanup(destination.Close)
+
+	runtimeAPI := NewRuntimeAPIClient("localhost:0", 5*time.Second, slog.Default())
+
+	stop, err := runtimeAPI.Start()
+	require.NoError(t, err)
+
+	address := runtimeAPI.address
+
+	id, status := registerExtension(t, address, `[]`)
+	require.Equal(t, http.StatusOK, status)
+
+	subscription := func(types, uri string) string {
+		return `{"schemaVersion":"2022-12-13","types":` + types +
+			`,"buffering":{"timeoutMs":25},"destination":{"protocol":"HTTP","URI":"` + uri + `"}}`
+	}
+
+	platform := subscription(`["platform"]`, destination.URL)
+	unknown := subscription(`["unknown"]`, destination.URL)
+
+	assert.Equal(t, http.StatusForbidden, subscribeTelemetry(t, address, "unknown", platform))
+	assert.Equal(t, http.StatusBadRequest, subscribeTelemetry(t, address, id, unknown))
+	assert.Equal(t, http.StatusBadRequest, subscribeTelemetry(t, address, id, subscription(`["platform"]`, "")))
+	require.Equal(t, http.StatusOK, subscribeTelemetry(t, address, id, platform))
+
+	go runtimeRoundTrip(t, address, "response", `{"statusCode":200}`)
+
+	_, err = runtimeAPI.Invoke(context.Background(), []byte(`{}`), WithRequestID("invoke-id"))
+	require.NoError(t, err)
+
+	go runtimeRoundTrip(t, address, "error", `{"errorMessage":"boom"}`)
+
+	_, err = runtimeAPI.Invoke(context.Background(), []byte(`{}`), WithRequestID("error-id"))
+	require.NoError(t, err)
+
+	// stopping delivers the events still buffered
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var types []string
+
+	for _, event := range received {
+		types = append(types, event.Type)
+		assert.NotEmpty(t, event.Time)
+	}
+
+	assert.Equal(
+		t,
+		[]string{
+			"platform.telemetrySubscription",
+			"platform.start",
+			"platform.runtimeDone",
+			"platform.report",
+			"platform.start",
+			"platform.runtimeDone",
+			"platform.report",
+		},
+		types,
+	)
+
+	report, ok := received[6].Record.(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "error-id", report["requestId"])
+	assert.Equal(t, "error", report["status"])
+}
+
+func TestTelemetryDestination(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uri            string
+		expected       string
+		expectedErrStr string
+	}{
+		"sandbox host": {
+			uri:      "http://sandbox.localdomain:4243/telemetry",
+			expected: "http://localhost:4243/telemetry",
+		},
+		"other host": {
+			uri:      "http://127.0.0.1:4243",
+			expected: "http://127.0.0.1:4243",
+		},
+		"no host": {
+			uri:            "telemetry",
+			expectedErrStr: "invalid destination URI 'telemetry'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				destination, err := telemetryDestination(tc.uri)
+
+				if tc.expectedErrStr != "" {
+					assert.EqualError(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, destination)
+			},
+		)
+	}
+}
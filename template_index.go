@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateIndex is a template decoded for the SAM and the plain CloudFormation resources read by
+// parseTemplate. It is shared by the readers of the cache and must not be modified.
+type templateIndex struct {
+	sam samTemplate
+	cfn cfnTemplate
+}
+
+// templateCache caches the index of each template by path and parameter overrides. An entry is
+// reused while the content hash of the template is unchanged, so parsing a large template again,
+// or a parent template whose nested stacks didn't change, only decodes the templates that changed.
+type templateCache struct {
+	mu      sync.Mutex
+	entries map[string]templateCacheEntry
+}
+
+type templateCacheEntry struct {
+	hash  [sha256.Size]byte
+	index *templateIndex
+}
+
+// templates is the cache of the templates parsed by the process.
+var templates = newTemplateCache() //nolint:gochecknoglobals
+
+func newTemplateCache() *templateCache {
+	return &templateCache{entries: make(map[string]templateCacheEntry)}
+}
+
+// index returns the index of the template at path with content data, decoding it when it isn't
+// cached or its content changed.
+func (c *templateCache) index(path string, data []byte, overrides map[string]string) (*templateIndex, error) {
+	key := path
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		key += "\x00" + name + "=" + overrides[name]
+	}
+
+	hash := sha256.Sum256(data)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && entry.hash == hash {
+		return entry.index, nil
+	}
+
+	index, err := indexTemplate(data, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = templateCacheEntry{hash: hash, index: index}
+	c.mu.Unlock()
+
+	return index, nil
+}
+
+// indexTemplate decodes a template like unmarshalTemplate. Its resources are decoded in parallel,
+// which keeps templates with thousands of resources fast to parse.
+func indexTemplate(data []byte, overrides map[string]string) (*templateIndex, error) {
+	index := &templateIndex{}
+
+	root, err := resolveTemplate(data, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	if root == nil {
+		if err = yaml.Unmarshal(data, &index.sam); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return index, yaml.Unmarshal(data, &index.cfn) //nolint:wrapcheck
+	}
+
+	// the sections other than Resources are decoded as they are, the resources one by one
+	sections := *root
+	sections.Content = nil
+
+	var resources *yaml.Node
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "Resources" && root.Content[i+1].Kind == yaml.MappingNode {
+			resources = root.Content[i+1]
+
+			continue
+		}
+
+		sections.Content = append(sections.Content, root.Content[i], root.Content[i+1])
+	}
+
+	if err = sections.Decode(&index.sam); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if err = sections.Decode(&index.cfn); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if resources == nil {
+		return index, nil
+	}
+
+	if err = decodeResources(resources, &index.sam.Resources); err != nil {
+		return nil, err
+	}
+
+	if err = decodeResources(resources, &index.cfn.Resources); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// decodeResources decodes the resources of a Resources mapping into out in parallel.
+func decodeResources[R any](resources *yaml.Node, out *map[string]R) error {
+	count := len(resources.Content) / 2 //nolint:mnd
+
+	decoded := make([]R, count)
+	errs := make([]error, count)
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range min(runtime.GOMAXPROCS(0), count) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				errs[i] = resources.Content[2*i+1].Decode(&decoded[i])
+			}
+		}()
+	}
+
+	for i := range count {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	*out = make(map[string]R, count)
+
+	for i := range count {
+		if errs[i] != nil {
+			return fmt.Errorf("resource %s: %w", resources.Content[2*i].Value, errs[i])
+		}
+
+		(*out)[resources.Content[2*i].Value] = decoded[i]
+	}
+
+	return nil
+}
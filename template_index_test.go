@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template string
+	}{
+		"sam and cloudformation resources": {
+			template: `
+Parameters:
+  Stage:
+    Type: String
+    Default: dev
+Conditions:
+  IsProd: !Equals [!Ref Stage, prod]
+Globals:
+  Api:
+    BinaryMediaTypes: [image~1png]
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      Events:
+        Api:
+          Type: HttpApi
+          Properties:
+            Path: !Sub /${Stage}/hello
+            Method: get
+  ProdOnly:
+    Type: AWS::Serverless::Function
+    Condition: IsProd
+  Route:
+    Type: AWS::ApiGatewayV2::Route
+    Properties:
+      ApiId: !Ref HttpApi
+      RouteKey: GET /orders
+`,
+		},
+		"no resources": {
+			template: "Globals:\n  Api:\n    BinaryMediaTypes: [image~1png]\n",
+		},
+		"empty": {
+			template: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				var (
+					sam samTemplate
+					cfn cfnTemplate
+				)
+
+				require.NoError(t, unmarshalTemplate([]byte(tc.template), nil, &sam))
+				require.NoError(t, unmarshalTemplate([]byte(tc.template), nil, &cfn))
+
+				index, err := indexTemplate([]byte(tc.template), nil)
+				require.NoError(t, err)
+
+				assert.Equal(t, sam, index.sam)
+				assert.Equal(t, cfn, index.cfn)
+			},
+		)
+	}
+}
+
+func TestIndexTemplateManyResources(t *testing.T) {
+	t.Parallel()
+
+	var template strings.Builder
+
+	template.WriteString("Resources:\n")
+
+	for i := range 500 {
+		_, _ = fmt.Fprintf(
+			&template,
+			"  Fn%d:\n    Type: AWS::Serverless::Function\n    Properties:\n      Events:\n        Api:\n"+
+				"          Type: HttpApi\n          Properties:\n            Path: /fn%d\n",
+			i,
+			i,
+		)
+	}
+
+	var sam samTemplate
+	require.NoError(t, unmarshalTemplate([]byte(template.String()), nil, &sam))
+
+	index, err := indexTemplate([]byte(template.String()), nil)
+	require.NoError(t, err)
+
+	assert.Len(t, index.sam.Resources, 500)
+	assert.Equal(t, sam, index.sam)
+}
+
+func TestIndexTemplateInvalidResource(t *testing.T) {
+	t.Parallel()
+
+	_, err := indexTemplate([]byte("Resources:\n  Fn:\n    Type: [not, a, string]\n"), nil)
+	assert.ErrorContains(t, err, "resource Fn")
+}
+
+func TestTemplateCache(t *testing.T) {
+	t.Parallel()
+
+	cache := newTemplateCache()
+	template := []byte("Parameters:\n  Stage:\n    Default: dev\nResources:\n  Fn:\n    Type: !Ref Stage\n")
+
+	first, err := cache.index("template.yaml", template, nil)
+	require.NoError(t, err)
+
+	cached, err := cache.index("template.yaml", template, nil)
+	require.NoError(t, err)
+	assert.Same(t, first, cached)
+
+	overridden, err := cache.index("template.yaml", template, map[string]string{"Stage": "prod"})
+	require.NoError(t, err)
+	assert.NotSame(t, first, overridden)
+	assert.Equal(t, "prod", overridden.sam.Resources["Fn"].Type)
+
+	changed, err := cache.index("template.yaml", append(template, "  Other:\n    Type: Other\n"...), nil)
+	require.NoError(t, err)
+	assert.NotSame(t, first, changed)
+	assert.Len(t, changed.sam.Resources, 2)
+
+	// the index of the unchanged template is replaced by the changed one
+	again, err := cache.index("template.yaml", template, nil)
+	require.NoError(t, err)
+	assert.NotSame(t, first, again)
+	assert.Equal(t, first, again)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	terraformFunctionType    = "aws_lambda_function"
+	terraformHTTPAPIType     = "aws_apigatewayv2_api"
+	terraformRouteType       = "aws_apigatewayv2_route"
+	terraformIntegrationType = "aws_apigatewayv2_integration"
+
+	// terraformPayloadFormat is the payload_format_version Terraform defaults to.
+	terraformPayloadFormat = payloadFormatV1
+)
+
+// terraformJSON is the output of `terraform show -json`, for a plan file or for the state.
+type terraformJSON struct {
+	FormatVersion    string           `json:"format_version"`    //nolint:tagliatelle
+	TerraformVersion string           `json:"terraform_version"` //nolint:tagliatelle
+	Values           *terraformValues `json:"values"`
+	PlannedValues    *terraformValues `json:"planned_values"` //nolint:tagliatelle
+	Configuration    struct {
+		RootModule terraformConfigModule `json:"root_module"` //nolint:tagliatelle
+	} `json:"configuration"`
+}
+
+type terraformValues struct {
+	RootModule terraformModule `json:"root_module"` //nolint:tagliatelle
+}
+
+type terraformModule struct {
+	Resources    []terraformResource `json:"resources"`
+	ChildModules []terraformModule   `json:"child_modules"` //nolint:tagliatelle
+}
+
+type terraformResource struct {
+	Address string         `json:"address"`
+	Type    string         `json:"type"`
+	Name    string         `json:"name"`
+	Values  map[string]any `json:"values"`
+}
+
+// terraformConfigModule holds the configuration of a module, which references other resources
+// where a plan doesn't know the values yet.
+type terraformConfigModule struct {
+	Resources []struct {
+		Address     string `json:"address"`
+		Expressions map[string]struct {
+			References []string `json:"references"`
+		} `json:"expressions"`
+	} `json:"resources"`
+}
+
+// isTerraformJSON reports if data is the JSON output of `terraform show -json`.
+func isTerraformJSON(data []byte) bool {
+	var tf terraformJSON
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return false
+	}
+
+	return tf.FormatVersion != "" && tf.TerraformVersion != ""
+}
+
+// terraformRoutes returns the routes of the aws_apigatewayv2_route resources of a Terraform plan or
+// state with a lambda proxy integration. Functions are named after their aws_lambda_function
+// resource, like the logical IDs of a SAM template.
+func terraformRoutes(data []byte) ([]apiRoute, error) {
+	var tf terraformJSON
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("[in lambdalocal.terraformRoutes] unmarshal json failed: %w", err)
+	}
+
+	values := tf.Values
+	if values == nil {
+		values = tf.PlannedValues
+	}
+
+	if values == nil {
+		return nil, nil
+	}
+
+	t := terraformResources{references: make(map[string]map[string][]string)}
+	t.add(values.RootModule)
+
+	// a plan doesn't know computed values like IDs and ARNs, the configuration references them
+	for _, resource := range tf.Configuration.RootModule.Resources {
+		t.references[resource.Address] = make(map[string][]string)
+		for name, expression := range resource.Expressions {
+			t.references[resource.Address][name] = expression.References
+		}
+	}
+
+	var routes []apiRoute
+
+	for _, resource := range t.resources {
+		switch resource.Type {
+		case terraformHTTPAPIType:
+			// quick create APIs send every request to their target
+			function := t.function(resource, "target")
+			if function == "" || !strings.EqualFold(stringValue(resource.Values["protocol_type"]), "HTTP") {
+				continue
+			}
+
+			route := httpAPIRoute(defaultRouteKey, "", terraformPayloadFormat)
+			route.function = function
+
+			routes = append(routes, route)
+		case terraformRouteType:
+			integration, ok := t.integration(resource)
+			if !ok || !strings.EqualFold(stringValue(integration.Values["integration_type"]), cfnProxyIntegration) {
+				continue
+			}
+
+			function := t.function(integration, "integration_uri")
+			if function == "" {
+				continue
+			}
+
+			payloadFormat := stringValue(integration.Values["payload_format_version"])
+			if payloadFormat == "" {
+				payloadFormat = terraformPayloadFormat
+			}
+
+			method, path, _ := strings.Cut(stringValue(resource.Values["route_key"]), " ")
+			if path == "" {
+				// $default has no method
+				method, path = "", method
+			}
+
+			route := httpAPIRoute(path, method, payloadFormat)
+			route.function = function
+
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// terraformResources indexes the resources of a plan or state.
+type terraformResources struct {
+	resources []terraformResource
+	// references holds the references of the configuration expressions, by resource address.
+	references map[string]map[string][]string
+}
+
+func (t *terraformResources) add(module terraformModule) {
+	t.resources = append(t.resources, module.Resources...)
+
+	for _, child := range module.ChildModules {
+		t.add(child)
+	}
+}
+
+// integration returns the integration a route targets, by the ID in its target or, in a plan, by
+// the integration its target references.
+func (t *terraformResources) integration(route terraformResource) (terraformResource, bool) {
+	id, hasID := strings.CutPrefix(stringValue(route.Values["target"]), cfnIntegrationTarget)
+
+	for _, resource := range t.resources {
+		if resource.Type != terraformIntegrationType {
+			continue
+		}
+
+		if hasID && stringValue(resource.Values["id"]) == id {
+			return resource, true
+		}
+
+		if !hasID && t.referenced(route, "target", resource.Address) {
+			return resource, true
+		}
+	}
+
+	return terraformResource{}, false
+}
+
+// function returns the name of the aws_lambda_function resource the attribute of resource invokes,
+// by its ARN, invoke ARN or function name, or in a plan by the function it references.
+func (t *terraformResources) function(resource terraformResource, attribute string) string {
+	target := stringValue(resource.Values[attribute])
+
+	for _, function := range t.resources {
+		if function.Type != terraformFunctionType {
+			continue
+		}
+
+		if target == "" {
+			if t.referenced(resource, attribute, function.Address) {
+				return function.Name
+			}
+
+			continue
+		}
+
+		for _, key := range []string{"arn", "invoke_arn", "qualified_arn", "qualified_invoke_arn"} {
+			if arn := stringValue(function.Values[key]); arn != "" && arn == target {
+				return function.Name
+			}
+		}
+
+		// integration uris embed the function ARN, which ends with :function:NAME
+		name := stringValue(function.Values["function_name"])
+		if name == "" {
+			continue
+		}
+
+		if strings.Contains(target, ":function:"+name+"/") || strings.HasSuffix(target, ":function:"+name) {
+			return function.Name
+		}
+	}
+
+	return ""
+}
+
+// referenced reports if the attribute of resource references address, or an attribute of it.
+func (t *terraformResources) referenced(resource terraformResource, attribute, address string) bool {
+	for _, reference := range t.references[resource.Address][attribute] {
+		if reference == address || strings.HasPrefix(reference, address+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringValue returns v when it is a string, and "" otherwise.
+func stringValue(v any) string {
+	s, _ := v.(string)
+
+	return s
+}
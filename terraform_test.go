@@ -0,0 +1,215 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:lll // invoke ARNs don't fit the line length limit
+const terraformState = `{
+  "format_version": "1.0",
+  "terraform_version": "1.9.5",
+  "values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_lambda_function.hello",
+          "type": "aws_lambda_function",
+          "name": "hello",
+          "values": {
+            "function_name": "hello-dev",
+            "arn": "arn:aws:lambda:us-east-1:123456789012:function:hello-dev",
+            "invoke_arn": "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:hello-dev/invocations"
+          }
+        },
+        {
+          "address": "aws_apigatewayv2_integration.hello",
+          "type": "aws_apigatewayv2_integration",
+          "name": "hello",
+          "values": {
+            "id": "abc123",
+            "integration_type": "AWS_PROXY",
+            "integration_uri": "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:hello-dev/invocations",
+            "payload_format_version": "2.0"
+          }
+        },
+        {
+          "address": "aws_apigatewayv2_route.hello",
+          "type": "aws_apigatewayv2_route",
+          "name": "hello",
+          "values": {"route_key": "GET /hello/{name}", "target": "integrations/abc123"}
+        },
+        {
+          "address": "aws_apigatewayv2_integration.http",
+          "type": "aws_apigatewayv2_integration",
+          "name": "http",
+          "values": {"id": "def456", "integration_type": "HTTP_PROXY", "integration_uri": "https://example.com"}
+        },
+        {
+          "address": "aws_apigatewayv2_route.http",
+          "type": "aws_apigatewayv2_route",
+          "name": "http",
+          "values": {"route_key": "GET /proxy", "target": "integrations/def456"}
+        }
+      ],
+      "child_modules": [
+        {
+          "resources": [
+            {
+              "address": "module.orders.aws_lambda_function.orders",
+              "type": "aws_lambda_function",
+              "name": "orders",
+              "values": {"function_name": "orders", "arn": "arn:aws:lambda:us-east-1:123456789012:function:orders"}
+            },
+            {
+              "address": "module.orders.aws_apigatewayv2_integration.orders",
+              "type": "aws_apigatewayv2_integration",
+              "name": "orders",
+              "values": {
+                "id": "ghi789",
+                "integration_type": "AWS_PROXY",
+                "integration_uri": "arn:aws:lambda:us-east-1:123456789012:function:orders"
+              }
+            },
+            {
+              "address": "module.orders.aws_apigatewayv2_route.default",
+              "type": "aws_apigatewayv2_route",
+              "name": "default",
+              "values": {"route_key": "$default", "target": "integrations/ghi789"}
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+const terraformPlan = `{
+  "format_version": "1.2",
+  "terraform_version": "1.9.5",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_lambda_function.hello",
+          "type": "aws_lambda_function",
+          "name": "hello",
+          "values": {"function_name": "hello-dev"}
+        },
+        {
+          "address": "aws_apigatewayv2_integration.hello",
+          "type": "aws_apigatewayv2_integration",
+          "name": "hello",
+          "values": {"integration_type": "AWS_PROXY"}
+        },
+        {
+          "address": "aws_apigatewayv2_route.hello",
+          "type": "aws_apigatewayv2_route",
+          "name": "hello",
+          "values": {"route_key": "POST /hello"}
+        },
+        {
+          "address": "aws_apigatewayv2_api.quick",
+          "type": "aws_apigatewayv2_api",
+          "name": "quick",
+          "values": {"protocol_type": "HTTP"}
+        }
+      ]
+    }
+  },
+  "configuration": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_apigatewayv2_integration.hello",
+          "expressions": {
+            "integration_uri": {"references": ["aws_lambda_function.hello.invoke_arn", "aws_lambda_function.hello"]}
+          }
+        },
+        {
+          "address": "aws_apigatewayv2_route.hello",
+          "expressions": {
+            "target": {"references": ["aws_apigatewayv2_integration.hello.id", "aws_apigatewayv2_integration.hello"]}
+          }
+        },
+        {
+          "address": "aws_apigatewayv2_api.quick",
+          "expressions": {
+            "target": {"references": ["aws_lambda_function.hello.arn", "aws_lambda_function.hello"]}
+          }
+        }
+      ]
+    }
+  }
+}`
+
+func TestTerraformRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data           string
+		expectedRoutes []apiRoute
+		expectedErrStr string
+	}{
+		"state": {
+			data: terraformState,
+			expectedRoutes: []apiRoute{
+				{method: "GET", path: "/hello/{name}", function: "hello", payloadFormat: payloadFormatV2},
+				{method: "", path: "/{proxy+}", function: "orders", payloadFormat: payloadFormatV1},
+			},
+		},
+		"plan": {
+			data: terraformPlan,
+			expectedRoutes: []apiRoute{
+				{method: "POST", path: "/hello", function: "hello", payloadFormat: payloadFormatV1},
+				{method: "", path: "/{proxy+}", function: "hello", payloadFormat: payloadFormatV1},
+			},
+		},
+		"no values": {
+			data:           `{"format_version": "1.0", "terraform_version": "1.9.5"}`,
+			expectedRoutes: nil,
+		},
+		"invalid json": {
+			data:           `{`,
+			expectedErrStr: "[in lambdalocal.terraformRoutes] unmarshal json failed:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				routes, err := terraformRoutes([]byte(tc.data))
+
+				if tc.expectedErrStr != "" {
+					assert.ErrorContains(t, err, tc.expectedErrStr)
+
+					return
+				}
+
+				require.NoError(t, err)
+
+				sortRoutes(routes)
+				assert.Equal(t, tc.expectedRoutes, routes)
+			},
+		)
+	}
+}
+
+func TestParseTemplateTerraform(t *testing.T) {
+	t.Parallel()
+
+	mockReader := new(mockOSFileReader)
+	mockReader.On("read", "plan.json").Return([]byte(terraformState), nil).Once()
+
+	routes, err := parseTemplate("plan.json", mockReader, nil)
+
+	require.NoError(t, err)
+	assert.Len(t, routes, 2)
+	assert.True(t, isTerraformJSON([]byte(terraformPlan)))
+	assert.False(t, isTerraformJSON([]byte(`{"Resources": {}}`)))
+	assert.False(t, isTerraformJSON([]byte("Resources: {}")))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"time"
+)
+
+// selfSignedValidity is how long the certificates of --tls-self-signed are valid.
+const selfSignedValidity = 30 * 24 * time.Hour
+
+// tlsConfig is the certificate the api is served with over https. It is served over http without
+// one.
+type tlsConfig struct {
+	certFile string
+	keyFile  string
+	// selfSigned serves the api with a certificate generated on startup.
+	selfSigned bool
+}
+
+func (c tlsConfig) enabled() bool {
+	return c.selfSigned || c.certFile != ""
+}
+
+// validate checks that the certificate is given by one of its flags, with both of its files.
+func (c tlsConfig) validate() []string {
+	var problems []string
+
+	switch {
+	case c.selfSigned && (c.certFile != "" || c.keyFile != ""):
+		problems = append(problems, "--tls-self-signed can't be combined with --tls-cert and --tls-key")
+	case c.certFile != "" && c.keyFile == "":
+		problems = append(problems, "--tls-cert requires --tls-key, the private key of the certificate")
+	case c.keyFile != "" && c.certFile == "":
+		problems = append(problems, "--tls-key requires --tls-cert, the certificate of the key")
+	}
+
+	for _, file := range []string{c.certFile, c.keyFile} {
+		if file == "" {
+			continue
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("TLS file '%s' can't be read: %s", file, err))
+		}
+	}
+
+	return problems
+}
+
+// certificate returns the certificate of c: the one of certFile and keyFile, or a self-signed one
+// for localhost and host.
+func (c tlsConfig) certificate(host string) (tls.Certificate, error) {
+	if c.selfSigned {
+		return selfSignedCertificate(certificateHosts(host), time.Now())
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("[in lambdalocal.tlsConfig.certificate] load key pair failed: %w", err)
+	}
+
+	return cert, nil
+}
+
+// certificateHosts returns the hosts a self-signed certificate is issued for: localhost, and host
+// when it is another host the api is served on.
+func certificateHosts(host string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	if ip := net.ParseIP(host); (ip == nil || !ip.IsUnspecified()) && !slices.Contains(hosts, host) {
+		hosts = append(hosts, host)
+	}
+
+	return hosts
+}
+
+// selfSignedCertificate generates a throwaway certificate for hosts, host names or IP addresses,
+// valid from now on for selfSignedValidity.
+func selfSignedCertificate(hosts []string, now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("[in lambdalocal.selfSignedCertificate] generate key failed: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128)) //nolint:mnd
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("[in lambdalocal.selfSignedCertificate] generate serial failed: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"lambdalocal"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf(
+			"[in lambdalocal.selfSignedCertificate] create certificate failed: %w",
+			err,
+		)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cert, err := selfSignedCertificate(certificateHosts("192.168.1.20"), now)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	// clients trusting the certificate can verify it for every host
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "192.168.1.20"} {
+		_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: now})
+		require.NoError(t, err, host)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, CurrentTime: now})
+	require.Error(t, err)
+
+	assert.WithinDuration(t, now.Add(selfSignedValidity), leaf.NotAfter, time.Second)
+}
+
+func TestCertificateHosts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		host     string
+		expected []string
+	}{
+		"localhost":    {host: "localhost", expected: []string{"localhost", "127.0.0.1", "::1"}},
+		"all IPv4":     {host: "0.0.0.0", expected: []string{"localhost", "127.0.0.1", "::1"}},
+		"all IPv6":     {host: "::", expected: []string{"localhost", "127.0.0.1", "::1"}},
+		"host name":    {host: "dev.local", expected: []string{"localhost", "127.0.0.1", "::1", "dev.local"}},
+		"LAN address":  {host: "10.0.0.5", expected: []string{"localhost", "127.0.0.1", "::1", "10.0.0.5"}},
+		"IPv6 address": {host: "::1", expected: []string{"localhost", "127.0.0.1", "::1"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, certificateHosts(tc.host))
+			},
+		)
+	}
+}
+
+func TestTLSConfigCertificate(t *testing.T) {
+	t.Parallel()
+
+	generated, err := selfSignedCertificate([]string{"localhost"}, time.Now())
+	require.NoError(t, err)
+
+	key, err := x509.MarshalPKCS8PrivateKey(generated.PrivateKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	require.NoError(
+		t,
+		os.WriteFile(
+			certFile,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: generated.Certificate[0]}),
+			0o600,
+		),
+	)
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	config := tlsConfig{certFile: certFile, keyFile: keyFile}
+	assert.True(t, config.enabled())
+	assert.Empty(t, config.validate())
+
+	cert, err := config.certificate("localhost")
+	require.NoError(t, err)
+	assert.Equal(t, generated.Certificate, cert.Certificate)
+
+	_, err = tlsConfig{certFile: keyFile, keyFile: certFile}.certificate("localhost")
+	require.Error(t, err)
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config   tlsConfig
+		expected []string
+	}{
+		"disabled": {},
+		"self-signed": {
+			config: tlsConfig{selfSigned: true},
+		},
+		"self-signed and files": {
+			config:   tlsConfig{selfSigned: true, certFile: "tls_test.go", keyFile: "tls.go"},
+			expected: []string{"--tls-self-signed can't be combined with --tls-cert and --tls-key"},
+		},
+		"cert without key": {
+			config:   tlsConfig{certFile: "tls_test.go"},
+			expected: []string{"--tls-cert requires --tls-key, the private key of the certificate"},
+		},
+		"key without cert": {
+			config:   tlsConfig{keyFile: "tls_test.go"},
+			expected: []string{"--tls-key requires --tls-cert, the certificate of the key"},
+		},
+		"missing file": {
+			config: tlsConfig{certFile: "tls_test.go", keyFile: "missing.pem"},
+			expected: []string{
+				"TLS file 'missing.pem' can't be read: stat missing.pem: no such file or directory",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, tc.config.validate())
+			},
+		)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// warmupHeader marks the synthetic requests sent by --warmup.
+const warmupHeader = "X-Lambdalocal-Warmup"
+
+// warmupTarget is a route warmed up on startup with the caller of its function.
+type warmupTarget struct {
+	route  apiRoute
+	caller lambdaCaller
+}
+
+// warmupRoutes invokes every route once, concurrently, so handlers are initialized before the first
+// real request. Functions with a warmup event are invoked with it once, other GET, HEAD and any
+// method routes get a synthetic request through handler. Routes of other methods are skipped.
+func warmupRoutes(
+	ctx context.Context,
+	handler http.Handler,
+	targets []warmupTarget,
+	events map[string]string,
+	logger *slog.Logger,
+) {
+	var (
+		wg     sync.WaitGroup
+		warmed = make(map[string]bool)
+	)
+
+	for _, target := range targets {
+		route := target.route
+
+		if event, ok := events[route.function]; ok {
+			if warmed[route.function] {
+				continue
+			}
+
+			warmed[route.function] = true
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				start := time.Now()
+
+				response, err := target.caller.Invoke(ctx, []byte(event))
+				if err == nil && response.Error != nil {
+					err = errors.New(response.Error.Message)
+				}
+
+				if err != nil {
+					logger.Warn(fmt.Sprintf("warmup of %s failed", route.function), "err", err)
+
+					return
+				}
+
+				logger.Info(
+					fmt.Sprintf(
+						"warmup %s with its warmup event in %s",
+						route.function,
+						time.Since(start).Round(time.Millisecond),
+					),
+				)
+			}()
+
+			continue
+		}
+
+		method := route.method
+
+		switch method {
+		case "":
+			method = http.MethodGet
+		case http.MethodGet, http.MethodHead:
+		default:
+			logger.Info(fmt.Sprintf("warmup %s skipped, set a warmupEvent for %s", route.routeKey(), route.function))
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			r := httptest.NewRequest(method, warmupPath(route.path), nil).WithContext(ctx)
+			r.Header.Set(warmupHeader, "true")
+
+			start := time.Now()
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+
+			logger.Info(
+				fmt.Sprintf(
+					"warmup %s %d in %s",
+					route.routeKey(),
+					recorder.Code,
+					time.Since(start).Round(time.Millisecond),
+				),
+			)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// warmupPath fills the path parameters of a route with "warmup".
+func warmupPath(path string) string {
+	return collectionParamRegex.ReplaceAllString(path, "warmup")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWarmupRoutes(t *testing.T) {
+	t.Parallel()
+
+	response := messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}
+
+	helloLambda := new(MockLambdaCaller)
+	helloLambda.On("Invoke", mock.Anything).Return(response, nil)
+
+	orderLambda := new(MockLambdaCaller)
+	orderLambda.On("Invoke", []byte(`{"warmup":true}`)).Return(response, nil).Once()
+
+	targets := []warmupTarget{
+		{route: apiRoute{method: "GET", path: "/hello/{name}", function: "HelloFn"}, caller: helloLambda},
+		{route: apiRoute{method: "POST", path: "/hello", function: "HelloFn"}, caller: helloLambda},
+		{route: apiRoute{method: "GET", path: "/orders", function: "OrderFn"}, caller: orderLambda},
+		{route: apiRoute{method: "POST", path: "/orders", function: "OrderFn"}, caller: orderLambda},
+	}
+
+	router := http.NewServeMux()
+	for _, target := range targets {
+		router.Handle(
+			target.route.muxPattern(),
+			gatewayHandler(target.caller, responseFormat{}, target.route, nil, slog.Default()),
+		)
+	}
+
+	warmupRoutes(context.Background(), router, targets, map[string]string{"OrderFn": `{"warmup":true}`}, slog.Default())
+
+	// the GET route is requested, the POST route is skipped
+	helloLambda.AssertNumberOfCalls(t, "Invoke", 1)
+	// functions with a warmup event are invoked once with it, whatever their routes
+	orderLambda.AssertExpectations(t)
+}
+
+func TestWarmupPath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/users/warmup/files/warmup", warmupPath("/users/{id}/files/{proxy+}"))
+	assert.Equal(t, "/hello", warmupPath("/hello"))
+}
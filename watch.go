@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounce = 300 * time.Millisecond
+
+// errBuildFailed is returned by restart when the build command fails, before the running process
+// is stopped.
+var errBuildFailed = errors.New("build failed")
+
+// lambdaWatcher rebuilds and restarts the managed lambda process when its sources change, and
+// before cold started invocations. It wraps a lambdaCaller so in-flight invocations are drained
+// before the process is swapped.
+type lambdaWatcher struct {
+	caller lambdaCaller
+	// dir is the root of the watched source tree.
+	dir string
+	// patterns are matched against file base names to decide if a change triggers a rebuild.
+	patterns []string
+	// build is an optional shell command run before the process is restarted.
+	build string
+	// run is the shell command that starts the lambda.
+	run      string
+	address  string
+	protocol string
+	// env holds the variables of the function in the template, set on the process.
+	env []string
+	// coldStart selects the invocations the process is restarted for.
+	coldStart coldStartPolicy
+	logger    *slog.Logger
+	// mu is held for reading by invocations and for writing while the process is swapped.
+	mu   sync.RWMutex
+	stop func()
+	// initDuration is how long the running process took to start, until the first invocation it
+	// serves claims it for its report.
+	initDuration atomic.Int64
+}
+
+func newLambdaWatcher(
+	caller lambdaCaller,
+	dir string,
+	patterns []string,
+	build, run, address, protocol string,
+	logger *slog.Logger,
+) *lambdaWatcher {
+	return &lambdaWatcher{
+		caller:   caller,
+		dir:      dir,
+		patterns: patterns,
+		build:    build,
+		run:      run,
+		address:  address,
+		protocol: protocol,
+		logger:   logger,
+		stop:     func() {},
+	}
+}
+
+// Invoke invokes the lambda, waiting for any restart in progress to complete. Cold started
+// invocations restart the process first. The first invocation served by a process reports how long
+// it took to start.
+func (l *lambdaWatcher) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	if l.coldStart.selects() {
+		// the process outlives the request that started it
+		initDuration, err := l.swap(context.WithoutCancel(ctx))
+		if err != nil {
+			return messages.InvokeResponse{}, fmt.Errorf(
+				"[in lambdalocal.lambdaWatcher.Invoke] cold start failed: %w",
+				err,
+			)
+		}
+
+		l.logger.Info("Cold start", "initDuration", initDuration)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// claimed under the lock of the invocation, so it is reported by an invocation of the process
+	// it belongs to, even when other invocations or swaps ran since the cold start
+	if initDuration := time.Duration(l.initDuration.Swap(0)); initDuration > 0 {
+		options = append(slices.Clip(options), WithInitDuration(initDuration))
+	}
+
+	return l.caller.Invoke(ctx, data, options...) //nolint:wrapcheck
+}
+
+// Start builds and starts the lambda.
+func (l *lambdaWatcher) Start(ctx context.Context) error {
+	if l.run == "" {
+		return errors.New("[in lambdalocal.lambdaWatcher.Start] --watch requires --run")
+	}
+
+	if err := l.restart(ctx); err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaWatcher.Start] initial start failed: %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops the lambda once in-flight invocations have completed.
+func (l *lambdaWatcher) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stop()
+	l.stop = func() {}
+}
+
+// Watch restarts the lambda on every change under dir until ctx is done.
+func (l *lambdaWatcher) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaWatcher.Watch] create watcher failed: %w", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	if err = l.addDirs(watcher, l.dir); err != nil {
+		return fmt.Errorf("[in lambdalocal.lambdaWatcher.Watch] watch '%s' failed: %w", l.dir, err)
+	}
+
+	l.logger.Info("Watching for changes in " + l.dir)
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			l.handleEvent(watcher, event, debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			l.logger.Error("[in lambdalocal.lambdaWatcher.Watch] watcher error", "err", err)
+		case <-debounce.C:
+			// the new process listens on the address of the old one, so it is only started once the
+			// old one stopped and nothing serves invocations when it fails to start
+			switch err = l.restart(ctx); {
+			case errors.Is(err, errBuildFailed):
+				l.logger.Error("Build failed, keeping previous lambda", "err", err)
+			case err != nil:
+				l.logger.Error("Restart failed, lambda is down until the next change", "err", err)
+			}
+		}
+	}
+}
+
+func (l *lambdaWatcher) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event, debounce *time.Timer) {
+	// new directories need to be watched as fsnotify is not recursive
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			_ = l.addDirs(watcher, event.Name)
+
+			return
+		}
+	}
+
+	if !l.matches(event.Name) {
+		return
+	}
+
+	l.logger.Debug("Source changed", "file", event.Name, "op", event.Op.String())
+
+	debounce.Reset(watchDebounce)
+}
+
+// restart builds the lambda and swaps the running process for a new one. A failed build leaves
+// the current process running.
+func (l *lambdaWatcher) restart(ctx context.Context) error {
+	if l.build != "" {
+		l.logger.Info("Building lambda: " + l.build)
+
+		output, err := shellCommand(l.build).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %w\n%s", errBuildFailed, err, output)
+		}
+	}
+
+	if _, err := l.swap(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// swap stops the running process and starts a new one, once in-flight invocations completed. It
+// returns how long the new process took to start.
+func (l *lambdaWatcher) swap(ctx context.Context) (time.Duration, error) {
+	// wait for in-flight invocations and block new ones while the process is swapped
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stop()
+	l.stop = func() {}
+	l.initDuration.Store(0)
+
+	start := time.Now()
+
+	stop, err := startManagedLambda(ctx, l.run, l.address, l.protocol, l.env, l.logger)
+	if err != nil {
+		return 0, fmt.Errorf("start failed: %w", err)
+	}
+
+	l.stop = stop
+
+	initDuration := time.Since(start)
+	l.initDuration.Store(int64(initDuration))
+
+	return initDuration, nil
+}
+
+func (l *lambdaWatcher) matches(name string) bool {
+	base := filepath.Base(name)
+
+	for _, pattern := range l.patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addDirs watches root and all directories below it, skipping hidden and vendored directories.
+func (l *lambdaWatcher) addDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error { //nolint:wrapcheck
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		name := d.Name()
+		if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path) //nolint:wrapcheck
+	})
+}
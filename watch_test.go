@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLambdaWatcherMatches(t *testing.T) {
+	t.Parallel()
+
+	watcher := newLambdaWatcher(nil, ".", []string{"*.go", "go.mod"}, "", "", "", ProtocolRPC, slog.Default())
+
+	tests := map[string]struct {
+		name     string
+		expected bool
+	}{
+		"go source":     {name: "cmd/fn/main.go", expected: true},
+		"go module":     {name: "go.mod", expected: true},
+		"build output":  {name: "bin/fn", expected: false},
+		"editor backup": {name: "main.go~", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expected, watcher.matches(tc.name))
+			},
+		)
+	}
+}
+
+func TestLambdaWatcherInvoke(t *testing.T) {
+	t.Parallel()
+
+	mockLambdaRPC := new(MockLambdaCaller)
+	mockLambdaRPC.
+		On("Invoke", mock.Anything).
+		Return(messages.InvokeResponse{Payload: []byte(`{}`)}, nil).
+		Once()
+
+	watcher := newLambdaWatcher(mockLambdaRPC, ".", nil, "", "", "", ProtocolRPC, slog.Default())
+
+	response, err := watcher.Invoke(context.Background(), []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), response.Payload)
+
+	require.ErrorContains(t, watcher.Start(context.Background()), "--watch requires --run")
+
+	mockLambdaRPC.AssertExpectations(t)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+const (
+	workerPoolDefaultWorkers      = 32
+	workerPoolDefaultQueueSize    = 128
+	workerPoolDefaultQueueTimeout = 30 * time.Second
+)
+
+// errWorkerPoolSaturated is returned for invocations the worker pool rejects because its queue is
+// full, or because they waited in it longer than the queue timeout.
+var errWorkerPoolSaturated = errors.New("worker pool saturated")
+
+// errWorkerPoolClosed is returned for invocations submitted after the worker pool was closed.
+var errWorkerPoolClosed = errors.New("worker pool closed")
+
+// states of a workerJob, the worker and the waiting request race to move it out of pending.
+const (
+	jobPending int32 = iota
+	jobRunning
+	jobAbandoned
+)
+
+// workerPool invokes the lambdas of the routes with a fixed number of workers. Invocations wait in
+// a bounded queue while every worker is busy and are rejected once it is full, so overload answers
+// with 429s instead of piling up goroutines that each dial the lambda.
+type workerPool struct {
+	workers      int
+	queueSize    int
+	queueTimeout time.Duration
+	jobs         chan *workerJob
+	quit         chan struct{}
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
+	logger       *slog.Logger
+
+	mu        sync.Mutex
+	active    int
+	queued    int
+	maxQueued int
+	completed int
+	rejected  int
+	timedOut  int
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+type workerJob struct {
+	ctx      context.Context //nolint:containedctx
+	caller   lambdaCaller
+	data     []byte
+	options  []Option
+	enqueued time.Time
+	state    atomic.Int32
+	// done receives the result of the invocation, it is buffered so workers never wait for
+	// abandoned jobs.
+	done chan workerResult
+}
+
+type workerResult struct {
+	response messages.InvokeResponse
+	err      error
+}
+
+type workerPoolMetrics struct {
+	Workers        int   `json:"workers"`
+	QueueSize      int   `json:"queueSize"`
+	QueueTimeoutMs int64 `json:"queueTimeoutMs"`
+	Active         int   `json:"active"`
+	Queued         int   `json:"queued"`
+	MaxQueued      int   `json:"maxQueued"`
+	Completed      int   `json:"completed"`
+	Rejected       int   `json:"rejected"`
+	TimedOut       int   `json:"timedOut"`
+	AvgWaitMs      int64 `json:"avgWaitMs"`
+	MaxWaitMs      int64 `json:"maxWaitMs"`
+}
+
+// newWorkerPool starts workers that take invocations from a queue of queueSize. Invocations are
+// rejected after waiting queueTimeout, 0 waits until a worker is free.
+func newWorkerPool(workers, queueSize int, queueTimeout time.Duration, logger *slog.Logger) *workerPool {
+	// submit admits at most workers+queueSize jobs, so sending one never blocks
+	pool := &workerPool{
+		workers:      workers,
+		queueSize:    queueSize,
+		queueTimeout: queueTimeout,
+		jobs:         make(chan *workerJob, workers+queueSize),
+		quit:         make(chan struct{}),
+		logger:       logger,
+	}
+
+	pool.wg.Add(workers)
+
+	for range workers {
+		go pool.work()
+	}
+
+	return pool
+}
+
+// caller returns a caller whose invocations of caller are run by the workers of the pool.
+func (p *workerPool) caller(caller lambdaCaller) lambdaCaller {
+	return workerPoolCaller{lambdaCaller: caller, pool: p}
+}
+
+// Close stops the workers once they finished their current invocation. Queued invocations are
+// rejected.
+func (p *workerPool) Close() {
+	p.closeOnce.Do(
+		func() {
+			close(p.quit)
+		},
+	)
+
+	p.wg.Wait()
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case job := <-p.jobs:
+			p.run(job)
+		}
+	}
+}
+
+// run invokes the lambda of job, unless the request waiting for it gave up.
+func (p *workerPool) run(job *workerJob) {
+	wait := time.Since(job.enqueued)
+
+	p.mu.Lock()
+	p.queued--
+
+	if !job.state.CompareAndSwap(jobPending, jobRunning) {
+		p.mu.Unlock()
+
+		return
+	}
+
+	p.active++
+	p.totalWait += wait
+	p.maxWait = max(p.maxWait, wait)
+	p.mu.Unlock()
+
+	response, err := job.caller.Invoke(job.ctx, job.data, job.options...)
+	job.done <- workerResult{response: response, err: err}
+
+	p.mu.Lock()
+	p.active--
+	p.completed++
+	p.mu.Unlock()
+}
+
+// submit queues an invocation and waits for its result.
+func (p *workerPool) submit(
+	ctx context.Context,
+	caller lambdaCaller,
+	data []byte,
+	options []Option,
+) (messages.InvokeResponse, error) {
+	job := &workerJob{
+		ctx:      ctx,
+		caller:   caller,
+		data:     data,
+		options:  options,
+		enqueued: time.Now(),
+		done:     make(chan workerResult, 1),
+	}
+
+	p.mu.Lock()
+
+	select {
+	case <-p.quit:
+		p.mu.Unlock()
+
+		return messages.InvokeResponse{}, errWorkerPoolClosed
+	default:
+	}
+
+	// jobs are queued until a worker takes them
+	if p.active+p.queued < p.workers+p.queueSize {
+		p.jobs <- job
+		p.queued++
+		p.maxQueued = max(p.maxQueued, p.queued)
+		p.mu.Unlock()
+	} else {
+		p.rejected++
+		p.mu.Unlock()
+
+		p.logger.Warn(
+			"Worker pool queue is full, rejecting the invocation",
+			"workers", p.workers,
+			"queueSize", p.queueSize,
+		)
+
+		return messages.InvokeResponse{}, fmt.Errorf(
+			"[in lambdalocal.workerPool.submit] %w: %d invocations are queued",
+			errWorkerPoolSaturated,
+			p.queueSize,
+		)
+	}
+
+	var timeout <-chan time.Time
+
+	if p.queueTimeout > 0 {
+		timer := time.NewTimer(p.queueTimeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-job.done:
+		return result.response, result.err
+	case <-p.quit:
+		if job.state.CompareAndSwap(jobPending, jobAbandoned) {
+			return messages.InvokeResponse{}, errWorkerPoolClosed
+		}
+	case <-ctx.Done():
+		// the request gave up while queued, the worker skips the job
+		if job.state.CompareAndSwap(jobPending, jobAbandoned) {
+			return messages.InvokeResponse{}, fmt.Errorf("[in lambdalocal.workerPool.submit] %w", ctx.Err())
+		}
+	case <-timeout:
+		if job.state.CompareAndSwap(jobPending, jobAbandoned) {
+			p.mu.Lock()
+			p.timedOut++
+			p.mu.Unlock()
+
+			p.logger.Warn("Invocation waited too long for a worker, rejecting it", "queueTimeout", p.queueTimeout)
+
+			return messages.InvokeResponse{}, fmt.Errorf(
+				"%w: no worker was free within %s",
+				errWorkerPoolSaturated,
+				p.queueTimeout,
+			)
+		}
+	}
+
+	// a worker took the job before it was abandoned
+	result := <-job.done
+
+	return result.response, result.err
+}
+
+// snapshot returns the current state of the pool.
+func (p *workerPool) snapshot() workerPoolMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics := workerPoolMetrics{
+		Workers:        p.workers,
+		QueueSize:      p.queueSize,
+		QueueTimeoutMs: p.queueTimeout.Milliseconds(),
+		Active:         p.active,
+		Queued:         p.queued,
+		MaxQueued:      p.maxQueued,
+		Completed:      p.completed,
+		Rejected:       p.rejected,
+		TimedOut:       p.timedOut,
+		MaxWaitMs:      p.maxWait.Milliseconds(),
+	}
+
+	if started := p.completed + p.active; started > 0 {
+		metrics.AvgWaitMs = (p.totalWait / time.Duration(started)).Milliseconds()
+	}
+
+	return metrics
+}
+
+type workerPoolCaller struct {
+	lambdaCaller
+	pool *workerPool
+}
+
+func (c workerPoolCaller) Invoke(ctx context.Context, data []byte, options ...Option) (messages.InvokeResponse, error) {
+	return c.pool.submit(ctx, c.lambdaCaller, data, options)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingLambdaCaller holds every invocation until release is closed.
+type blockingLambdaCaller struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingLambdaCaller() *blockingLambdaCaller {
+	return &blockingLambdaCaller{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (c *blockingLambdaCaller) Invoke(context.Context, []byte, ...Option) (messages.InvokeResponse, error) {
+	c.started <- struct{}{}
+	<-c.release
+
+	return messages.InvokeResponse{Payload: []byte(`{"statusCode":200}`)}, nil
+}
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(2, 1, 0, slog.New(slog.DiscardHandler))
+	defer pool.Close()
+
+	lambda := newBlockingLambdaCaller()
+	caller := pool.caller(lambda)
+
+	var wg sync.WaitGroup
+
+	// two invocations keep the workers busy, the third waits in the queue
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := caller.Invoke(context.Background(), []byte(`{}`))
+			errs <- err
+		}()
+	}
+
+	<-lambda.started
+	<-lambda.started
+
+	require.Eventually(
+		t, func() bool {
+			return pool.snapshot().Queued == 1
+		}, time.Second, time.Millisecond,
+	)
+
+	// the queue is full
+	_, err := caller.Invoke(context.Background(), []byte(`{}`))
+	require.ErrorIs(t, err, errWorkerPoolSaturated)
+
+	close(lambda.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	metrics := pool.snapshot()
+	assert.Equal(t, 2, metrics.Workers)
+	assert.Equal(t, 1, metrics.QueueSize)
+	assert.Equal(t, 0, metrics.Active)
+	assert.Equal(t, 0, metrics.Queued)
+	// invocations count as queued until a worker takes them, even while workers are free
+	assert.GreaterOrEqual(t, metrics.MaxQueued, 1)
+	assert.Equal(t, 3, metrics.Completed)
+	assert.Equal(t, 1, metrics.Rejected)
+}
+
+func TestWorkerPoolQueueTimeout(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(1, 1, 20*time.Millisecond, slog.New(slog.DiscardHandler))
+	defer pool.Close()
+
+	lambda := newBlockingLambdaCaller()
+	caller := pool.caller(lambda)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, _ = caller.Invoke(context.Background(), []byte(`{}`))
+	}()
+
+	<-lambda.started
+
+	// the only worker is busy longer than the queue timeout
+	_, err := caller.Invoke(context.Background(), []byte(`{}`))
+	require.ErrorIs(t, err, errWorkerPoolSaturated)
+	assert.ErrorContains(t, err, "no worker was free within 20ms")
+
+	close(lambda.release)
+	<-done
+
+	// the abandoned invocation is skipped by the worker
+	require.Eventually(
+		t, func() bool {
+			return pool.snapshot().Queued == 0
+		}, time.Second, time.Millisecond,
+	)
+
+	metrics := pool.snapshot()
+	assert.Equal(t, 1, metrics.Completed)
+	assert.Equal(t, 1, metrics.TimedOut)
+}
+
+func TestWorkerPoolCanceledWhileQueued(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(1, 1, 0, slog.New(slog.DiscardHandler))
+	defer pool.Close()
+
+	lambda := newBlockingLambdaCaller()
+	caller := pool.caller(lambda)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, _ = caller.Invoke(context.Background(), []byte(`{}`))
+	}()
+
+	<-lambda.started
+
+	// the request gives up while waiting for the only worker
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := caller.Invoke(ctx, []byte(`{}`))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(lambda.release)
+	<-done
+
+	require.Eventually(
+		t, func() bool {
+			return pool.snapshot().Queued == 0
+		}, time.Second, time.Millisecond,
+	)
+
+	assert.Equal(t, 1, pool.snapshot().Completed)
+}
+
+func TestWorkerPoolClose(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(1, 1, 0, slog.New(slog.DiscardHandler))
+	pool.Close()
+
+	_, err := pool.caller(newBlockingLambdaCaller()).Invoke(context.Background(), []byte(`{}`))
+	assert.ErrorIs(t, err, errWorkerPoolClosed)
+}
+
+func TestGatewayHandlerThrottled(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(1, 0, 0, slog.New(slog.DiscardHandler))
+	defer pool.Close()
+
+	lambda := newBlockingLambdaCaller()
+	route := apiRoute{method: http.MethodGet, path: "/orders", payloadFormat: payloadFormatV2}
+	handler := gatewayHandler(pool.caller(lambda), responseFormat{}, route, nil, slog.New(slog.DiscardHandler))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}()
+
+	<-lambda.started
+
+	// without a queue the request is throttled while the only worker is busy
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), http.StatusText(http.StatusTooManyRequests))
+
+	close(lambda.release)
+	<-done
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultWorkspacePath = "./lambdalocal.workspace.yaml"
+	// workspaceRestartDelay is how long a gateway that exited is waited for before it is restarted,
+	// doubled on every exit up to workspaceMaxRestartDelay.
+	workspaceRestartDelay    = time.Second
+	workspaceMaxRestartDelay = 30 * time.Second
+	// workspaceStableRun is how long a gateway has to run for its restart delay to be reset.
+	workspaceStableRun = time.Minute
+)
+
+// workspaceConfig is the format of the lambdalocal.workspace.yaml file, listing the independently
+// deployed services of a monorepo.
+type workspaceConfig struct {
+	// Services are the services started by `workspace up`, keyed by their name in the log.
+	Services map[string]workspaceService `yaml:"services"`
+}
+
+// workspaceService is a service of the workspace, served by its own api gateway.
+type workspaceService struct {
+	// Dir is the directory of the service, relative to the workspace file. The gateway runs in it,
+	// so the other paths and its lambdalocal.yaml are relative to it.
+	Dir string `yaml:"dir"`
+	// Template is the path of the service's template, ./template.yaml when unset.
+	Template string `yaml:"template"`
+	// Port is the port of the service's api gateway.
+	Port string `yaml:"port"`
+	// Address is the address of the service's lambda.
+	Address string `yaml:"address"`
+	// Protocol is the protocol used to invoke the lambda, rpc when unset.
+	Protocol string `yaml:"protocol"`
+	// Run is the shell command starting the lambda.
+	Run string `yaml:"run"`
+	// Build is the shell command building the lambda, run before the gateway starts.
+	Build string `yaml:"build"`
+	// Watch rebuilds and restarts the lambda when its sources change.
+	Watch bool `yaml:"watch"`
+	// URLEnv is the environment variable the other services find the URL of the gateway in,
+	// <NAME>_API_URL when unset.
+	URLEnv string `yaml:"urlEnv"`
+}
+
+// workspace is a loaded workspace file.
+type workspace struct {
+	// dir is the directory of the workspace file, service dirs are relative to it.
+	dir      string
+	services map[string]workspaceService
+	// discovery holds the environment variable pointing at the gateway of every service of the
+	// file, keyed by service name. Services that aren't started keep theirs, they may run elsewhere.
+	discovery map[string]string
+}
+
+// loadWorkspace reads and validates the workspace file at path.
+func loadWorkspace(path string, reader fileReader) (workspace, error) {
+	data, err := reader.read(path)
+	if err != nil {
+		return workspace{}, fmt.Errorf("[in lambdalocal.loadWorkspace] read file failed: %w", err)
+	}
+
+	config := workspaceConfig{}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return workspace{}, fmt.Errorf("[in lambdalocal.loadWorkspace] unmarshal yaml failed: %w", err)
+	}
+
+	if len(config.Services) == 0 {
+		return workspace{}, fmt.Errorf("[in lambdalocal.loadWorkspace] %s has no services", path)
+	}
+
+	var problems []string
+
+	ports := make(map[string]string)
+	addresses := make(map[string]string)
+	urlEnvs := make(map[string]string)
+	discovery := make(map[string]string, len(config.Services))
+
+	for _, name := range sortedServices(config.Services) {
+		service := config.Services[name]
+
+		if service.Port == "" {
+			problems = append(problems, fmt.Sprintf("service '%s' has no port", name))
+		} else if other, ok := ports[service.Port]; ok {
+			problems = append(problems, fmt.Sprintf("services '%s' and '%s' use port %s", other, name, service.Port))
+		} else {
+			ports[service.Port] = name
+		}
+
+		if err = validateAddress(service.Address); err != nil {
+			problems = append(problems, fmt.Sprintf("address of service '%s': %s", name, err))
+		} else if other, ok := addresses[service.Address]; ok {
+			problems = append(
+				problems,
+				fmt.Sprintf("services '%s' and '%s' use address %s", other, name, service.Address),
+			)
+		} else {
+			addresses[service.Address] = name
+		}
+
+		urlEnv := service.urlEnv(name)
+		if other, ok := urlEnvs[urlEnv]; ok {
+			problems = append(problems, fmt.Sprintf("services '%s' and '%s' use url variable %s", other, name, urlEnv))
+		} else {
+			urlEnvs[urlEnv] = name
+		}
+
+		discovery[name] = urlEnv + "=http://localhost:" + service.Port
+	}
+
+	if len(problems) > 0 {
+		return workspace{}, fmt.Errorf("[in lambdalocal.loadWorkspace] %s", strings.Join(problems, "; "))
+	}
+
+	return workspace{dir: filepath.Dir(path), services: config.Services, discovery: discovery}, nil
+}
+
+// selectServices keeps the services with the given names, all of them when names is empty.
+func (ws workspace) selectServices(names []string) (workspace, error) {
+	if len(names) == 0 {
+		return ws, nil
+	}
+
+	services := make(map[string]workspaceService, len(names))
+
+	for _, name := range names {
+		service, ok := ws.services[name]
+		if !ok {
+			return workspace{}, fmt.Errorf(
+				"[in lambdalocal.workspace.selectServices] unknown service '%s', expected one of %s",
+				name,
+				strings.Join(sortedServices(ws.services), ", "),
+			)
+		}
+
+		services[name] = service
+	}
+
+	return workspace{dir: ws.dir, services: services, discovery: ws.discovery}, nil
+}
+
+// siblingEnv returns the environment variables pointing at the gateways of the services other than
+// name, so service to service calls of its handler resolve locally.
+func (ws workspace) siblingEnv(name string) []string {
+	env := make([]string, 0, len(ws.discovery))
+
+	for sibling, variable := range ws.discovery {
+		if sibling != name {
+			env = append(env, variable)
+		}
+	}
+
+	sort.Strings(env)
+
+	return env
+}
+
+// urlEnv returns the environment variable holding the URL of the service's gateway, derived from
+// its name unless set.
+func (s workspaceService) urlEnv(name string) string {
+	if s.URLEnv != "" {
+		return s.URLEnv
+	}
+
+	return strings.Map(
+		func(r rune) rune {
+			if ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+				return r
+			}
+
+			return '_'
+		},
+		strings.ToUpper(name),
+	) + "_API_URL"
+}
+
+// gatewayArgs returns the lambdalocal arguments serving service with the api command.
+func (s workspaceService) gatewayArgs(verbose bool) []string {
+	args := []string{"--address", s.Address}
+
+	if s.Run != "" {
+		args = append(args, "--run", s.Run)
+	}
+
+	if verbose {
+		args = append(args, "--verbose")
+	}
+
+	args = append(args, "api", "--port", s.Port)
+
+	if s.Template != "" {
+		args = append(args, "--template", s.Template)
+	}
+
+	if s.Protocol != "" {
+		args = append(args, "--protocol", s.Protocol)
+	}
+
+	// with --watch the gateway runs the build on changes, without it the build is only run once
+	if s.Watch {
+		args = append(args, "--watch")
+
+		if s.Build != "" {
+			args = append(args, "--build", s.Build)
+		}
+	}
+
+	return args
+}
+
+// workspaceGateway is the supervised lambdalocal process serving a service of the workspace.
+type workspaceGateway struct {
+	name    string
+	service workspaceService
+	// dir is the directory the gateway runs in.
+	dir string
+	// executable is the lambdalocal binary started for the gateway.
+	executable string
+	verbose    bool
+	// env holds the variables added to the environment of the gateway, which passes them on to the
+	// lambda it runs.
+	env []string
+	// log is the combined log of all gateways, its lines are prefixed with the service name.
+	log    *workspaceLog
+	logger *slog.Logger
+}
+
+// supervise builds the service and runs its gateway, restarting it whenever it exits until ctx is
+// done.
+func (g *workspaceGateway) supervise(ctx context.Context) {
+	// with --watch the gateway builds the lambda on start itself
+	if g.service.Build != "" && !g.service.Watch {
+		g.logger.Info(fmt.Sprintf("Building %s: %s", g.name, g.service.Build))
+
+		build := shellCommand(g.service.Build)
+		build.Dir = g.dir
+
+		if output, err := build.CombinedOutput(); err != nil {
+			g.log.write(g.name, bytes.NewReader(output))
+			g.logger.Error(fmt.Sprintf("Build of %s failed, not starting it", g.name), "err", err)
+
+			return
+		}
+	}
+
+	delay := workspaceRestartDelay
+
+	for {
+		started := time.Now()
+
+		if err := g.run(ctx); err != nil {
+			g.logger.Warn(fmt.Sprintf("Gateway of %s exited", g.name), "err", err)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > workspaceStableRun {
+			delay = workspaceRestartDelay
+		}
+
+		g.logger.Info(fmt.Sprintf("Restarting %s in %s", g.name, delay))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, workspaceMaxRestartDelay) //nolint:mnd
+	}
+}
+
+// run starts the gateway and waits for it to exit, stopping it once ctx is done.
+func (g *workspaceGateway) run(ctx context.Context) error {
+	cmd := exec.Command(g.executable, g.service.gatewayArgs(g.verbose)...) //nolint:gosec
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), g.env...)
+
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout pipe failed: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("stderr pipe failed: %w", err)
+	}
+
+	g.logger.Info(fmt.Sprintf("Starting %s on port %s", g.name, g.service.Port))
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("start failed: %w", err)
+	}
+
+	var output sync.WaitGroup
+
+	output.Add(2) //nolint:mnd
+
+	for _, r := range []io.Reader{stdout, stderr} {
+		go func() {
+			defer output.Done()
+
+			g.log.write(g.name, r)
+		}()
+	}
+
+	exited := make(chan error, 1)
+
+	go func() {
+		output.Wait()
+
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-exited:
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return errors.New("exited without error")
+	case <-ctx.Done():
+	}
+
+	if err = terminateProcess(cmd); err != nil {
+		g.logger.Debug(fmt.Sprintf("Terminate %s failed", g.name), "err", err)
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(processStopTimeout):
+		g.logger.Warn(fmt.Sprintf("Gateway of %s did not exit in time, killing it", g.name))
+
+		_ = killProcess(cmd)
+
+		<-exited
+	}
+
+	return nil
+}
+
+// workspaceLog interleaves the output of the gateways line by line, prefixing every line with the
+// name of its service.
+type workspaceLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// width is the length of the longest service name, so the lines of all services align.
+	width int
+}
+
+// write copies the lines of r to the log.
+func (l *workspaceLog) write(name string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		l.mu.Lock()
+		_, _ = fmt.Fprintf(l.w, "%-*s | %s\n", l.width, name, scanner.Text())
+		l.mu.Unlock()
+	}
+}
+
+// RunWorkspace serves every service of ws with a supervised api gateway, running executable, and
+// logs their output to w until interrupted.
+func RunWorkspace(
+	ctx context.Context,
+	w io.Writer,
+	ws workspace,
+	executable string,
+	verbose bool,
+	logger *slog.Logger,
+) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	names := sortedServices(ws.services)
+
+	log := &workspaceLog{w: w}
+	for _, name := range names {
+		log.width = max(log.width, len(name))
+	}
+
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		gateway := &workspaceGateway{
+			name:       name,
+			service:    ws.services[name],
+			dir:        filepath.Join(ws.dir, ws.services[name].Dir),
+			executable: executable,
+			verbose:    verbose,
+			env:        ws.siblingEnv(name),
+			log:        log,
+			logger:     logger,
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			gateway.supervise(ctx)
+		}()
+	}
+
+	wg.Wait()
+
+	logger.Info("Workspace stopped")
+
+	return nil
+}
+
+func sortedServices(services map[string]workspaceService) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
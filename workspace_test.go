@@ -0,0 +1,230 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		workspace         string
+		expectedServices  map[string]workspaceService
+		expectedDiscovery map[string]string
+		expectedErr       string
+	}{
+		"services": {
+			workspace: `
+services:
+  orders:
+    dir: services/orders
+    port: 18080
+    address: localhost:9001
+    run: ./bin/fn
+    build: go build -o bin/fn .
+  users:
+    dir: services/users
+    template: sam.yaml
+    port: 18081
+    address: localhost:9002
+    protocol: runtime-api
+    watch: true
+    urlEnv: USERS_URL
+`,
+			expectedServices: map[string]workspaceService{
+				"orders": {
+					Dir:     "services/orders",
+					Port:    "18080",
+					Address: "localhost:9001",
+					Run:     "./bin/fn",
+					Build:   "go build -o bin/fn .",
+				},
+				"users": {
+					Dir:      "services/users",
+					Template: "sam.yaml",
+					Port:     "18081",
+					Address:  "localhost:9002",
+					Protocol: ProtocolRuntimeAPI,
+					Watch:    true,
+					URLEnv:   "USERS_URL",
+				},
+			},
+			expectedDiscovery: map[string]string{
+				"orders": "ORDERS_API_URL=http://localhost:18080",
+				"users":  "USERS_URL=http://localhost:18081",
+			},
+		},
+		"service without port": {
+			workspace: `
+services:
+  orders:
+    address: localhost:9001
+`,
+			expectedErr: "service 'orders' has no port",
+		},
+		"conflicting url variables": {
+			workspace: `
+services:
+  order-history:
+    port: 18080
+    address: localhost:9001
+  orders:
+    port: 18081
+    address: localhost:9002
+    urlEnv: ORDER_HISTORY_API_URL
+`,
+			expectedErr: "services 'order-history' and 'orders' use url variable ORDER_HISTORY_API_URL",
+		},
+		"no services": {
+			workspace:   `services: {}`,
+			expectedErr: "workspace.yaml has no services",
+		},
+		"conflicting ports and addresses": {
+			workspace: `
+services:
+  orders:
+    port: 18080
+    address: localhost:9001
+  users:
+    port: 18080
+    address: localhost:9001
+`,
+			expectedErr: "services 'orders' and 'users' use port 18080; services 'orders' and 'users' use address " +
+				"localhost:9001",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				reader := new(mockOSFileReader)
+				reader.On("read", "ws/workspace.yaml").Return([]byte(tc.workspace), nil)
+
+				ws, err := loadWorkspace("ws/workspace.yaml", reader)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "ws", ws.dir)
+				assert.Equal(t, tc.expectedServices, ws.services)
+				assert.Equal(t, tc.expectedDiscovery, ws.discovery)
+			},
+		)
+	}
+}
+
+func TestWorkspaceSelectServices(t *testing.T) {
+	t.Parallel()
+
+	ws := workspace{
+		dir: ".",
+		services: map[string]workspaceService{
+			"orders": {Port: "18080"},
+			"users":  {Port: "18081"},
+		},
+	}
+
+	selected, err := ws.selectServices([]string{"users"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]workspaceService{"users": {Port: "18081"}}, selected.services)
+
+	_, err = ws.selectServices([]string{"billing"})
+	require.ErrorContains(t, err, "unknown service 'billing', expected one of orders, users")
+}
+
+func TestWorkspaceSiblingEnv(t *testing.T) {
+	t.Parallel()
+
+	ws := workspace{
+		services: map[string]workspaceService{"orders": {Port: "18080"}},
+		discovery: map[string]string{
+			"billing": "BILLING_API_URL=http://localhost:18082",
+			"orders":  "ORDERS_API_URL=http://localhost:18080",
+			"users":   "USERS_API_URL=http://localhost:18081",
+		},
+	}
+
+	assert.Equal(
+		t,
+		[]string{"BILLING_API_URL=http://localhost:18082", "USERS_API_URL=http://localhost:18081"},
+		ws.siblingEnv("orders"),
+	)
+}
+
+func TestWorkspaceServiceGatewayArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		service      workspaceService
+		verbose      bool
+		expectedArgs []string
+	}{
+		"lambda started elsewhere": {
+			service:      workspaceService{Port: "18080", Address: "localhost:9001"},
+			expectedArgs: []string{"--address", "localhost:9001", "api", "--port", "18080"},
+		},
+		"managed lambda": {
+			service: workspaceService{
+				Port:     "18080",
+				Address:  "localhost:9001",
+				Template: "sam.yaml",
+				Protocol: ProtocolRuntimeAPI,
+				Run:      "./bin/fn",
+				Build:    "go build -o bin/fn .",
+			},
+			verbose: true,
+			expectedArgs: []string{
+				"--address", "localhost:9001", "--run", "./bin/fn", "--verbose",
+				"api", "--port", "18080", "--template", "sam.yaml", "--protocol", ProtocolRuntimeAPI,
+			},
+		},
+		"watched lambda": {
+			service: workspaceService{
+				Port:    "18080",
+				Address: "localhost:9001",
+				Run:     "./bin/fn",
+				Build:   "go build -o bin/fn .",
+				Watch:   true,
+			},
+			expectedArgs: []string{
+				"--address", "localhost:9001", "--run", "./bin/fn",
+				"api", "--port", "18080", "--watch", "--build", "go build -o bin/fn .",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				assert.Equal(t, tc.expectedArgs, tc.service.gatewayArgs(tc.verbose))
+			},
+		)
+	}
+}
+
+func TestWorkspaceLog(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+
+	log := &workspaceLog{w: &out, width: len("orders")}
+	log.write("users", strings.NewReader("Starting server\nGET /users\n"))
+	log.write("orders", strings.NewReader("Starting server"))
+
+	assert.Equal(
+		t,
+		"users  | Starting server\nusers  | GET /users\norders | Starting server\n",
+		out.String(),
+	)
+}
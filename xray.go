@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// traceIDHeader carries the X-Ray trace header of a request to the event and the invocation.
+const traceIDHeader = "X-Amzn-Trace-Id"
+
+// traceRootRegex matches the root trace ids of X-Ray: version 1, the epoch in seconds and a
+// random 96 bit id, both in hex.
+var traceRootRegex = regexp.MustCompile(`^1-[0-9a-f]{8}-[0-9a-f]{24}$`) //nolint:gochecknoglobals
+
+// requestTraceHeader returns the trace header of an invocation caused by a request with the trace
+// header header. Like API Gateway with tracing enabled, the trace of the client is continued with
+// a new parent segment, and requests without a valid trace start a new sampled trace.
+func requestTraceHeader(header string, now time.Time) string {
+	var root, sampled string
+
+	for field := range strings.SplitSeq(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+
+		switch key {
+		case "Root":
+			root = value
+		case "Sampled":
+			sampled = value
+		}
+	}
+
+	if !traceRootRegex.MatchString(root) {
+		root = fmt.Sprintf("1-%08x-%s", now.Unix(), randomHex(12)) //nolint:mnd
+		sampled = ""
+	}
+
+	// the client's decision is kept, undecided requests are sampled
+	if sampled != "0" {
+		sampled = "1"
+	}
+
+	return fmt.Sprintf("Root=%s;Parent=%s;Sampled=%s", root, randomHex(8), sampled) //nolint:mnd
+}
+
+// randomHex returns n random bytes in hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var traceHeaderRegex = regexp.MustCompile( //nolint:gochecknoglobals
+	`^Root=(1-[0-9a-f]{8}-[0-9a-f]{24});Parent=[0-9a-f]{16};Sampled=([01])$`,
+)
+
+func TestRequestTraceHeader(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0x5759e988, 0)
+
+	tests := map[string]struct {
+		header      string
+		wantRoot    string
+		wantSampled string
+	}{
+		"new trace": {
+			header:      "",
+			wantRoot:    "1-5759e988-",
+			wantSampled: "1",
+		},
+		"client trace": {
+			header:      "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+			wantRoot:    "1-5759e988-bd862e3fe1be46a994272793",
+			wantSampled: "1",
+		},
+		"client trace not sampled": {
+			header:      "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0",
+			wantRoot:    "1-5759e988-bd862e3fe1be46a994272793",
+			wantSampled: "0",
+		},
+		"client trace undecided": {
+			header:      "Sampled=?; Root=1-5759e988-bd862e3fe1be46a994272793",
+			wantRoot:    "1-5759e988-bd862e3fe1be46a994272793",
+			wantSampled: "1",
+		},
+		"invalid root": {
+			header:      "Root=abc;Sampled=0",
+			wantRoot:    "1-5759e988-",
+			wantSampled: "1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				header := requestTraceHeader(tc.header, now)
+
+				match := traceHeaderRegex.FindStringSubmatch(header)
+				require.NotNil(t, match, header)
+				assert.Contains(t, match[1], tc.wantRoot)
+				assert.Equal(t, tc.wantSampled, match[2])
+			},
+		)
+	}
+}
+
+func TestGatewayHandlerTraceHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		header   string
+		wantRoot string
+	}{
+		"generated": {},
+		"forwarded": {
+			header:   "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+			wantRoot: "Root=1-5759e988-bd862e3fe1be46a994272793;",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+
+				caller := new(recordingLambdaCaller)
+				route := apiRoute{method: http.MethodGet, path: "/test", payloadFormat: payloadFormatV2}
+
+				r := httptest.NewRequest(http.MethodGet, "/test", nil)
+				if tc.header != "" {
+					r.Header.Set(traceIDHeader, tc.header)
+				}
+
+				gatewayHandler(caller, responseFormat{}, route, nil, slog.Default()).
+					ServeHTTP(httptest.NewRecorder(), r)
+
+				// the invocation and the event have the same trace header
+				var event httpAPIEvent
+				require.NoError(t, json.Unmarshal(caller.data, &event))
+
+				assert.Regexp(t, traceHeaderRegex, caller.options.traceID)
+				assert.Equal(t, caller.options.traceID, event.Headers["x-amzn-trace-id"])
+				assert.Contains(t, caller.options.traceID, tc.wantRoot)
+			},
+		)
+	}
+}